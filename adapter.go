@@ -0,0 +1,112 @@
+package govault
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+
+	"github.com/muhammadluth/govault/internal"
+)
+
+// AdapterServices is what govault offers adapters to encrypt and decrypt
+// the models of their queries. It is the GovaultDB the adapter is created
+// for; adapters should depend on this interface only, it stays compatible
+// across releases.
+//
+// A query wrapper typically calls BeforeEncrypt and EncryptModel on the
+// models it writes, with the key of the query or "" for the default key,
+// and DecryptRecursiveContext and AfterDecrypt on the rows it scans.
+type AdapterServices interface {
+	Cryptor
+
+	// EncryptModel encrypts the fields of model tagged encrypted:"true"
+	// in place, with keyID or the default key
+	EncryptModel(model any, keyID string) error
+	// DecryptRecursiveContext decrypts the encrypted fields of value and
+	// the models it holds in place
+	DecryptRecursiveContext(ctx context.Context, value any) error
+	// BeforeEncrypt runs the ModelHooks of model before a write
+	BeforeEncrypt(operation Operation, model any) error
+	// AfterDecrypt runs the ModelHooks of the scanned dest
+	AfterDecrypt(ctx context.Context, operation Operation, dest ...any) error
+	// EncryptField and DecryptField encrypt and decrypt one column value,
+	// e.g. for query arguments; DecryptField reports false for values that
+	// are not ciphertexts
+	EncryptField(field *FieldSpec, table, plaintext, keyID string) (string, error)
+	DecryptField(field *FieldSpec, table, value string) (string, bool, error)
+	// BlindIndex computes the blind index of plaintext for the named field
+	// of model, for equality lookups
+	BlindIndex(model any, fieldName, plaintext string) (string, error)
+	// ModelSpec returns the encrypted fields of model, from its tags and
+	// Config.Fields
+	ModelSpec(model any) *ModelSpec
+}
+
+var _ AdapterServices = (*internal.GovaultDB)(nil)
+
+// ModelSpec is the encryption metadata of a model struct, read from its
+// tags and Config.Fields, see GovaultDB.ModelSpec
+type ModelSpec = internal.ModelSpec
+
+// FieldSpec is the encryption metadata of one encrypted field
+type FieldSpec = internal.FieldSpec
+
+// GetModelSpec returns the encryption metadata of model, a struct or a
+// pointer to one, or nil for other types, from its struct tags. Specs are
+// cached per type. GovaultDB.ModelSpec adds the columns of Config.Fields.
+func GetModelSpec(model any) *ModelSpec {
+	return internal.GetModelSpec(model)
+}
+
+// AdapterFactory wraps the database of an adapter, Config.AdapterDB, with
+// query methods encrypting and decrypting through services. The result is
+// returned by GovaultDB.Adapter.
+type AdapterFactory func(db any, services AdapterServices) (any, error)
+
+var (
+	adaptersMu sync.RWMutex
+	adapters   = make(map[AdapterName]AdapterFactory)
+)
+
+// RegisterAdapter makes an adapter for another ORM available under name,
+// selected with Config.AdapterName and Config.AdapterDB or WithAdapter. It
+// is meant to be called from the init function of the adapter package and
+// panics when name is taken or factory is nil, like sql.Register.
+func RegisterAdapter(name AdapterName, factory AdapterFactory) {
+	adaptersMu.Lock()
+	defer adaptersMu.Unlock()
+	if factory == nil {
+		panic("govault: RegisterAdapter factory is nil")
+	}
+	if _, dup := adapters[name]; dup || name == AdapterNameBun || name == AdapterNameGoPg {
+		panic(fmt.Sprintf("govault: RegisterAdapter called twice for adapter %s", name))
+	}
+	adapters[name] = factory
+}
+
+// Adapters returns the names of the registered adapters, sorted
+func Adapters() []AdapterName {
+	adaptersMu.RLock()
+	defer adaptersMu.RUnlock()
+	names := make([]AdapterName, 0, len(adapters))
+	for name := range adapters {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// lookupAdapter returns the factory registered under name
+func lookupAdapter(name AdapterName) (AdapterFactory, bool) {
+	adaptersMu.RLock()
+	defer adaptersMu.RUnlock()
+	factory, ok := adapters[name]
+	return factory, ok
+}
+
+// Adapter returns the database wrapped by the adapter, e.g. the result of
+// a registered AdapterFactory or the *gb.BunDB of BunDB
+func (g *GovaultDB) Adapter() any {
+	return g.DB
+}
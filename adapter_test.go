@@ -0,0 +1,95 @@
+package govault_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/muhammadluth/govault"
+	"github.com/muhammadluth/govault/govaulttest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryStore is the database of the memory adapter
+type memoryStore struct {
+	rows map[int]adapterUser
+}
+
+// memoryDB is an out-of-tree style adapter over memoryStore
+type memoryDB struct {
+	store    *memoryStore
+	services govault.AdapterServices
+}
+
+func (db *memoryDB) Insert(id int, user adapterUser) error {
+	if err := db.services.BeforeEncrypt(govault.OperationInsert, &user); err != nil {
+		return err
+	}
+	if err := db.services.EncryptModel(&user, ""); err != nil {
+		return err
+	}
+	db.store.rows[id] = user
+	return nil
+}
+
+func (db *memoryDB) Get(ctx context.Context, id int) (adapterUser, error) {
+	user := db.store.rows[id]
+	if err := db.services.DecryptRecursiveContext(ctx, &user); err != nil {
+		return user, err
+	}
+	return user, db.services.AfterDecrypt(ctx, govault.OperationSelect, &user)
+}
+
+type adapterUser struct {
+	Name  string
+	Email string `encrypted:"true"`
+}
+
+func init() {
+	govault.RegisterAdapter("memory", func(db any, services govault.AdapterServices) (any, error) {
+		store, ok := db.(*memoryStore)
+		if !ok {
+			return nil, assert.AnError
+		}
+		return &memoryDB{store: store, services: services}, nil
+	})
+}
+
+func TestRegisterAdapter(t *testing.T) {
+	store := &memoryStore{rows: make(map[int]adapterUser)}
+	govaultDB, err := govault.NewWithOptions(
+		govault.WithAdapter("memory", store),
+		govault.WithKeys(govaulttest.EphemeralKeys(1)),
+		govault.WithDefaultKey("1"),
+	)
+	require.NoError(t, err)
+	assert.Contains(t, govault.Adapters(), govault.AdapterName("memory"))
+	assert.Nil(t, govaultDB.BunDB())
+
+	db := govaultDB.Adapter().(*memoryDB)
+	require.NoError(t, db.Insert(1, adapterUser{Name: "Ada", Email: "ada@example.com"}))
+	assert.True(t, govault.IsEncrypted(store.rows[1].Email))
+	assert.Equal(t, "Ada", store.rows[1].Name)
+
+	user, err := db.Get(context.Background(), 1)
+	require.NoError(t, err)
+	assert.Equal(t, "ada@example.com", user.Email)
+
+	spec := govault.GetModelSpec(adapterUser{})
+	require.NotNil(t, spec)
+	assert.Equal(t, "email", spec.Field("Email").Column)
+
+	t.Run("factory errors", func(t *testing.T) {
+		_, err := govault.NewWithOptions(govault.WithAdapter("memory", "not a store"), govault.WithKeys(govaulttest.EphemeralKeys(1)), govault.WithDefaultKey("1"))
+		assert.ErrorIs(t, err, assert.AnError)
+		_, err = govault.NewWithOptions(govault.WithAdapter("memory", nil), govault.WithKeys(govaulttest.EphemeralKeys(1)), govault.WithDefaultKey("1"))
+		assert.ErrorContains(t, err, "AdapterDB is nil")
+	})
+
+	t.Run("names are registered once", func(t *testing.T) {
+		factory := func(any, govault.AdapterServices) (any, error) { return nil, nil }
+		assert.Panics(t, func() { govault.RegisterAdapter("memory", factory) })
+		assert.Panics(t, func() { govault.RegisterAdapter(govault.AdapterNameBun, factory) })
+		assert.Panics(t, func() { govault.RegisterAdapter("other", nil) })
+	})
+}
@@ -0,0 +1,227 @@
+// Package govault - Bun adapter blind index rewriting of aggregate queries
+package bun
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/muhammadluth/govault/internal"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/schema"
+)
+
+// UseBlindIndexes makes Scan group by blind indexes: encrypted columns
+// with a blind index are replaced by their blind index column in GROUP BY,
+// DISTINCT ON and aggregate DISTINCT arguments such as
+// count(DISTINCT email). A grouped column selected on its own becomes
+// min(column), one of the ciphertexts of its group, and decrypts to the
+// plaintext of the group. Plaintexts whose truncated blind indexes collide
+// fall in the same group.
+func (q *BunSelectQuery) UseBlindIndexes() *BunSelectQuery {
+	q.blindIndexes = true
+	return q
+}
+
+// modelSpec returns the bun table and the encryption spec of the model
+func (q *BunSelectQuery) modelSpec() (*schema.Table, *internal.ModelSpec) {
+	return modelTableSpec(q.govault, q.GetModel())
+}
+
+// scanBlindIndexes runs the query rewritten by rewriteBlindIndexes
+func (q *BunSelectQuery) scanBlindIndexes(ctx context.Context, table *schema.Table, spec *internal.ModelSpec, dest ...any) error {
+	query, err := q.SelectQuery.AppendQuery(q.DB().QueryGen(), nil)
+	if err != nil {
+		return err
+	}
+	rewritten := rewriteBlindIndexes(string(query), spec, table.Alias, table.Name)
+	if !q.allowEncrypted {
+		if err := checkClauses(tokenizeSQL(rewritten), spec, table.Alias, table.Name); err != nil {
+			return err
+		}
+	}
+
+	if len(dest) == 0 {
+		dest = []any{q.GetModel().Value()}
+	}
+	conn := q.conn
+	if conn == nil {
+		conn = q.DB()
+	}
+	// The statement is already formatted, bun.Safe keeps it as is
+	if err := conn.NewRaw("?", bun.Safe(rewritten)).Scan(ctx, dest...); err != nil {
+		return err
+	}
+	for _, d := range dest {
+		if err := q.govault.DecryptRecursiveContext(ctx, d); err != nil {
+			return err
+		}
+	}
+	return q.govault.AfterDecrypt(ctx, internal.OperationSelect, dest...)
+}
+
+// sqlEdit replaces the bytes from start to end of a statement
+type sqlEdit struct {
+	start, end int
+	text       string
+}
+
+// rewriteBlindIndexes substitutes blind index columns for the encrypted
+// columns of the model in the GROUP BY, DISTINCT ON and aggregate DISTINCT
+// of a formatted SELECT, and wraps the grouped columns selected on their
+// own in min()
+func rewriteBlindIndexes(query string, spec *internal.ModelSpec, alias, tableName string) string {
+	indexes := make(map[string]string)
+	for _, field := range spec.Fields {
+		if field.BlindIndex != "" {
+			indexes[field.Column] = field.BlindIndex
+		}
+	}
+	if len(indexes) == 0 {
+		return query
+	}
+
+	tokens := tokenizeSQL(query)
+	var edits []sqlEdit
+	grouped := make(map[string]bool)
+	// replace rewrites the column reference at tokens[i] if it has a blind
+	// index and returns the index of its last token
+	replace := func(i int) (int, bool) {
+		j, ok := columnRef(tokens, i, alias, tableName)
+		index, indexed := indexes[tokens[j].text]
+		if !ok || !indexed {
+			return j, false
+		}
+		text := index
+		if tokens[j].kind == tokIdent {
+			text = quoteIdent(index)
+		}
+		edits = append(edits, sqlEdit{tokens[j].start, tokens[j].end, text})
+		return j, true
+	}
+
+	var (
+		clause     string
+		depth      int
+		items      [][2]int // token ranges of the top level select items
+		itemStart  = -1
+		distinctOn bool
+	)
+	endItem := func(end int) {
+		if clause == "SELECT" && itemStart >= 0 && end >= itemStart {
+			items = append(items, [2]int{itemStart, end})
+		}
+		itemStart = -1
+	}
+	for i := 0; i < len(tokens); i++ {
+		t := tokens[i]
+
+		// Sub-queries select from other tables
+		if t.text == "(" && i+1 < len(tokens) && isKeyword(tokens[i+1], "SELECT") {
+			i = skipParens(tokens, i)
+			continue
+		}
+
+		if depth == 0 && t.kind == tokWord {
+			next := ""
+			if i+1 < len(tokens) {
+				next = strings.ToUpper(tokens[i+1].text)
+			}
+			word := strings.ToUpper(t.text)
+			switch {
+			case word == "SELECT":
+				clause = "SELECT"
+				if next == "DISTINCT" && i+2 < len(tokens) && isKeyword(tokens[i+2], "ON") {
+					distinctOn = true
+					i += 2
+				} else if next == "DISTINCT" || next == "ALL" {
+					i++
+				}
+				continue
+			case (word == "GROUP" || word == "ORDER") && next == "BY":
+				endItem(i - 1)
+				clause = word + " BY"
+				i++
+				continue
+			case word == "FROM" || word == "WHERE" || word == "HAVING" || word == "LIMIT" || word == "OFFSET" ||
+				word == "FETCH" || word == "FOR" || word == "WINDOW" || word == "UNION" || word == "EXCEPT" || word == "INTERSECT":
+				endItem(i - 1)
+				clause = word
+				continue
+			}
+		}
+
+		switch t.text {
+		case "(":
+			depth++
+			if i+2 < len(tokens) && isKeyword(tokens[i+1], "DISTINCT") {
+				i, _ = replace(i + 2)
+			}
+			continue
+		case ")":
+			depth--
+			if distinctOn && depth == 0 {
+				distinctOn = false
+			}
+			continue
+		case ",":
+			if depth == 0 {
+				endItem(i - 1)
+			}
+			continue
+		}
+
+		switch {
+		case distinctOn:
+			i, _ = replace(i)
+		case clause == "GROUP BY":
+			j, ok := replace(i)
+			if ok {
+				grouped[tokens[j].text] = true
+			}
+			i = j
+		case clause == "SELECT" && depth == 0 && itemStart < 0:
+			itemStart = i
+		}
+	}
+	endItem(len(tokens) - 1)
+
+	// Selected grouped columns are not functionally dependent on the blind
+	// index, any ciphertext of the group has the plaintext of the group
+	for _, item := range items {
+		j, ok := columnRef(tokens, item[0], alias, tableName)
+		if !ok || !grouped[tokens[j].text] {
+			continue
+		}
+		name := query[tokens[j].start:tokens[j].end]
+		switch {
+		case j == item[1]:
+		case j+2 == item[1] && isKeyword(tokens[j+1], "AS"):
+			name = query[tokens[item[1]].start:tokens[item[1]].end]
+		default:
+			continue
+		}
+		ref := query[tokens[item[0]].start:tokens[j].end]
+		edits = append(edits, sqlEdit{tokens[item[0]].start, tokens[item[1]].end, "min(" + ref + ") AS " + name})
+	}
+
+	sort.Slice(edits, func(a, b int) bool { return edits[a].start > edits[b].start })
+	for _, e := range edits {
+		query = query[:e.start] + e.text + query[e.end:]
+	}
+	return query
+}
+
+// columnRef reads a possibly qualified column reference at tokens[i] and
+// returns the index of its column token, and whether it refers to the
+// model table
+func columnRef(tokens []sqlToken, i int, alias, tableName string) (int, bool) {
+	t := tokens[i]
+	if t.kind != tokWord && t.kind != tokIdent {
+		return i, false
+	}
+	if i+2 < len(tokens) && tokens[i+1].text == "." {
+		return i + 2, t.text == alias || t.text == tableName
+	}
+	return i, true
+}
@@ -0,0 +1,80 @@
+// Package govault - Bun adapter blind index aggregate tests
+package bun_test
+
+import (
+	"context"
+	"testing"
+
+	gb "github.com/muhammadluth/govault/bun"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uptrace/bun"
+)
+
+type TestPhoneCount struct {
+	bun.BaseModel `bun:"table:test_catalog_users"`
+	Phone         string `bun:"phone" encrypted:"true" blindindex:"phone_bidx"`
+	Count         int    `bun:"n,scanonly"`
+}
+
+func TestBunUseBlindIndexes(t *testing.T) {
+	db, _, cleanup := setupTestDB(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	_, err := db.NewCreateTable().Model((*TestCatalogUser)(nil)).IfNotExists().Exec(ctx)
+	require.NoError(t, err)
+	defer db.NewDropTable().Model((*TestCatalogUser)(nil)).IfExists().Exec(ctx)
+
+	for _, phone := range []string{"+62811110000", "+62822220000", "+62811110000"} {
+		_, err := db.NewInsert().Model(&TestCatalogUser{Email: phone + "@example.com", Phone: phone}).Exec(ctx)
+		require.NoError(t, err)
+	}
+
+	t.Run("group by", func(t *testing.T) {
+		var counts []TestPhoneCount
+		err := db.NewSelect().Model(&counts).
+			Column("phone").ColumnExpr("count(*) AS n").
+			Group("phone").Order("n DESC").
+			UseBlindIndexes().Scan(ctx)
+		require.NoError(t, err)
+		require.Len(t, counts, 2)
+		assert.Equal(t, "+62811110000", counts[0].Phone)
+		assert.Equal(t, 2, counts[0].Count)
+		assert.Equal(t, "+62822220000", counts[1].Phone)
+		assert.Equal(t, 1, counts[1].Count)
+	})
+
+	t.Run("count distinct", func(t *testing.T) {
+		var n int
+		err := db.NewSelect().Model((*TestCatalogUser)(nil)).
+			ColumnExpr("count(DISTINCT phone)").
+			UseBlindIndexes().Scan(ctx, &n)
+		require.NoError(t, err)
+		assert.Equal(t, 2, n)
+
+		// Randomized ciphertexts are all distinct
+		err = db.NewSelect().Model((*TestCatalogUser)(nil)).
+			ColumnExpr("count(DISTINCT phone)").Scan(ctx, &n)
+		require.NoError(t, err)
+		assert.Equal(t, 3, n)
+	})
+
+	t.Run("not opted in", func(t *testing.T) {
+		var counts []TestPhoneCount
+		err := db.NewSelect().Model(&counts).
+			Column("phone").ColumnExpr("count(*) AS n").
+			Group("phone").Scan(ctx)
+		assert.ErrorIs(t, err, gb.ErrEncryptedColumn)
+	})
+
+	t.Run("deterministic column kept", func(t *testing.T) {
+		var counts []TestPhoneCount
+		err := db.NewSelect().Model((*TestCatalogUser)(nil)).
+			Column("phone").ColumnExpr("count(*) AS n").
+			Group("email", "phone").
+			UseBlindIndexes().Scan(ctx, &counts)
+		require.NoError(t, err)
+		assert.Len(t, counts, 2)
+	})
+}
@@ -0,0 +1,124 @@
+// Package govault - Bun adapter algorithm migration
+package bun
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/muhammadluth/govault/internal"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/schema"
+)
+
+// AlgorithmMigrationOptions configures MigrateAlgorithm
+type AlgorithmMigrationOptions struct {
+	BatchSize int // defaults to DefaultRotationBatchSize
+}
+
+// AlgorithmMigrationResult reports the values rewritten by MigrateAlgorithm
+// per column
+type AlgorithmMigrationResult struct {
+	Columns []AlgorithmColumnResult `json:"columns"`
+}
+
+// AlgorithmColumnResult is the outcome of one encrypted column
+type AlgorithmColumnResult struct {
+	Table     string             `json:"table"`
+	Column    string             `json:"column"`
+	Algorithm internal.Algorithm `json:"algorithm"`
+	Scanned   int64              `json:"scanned"`
+	Migrated  int64              `json:"migrated"`
+	// Conflicts counts rows changed concurrently, migrated by the next run
+	Conflicts int64 `json:"conflicts"`
+}
+
+// MigrateAlgorithm rewrites the native ciphertexts of the encrypted
+// columns of models sealed with another algorithm than their field's, see
+// the algorithm tag. Values keep their key unless it is decrypt-only or
+// archived, then they move to the default key.
+//
+// Reads decrypt every value with the algorithm in its header, so columns
+// may be migrated in batches or lazily: updates encrypt with the field's
+// algorithm, and SkipUnchanged rewrites values on another one.
+func (db *BunDB) MigrateAlgorithm(ctx context.Context, models []any, opts AlgorithmMigrationOptions) (*AlgorithmMigrationResult, error) {
+	if err := checkRewriteMode(db.govault); err != nil {
+		return nil, err
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = DefaultRotationBatchSize
+	}
+
+	result := &AlgorithmMigrationResult{}
+	for _, model := range models {
+		spec := db.govault.ModelSpec(model)
+		if spec == nil {
+			return nil, fmt.Errorf("model must be a struct, got %T", model)
+		}
+		pks := db.DB.Table(spec.Type).PKs
+		if len(pks) != 1 {
+			return nil, fmt.Errorf("%s: algorithm migration requires a single column primary key", spec.Table)
+		}
+
+		for _, field := range spec.Fields {
+			if field.Codec != "" {
+				continue
+			}
+			columnResult, err := db.migrateColumn(ctx, spec, field, pks[0].Name, opts.BatchSize)
+			if err != nil {
+				return nil, err
+			}
+			result.Columns = append(result.Columns, *columnResult)
+		}
+	}
+	return result, nil
+}
+
+// migrateColumn rewrites the values of field on another algorithm
+func (db *BunDB) migrateColumn(ctx context.Context, spec *internal.ModelSpec, field *internal.FieldSpec, primaryKey string, batchSize int) (*AlgorithmColumnResult, error) {
+	algorithm := field.Algorithm
+	if algorithm == "" {
+		algorithm = internal.AlgorithmAESGCM
+	}
+	result := &AlgorithmColumnResult{Table: spec.Table, Column: field.Column, Algorithm: algorithm}
+
+	total, err := db.rewriteColumns(ctx, &rewrite{
+		table:      spec.Table,
+		primaryKey: primaryKey,
+		columns:    []rewriteColumn{{name: field.Column, binary: field.Binary}},
+		where:      []schema.QueryWithArgs{bun.SafeQuery("? IS NOT NULL", bun.Ident(field.Column))},
+		batchSize:  batchSize,
+		row: func(pk any, values []string) (map[string]any, error) {
+			result.Scanned++
+			if !internal.NeedsAlgorithmMigration(field, values[0]) {
+				return nil, nil
+			}
+			migrated, err := db.reencryptAlgorithm(field, spec.Table, values[0])
+			if err != nil {
+				return nil, fmt.Errorf("column %s: %w", field.Column, err)
+			}
+			return map[string]any{field.Column: migrated}, nil
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to migrate %s.%s: %w", spec.Table, field.Column, err)
+	}
+	result.Migrated, result.Conflicts = total.rewritten, total.conflicts
+	return result, nil
+}
+
+// reencryptAlgorithm decrypts a native ciphertext and encrypts it with the
+// algorithm of field, under the same key when it may still encrypt
+func (db *BunDB) reencryptAlgorithm(field *internal.FieldSpec, table, ciphertext string) (string, error) {
+	plaintext, err := db.govault.DecryptStrict(ciphertext)
+	if err != nil {
+		return "", err
+	}
+	keyID, err := db.govault.GetKeyIDFromEncryptedData(ciphertext)
+	if err != nil {
+		return "", err
+	}
+	if !hasKey(db.govault, keyID) || db.govault.IsDecryptOnly(keyID) {
+		keyID = ""
+	}
+	return db.govault.EncryptField(field, table, plaintext, keyID)
+}
@@ -0,0 +1,114 @@
+// Package govault - Bun adapter algorithm migration tests
+package bun_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/muhammadluth/govault"
+	gb "github.com/muhammadluth/govault/bun"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uptrace/bun"
+)
+
+type TestAlgorithmUser struct {
+	bun.BaseModel `bun:"table:test_algorithm_users"`
+	ID            int64  `bun:"id,pk,autoincrement"`
+	Email         string `bun:"email" encrypted:"true"`
+	Phone         string `bun:"phone" encrypted:"true"`
+}
+
+// TestAlgorithmUserX is TestAlgorithmUser after switching email to XChaCha
+type TestAlgorithmUserX struct {
+	bun.BaseModel `bun:"table:test_algorithm_users"`
+	ID            int64  `bun:"id,pk,autoincrement"`
+	Email         string `bun:"email" encrypted:"true" algorithm:"xchacha20poly1305"`
+	Phone         string `bun:"phone" encrypted:"true"`
+}
+
+func TestBunMigrateAlgorithm(t *testing.T) {
+	db, _, cleanup := setupTestDB(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	_, err := db.NewCreateTable().Model((*TestAlgorithmUser)(nil)).IfNotExists().Exec(ctx)
+	require.NoError(t, err)
+	defer db.NewDropTable().Model((*TestAlgorithmUser)(nil)).IfExists().Exec(ctx)
+
+	for _, email := range []string{"a@example.com", "b@example.com", "c@example.com"} {
+		_, err := db.NewInsert().Model(&TestAlgorithmUser{Email: email, Phone: "555"}).Exec(ctx)
+		require.NoError(t, err)
+	}
+
+	// Old values read through the new model before the migration
+	var users []TestAlgorithmUserX
+	require.NoError(t, db.NewSelect().Model(&users).Order("id").Scan(ctx, &users))
+	require.Len(t, users, 3)
+	assert.Equal(t, "a@example.com", users[0].Email)
+
+	result, err := db.MigrateAlgorithm(ctx, []any{(*TestAlgorithmUserX)(nil)}, gb.AlgorithmMigrationOptions{BatchSize: 2})
+	require.NoError(t, err)
+	require.Len(t, result.Columns, 2)
+	assert.Equal(t, govault.AlgorithmXChaCha20Poly1305, result.Columns[0].Algorithm)
+	assert.EqualValues(t, 3, result.Columns[0].Migrated)
+	assert.EqualValues(t, 0, result.Columns[1].Migrated, "phone stays on AES-GCM")
+
+	var raw []string
+	require.NoError(t, db.DB.NewSelect().Table("test_algorithm_users").Column("email").Order("id").Scan(ctx, &raw))
+	for _, value := range raw {
+		algorithm, err := govault.AlgorithmOf(value)
+		require.NoError(t, err)
+		assert.Equal(t, govault.AlgorithmXChaCha20Poly1305, algorithm)
+	}
+
+	users = nil
+	require.NoError(t, db.NewSelect().Model(&users).Order("id").Scan(ctx, &users))
+	assert.Equal(t, "c@example.com", users[2].Email)
+
+	again, err := db.MigrateAlgorithm(ctx, []any{(*TestAlgorithmUserX)(nil)}, gb.AlgorithmMigrationOptions{})
+	require.NoError(t, err)
+	assert.EqualValues(t, 0, again.Columns[0].Migrated)
+}
+
+// TestBinaryUserX is TestBinaryUser after switching email to XChaCha
+type TestBinaryUserX struct {
+	bun.BaseModel `bun:"table:test_binary_users"`
+	ID            int64  `bun:"id,pk,autoincrement"`
+	Email         string `bun:"email,type:bytea" encrypted:"true" storage:"binary" algorithm:"xchacha20poly1305"`
+	SSN           string `bun:"ssn,type:bytea" encrypted:"true" deterministic:"true" storage:"binary"`
+}
+
+func TestBunMigrateAlgorithmBinary(t *testing.T) {
+	db, _, cleanup := setupTestDB(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	_, err := db.NewCreateTable().Model((*TestBinaryUser)(nil)).IfNotExists().Exec(ctx)
+	require.NoError(t, err)
+	defer db.NewDropTable().Model((*TestBinaryUser)(nil)).IfExists().Exec(ctx)
+
+	for _, email := range []string{"a@example.com", "b@example.com", "c@example.com"} {
+		_, err := db.NewInsert().Model(&TestBinaryUser{Email: email, SSN: "123-45-6789"}).Exec(ctx)
+		require.NoError(t, err)
+	}
+
+	result, err := db.MigrateAlgorithm(ctx, []any{(*TestBinaryUserX)(nil)}, gb.AlgorithmMigrationOptions{BatchSize: 2})
+	require.NoError(t, err)
+	assert.EqualValues(t, 3, result.Columns[0].Migrated)
+	assert.Zero(t, result.Columns[0].Conflicts, "binary values compare bytewise")
+
+	var raw [][]byte
+	require.NoError(t, db.DB.NewSelect().Table("test_binary_users").Column("email").Order("id").Scan(ctx, &raw))
+	for _, stored := range raw {
+		value, err := govault.DecodeBinary(stored)
+		require.NoError(t, err)
+		algorithm, err := govault.AlgorithmOf(value)
+		require.NoError(t, err)
+		assert.Equal(t, govault.AlgorithmXChaCha20Poly1305, algorithm)
+	}
+
+	var users []TestBinaryUserX
+	require.NoError(t, db.NewSelect().Model(&users).Order("id").Scan(ctx, &users))
+	assert.Equal(t, "c@example.com", users[2].Email)
+}
@@ -0,0 +1,57 @@
+// Package govault - Bun adapter array column tests
+package bun_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/muhammadluth/govault"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect/pgdialect"
+)
+
+type TestArrayUser struct {
+	bun.BaseModel `bun:"table:test_array_users"`
+	ID            int64    `bun:"id,pk,autoincrement"`
+	Phones        []string `bun:"phones,array" encrypted:"true"`
+	Emails        []string `bun:"emails,array" encrypted:"true" encryptarray:"whole"`
+}
+
+func TestBunArrayColumns(t *testing.T) {
+	db, _, cleanup := setupTestDB(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	_, err := db.NewCreateTable().Model((*TestArrayUser)(nil)).IfNotExists().Exec(ctx)
+	require.NoError(t, err)
+	defer db.NewDropTable().Model((*TestArrayUser)(nil)).IfExists().Exec(ctx)
+
+	user := &TestArrayUser{
+		Phones: []string{"+62811", "+62812"},
+		Emails: []string{"a@example.com", "b@example.com", "c@example.com"},
+	}
+	_, err = db.NewInsert().Model(user).Exec(ctx)
+	require.NoError(t, err)
+
+	t.Run("elements are stored encrypted", func(t *testing.T) {
+		var phones, emails []string
+		err := db.DB.NewRaw("SELECT phones, emails FROM test_array_users WHERE id = ?", user.ID).
+			Scan(ctx, pgdialect.Array(&phones), pgdialect.Array(&emails))
+		require.NoError(t, err)
+		require.Len(t, phones, 2)
+		for _, phone := range phones {
+			assert.True(t, govault.IsEncrypted(phone))
+		}
+		require.Len(t, emails, 1, "whole arrays are one element")
+		assert.True(t, govault.IsEncrypted(emails[0]))
+	})
+
+	t.Run("select decrypts", func(t *testing.T) {
+		var got TestArrayUser
+		require.NoError(t, db.NewSelect().Model(&got).Where("id = ?", user.ID).Scan(ctx, &got))
+		assert.Equal(t, []string{"+62811", "+62812"}, got.Phones)
+		assert.Equal(t, []string{"a@example.com", "b@example.com", "c@example.com"}, got.Emails)
+	})
+}
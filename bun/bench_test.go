@@ -0,0 +1,111 @@
+// Package govault - Bun adapter benchmarks against plain bun
+package bun_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/muhammadluth/govault"
+	gb "github.com/muhammadluth/govault/bun"
+	"github.com/muhammadluth/govault/govaulttest"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect/pgdialect"
+)
+
+// Rows with five text columns, of which none, two or all five are encrypted
+type (
+	BenchRow0 struct {
+		bun.BaseModel `bun:"table:bench_rows"`
+		ID            int64  `bun:"id,pk,autoincrement"`
+		Name          string `bun:"name"`
+		Email         string `bun:"email"`
+		Phone         string `bun:"phone"`
+		Address       string `bun:"address"`
+		Notes         string `bun:"notes"`
+	}
+	BenchRow2 struct {
+		bun.BaseModel `bun:"table:bench_rows"`
+		ID            int64  `bun:"id,pk,autoincrement"`
+		Name          string `bun:"name"`
+		Email         string `bun:"email" encrypted:"true"`
+		Phone         string `bun:"phone" encrypted:"true"`
+		Address       string `bun:"address"`
+		Notes         string `bun:"notes"`
+	}
+	BenchRow5 struct {
+		bun.BaseModel `bun:"table:bench_rows"`
+		ID            int64  `bun:"id,pk,autoincrement"`
+		Name          string `bun:"name" encrypted:"true"`
+		Email         string `bun:"email" encrypted:"true"`
+		Phone         string `bun:"phone" encrypted:"true"`
+		Address       string `bun:"address" encrypted:"true"`
+		Notes         string `bun:"notes" encrypted:"true"`
+	}
+)
+
+const benchRows = 1000
+
+// BenchmarkSelect compares selecting 1k rows with plain bun and through
+// the govault wrapper. Plain bun reads the same stored values without
+// decrypting them, so the difference is the overhead govault adds.
+func BenchmarkSelect(b *testing.B) {
+	openDB := openPostgres(b)
+	defer openDB.Close()
+
+	bunDB := bun.NewDB(openDB, pgdialect.New())
+	govaultDB, err := govault.New(govault.Config{
+		AdapterName:  govault.AdapterNameBun,
+		BunDB:        bunDB,
+		Keys:         govaulttest.EphemeralKeys(1),
+		DefaultKeyID: "1",
+	})
+	if err != nil {
+		b.Fatal(err)
+	}
+	db := govaultDB.BunDB()
+
+	b.Run("encrypted=0", benchSelect[BenchRow0](db, func() *BenchRow0 {
+		return &BenchRow0{Name: "Jane Doe", Email: "jane@example.com", Phone: "+62811112222", Address: "Jl. Sudirman 1", Notes: "none"}
+	}))
+	b.Run("encrypted=2", benchSelect[BenchRow2](db, func() *BenchRow2 {
+		return &BenchRow2{Name: "Jane Doe", Email: "jane@example.com", Phone: "+62811112222", Address: "Jl. Sudirman 1", Notes: "none"}
+	}))
+	b.Run("encrypted=5", benchSelect[BenchRow5](db, func() *BenchRow5 {
+		return &BenchRow5{Name: "Jane Doe", Email: "jane@example.com", Phone: "+62811112222", Address: "Jl. Sudirman 1", Notes: "none"}
+	}))
+}
+
+func benchSelect[T any](db *gb.BunDB, row func() *T) func(b *testing.B) {
+	return func(b *testing.B) {
+		ctx := context.Background()
+		if _, err := db.NewCreateTable().Model((*T)(nil)).IfNotExists().Exec(ctx); err != nil {
+			b.Fatal(err)
+		}
+		defer db.NewDropTable().Model((*T)(nil)).IfExists().Exec(ctx)
+
+		for i := 0; i < benchRows; i++ {
+			if _, err := db.NewInsert().Model(row()).Exec(ctx); err != nil {
+				b.Fatal(err)
+			}
+		}
+
+		b.Run("bun", func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				var out []T
+				if err := db.DB.NewSelect().Model(&out).Scan(ctx); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+		b.Run("govault", func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				var out []T
+				if err := db.NewSelect().Model(&out).Scan(ctx, &out); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,126 @@
+// Package govault - Bun adapter binary ciphertext columns
+package bun
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/muhammadluth/govault/internal"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect"
+	"github.com/uptrace/bun/schema"
+)
+
+// binaryFields records the bun fields converted by bindBinaryFields
+var binaryFields sync.Map // map[*schema.Field]struct{}
+
+// bindBinaryFields installs the conversions of the encrypted fields of
+// model stored as binary by govault, e.g. for BYTEA or BLOB columns:
+//
+//	Email string `bun:"email,type:bytea" encrypted:"true" storage:"binary"`
+//
+// Ciphertexts are written with internal.EncodeBinary and read back into
+// the text format before decryption. Wrapper queries bind their models;
+// queries on the underlying bun.DB need RegisterModel.
+//
+// bun keeps the fields in the table metadata of the dialect of db, which
+// every bun.DB on that dialect shares. The conversions therefore only
+// touch govault values: plaintext is left to the appender of bun, and
+// only values in the binary format are converted on scan, so other users
+// of the dialect read and write the column as before.
+func bindBinaryFields(db *bun.DB, govault *internal.GovaultDB, model any) {
+	spec := govault.ModelSpec(model)
+	if spec == nil {
+		return
+	}
+	var table *schema.Table
+	for _, fieldSpec := range spec.Fields {
+		if !fieldSpec.Binary {
+			continue
+		}
+		if table == nil {
+			table = db.Table(spec.Type)
+		}
+		field, ok := table.FieldMap[fieldSpec.Column]
+		if !ok || field.IndirectType.Kind() != reflect.String {
+			continue
+		}
+		if _, loaded := binaryFields.LoadOrStore(field, struct{}{}); loaded {
+			continue
+		}
+		field.Append = binaryAppender(fieldSpec, field.Append)
+		field.Scan = binaryScanner(fieldSpec, field.Scan)
+	}
+}
+
+// isBinaryField reports whether bindBinaryFields converted field
+func isBinaryField(field *schema.Field) bool {
+	_, ok := binaryFields.Load(field)
+	return ok
+}
+
+// storedText converts a value read from a column to the text format,
+// decoding it when the column is binary
+func storedText(data []byte, binary bool) (string, error) {
+	if !binary {
+		return string(data), nil
+	}
+	return internal.DecodeBinary(data)
+}
+
+// storedValue converts a value in the text format to the argument writing
+// it to a column, encoding it when the column is binary
+func storedValue(text string, binary bool) (any, error) {
+	if !binary {
+		return text, nil
+	}
+	return internal.EncodeBinary(text)
+}
+
+// storedArg returns the argument comparing a column with data as read,
+// bytes for a binary column and text otherwise
+func storedArg(data []byte, binary bool) any {
+	if binary {
+		return data
+	}
+	return string(data)
+}
+
+// binaryAppender appends the binary encoding of a ciphertext, and other
+// values with next
+func binaryAppender(fieldSpec *internal.FieldSpec, next schema.AppenderFunc) schema.AppenderFunc {
+	return func(gen schema.QueryGen, b []byte, v reflect.Value) []byte {
+		iv := reflect.Indirect(v)
+		if !iv.IsValid() || !strings.HasPrefix(iv.String(), internal.FormatPrefix) {
+			return next(gen, b, v)
+		}
+		data, err := internal.EncodeBinary(iv.String())
+		if err != nil {
+			return dialect.AppendError(b, fmt.Errorf("failed to encode field %s: %w", fieldSpec.Name, err))
+		}
+		return gen.Dialect().AppendBytes(b, data)
+	}
+}
+
+// binaryScanner converts binary values to the text format before the
+// default scanner runs
+func binaryScanner(fieldSpec *internal.FieldSpec, next schema.ScannerFunc) schema.ScannerFunc {
+	return func(dest reflect.Value, src any) error {
+		var data []byte
+		switch src := src.(type) {
+		case []byte:
+			data = src
+		case string:
+			data = []byte(src)
+		default:
+			return next(dest, src)
+		}
+		text, err := internal.DecodeBinary(data)
+		if err != nil {
+			return fmt.Errorf("failed to decode field %s: %w", fieldSpec.Name, err)
+		}
+		return next(dest, text)
+	}
+}
@@ -0,0 +1,134 @@
+package bun_test
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/muhammadluth/govault"
+	gb "github.com/muhammadluth/govault/bun"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uptrace/bun"
+)
+
+type TestBinaryUser struct {
+	bun.BaseModel `bun:"table:test_binary_users"`
+	ID            int64  `bun:"id,pk,autoincrement"`
+	Email         string `bun:"email,type:bytea" encrypted:"true" storage:"binary"`
+	SSN           string `bun:"ssn,type:bytea" encrypted:"true" deterministic:"true" storage:"binary"`
+}
+
+func TestBunBinaryStorage(t *testing.T) {
+	db, _, cleanup := setupTestDB(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	_, err := db.NewCreateTable().Model((*TestBinaryUser)(nil)).IfNotExists().Exec(ctx)
+	require.NoError(t, err)
+	defer db.NewDropTable().Model((*TestBinaryUser)(nil)).IfExists().Exec(ctx)
+
+	user := &TestBinaryUser{Email: "binary@example.com", SSN: "123-45-6789"}
+	_, err = db.NewInsert().Model(user).Exec(ctx)
+	require.NoError(t, err)
+	text := user.Email
+
+	t.Run("ciphertext is stored as bytes", func(t *testing.T) {
+		var stored []byte
+		err := db.DB.NewRaw("SELECT email FROM test_binary_users WHERE id = ?", user.ID).Scan(ctx, &stored)
+		require.NoError(t, err)
+		assert.True(t, strings.HasPrefix(string(stored), "\x00gv"))
+		assert.Less(t, len(stored), len(text))
+
+		decoded, err := govault.DecodeBinary(stored)
+		require.NoError(t, err)
+		assert.Equal(t, text, decoded)
+	})
+
+	t.Run("select decrypts", func(t *testing.T) {
+		var got TestBinaryUser
+		err := db.NewSelect().Model(&got).Where("id = ?", user.ID).Scan(ctx, &got)
+		require.NoError(t, err)
+		assert.Equal(t, "binary@example.com", got.Email)
+		assert.Equal(t, "123-45-6789", got.SSN)
+	})
+
+	t.Run("deterministic values are searchable", func(t *testing.T) {
+		var got TestBinaryUser
+		err := db.NewSelect().Model(&got).WhereQ(gb.Q("ssn = {enc:ssn}", "123-45-6789")).Scan(ctx, &got)
+		require.NoError(t, err)
+		assert.Equal(t, user.ID, got.ID)
+	})
+
+	t.Run("update writes bytes", func(t *testing.T) {
+		updated := &TestBinaryUser{ID: user.ID, Email: "changed@example.com", SSN: "987-65-4321"}
+		_, err := db.NewUpdate().Model(updated).WherePK().Exec(ctx)
+		require.NoError(t, err)
+
+		var got TestBinaryUser
+		require.NoError(t, db.NewSelect().Model(&got).Where("id = ?", user.ID).Scan(ctx, &got))
+		assert.Equal(t, "changed@example.com", got.Email)
+		assert.Equal(t, "987-65-4321", got.SSN)
+	})
+}
+
+func TestBunBinaryRotation(t *testing.T) {
+	db, _, cleanup := setupTestDB(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	_, err := db.NewCreateTable().Model((*TestBinaryUser)(nil)).IfNotExists().Exec(ctx)
+	require.NoError(t, err)
+	defer db.NewDropTable().Model((*TestBinaryUser)(nil)).IfExists().Exec(ctx)
+
+	for i := 0; i < 5; i++ {
+		_, err := db.WithKey("1").NewInsert().Model(&TestBinaryUser{
+			Email: fmt.Sprintf("user%d@example.com", i),
+			SSN:   fmt.Sprintf("000-00-000%d", i),
+		}).Exec(ctx)
+		require.NoError(t, err)
+	}
+	_, err = db.WithKey("2").NewInsert().Model(&TestBinaryUser{Email: "already@example.com"}).Exec(ctx)
+	require.NoError(t, err)
+
+	models := []any{(*TestBinaryUser)(nil)}
+	plan, err := db.PlanRotation(ctx, models, "2", 2)
+	require.NoError(t, err)
+	require.Len(t, plan.Tables, 1)
+	assert.EqualValues(t, 5, plan.Tables[0].AffectedRows)
+	assert.True(t, plan.Tables[0].Columns[0].Binary)
+	assert.Equal(t, map[string]int64{"1": 5, "2": 1}, plan.Tables[0].Columns[0].KeyCounts)
+
+	result, err := db.ExecuteRotation(ctx, plan)
+	require.NoError(t, err)
+	assert.EqualValues(t, 5, result.Tables[0].Rotated)
+	assert.Zero(t, result.Tables[0].Conflicts)
+
+	plan, err = db.PlanRotation(ctx, models, "2", 2)
+	require.NoError(t, err)
+	assert.Zero(t, plan.AffectedRows)
+
+	var stored []byte
+	err = db.DB.NewRaw("SELECT email FROM test_binary_users ORDER BY id LIMIT 1").Scan(ctx, &stored)
+	require.NoError(t, err)
+	text, err := govault.DecodeBinary(stored)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(text, "gv1:2|"), "rotated values stay binary")
+
+	var users []TestBinaryUser
+	require.NoError(t, db.NewSelect().Model(&users).Order("id").Scan(ctx, &users))
+	assert.Equal(t, "user3@example.com", users[3].Email)
+	assert.Equal(t, "000-00-0003", users[3].SSN)
+
+	verification, err := db.VerifyRotation(ctx, models, "1", 10)
+	require.NoError(t, err)
+	assert.Zero(t, verification.OnOldKey)
+	assert.Zero(t, verification.Undecryptable)
+
+	defer db.NewDropTable().Model((*gb.CatalogColumn)(nil)).IfExists().Exec(ctx)
+	require.NoError(t, db.SyncCatalog(ctx, models...))
+	issues, err := db.CheckDrift(ctx, models...)
+	require.NoError(t, err)
+	assert.Empty(t, issues)
+}
@@ -0,0 +1,30 @@
+// Package govault - Bun adapter bloom filter membership queries
+package bun
+
+import (
+	"fmt"
+
+	"github.com/uptrace/bun"
+)
+
+// WhereMember filters rows whose encrypted column may hold member among its
+// separated values, using the bloom filter of the column set with the bloom
+// tag. Bloom filters give false positives: decrypt and check the matches
+// when exact membership matters. The model must be set first.
+func (q *BunSelectQuery) WhereMember(column, member string) *BunSelectQuery {
+	_, spec := q.modelSpec()
+	if spec == nil {
+		return q.Err(fmt.Errorf("WhereMember requires a model with encrypted fields"))
+	}
+	field := fieldOfColumn(spec, column)
+	if field == nil || field.Bloom == "" {
+		return q.Err(fmt.Errorf("%s.%s has no bloom filter", spec.Table, column))
+	}
+
+	pattern, err := q.govault.BloomPattern(field, spec.Table, member)
+	if err != nil {
+		return q.Err(err)
+	}
+	q.SelectQuery.Where("?TableAlias.? LIKE ?", bun.Ident(field.Bloom), pattern)
+	return q
+}
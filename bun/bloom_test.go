@@ -0,0 +1,52 @@
+// Package govault - Bun adapter bloom filter membership tests
+package bun_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uptrace/bun"
+)
+
+type TestArticle struct {
+	bun.BaseModel `bun:"table:test_articles,alias:a"`
+	ID            int64  `bun:"id,pk,autoincrement"`
+	Tags          string `bun:"tags" encrypted:"true" bloom:"tags_bloom" bloomfpr:"0.0001"`
+	TagsBloom     string `bun:"tags_bloom"`
+}
+
+func TestBunWhereMember(t *testing.T) {
+	db, _, cleanup := setupTestDB(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	_, err := db.NewCreateTable().Model((*TestArticle)(nil)).IfNotExists().Exec(ctx)
+	require.NoError(t, err)
+	defer db.NewDropTable().Model((*TestArticle)(nil)).IfExists().Exec(ctx)
+
+	for _, tags := range []string{"go,crypto", "go,databases", "cooking"} {
+		_, err := db.NewInsert().Model(&TestArticle{Tags: tags}).Exec(ctx)
+		require.NoError(t, err)
+	}
+
+	members := func(member string) []string {
+		var articles []TestArticle
+		err := db.NewSelect().Model(&articles).WhereMember("tags", member).Order("id").Scan(ctx, &articles)
+		require.NoError(t, err)
+		tags := []string{}
+		for _, article := range articles {
+			tags = append(tags, article.Tags)
+		}
+		return tags
+	}
+
+	assert.Equal(t, []string{"go,crypto", "go,databases"}, members("go"))
+	assert.Equal(t, []string{"go,databases"}, members("databases"))
+	assert.Empty(t, members("rust"))
+
+	var articles []TestArticle
+	err = db.NewSelect().Model(&articles).WhereMember("tags_bloom", "go").Scan(ctx, &articles)
+	assert.ErrorContains(t, err, "test_articles.tags_bloom has no bloom filter")
+}
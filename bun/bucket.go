@@ -0,0 +1,32 @@
+// Package govault - Bun adapter range filters on numeric buckets
+package bun
+
+import (
+	"fmt"
+
+	"github.com/uptrace/bun"
+)
+
+// WhereBucketRange filters rows whose encrypted number in column may fall
+// between low and high, inclusive, using the bucket column set with the
+// bucket tag. The filter is coarse: rows of the whole buckets of low and
+// high match, so compare the decrypted values when the exact range
+// matters. See govault.GovaultDB.BucketID for what buckets leak. The model
+// must be set first.
+func (q *BunSelectQuery) WhereBucketRange(column string, low, high float64) *BunSelectQuery {
+	_, spec := q.modelSpec()
+	if spec == nil {
+		return q.Err(fmt.Errorf("WhereBucketRange requires a model with encrypted fields"))
+	}
+	field := fieldOfColumn(spec, column)
+	if field == nil || field.Bucket == "" {
+		return q.Err(fmt.Errorf("%s.%s has no bucket", spec.Table, column))
+	}
+
+	lowID, highID, err := q.govault.BucketRange(field, spec.Table, low, high)
+	if err != nil {
+		return q.Err(err)
+	}
+	q.SelectQuery.Where("?TableAlias.? BETWEEN ? AND ?", bun.Ident(field.Bucket), lowID, highID)
+	return q
+}
@@ -0,0 +1,52 @@
+// Package govault - Bun adapter numeric bucket range tests
+package bun_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uptrace/bun"
+)
+
+type TestEmployee struct {
+	bun.BaseModel `bun:"table:test_employees,alias:e"`
+	ID            int64  `bun:"id,pk,autoincrement"`
+	Salary        string `bun:"salary" encrypted:"true" bucket:"salary_bucket" bucketbounds:"30000,60000,100000"`
+	SalaryBucket  int64  `bun:"salary_bucket"`
+}
+
+func TestBunWhereBucketRange(t *testing.T) {
+	db, _, cleanup := setupTestDB(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	_, err := db.NewCreateTable().Model((*TestEmployee)(nil)).IfNotExists().Exec(ctx)
+	require.NoError(t, err)
+	defer db.NewDropTable().Model((*TestEmployee)(nil)).IfExists().Exec(ctx)
+
+	for _, salary := range []string{"25000", "45000", "59000", "80000", "150000"} {
+		_, err := db.NewInsert().Model(&TestEmployee{Salary: salary}).Exec(ctx)
+		require.NoError(t, err)
+	}
+
+	salaries := func(low, high float64) []string {
+		var employees []TestEmployee
+		err := db.NewSelect().Model(&employees).WhereBucketRange("salary", low, high).Order("id").Scan(ctx, &employees)
+		require.NoError(t, err)
+		salaries := []string{}
+		for _, employee := range employees {
+			salaries = append(salaries, employee.Salary)
+		}
+		return salaries
+	}
+
+	assert.Equal(t, []string{"45000", "59000"}, salaries(50000, 55000), "whole buckets match")
+	assert.Equal(t, []string{"45000", "59000", "80000"}, salaries(30000, 99999))
+	assert.Equal(t, []string{"150000"}, salaries(200000, 300000))
+
+	var employees []TestEmployee
+	err = db.NewSelect().Model(&employees).WhereBucketRange("salary_bucket", 0, 1).Scan(ctx, &employees)
+	assert.ErrorContains(t, err, "has no bucket")
+}
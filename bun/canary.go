@@ -0,0 +1,159 @@
+// Package govault - Bun adapter canary values
+package bun
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/muhammadluth/govault/internal"
+	"github.com/uptrace/bun"
+)
+
+// CanaryRecord is a row of the govault_canaries table. The canary
+// plaintext is stored encrypted.
+type CanaryRecord struct {
+	bun.BaseModel `bun:"table:govault_canaries"`
+
+	ID         string    `bun:"id,pk"`
+	TableName  string    `bun:"table_name,notnull"`
+	ColumnName string    `bun:"column_name,notnull"`
+	Plaintext  string    `bun:"plaintext,notnull"`
+	CreatedAt  time.Time `bun:"created_at,notnull,default:current_timestamp"`
+}
+
+// PlantCanary overwrites column of the row identified by the primary key of
+// model with an encrypted canary and records it in govault_canaries. An
+// empty plaintext generates one; pass a realistic value, e.g. a fake email
+// address, to make the canary indistinguishable from real data.
+func (db *BunDB) PlantCanary(ctx context.Context, model any, column, plaintext string) (*internal.Canary, error) {
+	spec := db.govault.ModelSpec(model)
+	if spec == nil {
+		return nil, fmt.Errorf("model must be a struct, got %T", model)
+	}
+
+	var field *internal.FieldSpec
+	for _, f := range spec.Fields {
+		if f.Column == column {
+			field = f
+		}
+	}
+	if field == nil {
+		return nil, fmt.Errorf("column %s.%s is not encrypted", spec.Table, column)
+	}
+
+	if plaintext == "" {
+		var err error
+		if plaintext, err = internal.NewCanaryValue(); err != nil {
+			return nil, err
+		}
+	}
+
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return nil, fmt.Errorf("failed to generate canary ID: %w", err)
+	}
+
+	ciphertext, err := db.govault.EncryptField(field, spec.Table, plaintext, db.keyID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt canary: %w", err)
+	}
+	stored, err := db.govault.Encrypt(plaintext, db.keyID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt canary: %w", err)
+	}
+
+	var blindIndex string
+	if field.BlindIndex != "" {
+		if blindIndex, err = db.govault.BlindIndex(model, field.Name, plaintext); err != nil {
+			return nil, fmt.Errorf("failed to compute canary blind index: %w", err)
+		}
+	}
+
+	var plainHash string
+	if field.PlainHash != "" {
+		if plainHash, err = db.govault.PlainHash(model, field.Name, plaintext); err != nil {
+			return nil, fmt.Errorf("failed to compute canary plaintext hash: %w", err)
+		}
+	}
+
+	canary := &internal.Canary{
+		ID:        hex.EncodeToString(id),
+		Table:     spec.Table,
+		Column:    column,
+		Plaintext: plaintext,
+		CreatedAt: time.Now(),
+	}
+
+	err = db.DB.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		q := tx.NewUpdate().
+			Model(model).
+			Set("? = ?", bun.Ident(column), ciphertext).
+			WherePK()
+		if field.BlindIndex != "" {
+			q = q.Set("? = ?", bun.Ident(field.BlindIndex), blindIndex)
+		}
+		if field.PlainHash != "" {
+			q = q.Set("? = ?", bun.Ident(field.PlainHash), plainHash)
+		}
+		res, err := q.Exec(ctx)
+		if err != nil {
+			return err
+		}
+		if n, err := res.RowsAffected(); err == nil && n == 0 {
+			return fmt.Errorf("row not found in %s", spec.Table)
+		}
+
+		if _, err := tx.NewCreateTable().Model((*CanaryRecord)(nil)).IfNotExists().Exec(ctx); err != nil {
+			return err
+		}
+		_, err = tx.NewInsert().Model(&CanaryRecord{
+			ID:         canary.ID,
+			TableName:  canary.Table,
+			ColumnName: canary.Column,
+			Plaintext:  stored,
+			CreatedAt:  canary.CreatedAt,
+		}).Exec(ctx)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to plant canary: %w", err)
+	}
+	return canary, nil
+}
+
+// Canaries returns all planted canaries with decrypted plaintexts
+func (db *BunDB) Canaries(ctx context.Context) ([]internal.Canary, error) {
+	var records []CanaryRecord
+	err := db.DB.NewSelect().Model(&records).Order("created_at").Scan(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read canaries: %w", err)
+	}
+
+	canaries := make([]internal.Canary, 0, len(records))
+	for _, r := range records {
+		plaintext, err := db.govault.DecryptContext(ctx, r.Plaintext)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt canary %s: %w", r.ID, err)
+		}
+		canaries = append(canaries, internal.Canary{
+			ID:        r.ID,
+			Table:     r.TableName,
+			Column:    r.ColumnName,
+			Plaintext: plaintext,
+			CreatedAt: r.CreatedAt,
+		})
+	}
+	return canaries, nil
+}
+
+// CanaryDetector returns a detector loaded with all planted canaries
+func (db *BunDB) CanaryDetector(ctx context.Context) (*internal.CanaryDetector, error) {
+	canaries, err := db.Canaries(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return internal.NewCanaryDetector(canaries...), nil
+}
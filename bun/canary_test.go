@@ -0,0 +1,62 @@
+// Package govault - Bun adapter canary tests
+package bun_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/muhammadluth/govault"
+	gb "github.com/muhammadluth/govault/bun"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBunPlantCanary(t *testing.T) {
+	db, govaultDB, cleanup := setupTestDB(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	_, err := db.NewCreateTable().Model((*TestSharedUser)(nil)).IfNotExists().Exec(ctx)
+	require.NoError(t, err)
+	defer db.NewDropTable().Model((*TestSharedUser)(nil)).IfExists().Exec(ctx)
+	defer db.NewDropTable().Model((*gb.CanaryRecord)(nil)).IfExists().Exec(ctx)
+
+	user := &TestSharedUser{Email: "real@example.com"}
+	_, err = db.NewInsert().Model(user).Exec(ctx)
+	require.NoError(t, err)
+
+	canary, err := db.PlantCanary(ctx, user, "email", "")
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(canary.Plaintext, "gvcanary-"))
+	assert.Equal(t, "email", canary.Column)
+
+	// The row now decrypts to the canary and its blind index matches
+	var planted TestSharedUser
+	err = db.NewSelect().Model(&planted).Where("id = ?", user.ID).Scan(ctx, &planted)
+	require.NoError(t, err)
+	assert.Equal(t, canary.Plaintext, planted.Email)
+
+	bidx, err := govaultDB.BlindIndex(&TestSharedUser{}, "Email", canary.Plaintext)
+	require.NoError(t, err)
+	assert.Equal(t, bidx, planted.EmailBidx)
+
+	_, err = db.PlantCanary(ctx, &TestSharedUser{ID: -1}, "email", "")
+	assert.Error(t, err)
+	_, err = db.PlantCanary(ctx, user, "id", "")
+	assert.Error(t, err)
+
+	// A dump of decrypted rows trips the detector
+	detector, err := db.CanaryDetector(ctx)
+	require.NoError(t, err)
+
+	var hits []govault.CanaryHit
+	detector.OnHit(func(_ context.Context, hit govault.CanaryHit) error {
+		hits = append(hits, hit)
+		return nil
+	})
+	_, err = detector.Check(ctx, "dump", []byte("id,email\n1,"+planted.Email+"\n"))
+	require.NoError(t, err)
+	require.Len(t, hits, 1)
+	assert.Equal(t, canary.ID, hits[0].Canary.ID)
+}
@@ -0,0 +1,182 @@
+// Package govault - Bun adapter encryption catalog
+package bun
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/muhammadluth/govault/internal"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect"
+)
+
+// CatalogColumn is a row of the govault_columns catalog table. Each row
+// records how one table.column is encrypted so tools and services that do
+// not share the Go structs can discover the encryption state.
+type CatalogColumn struct {
+	bun.BaseModel `bun:"table:govault_columns"`
+
+	TableName      string    `bun:"table_name,pk"`
+	ColumnName     string    `bun:"column_name,pk"`
+	FormatVersion  string    `bun:"format_version,notnull"`
+	Deterministic  bool      `bun:"deterministic,notnull,default:false"`
+	BlindIndex     string    `bun:"blind_index"`
+	BlindIndexBits int       `bun:"blind_index_bits"`
+	LastRotatedAt  time.Time `bun:"last_rotated_at,nullzero"`
+	UpdatedAt      time.Time `bun:"updated_at,notnull,default:current_timestamp"`
+}
+
+// CreateCatalog creates the govault_columns table if it does not exist
+func (db *BunDB) CreateCatalog(ctx context.Context) error {
+	return createCatalog(ctx, db.DB)
+}
+
+// createCatalog creates the catalog table through conn
+func createCatalog(ctx context.Context, conn bun.IDB) error {
+	_, err := conn.NewCreateTable().
+		Model((*CatalogColumn)(nil)).
+		IfNotExists().
+		Exec(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to create catalog: %w", err)
+	}
+	return nil
+}
+
+// SyncCatalog creates the catalog if needed and upserts a row for every
+// encrypted field of the given models. Rotation timestamps are preserved.
+func (db *BunDB) SyncCatalog(ctx context.Context, models ...any) error {
+	return syncCatalog(ctx, db.DB, catalogColumns(db.govault, models...))
+}
+
+// syncCatalog creates the catalog if needed and upserts columns through conn
+func syncCatalog(ctx context.Context, conn bun.IDB, columns []CatalogColumn) error {
+	if err := createCatalog(ctx, conn); err != nil {
+		return err
+	}
+	if len(columns) == 0 {
+		return nil
+	}
+
+	q := conn.NewInsert().Model(&columns)
+	excluded := "EXCLUDED.?"
+	if conn.Dialect().Name() == dialect.MySQL {
+		q = q.On("DUPLICATE KEY UPDATE")
+		excluded = "VALUES(?)"
+	} else {
+		q = q.On("CONFLICT (table_name, column_name) DO UPDATE")
+	}
+	for _, column := range []string{"format_version", "deterministic", "blind_index", "blind_index_bits", "updated_at"} {
+		q = q.Set("? = "+excluded, bun.Ident(column), bun.Ident(column))
+	}
+	_, err := q.Exec(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to sync catalog: %w", err)
+	}
+	return nil
+}
+
+// Catalog returns all rows of the catalog ordered by table and column
+func (db *BunDB) Catalog(ctx context.Context) ([]CatalogColumn, error) {
+	var columns []CatalogColumn
+	err := db.DB.NewSelect().
+		Model(&columns).
+		Order("table_name", "column_name").
+		Scan(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read catalog: %w", err)
+	}
+	return columns, nil
+}
+
+// MarkRotated records that table.column was re-encrypted at the given time
+func (db *BunDB) MarkRotated(ctx context.Context, table, column string, at time.Time) error {
+	res, err := db.DB.NewUpdate().
+		Model((*CatalogColumn)(nil)).
+		Set("last_rotated_at = ?", at).
+		Set("updated_at = ?", time.Now()).
+		Where("table_name = ?", table).
+		Where("column_name = ?", column).
+		Exec(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to mark rotation: %w", err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf("column %s.%s is not in the catalog", table, column)
+	}
+	return nil
+}
+
+// markRotated records through conn that the columns of table in the
+// catalog were re-encrypted at the given time, creating the catalog if
+// needed. Columns missing from it are left out.
+func markRotated(ctx context.Context, conn bun.IDB, table string, columns []string, at time.Time) error {
+	if err := createCatalog(ctx, conn); err != nil {
+		return err
+	}
+	_, err := conn.NewUpdate().
+		Model((*CatalogColumn)(nil)).
+		Set("last_rotated_at = ?", at).
+		Set("updated_at = ?", time.Now()).
+		Where("table_name = ?", table).
+		Where("column_name IN (?)", bun.In(columns)).
+		Exec(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to mark rotation: %w", err)
+	}
+	return nil
+}
+
+// catalogColumns builds catalog rows from the specs of models
+func catalogColumns(govault *internal.GovaultDB, models ...any) []CatalogColumn {
+	now := time.Now()
+	var columns []CatalogColumn
+	for _, model := range models {
+		spec := govault.ModelSpec(model)
+		if spec == nil {
+			continue
+		}
+		for _, field := range spec.Fields {
+			columns = append(columns, catalogColumn(spec.Table, field, now))
+		}
+	}
+	return columns
+}
+
+// catalogColumn builds the catalog row of an encrypted field
+func catalogColumn(table string, field *internal.FieldSpec, now time.Time) CatalogColumn {
+	column := CatalogColumn{
+		TableName:     table,
+		ColumnName:    field.Column,
+		FormatVersion: field.Format(),
+		Deterministic: field.Deterministic,
+		UpdatedAt:     now,
+	}
+	if field.BlindIndex != "" {
+		column.BlindIndex = field.BlindIndex
+		column.BlindIndexBits = field.BlindIndexBits
+	}
+	return column
+}
+
+// ComplianceReport reports the classified columns of models, see
+// GovaultDB.ComplianceReport, with the last rotation of each column read
+// from the catalog. The catalog must exist, see CreateCatalog.
+func (db *BunDB) ComplianceReport(ctx context.Context, models ...any) (*internal.ComplianceReport, error) {
+	report := db.govault.ComplianceReport(models...)
+	columns, err := db.Catalog(ctx)
+	if err != nil {
+		return nil, err
+	}
+	rotated := make(map[string]time.Time, len(columns))
+	for _, column := range columns {
+		rotated[column.TableName+"."+column.ColumnName] = column.LastRotatedAt
+	}
+	for i, field := range report.Fields {
+		if field.Encrypted {
+			report.Fields[i].LastRotatedAt = rotated[field.Table+"."+field.Column]
+		}
+	}
+	return report, nil
+}
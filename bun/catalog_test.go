@@ -0,0 +1,185 @@
+// Package govault - Bun adapter encryption catalog tests
+package bun_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/muhammadluth/govault"
+	gb "github.com/muhammadluth/govault/bun"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uptrace/bun"
+)
+
+type TestCatalogUser struct {
+	bun.BaseModel `bun:"table:test_catalog_users"`
+	ID            int64  `bun:"id,pk,autoincrement"`
+	Email         string `bun:"email" encrypted:"true" deterministic:"true"`
+	Phone         string `bun:"phone" encrypted:"true" blindindex:"phone_bidx"`
+	PhoneBidx     string `bun:"phone_bidx"`
+	Name          string `bun:"name"`
+}
+
+type TestClassifiedUser struct {
+	bun.BaseModel `bun:"table:test_catalog_users"`
+	ID            int64  `bun:"id,pk,autoincrement"`
+	Email         string `bun:"email" encrypted:"true" deterministic:"true" class:"pii-high"`
+	Name          string `bun:"name" class:"pii-low"`
+}
+
+func TestBunCatalog(t *testing.T) {
+	db, _, cleanup := setupTestDB(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	defer db.NewDropTable().Model((*gb.CatalogColumn)(nil)).IfExists().Exec(ctx)
+
+	t.Run("sync records encrypted columns", func(t *testing.T) {
+		require.NoError(t, db.SyncCatalog(ctx, (*TestCatalogUser)(nil), (*TestUser)(nil)))
+
+		columns, err := db.Catalog(ctx)
+		require.NoError(t, err)
+
+		byColumn := make(map[string]gb.CatalogColumn)
+		for _, c := range columns {
+			byColumn[c.TableName+"."+c.ColumnName] = c
+		}
+
+		email := byColumn["test_catalog_users.email"]
+		assert.Equal(t, "gv1", email.FormatVersion)
+		assert.True(t, email.Deterministic)
+
+		phone := byColumn["test_catalog_users.phone"]
+		assert.False(t, phone.Deterministic)
+		assert.Equal(t, "phone_bidx", phone.BlindIndex)
+		assert.Equal(t, 32, phone.BlindIndexBits)
+
+		assert.Contains(t, byColumn, "test_users.email")
+		assert.NotContains(t, byColumn, "test_catalog_users.name")
+	})
+
+	t.Run("rotation timestamp survives resync", func(t *testing.T) {
+		rotatedAt := time.Now().Add(-time.Hour).Truncate(time.Second)
+		require.NoError(t, db.MarkRotated(ctx, "test_catalog_users", "email", rotatedAt))
+		require.NoError(t, db.SyncCatalog(ctx, (*TestCatalogUser)(nil)))
+
+		columns, err := db.Catalog(ctx)
+		require.NoError(t, err)
+		for _, c := range columns {
+			if c.TableName == "test_catalog_users" && c.ColumnName == "email" {
+				assert.True(t, rotatedAt.Equal(c.LastRotatedAt))
+			}
+		}
+	})
+
+	t.Run("compliance report reads rotations", func(t *testing.T) {
+		report, err := db.ComplianceReport(ctx, (*TestClassifiedUser)(nil))
+		require.NoError(t, err)
+		require.Len(t, report.Fields, 2)
+
+		email := report.Fields[0]
+		assert.Equal(t, "pii-high", email.Class)
+		assert.True(t, email.Deterministic)
+		assert.False(t, email.LastRotatedAt.IsZero())
+
+		name := report.Fields[1]
+		assert.False(t, name.Encrypted)
+		assert.True(t, name.LastRotatedAt.IsZero())
+	})
+
+	t.Run("mark rotated on unknown column", func(t *testing.T) {
+		err := db.MarkRotated(ctx, "test_catalog_users", "missing", time.Now())
+		assert.Error(t, err)
+	})
+}
+
+func TestBunDeterministicEncryption(t *testing.T) {
+	db, govaultDB, cleanup := setupTestDB(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	_, err := db.NewCreateTable().Model((*TestCatalogUser)(nil)).IfNotExists().Exec(ctx)
+	require.NoError(t, err)
+	defer db.NewDropTable().Model((*TestCatalogUser)(nil)).IfExists().Exec(ctx)
+
+	user := &TestCatalogUser{Email: "same@example.com", Name: "Deterministic"}
+	_, err = db.NewInsert().Model(user).Exec(ctx)
+	require.NoError(t, err)
+
+	lookup, err := govaultDB.EncryptField(&govault.FieldSpec{Deterministic: true}, "", "same@example.com", "")
+	require.NoError(t, err)
+	assert.Equal(t, user.Email, lookup)
+
+	var found TestCatalogUser
+	err = db.NewSelect().Model(&found).Where("email = ?", lookup).Scan(ctx, &found)
+	require.NoError(t, err)
+	assert.Equal(t, "same@example.com", found.Email)
+}
+
+func TestBunCheckDrift(t *testing.T) {
+	db, govaultDB, cleanup := setupTestDB(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	_, err := db.NewCreateTable().Model((*TestCatalogUser)(nil)).IfNotExists().Exec(ctx)
+	require.NoError(t, err)
+	defer db.NewDropTable().Model((*TestCatalogUser)(nil)).IfExists().Exec(ctx)
+	defer db.NewDropTable().Model((*gb.CatalogColumn)(nil)).IfExists().Exec(ctx)
+
+	require.NoError(t, db.SyncCatalog(ctx, (*TestCatalogUser)(nil)))
+
+	t.Run("no drift", func(t *testing.T) {
+		_, err := db.NewInsert().Model(&TestCatalogUser{Email: "a@example.com", Phone: "1"}).Exec(ctx)
+		require.NoError(t, err)
+
+		issues, err := govaultDB.CheckDrift(ctx, (*TestCatalogUser)(nil))
+		require.NoError(t, err)
+		assert.Empty(t, issues)
+	})
+
+	t.Run("plaintext rows and catalog mismatch", func(t *testing.T) {
+		_, err := db.DB.NewInsert().Model(&TestCatalogUser{Email: "plain@example.com", Phone: "2"}).Exec(ctx)
+		require.NoError(t, err)
+
+		_, err = db.DB.NewUpdate().
+			Model((*gb.CatalogColumn)(nil)).
+			Set("deterministic = ?", false).
+			Where("table_name = ? AND column_name = ?", "test_catalog_users", "email").
+			Exec(ctx)
+		require.NoError(t, err)
+
+		issues, err := govaultDB.CheckDrift(ctx, (*TestCatalogUser)(nil))
+		require.NoError(t, err)
+
+		kinds := make(map[gb.DriftKind]gb.DriftIssue)
+		for _, issue := range issues {
+			kinds[issue.Kind] = issue
+		}
+		require.Contains(t, kinds, gb.DriftPlaintextRows)
+		assert.InDelta(t, 0.5, kinds[gb.DriftPlaintextRows].PlaintextRatio, 0.01)
+		require.Contains(t, kinds, gb.DriftDeterministicMismatch)
+		assert.Equal(t, "catalog says randomized but tag says deterministic", kinds[gb.DriftDeterministicMismatch].Message)
+	})
+
+	t.Run("rows are sampled at random", func(t *testing.T) {
+		ciphertext, err := govaultDB.Encrypt("bulk@example.com")
+		require.NoError(t, err)
+		// The first rows are all encrypted, the last ones all plaintext
+		for _, email := range []string{ciphertext, "bulk@example.com"} {
+			_, err = db.DB.NewRaw("INSERT INTO test_catalog_users (email) SELECT ? FROM generate_series(1, ?)", email, gb.DriftSampleSize).Exec(ctx)
+			require.NoError(t, err)
+		}
+
+		issues, err := govaultDB.CheckDrift(ctx, (*TestCatalogUser)(nil))
+		require.NoError(t, err)
+		var ratio float64
+		for _, issue := range issues {
+			if issue.Kind == gb.DriftPlaintextRows && issue.Column == "email" {
+				ratio = issue.PlaintextRatio
+			}
+		}
+		assert.InDelta(t, 0.5, ratio, 0.1)
+	})
+}
@@ -0,0 +1,338 @@
+// Package govault - Bun adapter add and drop column queries
+package bun
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/muhammadluth/govault/internal"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/schema"
+)
+
+// BunAddColumnQuery wraps bun.AddColumnQuery
+type BunAddColumnQuery struct {
+	*bun.AddColumnQuery
+	conn    bun.IDB
+	govault *internal.GovaultDB
+	table   string
+	field   *internal.FieldSpec // encrypted field added by Field, if any
+	timeout time.Duration
+}
+
+// Conn sets the database connection
+func (q *BunAddColumnQuery) Conn(db bun.IConn) *BunAddColumnQuery {
+	q.AddColumnQuery.Conn(db)
+	return q
+}
+
+// Model sets the model
+func (q *BunAddColumnQuery) Model(model any) *BunAddColumnQuery {
+	q.AddColumnQuery.Model(model)
+	return q
+}
+
+// Err sets an error on the query
+func (q *BunAddColumnQuery) Err(err error) *BunAddColumnQuery {
+	q.AddColumnQuery.Err(err)
+	return q
+}
+
+// Apply applies functions to the query
+func (q *BunAddColumnQuery) Apply(fns ...func(*BunAddColumnQuery) *BunAddColumnQuery) *BunAddColumnQuery {
+	for _, fn := range fns {
+		if fn != nil {
+			q = fn(q)
+		}
+	}
+	return q
+}
+
+// Table specifies the table
+func (q *BunAddColumnQuery) Table(tables ...string) *BunAddColumnQuery {
+	q.AddColumnQuery.Table(tables...)
+	return q
+}
+
+// TableExpr specifies the table
+func (q *BunAddColumnQuery) TableExpr(query string, args ...any) *BunAddColumnQuery {
+	q.AddColumnQuery.TableExpr(query, args...)
+	return q
+}
+
+// ModelTableExpr specifies the table
+func (q *BunAddColumnQuery) ModelTableExpr(query string, args ...any) *BunAddColumnQuery {
+	q.AddColumnQuery.ModelTableExpr(query, args...)
+	return q
+}
+
+// ColumnExpr specifies the column definition
+func (q *BunAddColumnQuery) ColumnExpr(query string, args ...any) *BunAddColumnQuery {
+	q.AddColumnQuery.ColumnExpr(query, args...)
+	return q
+}
+
+// IfNotExists adds IF NOT EXISTS
+func (q *BunAddColumnQuery) IfNotExists() *BunAddColumnQuery {
+	q.AddColumnQuery.IfNotExists()
+	return q
+}
+
+// Comment adds a comment to the query
+func (q *BunAddColumnQuery) Comment(comment string) *BunAddColumnQuery {
+	q.AddColumnQuery.Comment(comment)
+	return q
+}
+
+// Field adds the column of a field of model, named by its Go name or its
+// column, defined from its bun tag. An encrypted field must have a text
+// type, and its blind index, token index and bloom filter columns must be
+// fields of model. The catalog row of an encrypted field is upserted with
+// the column by Exec.
+func (q *BunAddColumnQuery) Field(model any, name string) *BunAddColumnQuery {
+	spec, field, err := modelField(q.govault, q.DB(), model, name)
+	if err != nil {
+		return q.Err(err)
+	}
+	q.Model(model)
+
+	def := "? ?"
+	args := []any{bun.Ident(field.Name), bun.Safe(field.CreateTableSQLType)}
+	if field.NotNull {
+		def += " NOT NULL"
+	}
+	if field.SQLDefault != "" {
+		def += " DEFAULT ?"
+		args = append(args, bun.Safe(field.SQLDefault))
+	}
+	q.ColumnExpr(def, args...)
+
+	encrypted := fieldOfColumn(spec, field.Name)
+	if encrypted == nil {
+		return q
+	}
+	if encrypted.Binary {
+		if !holdsBinary(field.CreateTableSQLType) {
+			return q.Err(fmt.Errorf("%s.%s is stored as binary, its type %s is not binary", spec.Table, field.Name, field.CreateTableSQLType))
+		}
+	} else if !holdsCiphertext(field.CreateTableSQLType) {
+		return q.Err(fmt.Errorf("%s.%s is encrypted, its type %s cannot hold ciphertext", spec.Table, field.Name, field.CreateTableSQLType))
+	}
+	table := q.DB().Table(spec.Type)
+	for kind, column := range companionColumns(encrypted) {
+		if _, ok := table.FieldMap[column]; !ok {
+			return q.Err(fmt.Errorf("%s.%s: %s column %s not found in the model", spec.Table, field.Name, kind, column))
+		}
+	}
+	q.table, q.field = spec.Table, encrypted
+	return q
+}
+
+// Exec executes the query. The column of an encrypted field added with
+// Field and its catalog row are created in one transaction.
+func (q *BunAddColumnQuery) Exec(ctx context.Context, dest ...any) (sql.Result, error) {
+	ctx, cancel := withTimeout(ctx, q.timeout)
+	defer cancel()
+
+	if q.field == nil {
+		return q.AddColumnQuery.Exec(ctx, dest...)
+	}
+
+	var res sql.Result
+	err := q.conn.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		var err error
+		if res, err = q.AddColumnQuery.Conn(tx).Exec(ctx, dest...); err != nil {
+			return err
+		}
+		return syncCatalog(ctx, tx, []CatalogColumn{catalogColumn(q.table, q.field, time.Now())})
+	})
+	return res, err
+}
+
+// BunDropColumnQuery wraps bun.DropColumnQuery
+type BunDropColumnQuery struct {
+	*bun.DropColumnQuery
+	conn    bun.IDB
+	govault *internal.GovaultDB
+	table   string
+	column  string // encrypted column dropped by Field, if any
+	timeout time.Duration
+}
+
+// Conn sets the database connection
+func (q *BunDropColumnQuery) Conn(db bun.IConn) *BunDropColumnQuery {
+	q.DropColumnQuery.Conn(db)
+	return q
+}
+
+// Model sets the model
+func (q *BunDropColumnQuery) Model(model any) *BunDropColumnQuery {
+	q.DropColumnQuery.Model(model)
+	return q
+}
+
+// Err sets an error on the query
+func (q *BunDropColumnQuery) Err(err error) *BunDropColumnQuery {
+	q.DropColumnQuery.Err(err)
+	return q
+}
+
+// Apply applies functions to the query
+func (q *BunDropColumnQuery) Apply(fns ...func(*BunDropColumnQuery) *BunDropColumnQuery) *BunDropColumnQuery {
+	for _, fn := range fns {
+		if fn != nil {
+			q = fn(q)
+		}
+	}
+	return q
+}
+
+// Table specifies the table
+func (q *BunDropColumnQuery) Table(tables ...string) *BunDropColumnQuery {
+	q.DropColumnQuery.Table(tables...)
+	return q
+}
+
+// TableExpr specifies the table
+func (q *BunDropColumnQuery) TableExpr(query string, args ...any) *BunDropColumnQuery {
+	q.DropColumnQuery.TableExpr(query, args...)
+	return q
+}
+
+// ModelTableExpr specifies the table
+func (q *BunDropColumnQuery) ModelTableExpr(query string, args ...any) *BunDropColumnQuery {
+	q.DropColumnQuery.ModelTableExpr(query, args...)
+	return q
+}
+
+// Column specifies the column to drop
+func (q *BunDropColumnQuery) Column(columns ...string) *BunDropColumnQuery {
+	q.DropColumnQuery.Column(columns...)
+	return q
+}
+
+// ColumnExpr specifies the column to drop
+func (q *BunDropColumnQuery) ColumnExpr(query string, args ...any) *BunDropColumnQuery {
+	q.DropColumnQuery.ColumnExpr(query, args...)
+	return q
+}
+
+// Comment adds a comment to the query
+func (q *BunDropColumnQuery) Comment(comment string) *BunDropColumnQuery {
+	q.DropColumnQuery.Comment(comment)
+	return q
+}
+
+// Field drops the column of a field of model, named by its Go name or its
+// column. The blind index, token index or bloom filter column of an
+// encrypted field of model cannot be dropped before the field itself. The
+// catalog row of an encrypted field is deleted with the column by Exec.
+func (q *BunDropColumnQuery) Field(model any, name string) *BunDropColumnQuery {
+	spec, field, err := modelField(q.govault, q.DB(), model, name)
+	if err != nil {
+		return q.Err(err)
+	}
+	q.Model(model).Column(field.Name)
+
+	for _, encrypted := range spec.Fields {
+		for kind, column := range companionColumns(encrypted) {
+			if column == field.Name {
+				return q.Err(fmt.Errorf("%s.%s is the %s column of encrypted column %s, drop it first", spec.Table, column, kind, encrypted.Column))
+			}
+		}
+	}
+	if fieldOfColumn(spec, field.Name) != nil {
+		q.table, q.column = spec.Table, field.Name
+	}
+	return q
+}
+
+// Exec executes the query. The column of an encrypted field dropped with
+// Field and its catalog row are deleted in one transaction.
+func (q *BunDropColumnQuery) Exec(ctx context.Context, dest ...any) (sql.Result, error) {
+	ctx, cancel := withTimeout(ctx, q.timeout)
+	defer cancel()
+
+	if q.column == "" {
+		return q.DropColumnQuery.Exec(ctx, dest...)
+	}
+
+	var res sql.Result
+	err := q.conn.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		var err error
+		if res, err = q.DropColumnQuery.Conn(tx).Exec(ctx, dest...); err != nil {
+			return err
+		}
+		if err := createCatalog(ctx, tx); err != nil {
+			return err
+		}
+		_, err = tx.NewDelete().
+			Model((*CatalogColumn)(nil)).
+			Where("table_name = ?", q.table).
+			Where("column_name = ?", q.column).
+			Exec(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to sync catalog: %w", err)
+		}
+		return nil
+	})
+	return res, err
+}
+
+// modelField returns the field of model named by its Go name or column
+func modelField(govault *internal.GovaultDB, db *bun.DB, model any, name string) (*internal.ModelSpec, *schema.Field, error) {
+	spec := govault.ModelSpec(model)
+	if spec == nil {
+		return nil, nil, fmt.Errorf("model must be a struct, got %T", model)
+	}
+	for _, field := range db.Table(spec.Type).Fields {
+		if field.GoName == name || field.Name == name {
+			return spec, field, nil
+		}
+	}
+	return nil, nil, fmt.Errorf("field %s not found in %s", name, spec.Table)
+}
+
+// companionColumns returns the columns derived from an encrypted field by
+// kind
+func companionColumns(field *internal.FieldSpec) map[string]string {
+	columns := make(map[string]string)
+	if field.BlindIndex != "" {
+		columns["blind index"] = field.BlindIndex
+	}
+	if field.PlainHash != "" {
+		columns["plaintext hash"] = field.PlainHash
+	}
+	if field.TokenIndex != "" {
+		columns["token index"] = field.TokenIndex
+	}
+	if field.Bloom != "" {
+		columns["bloom filter"] = field.Bloom
+	}
+	return columns
+}
+
+// holdsCiphertext reports whether an SQL type stores text
+func holdsCiphertext(sqlType string) bool {
+	sqlType = strings.ToUpper(sqlType)
+	for _, text := range []string{"TEXT", "CHAR", "CLOB", "STRING"} {
+		if strings.Contains(sqlType, text) {
+			return true
+		}
+	}
+	return false
+}
+
+// holdsBinary reports whether an SQL type stores bytes
+func holdsBinary(sqlType string) bool {
+	sqlType = strings.ToUpper(sqlType)
+	for _, binary := range []string{"BYTEA", "BLOB", "BINARY", "BYTES"} {
+		if strings.Contains(sqlType, binary) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,109 @@
+// Package govault - Bun adapter add and drop column tests
+package bun_test
+
+import (
+	"context"
+	"testing"
+
+	gb "github.com/muhammadluth/govault/bun"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uptrace/bun"
+)
+
+type TestColumnUserV1 struct {
+	bun.BaseModel `bun:"table:test_column_users"`
+	ID            int64  `bun:"id,pk,autoincrement"`
+	Name          string `bun:"name"`
+}
+
+type TestColumnUser struct {
+	bun.BaseModel `bun:"table:test_column_users"`
+	ID            int64  `bun:"id,pk,autoincrement"`
+	Name          string `bun:"name"`
+	Phone         string `bun:"phone" encrypted:"true" blindindex:"phone_bidx"`
+	PhoneBidx     string `bun:"phone_bidx"`
+	Secret        string `bun:"secret,type:integer" encrypted:"true"`
+	Token         string `bun:"token" encrypted:"true" blindindex:"token_bidx"`
+}
+
+func TestBunAddDropColumn(t *testing.T) {
+	db, _, cleanup := setupTestDB(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	_, err := db.NewCreateTable().Model((*TestColumnUserV1)(nil)).IfNotExists().Exec(ctx)
+	require.NoError(t, err)
+	defer db.NewDropTable().Model((*TestColumnUserV1)(nil)).IfExists().Exec(ctx)
+	defer db.NewDropTable().Model((*gb.CatalogColumn)(nil)).IfExists().Exec(ctx)
+
+	catalogued := func(t *testing.T, column string) bool {
+		columns, err := db.Catalog(ctx)
+		require.NoError(t, err)
+		for _, c := range columns {
+			if c.TableName == "test_column_users" && c.ColumnName == column {
+				return true
+			}
+		}
+		return false
+	}
+
+	t.Run("add encrypted column", func(t *testing.T) {
+		_, err := db.NewAddColumn().Field((*TestColumnUser)(nil), "PhoneBidx").Exec(ctx)
+		require.NoError(t, err)
+		_, err = db.NewAddColumn().Field((*TestColumnUser)(nil), "phone").Exec(ctx)
+		require.NoError(t, err)
+		assert.True(t, catalogued(t, "phone"))
+		assert.False(t, catalogued(t, "phone_bidx"))
+
+		_, err = db.NewInsert().Model(&TestColumnUser{Name: "Ayu", Phone: "+62811110000"}).
+			Column("name", "phone", "phone_bidx").Exec(ctx)
+		require.NoError(t, err)
+
+		var user TestColumnUser
+		err = db.NewSelect().Model(&user).Column("id", "name", "phone").Limit(1).Scan(ctx, &user)
+		require.NoError(t, err)
+		assert.Equal(t, "+62811110000", user.Phone)
+	})
+
+	t.Run("reject non text type", func(t *testing.T) {
+		_, err := db.NewAddColumn().Field((*TestColumnUser)(nil), "Secret").Exec(ctx)
+		assert.ErrorContains(t, err, "cannot hold ciphertext")
+		assert.False(t, catalogued(t, "secret"))
+	})
+
+	t.Run("reject missing blind index column", func(t *testing.T) {
+		type TestColumnUserNoBidx struct {
+			bun.BaseModel `bun:"table:test_column_users"`
+			ID            int64  `bun:"id,pk,autoincrement"`
+			Token         string `bun:"token" encrypted:"true" blindindex:"token_bidx"`
+		}
+		_, err := db.NewAddColumn().Field((*TestColumnUserNoBidx)(nil), "Token").Exec(ctx)
+		assert.ErrorContains(t, err, "blind index column token_bidx not found")
+	})
+
+	t.Run("unknown field", func(t *testing.T) {
+		_, err := db.NewAddColumn().Field((*TestColumnUser)(nil), "Missing").Exec(ctx)
+		assert.ErrorContains(t, err, "field Missing not found")
+	})
+
+	t.Run("drop blind index before its field", func(t *testing.T) {
+		_, err := db.NewDropColumn().Field((*TestColumnUser)(nil), "phone_bidx").Exec(ctx)
+		assert.ErrorContains(t, err, "blind index column of encrypted column phone")
+	})
+
+	t.Run("drop encrypted column", func(t *testing.T) {
+		_, err := db.NewDropColumn().Field((*TestColumnUser)(nil), "Phone").Exec(ctx)
+		require.NoError(t, err)
+		assert.False(t, catalogued(t, "phone"))
+	})
+
+	t.Run("in a transaction", func(t *testing.T) {
+		tx, err := db.BeginTx(ctx, nil)
+		require.NoError(t, err)
+		_, err = tx.NewAddColumn().Field((*TestColumnUser)(nil), "Phone").Exec(ctx)
+		require.NoError(t, err)
+		require.NoError(t, tx.Rollback())
+		assert.False(t, catalogued(t, "phone"))
+	})
+}
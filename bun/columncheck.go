@@ -0,0 +1,125 @@
+// Package govault - Bun adapter checks of clauses on encrypted columns
+package bun
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/muhammadluth/govault/internal"
+)
+
+// AllowEncryptedColumns disables the checks of encrypted columns in
+// ORDER BY, GROUP BY, WHERE and HAVING for this query, e.g. for
+// predicates comparing ciphertexts on purpose
+func (q *BunSelectQuery) AllowEncryptedColumns() *BunSelectQuery {
+	q.allowEncrypted = true
+	return q
+}
+
+// checkEncryptedColumns rejects clauses that only work on plaintext:
+// ordering by any encrypted column, and filtering or grouping by a
+// randomized one, whose equal plaintexts have different ciphertexts.
+// Comparisons with NULL or the empty string are allowed.
+func (q *BunSelectQuery) checkEncryptedColumns() error {
+	if q.allowEncrypted {
+		return nil
+	}
+	table, spec := q.modelSpec()
+	if spec == nil {
+		return nil
+	}
+
+	query, err := q.SelectQuery.AppendQuery(q.DB().QueryGen(), nil)
+	if err != nil {
+		// Reported when the query runs
+		return nil
+	}
+	return checkClauses(tokenizeSQL(string(query)), spec, table.Alias, table.Name)
+}
+
+// checkClauses walks the top level clauses of a formatted SELECT
+func checkClauses(tokens []sqlToken, spec *internal.ModelSpec, alias, tableName string) error {
+	columns := make(map[string]*internal.FieldSpec, len(spec.Fields))
+	for _, field := range spec.Fields {
+		columns[field.Column] = field
+	}
+
+	var clause string
+	for i := 0; i < len(tokens); i++ {
+		t := tokens[i]
+
+		// Sub-queries select from other tables
+		if t.text == "(" && i+1 < len(tokens) && isKeyword(tokens[i+1], "SELECT") {
+			i = skipParens(tokens, i)
+			continue
+		}
+
+		switch {
+		case isKeyword(t, "WHERE", "HAVING"):
+			clause = strings.ToUpper(t.text)
+			continue
+		case isKeyword(t, "GROUP", "ORDER") && i+1 < len(tokens) && isKeyword(tokens[i+1], "BY"):
+			clause = strings.ToUpper(t.text) + " BY"
+			i++
+			continue
+		case isKeyword(t, "FROM", "JOIN", "LIMIT", "OFFSET", "FETCH", "FOR", "WINDOW", "UNION", "EXCEPT", "INTERSECT"):
+			clause = ""
+			continue
+		}
+		if clause == "" {
+			continue
+		}
+
+		j, ok := columnRef(tokens, i, alias, tableName)
+		column := tokens[j].text
+		i = j
+		if !ok {
+			continue
+		}
+		field, ok := columns[column]
+		if !ok {
+			continue
+		}
+
+		if clause == "ORDER BY" {
+			return fmt.Errorf("cannot order by encrypted column %s.%s, ciphertexts don't sort like plaintexts: %w", spec.Table, column, ErrEncryptedColumn)
+		}
+		if field.Deterministic || comparesEmpty(tokens[i+1:]) {
+			continue
+		}
+		return fmt.Errorf("cannot use randomized encrypted column %s.%s in %s, equal plaintexts have different ciphertexts; tag it deterministic:\"true\" or query a blind index: %w",
+			spec.Table, column, clause, ErrEncryptedColumn)
+	}
+	return nil
+}
+
+// comparesEmpty reports whether tokens start with IS [NOT] NULL or a
+// comparison with the empty string
+func comparesEmpty(tokens []sqlToken) bool {
+	if len(tokens) > 0 && isKeyword(tokens[0], "IS") {
+		return true
+	}
+	var op string
+	for len(tokens) > 0 && tokens[0].kind == tokPunct && strings.Contains("=<>!", tokens[0].text) {
+		op += tokens[0].text
+		tokens = tokens[1:]
+	}
+	return (op == "=" || op == "<>" || op == "!=") && len(tokens) > 0 && tokens[0].kind == tokString && tokens[0].text == ""
+}
+
+// skipParens returns the index of the parenthesis closing tokens[i]
+func skipParens(tokens []sqlToken, i int) int {
+	depth := 0
+	for ; i < len(tokens); i++ {
+		switch tokens[i].text {
+		case "(":
+			depth++
+		case ")":
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return i
+}
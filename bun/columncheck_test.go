@@ -0,0 +1,80 @@
+// Package govault - Bun adapter encrypted column clause check tests
+package bun_test
+
+import (
+	"context"
+	"testing"
+
+	gb "github.com/muhammadluth/govault/bun"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBunEncryptedColumnClauses(t *testing.T) {
+	db, _, cleanup := setupTestDB(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	_, err := db.NewCreateTable().Model((*TestCatalogUser)(nil)).IfNotExists().Exec(ctx)
+	require.NoError(t, err)
+	defer db.NewDropTable().Model((*TestCatalogUser)(nil)).IfExists().Exec(ctx)
+
+	tests := []struct {
+		name  string
+		apply func(*gb.BunSelectQuery) *gb.BunSelectQuery
+		err   string
+	}{
+		{"order by randomized", func(q *gb.BunSelectQuery) *gb.BunSelectQuery {
+			return q.Model((*TestUser)(nil)).Order("email ASC")
+		}, "cannot order by encrypted column test_users.email"},
+		{"order by qualified", func(q *gb.BunSelectQuery) *gb.BunSelectQuery {
+			return q.Model((*TestUser)(nil)).OrderExpr("u.phone DESC")
+		}, "cannot order by encrypted column test_users.phone"},
+		{"order by deterministic", func(q *gb.BunSelectQuery) *gb.BunSelectQuery {
+			return q.Model((*TestCatalogUser)(nil)).Order("email")
+		}, "cannot order by encrypted column test_catalog_users.email"},
+		{"where randomized", func(q *gb.BunSelectQuery) *gb.BunSelectQuery {
+			return q.Model((*TestUser)(nil)).Where("name = ?", "a").Where("lower(email) = ?", "a@example.com")
+		}, "cannot use randomized encrypted column test_users.email in WHERE"},
+		{"group by randomized", func(q *gb.BunSelectQuery) *gb.BunSelectQuery {
+			return q.Model((*TestUser)(nil)).Group("phone")
+		}, "cannot use randomized encrypted column test_users.phone in GROUP BY"},
+		{"having randomized", func(q *gb.BunSelectQuery) *gb.BunSelectQuery {
+			return q.Model((*TestUser)(nil)).Group("name").Having("count(DISTINCT email) > 1")
+		}, "in HAVING"},
+		{"plaintext columns", func(q *gb.BunSelectQuery) *gb.BunSelectQuery {
+			return q.Model((*TestUser)(nil)).Where("name = ?", "email").Group("address").Order("name")
+		}, ""},
+		{"null and empty", func(q *gb.BunSelectQuery) *gb.BunSelectQuery {
+			return q.Model((*TestUser)(nil)).Where("email IS NOT NULL").Where("phone <> ''")
+		}, ""},
+		{"deterministic equality", func(q *gb.BunSelectQuery) *gb.BunSelectQuery {
+			return q.Model((*TestCatalogUser)(nil)).Where("email = ?", "gv1:1|x|y").Group("email")
+		}, ""},
+		{"blind index", func(q *gb.BunSelectQuery) *gb.BunSelectQuery {
+			return q.Model((*TestCatalogUser)(nil)).Where("phone_bidx = ?", "x").Order("phone_bidx")
+		}, ""},
+		{"sub-query", func(q *gb.BunSelectQuery) *gb.BunSelectQuery {
+			return q.Model((*TestUser)(nil)).Where("id IN (SELECT id FROM test_catalog_users WHERE phone = 'x')")
+		}, ""},
+		{"allowed", func(q *gb.BunSelectQuery) *gb.BunSelectQuery {
+			return q.Model((*TestUser)(nil)).Order("email").AllowEncryptedColumns()
+		}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tt.apply(db.NewSelect()).Count(ctx)
+			if tt.err == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorIs(t, err, gb.ErrEncryptedColumn)
+			assert.ErrorContains(t, err, tt.err)
+		})
+	}
+
+	var users []TestUser
+	err = db.NewSelect().Model(&users).Order("email").Scan(ctx, &users)
+	assert.ErrorIs(t, err, gb.ErrEncryptedColumn)
+}
@@ -0,0 +1,123 @@
+// Package govault - Bun adapter integration test databases
+package bun_test
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/muhammadluth/govault/govaulttest"
+	"github.com/testcontainers/testcontainers-go"
+	tcmysql "github.com/testcontainers/testcontainers-go/modules/mysql"
+	tcpostgres "github.com/testcontainers/testcontainers-go/modules/postgres"
+	"github.com/uptrace/bun/driver/pgdriver"
+)
+
+// The integration tests use the databases set in the environment, see
+// govaulttest.DBFromEnv, e.g. in CI. Otherwise a container is started on
+// first use and removed after the tests; without Docker the tests are
+// skipped, unless GOVAULT_TEST_REQUIRE_DB is set, as in CI, where they
+// fail instead.
+
+// testDatabase starts one container per test binary
+type testDatabase struct {
+	once      sync.Once
+	dsn       string
+	err       error
+	container testcontainers.Container
+}
+
+var (
+	testPostgres testDatabase
+	testMySQL    testDatabase
+)
+
+func TestMain(m *testing.M) {
+	code := m.Run()
+	for _, db := range []*testDatabase{&testPostgres, &testMySQL} {
+		if db.container != nil {
+			_ = testcontainers.TerminateContainer(db.container)
+		}
+	}
+	os.Exit(code)
+}
+
+// openPostgres connects to the Postgres test database
+func openPostgres(t testing.TB) *sql.DB {
+	t.Helper()
+	dsn := testPostgres.get(t, govaulttest.Postgres, func(ctx context.Context, db govaulttest.DB) (testcontainers.Container, string, error) {
+		c, err := tcpostgres.Run(ctx, "postgres:16-alpine",
+			tcpostgres.WithDatabase(db.Database),
+			tcpostgres.WithUsername(db.User),
+			tcpostgres.WithPassword(db.Password),
+			tcpostgres.BasicWaitStrategies(),
+		)
+		if err != nil {
+			return c, "", err
+		}
+		dsn, err := c.ConnectionString(ctx, "sslmode=disable")
+		return c, dsn, err
+	})
+	return sql.OpenDB(pgdriver.NewConnector(pgdriver.WithDSN(dsn), pgdriver.WithDialTimeout(5*time.Second)))
+}
+
+// openMySQL connects to the MySQL test database
+func openMySQL(t testing.TB) *sql.DB {
+	t.Helper()
+	dsn := testMySQL.get(t, govaulttest.MySQL, func(ctx context.Context, db govaulttest.DB) (testcontainers.Container, string, error) {
+		c, err := tcmysql.Run(ctx, "mysql:8.4",
+			tcmysql.WithDatabase(db.Database),
+			tcmysql.WithUsername(db.User),
+			tcmysql.WithPassword(db.Password),
+		)
+		if err != nil {
+			return c, "", err
+		}
+		dsn, err := c.ConnectionString(ctx, "parseTime=true")
+		return c, dsn, err
+	})
+
+	db, err := sql.Open("mysql", dsn)
+	if err != nil {
+		t.Fatalf("failed to open mysql: %v", err)
+	}
+	return db
+}
+
+// get returns the DSN of the database of dialect set in the environment,
+// or of a container started by run with its defaults. Tests are skipped
+// when the container cannot be started.
+func (db *testDatabase) get(t testing.TB, dialect string, run func(context.Context, govaulttest.DB) (testcontainers.Container, string, error)) string {
+	t.Helper()
+	config, set, err := govaulttest.DBFromEnv(dialect)
+	if err != nil {
+		t.Fatalf("failed to configure the test database: %v", err)
+	}
+	if set {
+		return config.ConnString()
+	}
+
+	db.once.Do(func() {
+		defer func() {
+			// Some providers panic instead of failing without Docker
+			if r := recover(); r != nil {
+				db.err = fmt.Errorf("%v", r)
+			}
+		}()
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+		defer cancel()
+		db.container, db.dsn, db.err = run(ctx, config)
+	})
+	if db.err != nil {
+		if os.Getenv("GOVAULT_TEST_REQUIRE_DB") != "" {
+			t.Fatalf("no %s test database: %v", dialect, db.err)
+		}
+		t.Skipf("no %s test database, see govaulttest.DBFromEnv or start Docker: %v", dialect, db.err)
+	}
+	return db.dsn
+}
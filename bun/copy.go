@@ -0,0 +1,367 @@
+// Package govault - Bun adapter bulk loading with pre-encryption
+package bun
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"reflect"
+	"runtime"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/muhammadluth/govault/internal"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/driver/pgdriver"
+	"github.com/uptrace/bun/schema"
+)
+
+// DefaultCopyBatchSize is the number of rows CopyFrom encrypts at a time
+const DefaultCopyBatchSize = 1000
+
+// CopyOptions configures CopyFrom
+type CopyOptions struct {
+	// Columns are loaded, by default every column but auto-increment,
+	// identity and defaulted ones
+	Columns []string
+	// BatchSize defaults to DefaultCopyBatchSize
+	BatchSize int
+	// Workers encrypt the rows of a batch in parallel, defaults to
+	// GOMAXPROCS
+	Workers int
+}
+
+// CopyResult reports the rows loaded by CopyFrom
+type CopyResult struct {
+	Table string `json:"table"`
+	Rows  int64  `json:"rows"`
+	// Copy is false when the rows were inserted in batches instead
+	Copy bool `json:"copy"`
+}
+
+// CopyFrom bulk loads models, pointers to structs of one type, into their
+// table. Rows are read and encrypted in batches, in parallel, and streamed
+// with a single COPY FROM STDIN on Postgres through pgdriver, so a failure
+// loads no row. Other drivers get one multi-row INSERT per batch, and rows
+// of the batches before a failure stay loaded unless run in a transaction.
+// Models are encrypted in place.
+func (db *BunDB) CopyFrom(ctx context.Context, models iter.Seq[any], opts CopyOptions) (*CopyResult, error) {
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = DefaultCopyBatchSize
+	}
+	if opts.Workers <= 0 {
+		opts.Workers = runtime.GOMAXPROCS(0)
+	}
+
+	next, stop := iter.Pull(models)
+	defer stop()
+	var typ reflect.Type
+	var offset int64
+	readBatch := func() ([]any, error) {
+		batch := make([]any, 0, opts.BatchSize)
+		for len(batch) < opts.BatchSize {
+			model, ok := next()
+			if !ok {
+				break
+			}
+			if typ == nil {
+				typ = reflect.TypeOf(model)
+				if typ == nil || typ.Kind() != reflect.Ptr || typ.Elem().Kind() != reflect.Struct {
+					return nil, fmt.Errorf("models must be pointers to structs, got %T", model)
+				}
+			}
+			if reflect.TypeOf(model) != typ {
+				return nil, fmt.Errorf("row %d: models must all be %s, got %T", offset+int64(len(batch)), typ, model)
+			}
+			if err := db.govault.BeforeEncrypt(internal.OperationInsert, model); err != nil {
+				return nil, err
+			}
+			batch = append(batch, model)
+		}
+		if err := db.encryptBatch(ctx, batch, offset, opts.Workers); err != nil {
+			return nil, err
+		}
+		offset += int64(len(batch))
+		return batch, nil
+	}
+
+	batch, err := readBatch()
+	if err != nil {
+		return nil, err
+	}
+	if len(batch) == 0 {
+		return &CopyResult{}, nil
+	}
+	bindBinaryFields(db.DB, db.govault, batch[0])
+	table := db.DB.Table(typ.Elem())
+	columns, err := copyColumns(table, opts.Columns)
+	if err != nil {
+		return nil, err
+	}
+	result := &CopyResult{Table: table.Name}
+
+	if !usesPgDriver(db.DB) {
+		for len(batch) > 0 {
+			slice := reflect.New(reflect.SliceOf(typ))
+			for _, model := range batch {
+				slice.Elem().Set(reflect.Append(slice.Elem(), reflect.ValueOf(model)))
+			}
+			q := db.DB.NewInsert().Model(slice.Interface())
+			if len(opts.Columns) > 0 {
+				q = q.Column(opts.Columns...)
+			}
+			if _, err := q.Exec(ctx); err != nil {
+				return nil, fmt.Errorf("failed to insert into %s: %w", table.Name, err)
+			}
+			result.Rows += int64(len(batch))
+			if batch, err = readBatch(); err != nil {
+				return nil, err
+			}
+		}
+		return result, nil
+	}
+
+	result.Copy = true
+	idents := make([]bun.Ident, len(columns))
+	for i, column := range columns {
+		if column.Tag.HasOption("array") {
+			return nil, fmt.Errorf("array column %s is not supported by COPY", column.Name)
+		}
+		idents[i] = bun.Ident(column.Name)
+	}
+	query, err := db.DB.NewRaw("COPY ? (?) FROM STDIN", table.SQLName, bun.In(idents)).AppendQuery(db.DB.QueryGen(), nil)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := db.DB.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	pr, pw := io.Pipe()
+	done := make(chan error, 1)
+	go func() {
+		_, err := pgdriver.CopyFrom(ctx, conn, pr, string(query))
+		pr.CloseWithError(err)
+		done <- err
+	}()
+
+	var buf []byte
+	var loadErr error
+	for len(batch) > 0 {
+		buf = buf[:0]
+		for _, model := range batch {
+			if buf, loadErr = appendCopyRow(buf, columns, reflect.ValueOf(model).Elem()); loadErr != nil {
+				break
+			}
+		}
+		if loadErr == nil {
+			if _, err := pw.Write(buf); err != nil {
+				break
+			}
+			result.Rows += int64(len(batch))
+			batch, loadErr = readBatch()
+		}
+		if loadErr != nil {
+			break
+		}
+	}
+
+	if loadErr != nil {
+		// pgdriver stops sending at the error of the pipe, leaving the
+		// COPY open for abortCopy
+		pw.CloseWithError(loadErr)
+		<-done
+		abortCopy(conn)
+		return nil, loadErr
+	}
+	pw.Close()
+	if err := <-done; err != nil {
+		return nil, fmt.Errorf("failed to copy into %s: %w", table.Name, err)
+	}
+	return result, nil
+}
+
+// abortCopy fails the COPY FROM STDIN in progress on conn, so the server
+// loads none of its rows, and closes the connection. pgdriver has no
+// CopyFail of its own: the message is written on the network connection,
+// which pgdriver no longer tracks the state of, hence the close, that also
+// aborts the COPY should the write fail.
+func abortCopy(conn bun.Conn) {
+	_ = conn.Raw(func(driverConn any) error {
+		cn, ok := driverConn.(*pgdriver.Conn)
+		if !ok {
+			return nil
+		}
+		reason := "govault: copy aborted\x00"
+		msg := binary.BigEndian.AppendUint32([]byte{'f'}, uint32(4+len(reason)))
+		_, _ = cn.Conn().Write(append(msg, reason...))
+		return cn.Close()
+	})
+}
+
+// usesPgDriver reports whether db connects through pgdriver, which
+// supports COPY
+func usesPgDriver(db *bun.DB) bool {
+	switch db.Driver().(type) {
+	case pgdriver.Driver, *pgdriver.Driver:
+		return true
+	}
+	return false
+}
+
+// encryptBatch encrypts models in parallel; offset numbers the rows in
+// errors
+func (db *BunDB) encryptBatch(ctx context.Context, batch []any, offset int64, workers int) error {
+	errs := make([]error, workers)
+	var wg sync.WaitGroup
+	for w := 0; w < workers && w < len(batch); w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := w; i < len(batch); i += workers {
+				if err := db.govault.EncryptModelContext(ctx, batch[i], db.keyID); err != nil {
+					errs[w] = fmt.Errorf("row %d: %w", offset+int64(i), err)
+					return
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// copyColumns returns the fields of names, or the default columns of COPY
+func copyColumns(table *schema.Table, names []string) ([]*schema.Field, error) {
+	var columns []*schema.Field
+	for _, name := range names {
+		field, ok := table.FieldMap[name]
+		if !ok {
+			return nil, fmt.Errorf("column %s not found in %s", name, table.Name)
+		}
+		columns = append(columns, field)
+	}
+	if len(names) > 0 {
+		return columns, nil
+	}
+	for _, field := range table.Fields {
+		if field.AutoIncrement || field.Identity || field.SQLDefault != "" {
+			continue
+		}
+		columns = append(columns, field)
+	}
+	return columns, nil
+}
+
+// appendCopyRow appends a row of the COPY text format
+func appendCopyRow(b []byte, columns []*schema.Field, strct reflect.Value) ([]byte, error) {
+	for i, field := range columns {
+		if i > 0 {
+			b = append(b, '\t')
+		}
+		value := field.Value(strct)
+		if field.NullZero && field.HasZeroValue(strct) {
+			b = append(b, `\N`...)
+			continue
+		}
+		text, null, err := copyText(value)
+		if err != nil {
+			return nil, fmt.Errorf("column %s: %w", field.Name, err)
+		}
+		if null {
+			b = append(b, `\N`...)
+			continue
+		}
+		if isBinaryField(field) {
+			data, err := internal.EncodeBinary(text)
+			if err != nil {
+				return nil, fmt.Errorf("column %s: %w", field.Name, err)
+			}
+			text = `\x` + hex.EncodeToString(data)
+		}
+		b = appendCopyEscaped(b, text)
+	}
+	return append(b, '\n'), nil
+}
+
+// copyText returns the text representation of a column value
+func copyText(v reflect.Value) (string, bool, error) {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return "", true, nil
+		}
+		if _, ok := v.Interface().(driver.Valuer); ok {
+			break
+		}
+		v = v.Elem()
+	}
+
+	value := v.Interface()
+	if valuer, ok := value.(driver.Valuer); ok {
+		var err error
+		if value, err = valuer.Value(); err != nil {
+			return "", false, err
+		}
+	}
+
+	switch x := value.(type) {
+	case nil:
+		return "", true, nil
+	case string:
+		return x, false, nil
+	case []byte:
+		if x == nil {
+			return "", true, nil
+		}
+		return `\x` + hex.EncodeToString(x), false, nil
+	case time.Time:
+		return x.UTC().Format(time.RFC3339Nano), false, nil
+	case bool:
+		return strconv.FormatBool(x), false, nil
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+		return fmt.Sprint(x), false, nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		return v.String(), false, nil
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool()), false, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10), false, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(v.Uint(), 10), false, nil
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return "", false, err
+	}
+	return string(data), false, nil
+}
+
+// appendCopyEscaped escapes the characters special to the COPY text format
+func appendCopyEscaped(b []byte, s string) []byte {
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '\\':
+			b = append(b, `\\`...)
+		case '\t':
+			b = append(b, `\t`...)
+		case '\n':
+			b = append(b, `\n`...)
+		case '\r':
+			b = append(b, `\r`...)
+		default:
+			b = append(b, c)
+		}
+	}
+	return b
+}
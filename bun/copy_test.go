@@ -0,0 +1,82 @@
+// Package govault - Bun adapter bulk loading tests
+package bun_test
+
+import (
+	"context"
+	"fmt"
+	"iter"
+	"strings"
+	"testing"
+
+	gb "github.com/muhammadluth/govault/bun"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// copyUsers yields n users, then extra
+func copyUsers(n int, extra ...any) iter.Seq[any] {
+	return func(yield func(any) bool) {
+		for i := 0; i < n; i++ {
+			user := &TestUser{
+				Name:  fmt.Sprintf("Copy %d", i),
+				Email: fmt.Sprintf("copy%d@example.com", i),
+				Phone: fmt.Sprintf("+6281%08d", i),
+			}
+			if !yield(user) {
+				return
+			}
+		}
+		for _, model := range extra {
+			if !yield(model) {
+				return
+			}
+		}
+	}
+}
+
+func TestBunCopyFrom(t *testing.T) {
+	db, _, cleanup := setupTestDB(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	special := &TestUser{Name: "Tab\tNew\nLine \\ back", Email: "special@example.com"}
+	result, err := db.CopyFrom(ctx, copyUsers(2500, special), gb.CopyOptions{BatchSize: 1000, Workers: 4})
+	require.NoError(t, err)
+	assert.Equal(t, int64(2501), result.Rows)
+	assert.Equal(t, "test_users", result.Table)
+	assert.True(t, result.Copy)
+
+	count, err := db.NewSelect().Model((*TestUser)(nil)).Count(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 2501, count)
+
+	var raw []TestUser
+	require.NoError(t, db.DB.NewSelect().Model(&raw).Limit(10).Scan(ctx))
+	for _, user := range raw {
+		assert.True(t, strings.HasPrefix(user.Email, "gv1:"), "rows are stored encrypted")
+	}
+
+	var user TestUser
+	err = db.NewSelect().Model(&user).Where("name = ?", "Copy 1234").Scan(ctx, &user)
+	require.NoError(t, err)
+	assert.Equal(t, "copy1234@example.com", user.Email)
+	assert.Equal(t, "+628100001234", user.Phone)
+
+	user = TestUser{}
+	err = db.NewSelect().Model(&user).Where("name = ?", special.Name).Scan(ctx, &user)
+	require.NoError(t, err)
+	assert.Equal(t, "special@example.com", user.Email)
+
+	// A failure past the first batch loads no row
+	_, err = db.CopyFrom(ctx, copyUsers(1500, &TestCatalogUser{}), gb.CopyOptions{BatchSize: 1000})
+	assert.EqualError(t, err, "row 1500: models must all be *bun_test.TestUser, got *bun_test.TestCatalogUser",
+		"the error is the one of the row, not of the aborted COPY")
+	count, err = db.NewSelect().Model((*TestUser)(nil)).Count(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 2501, count)
+
+	_, err = db.CopyFrom(ctx, copyUsers(0, TestUser{}), gb.CopyOptions{})
+	assert.ErrorContains(t, err, "models must be pointers to structs")
+	_, err = db.CopyFrom(ctx, copyUsers(1), gb.CopyOptions{Columns: []string{"missing"}})
+	assert.ErrorContains(t, err, "column missing not found in test_users")
+}
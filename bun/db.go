@@ -51,6 +51,12 @@ func (db *BunDB) WithKey(keyID string) *BunDB {
 	}
 }
 
+// KeyID returns the key passed on to the queries and transactions of db,
+// empty for the default key
+func (db *BunDB) KeyID() string {
+	return db.keyID
+}
+
 // WithQueryHook returns a copy of the DB with the provided query hook attached.
 func (db *BunDB) WithQueryHook(hook bun.QueryHook) *BunDB {
 	return &BunDB{
@@ -101,6 +107,7 @@ func (db *BunDB) NewSelect() *BunSelectQuery {
 	return &BunSelectQuery{
 		SelectQuery: db.DB.NewSelect(),
 		govault:     db.govault,
+		keyID:       db.keyID,
 	}
 }
 
@@ -108,6 +115,7 @@ func (db *BunDB) NewSelect() *BunSelectQuery {
 func (db *BunDB) NewUpdate() *BunUpdateQuery {
 	return &BunUpdateQuery{
 		UpdateQuery: db.DB.NewUpdate(),
+		conn:        db.DB,
 		govault:     db.govault,
 		keyID:       db.keyID,
 	}
@@ -117,6 +125,7 @@ func (db *BunDB) NewUpdate() *BunUpdateQuery {
 func (db *BunDB) NewDelete() *BunDeleteQuery {
 	return &BunDeleteQuery{
 		DeleteQuery: db.DB.NewDelete(),
+		conn:        db.DB,
 		govault:     db.govault,
 		keyID:       db.keyID,
 	}
@@ -126,11 +135,40 @@ func (db *BunDB) NewDelete() *BunDeleteQuery {
 func (db *BunDB) NewRaw(query string, args ...any) *BunRawQuery {
 	return &BunRawQuery{
 		RawQuery: db.DB.NewRaw(query, args...),
+		conn:     db.DB,
 		govault:  db.govault,
 		keyID:    db.keyID,
 	}
 }
 
+// NewRawNamed creates a raw query binding params to its ?name
+// placeholders. Parameters named like an encrypted column of a model known
+// to bun, e.g. by RegisterModel or a previous query, are encrypted with the
+// key of the query:
+//
+//	db.NewRawNamed("UPDATE users SET email = ?email WHERE id = ?id",
+//		map[string]any{"email": "ada@example.com", "id": 1})
+//
+// Parameters of columns encrypted differently in several tables are
+// rejected; bind them with EncryptValue.
+func (db *BunDB) NewRawNamed(query string, params map[string]any) *BunRawQuery {
+	bound, err := bindNamed(db.DB.Dialect().Tables(), db.govault, db.keyID, params)
+	if err != nil {
+		return db.NewRaw(query).Err(err)
+	}
+	return db.NewRaw(query, bound)
+}
+
+// NewRawQ creates a raw query expanded from a template, whose columns are
+// qualified by their table, see Q
+func (db *BunDB) NewRawQ(t Template) *BunRawQuery {
+	query, args, err := t.expand(db.govault, db.DB.Dialect().Tables(), nil, db.keyID)
+	if err != nil {
+		return db.NewRaw(t.query).Err(err)
+	}
+	return db.NewRaw(query, args...)
+}
+
 // NewMerge creates a new merge query
 func (db *BunDB) NewMerge() *bun.MergeQuery {
 	return db.DB.NewMerge()
@@ -152,13 +190,21 @@ func (db *BunDB) NewTruncateTable() *bun.TruncateTableQuery {
 }
 
 // NewAddColumn creates a new add column query
-func (db *BunDB) NewAddColumn() *bun.AddColumnQuery {
-	return db.DB.NewAddColumn()
+func (db *BunDB) NewAddColumn() *BunAddColumnQuery {
+	return &BunAddColumnQuery{
+		AddColumnQuery: db.DB.NewAddColumn(),
+		conn:           db.DB,
+		govault:        db.govault,
+	}
 }
 
 // NewDropColumn creates a new drop column query
-func (db *BunDB) NewDropColumn() *bun.DropColumnQuery {
-	return db.DB.NewDropColumn()
+func (db *BunDB) NewDropColumn() *BunDropColumnQuery {
+	return &BunDropColumnQuery{
+		DropColumnQuery: db.DB.NewDropColumn(),
+		conn:            db.DB,
+		govault:         db.govault,
+	}
 }
 
 // ResetModel resets the model
@@ -176,9 +222,14 @@ func (db *BunDB) NewDropIndex() *bun.DropIndexQuery {
 	return db.DB.NewDropIndex()
 }
 
-// NewValues creates a new values query
+// NewValues creates a new values query with encryption of the model, a
+// struct or a slice of structs, e.g. for bulk UPDATE ... FROM VALUES
 func (db *BunDB) NewValues(model any) *bun.ValuesQuery {
-	return db.DB.NewValues(model)
+	q := db.DB.NewValues(model)
+	if err := encryptValues(db.govault, model, db.keyID); err != nil {
+		return q.Err(err)
+	}
+	return q
 }
 
 // Exec executes the query
@@ -221,9 +272,29 @@ func (db *BunDB) Table(typ reflect.Type) *schema.Table {
 	return db.DB.Table(typ)
 }
 
-// RegisterModel registers the models
-func (db *BunDB) RegisterModel(models ...any) {
+// RegisterModel registers models with bun and installs append/scan hooks on
+// their encrypted fields. Afterwards queries issued directly on the
+// underlying bun.DB encrypt on write and decrypt on scan as well.
+//
+// The hooks use the default key and do not fill blind index columns; use
+// the wrapper queries for models with blind indexes.
+//
+// Models must be pointers to structs; invalid models and table metadata
+// bun can't parse are reported as errors.
+func (db *BunDB) RegisterModel(models ...any) (err error) {
+	for _, model := range models {
+		if typ := reflect.TypeOf(model); typ == nil || typ.Kind() != reflect.Ptr || typ.Elem().Kind() != reflect.Struct {
+			return fmt.Errorf("models must be pointers to structs, got %T", model)
+		}
+	}
+	defer func() {
+		// bun panics on invalid metadata, e.g. a join on a missing column
+		if r := recover(); r != nil {
+			err = fmt.Errorf("failed to register model: %v", r)
+		}
+	}()
 	db.DB.RegisterModel(models...)
+	return RegisterEncryptedModels(db.DB, db.govault, models...)
 }
 
 // ScanRow executes the query and scans the result
@@ -233,11 +304,11 @@ func (db *BunDB) ScanRow(ctx context.Context, rows *sql.Rows, dest ...any) error
 		return err
 	}
 	for _, d := range dest {
-		if err := db.govault.DecryptRecursive(d); err != nil {
+		if err := db.govault.DecryptRecursiveContext(ctx, d); err != nil {
 			return err
 		}
 	}
-	return nil
+	return db.govault.AfterDecrypt(ctx, internal.OperationSelect, dest...)
 }
 
 // ScanRows executes the query and scans the result
@@ -247,11 +318,11 @@ func (db *BunDB) ScanRows(ctx context.Context, rows *sql.Rows, dest ...any) erro
 		return err
 	}
 	for _, d := range dest {
-		if err := db.govault.DecryptRecursive(d); err != nil {
+		if err := db.govault.DecryptRecursiveContext(ctx, d); err != nil {
 			return err
 		}
 	}
-	return nil
+	return db.govault.AfterDecrypt(ctx, internal.OperationSelect, dest...)
 }
 
 // Begin starts a new transaction
@@ -312,6 +383,12 @@ func (tx *BunTx) WithKey(keyID string) *BunTx {
 	}
 }
 
+// KeyID returns the key passed on to the queries and savepoints of tx,
+// empty for the default key
+func (tx *BunTx) KeyID() string {
+	return tx.keyID
+}
+
 // NewInsert creates a new insert query with encryption
 func (tx *BunTx) NewInsert() *BunInsertQuery {
 	return &BunInsertQuery{
@@ -326,6 +403,8 @@ func (tx *BunTx) NewSelect() *BunSelectQuery {
 	return &BunSelectQuery{
 		SelectQuery: tx.Tx.NewSelect(),
 		govault:     tx.govault,
+		keyID:       tx.keyID,
+		conn:        tx.Tx,
 	}
 }
 
@@ -333,6 +412,7 @@ func (tx *BunTx) NewSelect() *BunSelectQuery {
 func (tx *BunTx) NewUpdate() *BunUpdateQuery {
 	return &BunUpdateQuery{
 		UpdateQuery: tx.Tx.NewUpdate(),
+		conn:        tx.Tx,
 		govault:     tx.govault,
 		keyID:       tx.keyID,
 	}
@@ -342,6 +422,7 @@ func (tx *BunTx) NewUpdate() *BunUpdateQuery {
 func (tx *BunTx) NewDelete() *BunDeleteQuery {
 	return &BunDeleteQuery{
 		DeleteQuery: tx.Tx.NewDelete(),
+		conn:        tx.Tx,
 		govault:     tx.govault,
 		keyID:       tx.keyID,
 	}
@@ -351,11 +432,31 @@ func (tx *BunTx) NewDelete() *BunDeleteQuery {
 func (tx *BunTx) NewRaw(query string, args ...any) *BunRawQuery {
 	return &BunRawQuery{
 		RawQuery: tx.Tx.NewRaw(query, args...),
+		conn:     tx.Tx,
 		govault:  tx.govault,
 		keyID:    tx.keyID,
 	}
 }
 
+// NewRawNamed creates a raw query binding named parameters, see
+// BunDB.NewRawNamed
+func (tx *BunTx) NewRawNamed(query string, params map[string]any) *BunRawQuery {
+	bound, err := bindNamed(tx.Tx.Dialect().Tables(), tx.govault, tx.keyID, params)
+	if err != nil {
+		return tx.NewRaw(query).Err(err)
+	}
+	return tx.NewRaw(query, bound)
+}
+
+// NewRawQ creates a raw query expanded from a template, see BunDB.NewRawQ
+func (tx *BunTx) NewRawQ(t Template) *BunRawQuery {
+	query, args, err := t.expand(tx.govault, tx.Tx.Dialect().Tables(), nil, tx.keyID)
+	if err != nil {
+		return tx.NewRaw(t.query).Err(err)
+	}
+	return tx.NewRaw(query, args...)
+}
+
 // NewMerge creates a new merge query
 func (tx *BunTx) NewMerge() *bun.MergeQuery {
 	return tx.Tx.NewMerge()
@@ -377,13 +478,21 @@ func (tx *BunTx) NewTruncateTable() *bun.TruncateTableQuery {
 }
 
 // NewAddColumn creates a new add column query
-func (tx *BunTx) NewAddColumn() *bun.AddColumnQuery {
-	return tx.Tx.NewAddColumn()
+func (tx *BunTx) NewAddColumn() *BunAddColumnQuery {
+	return &BunAddColumnQuery{
+		AddColumnQuery: tx.Tx.NewAddColumn(),
+		conn:           tx.Tx,
+		govault:        tx.govault,
+	}
 }
 
 // NewDropColumn creates a new drop column query
-func (tx *BunTx) NewDropColumn() *bun.DropColumnQuery {
-	return tx.Tx.NewDropColumn()
+func (tx *BunTx) NewDropColumn() *BunDropColumnQuery {
+	return &BunDropColumnQuery{
+		DropColumnQuery: tx.Tx.NewDropColumn(),
+		conn:            tx.Tx,
+		govault:         tx.govault,
+	}
 }
 
 // NewCreateIndex creates a new create index query
@@ -396,9 +505,22 @@ func (tx *BunTx) NewDropIndex() *bun.DropIndexQuery {
 	return tx.Tx.NewDropIndex()
 }
 
-// NewValues creates a new values query
+// NewValues creates a new values query with encryption of the model, a
+// struct or a slice of structs, e.g. for bulk UPDATE ... FROM VALUES
 func (tx *BunTx) NewValues(model any) *bun.ValuesQuery {
-	return tx.Tx.NewValues(model)
+	q := tx.Tx.NewValues(model)
+	if err := encryptValues(tx.govault, model, tx.keyID); err != nil {
+		return q.Err(err)
+	}
+	return q
+}
+
+// encryptValues encrypts the model of a values query
+func encryptValues(govault *internal.GovaultDB, model any, keyID string) error {
+	if err := govault.BeforeEncrypt(internal.OperationValues, model); err != nil {
+		return err
+	}
+	return govault.EncryptModel(model, keyID)
 }
 
 // Exec executes the query
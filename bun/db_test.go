@@ -3,19 +3,19 @@ package bun_test
 import (
 	"context"
 	"database/sql"
+	"encoding/base64"
 	"fmt"
 	"reflect"
 	"strings"
 	"testing"
-	"time"
 
 	"github.com/muhammadluth/govault"
+	"github.com/muhammadluth/govault/govaulttest"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/uptrace/bun"
 	"github.com/uptrace/bun/dialect/feature"
 	"github.com/uptrace/bun/dialect/pgdialect"
-	"github.com/uptrace/bun/driver/pgdriver"
 
 	gb "github.com/muhammadluth/govault/bun"
 )
@@ -65,27 +65,14 @@ type TestUserWithPrivate struct {
 
 func setupTestDB(t *testing.T) (*gb.BunDB, *govault.GovaultDB, func()) {
 	// Setup Bun connection
-	openDB := sql.OpenDB(pgdriver.NewConnector(
-		pgdriver.WithNetwork("tcp"),
-		pgdriver.WithAddr("localhost:5433"),
-		pgdriver.WithUser("postgres"),
-		pgdriver.WithPassword("Admin123!"),
-		pgdriver.WithDatabase("postgres"),
-		pgdriver.WithApplicationName("playground"),
-		pgdriver.WithTLSConfig(nil),
-		pgdriver.WithDialTimeout(5*time.Second),
-	))
+	openDB := openPostgres(t)
 
 	bunDB := bun.NewDB(openDB, pgdialect.New())
 
 	goVaultDB, err := govault.New(govault.Config{
-		AdapterName: govault.AdapterNameBun,
-		BunDB:       bunDB,
-		Keys: map[string][]byte{
-			"1": []byte("727d37a0-a5f2-4d67-af47-83039c8e"),
-			"2": []byte("e778dc27-9b04-44c3-a862-feba061c"),
-			"3": []byte("e778dc27-9b04-44c3-a862-83039c8e"),
-		},
+		AdapterName:  govault.AdapterNameBun,
+		BunDB:        bunDB,
+		Keys:         govaulttest.EphemeralKeys(3),
 		DefaultKeyID: "3", // Key 3 is default for encryption
 	})
 	if err != nil {
@@ -115,25 +102,14 @@ func setupTestDB(t *testing.T) (*gb.BunDB, *govault.GovaultDB, func()) {
 
 func TestBunAdapter(t *testing.T) {
 	t.Run("adapter get name", func(t *testing.T) {
-		openDB := sql.OpenDB(pgdriver.NewConnector(
-			pgdriver.WithNetwork("tcp"),
-			pgdriver.WithAddr("localhost:5433"),
-			pgdriver.WithUser("postgres"),
-			pgdriver.WithPassword("Admin123!"),
-			pgdriver.WithDatabase("postgres"),
-			pgdriver.WithApplicationName("playground"),
-			pgdriver.WithTLSConfig(nil),
-			pgdriver.WithDialTimeout(5*time.Second),
-		))
+		openDB := openPostgres(t)
 		defer openDB.Close()
 
 		bunDB := bun.NewDB(openDB, pgdialect.New())
 		govaultDB, err := govault.New(govault.Config{
-			AdapterName: govault.AdapterNameBun,
-			BunDB:       bunDB,
-			Keys: map[string][]byte{
-				"1": []byte("727d37a0-a5f2-4d67-af47-83039c8e"),
-			},
+			AdapterName:  govault.AdapterNameBun,
+			BunDB:        bunDB,
+			Keys:         govaulttest.EphemeralKeys(1),
 			DefaultKeyID: "1",
 		})
 		require.NoError(t, err)
@@ -171,8 +147,8 @@ func TestBunDBWithKey(t *testing.T) {
 		err := db.NewSelect().Model(&raw).Where("id = ?", user.ID).Scan(ctx, &raw)
 		require.NoError(t, err)
 
-		assert.Contains(t, raw.Email, "|")
-		parts := strings.Split(raw.Email, "|")
+		assert.True(t, strings.HasPrefix(raw.Email, "gv1:"))
+		parts := strings.Split(strings.TrimPrefix(raw.Email, "gv1:"), "|")
 		assert.Equal(t, "2", parts[0])
 	})
 }
@@ -314,7 +290,7 @@ func TestBunEncryptDecryptEdgeCases(t *testing.T) {
 	t.Run("decrypt invalid encrypted data", func(t *testing.T) {
 		user := &TestUser{
 			Name:  "Invalid Decrypt",
-			Email: "invalid|data|here",
+			Email: "gv1:invalid|data|here",
 			Phone: "+62877777777",
 		}
 
@@ -361,6 +337,43 @@ func TestDecryptValueExotic(t *testing.T) {
 	})
 }
 
+func TestBunCiphertextFormat(t *testing.T) {
+	db, govaultDB, cleanup := setupTestDB(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	t.Run("plaintext with pipes is not decrypted", func(t *testing.T) {
+		user := &TestUser{Name: "Pipe User", Email: "a|b|c", Phone: "x|y"}
+		_, err := db.DB.NewInsert().Model(user).Exec(ctx)
+		require.NoError(t, err)
+
+		var retrieved TestUser
+		err = db.NewSelect().Model(&retrieved).Where("id = ?", user.ID).Scan(ctx, &retrieved)
+		require.NoError(t, err)
+		assert.Equal(t, "a|b|c", retrieved.Email)
+		assert.Equal(t, "x|y", retrieved.Phone)
+	})
+
+	t.Run("legacy unprefixed ciphertext is decrypted", func(t *testing.T) {
+		encrypted, err := govaultDB.Encrypt("legacy@example.com", "1")
+		require.NoError(t, err)
+		require.True(t, strings.HasPrefix(encrypted, "gv1:"))
+
+		user := &TestUser{Name: "Legacy User", Email: strings.TrimPrefix(encrypted, "gv1:")}
+		_, err = db.DB.NewInsert().Model(user).Exec(ctx)
+		require.NoError(t, err)
+
+		var retrieved TestUser
+		err = db.NewSelect().Model(&retrieved).Where("id = ?", user.ID).Scan(ctx, &retrieved)
+		require.NoError(t, err)
+		assert.Equal(t, "legacy@example.com", retrieved.Email)
+
+		keyID, err := govaultDB.GetKeyIDFromEncryptedData(user.Email)
+		require.NoError(t, err)
+		assert.Equal(t, "1", keyID)
+	})
+}
+
 func TestBunGeneralDDL(t *testing.T) {
 	db, _, cleanup := setupTestDB(t)
 	defer cleanup()
@@ -548,7 +561,7 @@ func TestBunCoverageWrappers(t *testing.T) {
 		assert.NotNil(t, db.Table(reflect.TypeOf(&TestUser{})))
 
 		// Register/Reset
-		db.RegisterModel((*TestUser)(nil))
+		require.NoError(t, db.RegisterModel((*TestUser)(nil)))
 		err = db.ResetModel(ctx, (*TestUser)(nil))
 		assert.NoError(t, err)
 
@@ -839,3 +852,150 @@ func TestDecryptRecursiveDirectly(t *testing.T) {
 	}
 	assert.Error(t, err)
 }
+
+type TestSharedUser struct {
+	bun.BaseModel `bun:"table:test_shared_users"`
+	ID            int64  `bun:"id,pk,autoincrement"`
+	Email         string `bun:"email" encrypted:"true" codec:"ciphersweet" blindindex:"email_bidx"`
+	EmailBidx     string `bun:"email_bidx"`
+	Phone         string `bun:"phone" encrypted:"true" blindindex:"phone_bidx" blindindexbits:"64"`
+	PhoneBidx     string `bun:"phone_bidx"`
+}
+
+func TestBunCodecAndBlindIndex(t *testing.T) {
+	db, govaultDB, cleanup := setupTestDB(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	_, err := db.NewCreateTable().Model((*TestSharedUser)(nil)).IfNotExists().Exec(ctx)
+	require.NoError(t, err)
+	defer db.NewDropTable().Model((*TestSharedUser)(nil)).IfExists().Exec(ctx)
+
+	user := &TestSharedUser{Email: "shared@example.com", Phone: "+62811112222"}
+	_, err = db.NewInsert().Model(user).Exec(ctx)
+	require.NoError(t, err)
+
+	t.Run("ciphersweet codec writes nacl ciphertexts", func(t *testing.T) {
+		var raw struct {
+			bun.BaseModel `bun:"table:test_shared_users"`
+			Email         string `bun:"email"`
+			Phone         string `bun:"phone"`
+		}
+		err := db.NewSelect().Model(&raw).Where("id = ?", user.ID).Scan(ctx, &raw)
+		require.NoError(t, err)
+		assert.True(t, strings.HasPrefix(raw.Email, "nacl:"))
+		assert.True(t, strings.HasPrefix(raw.Phone, "gv1:"))
+	})
+
+	t.Run("blind indexes support equality lookups", func(t *testing.T) {
+		emailIdx, err := govaultDB.BlindIndex(&TestSharedUser{}, "Email", "shared@example.com")
+		require.NoError(t, err)
+		assert.Len(t, emailIdx, 8)
+
+		phoneIdx, err := govaultDB.BlindIndex(&TestSharedUser{}, "Phone", "+62811112222")
+		require.NoError(t, err)
+		assert.Len(t, phoneIdx, 16)
+
+		var found TestSharedUser
+		err = db.NewSelect().
+			Model(&found).
+			Where("email_bidx = ?", emailIdx).
+			Where("phone_bidx = ?", phoneIdx).
+			Scan(ctx, &found)
+		require.NoError(t, err)
+		assert.Equal(t, "shared@example.com", found.Email)
+		assert.Equal(t, "+62811112222", found.Phone)
+	})
+
+	t.Run("blind index of unknown field", func(t *testing.T) {
+		_, err := govaultDB.BlindIndex(&TestSharedUser{}, "EmailBidx", "x")
+		assert.Error(t, err)
+	})
+}
+
+type TestTinkUser struct {
+	bun.BaseModel `bun:"table:test_tink_users"`
+	ID            int64  `bun:"id,pk,autoincrement"`
+	Email         string `bun:"email" encrypted:"true" codec:"tink"`
+}
+
+func TestBunTinkCodec(t *testing.T) {
+	db, _, cleanup := setupTestDB(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	_, err := db.NewCreateTable().Model((*TestTinkUser)(nil)).IfNotExists().Exec(ctx)
+	require.NoError(t, err)
+	defer db.NewDropTable().Model((*TestTinkUser)(nil)).IfExists().Exec(ctx)
+
+	user := &TestTinkUser{Email: "tink@example.com"}
+	_, err = db.WithKey("2").NewInsert().Model(user).Exec(ctx)
+	require.NoError(t, err)
+
+	var raw struct {
+		bun.BaseModel `bun:"table:test_tink_users"`
+		Email         string `bun:"email"`
+	}
+	err = db.NewSelect().Model(&raw).Where("id = ?", user.ID).Scan(ctx, &raw)
+	require.NoError(t, err)
+
+	data, err := base64.StdEncoding.DecodeString(raw.Email)
+	require.NoError(t, err)
+	assert.Equal(t, []byte{0x01, 0, 0, 0, 2}, data[:5])
+
+	var retrieved TestTinkUser
+	err = db.NewSelect().Model(&retrieved).Where("id = ?", user.ID).Scan(ctx, &retrieved)
+	require.NoError(t, err)
+	assert.Equal(t, "tink@example.com", retrieved.Email)
+}
+
+type TestFieldUser struct {
+	bun.BaseModel `bun:"table:test_field_users"`
+	ID            int64                         `bun:"id,pk,autoincrement"`
+	Email         govault.Field[string]         `bun:"email,type:text"`
+	Tags          govault.Field[[]string]       `bun:"tags,type:text"`
+	Phone         govault.Field[string]         `bun:"phone,type:text"`
+	Extra         govault.Field[map[string]int] `bun:"extra,type:text"`
+}
+
+func TestBunField(t *testing.T) {
+	db, govaultDB, cleanup := setupTestDB(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	govault.SetFieldVault(govaultDB)
+	defer govault.SetFieldVault(nil)
+
+	_, err := db.DB.NewCreateTable().Model((*TestFieldUser)(nil)).IfNotExists().Exec(ctx)
+	require.NoError(t, err)
+	defer db.DB.NewDropTable().Model((*TestFieldUser)(nil)).IfExists().Exec(ctx)
+
+	// Plain bun queries, no wrapper involved
+	user := &TestFieldUser{
+		Email: govault.NewField("field@example.com"),
+		Tags:  govault.NewField([]string{"a", "b"}).WithKey("1"),
+		Extra: govault.NewField(map[string]int{"n": 1}),
+	}
+	_, err = db.DB.NewInsert().Model(user).Exec(ctx)
+	require.NoError(t, err)
+
+	var raw struct {
+		bun.BaseModel `bun:"table:test_field_users"`
+		Email         string  `bun:"email"`
+		Tags          string  `bun:"tags"`
+		Phone         *string `bun:"phone"`
+	}
+	err = db.DB.NewSelect().Model(&raw).Where("id = ?", user.ID).Scan(ctx)
+	require.NoError(t, err)
+	assert.True(t, govault.IsEncrypted(raw.Email))
+	assert.True(t, strings.HasPrefix(raw.Tags, govault.FormatPrefix+"1|"))
+	assert.Nil(t, raw.Phone)
+
+	var retrieved TestFieldUser
+	err = db.DB.NewSelect().Model(&retrieved).Where("id = ?", user.ID).Scan(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "field@example.com", retrieved.Email.V)
+	assert.Equal(t, []string{"a", "b"}, retrieved.Tags.V)
+	assert.Equal(t, map[string]int{"n": 1}, retrieved.Extra.V)
+	assert.False(t, retrieved.Phone.Valid)
+}
@@ -4,6 +4,7 @@ package bun
 import (
 	"context"
 	"database/sql"
+	"time"
 
 	"github.com/muhammadluth/govault/internal"
 	"github.com/uptrace/bun"
@@ -13,18 +14,26 @@ import (
 // BunDeleteQuery wraps bun.DeleteQuery
 type BunDeleteQuery struct {
 	*bun.DeleteQuery
+	conn    bun.IConn
 	govault *internal.GovaultDB
 	keyID   string
+	timeout time.Duration
+	// model is the last model, erased by Erase
+	model any
+	erase *EraseOptions
 }
 
 // Conn sets the database connection
 func (q *BunDeleteQuery) Conn(db bun.IConn) *BunDeleteQuery {
+	q.conn = db
 	q.DeleteQuery.Conn(db)
 	return q
 }
 
 // Model sets the model and encrypts fields
 func (q *BunDeleteQuery) Model(model any) *BunDeleteQuery {
+	bindBinaryFields(q.DB(), q.govault, model)
+	q.model = model
 	q.DeleteQuery.Model(model)
 	return q
 }
@@ -162,32 +171,32 @@ func (q *BunDeleteQuery) AppendQuery(gen schema.QueryGen, b []byte) (_ []byte, e
 
 // Scan executes the query and scans the result
 func (q *BunDeleteQuery) Scan(ctx context.Context, dest ...any) error {
-	err := q.DeleteQuery.Scan(ctx, dest...)
-	if err != nil {
+	ctx, cancel := withTimeout(ctx, q.timeout)
+	defer cancel()
+
+	if err := q.eraseModel(ctx); err != nil {
 		return err
 	}
-	for _, d := range dest {
-		if err := q.govault.DecryptRecursive(d); err != nil {
-			return err
-		}
+	if err := q.DeleteQuery.Scan(ctx, dest...); err != nil {
+		return err
 	}
-	return nil
+	return decryptReturning(ctx, q.govault, internal.OperationDelete, dest)
 }
 
-// Exec executes the delete query
+// Exec executes the delete query. Rows returned into dest, e.g. a slice for
+// a multi-row RETURNING, are decrypted.
 func (q *BunDeleteQuery) Exec(ctx context.Context, dest ...any) (sql.Result, error) {
+	ctx, cancel := withTimeout(ctx, q.timeout)
+	defer cancel()
+
+	if err := q.eraseModel(ctx); err != nil {
+		return nil, err
+	}
 	res, err := q.DeleteQuery.Exec(ctx, dest...)
 	if err != nil {
 		return res, err
 	}
-	if len(dest) > 0 {
-		for _, d := range dest {
-			if err := q.govault.DecryptRecursive(d); err != nil {
-				return res, err
-			}
-		}
-	}
-	return res, nil
+	return res, decryptReturning(ctx, q.govault, internal.OperationDelete, dest)
 }
 
 func (q *BunDeleteQuery) String() string {
@@ -249,3 +258,8 @@ func (q *BunDeleteQuery) WithKey(keyID string) *BunDeleteQuery {
 	q.keyID = keyID
 	return q
 }
+
+// KeyID returns the key of the query, empty for the default key
+func (q *BunDeleteQuery) KeyID() string {
+	return q.keyID
+}
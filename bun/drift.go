@@ -0,0 +1,193 @@
+// Package govault - Bun adapter drift detection
+package bun
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/muhammadluth/govault/internal"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect"
+)
+
+// DriftSampleSize is the number of non-empty rows sampled at random per
+// column
+const DriftSampleSize = 1000
+
+// DriftKind classifies a mismatch found by CheckDrift
+type DriftKind string
+
+const (
+	DriftNotInCatalog          DriftKind = "not_in_catalog"
+	DriftNotTagged             DriftKind = "not_tagged"
+	DriftFormatMismatch        DriftKind = "format_mismatch"
+	DriftDeterministicMismatch DriftKind = "deterministic_mismatch"
+	DriftBlindIndexMismatch    DriftKind = "blind_index_mismatch"
+	DriftPlaintextRows         DriftKind = "plaintext_rows"
+)
+
+// DriftIssue describes one mismatch between struct tags, the catalog and
+// the data actually stored in a column
+type DriftIssue struct {
+	Table   string
+	Column  string
+	Kind    DriftKind
+	Message string
+	// PlaintextRatio is the share of sampled rows that are not ciphertext,
+	// set for DriftPlaintextRows
+	PlaintextRatio float64
+}
+
+func (i DriftIssue) String() string {
+	return fmt.Sprintf("%s.%s: %s", i.Table, i.Column, i.Message)
+}
+
+// CheckDrift compares the tag configuration of models with the catalog and
+// with a sample of stored values. It returns one issue per mismatch; an
+// empty result means tags, catalog and data agree.
+func (db *BunDB) CheckDrift(ctx context.Context, models ...any) ([]DriftIssue, error) {
+	catalog, err := db.Catalog(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	catalogByColumn := make(map[string]CatalogColumn, len(catalog))
+	for _, c := range catalog {
+		catalogByColumn[c.TableName+"."+c.ColumnName] = c
+	}
+
+	var issues []DriftIssue
+	for _, model := range models {
+		spec := db.govault.ModelSpec(model)
+		if spec == nil {
+			return nil, fmt.Errorf("model must be a struct, got %T", model)
+		}
+
+		tagged := make(map[string]bool, len(spec.Fields))
+		for _, field := range spec.Fields {
+			tagged[field.Column] = true
+			issues = append(issues, compareCatalog(spec.Table, field, catalogByColumn)...)
+
+			issue, err := db.samplePlaintext(ctx, spec.Table, field)
+			if err != nil {
+				return nil, err
+			}
+			if issue != nil {
+				issues = append(issues, *issue)
+			}
+		}
+
+		for _, c := range catalog {
+			if c.TableName == spec.Table && !tagged[c.ColumnName] {
+				issues = append(issues, DriftIssue{
+					Table:   c.TableName,
+					Column:  c.ColumnName,
+					Kind:    DriftNotTagged,
+					Message: "catalog says encrypted but struct field is not tagged",
+				})
+			}
+		}
+	}
+
+	return issues, nil
+}
+
+// compareCatalog reports differences between a field's tags and its catalog row
+func compareCatalog(table string, field *internal.FieldSpec, catalog map[string]CatalogColumn) []DriftIssue {
+	entry, ok := catalog[table+"."+field.Column]
+	if !ok {
+		return []DriftIssue{{
+			Table:   table,
+			Column:  field.Column,
+			Kind:    DriftNotInCatalog,
+			Message: "column tagged encrypted but missing from catalog",
+		}}
+	}
+
+	var issues []DriftIssue
+	if entry.FormatVersion != field.Format() {
+		issues = append(issues, DriftIssue{
+			Table:   table,
+			Column:  field.Column,
+			Kind:    DriftFormatMismatch,
+			Message: fmt.Sprintf("catalog says format %s but tag says %s", entry.FormatVersion, field.Format()),
+		})
+	}
+	if entry.Deterministic != field.Deterministic {
+		issues = append(issues, DriftIssue{
+			Table:   table,
+			Column:  field.Column,
+			Kind:    DriftDeterministicMismatch,
+			Message: fmt.Sprintf("catalog says %s but tag says %s", encryptionMode(entry.Deterministic), encryptionMode(field.Deterministic)),
+		})
+	}
+	if entry.BlindIndex != field.BlindIndex {
+		issues = append(issues, DriftIssue{
+			Table:   table,
+			Column:  field.Column,
+			Kind:    DriftBlindIndexMismatch,
+			Message: fmt.Sprintf("catalog says blind index %q but tag says %q", entry.BlindIndex, field.BlindIndex),
+		})
+	}
+	return issues
+}
+
+// samplePlaintext reads up to DriftSampleSize non-empty values of a column,
+// picked at random, and reports how many of them are not ciphertext. The
+// random order reads the whole column but keeps rows written in bulk, e.g.
+// the oldest ones, from skewing the ratio.
+func (db *BunDB) samplePlaintext(ctx context.Context, table string, field *internal.FieldSpec) (*DriftIssue, error) {
+	var values [][]byte
+	err := db.DB.NewSelect().
+		TableExpr("?", bun.Ident(table)).
+		ColumnExpr("?", bun.Ident(field.Column)).
+		Where("? IS NOT NULL", bun.Ident(field.Column)).
+		Where("? <> ''", bun.Ident(field.Column)).
+		OrderExpr(randomOrder(db.DB.Dialect().Name())).
+		Limit(DriftSampleSize).
+		Scan(ctx, &values)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sample %s.%s: %w", table, field.Column, err)
+	}
+	if len(values) == 0 {
+		return nil, nil
+	}
+
+	plaintext := 0
+	for _, stored := range values {
+		v, err := storedText(stored, field.Binary)
+		if err != nil || !internal.IsFieldEncrypted(field, v) {
+			plaintext++
+		}
+	}
+	if plaintext == 0 {
+		return nil, nil
+	}
+
+	ratio := float64(plaintext) / float64(len(values))
+	return &DriftIssue{
+		Table:          table,
+		Column:         field.Column,
+		Kind:           DriftPlaintextRows,
+		Message:        fmt.Sprintf("column tagged encrypted but %.0f%% plaintext rows (sampled %d)", ratio*100, len(values)),
+		PlaintextRatio: ratio,
+	}, nil
+}
+
+func encryptionMode(deterministic bool) string {
+	if deterministic {
+		return "deterministic"
+	}
+	return "randomized"
+}
+
+// randomOrder returns the expression ordering rows at random in name
+func randomOrder(name dialect.Name) string {
+	switch name {
+	case dialect.MySQL:
+		return "RAND()"
+	case dialect.MSSQL:
+		return "NEWID()"
+	}
+	return "random()"
+}
@@ -0,0 +1,68 @@
+// Package govault - Bun adapter duplicate detection on plaintext hashes
+package bun
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/uptrace/bun"
+)
+
+// DuplicateGroup is a set of rows holding the same plaintext in an
+// encrypted column, found by Duplicates
+type DuplicateGroup struct {
+	PlainHash string `json:"plain_hash"`
+	// Keys are the primary keys of the rows, in ascending order
+	Keys []any `json:"keys"`
+}
+
+// Duplicates groups the rows of model whose field, given by its Go name,
+// holds the same plaintext, for dedup jobs. It compares the plaintext hash
+// column of the field, see the plainhash tag, without decrypting; rows
+// without a hash, e.g. written before the tag was added, are left out.
+func (db *BunDB) Duplicates(ctx context.Context, model any, fieldName string) ([]DuplicateGroup, error) {
+	spec := db.govault.ModelSpec(model)
+	if spec == nil {
+		return nil, fmt.Errorf("model must be a struct, got %T", model)
+	}
+	field := spec.Field(fieldName)
+	if field == nil {
+		return nil, fmt.Errorf("field %s is not encrypted", fieldName)
+	}
+	if field.PlainHash == "" {
+		return nil, fmt.Errorf("field %s has no plainhash column", fieldName)
+	}
+	pks := db.DB.Table(spec.Type).PKs
+	if len(pks) != 1 {
+		return nil, fmt.Errorf("duplicate detection requires a single column primary key, %s has %d", spec.Table, len(pks))
+	}
+	pk, hash := pks[0].Name, bun.Ident(field.PlainHash)
+
+	duplicated := db.DB.NewSelect().
+		TableExpr("?", bun.Ident(spec.Table)).
+		ColumnExpr("?", hash).
+		Where("? <> ''", hash).
+		GroupExpr("?", hash).
+		Having("count(*) > 1")
+	var rows []map[string]any
+	err := db.DB.NewSelect().
+		TableExpr("?", bun.Ident(spec.Table)).
+		Column(pk, field.PlainHash).
+		Where("? IN (?)", hash, duplicated).
+		OrderExpr("?, ?", hash, bun.Ident(pk)).
+		Scan(ctx, &rows)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find duplicates in %s.%s: %w", spec.Table, field.Column, err)
+	}
+
+	var groups []DuplicateGroup
+	for _, row := range rows {
+		h := rowKey(row[field.PlainHash])
+		if len(groups) == 0 || groups[len(groups)-1].PlainHash != h {
+			groups = append(groups, DuplicateGroup{PlainHash: h})
+		}
+		group := &groups[len(groups)-1]
+		group.Keys = append(group.Keys, row[pk])
+	}
+	return groups, nil
+}
@@ -0,0 +1,95 @@
+package bun_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uptrace/bun"
+)
+
+type TestHashedUser struct {
+	bun.BaseModel `bun:"table:test_hashed_users"`
+	ID            int64  `bun:"id,pk,autoincrement"`
+	Email         string `bun:"email" encrypted:"true" plainhash:"email_hash"`
+	EmailHash     string `bun:"email_hash"`
+	Name          string `bun:"name"`
+}
+
+func TestBunPlainHash(t *testing.T) {
+	db, _, cleanup := setupTestDB(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	_, err := db.NewCreateTable().Model((*TestHashedUser)(nil)).IfNotExists().Exec(ctx)
+	require.NoError(t, err)
+	defer db.NewDropTable().Model((*TestHashedUser)(nil)).IfExists().Exec(ctx)
+
+	var ids []int64
+	for _, email := range []string{"a@example.com", "b@example.com", "a@example.com", "c@example.com", "b@example.com", "a@example.com"} {
+		user := &TestHashedUser{Email: email}
+		_, err := db.NewInsert().Model(user).Exec(ctx)
+		require.NoError(t, err)
+		ids = append(ids, user.ID)
+	}
+
+	t.Run("duplicates are grouped without decrypting", func(t *testing.T) {
+		groups, err := db.Duplicates(ctx, (*TestHashedUser)(nil), "Email")
+		require.NoError(t, err)
+		require.Len(t, groups, 2)
+
+		byFirst := make(map[any][]any)
+		for _, g := range groups {
+			assert.Len(t, g.PlainHash, 64)
+			var keys []any
+			for _, k := range g.Keys {
+				keys = append(keys, toInt64(k))
+			}
+			byFirst[keys[0]] = keys
+		}
+		assert.Equal(t, []any{ids[0], ids[2], ids[5]}, byFirst[ids[0]])
+		assert.Equal(t, []any{ids[1], ids[4]}, byFirst[ids[1]])
+
+		_, err = db.Duplicates(ctx, (*TestHashedUser)(nil), "Name")
+		assert.ErrorContains(t, err, "not encrypted")
+	})
+
+	t.Run("unique constraints apply to the plaintext", func(t *testing.T) {
+		_, err := db.NewDelete().Model((*TestHashedUser)(nil)).Where("id IN (?)", bun.In([]int64{ids[2], ids[4], ids[5]})).Exec(ctx)
+		require.NoError(t, err)
+		_, err = db.NewRaw("CREATE UNIQUE INDEX test_hashed_users_email_hash ON test_hashed_users (email_hash)").Exec(ctx)
+		require.NoError(t, err)
+
+		_, err = db.NewInsert().Model(&TestHashedUser{Email: "a@example.com"}).Exec(ctx)
+		assert.Error(t, err)
+		_, err = db.NewInsert().Model(&TestHashedUser{Email: "d@example.com"}).Exec(ctx)
+		assert.NoError(t, err)
+	})
+
+	t.Run("unchanged values are detected on the hash", func(t *testing.T) {
+		var email string
+		require.NoError(t, db.DB.NewSelect().Table("test_hashed_users").Column("email").Where("id = ?", ids[0]).Scan(ctx, &email))
+
+		update := &TestHashedUser{ID: ids[0], Email: "a@example.com", Name: "renamed"}
+		_, err := db.NewUpdate().Model(update).WherePK().SkipUnchanged().Exec(ctx)
+		require.NoError(t, err)
+
+		var got string
+		require.NoError(t, db.DB.NewSelect().Table("test_hashed_users").Column("email").Where("id = ?", ids[0]).Scan(ctx, &got))
+		assert.Equal(t, email, got)
+	})
+}
+
+// toInt64 converts a primary key scanned into any
+func toInt64(v any) any {
+	switch v := v.(type) {
+	case int64:
+		return v
+	case int32:
+		return int64(v)
+	case int:
+		return int64(v)
+	}
+	return v
+}
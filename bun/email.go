@@ -0,0 +1,29 @@
+// Package govault - Bun adapter email domain queries
+package bun
+
+import (
+	"fmt"
+
+	"github.com/uptrace/bun"
+)
+
+// WhereEmailDomain filters rows whose encrypted email address in column is
+// at domain, using the domain column set with the emaildomain tag. Domains
+// are matched case-insensitively. The model must be set first.
+func (q *BunSelectQuery) WhereEmailDomain(column, domain string) *BunSelectQuery {
+	_, spec := q.modelSpec()
+	if spec == nil {
+		return q.Err(fmt.Errorf("WhereEmailDomain requires a model with encrypted fields"))
+	}
+	field := fieldOfColumn(spec, column)
+	if field == nil || field.EmailDomain == "" {
+		return q.Err(fmt.Errorf("%s.%s has no email domain", spec.Table, column))
+	}
+
+	value, err := q.govault.EmailDomain(field, spec.Table, domain)
+	if err != nil {
+		return q.Err(err)
+	}
+	q.SelectQuery.Where("?TableAlias.? = ?", bun.Ident(field.EmailDomain), value)
+	return q
+}
@@ -0,0 +1,48 @@
+// Package govault - Bun adapter email domain tests
+package bun_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uptrace/bun"
+)
+
+type TestSubscriber struct {
+	bun.BaseModel `bun:"table:test_subscribers,alias:s"`
+	ID            int64  `bun:"id,pk,autoincrement"`
+	Email         string `bun:"email" encrypted:"true" emaildomain:"email_domain" emaildomainhash:"true"`
+	EmailDomain   string `bun:"email_domain"`
+}
+
+func TestBunWhereEmailDomain(t *testing.T) {
+	db, _, cleanup := setupTestDB(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	_, err := db.NewCreateTable().Model((*TestSubscriber)(nil)).IfNotExists().Exec(ctx)
+	require.NoError(t, err)
+	defer db.NewDropTable().Model((*TestSubscriber)(nil)).IfExists().Exec(ctx)
+
+	for _, email := range []string{"ada@example.com", "bob@other.org", "eve@Example.com"} {
+		_, err := db.NewInsert().Model(&TestSubscriber{Email: email}).Exec(ctx)
+		require.NoError(t, err)
+	}
+
+	var subscribers []TestSubscriber
+	err = db.NewSelect().Model(&subscribers).WhereEmailDomain("email", "EXAMPLE.com").Order("id").Scan(ctx, &subscribers)
+	require.NoError(t, err)
+	require.Len(t, subscribers, 2)
+	assert.Equal(t, "ada@example.com", subscribers[0].Email)
+	assert.Equal(t, "eve@Example.com", subscribers[1].Email)
+
+	var count int
+	err = db.NewSelect().Model((*TestSubscriber)(nil)).ColumnExpr("count(DISTINCT email_domain)").Scan(ctx, &count)
+	require.NoError(t, err)
+	assert.Equal(t, 2, count)
+
+	err = db.NewSelect().Model(&subscribers).WhereEmailDomain("email_domain", "example.com").Scan(ctx, &subscribers)
+	assert.ErrorContains(t, err, "has no email domain")
+}
@@ -0,0 +1,242 @@
+// Package govault - Bun adapter crypto-erasure of deleted rows
+package bun
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"time"
+
+	"github.com/muhammadluth/govault/internal"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/schema"
+)
+
+// EraseOptions configures how the encrypted values of deleted rows are
+// erased, see BunDeleteQuery.Erase and PurgeDeleted
+type EraseOptions struct {
+	Action         SweepAction
+	TombstoneKeyID string // required by SweepTombstone
+}
+
+// PurgeOptions configures PurgeDeleted
+type PurgeOptions struct {
+	EraseOptions
+	// After is the grace period between the soft delete of a row and the
+	// erasure of its values, during which it can be restored
+	After time.Duration
+	// Delete removes the rows once erased
+	Delete    bool
+	BatchSize int // defaults to DefaultRotationBatchSize
+}
+
+// PurgeResult reports the rows purged by PurgeDeleted per model
+type PurgeResult struct {
+	Tables []PurgeTableResult `json:"tables"`
+}
+
+// PurgeTableResult is the outcome of one model
+type PurgeTableResult struct {
+	Table   string `json:"table"`
+	Erased  int64  `json:"erased"`
+	Deleted int64  `json:"deleted"`
+}
+
+// Erase makes Exec and Scan erase the encrypted values of the rows of the
+// model, read by primary key, before deleting them, so that soft-deleted
+// rows keep no recoverable PII. Values are set to NULL or re-encrypted
+// under a tombstone key, and their companion columns are set to NULL, as
+// by SweepExpired. Run the query in a transaction to erase and delete
+// atomically; rows that the query does not delete are erased all the same.
+func (q *BunDeleteQuery) Erase(opts EraseOptions) *BunDeleteQuery {
+	q.erase = &opts
+	return q
+}
+
+// eraseModel erases the rows of the model when Erase was called
+func (q *BunDeleteQuery) eraseModel(ctx context.Context) error {
+	if q.erase == nil {
+		return nil
+	}
+	if err := checkSweepAction(q.govault, q.erase.Action, q.erase.TombstoneKeyID); err != nil {
+		return err
+	}
+	spec := q.govault.ModelSpec(q.model)
+	if spec == nil {
+		return fmt.Errorf("Erase requires a model, got %T", q.model)
+	}
+	pks := q.DB().Table(spec.Type).PKs
+	if len(pks) != 1 {
+		return fmt.Errorf("Erase requires a single column primary key, %s has %d", spec.Table, len(pks))
+	}
+	pk := pks[0]
+
+	structs := modelStructs(q.model)
+	ids := make([]any, 0, len(structs))
+	for _, strct := range structs {
+		ids = append(ids, pk.Value(strct).Interface())
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	columns := eraseColumns(spec)
+	var rows []map[string]any
+	sel := q.DB().NewSelect().
+		Conn(q.conn).
+		TableExpr("?", bun.Ident(spec.Table)).
+		Column(pk.Name).
+		Where("? IN (?)", bun.Ident(pk.Name), bun.In(ids))
+	for _, c := range columns {
+		sel = sel.Column(c.name)
+	}
+	if err := sel.Scan(ctx, &rows); err != nil {
+		return fmt.Errorf("failed to read %s: %w", spec.Table, err)
+	}
+	for _, row := range rows {
+		values := make([]string, len(columns))
+		for i, c := range columns {
+			values[i] = rowKey(row[c.name])
+			if text, err := storedText([]byte(values[i]), c.binary); err == nil {
+				values[i] = text
+			}
+		}
+		set, err := eraseFields(q.govault, spec, values, *q.erase)
+		if err != nil {
+			return fmt.Errorf("row %v: %w", row[pk.Name], err)
+		}
+		if len(set) == 0 {
+			continue
+		}
+		upd := q.DB().NewUpdate().
+			Conn(q.conn).
+			TableExpr("?", bun.Ident(spec.Table)).
+			Where("? = ?", bun.Ident(pk.Name), row[pk.Name])
+		if err := setValues(upd, columns, set); err != nil {
+			return fmt.Errorf("row %v: %w", row[pk.Name], err)
+		}
+		if _, err := upd.Exec(ctx); err != nil {
+			return fmt.Errorf("failed to erase row %v of %s: %w", row[pk.Name], spec.Table, err)
+		}
+	}
+	return nil
+}
+
+// PurgeDeleted erases the encrypted values of the rows of models that were
+// soft deleted, see bun's soft_delete tag, longer than opts.After ago, and
+// deletes them when opts.Delete is set. Rows are processed in batches, each
+// in its own transaction. Run it periodically, e.g. next to SweepExpired,
+// so that logically deleted PII becomes unrecoverable.
+func (db *BunDB) PurgeDeleted(ctx context.Context, models []any, opts PurgeOptions) (*PurgeResult, error) {
+	if err := checkRewriteMode(db.govault); err != nil {
+		return nil, err
+	}
+	if err := checkSweepAction(db.govault, opts.Action, opts.TombstoneKeyID); err != nil {
+		return nil, err
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = DefaultRotationBatchSize
+	}
+
+	cutoff := time.Now().Add(-opts.After)
+	result := &PurgeResult{}
+	for _, model := range models {
+		spec := db.govault.ModelSpec(model)
+		if spec == nil {
+			return nil, fmt.Errorf("model must be a struct, got %T", model)
+		}
+		table := db.DB.Table(spec.Type)
+		if table.SoftDeleteField == nil {
+			return nil, fmt.Errorf("%s has no soft delete column", spec.Table)
+		}
+		if len(table.PKs) != 1 {
+			return nil, fmt.Errorf("%s: purging requires a single column primary key", spec.Table)
+		}
+
+		tableResult, err := db.purgeTable(ctx, spec, table.PKs[0].Name, table.SoftDeleteField.Name, cutoff, opts)
+		if err != nil {
+			return nil, err
+		}
+		result.Tables = append(result.Tables, *tableResult)
+	}
+	return result, nil
+}
+
+// purgeTable purges the rows of a table soft deleted before cutoff
+func (db *BunDB) purgeTable(ctx context.Context, spec *internal.ModelSpec, pk, deletedAt string, cutoff time.Time, opts PurgeOptions) (*PurgeTableResult, error) {
+	result := &PurgeTableResult{Table: spec.Table}
+	deleted := bun.SafeQuery("(? IS NOT NULL AND ? < ?)", bun.Ident(deletedAt), bun.Ident(deletedAt), cutoff)
+
+	rw := &rewrite{
+		table:      spec.Table,
+		primaryKey: pk,
+		columns:    eraseColumns(spec),
+		where:      []schema.QueryWithArgs{deleted},
+		batchSize:  opts.BatchSize,
+		row: func(pk any, values []string) (map[string]any, error) {
+			return eraseFields(db.govault, spec, values, opts.EraseOptions)
+		},
+	}
+	if opts.Delete {
+		rw.afterBatch = func(ctx context.Context, tx bun.Tx, batch rewriteBatch) error {
+			if len(batch.pks) == 0 {
+				return nil
+			}
+			res, err := tx.NewDelete().
+				TableExpr("?", bun.Ident(spec.Table)).
+				Where("? IN (?)", bun.Ident(pk), bun.In(batch.pks)).
+				Where("?", deleted).
+				Exec(ctx)
+			if err != nil {
+				return err
+			}
+			if n, err := res.RowsAffected(); err == nil {
+				result.Deleted += n
+			}
+			return nil
+		}
+	}
+
+	total, err := db.rewriteColumns(ctx, rw)
+	if err != nil {
+		return nil, fmt.Errorf("failed to purge %s: %w", spec.Table, err)
+	}
+	result.Erased = total.rewritten
+	return result, nil
+}
+
+// eraseColumns returns the encrypted columns of spec
+func eraseColumns(spec *internal.ModelSpec) []rewriteColumn {
+	columns := make([]rewriteColumn, 0, len(spec.Fields))
+	for _, field := range spec.Fields {
+		columns = append(columns, rewriteColumn{name: field.Column, binary: field.Binary})
+	}
+	return columns
+}
+
+// eraseFields returns the columns to set to erase the values of the
+// fields of spec, given in the text format in the order of its fields.
+// The result is empty when no value is left to erase.
+func eraseFields(govault *internal.GovaultDB, spec *internal.ModelSpec, values []string, opts EraseOptions) (map[string]any, error) {
+	set := make(map[string]any)
+	for i, field := range spec.Fields {
+		value := values[i]
+		if value == "" {
+			continue
+		}
+		if opts.Action == SweepTombstone {
+			if field.Codec != "" {
+				return nil, fmt.Errorf("%s.%s: tombstones require the native format, codec %s is not keyed", spec.Table, field.Column, field.Codec)
+			}
+			if isOnKey(value, opts.TombstoneKeyID) {
+				continue
+			}
+		}
+		erased, err := eraseValue(govault, field, spec.Table, value, opts.Action, opts.TombstoneKeyID)
+		if err != nil {
+			return nil, fmt.Errorf("column %s: %w", field.Column, err)
+		}
+		maps.Copy(set, erased)
+	}
+	return set, nil
+}
@@ -0,0 +1,117 @@
+// Package govault - Bun adapter crypto-erasure tests
+package bun_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	gb "github.com/muhammadluth/govault/bun"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uptrace/bun"
+)
+
+type TestErasedUser struct {
+	bun.BaseModel `bun:"table:test_erased_users"`
+	ID            int64     `bun:"id,pk,autoincrement"`
+	Email         string    `bun:"email" encrypted:"true" blindindex:"email_bidx"`
+	EmailBidx     string    `bun:"email_bidx"`
+	Phone         string    `bun:"phone" encrypted:"true"`
+	Name          string    `bun:"name"`
+	DeletedAt     time.Time `bun:"deleted_at,soft_delete,nullzero"`
+}
+
+func TestBunEraseDeleted(t *testing.T) {
+	db, govaultDB, cleanup := setupTestDB(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	_, err := db.NewCreateTable().Model((*TestErasedUser)(nil)).IfNotExists().Exec(ctx)
+	require.NoError(t, err)
+	defer db.NewDropTable().Model((*TestErasedUser)(nil)).IfExists().Exec(ctx)
+
+	insert := func(email string) *TestErasedUser {
+		u := &TestErasedUser{Email: email, Phone: "555-0100", Name: email}
+		_, err := db.NewInsert().Model(u).Exec(ctx)
+		require.NoError(t, err)
+		return u
+	}
+	// raw reads the stored values of a row, soft deleted or not
+	raw := func(t *testing.T, id int64) (email, bidx, phone *string, name string) {
+		t.Helper()
+		err := db.DB.NewSelect().
+			Table("test_erased_users").
+			Column("email", "email_bidx", "phone", "name").
+			Where("id = ?", id).
+			Scan(ctx, &email, &bidx, &phone, &name)
+		require.NoError(t, err)
+		return email, bidx, phone, name
+	}
+
+	t.Run("soft deletes erase the model values", func(t *testing.T) {
+		u := insert("erase@example.com")
+		_, err := db.NewDelete().Model(u).WherePK().Erase(gb.EraseOptions{Action: gb.SweepNull}).Exec(ctx)
+		require.NoError(t, err)
+
+		email, bidx, phone, name := raw(t, u.ID)
+		assert.Nil(t, email)
+		assert.Nil(t, bidx)
+		assert.Nil(t, phone)
+		assert.Equal(t, "erase@example.com", name)
+
+		count, err := db.NewSelect().Model((*TestErasedUser)(nil)).Where("id = ?", u.ID).Count(ctx)
+		require.NoError(t, err)
+		assert.Zero(t, count, "the row is soft deleted")
+	})
+
+	t.Run("deletes without Erase keep the values", func(t *testing.T) {
+		u := insert("keep@example.com")
+		_, err := db.NewDelete().Model(u).WherePK().Exec(ctx)
+		require.NoError(t, err)
+
+		email, _, _, _ := raw(t, u.ID)
+		require.NotNil(t, email)
+		assert.NotEmpty(t, *email)
+	})
+
+	t.Run("purges tombstone rows deleted before the grace period", func(t *testing.T) {
+		_, err := db.NewDelete().Model((*TestErasedUser)(nil)).Where("1 = 1").ForceDelete().Exec(ctx)
+		require.NoError(t, err)
+		old, recent, live := insert("old@example.com"), insert("recent@example.com"), insert("live@example.com")
+		_, err = db.NewDelete().Model(old).WherePK().Exec(ctx)
+		require.NoError(t, err)
+		_, err = db.NewDelete().Model(recent).WherePK().Exec(ctx)
+		require.NoError(t, err)
+		_, err = db.DB.NewUpdate().Table("test_erased_users").Set("deleted_at = ?", time.Now().Add(-48*time.Hour)).Where("id = ?", old.ID).Exec(ctx)
+		require.NoError(t, err)
+
+		opts := gb.PurgeOptions{EraseOptions: gb.EraseOptions{Action: gb.SweepTombstone, TombstoneKeyID: "1"}, After: 24 * time.Hour}
+		result, err := db.PurgeDeleted(ctx, []any{(*TestErasedUser)(nil)}, opts)
+		require.NoError(t, err)
+		require.Len(t, result.Tables, 1)
+		assert.EqualValues(t, 1, result.Tables[0].Erased)
+		assert.Zero(t, result.Tables[0].Deleted)
+
+		email, bidx, _, _ := raw(t, old.ID)
+		require.NotNil(t, email)
+		keyID, err := govaultDB.GetKeyIDFromEncryptedData(*email)
+		require.NoError(t, err)
+		assert.Equal(t, "1", keyID)
+		assert.Nil(t, bidx)
+		for _, id := range []int64{recent.ID, live.ID} {
+			_, bidx, _, _ := raw(t, id)
+			assert.NotNil(t, bidx)
+		}
+
+		// Tombstoned rows are not erased again, and are deleted on request
+		opts.Delete = true
+		result, err = db.PurgeDeleted(ctx, []any{(*TestErasedUser)(nil)}, opts)
+		require.NoError(t, err)
+		assert.Zero(t, result.Tables[0].Erased)
+		assert.EqualValues(t, 1, result.Tables[0].Deleted)
+
+		_, err = db.PurgeDeleted(ctx, []any{(*TestSweepUser)(nil)}, opts)
+		assert.ErrorContains(t, err, "soft delete")
+	})
+}
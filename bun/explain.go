@@ -0,0 +1,267 @@
+// Package govault - Bun adapter query explanation
+package bun
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/muhammadluth/govault/internal"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/schema"
+)
+
+// ExplainAction is what a query does to the value of an encrypted column
+type ExplainAction string
+
+const (
+	// ExplainEncrypted is a written ciphertext
+	ExplainEncrypted ExplainAction = "encrypted"
+	// ExplainPlaintext is a written value that is not a ciphertext of the
+	// field, stored as plaintext
+	ExplainPlaintext ExplainAction = "plaintext"
+	// ExplainEmpty is a written empty string, stored as is unless
+	// Config.EncryptEmptyStrings is set
+	ExplainEmpty ExplainAction = "empty"
+	// ExplainDecrypted is a selected column decrypted on scan
+	ExplainDecrypted ExplainAction = "decrypted"
+)
+
+// Explanation annotates the statement of a wrapped query with what
+// govault does to its encrypted columns, see BunSelectQuery.Explain. The
+// query is not run.
+type Explanation struct {
+	Query   string            `json:"query"`
+	Columns []ExplainedColumn `json:"columns"`
+}
+
+// ExplainedColumn is one encrypted column of an explained query
+type ExplainedColumn struct {
+	Table  string        `json:"table"`
+	Column string        `json:"column"`
+	Field  string        `json:"field"`
+	Row    int           `json:"row"` // row of a multi-row insert, from 0
+	Action ExplainAction `json:"action"`
+	// KeyID is the key of a written ciphertext, or the key the query
+	// encrypts with. Decrypted values are read with the key in their header.
+	KeyID         string             `json:"key_id,omitempty"`
+	Format        string             `json:"format"`
+	Algorithm     internal.Algorithm `json:"algorithm,omitempty"`
+	Deterministic bool               `json:"deterministic,omitempty"`
+	// BlindIndex is the column grouped by instead in a select with
+	// UseBlindIndexes
+	BlindIndex string `json:"blind_index,omitempty"`
+}
+
+// String returns the query followed by one SQL comment per column
+func (e *Explanation) String() string {
+	var b strings.Builder
+	b.WriteString(e.Query)
+	for _, c := range e.Columns {
+		fmt.Fprintf(&b, "\n-- %s.%s (%s): %s", c.Table, c.Column, c.Field, c.Action)
+		if c.KeyID != "" {
+			fmt.Fprintf(&b, ", key %s", c.KeyID)
+		}
+		fmt.Fprintf(&b, ", %s", c.Format)
+		if c.Algorithm != "" {
+			fmt.Fprintf(&b, " %s", c.Algorithm)
+		}
+		if c.Deterministic {
+			b.WriteString(", deterministic")
+		}
+		if c.BlindIndex != "" {
+			fmt.Fprintf(&b, ", grouped by blind index %s", c.BlindIndex)
+		}
+		if c.Row > 0 {
+			fmt.Fprintf(&b, ", row %d", c.Row)
+		}
+	}
+	return b.String()
+}
+
+// explainedColumn returns the column of field with its format
+func explainedColumn(table string, field *internal.FieldSpec, action ExplainAction) ExplainedColumn {
+	c := ExplainedColumn{
+		Table:         table,
+		Column:        field.Column,
+		Field:         field.Name,
+		Action:        action,
+		Format:        field.Format(),
+		Deterministic: field.Deterministic,
+	}
+	if field.Codec == "" {
+		c.Algorithm = field.Algorithm
+		if c.Algorithm == "" {
+			c.Algorithm = internal.AlgorithmAESGCM
+		}
+	}
+	return c
+}
+
+// Explain returns the statement of the query, with the rewriting of
+// UseBlindIndexes, and the encrypted columns decrypted on scan
+func (q *BunSelectQuery) Explain() (*Explanation, error) {
+	query, err := q.SelectQuery.AppendQuery(q.DB().QueryGen(), nil)
+	if err != nil {
+		return nil, err
+	}
+	e := &Explanation{Query: string(query)}
+	table, spec := q.modelSpec()
+	if spec == nil {
+		return e, nil
+	}
+
+	tokens := tokenizeSQL(e.Query)
+	selected := selectColumns(tokens)
+	if q.blindIndexes {
+		e.Query = rewriteBlindIndexes(e.Query, spec, table.Alias, table.Name)
+	}
+	rewritten := identifiers(tokenizeSQL(e.Query))
+	original := identifiers(tokens)
+	for _, field := range spec.Fields {
+		if !selected["*"] && !selected[field.Column] {
+			continue
+		}
+		c := explainedColumn(spec.Table, field, ExplainDecrypted)
+		if field.BlindIndex != "" && rewritten[field.BlindIndex] && !original[field.BlindIndex] {
+			c.BlindIndex = field.BlindIndex
+		}
+		e.Columns = append(e.Columns, c)
+	}
+	return e, nil
+}
+
+// Explain returns the statement of the query and, per encrypted column
+// written with a literal, whether the value is a ciphertext and its key.
+// Models are encrypted by Model, so values that stayed plaintext show
+// here, e.g. ones set with Value or Set.
+func (q *BunInsertQuery) Explain() (*Explanation, error) {
+	return explainWrites(q.InsertQuery.AppendQuery, q.DB().QueryGen(), q.GetModel(), q.govault, q.keyID)
+}
+
+// Explain returns the statement of the query and, per encrypted column
+// written with a literal, whether the value is a ciphertext and its key.
+// Unchanged values dropped by SkipUnchanged are only known when it runs.
+func (q *BunUpdateQuery) Explain() (*Explanation, error) {
+	return explainWrites(q.UpdateQuery.AppendQuery, q.DB().QueryGen(), q.GetModel(), q.govault, q.keyID)
+}
+
+// explainWrites explains the literal writes of an INSERT or UPDATE
+func explainWrites(appendQuery func(schema.QueryGen, []byte) ([]byte, error), gen schema.QueryGen, model bun.Model, govault *internal.GovaultDB, keyID string) (*Explanation, error) {
+	query, err := appendQuery(gen, nil)
+	if err != nil {
+		return nil, err
+	}
+	e := &Explanation{Query: string(query)}
+	_, spec := modelTableSpec(govault, model)
+	if spec == nil {
+		return e, nil
+	}
+	columns := make(map[string]*internal.FieldSpec, len(spec.Fields))
+	for _, field := range spec.Fields {
+		columns[field.Column] = field
+	}
+
+	tokens := tokenizeSQL(e.Query)
+	if len(tokens) == 0 {
+		return e, nil
+	}
+	var writes []columnWrite
+	switch strings.ToUpper(tokens[0].text) {
+	case "INSERT":
+		_, writes = parseInsert(tokens)
+	case "UPDATE":
+		_, writes = parseUpdate(tokens)
+	}
+
+	if keyID == "" {
+		keyID = govault.GetDefaultKeyID()
+	}
+	rows := make(map[string]int)
+	for _, w := range writes {
+		field, ok := columns[w.column]
+		if !ok {
+			continue
+		}
+		value := w.value
+		if field.Binary {
+			if decoded, err := decodeBinaryLiteral(value); err == nil {
+				value = decoded
+			}
+		}
+
+		var c ExplainedColumn
+		switch {
+		case value == "":
+			c = explainedColumn(spec.Table, field, ExplainEmpty)
+		case internal.IsFieldEncrypted(field, value):
+			c = explainedColumn(spec.Table, field, ExplainEncrypted)
+			if field.Codec == "" {
+				c.KeyID, _ = govault.GetKeyIDFromEncryptedData(value)
+			}
+		default:
+			c = explainedColumn(spec.Table, field, ExplainPlaintext)
+		}
+		if c.KeyID == "" && field.Codec == "" {
+			c.KeyID = keyID
+		}
+		c.Row = rows[w.column]
+		rows[w.column]++
+		e.Columns = append(e.Columns, c)
+	}
+	return e, nil
+}
+
+// selectColumns returns the columns named in the top level select list of
+// a formatted SELECT, with "*" for a star
+func selectColumns(tokens []sqlToken) map[string]bool {
+	selected := make(map[string]bool)
+	var clause string
+	depth := 0
+	for i := 0; i < len(tokens); i++ {
+		t := tokens[i]
+		switch {
+		case t.text == "(" && i+1 < len(tokens) && isKeyword(tokens[i+1], "SELECT"):
+			i = skipParens(tokens, i)
+			continue
+		case t.text == "(":
+			depth++
+			continue
+		case t.text == ")":
+			depth--
+			continue
+		}
+		if depth == 0 && isKeyword(t, "SELECT", "FROM", "WHERE", "GROUP", "ORDER", "LIMIT", "HAVING") {
+			clause = strings.ToUpper(t.text)
+			continue
+		}
+		switch {
+		case clause == "SELECT" && t.kind == tokIdent:
+			selected[t.text] = true
+		case clause == "SELECT" && t.text == "*" && depth == 0:
+			selected["*"] = true
+		}
+	}
+	return selected
+}
+
+// identifiers returns the quoted identifiers of tokens
+func identifiers(tokens []sqlToken) map[string]bool {
+	idents := make(map[string]bool)
+	for _, t := range tokens {
+		if t.kind == tokIdent {
+			idents[t.text] = true
+		}
+	}
+	return idents
+}
+
+// decodeBinaryLiteral returns the text format of a binary column literal
+// in the \x hex form of PostgreSQL
+func decodeBinaryLiteral(literal string) (string, error) {
+	data, err := hex.DecodeString(strings.TrimPrefix(literal, `\x`))
+	if err != nil {
+		return "", err
+	}
+	return internal.DecodeBinary(data)
+}
@@ -0,0 +1,64 @@
+// Package govault - Bun adapter query explanation tests
+package bun_test
+
+import (
+	"testing"
+
+	"github.com/muhammadluth/govault"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	gb "github.com/muhammadluth/govault/bun"
+)
+
+func TestBunExplain(t *testing.T) {
+	db, _, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	t.Run("insert", func(t *testing.T) {
+		users := []TestUser{{Name: "a", Email: "a@example.com"}, {Name: "b", Email: "b@example.com", Phone: "555"}}
+		e, err := db.NewInsert().WithKey("1").Model(&users).Explain()
+		require.NoError(t, err)
+		assert.Contains(t, e.Query, `INSERT INTO "test_users"`)
+
+		actions := make(map[string][]gb.ExplainAction)
+		for _, c := range e.Columns {
+			actions[c.Column] = append(actions[c.Column], c.Action)
+			assert.Equal(t, "1", c.KeyID)
+			assert.Equal(t, "gv1", c.Format)
+			assert.Equal(t, govault.AlgorithmAESGCM, c.Algorithm)
+		}
+		assert.Equal(t, []gb.ExplainAction{gb.ExplainEncrypted, gb.ExplainEncrypted}, actions["email"])
+		assert.Equal(t, []gb.ExplainAction{gb.ExplainEmpty, gb.ExplainEncrypted}, actions["phone"])
+		assert.Contains(t, e.String(), "-- test_users.phone (Phone): encrypted, key 1, gv1 aes-gcm, row 1")
+	})
+
+	t.Run("update with a plaintext value", func(t *testing.T) {
+		e, err := db.NewUpdate().Model((*TestUser)(nil)).Set("email = ?", "leak@example.com").Where("id = 1").Explain()
+		require.NoError(t, err)
+		require.Len(t, e.Columns, 1)
+		assert.Equal(t, "email", e.Columns[0].Column)
+		assert.Equal(t, gb.ExplainPlaintext, e.Columns[0].Action)
+		assert.Equal(t, "3", e.Columns[0].KeyID, "the key the query encrypts with")
+	})
+
+	t.Run("select", func(t *testing.T) {
+		e, err := db.NewSelect().Model((*TestUser)(nil)).Column("id", "email").Explain()
+		require.NoError(t, err)
+		require.Len(t, e.Columns, 1)
+		assert.Equal(t, "email", e.Columns[0].Column)
+		assert.Equal(t, gb.ExplainDecrypted, e.Columns[0].Action)
+
+		e, err = db.NewSelect().Model((*TestUser)(nil)).Explain()
+		require.NoError(t, err)
+		assert.Len(t, e.Columns, 2)
+	})
+
+	t.Run("select with blind indexes", func(t *testing.T) {
+		e, err := db.NewSelect().Model((*TestCatalogUser)(nil)).Column("phone").ColumnExpr("count(*)").Group("phone").UseBlindIndexes().Explain()
+		require.NoError(t, err)
+		assert.Contains(t, e.Query, `"phone_bidx"`)
+		require.Len(t, e.Columns, 1)
+		assert.Equal(t, "phone_bidx", e.Columns[0].BlindIndex)
+	})
+}
@@ -0,0 +1,47 @@
+// Package govault - Bun adapter word search on token indexes
+package bun
+
+import (
+	"fmt"
+
+	"github.com/muhammadluth/govault/internal"
+	"github.com/uptrace/bun"
+)
+
+// WhereToken filters rows whose encrypted column contains every word of
+// words, using the token index of the column set with the tokenindex tag.
+// Words are whole and case-insensitive, see govault.Tokenize. The model
+// must be set first.
+func (q *BunSelectQuery) WhereToken(column, words string) *BunSelectQuery {
+	_, spec := q.modelSpec()
+	if spec == nil {
+		return q.Err(fmt.Errorf("WhereToken requires a model with encrypted fields"))
+	}
+	field := fieldOfColumn(spec, column)
+	if field == nil || field.TokenIndex == "" {
+		return q.Err(fmt.Errorf("%s.%s has no token index", spec.Table, column))
+	}
+
+	tokens := internal.Tokenize(words)
+	if len(tokens) == 0 {
+		return q.Err(fmt.Errorf("no words to search for in %q", words))
+	}
+	for _, token := range tokens {
+		hash, err := q.govault.TokenHash(field, spec.Table, token)
+		if err != nil {
+			return q.Err(err)
+		}
+		q.SelectQuery.Where("?TableAlias.? LIKE ?", bun.Ident(field.TokenIndex), "% "+hash+" %")
+	}
+	return q
+}
+
+// fieldOfColumn returns the encrypted field stored in column, if any
+func fieldOfColumn(spec *internal.ModelSpec, column string) *internal.FieldSpec {
+	for _, field := range spec.Fields {
+		if field.Column == column {
+			return field
+		}
+	}
+	return nil
+}
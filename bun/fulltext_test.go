@@ -0,0 +1,62 @@
+// Package govault - Bun adapter token index search tests
+package bun_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uptrace/bun"
+)
+
+type TestNote struct {
+	bun.BaseModel `bun:"table:test_notes,alias:n"`
+	ID            int64  `bun:"id,pk,autoincrement"`
+	Body          string `bun:"body" encrypted:"true" tokenindex:"body_tokens"`
+	BodyTokens    string `bun:"body_tokens"`
+}
+
+func TestBunWhereToken(t *testing.T) {
+	db, _, cleanup := setupTestDB(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	_, err := db.NewCreateTable().Model((*TestNote)(nil)).IfNotExists().Exec(ctx)
+	require.NoError(t, err)
+	defer db.NewDropTable().Model((*TestNote)(nil)).IfExists().Exec(ctx)
+
+	for _, body := range []string{"Call Bob about the invoice", "Invoice #42 is paid", "Lunch with Alice"} {
+		_, err := db.NewInsert().Model(&TestNote{Body: body}).Exec(ctx)
+		require.NoError(t, err)
+	}
+
+	search := func(words string) []string {
+		var notes []TestNote
+		err := db.NewSelect().Model(&notes).WhereToken("body", words).Order("id").Scan(ctx, &notes)
+		require.NoError(t, err)
+		bodies := []string{}
+		for _, note := range notes {
+			bodies = append(bodies, note.Body)
+		}
+		return bodies
+	}
+
+	assert.Equal(t, []string{"Call Bob about the invoice", "Invoice #42 is paid"}, search("INVOICE"))
+	assert.Equal(t, []string{"Invoice #42 is paid"}, search("invoice 42"))
+	assert.Equal(t, []string{"Lunch with Alice"}, search("alice"))
+	assert.Empty(t, search("ali"), "words match whole")
+
+	// Updates refresh the token set
+	note := &TestNote{ID: 3, Body: "Dinner with Alice"}
+	_, err = db.NewUpdate().Model(note).WherePK().Exec(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Dinner with Alice"}, search("dinner"))
+	assert.Empty(t, search("lunch"))
+
+	var notes []TestNote
+	err = db.NewSelect().Model(&notes).WhereToken("body_tokens", "x").Scan(ctx, &notes)
+	assert.ErrorContains(t, err, "test_notes.body_tokens has no token index")
+	err = db.NewSelect().Model(&notes).WhereToken("body", "?!").Scan(ctx, &notes)
+	assert.ErrorContains(t, err, "no words to search for")
+}
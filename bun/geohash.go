@@ -0,0 +1,32 @@
+// Package govault - Bun adapter proximity queries on geohash cells
+package bun
+
+import (
+	"fmt"
+
+	"github.com/uptrace/bun"
+)
+
+// WhereNear filters rows whose encrypted location in column may be near
+// the point lat, lon, using the geohash column set with the geohash tag:
+// rows in the cell of the point or in the eight cells around it. Rows
+// nearer than one cell always match, rows up to two cells away may, so
+// compute the distances of the decrypted locations when they matter. The
+// model must be set first.
+func (q *BunSelectQuery) WhereNear(column string, lat, lon float64) *BunSelectQuery {
+	_, spec := q.modelSpec()
+	if spec == nil {
+		return q.Err(fmt.Errorf("WhereNear requires a model with encrypted fields"))
+	}
+	field := fieldOfColumn(spec, column)
+	if field == nil || field.Geohash == "" {
+		return q.Err(fmt.Errorf("%s.%s has no geohash", spec.Table, column))
+	}
+
+	cells, err := q.govault.GeohashNear(field, spec.Table, lat, lon)
+	if err != nil {
+		return q.Err(err)
+	}
+	q.SelectQuery.Where("?TableAlias.? IN (?)", bun.Ident(field.Geohash), bun.In(cells))
+	return q
+}
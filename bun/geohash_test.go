@@ -0,0 +1,51 @@
+// Package govault - Bun adapter geohash proximity tests
+package bun_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/muhammadluth/govault"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uptrace/bun"
+)
+
+type TestShop struct {
+	bun.BaseModel `bun:"table:test_shops,alias:s"`
+	ID            int64  `bun:"id,pk,autoincrement"`
+	Name          string `bun:"name"`
+	Location      string `bun:"location" encrypted:"true" geohash:"location_cell" geohashprecision:"5"`
+	LocationCell  string `bun:"location_cell"`
+}
+
+func TestBunWhereNear(t *testing.T) {
+	db, _, cleanup := setupTestDB(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	_, err := db.NewCreateTable().Model((*TestShop)(nil)).IfNotExists().Exec(ctx)
+	require.NoError(t, err)
+	defer db.NewDropTable().Model((*TestShop)(nil)).IfExists().Exec(ctx)
+
+	shops := []*TestShop{
+		{Name: "center", Location: govault.FormatLatLon(57.64911, 10.40744)},
+		{Name: "north", Location: govault.FormatLatLon(57.7, 10.40744)},
+		{Name: "far", Location: govault.FormatLatLon(-6.2, 106.8)},
+	}
+	for _, shop := range shops {
+		_, err := db.NewInsert().Model(shop).Exec(ctx)
+		require.NoError(t, err)
+	}
+
+	var near []TestShop
+	err = db.NewSelect().Model(&near).WhereNear("location", 57.65, 10.41).Order("id").Scan(ctx, &near)
+	require.NoError(t, err)
+	require.Len(t, near, 2)
+	assert.Equal(t, "center", near[0].Name)
+	assert.Equal(t, "57.64911,10.40744", near[0].Location)
+	assert.Equal(t, "north", near[1].Name)
+
+	err = db.NewSelect().Model(&near).WhereNear("name", 0, 0).Scan(ctx, &near)
+	assert.ErrorContains(t, err, "has no geohash")
+}
@@ -0,0 +1,590 @@
+// Package govault - Bun adapter plaintext write guard
+package bun
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/muhammadluth/govault/internal"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect"
+)
+
+// PlaintextWriteError reports a write of a non-ciphertext value to an
+// encrypted column
+type PlaintextWriteError struct {
+	Table  string
+	Column string
+	Query  string
+}
+
+func (e *PlaintextWriteError) Error() string {
+	return fmt.Sprintf("plaintext write to encrypted column %s.%s", e.Table, e.Column)
+}
+
+// PlaintextGuard is a bun.QueryHook that inspects outgoing INSERT and
+// UPDATE statements and catches string literals written to encrypted
+// columns without being encrypted, e.g. from code that bypasses the wrapper
+// queries. Expressions and bulk updates from VALUES lists are not checked.
+//
+// bun hooks cannot return errors, so a blocked statement is aborted by
+// cancelling its context. The query fails with an error wrapping both the
+// *PlaintextWriteError and context.Canceled. Use OnViolation to log the
+// offending column.
+type PlaintextGuard struct {
+	// LogOnly reports violations to OnViolation without blocking the query
+	LogOnly bool
+	// OnViolation is called for every violation before the query is blocked
+	OnViolation func(ctx context.Context, err *PlaintextWriteError)
+
+	columns map[string]map[string]*internal.FieldSpec
+}
+
+var _ bun.QueryHook = (*PlaintextGuard)(nil)
+
+// NewPlaintextGuard returns a guard for the encrypted columns of models
+func NewPlaintextGuard(models ...any) *PlaintextGuard {
+	g := &PlaintextGuard{columns: make(map[string]map[string]*internal.FieldSpec)}
+	for _, model := range models {
+		spec := internal.GetModelSpec(model)
+		if spec == nil {
+			continue
+		}
+		for _, field := range spec.Fields {
+			if g.columns[spec.Table] == nil {
+				g.columns[spec.Table] = make(map[string]*internal.FieldSpec)
+			}
+			g.columns[spec.Table][field.Column] = field
+		}
+	}
+	return g
+}
+
+// BeforeQuery implements bun.QueryHook
+func (g *PlaintextGuard) BeforeQuery(ctx context.Context, event *bun.QueryEvent) context.Context {
+	err := g.Check(event.Query)
+	if err == nil {
+		return ctx
+	}
+
+	if g.OnViolation != nil {
+		g.OnViolation(ctx, err)
+	}
+	if g.LogOnly {
+		return ctx
+	}
+
+	ctx, cancel := context.WithCancelCause(ctx)
+	cancel(err)
+	return blockedContext{Context: ctx, err: fmt.Errorf("%w: %w", err, context.Canceled)}
+}
+
+// blockedContext is the cancelled context of a blocked statement. database/sql
+// fails queries with the Err of their context, which is the violation here
+// rather than the bare context.Canceled.
+type blockedContext struct {
+	context.Context
+	err error
+}
+
+func (c blockedContext) Err() error { return c.err }
+
+// AfterQuery implements bun.QueryHook
+func (g *PlaintextGuard) AfterQuery(context.Context, *bun.QueryEvent) {}
+
+// Check returns the first plaintext write to an encrypted column in query
+func (g *PlaintextGuard) Check(query string) *PlaintextWriteError {
+	tokens := tokenizeSQL(query)
+	if len(tokens) == 0 {
+		return nil
+	}
+
+	var table string
+	var writes []columnWrite
+	switch strings.ToUpper(tokens[0].text) {
+	case "INSERT":
+		table, writes = parseInsert(tokens)
+	case "UPDATE":
+		table, writes = parseUpdate(tokens)
+	default:
+		return nil
+	}
+
+	columns := g.columns[table]
+	if columns == nil {
+		return nil
+	}
+
+	for _, w := range writes {
+		field, ok := columns[w.column]
+		if !ok || w.value == "" || internal.IsFieldEncrypted(field, writtenText(field, w.value)) {
+			continue
+		}
+		return &PlaintextWriteError{Table: table, Column: w.column, Query: query}
+	}
+	return nil
+}
+
+// writtenText returns the text format of a literal written to the column
+// of field: bytea hex literals of binary columns are decoded, see
+// EncodeBinary
+func writtenText(field *internal.FieldSpec, value string) string {
+	if !field.Binary || !strings.HasPrefix(value, `\x`) {
+		return value
+	}
+	data, err := hex.DecodeString(value[2:])
+	if err != nil {
+		return value
+	}
+	text, err := internal.DecodeBinary(data)
+	if err != nil {
+		return value
+	}
+	return text
+}
+
+// PlaintextGuardTriggers returns PostgreSQL statements creating a trigger
+// per model table that rejects writes of values without the ciphertext
+// prefix. Binary columns are checked against the header of EncodeBinary.
+// Columns using the tink codec have no textual prefix and are not checked.
+func PlaintextGuardTriggers(models ...any) []string {
+	var stmts []string
+	for _, model := range models {
+		spec := internal.GetModelSpec(model)
+		if spec == nil {
+			continue
+		}
+
+		var checks []string
+		for _, field := range spec.Fields {
+			prefixes := ciphertextPrefixes(field)
+			if len(prefixes) == 0 {
+				continue
+			}
+			col := quoteIdent(field.Column)
+			var mismatches []string
+			if field.Binary {
+				for _, prefix := range binaryCiphertextPrefixes(field, prefixes) {
+					mismatches = append(mismatches, fmt.Sprintf("substring(NEW.%s from 1 for %d) <> '\\x%x'::bytea", col, len(prefix), prefix))
+				}
+			} else {
+				for _, prefix := range prefixes {
+					mismatches = append(mismatches, fmt.Sprintf("left(NEW.%s, %d) <> '%s'", col, len(prefix), prefix))
+				}
+			}
+			checks = append(checks, fmt.Sprintf(
+				"\tIF NEW.%s IS NOT NULL AND NEW.%s <> '' AND %s THEN\n"+
+					"\t\tRAISE EXCEPTION 'govault: plaintext write to encrypted column %%.%%', TG_TABLE_NAME, '%s';\n"+
+					"\tEND IF;\n",
+				col, col, strings.Join(mismatches, " AND "), field.Column))
+		}
+		if len(checks) == 0 {
+			continue
+		}
+
+		fn := quoteIdent("govault_guard_" + spec.Table)
+		trigger := quoteIdent("govault_guard")
+		stmts = append(stmts,
+			fmt.Sprintf("CREATE OR REPLACE FUNCTION %s() RETURNS trigger AS $$\nBEGIN\n%s\tRETURN NEW;\nEND\n$$ LANGUAGE plpgsql", fn, strings.Join(checks, "")),
+			fmt.Sprintf("DROP TRIGGER IF EXISTS %s ON %s", trigger, quoteIdent(spec.Table)),
+			fmt.Sprintf("CREATE TRIGGER %s BEFORE INSERT OR UPDATE ON %s FOR EACH ROW EXECUTE FUNCTION %s()", trigger, quoteIdent(spec.Table), fn),
+		)
+	}
+	return stmts
+}
+
+// InstallPlaintextGuard creates the triggers of PlaintextGuardTriggers. It
+// requires PostgreSQL.
+func (db *BunDB) InstallPlaintextGuard(ctx context.Context, models ...any) error {
+	if name := db.DB.Dialect().Name(); name != dialect.PG {
+		return fmt.Errorf("plaintext guard triggers require PostgreSQL, got %s", name)
+	}
+	for _, stmt := range PlaintextGuardTriggers(models...) {
+		if _, err := db.DB.ExecContext(ctx, stmt); err != nil {
+			return fmt.Errorf("failed to install plaintext guard: %w", err)
+		}
+	}
+	return nil
+}
+
+// ciphertextPrefixes returns the prefixes a ciphertext of field may start
+// with
+func ciphertextPrefixes(field *internal.FieldSpec) []string {
+	switch field.Codec {
+	case "":
+		return []string{internal.FormatPrefix, internal.HPKEFormatPrefix}
+	case internal.CodecCipherSweet:
+		return []string{"nacl:"}
+	}
+	return nil
+}
+
+// binaryCiphertextPrefixes returns the prefixes a ciphertext of field may
+// start with in a binary column: the header of EncodeBinary for the native
+// format, and the text prefixes of the formats it stores unchanged
+func binaryCiphertextPrefixes(field *internal.FieldSpec, prefixes []string) [][]byte {
+	var binary [][]byte
+	if field.Codec == "" {
+		header, _ := internal.BinaryKeyPrefix("")
+		binary = append(binary, header)
+	}
+	for _, prefix := range prefixes {
+		binary = append(binary, []byte(prefix))
+	}
+	return binary
+}
+
+func quoteIdent(s string) string {
+	return `"` + strings.ReplaceAll(s, `"`, `""`) + `"`
+}
+
+// columnWrite is a column assigned a literal string value
+type columnWrite struct {
+	column string
+	value  string
+}
+
+type sqlTokenKind int
+
+const (
+	tokWord sqlTokenKind = iota
+	tokIdent
+	tokString
+	tokPunct
+)
+
+type sqlToken struct {
+	kind sqlTokenKind
+	text string
+	// start and end are the byte offsets of the token in the statement
+	start, end int
+}
+
+// tokenizeSQL splits a formatted statement into words, quoted identifiers,
+// string literals and punctuation
+func tokenizeSQL(query string) []sqlToken {
+	var tokens []sqlToken
+	for i := 0; i < len(query); {
+		c := query[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '"' || c == '\'':
+			text, n := readQuoted(query[i:], c)
+			kind := tokIdent
+			if c == '\'' {
+				kind = tokString
+			}
+			tokens = append(tokens, sqlToken{kind, text, i, i + n})
+			i += n
+		case c == '$' && dollarTag(query[i:]) != "":
+			tag := dollarTag(query[i:])
+			text := query[i+len(tag):]
+			n := len(query) - i
+			if end := strings.Index(text, tag); end >= 0 {
+				text, n = text[:end], len(tag)+end+len(tag)
+			}
+			tokens = append(tokens, sqlToken{tokString, text, i, i + n})
+			i += n
+		case isWordByte(c):
+			j := i
+			for j < len(query) && isWordByte(query[j]) {
+				j++
+			}
+			if (query[i:j] == "E" || query[i:j] == "e") && j < len(query) && query[j] == '\'' {
+				text, n := readEscaped(query[j:])
+				tokens = append(tokens, sqlToken{tokString, text, i, j + n})
+				i = j + n
+				continue
+			}
+			tokens = append(tokens, sqlToken{tokWord, query[i:j], i, j})
+			i = j
+		default:
+			tokens = append(tokens, sqlToken{tokPunct, string(c), i, i + 1})
+			i++
+		}
+	}
+	return tokens
+}
+
+// readQuoted reads a quoted token with doubled quote escapes and returns
+// its unquoted text and length
+func readQuoted(s string, quote byte) (string, int) {
+	var b strings.Builder
+	for i := 1; i < len(s); i++ {
+		if s[i] == quote {
+			if i+1 < len(s) && s[i+1] == quote {
+				b.WriteByte(quote)
+				i++
+				continue
+			}
+			return b.String(), i + 1
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String(), len(s)
+}
+
+// readEscaped reads an escape string constant from its opening quote, the
+// E'...' syntax, and returns its text and length. Backslash escapes are
+// decoded as Postgres does, so that a quote escaped with a backslash
+// doesn't end the string early.
+func readEscaped(s string) (string, int) {
+	var b strings.Builder
+	for i := 1; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '\\' && i+1 < len(s):
+			i += appendEscape(&b, s[i+1:])
+		case c == '\'' && i+1 < len(s) && s[i+1] == '\'':
+			b.WriteByte(c)
+			i++
+		case c == '\'':
+			return b.String(), i + 1
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String(), len(s)
+}
+
+// appendEscape appends the character of the backslash escape s starts with
+// and returns the number of bytes of s it used
+func appendEscape(b *strings.Builder, s string) int {
+	digits := func(max int, isDigit func(byte) bool) int {
+		n := 0
+		for n < max && n < len(s)-1 && isDigit(s[1+n]) {
+			n++
+		}
+		return n
+	}
+	switch c := s[0]; c {
+	case 'b':
+		b.WriteByte('\b')
+	case 'f':
+		b.WriteByte('\f')
+	case 'n':
+		b.WriteByte('\n')
+	case 'r':
+		b.WriteByte('\r')
+	case 't':
+		b.WriteByte('\t')
+	case '0', '1', '2', '3', '4', '5', '6', '7':
+		n := 1 + digits(2, isOctal)
+		v, _ := strconv.ParseUint(s[:n], 8, 8)
+		b.WriteByte(byte(v))
+		return n
+	case 'x':
+		if n := digits(2, isHex); n > 0 {
+			v, _ := strconv.ParseUint(s[1:1+n], 16, 8)
+			b.WriteByte(byte(v))
+			return 1 + n
+		}
+		b.WriteByte(c)
+	case 'u', 'U':
+		size := 4
+		if c == 'U' {
+			size = 8
+		}
+		if digits(size, isHex) == size {
+			v, _ := strconv.ParseUint(s[1:1+size], 16, 32)
+			b.WriteRune(rune(v))
+			return 1 + size
+		}
+		b.WriteByte(c)
+	default:
+		b.WriteByte(c)
+	}
+	return 1
+}
+
+// dollarTag returns the opening delimiter of the dollar-quoted string s
+// starts with, $$ or $tag$, or "" when it doesn't start with one, e.g. at
+// a $1 placeholder
+func dollarTag(s string) string {
+	for i := 1; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '$':
+			return s[:i+1]
+		case c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || i > 1 && c >= '0' && c <= '9':
+		default:
+			return ""
+		}
+	}
+	return ""
+}
+
+func isOctal(c byte) bool { return c >= '0' && c <= '7' }
+
+func isHex(c byte) bool {
+	return c >= '0' && c <= '9' || c >= 'a' && c <= 'f' || c >= 'A' && c <= 'F'
+}
+
+func isWordByte(c byte) bool {
+	return c == '_' || c == '$' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+func isKeyword(t sqlToken, words ...string) bool {
+	if t.kind != tokWord {
+		return false
+	}
+	for _, w := range words {
+		if strings.EqualFold(t.text, w) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseTableName reads a possibly schema qualified name at tokens[i]
+func parseTableName(tokens []sqlToken, i int) (string, int) {
+	var name string
+	for i < len(tokens) && (tokens[i].kind == tokIdent || tokens[i].kind == tokWord) {
+		name = tokens[i].text
+		i++
+		if i < len(tokens) && tokens[i].text == "." {
+			i++
+			continue
+		}
+		break
+	}
+	return name, i
+}
+
+// splitList splits tokens[i:] after an opening parenthesis into top level
+// comma separated items up to the matching closing parenthesis
+func splitList(tokens []sqlToken, i int) ([][]sqlToken, int) {
+	var items [][]sqlToken
+	var current []sqlToken
+	depth := 0
+	for ; i < len(tokens); i++ {
+		t := tokens[i]
+		switch {
+		case t.kind == tokPunct && t.text == "(":
+			depth++
+		case t.kind == tokPunct && t.text == ")":
+			if depth == 0 {
+				return append(items, current), i + 1
+			}
+			depth--
+		case t.kind == tokPunct && t.text == "," && depth == 0:
+			items = append(items, current)
+			current = nil
+			continue
+		}
+		current = append(current, t)
+	}
+	return append(items, current), i
+}
+
+// literalString returns the value of an expression that is a single string
+// literal, optionally followed by a cast
+func literalString(expr []sqlToken) (string, bool) {
+	if len(expr) == 0 || expr[0].kind != tokString {
+		return "", false
+	}
+	if len(expr) == 1 {
+		return expr[0].text, true
+	}
+	if len(expr) >= 3 && expr[1].text == ":" && expr[2].text == ":" {
+		return expr[0].text, true
+	}
+	return "", false
+}
+
+// parseInsert handles INSERT INTO table (columns) VALUES (...), (...)
+func parseInsert(tokens []sqlToken) (string, []columnWrite) {
+	if len(tokens) < 3 || !isKeyword(tokens[1], "INTO") {
+		return "", nil
+	}
+	table, i := parseTableName(tokens, 2)
+	if i < len(tokens) && isKeyword(tokens[i], "AS") {
+		i += 2
+	}
+	if i >= len(tokens) || tokens[i].text != "(" {
+		return table, nil
+	}
+
+	columnItems, i := splitList(tokens, i+1)
+	columns := make([]string, len(columnItems))
+	for n, item := range columnItems {
+		if len(item) == 1 {
+			columns[n] = item[0].text
+		}
+	}
+
+	if i >= len(tokens) || !isKeyword(tokens[i], "VALUES") {
+		return table, nil
+	}
+	i++
+
+	var writes []columnWrite
+	for i < len(tokens) && tokens[i].text == "(" {
+		var values [][]sqlToken
+		values, i = splitList(tokens, i+1)
+		for n, expr := range values {
+			if n >= len(columns) {
+				break
+			}
+			if v, ok := literalString(expr); ok {
+				writes = append(writes, columnWrite{columns[n], v})
+			}
+		}
+		if i < len(tokens) && tokens[i].text == "," {
+			i++
+		}
+	}
+	return table, writes
+}
+
+// parseUpdate handles UPDATE table [AS alias] SET column = value, ...
+func parseUpdate(tokens []sqlToken) (string, []columnWrite) {
+	i := 1
+	if i < len(tokens) && isKeyword(tokens[i], "ONLY") {
+		i++
+	}
+	table, i := parseTableName(tokens, i)
+	if i < len(tokens) && isKeyword(tokens[i], "AS") {
+		i += 2
+	}
+	if i >= len(tokens) || !isKeyword(tokens[i], "SET") {
+		return table, nil
+	}
+	i++
+
+	var writes []columnWrite
+	var current []sqlToken
+	depth := 0
+	flush := func() {
+		for n, t := range current {
+			if t.kind == tokPunct && t.text == "=" && n > 0 {
+				column := current[n-1].text
+				if v, ok := literalString(current[n+1:]); ok {
+					writes = append(writes, columnWrite{column, v})
+				}
+				break
+			}
+		}
+		current = nil
+	}
+	for ; i < len(tokens); i++ {
+		t := tokens[i]
+		if depth == 0 && isKeyword(t, "FROM", "WHERE", "RETURNING") {
+			break
+		}
+		switch {
+		case t.kind == tokPunct && t.text == "(":
+			depth++
+		case t.kind == tokPunct && t.text == ")":
+			depth--
+		case t.kind == tokPunct && t.text == "," && depth == 0:
+			flush()
+			continue
+		}
+		current = append(current, t)
+	}
+	flush()
+	return table, writes
+}
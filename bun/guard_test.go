@@ -0,0 +1,180 @@
+// Package govault - Bun adapter plaintext write guard tests
+package bun_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/muhammadluth/govault"
+	gb "github.com/muhammadluth/govault/bun"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uptrace/bun"
+)
+
+type TestGuardUser struct {
+	bun.BaseModel `bun:"table:test_guard_users"`
+	ID            int64  `bun:"id,pk,autoincrement"`
+	Email         string `bun:"email" encrypted:"true"`
+	Phone         string `bun:"phone" encrypted:"true" codec:"ciphersweet"`
+	Name          string `bun:"name"`
+}
+
+func TestPlaintextGuardCheck(t *testing.T) {
+	guard := gb.NewPlaintextGuard((*TestGuardUser)(nil))
+
+	tests := []struct {
+		name   string
+		query  string
+		column string
+	}{
+		{"insert ciphertext", `INSERT INTO "test_guard_users" ("id", "email", "name") VALUES (DEFAULT, 'gv1:3|bm9uY2U=|Y3Q=', 'Bob') RETURNING "id"`, ""},
+		{"insert plaintext", `INSERT INTO "test_guard_users" ("id", "email", "name") VALUES (DEFAULT, 'bob@example.com', 'Bob') RETURNING "id"`, "email"},
+		{"bulk insert second row", `INSERT INTO "test_guard_users" ("email", "phone") VALUES ('gv1:3|a|b', 'nacl:x'), ('gv1:3|a|b', '555')`, "phone"},
+		{"insert empty and null", `INSERT INTO "test_guard_users" ("email", "phone") VALUES ('', NULL)`, ""},
+		{"insert cast", `INSERT INTO test_guard_users AS u (email) VALUES ('x'::text)`, "email"},
+		{"update ciphertext", `UPDATE "test_guard_users" AS "test_guard_user" SET "email" = 'gv1:3|a|b', "name" = 'x' WHERE ("test_guard_user"."id" = 1)`, ""},
+		{"update plaintext", `UPDATE "test_guard_users" AS "test_guard_user" SET "name" = 'it''s', "email" = 'bob@example.com' WHERE (id = 1)`, "email"},
+		{"update qualified column", `UPDATE test_guard_users SET "test_guard_users"."email" = E'bob' WHERE id = 1`, "email"},
+		{"escape string quote", `UPDATE test_guard_users SET email = E'\'', name = 'x' WHERE id = 1`, "email"},
+		{"escape string hiding a write", `UPDATE test_guard_users SET name = E'\'', email = 'bob@example.com' WHERE id = 1`, "email"},
+		{"escape string ciphertext", `INSERT INTO test_guard_users (email) VALUES (E'\x67v1:3|a|b')`, ""},
+		{"escape string plaintext", `INSERT INTO test_guard_users (email) VALUES (E'\u0067v1')`, "email"},
+		{"dollar quoted", `INSERT INTO test_guard_users (email, name) VALUES ($$bob@example.com$$, $1)`, "email"},
+		{"tagged dollar quoted", `UPDATE test_guard_users SET email = $q$it's$q$ WHERE id = $1`, "email"},
+		{"update expression", `UPDATE "test_guard_users" SET "email" = lower(email) WHERE "email" = 'plain'`, ""},
+		{"other table", `INSERT INTO "other" ("email") VALUES ('bob@example.com')`, ""},
+		{"select", `SELECT * FROM "test_guard_users" WHERE "email" = 'bob@example.com'`, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := guard.Check(tt.query)
+			if tt.column == "" {
+				assert.Nil(t, err)
+				return
+			}
+			require.NotNil(t, err)
+			assert.Equal(t, "test_guard_users", err.Table)
+			assert.Equal(t, tt.column, err.Column)
+		})
+	}
+}
+
+type TestGuardBinaryUser struct {
+	bun.BaseModel `bun:"table:test_guard_binary_users"`
+	ID            int64  `bun:"id,pk,autoincrement"`
+	Email         string `bun:"email,type:bytea" encrypted:"true" storage:"binary"`
+}
+
+func TestPlaintextGuardBinary(t *testing.T) {
+	guard := gb.NewPlaintextGuard((*TestGuardBinaryUser)(nil))
+	data, err := govault.EncodeBinary("gv1:3|AAAAAAAAAAAAAAAA|AAAAAAAAAAAAAAAAAAAAAA==")
+	require.NoError(t, err)
+
+	ciphertext := fmt.Sprintf(`INSERT INTO "test_guard_binary_users" ("id", "email") VALUES (DEFAULT, '\x%x')`, data)
+	assert.Nil(t, guard.Check(ciphertext))
+	plaintext := fmt.Sprintf(`INSERT INTO "test_guard_binary_users" ("id", "email") VALUES (DEFAULT, '\x%x')`, "bob@example.com")
+	guardErr := guard.Check(plaintext)
+	require.NotNil(t, guardErr)
+	assert.Equal(t, "email", guardErr.Column)
+
+	stmts := gb.PlaintextGuardTriggers((*TestGuardBinaryUser)(nil))
+	require.Len(t, stmts, 3)
+	assert.Contains(t, stmts[0], `substring(NEW."email" from 1 for 4) <> '\x00677601'::bytea`)
+	assert.NotContains(t, stmts[0], `left(NEW."email"`)
+}
+
+func TestPlaintextGuardBlocks(t *testing.T) {
+	guard := gb.NewPlaintextGuard((*TestGuardUser)(nil))
+	ctx := guard.BeforeQuery(context.Background(), &bun.QueryEvent{
+		Query: `INSERT INTO "test_guard_users" ("email") VALUES ('bob@example.com')`,
+	})
+	<-ctx.Done()
+
+	var guardErr *gb.PlaintextWriteError
+	require.ErrorAs(t, ctx.Err(), &guardErr, "queries fail with the violation")
+	assert.Equal(t, "email", guardErr.Column)
+	assert.ErrorIs(t, ctx.Err(), context.Canceled)
+	assert.Equal(t, guardErr, context.Cause(ctx))
+
+	ctx = guard.BeforeQuery(context.Background(), &bun.QueryEvent{Query: `SELECT 1`})
+	assert.NoError(t, ctx.Err())
+}
+
+func TestPlaintextGuardTriggers(t *testing.T) {
+	stmts := gb.PlaintextGuardTriggers((*TestGuardUser)(nil), (*TestUser)(nil))
+	require.Len(t, stmts, 6)
+	assert.Contains(t, stmts[0], `CREATE OR REPLACE FUNCTION "govault_guard_test_guard_users"()`)
+	assert.Contains(t, stmts[0], `left(NEW."email", 4) <> 'gv1:'`)
+	assert.Contains(t, stmts[0], `left(NEW."phone", 5) <> 'nacl:'`)
+	assert.NotContains(t, stmts[0], `NEW."name"`)
+	assert.Equal(t, `CREATE TRIGGER "govault_guard" BEFORE INSERT OR UPDATE ON "test_guard_users" FOR EACH ROW EXECUTE FUNCTION "govault_guard_test_guard_users"()`, stmts[2])
+}
+
+func TestBunPlaintextGuard(t *testing.T) {
+	db, _, cleanup := setupTestDB(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	_, err := db.NewCreateTable().Model((*TestGuardUser)(nil)).IfNotExists().Exec(ctx)
+	require.NoError(t, err)
+	defer db.NewDropTable().Model((*TestGuardUser)(nil)).IfExists().Exec(ctx)
+
+	t.Run("query hook", func(t *testing.T) {
+		var violations []*gb.PlaintextWriteError
+		guard := gb.NewPlaintextGuard((*TestGuardUser)(nil))
+		guard.OnViolation = func(_ context.Context, err *gb.PlaintextWriteError) {
+			violations = append(violations, err)
+		}
+		db.AddQueryHook(guard)
+
+		// Wrapper queries encrypt and pass the guard
+		_, err := db.NewInsert().Model(&TestGuardUser{Email: "ok@example.com", Phone: "1"}).Exec(ctx)
+		require.NoError(t, err)
+		assert.Empty(t, violations)
+
+		// Direct bun queries bypass encryption and are blocked
+		_, err = db.DB.NewInsert().Model(&TestGuardUser{Email: "leak@example.com"}).Exec(ctx)
+		var guardErr *gb.PlaintextWriteError
+		require.ErrorAs(t, err, &guardErr)
+		assert.Equal(t, "email", guardErr.Column)
+		require.Len(t, violations, 1)
+		assert.Equal(t, "email", violations[0].Column)
+
+		guard.LogOnly = true
+		_, err = db.DB.NewUpdate().Model((*TestGuardUser)(nil)).Set("email = ?", "leak@example.com").Where("1 = 1").Exec(ctx)
+		require.NoError(t, err)
+		assert.Len(t, violations, 2)
+	})
+
+	t.Run("triggers", func(t *testing.T) {
+		require.NoError(t, db.InstallPlaintextGuard(ctx, (*TestGuardUser)(nil)))
+
+		_, err := db.NewInsert().Model(&TestGuardUser{Email: "ok@example.com"}).Exec(ctx)
+		require.NoError(t, err)
+
+		_, err = db.DB.NewRaw("INSERT INTO test_guard_users (email) VALUES (?)", "leak@example.com").Exec(ctx)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "plaintext write to encrypted column test_guard_users.email")
+	})
+
+	t.Run("binary columns", func(t *testing.T) {
+		_, err := db.NewCreateTable().Model((*TestGuardBinaryUser)(nil)).IfNotExists().Exec(ctx)
+		require.NoError(t, err)
+		defer db.NewDropTable().Model((*TestGuardBinaryUser)(nil)).IfExists().Exec(ctx)
+
+		guard := gb.NewPlaintextGuard((*TestGuardBinaryUser)(nil))
+		db.AddQueryHook(guard)
+		require.NoError(t, db.InstallPlaintextGuard(ctx, (*TestGuardBinaryUser)(nil)))
+
+		_, err = db.NewInsert().Model(&TestGuardBinaryUser{Email: "ok@example.com"}).Exec(ctx)
+		require.NoError(t, err)
+
+		guard.LogOnly = true
+		_, err = db.DB.NewRaw("INSERT INTO test_guard_binary_users (email) VALUES (?)", []byte("leak@example.com")).Exec(ctx)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "plaintext write to encrypted column test_guard_binary_users.email")
+	})
+}
@@ -0,0 +1,81 @@
+// Package govault - Bun adapter model hook tests
+package bun_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/muhammadluth/govault"
+	"github.com/muhammadluth/govault/govaulttest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect/pgdialect"
+)
+
+type TestHookUser struct {
+	bun.BaseModel `bun:"table:test_hook_users"`
+	ID            int64  `bun:"id,pk,autoincrement"`
+	Email         string `bun:"email" encrypted:"true"`
+}
+
+// orderHook records bun's AfterQuery and govault's model hooks in order
+type orderHook struct {
+	events []string
+}
+
+func (h *orderHook) BeforeQuery(ctx context.Context, _ *bun.QueryEvent) context.Context {
+	return ctx
+}
+
+func (h *orderHook) AfterQuery(_ context.Context, event *bun.QueryEvent) {
+	h.events = append(h.events, "bun "+event.Operation())
+}
+
+func (h *orderHook) BeforeEncrypt(event *govault.ModelEvent) error {
+	h.events = append(h.events, "before "+event.Model.(*TestHookUser).Email)
+	return nil
+}
+
+func (h *orderHook) AfterDecrypt(_ context.Context, event *govault.ModelEvent) error {
+	if user, ok := event.Model.(*TestHookUser); ok {
+		h.events = append(h.events, "after "+string(event.Operation)+" "+user.Email)
+	}
+	return nil
+}
+
+func TestModelHooks(t *testing.T) {
+	openDB := openPostgres(t)
+	defer openDB.Close()
+
+	hook := &orderHook{}
+	govaultDB, err := govault.NewWithOptions(
+		govault.WithBun(bun.NewDB(openDB, pgdialect.New())),
+		govault.WithKeys(govaulttest.EphemeralKeys(1)),
+		govault.WithDefaultKey("1"),
+		govault.WithHook(hook),
+		govault.WithModelHook(hook),
+		govault.WithExposePlaintextToHooks(),
+	)
+	require.NoError(t, err)
+	db := govaultDB.BunDB()
+
+	ctx := context.Background()
+	_, err = db.NewCreateTable().Model((*TestHookUser)(nil)).IfNotExists().Exec(ctx)
+	require.NoError(t, err)
+	defer db.NewDropTable().Model((*TestHookUser)(nil)).IfExists().Exec(ctx)
+
+	user := &TestHookUser{Email: "hook@example.com"}
+	hook.events = nil
+	_, err = db.NewInsert().Model(user).Exec(ctx)
+	require.NoError(t, err)
+
+	var retrieved TestHookUser
+	require.NoError(t, db.NewSelect().Model(&retrieved).Where("id = ?", user.ID).Scan(ctx, &retrieved))
+	assert.Equal(t, []string{
+		"before hook@example.com",
+		"bun INSERT",
+		"bun SELECT",
+		"after SELECT hook@example.com",
+	}, hook.events)
+}
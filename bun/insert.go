@@ -6,6 +6,7 @@ import (
 	"database/sql"
 	"fmt"
 	"reflect"
+	"time"
 
 	"github.com/muhammadluth/govault/internal"
 	"github.com/uptrace/bun"
@@ -17,6 +18,9 @@ type BunInsertQuery struct {
 	*bun.InsertQuery
 	govault *internal.GovaultDB
 	keyID   string
+	timeout time.Duration
+	// encrypted is set once Model encrypted a model with keyID
+	encrypted bool
 }
 
 // Conn sets the database connection
@@ -27,9 +31,11 @@ func (q *BunInsertQuery) Conn(db bun.IConn) *BunInsertQuery {
 
 // Model sets the model and encrypts fields
 func (q *BunInsertQuery) Model(model any) *BunInsertQuery {
+	bindBinaryFields(q.DB(), q.govault, model)
 	if err := q.encryptModel(model); err != nil {
 		return q.Err(err)
 	}
+	q.encrypted = q.encrypted || modelEncrypted(model)
 	q.InsertQuery.Model(model)
 	return q
 }
@@ -172,85 +178,58 @@ func (q *BunInsertQuery) SetValues(values bun.ValuesQuery) *BunInsertQuery {
 
 // Scan executes the query and scans the result
 func (q *BunInsertQuery) Scan(ctx context.Context, dest ...any) error {
-	err := q.InsertQuery.Scan(ctx, dest...)
-	if err != nil {
+	ctx, cancel := withTimeout(ctx, q.timeout)
+	defer cancel()
+
+	if err := q.InsertQuery.Scan(ctx, dest...); err != nil {
 		return err
 	}
-	for _, d := range dest {
-		if err := q.govault.DecryptRecursive(d); err != nil {
-			return err
-		}
-	}
-	return nil
+	return decryptReturning(ctx, q.govault, internal.OperationInsert, dest)
 }
 
-// Exec executes the insert query
+// Exec executes the insert query. Rows returned into dest, e.g. a slice for
+// a multi-row RETURNING, are decrypted.
 func (q *BunInsertQuery) Exec(ctx context.Context, dest ...any) (sql.Result, error) {
+	ctx, cancel := withTimeout(ctx, q.timeout)
+	defer cancel()
+
 	res, err := q.InsertQuery.Exec(ctx, dest...)
 	if err != nil {
 		return res, err
 	}
-	if len(dest) > 0 {
-		for _, d := range dest {
-			if err := q.govault.DecryptRecursive(d); err != nil {
-				return res, err
-			}
-		}
-	}
-	return res, nil
+	return res, decryptReturning(ctx, q.govault, internal.OperationInsert, dest)
 }
 
 func (q *BunInsertQuery) String() string {
 	return q.InsertQuery.String()
 }
 
-// WithKey sets the encryption key for this query
+// WithKey sets the encryption key for this query. It must be called
+// before Model, which encrypts the model.
 func (q *BunInsertQuery) WithKey(keyID string) *BunInsertQuery {
+	if q.encrypted && keyID != q.keyID {
+		return q.Err(fmt.Errorf("WithKey(%q) called after Model, the model is already encrypted", keyID))
+	}
 	q.keyID = keyID
 	return q
 }
 
+// KeyID returns the key of the query, empty for the default key
+func (q *BunInsertQuery) KeyID() string {
+	return q.keyID
+}
+
 // encryptModel encrypts fields tagged with encrypted:"true"
 func (q *BunInsertQuery) encryptModel(model any) error {
-	val := reflect.ValueOf(model)
-	if val.Kind() == reflect.Ptr {
-		val = val.Elem()
-	}
-
-	if val.Kind() != reflect.Struct {
-		return nil
-	}
-
-	typ := val.Type()
-	for i := 0; i < val.NumField(); i++ {
-		field := val.Field(i)
-		fieldType := typ.Field(i)
-
-		if !field.CanSet() {
-			continue
-		}
-
-		if fieldType.Tag.Get("encrypted") == "true" {
-			if field.Kind() == reflect.String {
-				plaintext := field.String()
-				if plaintext != "" {
-					var encrypted string
-					var err error
-
-					if q.keyID != "" {
-						encrypted, err = q.govault.Encrypt(plaintext, q.keyID)
-					} else {
-						encrypted, err = q.govault.Encrypt(plaintext)
-					}
-
-					if err != nil {
-						return fmt.Errorf("failed to encrypt field %s: %w", fieldType.Name, err)
-					}
-					field.SetString(encrypted)
-				}
-			}
-		}
+	if err := q.govault.BeforeEncrypt(internal.OperationInsert, model); err != nil {
+		return err
 	}
+	return q.govault.EncryptModel(model, q.keyID)
+}
 
-	return nil
+// modelEncrypted reports whether Model encrypts values of model, i.e. it
+// is not a nil pointer
+func modelEncrypted(model any) bool {
+	v := reflect.ValueOf(model)
+	return v.IsValid() && !(v.Kind() == reflect.Ptr && v.IsNil())
 }
@@ -3,10 +3,13 @@ package bun_test
 
 import (
 	"context"
+	"database/sql"
 	"strings"
 	"testing"
 
+	"github.com/muhammadluth/govault"
 	gb "github.com/muhammadluth/govault/bun"
+	"github.com/muhammadluth/govault/govaulttest"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/uptrace/bun"
@@ -298,3 +301,67 @@ func TestBunInsertExotic(t *testing.T) {
 		require.NoError(t, err)
 	})
 }
+
+type TestEmptyValue struct {
+	bun.BaseModel `bun:"table:test_empty_values"`
+	ID            int64  `bun:"id,pk,autoincrement"`
+	Email         string `bun:"email" encrypted:"true"`
+	Phone         string `bun:"phone,nullzero" encrypted:"true"`
+}
+
+func TestBunEncryptEmptyStrings(t *testing.T) {
+	db, _, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	govaultDB, err := govault.New(govault.Config{
+		AdapterName:         govault.AdapterNameBun,
+		BunDB:               db.DB,
+		Keys:                map[string][]byte{"3": govaulttest.EphemeralKeys(3)["3"]},
+		DefaultKeyID:        "3",
+		EncryptEmptyStrings: true,
+	})
+	require.NoError(t, err)
+	edb := govaultDB.BunDB()
+
+	_, err = edb.NewCreateTable().Model((*TestEmptyValue)(nil)).IfNotExists().Exec(ctx)
+	require.NoError(t, err)
+	defer edb.NewDropTable().Model((*TestEmptyValue)(nil)).IfExists().Exec(ctx)
+
+	empty := &TestEmptyValue{}
+	short := &TestEmptyValue{Email: "a", Phone: "1"}
+	_, err = edb.NewInsert().Model(empty).Exec(ctx)
+	require.NoError(t, err)
+	_, err = edb.NewInsert().Model(short).Exec(ctx)
+	require.NoError(t, err)
+	_, err = edb.NewRaw("INSERT INTO test_empty_values (email, phone) VALUES (NULL, NULL)").Exec(ctx)
+	require.NoError(t, err)
+
+	t.Run("empty strings are stored encrypted", func(t *testing.T) {
+		var raw []struct {
+			ID    int64
+			Email sql.NullString
+			Phone sql.NullString
+		}
+		require.NoError(t, edb.DB.NewSelect().Table("test_empty_values").Column("id", "email", "phone").Order("id").Scan(ctx, &raw))
+		require.Len(t, raw, 3)
+
+		assert.True(t, strings.HasPrefix(raw[0].Email.String, govault.FormatPrefix))
+		assert.Len(t, raw[0].Email.String, len(raw[1].Email.String), "padding hides empty values")
+		assert.False(t, raw[0].Phone.Valid, "nullzero fields keep storing NULL")
+		assert.False(t, raw[2].Email.Valid)
+	})
+
+	t.Run("empty and NULL read back as empty", func(t *testing.T) {
+		var rows []TestEmptyValue
+		require.NoError(t, edb.NewSelect().Model(&rows).Order("id").Scan(ctx, &rows))
+		require.Len(t, rows, 3)
+
+		assert.Equal(t, "", rows[0].Email)
+		assert.Equal(t, "", rows[0].Phone)
+		assert.Equal(t, "a", rows[1].Email)
+		assert.Equal(t, "1", rows[1].Phone)
+		assert.Equal(t, "", rows[2].Email)
+		assert.Equal(t, "", rows[2].Phone)
+	})
+}
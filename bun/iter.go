@@ -0,0 +1,103 @@
+// Package govault - Bun adapter result streaming
+package bun
+
+import (
+	"context"
+	"database/sql"
+	"iter"
+	"reflect"
+
+	"github.com/muhammadluth/govault/internal"
+)
+
+// All runs the select built by q and yields its rows as T, each decrypted
+// as it is read, so large result sets are never held in a slice:
+//
+//	for user, err := range gb.All[User](ctx, db.NewSelect().Model((*User)(nil))) {
+//		if err != nil {
+//			return err
+//		}
+//		...
+//	}
+//
+// The query runs when the iteration starts; a failure is yielded once and
+// ends it. Breaking out of the loop closes the rows. The timeout of q
+// bounds the whole iteration.
+func All[T any](ctx context.Context, q *BunSelectQuery) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		var zero T
+		ctx, cancel := withTimeout(ctx, q.timeout)
+		defer cancel()
+
+		rows, err := q.rows(ctx)
+		if err != nil {
+			yield(zero, err)
+			return
+		}
+		defer rows.Close()
+
+		_, model := newRow[T]()
+		bindBinaryFields(q.DB(), q.govault, model)
+		for rows.Next() {
+			row, dest := newRow[T]()
+			if err := q.DB().ScanRow(ctx, rows, dest); err != nil {
+				yield(zero, err)
+				return
+			}
+			if err := q.govault.DecryptRecursiveContext(ctx, dest); err != nil {
+				yield(zero, err)
+				return
+			}
+			if err := q.govault.AfterDecrypt(ctx, internal.OperationSelect, dest); err != nil {
+				yield(zero, err)
+				return
+			}
+			if !yield(*row, nil) {
+				return
+			}
+		}
+		if err := rows.Err(); err != nil {
+			yield(zero, err)
+		}
+	}
+}
+
+// newRow returns a row of type T and the pointer to the struct scanned
+// into, the row itself when T is a pointer to a struct
+func newRow[T any]() (*T, any) {
+	row := new(T)
+	if typ := reflect.TypeFor[T](); typ.Kind() == reflect.Pointer && typ.Elem().Kind() == reflect.Struct {
+		v := reflect.New(typ.Elem())
+		reflect.ValueOf(row).Elem().Set(v)
+		return row, v.Interface()
+	}
+	return row, row
+}
+
+// rows runs the select, rewritten by UseBlindIndexes, and returns its
+// unread rows
+func (q *BunSelectQuery) rows(ctx context.Context) (*sql.Rows, error) {
+	if q.blindIndexes {
+		if table, spec := q.modelSpec(); spec != nil {
+			query, err := q.SelectQuery.AppendQuery(q.DB().QueryGen(), nil)
+			if err != nil {
+				return nil, err
+			}
+			rewritten := rewriteBlindIndexes(string(query), spec, table.Alias, table.Name)
+			if !q.allowEncrypted {
+				if err := checkClauses(tokenizeSQL(rewritten), spec, table.Alias, table.Name); err != nil {
+					return nil, err
+				}
+			}
+			conn := q.conn
+			if conn == nil {
+				conn = q.DB()
+			}
+			return conn.QueryContext(ctx, rewritten)
+		}
+	}
+	if err := q.checkEncryptedColumns(); err != nil {
+		return nil, err
+	}
+	return q.SelectQuery.Rows(ctx)
+}
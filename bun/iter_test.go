@@ -0,0 +1,61 @@
+// Package govault - Bun adapter result streaming tests
+package bun_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	gb "github.com/muhammadluth/govault/bun"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBunAll(t *testing.T) {
+	db, _, cleanup := setupTestDB(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	for i := range 5 {
+		user := &TestUser{Name: fmt.Sprintf("user%d", i), Email: fmt.Sprintf("user%d@example.com", i)}
+		_, err := db.NewInsert().Model(user).Exec(ctx)
+		require.NoError(t, err)
+	}
+
+	t.Run("rows are decrypted", func(t *testing.T) {
+		var emails []string
+		for user, err := range gb.All[TestUser](ctx, db.NewSelect().Model((*TestUser)(nil)).Order("id")) {
+			require.NoError(t, err)
+			emails = append(emails, user.Email)
+		}
+		assert.Equal(t, []string{"user0@example.com", "user1@example.com", "user2@example.com", "user3@example.com", "user4@example.com"}, emails)
+	})
+
+	t.Run("break stops the iteration", func(t *testing.T) {
+		n := 0
+		for _, err := range gb.All[*TestUser](ctx, db.NewSelect().Model((*TestUser)(nil))) {
+			require.NoError(t, err)
+			if n++; n == 2 {
+				break
+			}
+		}
+		assert.Equal(t, 2, n)
+	})
+
+	t.Run("errors are yielded once", func(t *testing.T) {
+		var errs []error
+		for _, err := range gb.All[TestUser](ctx, db.NewSelect().Model((*TestUser)(nil)).Order("email")) {
+			errs = append(errs, err)
+		}
+		require.Len(t, errs, 1)
+		assert.ErrorContains(t, errs[0], "cannot order by encrypted column")
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		q := db.NewSelect().Model((*TestUser)(nil)).Timeout(time.Nanosecond)
+		for _, err := range gb.All[TestUser](ctx, q) {
+			assert.Error(t, err)
+		}
+	})
+}
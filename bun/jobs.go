@@ -0,0 +1,275 @@
+// Package govault - Bun adapter checkpointed rotation jobs
+package bun
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// JobStatus is the state of a job in govault_jobs
+type JobStatus string
+
+const (
+	JobPending   JobStatus = "pending"
+	JobRunning   JobStatus = "running"
+	JobPaused    JobStatus = "paused"
+	JobCompleted JobStatus = "completed"
+	JobFailed    JobStatus = "failed"
+)
+
+// JobKindRotation marks jobs running a RotationPlan
+const JobKindRotation = "rotation"
+
+// RotationJob is a row of the govault_jobs table. It stores a rotation plan
+// and the progress made on it, so an interrupted rotation resumes from its
+// last checkpoint instead of restarting.
+type RotationJob struct {
+	bun.BaseModel `bun:"table:govault_jobs"`
+
+	ID         string                       `bun:"id,pk" json:"id"`
+	Kind       string                       `bun:"kind,notnull" json:"kind"`
+	Status     JobStatus                    `bun:"status,notnull" json:"status"`
+	Plan       *RotationPlan                `bun:"plan,type:json" json:"plan"`
+	Progress   map[string]*RotationProgress `bun:"progress,type:json" json:"progress"`
+	Error      string                       `bun:"error" json:"error,omitempty"`
+	CreatedAt  time.Time                    `bun:"created_at,notnull,default:current_timestamp" json:"created_at"`
+	UpdatedAt  time.Time                    `bun:"updated_at,notnull,default:current_timestamp" json:"updated_at"`
+	StartedAt  time.Time                    `bun:"started_at,nullzero" json:"started_at,omitempty"`
+	FinishedAt time.Time                    `bun:"finished_at,nullzero" json:"finished_at,omitempty"`
+}
+
+// RotationProgress is the checkpoint of one table of a rotation job
+type RotationProgress struct {
+	// LastPK is the last primary key processed, empty before the first batch
+	LastPK    string `json:"last_pk,omitempty"`
+	Rotated   int64  `json:"rotated"`
+	Conflicts int64  `json:"conflicts"`
+	Skipped   int64  `json:"skipped"`
+	Done      bool   `json:"done"`
+}
+
+// CreateRotationJob stores plan as a pending job in govault_jobs, creating
+// the table if needed
+func (db *BunDB) CreateRotationJob(ctx context.Context, plan *RotationPlan) (*RotationJob, error) {
+	if _, err := db.DB.NewCreateTable().Model((*RotationJob)(nil)).IfNotExists().Exec(ctx); err != nil {
+		return nil, fmt.Errorf("failed to create jobs table: %w", err)
+	}
+
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return nil, fmt.Errorf("failed to generate job ID: %w", err)
+	}
+
+	now := time.Now()
+	job := &RotationJob{
+		ID:        hex.EncodeToString(id),
+		Kind:      JobKindRotation,
+		Status:    JobPending,
+		Plan:      plan,
+		Progress:  make(map[string]*RotationProgress),
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if _, err := db.DB.NewInsert().Model(job).Exec(ctx); err != nil {
+		return nil, fmt.Errorf("failed to create rotation job: %w", err)
+	}
+	return job, nil
+}
+
+// RunRotationJob runs or resumes a rotation job from its checkpoints. The
+// progress of every batch is committed together with the batch. It returns
+// when the job completes, fails, is paused with PauseRotationJob or ctx is
+// cancelled; a cancelled job is left paused. Only one runner per job is
+// supported. Values are skipped and tables marked rotated in the catalog
+// as by ExecuteRotation.
+func (db *BunDB) RunRotationJob(ctx context.Context, id string) (*RotationJob, error) {
+	if err := checkRewriteMode(db.govault); err != nil {
+		return nil, err
+	}
+	job, err := db.RotationJob(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if job.Status == JobCompleted {
+		return job, nil
+	}
+	if !hasKey(db.govault, job.Plan.TargetKeyID) {
+		return nil, fmt.Errorf("target key ID '%s' not found in keys", job.Plan.TargetKeyID)
+	}
+
+	job.Status, job.Error = JobRunning, ""
+	if job.StartedAt.IsZero() {
+		job.StartedAt = time.Now()
+	}
+	if err := db.saveJob(ctx, job, "status", "error", "started_at"); err != nil {
+		return nil, err
+	}
+
+	paused, err := db.runRotationJob(ctx, job)
+	switch {
+	case errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded):
+		job.Status = JobPaused
+	case err != nil:
+		job.Status, job.Error = JobFailed, err.Error()
+	case paused:
+		return job, nil
+	default:
+		job.Status, job.FinishedAt = JobCompleted, time.Now()
+	}
+
+	// Record the outcome even if ctx is done
+	if saveErr := db.saveJob(context.WithoutCancel(ctx), job, "status", "error", "finished_at"); saveErr != nil && err == nil {
+		err = saveErr
+	}
+	return job, err
+}
+
+// runRotationJob processes the remaining batches of job. It reports true
+// when the job was paused in between.
+func (db *BunDB) runRotationJob(ctx context.Context, job *RotationJob) (bool, error) {
+	plan := job.Plan
+	batchSize := plan.BatchSize
+	if batchSize <= 0 {
+		batchSize = DefaultRotationBatchSize
+	}
+
+	for _, tablePlan := range plan.Tables {
+		columns := tablePlan.rotatedColumns()
+		if tablePlan.Skipped != "" || len(columns) == 0 {
+			continue
+		}
+
+		progress := job.Progress[tablePlan.Table]
+		if progress == nil {
+			progress = &RotationProgress{}
+			job.Progress[tablePlan.Table] = progress
+		}
+
+		for !progress.Done {
+			var after any
+			if progress.LastPK != "" {
+				after = progress.LastPK
+			}
+
+			next := *progress
+			rw := db.rotation(tablePlan, columns, plan.TargetKeyID, batchSize)
+			rw.afterBatch = func(ctx context.Context, tx bun.Tx, batch rewriteBatch) error {
+				next.Rotated += batch.rewritten
+				next.Conflicts += batch.conflicts
+				next.Skipped += batch.skipped
+				if batch.last == nil {
+					next.Done = true
+				} else {
+					next.LastPK = pkString(batch.last)
+				}
+
+				job.Progress[tablePlan.Table] = &next
+				job.UpdatedAt = time.Now()
+				_, err := tx.NewUpdate().
+					Model(job).
+					Column("progress", "updated_at").
+					WherePK().
+					Exec(ctx)
+				return err
+			}
+			if _, err := db.rewriteBatch(ctx, rw, after); err != nil {
+				job.Progress[tablePlan.Table] = progress
+				return false, fmt.Errorf("failed to rotate %s: %w", tablePlan.Table, err)
+			}
+			progress = job.Progress[tablePlan.Table]
+			if progress.Done && progress.Conflicts == 0 && progress.Skipped == 0 {
+				if err := markRotated(ctx, db.DB, tablePlan.Table, columnNames(columns), time.Now()); err != nil {
+					return false, fmt.Errorf("failed to rotate %s: %w", tablePlan.Table, err)
+				}
+			}
+
+			var status JobStatus
+			err := db.DB.NewSelect().
+				Model((*RotationJob)(nil)).
+				Column("status").
+				Where("id = ?", job.ID).
+				Scan(ctx, &status)
+			if err != nil {
+				return false, fmt.Errorf("failed to read job status: %w", err)
+			}
+			if status == JobPaused {
+				job.Status = JobPaused
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+// PauseRotationJob asks a running job to stop after its current batch. A
+// paused job is resumed with RunRotationJob.
+func (db *BunDB) PauseRotationJob(ctx context.Context, id string) error {
+	res, err := db.DB.NewUpdate().
+		Model((*RotationJob)(nil)).
+		Set("status = ?", JobPaused).
+		Set("updated_at = ?", time.Now()).
+		Where("id = ?", id).
+		Where("status IN (?)", bun.In([]JobStatus{JobPending, JobRunning})).
+		Exec(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to pause job: %w", err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf("job %s is not pending or running", id)
+	}
+	return nil
+}
+
+// RotationJob returns the job with its status and per-table progress
+func (db *BunDB) RotationJob(ctx context.Context, id string) (*RotationJob, error) {
+	job := new(RotationJob)
+	if err := db.DB.NewSelect().Model(job).Where("id = ?", id).Scan(ctx); err != nil {
+		return nil, fmt.Errorf("failed to read job %s: %w", id, err)
+	}
+	if job.Progress == nil {
+		job.Progress = make(map[string]*RotationProgress)
+	}
+	return job, nil
+}
+
+// RotationJobs returns all rotation jobs, newest first
+func (db *BunDB) RotationJobs(ctx context.Context) ([]RotationJob, error) {
+	var jobs []RotationJob
+	err := db.DB.NewSelect().
+		Model(&jobs).
+		Where("kind = ?", JobKindRotation).
+		Order("created_at DESC").
+		Scan(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read jobs: %w", err)
+	}
+	return jobs, nil
+}
+
+// saveJob writes the given columns of job
+func (db *BunDB) saveJob(ctx context.Context, job *RotationJob, columns ...string) error {
+	job.UpdatedAt = time.Now()
+	_, err := db.DB.NewUpdate().
+		Model(job).
+		Column(append(columns, "updated_at")...).
+		WherePK().
+		Exec(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to update job %s: %w", job.ID, err)
+	}
+	return nil
+}
+
+// pkString converts a scanned primary key to its checkpoint form
+func pkString(pk any) string {
+	if b, ok := pk.([]byte); ok {
+		return string(b)
+	}
+	return fmt.Sprint(pk)
+}
@@ -0,0 +1,106 @@
+// Package govault - Bun adapter rotation job tests
+package bun_test
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	gb "github.com/muhammadluth/govault/bun"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uptrace/bun"
+)
+
+// cancelAfterCheckpoints cancels a context once n job checkpoints were
+// written, simulating a rotation interrupted mid-run
+type cancelAfterCheckpoints struct {
+	n      int
+	cancel context.CancelFunc
+}
+
+func (h *cancelAfterCheckpoints) BeforeQuery(ctx context.Context, _ *bun.QueryEvent) context.Context {
+	return ctx
+}
+
+func (h *cancelAfterCheckpoints) AfterQuery(_ context.Context, event *bun.QueryEvent) {
+	if h.cancel != nil && strings.HasPrefix(event.Query, `UPDATE "govault_jobs"`) && strings.Contains(event.Query, `"progress"`) {
+		if h.n--; h.n == 0 {
+			h.cancel()
+		}
+	}
+}
+
+func TestBunRotationJob(t *testing.T) {
+	db, _, cleanup := setupTestDB(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	_, err := db.NewCreateTable().Model((*TestRotationUser)(nil)).IfNotExists().Exec(ctx)
+	require.NoError(t, err)
+	defer db.NewDropTable().Model((*TestRotationUser)(nil)).IfExists().Exec(ctx)
+	defer db.NewDropTable().Model((*gb.RotationJob)(nil)).IfExists().Exec(ctx)
+
+	for i := 0; i < 10; i++ {
+		_, err := db.WithKey("1").NewInsert().Model(&TestRotationUser{Email: fmt.Sprintf("user%d@example.com", i)}).Exec(ctx)
+		require.NoError(t, err)
+	}
+
+	plan, err := db.PlanRotation(ctx, []any{(*TestRotationUser)(nil)}, "2", 3)
+	require.NoError(t, err)
+	job, err := db.CreateRotationJob(ctx, plan)
+	require.NoError(t, err)
+	assert.Equal(t, gb.JobPending, job.Status)
+
+	t.Run("interrupted run resumes from checkpoint", func(t *testing.T) {
+		runCtx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		hook := &cancelAfterCheckpoints{n: 2, cancel: cancel}
+		db.AddQueryHook(hook)
+
+		// The second batch is rolled back together with its checkpoint
+		job, err := db.RunRotationJob(runCtx, job.ID)
+		require.ErrorIs(t, err, context.Canceled)
+		assert.Equal(t, gb.JobPaused, job.Status)
+		hook.cancel = nil
+
+		status, err := db.RotationJob(ctx, job.ID)
+		require.NoError(t, err)
+		assert.Equal(t, gb.JobPaused, status.Status)
+		progress := status.Progress["test_rotation_users"]
+		require.NotNil(t, progress)
+		assert.EqualValues(t, 3, progress.Rotated)
+		assert.False(t, progress.Done)
+
+		job, err = db.RunRotationJob(ctx, job.ID)
+		require.NoError(t, err)
+		assert.Equal(t, gb.JobCompleted, job.Status)
+		assert.EqualValues(t, 10, job.Progress["test_rotation_users"].Rotated)
+		assert.False(t, job.FinishedAt.IsZero())
+
+		remaining, err := db.PlanRotation(ctx, []any{(*TestRotationUser)(nil)}, "2", 3)
+		require.NoError(t, err)
+		assert.Zero(t, remaining.AffectedRows)
+	})
+
+	t.Run("pause", func(t *testing.T) {
+		assert.Error(t, db.PauseRotationJob(ctx, job.ID), "completed jobs cannot be paused")
+
+		pending, err := db.CreateRotationJob(ctx, plan)
+		require.NoError(t, err)
+		require.NoError(t, db.PauseRotationJob(ctx, pending.ID))
+
+		status, err := db.RotationJob(ctx, pending.ID)
+		require.NoError(t, err)
+		assert.Equal(t, gb.JobPaused, status.Status)
+	})
+
+	t.Run("list", func(t *testing.T) {
+		jobs, err := db.RotationJobs(ctx)
+		require.NoError(t, err)
+		require.Len(t, jobs, 2)
+		assert.Equal(t, gb.JobPaused, jobs[0].Status)
+		assert.Equal(t, gb.JobCompleted, jobs[1].Status)
+	})
+}
@@ -0,0 +1,90 @@
+// Package govault - Bun adapter key propagation tests
+package bun_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	gb "github.com/muhammadluth/govault/bun"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// keyIDs returns the key of every query constructor of a BunDB or BunTx
+func keyIDs(q interface {
+	NewInsert() *gb.BunInsertQuery
+	NewSelect() *gb.BunSelectQuery
+	NewUpdate() *gb.BunUpdateQuery
+	NewDelete() *gb.BunDeleteQuery
+	NewRaw(query string, args ...any) *gb.BunRawQuery
+}) map[string]string {
+	return map[string]string{
+		"insert": q.NewInsert().KeyID(),
+		"select": q.NewSelect().KeyID(),
+		"update": q.NewUpdate().KeyID(),
+		"delete": q.NewDelete().KeyID(),
+		"raw":    q.NewRaw("SELECT 1").KeyID(),
+	}
+}
+
+func TestBunKeyPropagation(t *testing.T) {
+	db, _, cleanup := setupTestDB(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	all := func(keyID string) map[string]string {
+		return map[string]string{"insert": keyID, "select": keyID, "update": keyID, "delete": keyID, "raw": keyID}
+	}
+	keyed := db.WithKey("2")
+
+	t.Run("db", func(t *testing.T) {
+		assert.Equal(t, "", db.KeyID())
+		assert.Equal(t, all(""), keyIDs(db))
+		assert.Equal(t, all("2"), keyIDs(keyed))
+		assert.Equal(t, all("2"), keyIDs(keyed.WithQueryHook(nil)))
+		assert.Equal(t, all("2"), keyIDs(keyed.WithNamedArg("name", "value")))
+		assert.Equal(t, all("1"), keyIDs(keyed.WithKey("1")))
+	})
+
+	t.Run("transactions", func(t *testing.T) {
+		tx, err := keyed.BeginTx(ctx, nil)
+		require.NoError(t, err)
+		assert.Equal(t, "2", tx.KeyID())
+		assert.Equal(t, all("2"), keyIDs(tx))
+		assert.Equal(t, all("1"), keyIDs(tx.WithKey("1")))
+
+		savepoint, err := tx.BeginTx(ctx, nil)
+		require.NoError(t, err)
+		assert.Equal(t, all("2"), keyIDs(savepoint))
+		require.NoError(t, savepoint.Rollback())
+		require.NoError(t, tx.Rollback())
+
+		err = keyed.RunInTx(ctx, nil, func(ctx context.Context, tx *gb.BunTx) error {
+			assert.Equal(t, all("2"), keyIDs(tx))
+			return tx.RunInTx(ctx, nil, func(ctx context.Context, tx *gb.BunTx) error {
+				assert.Equal(t, all("2"), keyIDs(tx))
+				return nil
+			})
+		})
+		require.NoError(t, err)
+	})
+
+	t.Run("insert in a keyed transaction", func(t *testing.T) {
+		user := &TestUser{Name: "Tx Key", Email: "txkey@example.com"}
+		err := keyed.RunInTx(ctx, nil, func(ctx context.Context, tx *gb.BunTx) error {
+			_, err := tx.NewInsert().Model(user).Exec(ctx)
+			return err
+		})
+		require.NoError(t, err)
+		assert.True(t, strings.HasPrefix(user.Email, "gv1:2|"))
+	})
+
+	t.Run("with key after model", func(t *testing.T) {
+		_, err := db.NewInsert().Model(&TestUser{Name: "Late Key", Email: "late@example.com"}).WithKey("2").Exec(ctx)
+		assert.ErrorContains(t, err, "called after Model")
+
+		_, err = db.NewUpdate().Model(&TestUser{ID: 1, Name: "Late Key"}).WithKey("2").WherePK().Exec(ctx)
+		assert.ErrorContains(t, err, "called after Model")
+	})
+}
@@ -0,0 +1,264 @@
+// Package govault - Bun adapter decrypted copies for offline analytics
+package bun
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/muhammadluth/govault/internal"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/schema"
+)
+
+// MaterializeOptions configures Materialize
+type MaterializeOptions struct {
+	// Target is the table receiving the copies, e.g. "analytics.users". It
+	// is created if missing; its schema must exist.
+	Target string
+	// Columns are copied besides the primary key, encrypted ones decrypted
+	Columns []string
+	// Dest is the database of Target, e.g. an isolated analytics database.
+	// Defaults to the source database.
+	Dest bun.IDB
+	// MaxRows fails the copy of a larger table, zero means no limit
+	MaxRows int64
+	// BatchSize defaults to DefaultRotationBatchSize
+	BatchSize int
+	// Purpose is recorded in the audit record, by default the purpose of
+	// the Access of ctx
+	Purpose string
+	// Audit receives the record of every copy, successful or not. It is
+	// required: decrypted copies must be accounted for.
+	Audit func(ctx context.Context, result *MaterializeResult, err error)
+}
+
+// MaterializeResult is the audit record of a copy
+type MaterializeResult struct {
+	Source    string    `json:"source"`
+	Target    string    `json:"target"`
+	Columns   []string  `json:"columns"`
+	Decrypted []string  `json:"decrypted"`
+	Purpose   string    `json:"purpose,omitempty"`
+	Actor     string    `json:"actor,omitempty"`
+	Rows      int64     `json:"rows"`
+	Started   time.Time `json:"started"`
+	Finished  time.Time `json:"finished"`
+}
+
+// Materialize replaces the contents of opts.Target with a copy of the
+// table of model whose encrypted columns are decrypted. The copy runs in
+// one transaction of the destination database, so readers of the target
+// see the previous copy until it commits.
+func (db *BunDB) Materialize(ctx context.Context, model any, opts MaterializeOptions) (*MaterializeResult, error) {
+	if opts.Audit == nil {
+		return nil, fmt.Errorf("an audit function is required to materialize decrypted copies")
+	}
+	if opts.Target == "" {
+		return nil, fmt.Errorf("target table is required")
+	}
+	if len(opts.Columns) == 0 {
+		return nil, fmt.Errorf("no columns to materialize")
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = DefaultRotationBatchSize
+	}
+	dest := opts.Dest
+	if dest == nil {
+		dest = db.DB
+	}
+
+	spec := db.govault.ModelSpec(model)
+	if spec == nil {
+		return nil, fmt.Errorf("model must be a struct, got %T", model)
+	}
+	table := db.DB.Table(spec.Type)
+	if len(table.PKs) != 1 {
+		return nil, fmt.Errorf("%s: materializing requires a single column primary key", spec.Table)
+	}
+	pk := table.PKs[0]
+
+	access := internal.AccessFromContext(ctx)
+	if opts.Purpose == "" {
+		opts.Purpose = access.Purpose
+	}
+	result := &MaterializeResult{Source: spec.Table, Target: opts.Target, Purpose: opts.Purpose, Actor: access.Actor}
+	columns := []*schema.Field{pk}
+	fields := make(map[string]*internal.FieldSpec)
+	for _, column := range opts.Columns {
+		field, ok := table.FieldMap[column]
+		if !ok {
+			return nil, fmt.Errorf("column %s not found in %s", column, spec.Table)
+		}
+		if field == pk {
+			continue
+		}
+		columns = append(columns, field)
+		for _, f := range spec.Fields {
+			if f.Column == column {
+				fields[column] = f
+				result.Decrypted = append(result.Decrypted, column)
+			}
+		}
+	}
+	for _, column := range columns {
+		result.Columns = append(result.Columns, column.Name)
+	}
+
+	result.Started = time.Now()
+	err := dest.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		// A copy into the source database reads in the same transaction
+		var src bun.IDB = db.DB
+		if opts.Dest == nil {
+			src = tx
+		}
+		return db.materialize(ctx, src, tx, spec, columns, fields, opts, result)
+	})
+	result.Finished = time.Now()
+	if err != nil {
+		err = fmt.Errorf("failed to materialize %s into %s: %w", spec.Table, opts.Target, err)
+	}
+	opts.Audit(ctx, result, err)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// materialize recreates the rows of the target within tx from src
+func (db *BunDB) materialize(ctx context.Context, src bun.IDB, tx bun.Tx, spec *internal.ModelSpec, columns []*schema.Field, fields map[string]*internal.FieldSpec, opts MaterializeOptions, result *MaterializeResult) error {
+	source, target := bun.Ident(spec.Table), bun.Ident(opts.Target)
+	if opts.MaxRows > 0 {
+		count, err := src.NewSelect().TableExpr("?", source).Count(ctx)
+		if err != nil {
+			return err
+		}
+		if int64(count) > opts.MaxRows {
+			return fmt.Errorf("%s has %d rows, more than the limit of %d", spec.Table, count, opts.MaxRows)
+		}
+	}
+
+	defs := make([]string, len(columns))
+	args := []any{target}
+	for i, column := range columns {
+		defs[i] = "? ?"
+		sqlType := column.CreateTableSQLType
+		if fields[column.Name] != nil {
+			sqlType = "TEXT"
+		}
+		if i == 0 {
+			sqlType += " PRIMARY KEY"
+		}
+		args = append(args, bun.Ident(column.Name), bun.Safe(sqlType))
+	}
+	if _, err := tx.NewRaw("CREATE TABLE IF NOT EXISTS ? ("+strings.Join(defs, ", ")+")", args...).Exec(ctx); err != nil {
+		return err
+	}
+	if _, err := tx.NewRaw("DELETE FROM ?", target).Exec(ctx); err != nil {
+		return err
+	}
+
+	pk := bun.Ident(columns[0].Name)
+	idents := make([]bun.Ident, len(columns))
+	for i, column := range columns {
+		idents[i] = bun.Ident(column.Name)
+	}
+	var after any
+	for {
+		q := src.NewSelect().
+			TableExpr("?", source).
+			OrderExpr("? ASC", pk).
+			Limit(opts.BatchSize)
+		for _, ident := range idents {
+			q = q.ColumnExpr("?", ident)
+		}
+		if after != nil {
+			q = q.Where("? > ?", pk, after)
+		}
+		batch, err := db.materializeBatch(ctx, q, spec, columns, fields)
+		if err != nil {
+			return err
+		}
+
+		result.Rows += int64(len(batch))
+		if opts.MaxRows > 0 && result.Rows > opts.MaxRows {
+			return fmt.Errorf("%s has more rows than the limit of %d", spec.Table, opts.MaxRows)
+		}
+		if len(batch) > 0 {
+			if _, err := tx.NewRaw("INSERT INTO ? (?) VALUES ?", target, bun.In(idents), bun.In(batch)).Exec(ctx); err != nil {
+				return err
+			}
+		}
+		if len(batch) < opts.BatchSize {
+			return nil
+		}
+		after = batch[len(batch)-1][0]
+	}
+}
+
+// materializeBatch reads and decrypts the rows selected by q, values in
+// the order of columns
+func (db *BunDB) materializeBatch(ctx context.Context, q *bun.SelectQuery, spec *internal.ModelSpec, columns []*schema.Field, fields map[string]*internal.FieldSpec) ([][]any, error) {
+	rows, err := q.Rows(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var batch [][]any
+	for rows.Next() {
+		values := make([]any, len(columns))
+		ptrs := make([]any, len(columns))
+		for i := range values {
+			ptrs[i] = &values[i]
+		}
+		if err := rows.Scan(ptrs...); err != nil {
+			return nil, err
+		}
+
+		for i, column := range columns {
+			value := values[i]
+			if field := fields[column.Name]; field != nil && value != nil {
+				ciphertext := fmt.Sprint(value)
+				if b, ok := value.([]byte); ok {
+					ciphertext = string(b)
+				}
+				plaintext, ok, err := db.govault.DecryptFieldStrict(field, spec.Table, ciphertext)
+				if err != nil {
+					return nil, fmt.Errorf("row %v column %s: %w", values[0], column.Name, err)
+				}
+				if !ok {
+					plaintext = ciphertext
+				}
+				values[i] = plaintext
+			}
+		}
+		batch = append(batch, values)
+	}
+	return batch, rows.Err()
+}
+
+// RunMaterializer calls Materialize every interval until ctx is done,
+// keeping the copy in sync, and passes every result or error to report,
+// which may be nil. interval must be positive.
+func (db *BunDB) RunMaterializer(ctx context.Context, interval time.Duration, model any, opts MaterializeOptions, report func(*MaterializeResult, error)) error {
+	if interval <= 0 {
+		return fmt.Errorf("interval must be positive, got %s", interval)
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		result, err := db.Materialize(ctx, model, opts)
+		if report != nil {
+			report(result, err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
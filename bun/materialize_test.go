@@ -0,0 +1,101 @@
+// Package govault - Bun adapter decrypted copy tests
+package bun_test
+
+import (
+	"context"
+	"testing"
+
+	gb "github.com/muhammadluth/govault/bun"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type materializedUser struct {
+	ID    int64  `bun:"id"`
+	Email string `bun:"email"`
+	Phone string `bun:"phone"`
+	Name  string `bun:"name"`
+}
+
+func TestBunMaterialize(t *testing.T) {
+	db, _, cleanup := setupTestDB(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	_, err := db.NewCreateTable().Model((*TestCatalogUser)(nil)).IfNotExists().Exec(ctx)
+	require.NoError(t, err)
+	defer db.NewDropTable().Model((*TestCatalogUser)(nil)).IfExists().Exec(ctx)
+	defer db.NewDropTable().Table("test_catalog_users_plain").IfExists().Exec(ctx)
+
+	want := []materializedUser{
+		{Email: "a@example.com", Phone: "+62811110000", Name: "a"},
+		{Email: "b@example.com", Phone: "+62822220000", Name: "b"},
+		{Email: "c@example.com", Phone: "+62833330000", Name: "c"},
+	}
+	for i, u := range want {
+		user := &TestCatalogUser{Email: u.Email, Phone: u.Phone, Name: u.Name}
+		_, err := db.NewInsert().Model(user).Exec(ctx)
+		require.NoError(t, err)
+		want[i].ID = user.ID
+	}
+
+	var audits []*gb.MaterializeResult
+	var auditErrs []error
+	opts := gb.MaterializeOptions{
+		Target:    "test_catalog_users_plain",
+		Columns:   []string{"email", "phone", "name"},
+		BatchSize: 2,
+		Purpose:   "weekly report",
+		Audit: func(_ context.Context, result *gb.MaterializeResult, err error) {
+			audits = append(audits, result)
+			auditErrs = append(auditErrs, err)
+		},
+	}
+	readCopy := func() []materializedUser {
+		var rows []materializedUser
+		require.NoError(t, db.DB.NewSelect().Table("test_catalog_users_plain").Order("id").Scan(ctx, &rows))
+		return rows
+	}
+
+	result, err := db.Materialize(ctx, (*TestCatalogUser)(nil), opts)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), result.Rows)
+	assert.Equal(t, []string{"id", "email", "phone", "name"}, result.Columns)
+	assert.Equal(t, []string{"email", "phone"}, result.Decrypted)
+	require.Len(t, audits, 1)
+	assert.Same(t, result, audits[0])
+	assert.Equal(t, "weekly report", audits[0].Purpose)
+
+	assert.Equal(t, want, readCopy())
+
+	// Re-syncing picks up changes and deletions
+	_, err = db.NewUpdate().Model(&TestCatalogUser{ID: want[0].ID, Email: want[0].Email, Phone: "+62844440000", Name: want[0].Name}).WherePK().Exec(ctx)
+	require.NoError(t, err)
+	_, err = db.NewDelete().Model(&TestCatalogUser{ID: want[2].ID}).WherePK().Exec(ctx)
+	require.NoError(t, err)
+	_, err = db.Materialize(ctx, (*TestCatalogUser)(nil), opts)
+	require.NoError(t, err)
+	rows := readCopy()
+	require.Len(t, rows, 2)
+	assert.Equal(t, "+62844440000", rows[0].Phone)
+
+	// A failed copy leaves the previous one and is audited
+	opts.MaxRows = 1
+	_, err = db.Materialize(ctx, (*TestCatalogUser)(nil), opts)
+	assert.ErrorContains(t, err, "more than the limit of 1")
+	require.Len(t, auditErrs, 3)
+	assert.Error(t, auditErrs[2])
+	assert.Len(t, readCopy(), 2)
+
+	opts.Audit = nil
+	_, err = db.Materialize(ctx, (*TestCatalogUser)(nil), opts)
+	assert.ErrorContains(t, err, "an audit function is required")
+
+	opts.Audit = func(context.Context, *gb.MaterializeResult, error) {}
+	opts.Columns = []string{"missing"}
+	_, err = db.Materialize(ctx, (*TestCatalogUser)(nil), opts)
+	assert.ErrorContains(t, err, "column missing not found in test_catalog_users")
+
+	err = db.RunMaterializer(ctx, 0, (*TestCatalogUser)(nil), opts, nil)
+	assert.ErrorContains(t, err, "interval must be positive")
+}
@@ -0,0 +1,483 @@
+// Package govault - Bun adapter encryption migrations
+package bun
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"go/format"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/muhammadluth/govault/internal"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect"
+	"github.com/uptrace/bun/schema"
+)
+
+// MigrationKind classifies a change of a Migration
+type MigrationKind string
+
+const (
+	// MigrationEncryptColumn encrypts the plaintext values of a column
+	// newly tagged encrypted
+	MigrationEncryptColumn MigrationKind = "encrypt_column"
+	// MigrationAddBlindIndex adds the blind index column of an encrypted
+	// column and fills it
+	MigrationAddBlindIndex MigrationKind = "add_blind_index"
+	// MigrationRotateColumn re-encrypts the values of a column with a key
+	MigrationRotateColumn MigrationKind = "rotate_column"
+)
+
+// MigrationChange is one change of the encryption of a column
+type MigrationChange struct {
+	Kind   MigrationKind `json:"kind"`
+	Table  string        `json:"table"`
+	Column string        `json:"column"`
+	// BlindIndex and BlindIndexBits describe the column added by
+	// MigrationAddBlindIndex
+	BlindIndex     string `json:"blind_index,omitempty"`
+	BlindIndexBits int    `json:"blind_index_bits,omitempty"`
+	// KeyID is the key of MigrationRotateColumn
+	KeyID string `json:"key_id,omitempty"`
+}
+
+// Migration brings the encryption of a database in line with the struct
+// tags of its models: DDL and changes of the stored values, reviewed and
+// applied together. Up runs before the changes are applied, Down after
+// they are reverted.
+type Migration struct {
+	Name    string            `json:"name"`
+	Changes []MigrationChange `json:"changes"`
+	Up      []string          `json:"up"`
+	Down    []string          `json:"down"`
+}
+
+// MigrationOptions configures PlanMigration
+type MigrationOptions struct {
+	// Name is appended to the timestamp of the migration name, e.g.
+	// encrypt_users; defaults to govault
+	Name string
+	// Rotate lists table.column pairs to re-encrypt with RotateKeyID, or
+	// the default key
+	Rotate      []string
+	RotateKeyID string
+}
+
+// migrationName matches the names bun/migrate accepts after the timestamp
+var migrationName = regexp.MustCompile(`^[0-9a-z_\-]+$`)
+
+// PlanMigration compares the tags of models with the catalog and returns
+// the migration of the columns that changed: encrypted columns missing
+// from the catalog are encrypted, and blind indexes missing from it are
+// added. Columns listed in opts.Rotate are re-encrypted. The catalog must
+// exist, see CreateCatalog; ApplyMigration records the changes in it.
+//
+// Column types are left as they are: encrypted columns must already be
+// wide enough for ciphertexts, e.g. text.
+func (db *BunDB) PlanMigration(ctx context.Context, models []any, opts MigrationOptions) (*Migration, error) {
+	if opts.Name == "" {
+		opts.Name = "govault"
+	}
+	if !migrationName.MatchString(opts.Name) {
+		return nil, fmt.Errorf("migration name %q must be lowercase letters, digits, _ and -", opts.Name)
+	}
+	catalog, err := db.Catalog(ctx)
+	if err != nil {
+		return nil, err
+	}
+	catalogByColumn := make(map[string]CatalogColumn, len(catalog))
+	for _, c := range catalog {
+		catalogByColumn[c.TableName+"."+c.ColumnName] = c
+	}
+	keyID := opts.RotateKeyID
+	if keyID == "" {
+		keyID = db.govault.GetDefaultKeyID()
+	}
+	rotate := make(map[string]bool, len(opts.Rotate))
+	for _, column := range opts.Rotate {
+		rotate[column] = true
+	}
+
+	m := &Migration{Name: time.Now().UTC().Format("20060102150405") + "_" + opts.Name}
+	for _, model := range models {
+		spec := db.govault.ModelSpec(model)
+		if spec == nil {
+			return nil, fmt.Errorf("model must be a struct, got %T", model)
+		}
+		for _, field := range spec.Fields {
+			entry, inCatalog := catalogByColumn[spec.Table+"."+field.Column]
+			if !inCatalog {
+				m.Changes = append(m.Changes, MigrationChange{Kind: MigrationEncryptColumn, Table: spec.Table, Column: field.Column})
+			}
+			if field.BlindIndex != "" && entry.BlindIndex != field.BlindIndex {
+				m.Changes = append(m.Changes, MigrationChange{
+					Kind:           MigrationAddBlindIndex,
+					Table:          spec.Table,
+					Column:         field.Column,
+					BlindIndex:     field.BlindIndex,
+					BlindIndexBits: field.BlindIndexBits,
+				})
+			}
+			if rotate[spec.Table+"."+field.Column] {
+				if field.Codec != "" {
+					return nil, fmt.Errorf("column %s.%s uses codec %s, which is not rotated", spec.Table, field.Column, field.Codec)
+				}
+				m.Changes = append(m.Changes, MigrationChange{Kind: MigrationRotateColumn, Table: spec.Table, Column: field.Column, KeyID: keyID})
+				delete(rotate, spec.Table+"."+field.Column)
+			}
+		}
+	}
+	for column := range rotate {
+		return nil, fmt.Errorf("column %s to rotate is not an encrypted field of the models", column)
+	}
+
+	for _, change := range m.Changes {
+		if change.Kind != MigrationAddBlindIndex {
+			continue
+		}
+		up, down := db.blindIndexDDL(change)
+		m.Up = append(m.Up, up...)
+		m.Down = append(down, m.Down...)
+	}
+	return m, nil
+}
+
+// blindIndexDDL returns the statements adding and dropping the blind index
+// column of change and its index
+func (db *BunDB) blindIndexDDL(change MigrationChange) (up, down []string) {
+	table, column := bun.Ident(change.Table), bun.Ident(change.BlindIndex)
+	index := bun.Ident(change.Table + "_" + change.BlindIndex + "_idx")
+	up = []string{
+		db.DB.NewRaw("ALTER TABLE ? ADD COLUMN ? VARCHAR(?)", table, column, blindIndexSize(change.BlindIndexBits)).String(),
+		db.DB.NewRaw("CREATE INDEX ? ON ? (?)", index, table, column).String(),
+	}
+	dropIndex := db.DB.NewRaw("DROP INDEX ?", index).String()
+	if db.DB.Dialect().Name() == dialect.MySQL {
+		dropIndex = db.DB.NewRaw("DROP INDEX ? ON ?", index, table).String()
+	}
+	down = []string{
+		dropIndex,
+		db.DB.NewRaw("ALTER TABLE ? DROP COLUMN ?", table, column).String(),
+	}
+	return up, down
+}
+
+// blindIndexSize returns the size of the hex blind indexes of bits
+func blindIndexSize(bits int) int {
+	return (bits + 7) / 8 * 2
+}
+
+// ApplyMigration runs the Up DDL of m, then applies its changes to the
+// values of the columns, in batches of DefaultRotationBatchSize rows, each
+// in its own transaction, and records them in the catalog. models are
+// those m was planned from; they give the encryption settings of the
+// columns. Changes may be applied again after a failure: values already
+// changed are skipped.
+func (db *BunDB) ApplyMigration(ctx context.Context, m *Migration, models ...any) error {
+	if err := checkRewriteMode(db.govault); err != nil {
+		return err
+	}
+	fields, err := db.migrationFields(m, models)
+	if err != nil {
+		return err
+	}
+	for _, stmt := range m.Up {
+		if _, err := db.DB.ExecContext(ctx, stmt); err != nil {
+			return fmt.Errorf("migration %s: %w", m.Name, err)
+		}
+	}
+
+	var catalog []CatalogColumn
+	now := time.Now()
+	for _, change := range m.Changes {
+		f := fields[change.Table+"."+change.Column]
+		switch change.Kind {
+		case MigrationEncryptColumn:
+			err = db.rewriteValues(ctx, f, func(value string) (map[string]any, error) {
+				if internal.IsFieldEncrypted(f.field, value) {
+					return nil, nil
+				}
+				plaintext, err := internal.NormalizeField(f.field, value)
+				if err != nil {
+					return nil, err
+				}
+				encrypted, err := db.govault.EncryptField(f.field, f.spec.Table, plaintext, "")
+				if err != nil {
+					return nil, err
+				}
+				return map[string]any{f.field.Column: encrypted}, nil
+			})
+		case MigrationAddBlindIndex:
+			err = db.rewriteValues(ctx, f, func(value string) (map[string]any, error) {
+				plaintext, err := db.migrationPlaintext(f, value)
+				if err != nil {
+					return nil, err
+				}
+				index, err := db.govault.BlindIndex(reflect.New(f.spec.Type).Interface(), f.field.Name, plaintext)
+				if err != nil {
+					return nil, err
+				}
+				return map[string]any{change.BlindIndex: index}, nil
+			})
+		case MigrationRotateColumn:
+			column := RotationColumnPlan{Column: f.field.Column, Deterministic: f.field.Deterministic, field: f.field}
+			err = db.rewriteValues(ctx, f, func(value string) (map[string]any, error) {
+				if !internal.IsEncrypted(value) || isOnKey(value, change.KeyID) {
+					return nil, nil
+				}
+				rotated, err := reencrypt(db.govault, value, column, change.KeyID)
+				if err != nil {
+					return nil, err
+				}
+				return map[string]any{f.field.Column: rotated}, nil
+			})
+		default:
+			err = fmt.Errorf("unknown change %q", change.Kind)
+		}
+		if err != nil {
+			return fmt.Errorf("migration %s: %s %s.%s: %w", m.Name, change.Kind, change.Table, change.Column, err)
+		}
+		catalog = append(catalog, catalogColumn(change.Table, f.field, now))
+	}
+
+	if err := syncCatalog(ctx, db.DB, catalog); err != nil {
+		return err
+	}
+	for _, change := range m.Changes {
+		if change.Kind == MigrationRotateColumn {
+			if err := db.MarkRotated(ctx, change.Table, change.Column, now); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// RevertMigration reverts the changes of m in reverse order, then runs its
+// Down DDL: encrypted columns are decrypted back to plaintext and dropped
+// from the catalog, and blind indexes dropped from it. Rotated values stay
+// on their new key, which reads as well as the old one.
+func (db *BunDB) RevertMigration(ctx context.Context, m *Migration, models ...any) error {
+	if err := checkRewriteMode(db.govault); err != nil {
+		return err
+	}
+	fields, err := db.migrationFields(m, models)
+	if err != nil {
+		return err
+	}
+
+	for i := len(m.Changes) - 1; i >= 0; i-- {
+		change := m.Changes[i]
+		f := fields[change.Table+"."+change.Column]
+		switch change.Kind {
+		case MigrationEncryptColumn:
+			err = db.rewriteValues(ctx, f, func(value string) (map[string]any, error) {
+				if !internal.IsFieldEncrypted(f.field, value) {
+					return nil, nil
+				}
+				plaintext, err := db.migrationPlaintext(f, value)
+				if err != nil {
+					return nil, err
+				}
+				return map[string]any{f.field.Column: plaintext}, nil
+			})
+			if err == nil {
+				_, err = db.DB.NewDelete().
+					Model((*CatalogColumn)(nil)).
+					Where("table_name = ?", change.Table).
+					Where("column_name = ?", change.Column).
+					Exec(ctx)
+			}
+		case MigrationAddBlindIndex:
+			_, err = db.DB.NewUpdate().
+				Model((*CatalogColumn)(nil)).
+				Set("blind_index = ''").
+				Set("blind_index_bits = 0").
+				Set("updated_at = ?", time.Now()).
+				Where("table_name = ?", change.Table).
+				Where("column_name = ?", change.Column).
+				Exec(ctx)
+		}
+		if err != nil {
+			return fmt.Errorf("migration %s: revert %s %s.%s: %w", m.Name, change.Kind, change.Table, change.Column, err)
+		}
+	}
+
+	for _, stmt := range m.Down {
+		if _, err := db.DB.ExecContext(ctx, stmt); err != nil {
+			return fmt.Errorf("migration %s: %w", m.Name, err)
+		}
+	}
+	return nil
+}
+
+// migrationField is an encrypted field changed by a migration
+type migrationField struct {
+	spec       *internal.ModelSpec
+	field      *internal.FieldSpec
+	primaryKey string
+}
+
+// migrationFields finds the fields of the changes of m among models
+func (db *BunDB) migrationFields(m *Migration, models []any) (map[string]migrationField, error) {
+	fields := make(map[string]migrationField)
+	for _, model := range models {
+		spec := db.govault.ModelSpec(model)
+		if spec == nil {
+			return nil, fmt.Errorf("model must be a struct, got %T", model)
+		}
+		for _, field := range spec.Fields {
+			fields[spec.Table+"."+field.Column] = migrationField{spec: spec, field: field}
+		}
+	}
+
+	for _, change := range m.Changes {
+		key := change.Table + "." + change.Column
+		f, ok := fields[key]
+		if !ok {
+			return nil, fmt.Errorf("migration %s: %s is not an encrypted field of the models", m.Name, key)
+		}
+		if f.field.Array != "" {
+			return nil, fmt.Errorf("migration %s: %s is not a text column", m.Name, key)
+		}
+		if f.primaryKey == "" {
+			pks := db.DB.Table(f.spec.Type).PKs
+			if len(pks) != 1 {
+				return nil, fmt.Errorf("migration %s: %s requires a single column primary key", m.Name, change.Table)
+			}
+			f.primaryKey = pks[0].Name
+			fields[key] = f
+		}
+	}
+	return fields, nil
+}
+
+// migrationPlaintext returns the plaintext of a stored value, encrypted
+// or not
+func (db *BunDB) migrationPlaintext(f migrationField, value string) (string, error) {
+	if !internal.IsFieldEncrypted(f.field, value) {
+		return value, nil
+	}
+	plaintext, _, err := db.govault.DecryptFieldStrict(f.field, f.spec.Table, value)
+	return plaintext, err
+}
+
+// rewriteValues calls change with the non-empty values of a column and
+// sets the columns it returns
+func (db *BunDB) rewriteValues(ctx context.Context, f migrationField, change func(value string) (map[string]any, error)) error {
+	_, err := db.rewriteColumns(ctx, &rewrite{
+		table:      f.spec.Table,
+		primaryKey: f.primaryKey,
+		columns:    []rewriteColumn{{name: f.field.Column, binary: f.field.Binary}},
+		where:      []schema.QueryWithArgs{nonEmpty(f.field.Column)},
+		batchSize:  DefaultRotationBatchSize,
+		row: func(pk any, values []string) (map[string]any, error) {
+			return change(values[0])
+		},
+	})
+	return err
+}
+
+// migrationTemplate is the bun/migrate Go migration of a Migration
+var migrationTemplate = template.Must(template.New("migration").Funcs(template.FuncMap{
+	"kind": func(kind MigrationKind) string {
+		switch kind {
+		case MigrationEncryptColumn:
+			return "gb.MigrationEncryptColumn"
+		case MigrationAddBlindIndex:
+			return "gb.MigrationAddBlindIndex"
+		case MigrationRotateColumn:
+			return "gb.MigrationRotateColumn"
+		}
+		return fmt.Sprintf("%q", kind)
+	},
+}).Parse(`// Code generated by govault from the struct tags of the models. Review
+// it with the models it migrates.
+//
+// It is applied by the Vault of this package, a *gb.BunDB set before the
+// migrations run, with the encryption settings of the Models of this
+// package.
+
+package {{.Package}}
+
+import (
+	"context"
+
+	gb "github.com/muhammadluth/govault/bun"
+	"github.com/uptrace/bun"
+)
+
+func init() {
+	migration := &gb.Migration{
+		Name: {{printf "%q" .Migration.Name}},
+		Changes: []gb.MigrationChange{
+{{- range .Migration.Changes}}
+			{Kind: {{kind .Kind}}, Table: {{printf "%q" .Table}}, Column: {{printf "%q" .Column}}
+				{{- if .BlindIndex}}, BlindIndex: {{printf "%q" .BlindIndex}}, BlindIndexBits: {{.BlindIndexBits}}{{end}}
+				{{- if .KeyID}}, KeyID: {{printf "%q" .KeyID}}{{end}}},
+{{- end}}
+		},
+		Up: []string{
+{{- range .Migration.Up}}
+			{{printf "%q" .}},
+{{- end}}
+		},
+		Down: []string{
+{{- range .Migration.Down}}
+			{{printf "%q" .}},
+{{- end}}
+		},
+	}
+
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		return Vault.ApplyMigration(ctx, migration, Models...)
+	}, func(ctx context.Context, db *bun.DB) error {
+		return Vault.RevertMigration(ctx, migration, Models...)
+	})
+}
+`))
+
+// GoMigration returns the source of a bun/migrate Go migration applying m,
+// in package pkg. The package declares the Migrations of bun/migrate, the
+// Vault applying them and the Models they were planned from:
+//
+//	var Migrations = migrate.NewMigrations()
+//	var Vault *gb.BunDB
+//	var Models = []any{(*User)(nil)}
+func GoMigration(pkg string, m *Migration) ([]byte, error) {
+	var buf bytes.Buffer
+	data := struct {
+		Package   string
+		Migration *Migration
+	}{pkg, m}
+	if err := migrationTemplate.Execute(&buf, data); err != nil {
+		return nil, err
+	}
+	return format.Source(buf.Bytes())
+}
+
+// WriteGoMigration writes the GoMigration of m into dir, named after m so
+// bun/migrate discovers it, and returns its path. The package is named
+// after dir.
+func WriteGoMigration(dir string, m *Migration) (string, error) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	pkg := strings.ReplaceAll(filepath.Base(abs), "-", "_")
+	src, err := GoMigration(pkg, m)
+	if err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, m.Name+".go")
+	if err := os.WriteFile(path, src, 0o644); err != nil {
+		return "", fmt.Errorf("failed to write migration: %w", err)
+	}
+	return path, nil
+}
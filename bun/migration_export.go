@@ -0,0 +1,103 @@
+// Package govault - Bun adapter migration exporters
+package bun
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// GooseMigration returns m as a goose SQL migration: its Up and Down DDL,
+// one statement each. Goose runs no Go code from SQL files, so the changes
+// of the values are listed as comments; apply them with ApplyMigration of
+// m without its DDL once the migration ran.
+func GooseMigration(m *Migration) []byte {
+	var b strings.Builder
+	b.WriteString("-- Code generated by govault from the struct tags of the models.\n")
+	writeChangeComments(&b, m)
+
+	b.WriteString("\n-- +goose Up\n")
+	writeGooseStatements(&b, m.Up)
+	b.WriteString("\n-- +goose Down\n")
+	writeGooseStatements(&b, m.Down)
+	return []byte(b.String())
+}
+
+// writeChangeComments lists the changes of the values of m as SQL comments
+func writeChangeComments(b *strings.Builder, m *Migration) {
+	if len(m.Changes) == 0 {
+		return
+	}
+	fmt.Fprintf(b, "-- Changes of the values of migration %s, applied by govault:\n", m.Name)
+	for _, change := range m.Changes {
+		fmt.Fprintf(b, "--   %s %s.%s", change.Kind, change.Table, change.Column)
+		switch change.Kind {
+		case MigrationAddBlindIndex:
+			fmt.Fprintf(b, " into %s", change.BlindIndex)
+		case MigrationRotateColumn:
+			fmt.Fprintf(b, " to key %s", change.KeyID)
+		}
+		b.WriteString("\n")
+	}
+}
+
+func writeGooseStatements(b *strings.Builder, stmts []string) {
+	for _, stmt := range stmts {
+		b.WriteString("-- +goose StatementBegin\n")
+		b.WriteString(strings.TrimSuffix(strings.TrimSpace(stmt), ";"))
+		b.WriteString(";\n-- +goose StatementEnd\n")
+	}
+}
+
+// WriteGooseMigration writes the GooseMigration of m into dir, named after
+// m so goose orders it, and returns its path
+func WriteGooseMigration(dir string, m *Migration) (string, error) {
+	path := filepath.Join(dir, m.Name+".sql")
+	if err := os.WriteFile(path, GooseMigration(m), 0o644); err != nil {
+		return "", fmt.Errorf("failed to write migration: %w", err)
+	}
+	return path, nil
+}
+
+// AtlasSchema returns the columns and indexes m adds as Atlas HCL table
+// blocks of schema, e.g. public, to merge into the desired state of the
+// tables. Atlas plans the DDL from it; the changes of the values are
+// listed as comments, apply them with ApplyMigration of m without its DDL.
+func AtlasSchema(m *Migration, schema string) []byte {
+	var b strings.Builder
+	b.WriteString("# Code generated by govault from the struct tags of the models.\n")
+	var comments strings.Builder
+	writeChangeComments(&comments, m)
+	b.WriteString(strings.ReplaceAll(comments.String(), "-- ", "# "))
+
+	var tables []string
+	columns := make(map[string][]MigrationChange)
+	for _, change := range m.Changes {
+		if change.Kind != MigrationAddBlindIndex {
+			continue
+		}
+		if _, ok := columns[change.Table]; !ok {
+			tables = append(tables, change.Table)
+		}
+		columns[change.Table] = append(columns[change.Table], change)
+	}
+
+	for _, table := range tables {
+		fmt.Fprintf(&b, "\ntable %q {\n", table)
+		fmt.Fprintf(&b, "  schema = schema.%s\n", schema)
+		for _, change := range columns[table] {
+			fmt.Fprintf(&b, "  column %q {\n", change.BlindIndex)
+			b.WriteString("    null = true\n")
+			fmt.Fprintf(&b, "    type = varchar(%d)\n", blindIndexSize(change.BlindIndexBits))
+			b.WriteString("  }\n")
+		}
+		for _, change := range columns[table] {
+			fmt.Fprintf(&b, "  index %q {\n", change.Table+"_"+change.BlindIndex+"_idx")
+			fmt.Fprintf(&b, "    columns = [column.%s]\n", change.BlindIndex)
+			b.WriteString("  }\n")
+		}
+		b.WriteString("}\n")
+	}
+	return []byte(b.String())
+}
@@ -0,0 +1,153 @@
+// Package govault - Bun adapter encryption migration tests
+package bun_test
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	gb "github.com/muhammadluth/govault/bun"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uptrace/bun"
+)
+
+type TestPlainContact struct {
+	bun.BaseModel `bun:"table:test_migrated_contacts"`
+	ID            int64  `bun:"id,pk,autoincrement"`
+	Email         string `bun:"email,type:text"`
+}
+
+type TestMigratedContact struct {
+	bun.BaseModel `bun:"table:test_migrated_contacts"`
+	ID            int64  `bun:"id,pk,autoincrement"`
+	Email         string `bun:"email,type:text" encrypted:"true" blindindex:"email_bidx"`
+	EmailBidx     string `bun:"email_bidx"`
+}
+
+func TestGoMigration(t *testing.T) {
+	m := &gb.Migration{
+		Name: "20260101000000_encrypt_contacts",
+		Changes: []gb.MigrationChange{
+			{Kind: gb.MigrationEncryptColumn, Table: "contacts", Column: "email"},
+			{Kind: gb.MigrationAddBlindIndex, Table: "contacts", Column: "email", BlindIndex: "email_bidx", BlindIndexBits: 32},
+			{Kind: gb.MigrationRotateColumn, Table: "contacts", Column: "phone", KeyID: "2"},
+		},
+		Up:   []string{`ALTER TABLE "contacts" ADD COLUMN "email_bidx" VARCHAR(8)`},
+		Down: []string{`ALTER TABLE "contacts" DROP COLUMN "email_bidx"`},
+	}
+
+	src, err := gb.GoMigration("migrations", m)
+	require.NoError(t, err)
+	code := string(src)
+	assert.True(t, strings.HasPrefix(code, "// Code generated by govault"))
+	assert.Contains(t, code, "package migrations")
+	assert.Contains(t, code, `{Kind: gb.MigrationAddBlindIndex, Table: "contacts", Column: "email", BlindIndex: "email_bidx", BlindIndexBits: 32}`)
+	assert.Contains(t, code, `{Kind: gb.MigrationRotateColumn, Table: "contacts", Column: "phone", KeyID: "2"}`)
+	assert.Contains(t, code, `"ALTER TABLE \"contacts\" ADD COLUMN \"email_bidx\" VARCHAR(8)"`)
+	assert.Contains(t, code, "Vault.ApplyMigration(ctx, migration, Models...)")
+}
+
+func TestBunMigration(t *testing.T) {
+	db, govaultDB, cleanup := setupTestDB(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	_, err := db.NewCreateTable().Model((*TestPlainContact)(nil)).IfNotExists().Exec(ctx)
+	require.NoError(t, err)
+	defer db.NewDropTable().Model((*TestPlainContact)(nil)).IfExists().Exec(ctx)
+	require.NoError(t, db.CreateCatalog(ctx))
+	defer db.NewDropTable().Model((*gb.CatalogColumn)(nil)).IfExists().Exec(ctx)
+
+	for _, email := range []string{"ada@example.com", "bob@example.com"} {
+		_, err := db.DB.NewInsert().Model(&TestPlainContact{Email: email}).Exec(ctx)
+		require.NoError(t, err)
+	}
+
+	models := []any{(*TestMigratedContact)(nil)}
+	m, err := db.PlanMigration(ctx, models, gb.MigrationOptions{Name: "encrypt_contacts"})
+	require.NoError(t, err)
+	assert.True(t, strings.HasSuffix(m.Name, "_encrypt_contacts"))
+	require.Len(t, m.Changes, 2)
+	assert.Equal(t, gb.MigrationEncryptColumn, m.Changes[0].Kind)
+	assert.Equal(t, gb.MigrationAddBlindIndex, m.Changes[1].Kind)
+	require.Len(t, m.Up, 2)
+	assert.Contains(t, m.Up[0], `ADD COLUMN "email_bidx" VARCHAR(8)`)
+
+	require.NoError(t, db.ApplyMigration(ctx, m, models...))
+
+	var raw []TestPlainContact
+	require.NoError(t, db.DB.NewSelect().Model(&raw).Order("id").Scan(ctx))
+	require.Len(t, raw, 2)
+	assert.NotEqual(t, "ada@example.com", raw[0].Email, "values are encrypted")
+
+	bidx, err := govaultDB.BlindIndex(&TestMigratedContact{}, "Email", "bob@example.com")
+	require.NoError(t, err)
+	var contact TestMigratedContact
+	err = db.NewSelect().Model(&contact).Where("email_bidx = ?", bidx).Scan(ctx, &contact)
+	require.NoError(t, err)
+	assert.Equal(t, "bob@example.com", contact.Email)
+
+	next, err := db.PlanMigration(ctx, models, gb.MigrationOptions{Rotate: []string{"test_migrated_contacts.email"}})
+	require.NoError(t, err)
+	require.Len(t, next.Changes, 1, "applied changes are in the catalog")
+	assert.Equal(t, gb.MigrationRotateColumn, next.Changes[0].Kind)
+
+	require.NoError(t, db.RevertMigration(ctx, m, models...))
+	require.NoError(t, db.DB.NewSelect().Model(&raw).Order("id").Scan(ctx))
+	assert.Equal(t, "ada@example.com", raw[0].Email, "values are decrypted back")
+	catalog, err := db.Catalog(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, catalog)
+}
+
+func TestMigrationExports(t *testing.T) {
+	m := &gb.Migration{
+		Name: "20260101000000_index_contacts",
+		Changes: []gb.MigrationChange{
+			{Kind: gb.MigrationEncryptColumn, Table: "contacts", Column: "email"},
+			{Kind: gb.MigrationAddBlindIndex, Table: "contacts", Column: "email", BlindIndex: "email_bidx", BlindIndexBits: 16},
+		},
+		Up: []string{
+			`ALTER TABLE "contacts" ADD COLUMN "email_bidx" VARCHAR(4)`,
+			`CREATE INDEX "contacts_email_bidx_idx" ON "contacts" ("email_bidx")`,
+		},
+		Down: []string{
+			`DROP INDEX "contacts_email_bidx_idx"`,
+			`ALTER TABLE "contacts" DROP COLUMN "email_bidx"`,
+		},
+	}
+
+	t.Run("goose", func(t *testing.T) {
+		sql := string(gb.GooseMigration(m))
+		assert.Contains(t, sql, "--   encrypt_column contacts.email\n")
+		assert.Contains(t, sql, "--   add_blind_index contacts.email into email_bidx\n")
+		up, down, ok := strings.Cut(sql, "-- +goose Down\n")
+		require.True(t, ok)
+		assert.Contains(t, up, "-- +goose Up\n-- +goose StatementBegin\nALTER TABLE \"contacts\" ADD COLUMN \"email_bidx\" VARCHAR(4);\n-- +goose StatementEnd\n")
+		assert.Equal(t, 2, strings.Count(down, "-- +goose StatementBegin"))
+		assert.True(t, strings.HasSuffix(down, "DROP COLUMN \"email_bidx\";\n-- +goose StatementEnd\n"))
+
+		dir := t.TempDir()
+		path, err := gb.WriteGooseMigration(dir, m)
+		require.NoError(t, err)
+		assert.Equal(t, filepath.Join(dir, "20260101000000_index_contacts.sql"), path)
+	})
+
+	t.Run("atlas", func(t *testing.T) {
+		hcl := string(gb.AtlasSchema(m, "public"))
+		assert.Contains(t, hcl, "#   encrypt_column contacts.email\n")
+		assert.Contains(t, hcl, `table "contacts" {
+  schema = schema.public
+  column "email_bidx" {
+    null = true
+    type = varchar(4)
+  }
+  index "contacts_email_bidx_idx" {
+    columns = [column.email_bidx]
+  }
+}
+`)
+	})
+}
@@ -0,0 +1,135 @@
+// Package govault - Bun adapter MySQL tests
+package bun_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/muhammadluth/govault"
+	gb "github.com/muhammadluth/govault/bun"
+	"github.com/muhammadluth/govault/govaulttest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect/mysqldialect"
+)
+
+type TestMySQLUser struct {
+	bun.BaseModel `bun:"table:test_mysql_users"`
+	ID            int64  `bun:"id,pk,autoincrement"`
+	Email         string `bun:"email,type:varchar(512)" encrypted:"true" blindindex:"email_bidx"`
+	EmailBidx     string `bun:"email_bidx,type:varchar(64)"`
+	SSN           string `bun:"ssn,type:varchar(512)" encrypted:"true" deterministic:"true"`
+}
+
+func TestBunMySQL(t *testing.T) {
+	openDB := openMySQL(t)
+	defer openDB.Close()
+
+	govaultDB, err := govault.New(govault.Config{
+		AdapterName:  govault.AdapterNameBun,
+		BunDB:        bun.NewDB(openDB, mysqldialect.New()),
+		Keys:         govaulttest.EphemeralKeys(1),
+		DefaultKeyID: "1",
+	})
+	require.NoError(t, err)
+	db := govaultDB.BunDB()
+
+	ctx := context.Background()
+	_, err = db.NewCreateTable().Model((*TestMySQLUser)(nil)).IfNotExists().Exec(ctx)
+	require.NoError(t, err)
+	defer db.NewDropTable().Model((*TestMySQLUser)(nil)).IfExists().Exec(ctx)
+
+	user := &TestMySQLUser{Email: "mysql@example.com", SSN: "123-45-6789"}
+	_, err = db.NewInsert().Model(user).Exec(ctx)
+	require.NoError(t, err)
+	require.NotZero(t, user.ID)
+
+	t.Run("stored encrypted", func(t *testing.T) {
+		var email string
+		require.NoError(t, db.DB.NewSelect().Table("test_mysql_users").Column("email").Where("id = ?", user.ID).Scan(ctx, &email))
+		assert.True(t, govault.IsEncrypted(email))
+	})
+
+	t.Run("select by blind index", func(t *testing.T) {
+		index, err := govaultDB.BlindIndex(&TestMySQLUser{}, "Email", "mysql@example.com")
+		require.NoError(t, err)
+
+		var retrieved TestMySQLUser
+		require.NoError(t, db.NewSelect().Model(&retrieved).Where("email_bidx = ?", index).Scan(ctx, &retrieved))
+		assert.Equal(t, "mysql@example.com", retrieved.Email)
+		assert.Equal(t, "123-45-6789", retrieved.SSN)
+	})
+
+	t.Run("update", func(t *testing.T) {
+		user.Email = "updated@example.com"
+		_, err := db.NewUpdate().Model(user).WherePK().Exec(ctx)
+		require.NoError(t, err)
+
+		var retrieved TestMySQLUser
+		require.NoError(t, db.NewSelect().Model(&retrieved).Where("id = ?", user.ID).Scan(ctx, &retrieved))
+		assert.Equal(t, "updated@example.com", retrieved.Email)
+	})
+}
+
+func TestBunMySQLMaintenance(t *testing.T) {
+	openDB := openMySQL(t)
+	defer openDB.Close()
+
+	govaultDB, err := govault.New(govault.Config{
+		AdapterName:  govault.AdapterNameBun,
+		BunDB:        bun.NewDB(openDB, mysqldialect.New()),
+		Keys:         govaulttest.EphemeralKeys(2),
+		DefaultKeyID: "1",
+	})
+	require.NoError(t, err)
+	db := govaultDB.BunDB()
+
+	ctx := context.Background()
+	_, err = db.NewCreateTable().Model((*TestMySQLUser)(nil)).IfNotExists().Exec(ctx)
+	require.NoError(t, err)
+	defer db.NewDropTable().Model((*TestMySQLUser)(nil)).IfExists().Exec(ctx)
+	defer db.NewDropTable().Model((*gb.CatalogColumn)(nil)).IfExists().Exec(ctx)
+	defer db.NewDropTable().Model((*gb.RotationJob)(nil)).IfExists().Exec(ctx)
+
+	for i := 0; i < 5; i++ {
+		_, err := db.NewInsert().Model(&TestMySQLUser{Email: fmt.Sprintf("user%d@example.com", i), SSN: "123-45-6789"}).Exec(ctx)
+		require.NoError(t, err)
+	}
+
+	t.Run("catalog sync upserts", func(t *testing.T) {
+		require.NoError(t, db.SyncCatalog(ctx, (*TestMySQLUser)(nil)))
+		require.NoError(t, db.SyncCatalog(ctx, (*TestMySQLUser)(nil)))
+
+		columns, err := db.Catalog(ctx)
+		require.NoError(t, err)
+		assert.Len(t, columns, 2)
+	})
+
+	t.Run("rotation job", func(t *testing.T) {
+		plan, err := db.PlanRotation(ctx, []any{(*TestMySQLUser)(nil)}, "2", 2)
+		require.NoError(t, err)
+		job, err := db.CreateRotationJob(ctx, plan)
+		require.NoError(t, err)
+
+		job, err = db.RunRotationJob(ctx, job.ID)
+		require.NoError(t, err)
+		assert.Equal(t, gb.JobCompleted, job.Status)
+		assert.EqualValues(t, 5, job.Progress["test_mysql_users"].Rotated)
+
+		verification, err := db.VerifyRotation(ctx, []any{(*TestMySQLUser)(nil)}, "1", 10)
+		require.NoError(t, err)
+		assert.True(t, verification.OK())
+	})
+
+	t.Run("drift", func(t *testing.T) {
+		issues, err := govaultDB.CheckDrift(ctx, (*TestMySQLUser)(nil))
+		require.NoError(t, err)
+		assert.Empty(t, issues)
+	})
+
+	t.Run("plaintext guard triggers", func(t *testing.T) {
+		assert.ErrorContains(t, db.InstallPlaintextGuard(ctx, (*TestMySQLUser)(nil)), "require PostgreSQL")
+	})
+}
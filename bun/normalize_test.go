@@ -0,0 +1,54 @@
+// Package govault - Bun adapter normalizer tests
+package bun_test
+
+import (
+	"context"
+	"testing"
+
+	gb "github.com/muhammadluth/govault/bun"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uptrace/bun"
+)
+
+type TestNormalizedUser struct {
+	bun.BaseModel `bun:"table:test_normalized_users,alias:n"`
+	ID            int64  `bun:"id,pk,autoincrement"`
+	Phone         string `bun:"phone" encrypted:"true" blindindex:"phone_bidx" normalize:"e164"`
+	PhoneBidx     string `bun:"phone_bidx"`
+	Token         string `bun:"token" encrypted:"true" deterministic:"true" normalize:"trim,lower"`
+}
+
+func TestBunNormalizers(t *testing.T) {
+	db, _, cleanup := setupTestDB(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	_, err := db.NewCreateTable().Model((*TestNormalizedUser)(nil)).IfNotExists().Exec(ctx)
+	require.NoError(t, err)
+	defer db.NewDropTable().Model((*TestNormalizedUser)(nil)).IfExists().Exec(ctx)
+
+	user := &TestNormalizedUser{Phone: "+62 811-1111-0000", Token: " ABC "}
+	_, err = db.NewInsert().Model(user).Exec(ctx)
+	require.NoError(t, err)
+
+	t.Run("lookups by blind index match other formats", func(t *testing.T) {
+		var got TestNormalizedUser
+		err := db.NewSelect().Model(&got).WhereQ(gb.Q("phone = {enc:phone}", "0062 (811) 1111 0000")).Scan(ctx, &got)
+		require.NoError(t, err)
+		assert.Equal(t, user.ID, got.ID)
+		assert.Equal(t, "+6281111110000", got.Phone)
+	})
+
+	t.Run("deterministic lookups match other formats", func(t *testing.T) {
+		var got TestNormalizedUser
+		err := db.NewSelect().Model(&got).WhereQ(gb.Q("token = {enc:token}", "abc")).Scan(ctx, &got)
+		require.NoError(t, err)
+		assert.Equal(t, "abc", got.Token)
+	})
+
+	t.Run("invalid values fail the write", func(t *testing.T) {
+		_, err := db.NewInsert().Model(&TestNormalizedUser{Phone: "not a phone"}).Exec(ctx)
+		assert.ErrorContains(t, err, "not a phone number")
+	})
+}
@@ -0,0 +1,90 @@
+// Package govault - Bun adapter online rotation verification
+package bun
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/muhammadluth/govault/internal"
+	"github.com/uptrace/bun"
+)
+
+// DefaultVerificationSampleSize is used when VerifyRotation gets no sample
+// size
+const DefaultVerificationSampleSize = 100
+
+// VerifyRotation checks that the encrypted columns of models no longer
+// depend on oldKeyID: it counts values still encrypted with it and decrypts
+// up to sampleSize random values per column. Pass the result to
+// OnlineRotation.Verify. When the verification passes, the catalog records
+// the rotation of the columns, see MarkRotated.
+func (db *BunDB) VerifyRotation(ctx context.Context, models []any, oldKeyID string, sampleSize int) (*internal.RotationVerification, error) {
+	if sampleSize <= 0 {
+		sampleSize = DefaultVerificationSampleSize
+	}
+
+	result := &internal.RotationVerification{}
+	rotated := make(map[string][]string)
+	var tables []string
+	for _, model := range models {
+		spec := db.govault.ModelSpec(model)
+		if spec == nil {
+			return nil, fmt.Errorf("model must be a struct, got %T", model)
+		}
+
+		for _, field := range spec.Fields {
+			// Codec ciphertexts are not keyed by govault key IDs
+			if field.Codec == "" {
+				if rotated[spec.Table] == nil {
+					tables = append(tables, spec.Table)
+				}
+				rotated[spec.Table] = append(rotated[spec.Table], field.Column)
+				var onOldKey int64
+				err := db.DB.NewSelect().
+					TableExpr("?", bun.Ident(spec.Table)).
+					ColumnExpr("count(*)").
+					Where("?", onKey(field.Column, field.Binary, oldKeyID)).
+					Scan(ctx, &onOldKey)
+				if err != nil {
+					return nil, fmt.Errorf("failed to count %s.%s on key '%s': %w", spec.Table, field.Column, oldKeyID, err)
+				}
+				result.OnOldKey += onOldKey
+			}
+
+			var values [][]byte
+			err := db.DB.NewSelect().
+				TableExpr("?", bun.Ident(spec.Table)).
+				ColumnExpr("?", bun.Ident(field.Column)).
+				Where("? IS NOT NULL", bun.Ident(field.Column)).
+				Where("? <> ''", bun.Ident(field.Column)).
+				OrderExpr(randomOrder(db.DB.Dialect().Name())).
+				Limit(sampleSize).
+				Scan(ctx, &values)
+			if err != nil {
+				return nil, fmt.Errorf("failed to sample %s.%s: %w", spec.Table, field.Column, err)
+			}
+
+			for _, stored := range values {
+				result.Sampled++
+				value, err := storedText(stored, field.Binary)
+				if err == nil {
+					_, _, err = db.govault.DecryptFieldStrict(field, spec.Table, value)
+				}
+				if err != nil {
+					result.Undecryptable++
+				}
+			}
+		}
+	}
+
+	if result.OK() {
+		now := time.Now()
+		for _, table := range tables {
+			if err := markRotated(ctx, db.DB, table, rotated[table], now); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,71 @@
+// Package govault - Bun adapter online rotation tests
+package bun_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	gb "github.com/muhammadluth/govault/bun"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBunOnlineRotation(t *testing.T) {
+	db, govaultDB, cleanup := setupTestDB(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	_, err := db.NewCreateTable().Model((*TestRotationUser)(nil)).IfNotExists().Exec(ctx)
+	require.NoError(t, err)
+	defer db.NewDropTable().Model((*TestRotationUser)(nil)).IfExists().Exec(ctx)
+
+	models := []any{(*TestRotationUser)(nil)}
+	defer db.NewDropTable().Model((*gb.CatalogColumn)(nil)).IfExists().Exec(ctx)
+	require.NoError(t, db.SyncCatalog(ctx, models...))
+
+	r, err := govaultDB.NewOnlineRotation("4")
+	require.NoError(t, err)
+	require.NoError(t, govaultDB.AddKey("4", []byte("4f1c2b7e-6d3a-4c8b-9e21-5a7d0c3f")))
+	require.NoError(t, r.DualRead())
+
+	for i := 0; i < 5; i++ {
+		_, err := db.NewInsert().Model(&TestRotationUser{
+			Email: fmt.Sprintf("user%d@example.com", i),
+			SSN:   fmt.Sprintf("000-00-000%d", i),
+		}).Exec(ctx)
+		require.NoError(t, err)
+	}
+
+	require.NoError(t, r.EncryptNew())
+
+	verification, err := db.VerifyRotation(ctx, models, r.OldKeyID, 0)
+	require.NoError(t, err)
+	assert.EqualValues(t, 10, verification.OnOldKey)
+	assert.EqualValues(t, 10, verification.Sampled)
+	assert.Error(t, r.Verify(verification))
+
+	plan, err := db.PlanRotation(ctx, models, r.NewKeyID, 2)
+	require.NoError(t, err)
+	_, err = db.ExecuteRotation(ctx, plan)
+	require.NoError(t, err)
+
+	verification, err = db.VerifyRotation(ctx, models, r.OldKeyID, 3)
+	require.NoError(t, err)
+	assert.Zero(t, verification.OnOldKey)
+	assert.Zero(t, verification.Undecryptable)
+	assert.EqualValues(t, 6, verification.Sampled)
+	require.NoError(t, r.Verify(verification))
+	require.NoError(t, r.Finalize())
+
+	catalog, err := db.Catalog(ctx)
+	require.NoError(t, err)
+	for _, column := range catalog {
+		assert.False(t, column.LastRotatedAt.IsZero(), column.ColumnName)
+	}
+
+	var users []TestRotationUser
+	require.NoError(t, db.NewSelect().Model(&users).Order("id").Scan(ctx, &users))
+	require.Len(t, users, 5)
+	assert.Equal(t, "user0@example.com", users[0].Email)
+}
@@ -0,0 +1,188 @@
+// Package govault - Bun adapter functional options tests
+package bun_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/muhammadluth/govault"
+	gb "github.com/muhammadluth/govault/bun"
+	"github.com/muhammadluth/govault/govaulttest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect/pgdialect"
+)
+
+func TestNewWithOptions(t *testing.T) {
+	openDB := openPostgres(t)
+	defer openDB.Close()
+
+	var violations []*gb.PlaintextWriteError
+	guard := gb.NewPlaintextGuard((*TestGuardUser)(nil))
+	guard.OnViolation = func(_ context.Context, err *gb.PlaintextWriteError) {
+		violations = append(violations, err)
+	}
+
+	govaultDB, err := govault.NewWithOptions(
+		govault.WithBun(bun.NewDB(openDB, pgdialect.New())),
+		govault.WithKeys(govaulttest.EphemeralKeys(2)),
+		govault.WithDefaultKey("2"),
+		govault.WithDecryptOnlyKeys("1"),
+		govault.WithHook(guard),
+	)
+	require.NoError(t, err)
+	db := govaultDB.BunDB()
+
+	ctx := context.Background()
+	_, err = db.NewCreateTable().Model((*TestGuardUser)(nil)).IfNotExists().Exec(ctx)
+	require.NoError(t, err)
+	defer db.NewDropTable().Model((*TestGuardUser)(nil)).IfExists().Exec(ctx)
+
+	t.Run("keys", func(t *testing.T) {
+		ciphertext, err := govaultDB.Encrypt("secret")
+		require.NoError(t, err)
+		keyID, err := govaultDB.GetKeyIDFromEncryptedData(ciphertext)
+		require.NoError(t, err)
+		assert.Equal(t, "2", keyID)
+
+		_, err = govaultDB.Encrypt("secret", "1")
+		assert.Error(t, err, "decrypt-only keys cannot encrypt")
+	})
+
+	t.Run("hook", func(t *testing.T) {
+		_, err := db.NewInsert().Model(&TestGuardUser{Email: "bob@example.com", Phone: "555", Name: "Bob"}).Exec(ctx)
+		require.NoError(t, err)
+		assert.Empty(t, violations)
+
+		// The hook sees queries bypassing the wrapper too
+		_, err = db.DB.NewInsert().Model(&TestGuardUser{Email: "bob@example.com", Name: "Bob"}).Exec(ctx)
+		require.ErrorIs(t, err, context.Canceled)
+		require.Len(t, violations, 1)
+		assert.Equal(t, "email", violations[0].Column)
+	})
+}
+
+func TestNewWithOptionsConfig(t *testing.T) {
+	openDB := openPostgres(t)
+	defer openDB.Close()
+
+	govaultDB, err := govault.NewWithOptions(
+		govault.WithConfig(govault.Config{
+			AdapterName:  govault.AdapterNameBun,
+			BunDB:        bun.NewDB(openDB, pgdialect.New()),
+			Keys:         govaulttest.EphemeralKeys(1),
+			DefaultKeyID: "1",
+		}),
+		govault.WithMode(govault.ModeEncryptOnly),
+	)
+	require.NoError(t, err)
+
+	ciphertext, err := govaultDB.Encrypt("secret")
+	require.NoError(t, err)
+	_, err = govaultDB.Decrypt(ciphertext)
+	assert.ErrorIs(t, err, govault.ErrOperationNotAllowed)
+}
+
+func TestDecryptOnlyMode(t *testing.T) {
+	db, _, cleanup := setupTestDB(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	user := &TestUser{Name: "Staging", Email: "staging@example.com", Phone: "+1 555 0100"}
+	_, err := db.NewInsert().Model(user).Exec(ctx)
+	require.NoError(t, err)
+
+	// A staging instance reads production data but writes no ciphertext
+	staging, err := govault.NewWithOptions(
+		govault.WithBun(db.DB),
+		govault.WithKeys(govaulttest.EphemeralKeys(3)),
+		govault.WithMode(govault.ModeDecryptOnly),
+	)
+	require.NoError(t, err)
+	sdb := staging.BunDB()
+
+	var read TestUser
+	require.NoError(t, sdb.NewSelect().Model(&read).Where("id = ?", user.ID).Scan(ctx, &read))
+	assert.Equal(t, "staging@example.com", read.Email)
+
+	_, err = sdb.NewInsert().Model(&TestUser{Name: "New", Email: "new@example.com"}).Exec(ctx)
+	assert.ErrorIs(t, err, govault.ErrOperationNotAllowed)
+	read.Email = "changed@example.com"
+	_, err = sdb.NewUpdate().Model(&read).WherePK().Exec(ctx)
+	assert.ErrorIs(t, err, govault.ErrOperationNotAllowed)
+
+	count, err := db.NewSelect().Model((*TestUser)(nil)).Count(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+
+	// Maintenance rewrites no ciphertext either
+	var before TestUser
+	require.NoError(t, db.DB.NewSelect().Model(&before).Where("id = ?", user.ID).Scan(ctx))
+	plan, err := db.PlanRotation(ctx, []any{(*TestUser)(nil)}, "2", 100)
+	require.NoError(t, err)
+	_, err = sdb.ExecuteRotation(ctx, plan)
+	assert.ErrorIs(t, err, govault.ErrOperationNotAllowed)
+	_, err = sdb.EnsureUniqueEncrypted(ctx, (*TestUser)(nil), "Email")
+	assert.ErrorIs(t, err, govault.ErrOperationNotAllowed)
+
+	var after TestUser
+	require.NoError(t, db.DB.NewSelect().Model(&after).Where("id = ?", user.ID).Scan(ctx))
+	assert.Equal(t, before.Email, after.Email)
+}
+
+func TestNewWithOptionsErrors(t *testing.T) {
+	_, err := govault.NewWithOptions(govault.WithDefaultKey("1"))
+	assert.ErrorContains(t, err, "at least one encryption key is required")
+
+	_, err = govault.NewWithOptions(
+		govault.WithKeys(govaulttest.EphemeralKeys(1)),
+		govault.WithDefaultKey("1"),
+		govault.WithHook(gb.NewPlaintextGuard()),
+	)
+	assert.ErrorContains(t, err, "query hooks require the bun adapter")
+}
+
+func TestNamedDatabases(t *testing.T) {
+	primary := openPostgres(t)
+	defer primary.Close()
+	archive := openPostgres(t)
+	defer archive.Close()
+
+	govaultDB, err := govault.NewWithOptions(
+		govault.WithBun(bun.NewDB(primary, pgdialect.New())),
+		govault.WithNamedBun("archive", bun.NewDB(archive, pgdialect.New())),
+		govault.WithKeys(govaulttest.EphemeralKeys(1)),
+		govault.WithDefaultKey("1"),
+	)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"archive"}, govaultDB.BunDBNames())
+	assert.Same(t, govaultDB.BunDB(), govaultDB.BunDBFor(""))
+	assert.Nil(t, govaultDB.BunDBFor("missing"))
+	require.NoError(t, govaultDB.HealthCheck(context.Background()))
+
+	db := govaultDB.BunDBFor("archive")
+	require.NotNil(t, db)
+	ctx := context.Background()
+	_, err = db.NewCreateTable().Model((*TestUser)(nil)).IfNotExists().Exec(ctx)
+	require.NoError(t, err)
+	defer db.NewDropTable().Model((*TestUser)(nil)).IfExists().Exec(ctx)
+
+	user := &TestUser{Name: "Archived", Email: "archived@example.com"}
+	_, err = db.NewInsert().Model(user).Exec(ctx)
+	require.NoError(t, err)
+
+	// The primary reads what the archive wrote with the shared keys
+	var read TestUser
+	require.NoError(t, govaultDB.BunDB().NewSelect().Model(&read).Where("id = ?", user.ID).Scan(ctx, &read))
+	assert.Equal(t, "archived@example.com", read.Email)
+
+	_, err = govault.New(govault.Config{
+		AdapterName:  govault.AdapterNameBun,
+		BunDB:        bun.NewDB(primary, pgdialect.New()),
+		BunDBs:       map[string]*bun.DB{"archive": nil},
+		Keys:         govaulttest.EphemeralKeys(1),
+		DefaultKeyID: "1",
+	})
+	assert.ErrorContains(t, err, "BunDB 'archive' is nil")
+}
@@ -0,0 +1,128 @@
+// Package govault - Bun adapter keyset pagination
+package bun
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/uptrace/bun"
+)
+
+// ErrEncryptedColumn is wrapped by errors of operations that need the
+// plaintext order or value of a column holding ciphertext
+var ErrEncryptedColumn = errors.New("encrypted column")
+
+// Paginate scans the page of at most limit rows following cursor into
+// dest, a pointer to a slice of models, and decrypts it. Rows are ordered
+// ascending by columns, by default the primary key; the columns must
+// identify a row and must not be encrypted. It returns the cursor of the
+// next page, empty after the last page. An empty cursor starts at the
+// first page. Paginate sets the model and order of q.
+func (q *BunSelectQuery) Paginate(ctx context.Context, dest any, cursor string, limit int, columns ...string) (string, error) {
+	if limit <= 0 {
+		return "", fmt.Errorf("limit must be positive, got %d", limit)
+	}
+	slice := reflect.ValueOf(dest)
+	if slice.Kind() != reflect.Ptr || slice.Elem().Kind() != reflect.Slice {
+		return "", fmt.Errorf("dest must be a pointer to a slice, got %T", dest)
+	}
+	slice = slice.Elem()
+
+	elemType := slice.Type().Elem()
+	for elemType.Kind() == reflect.Ptr {
+		elemType = elemType.Elem()
+	}
+	table := q.DB().Table(elemType)
+	if len(columns) == 0 {
+		for _, pk := range table.PKs {
+			columns = append(columns, pk.Name)
+		}
+		if len(columns) == 0 {
+			return "", fmt.Errorf("table %s has no primary key, pass the columns to paginate by", table.Name)
+		}
+	}
+
+	spec := q.govault.ModelSpec(dest)
+	for _, column := range columns {
+		if _, ok := table.FieldMap[column]; !ok {
+			return "", fmt.Errorf("column %s not found in %s", column, table.Name)
+		}
+		for _, field := range spec.Fields {
+			if field.Column == column {
+				return "", fmt.Errorf("cannot paginate by %s.%s, ciphertexts don't sort like plaintexts: %w", spec.Table, column, ErrEncryptedColumn)
+			}
+		}
+	}
+
+	q.SelectQuery.Model(dest)
+	idents := make([]any, len(columns))
+	for i, column := range columns {
+		idents[i] = bun.Ident(column)
+		q.SelectQuery.OrderExpr("?TableAlias.? ASC", idents[i])
+	}
+
+	if cursor != "" {
+		values, err := decodeCursor(cursor, len(columns))
+		if err != nil {
+			return "", err
+		}
+		// Row value comparison: rows after the cursor row in column order
+		placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(columns)), ", ")
+		where := fmt.Sprintf("(%s) > (%s)", strings.TrimSuffix(strings.Repeat("?TableAlias.?, ", len(columns)), ", "), placeholders)
+		q.SelectQuery.Where(where, append(idents, values...)...)
+	}
+
+	// One extra row tells whether a next page exists
+	q.SelectQuery.Limit(limit + 1)
+	if err := q.Scan(ctx, dest); err != nil {
+		return "", err
+	}
+	if slice.Len() <= limit {
+		return "", nil
+	}
+	slice.SetLen(limit)
+
+	last := reflect.Indirect(slice.Index(limit - 1))
+	values := make([]any, len(columns))
+	for i, column := range columns {
+		values[i] = table.FieldMap[column].Value(last).Interface()
+	}
+	return encodeCursor(values)
+}
+
+// encodeCursor encodes the column values of the last row of a page
+func encodeCursor(values []any) (string, error) {
+	data, err := json.Marshal(values)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode cursor: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// decodeCursor decodes a cursor returned by Paginate
+func decodeCursor(cursor string, columns int) ([]any, error) {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cursor: %w", err)
+	}
+	decoder := json.NewDecoder(strings.NewReader(string(data)))
+	decoder.UseNumber()
+	var values []any
+	if err := decoder.Decode(&values); err != nil {
+		return nil, fmt.Errorf("invalid cursor: %w", err)
+	}
+	if len(values) != columns {
+		return nil, fmt.Errorf("invalid cursor: got %d values for %d columns", len(values), columns)
+	}
+	for i, value := range values {
+		if n, ok := value.(json.Number); ok {
+			values[i] = bun.Safe(n.String())
+		}
+	}
+	return values, nil
+}
@@ -0,0 +1,84 @@
+// Package govault - Bun adapter keyset pagination tests
+package bun_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	gb "github.com/muhammadluth/govault/bun"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBunPaginate(t *testing.T) {
+	db, _, cleanup := setupTestDB(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	for i := 0; i < 7; i++ {
+		// Names repeat so that pages by name need the id as tie breaker
+		user := &TestUser{Name: fmt.Sprintf("page %d", i/2), Email: fmt.Sprintf("page%d@example.com", i)}
+		_, err := db.NewInsert().Model(user).Exec(ctx)
+		require.NoError(t, err)
+	}
+
+	t.Run("by primary key", func(t *testing.T) {
+		var emails []string
+		cursor, pages := "", 0
+		for {
+			var users []TestUser
+			next, err := db.NewSelect().Paginate(ctx, &users, cursor, 3)
+			require.NoError(t, err)
+			pages++
+			for _, u := range users {
+				emails = append(emails, u.Email)
+			}
+			if next == "" {
+				break
+			}
+			assert.Len(t, users, 3)
+			cursor = next
+		}
+		assert.Equal(t, 3, pages)
+		assert.Equal(t, []string{
+			"page0@example.com", "page1@example.com", "page2@example.com", "page3@example.com",
+			"page4@example.com", "page5@example.com", "page6@example.com",
+		}, emails)
+	})
+
+	t.Run("by plaintext columns with filter", func(t *testing.T) {
+		var users []*TestUser
+		cursor, err := db.NewSelect().Where("name <> ?", "page 0").Paginate(ctx, &users, "", 2, "name", "id")
+		require.NoError(t, err)
+		require.Len(t, users, 2)
+		assert.Equal(t, "page2@example.com", users[0].Email)
+
+		users = nil
+		cursor, err = db.NewSelect().Where("name <> ?", "page 0").Paginate(ctx, &users, cursor, 4, "name", "id")
+		require.NoError(t, err)
+		assert.Empty(t, cursor)
+		require.Len(t, users, 3)
+		assert.Equal(t, "page4@example.com", users[0].Email)
+		assert.Equal(t, "page6@example.com", users[2].Email)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		var users []TestUser
+		_, err := db.NewSelect().Paginate(ctx, &users, "", 2, "email")
+		assert.ErrorIs(t, err, gb.ErrEncryptedColumn)
+		assert.ErrorContains(t, err, "cannot paginate by test_users.email")
+
+		_, err = db.NewSelect().Paginate(ctx, &users, "", 2, "nope")
+		assert.ErrorContains(t, err, "column nope not found in test_users")
+
+		_, err = db.NewSelect().Paginate(ctx, &users, "not a cursor", 2)
+		assert.ErrorContains(t, err, "invalid cursor")
+
+		_, err = db.NewSelect().Paginate(ctx, users, "", 2)
+		assert.ErrorContains(t, err, "dest must be a pointer to a slice")
+
+		_, err = db.NewSelect().Paginate(ctx, &users, "", 0)
+		assert.ErrorContains(t, err, "limit must be positive")
+	})
+}
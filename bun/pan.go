@@ -0,0 +1,30 @@
+// Package govault - Bun adapter card number queries
+package bun
+
+import (
+	"fmt"
+
+	"github.com/uptrace/bun"
+)
+
+// WherePAN filters rows whose encrypted card number in column is pan,
+// using the token column set with the pantoken tag. The card number is
+// normalized first, so spaces and dashes may be kept. The model must be
+// set first.
+func (q *BunSelectQuery) WherePAN(column, pan string) *BunSelectQuery {
+	_, spec := q.modelSpec()
+	if spec == nil {
+		return q.Err(fmt.Errorf("WherePAN requires a model with encrypted fields"))
+	}
+	field := fieldOfColumn(spec, column)
+	if field == nil || field.PANToken == "" {
+		return q.Err(fmt.Errorf("%s.%s has no card token", spec.Table, column))
+	}
+
+	token, err := q.govault.PANToken(field, spec.Table, pan)
+	if err != nil {
+		return q.Err(err)
+	}
+	q.SelectQuery.Where("?TableAlias.? = ?", bun.Ident(field.PANToken), token)
+	return q
+}
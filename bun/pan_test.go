@@ -0,0 +1,47 @@
+// Package govault - Bun adapter card number tests
+package bun_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uptrace/bun"
+)
+
+type TestPayment struct {
+	bun.BaseModel `bun:"table:test_payments,alias:p"`
+	ID            int64  `bun:"id,pk,autoincrement"`
+	Card          string `bun:"card" encrypted:"true" pan:"true" panlast4:"card_last4" pantoken:"card_token"`
+	CardLast4     string `bun:"card_last4"`
+	CardToken     string `bun:"card_token"`
+}
+
+func TestBunWherePAN(t *testing.T) {
+	db, _, cleanup := setupTestDB(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	_, err := db.NewCreateTable().Model((*TestPayment)(nil)).IfNotExists().Exec(ctx)
+	require.NoError(t, err)
+	defer db.NewDropTable().Model((*TestPayment)(nil)).IfExists().Exec(ctx)
+
+	for _, card := range []string{"4111 1111 1111 1111", "5555555555554444"} {
+		_, err := db.NewInsert().Model(&TestPayment{Card: card}).Exec(ctx)
+		require.NoError(t, err)
+	}
+
+	var payments []TestPayment
+	err = db.NewSelect().Model(&payments).WherePAN("card", "4111-1111-1111-1111").Scan(ctx, &payments)
+	require.NoError(t, err)
+	require.Len(t, payments, 1)
+	assert.Equal(t, "4111111111111111", payments[0].Card)
+	assert.Equal(t, "1111", payments[0].CardLast4)
+
+	err = db.NewSelect().Model(&payments).WherePAN("card", "4111111111111112").Scan(ctx, &payments)
+	assert.ErrorContains(t, err, "not a card number")
+
+	_, err = db.NewInsert().Model(&TestPayment{Card: "1234"}).Exec(ctx)
+	assert.Error(t, err)
+}
@@ -4,6 +4,7 @@ package bun
 import (
 	"context"
 	"database/sql"
+	"time"
 
 	"github.com/muhammadluth/govault/internal"
 	"github.com/uptrace/bun"
@@ -13,12 +14,17 @@ import (
 // BunRawQuery wraps bun.RawQuery with encryption/decryption support
 type BunRawQuery struct {
 	*bun.RawQuery
+	conn    bun.IConn
 	govault *internal.GovaultDB
 	keyID   string
+	timeout time.Duration
+	limit   int
+	offset  int
 }
 
 func (q *BunRawQuery) Conn(db bun.IConn) *BunRawQuery {
 	q.RawQuery.Conn(db)
+	q.conn = db
 	return q
 }
 
@@ -29,6 +35,9 @@ func (q *BunRawQuery) Err(err error) *BunRawQuery {
 
 // Exec executes the raw query
 func (q *BunRawQuery) Exec(ctx context.Context, dest ...any) (sql.Result, error) {
+	ctx, cancel := withTimeout(ctx, q.timeout)
+	defer cancel()
+
 	res, err := q.RawQuery.Exec(ctx, dest...)
 	if err != nil {
 		return res, err
@@ -37,32 +46,38 @@ func (q *BunRawQuery) Exec(ctx context.Context, dest ...any) (sql.Result, error)
 	// If destinations are provided (using RETURNING), attempt to decrypt
 	if len(dest) > 0 {
 		for _, d := range dest {
-			if err := q.govault.DecryptRecursive(d); err != nil {
+			if err := q.govault.DecryptRecursiveContext(ctx, d); err != nil {
 				// We log or return error?
 				// Since query succeeded, we should probably return the error as it affects the data integrity for the caller.
 				return res, err
 			}
 		}
+		if err := q.govault.AfterDecrypt(ctx, internal.OperationRaw, dest...); err != nil {
+			return res, err
+		}
 	}
 	return res, nil
 }
 
-// Scan executes the raw query and scans results
+// Scan executes the raw query and scans results, limited to the page set
+// by Limit and Offset
 // If dest is a struct with encrypted fields, they will be decrypted
 func (q *BunRawQuery) Scan(ctx context.Context, dest ...any) error {
-	err := q.RawQuery.Scan(ctx, dest...)
+	ctx, cancel := withTimeout(ctx, q.timeout)
+	defer cancel()
+
+	err := q.page().Scan(ctx, dest...)
 	if err != nil {
 		return err
 	}
 
 	// Attempt to decrypt if dest contains encrypted fields
 	for _, d := range dest {
-		if err := q.govault.DecryptRecursive(d); err != nil {
+		if err := q.govault.DecryptRecursiveContext(ctx, d); err != nil {
 			return err
 		}
 	}
-
-	return nil
+	return q.govault.AfterDecrypt(ctx, internal.OperationRaw, dest...)
 }
 
 // Comment adds a comment to the query, wrapped by /* ... */.
@@ -89,15 +104,98 @@ func (q *BunRawQuery) WithKey(keyID string) *BunRawQuery {
 	return q
 }
 
+// KeyID returns the key of the query, empty for the default key
+func (q *BunRawQuery) KeyID() string {
+	return q.keyID
+}
+
 // EncryptValue encrypts a single value for use in raw SQL
-// Returns encrypted string in format: keyID|nonce|ciphertext
+// Returns encrypted string in format: gv1:keyID|nonce|ciphertext
 func (q *BunRawQuery) EncryptValue(plaintext string) (string, error) {
-	if plaintext == "" {
-		return "", nil
-	}
-
 	if q.keyID != "" {
 		return q.govault.Encrypt(plaintext, q.keyID)
 	}
 	return q.govault.Encrypt(plaintext)
 }
+
+// Limit limits Scan and ScanAndCount to n rows. It is appended to the
+// query, which must be a SELECT without LIMIT or OFFSET clause.
+func (q *BunRawQuery) Limit(n int) *BunRawQuery {
+	q.limit = n
+	return q
+}
+
+// Offset skips the first n rows in Scan and ScanAndCount, see Limit
+func (q *BunRawQuery) Offset(n int) *BunRawQuery {
+	q.offset = n
+	return q
+}
+
+// page returns the query scanning the page set by Limit and Offset
+func (q *BunRawQuery) page() *bun.RawQuery {
+	if q.limit <= 0 && q.offset <= 0 {
+		return q.RawQuery
+	}
+	query, args := "?", []any{q.RawQuery}
+	if q.limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, q.limit)
+	}
+	if q.offset > 0 {
+		query += " OFFSET ?"
+		args = append(args, q.offset)
+	}
+	return q.DB().NewRaw(query, args...).Conn(q.conn)
+}
+
+// Count returns the number of rows of the query, ignoring Limit and Offset
+func (q *BunRawQuery) Count(ctx context.Context) (int, error) {
+	ctx, cancel := withTimeout(ctx, q.timeout)
+	defer cancel()
+
+	var count int
+	err := q.DB().NewRaw("SELECT count(*) FROM (?) AS govault_count", q.RawQuery).Conn(q.conn).Scan(ctx, &count)
+	return count, err
+}
+
+// Exists reports whether the query returns any row
+func (q *BunRawQuery) Exists(ctx context.Context) (bool, error) {
+	ctx, cancel := withTimeout(ctx, q.timeout)
+	defer cancel()
+
+	var exists bool
+	err := q.DB().NewRaw("SELECT EXISTS (?)", q.RawQuery).Conn(q.conn).Scan(ctx, &exists)
+	return exists, err
+}
+
+// ScanAndCount scans the page set by Limit and Offset, decrypted, and
+// returns the number of rows of the whole query
+func (q *BunRawQuery) ScanAndCount(ctx context.Context, dest ...any) (int, error) {
+	if err := q.Scan(ctx, dest...); err != nil {
+		return 0, err
+	}
+	return q.Count(ctx)
+}
+
+// ScanAll scans the rows of a raw query into a slice of T, decrypted:
+//
+//	users, err := gb.ScanAll[User](ctx, db.NewRaw("SELECT * FROM users WHERE org_id = ?", orgID))
+func ScanAll[T any](ctx context.Context, q *BunRawQuery) ([]T, error) {
+	var rows []T
+	if err := q.Scan(ctx, &rows); err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+// ScanAllAndCount scans the page of a raw query set by Limit and Offset
+// into a slice of T, decrypted, and returns the number of rows of the whole
+// query
+func ScanAllAndCount[T any](ctx context.Context, q *BunRawQuery) ([]T, int, error) {
+	var rows []T
+	count, err := q.ScanAndCount(ctx, &rows)
+	if err != nil {
+		return nil, 0, err
+	}
+	return rows, count, nil
+}
@@ -5,8 +5,11 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/muhammadluth/govault"
+	gb "github.com/muhammadluth/govault/bun"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"github.com/uptrace/bun"
 )
 
 func TestBunRawQueryEncryptionDecryption(t *testing.T) {
@@ -131,7 +134,7 @@ func TestBunRawQueryEncryptionDecryption(t *testing.T) {
 		require.NoError(t, err)
 
 		// Verify key ID is in encrypted value
-		assert.True(t, strings.HasPrefix(encryptedEmail, "2|"), "Should use key 2")
+		assert.True(t, strings.HasPrefix(encryptedEmail, "gv1:2|"), "Should use key 2")
 
 		// Decrypt to verify
 		decrypted, err := govaultDB.Decrypt(encryptedEmail)
@@ -180,3 +183,108 @@ func TestBunRawExotic(t *testing.T) {
 		assert.GreaterOrEqual(t, count, 0)
 	})
 }
+
+func TestBunRawPagination(t *testing.T) {
+	db, _, cleanup := setupTestDB(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	for _, email := range []string{"page1@example.com", "page2@example.com", "page3@example.com"} {
+		_, err := db.NewInsert().Model(&TestUser{Name: "Raw Page", Email: email}).Exec(ctx)
+		require.NoError(t, err)
+	}
+	query := func() *gb.BunRawQuery {
+		return db.NewRaw("SELECT * FROM test_users WHERE name = ? ORDER BY id", "Raw Page")
+	}
+
+	count, err := query().Count(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 3, count)
+
+	exists, err := query().Exists(ctx)
+	require.NoError(t, err)
+	assert.True(t, exists)
+	exists, err = db.NewRaw("SELECT * FROM test_users WHERE name = ?", "No Page").Exists(ctx)
+	require.NoError(t, err)
+	assert.False(t, exists)
+
+	var page []TestUser
+	count, err = query().Limit(2).Offset(1).ScanAndCount(ctx, &page)
+	require.NoError(t, err)
+	assert.Equal(t, 3, count)
+	require.Len(t, page, 2)
+	assert.Equal(t, "page2@example.com", page[0].Email)
+	assert.Equal(t, "page3@example.com", page[1].Email)
+
+	users, err := gb.ScanAll[TestUser](ctx, query())
+	require.NoError(t, err)
+	require.Len(t, users, 3)
+	assert.Equal(t, "page1@example.com", users[0].Email)
+
+	pointers, count, err := gb.ScanAllAndCount[*TestUser](ctx, query().Limit(1))
+	require.NoError(t, err)
+	assert.Equal(t, 3, count)
+	require.Len(t, pointers, 1)
+	assert.Equal(t, "page1@example.com", pointers[0].Email)
+
+	// Counting runs on the connection of the query
+	tx, err := db.BeginTx(ctx, nil)
+	require.NoError(t, err)
+	defer tx.Rollback()
+	count, err = tx.NewRaw("SELECT * FROM test_users WHERE name = ?", "Raw Page").Limit(1).ScanAndCount(ctx, &page)
+	require.NoError(t, err)
+	assert.Equal(t, 3, count)
+	require.NoError(t, tx.Rollback())
+
+	_, err = gb.ScanAll[TestUser](ctx, db.NewRaw("SELECT * FROM missing_table"))
+	assert.Error(t, err)
+}
+
+type TestNamedDeterministic struct {
+	bun.BaseModel `bun:"table:test_named_deterministic"`
+	ID            int64  `bun:"id,pk,autoincrement"`
+	Email         string `bun:"email" encrypted:"true" deterministic:"true"`
+}
+
+func TestBunRawNamed(t *testing.T) {
+	db, _, cleanup := setupTestDB(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	_, err := db.NewRawNamed(
+		"INSERT INTO test_users (name, email, phone, address) VALUES (?name, ?email, ?phone, ?address)",
+		map[string]any{"name": "Named", "email": "named@example.com", "phone": "+62811110000", "address": "Jl. Named"},
+	).Exec(ctx)
+	require.NoError(t, err)
+
+	var stored struct {
+		Email   string `bun:"email"`
+		Phone   string `bun:"phone"`
+		Address string `bun:"address"`
+	}
+	require.NoError(t, db.NewRaw("SELECT email, phone, address FROM test_users WHERE name = ?", "Named").Scan(ctx, &stored))
+	assert.True(t, govault.IsEncrypted(stored.Email))
+	assert.True(t, govault.IsEncrypted(stored.Phone))
+	assert.Equal(t, "Jl. Named", stored.Address)
+
+	var user TestUser
+	require.NoError(t, db.NewRawNamed("SELECT * FROM test_users WHERE name = ?name", map[string]any{"name": "Named"}).Scan(ctx, &user))
+	assert.Equal(t, "named@example.com", user.Email)
+	assert.Equal(t, "+62811110000", user.Phone)
+
+	// Ciphertext and empty values are bound as is
+	_, err = db.NewRawNamed("UPDATE test_users SET email = ?email, phone = ?phone WHERE id = ?id",
+		map[string]any{"email": stored.Email, "phone": "", "id": user.ID}).Exec(ctx)
+	require.NoError(t, err)
+	require.NoError(t, db.NewSelect().Model(&user).WherePK().Scan(ctx, &user))
+	assert.Equal(t, "named@example.com", user.Email)
+	assert.Empty(t, user.Phone)
+
+	_, err = db.NewRawNamed("SELECT ?email", map[string]any{"email": 1}).Exec(ctx)
+	assert.ErrorContains(t, err, "parameter email of an encrypted column must be a string")
+
+	// email is randomized in test_users but deterministic here
+	require.NoError(t, db.RegisterModel((*TestNamedDeterministic)(nil)))
+	_, err = db.NewRawNamed("SELECT ?email", map[string]any{"email": "x"}).Exec(ctx)
+	assert.ErrorContains(t, err, "parameter email is encrypted differently in tables test_named_deterministic, test_users")
+}
@@ -0,0 +1,116 @@
+// Package govault - Bun adapter named raw query parameters
+package bun
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/muhammadluth/govault/internal"
+	"github.com/uptrace/bun/schema"
+)
+
+// namedParams binds the ?name placeholders of a raw query
+type namedParams map[string]any
+
+var _ schema.NamedArgAppender = namedParams(nil)
+
+func (p namedParams) AppendNamedArg(gen schema.QueryGen, b []byte, name string) ([]byte, bool) {
+	value, ok := p[name]
+	if !ok {
+		return b, false
+	}
+	return gen.AppendQuery(b, "?", value), true
+}
+
+// tableField is an encrypted field of a table
+type tableField struct {
+	table string
+	spec  *internal.FieldSpec
+}
+
+// encryptedColumns returns the encrypted fields of the models known to
+// bun by column
+func encryptedColumns(govault *internal.GovaultDB, tables *schema.Tables) map[string][]tableField {
+	columns := make(map[string][]tableField)
+	for _, table := range tables.All() {
+		spec := govault.ModelSpec(reflect.New(table.Type).Interface())
+		if spec == nil {
+			continue
+		}
+		for _, field := range spec.Fields {
+			columns[field.Column] = append(columns[field.Column], tableField{table: spec.Table, spec: field})
+		}
+	}
+	return columns
+}
+
+// sameEncryption reports whether a and b encrypt values alike, so that a
+// parameter can be encrypted for either
+func sameEncryption(a, b tableField) bool {
+	return a.spec.Codec == b.spec.Codec &&
+		(a.spec.Codec == "" || a.table == b.table) &&
+		a.spec.Deterministic == b.spec.Deterministic &&
+		a.spec.NullZero == b.spec.NullZero &&
+		a.spec.Binary == b.spec.Binary &&
+		slices.Equal(a.spec.PadBuckets, b.spec.PadBuckets)
+}
+
+// bindNamed encrypts the parameters named like the encrypted columns of the
+// models known to bun, under keyID
+func bindNamed(tables *schema.Tables, govault *internal.GovaultDB, keyID string, params map[string]any) (namedParams, error) {
+	columns := encryptedColumns(govault, tables)
+	bound := make(namedParams, len(params))
+	for name, value := range params {
+		bound[name] = value
+		fields, ok := columns[name]
+		if !ok {
+			continue
+		}
+		for _, other := range fields[1:] {
+			if !sameEncryption(fields[0], other) {
+				tableNames := make([]string, 0, len(fields))
+				for _, f := range fields {
+					tableNames = append(tableNames, f.table)
+				}
+				sort.Strings(tableNames)
+				return nil, fmt.Errorf("parameter %s is encrypted differently in tables %s, bind it with EncryptValue", name, strings.Join(tableNames, ", "))
+			}
+		}
+
+		var plaintext string
+		switch v := value.(type) {
+		case string:
+			plaintext = v
+		case *string:
+			if v == nil {
+				continue
+			}
+			plaintext = *v
+		default:
+			return nil, fmt.Errorf("parameter %s of an encrypted column must be a string, got %T", name, value)
+		}
+
+		field := fields[0]
+		if (plaintext == "" && !govault.EncryptsEmpty(field.spec)) || internal.IsFieldEncrypted(field.spec, plaintext) {
+			bound[name] = plaintext
+			continue
+		}
+		encrypted, err := govault.EncryptField(field.spec, field.table, plaintext, keyID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encrypt parameter %s: %w", name, err)
+		}
+		if field.spec.Binary {
+			data, err := internal.EncodeBinary(encrypted)
+			if err != nil {
+				return nil, fmt.Errorf("failed to encode parameter %s: %w", name, err)
+			}
+			bound[name] = data
+			continue
+		}
+		bound[name] = encrypted
+	}
+	return bound, nil
+}
@@ -0,0 +1,20 @@
+// Package govault - Bun adapter RETURNING results
+package bun
+
+import (
+	"context"
+
+	"github.com/muhammadluth/govault/internal"
+)
+
+// decryptReturning decrypts the rows returned by an insert, update or
+// delete into dest: structs, or slices of structs or struct pointers, one
+// per returned row. Exec and Scan share it so both decrypt alike.
+func decryptReturning(ctx context.Context, govault *internal.GovaultDB, operation internal.Operation, dest []any) error {
+	for _, d := range dest {
+		if err := govault.DecryptRecursiveContext(ctx, d); err != nil {
+			return err
+		}
+	}
+	return govault.AfterDecrypt(ctx, operation, dest...)
+}
@@ -0,0 +1,97 @@
+package bun_test
+
+import (
+	"context"
+	"sort"
+	"testing"
+
+	"github.com/muhammadluth/govault"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// emails returns the sorted emails of users, which are *[]TestUser or
+// *[]*TestUser
+func emails(users any) []string {
+	var result []string
+	switch users := users.(type) {
+	case *[]TestUser:
+		for _, u := range *users {
+			result = append(result, u.Email)
+		}
+	case *[]*TestUser:
+		for _, u := range *users {
+			result = append(result, u.Email)
+		}
+	}
+	sort.Strings(result)
+	return result
+}
+
+func TestBunReturningSlices(t *testing.T) {
+	db, _, cleanup := setupTestDB(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	want := []string{"ret1@example.com", "ret2@example.com", "ret3@example.com"}
+	dests := map[string]func() any{
+		"slice":         func() any { return &[]TestUser{} },
+		"pointer slice": func() any { return &[]*TestUser{} },
+	}
+
+	for name, dest := range dests {
+		t.Run(name, func(t *testing.T) {
+			_, err := db.NewDelete().Model((*TestUser)(nil)).Where("1=1").Exec(ctx)
+			require.NoError(t, err)
+
+			// Models are encrypted in place, each insert needs new ones
+			users := func() []*TestUser {
+				return []*TestUser{
+					{Name: "Returning", Email: want[0]},
+					{Name: "Returning", Email: want[1]},
+					{Name: "Returning", Email: want[2]},
+				}
+			}
+			inserted := dest()
+			first := users()
+			_, err = db.NewInsert().Model(&first).Returning("*").Exec(ctx, inserted)
+			require.NoError(t, err)
+			assert.Equal(t, want, emails(inserted), "insert Exec")
+
+			for _, scan := range []bool{false, true} {
+				updated := dest()
+				q := db.NewUpdate().
+					Model((*TestUser)(nil)).
+					Set("name = ?", "Returned").
+					Where("name LIKE ?", "Return%").
+					Returning("*")
+				if scan {
+					err = q.Scan(ctx, updated)
+				} else {
+					_, err = q.Exec(ctx, updated)
+				}
+				require.NoError(t, err)
+				assert.Equal(t, want, emails(updated), "update scan=%v", scan)
+			}
+
+			deleted := dest()
+			_, err = db.NewDelete().
+				Model((*TestUser)(nil)).
+				Where("name = ?", "Returned").
+				Returning("*").
+				Exec(ctx, deleted)
+			require.NoError(t, err)
+			assert.Equal(t, want, emails(deleted), "delete Exec")
+
+			second := users()
+			_, err = db.NewInsert().Model(&second).Exec(ctx)
+			require.NoError(t, err)
+			deleted = dest()
+			require.NoError(t, db.NewDelete().Model((*TestUser)(nil)).Where("1=1").Returning("*").Scan(ctx, deleted))
+			assert.Equal(t, want, emails(deleted), "delete Scan")
+			for _, email := range emails(deleted) {
+				assert.False(t, govault.IsEncrypted(email))
+			}
+		})
+	}
+}
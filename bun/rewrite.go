@@ -0,0 +1,213 @@
+// Package govault - Bun adapter batched rewrites of encrypted columns
+package bun
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+
+	"github.com/muhammadluth/govault/internal"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/schema"
+)
+
+// rewriteColumn is a column read by a rewrite
+type rewriteColumn struct {
+	name   string
+	binary bool
+}
+
+// rewrite walks the rows of a table in primary key order and updates them
+// with the values its row function returns. Rows are processed in batches,
+// each in its own transaction. Updates require the columns read to be
+// unchanged, so a row changed concurrently is left alone, counted as a
+// conflict and picked up by the next run.
+//
+// Values of binary columns are read and written in the text format; values
+// that fail to decode are passed on as read.
+type rewrite struct {
+	table      string
+	primaryKey string
+	columns    []rewriteColumn
+	where      []schema.QueryWithArgs
+	batchSize  int
+	// row returns the columns to set in the row with primary key pk, given
+	// the values of columns, empty for NULL. A nil value sets NULL; an
+	// empty result leaves the row.
+	row func(pk any, values []string) (map[string]any, error)
+	// afterBatch, if set, runs in the transaction of every batch
+	afterBatch func(ctx context.Context, tx bun.Tx, batch rewriteBatch) error
+	// skipped counts the values row left alone because they can't be
+	// rewritten, e.g. undecryptable ones; batches report their share
+	skipped int64
+}
+
+// rewriteBatch is the outcome of one or more batches of a rewrite. pks
+// are the primary keys of the rows of the batch not in conflict, and last
+// the last primary key read, nil when the table is done.
+type rewriteBatch struct {
+	rewritten int64
+	conflicts int64
+	skipped   int64
+	pks       []any
+	last      any
+}
+
+// checkRewriteMode fails unless govault may both decrypt and encrypt.
+// Rewrites, and so rotations, sweeps, purges and migrations, run on
+// read-write instances only: a decrypt-only one must not write
+// ciphertexts, an encrypt-only one must not read plaintexts.
+func checkRewriteMode(govault *internal.GovaultDB) error {
+	if mode := govault.Mode(); mode != internal.ModeReadWrite {
+		return fmt.Errorf("rewriting encrypted columns in %s mode: %w", mode, internal.ErrOperationNotAllowed)
+	}
+	return nil
+}
+
+// rewriteColumns runs rw over the whole table
+func (db *BunDB) rewriteColumns(ctx context.Context, rw *rewrite) (rewriteBatch, error) {
+	var total rewriteBatch
+	var after any
+	for {
+		batch, err := db.rewriteBatch(ctx, rw, after)
+		total.rewritten += batch.rewritten
+		total.conflicts += batch.conflicts
+		total.skipped += batch.skipped
+		if err != nil || batch.last == nil {
+			return total, err
+		}
+		after = batch.last
+	}
+}
+
+// rewriteBatch runs rw over up to rw.batchSize rows with a primary key
+// after the given one
+func (db *BunDB) rewriteBatch(ctx context.Context, rw *rewrite, after any) (rewriteBatch, error) {
+	if err := checkRewriteMode(db.govault); err != nil {
+		return rewriteBatch{}, err
+	}
+
+	var result rewriteBatch
+	table, pk := bun.Ident(rw.table), bun.Ident(rw.primaryKey)
+	skipped := rw.skipped
+
+	err := db.DB.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		q := tx.NewSelect().
+			TableExpr("?", table).
+			ColumnExpr("?", pk).
+			OrderExpr("? ASC", pk).
+			Limit(rw.batchSize)
+		for _, c := range rw.columns {
+			q = q.ColumnExpr("?", bun.Ident(c.name))
+		}
+		for _, where := range rw.where {
+			q = q.Where("?", where)
+		}
+		if after != nil {
+			q = q.Where("? > ?", pk, after)
+		}
+
+		rows, err := q.Rows(ctx)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		type row struct {
+			pk     any
+			stored [][]byte
+		}
+		var batch []row
+		for rows.Next() {
+			r := row{stored: make([][]byte, len(rw.columns))}
+			dest := []any{&r.pk}
+			for i := range r.stored {
+				dest = append(dest, &r.stored[i])
+			}
+			if err := rows.Scan(dest...); err != nil {
+				return err
+			}
+			batch = append(batch, r)
+		}
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		rows.Close()
+
+		for _, r := range batch {
+			values := make([]string, len(rw.columns))
+			for i, c := range rw.columns {
+				if values[i], err = storedText(r.stored[i], c.binary); err != nil {
+					values[i] = string(r.stored[i])
+				}
+			}
+			set, err := rw.row(r.pk, values)
+			if err != nil {
+				return fmt.Errorf("row %v: %w", r.pk, err)
+			}
+			if len(set) == 0 {
+				result.pks = append(result.pks, r.pk)
+				continue
+			}
+
+			upd := tx.NewUpdate().TableExpr("?", table).Where("? = ?", pk, r.pk)
+			if err := setValues(upd, rw.columns, set); err != nil {
+				return fmt.Errorf("row %v: %w", r.pk, err)
+			}
+			for i, c := range rw.columns {
+				if r.stored[i] == nil {
+					upd = upd.Where("? IS NULL", bun.Ident(c.name))
+				} else {
+					upd = upd.Where("? = ?", bun.Ident(c.name), storedArg(r.stored[i], c.binary))
+				}
+			}
+
+			res, err := upd.Exec(ctx)
+			if err != nil {
+				return err
+			}
+			if n, err := res.RowsAffected(); err == nil && n == 0 {
+				result.conflicts++
+			} else {
+				result.rewritten++
+				result.pks = append(result.pks, r.pk)
+			}
+		}
+
+		result.skipped = rw.skipped - skipped
+		if len(batch) == rw.batchSize {
+			result.last = batch[len(batch)-1].pk
+		}
+		if rw.afterBatch != nil {
+			return rw.afterBatch(ctx, tx, result)
+		}
+		return nil
+	})
+	if err != nil {
+		return rewriteBatch{}, err
+	}
+	return result, nil
+}
+
+// setValues adds to upd the SET clauses of set, storing the values of the
+// binary columns among columns in the binary format
+func setValues(upd *bun.UpdateQuery, columns []rewriteColumn, set map[string]any) error {
+	for _, column := range slices.Sorted(maps.Keys(set)) {
+		value := set[column]
+		if text, ok := value.(string); ok && slices.Contains(columns, rewriteColumn{name: column, binary: true}) {
+			data, err := storedValue(text, true)
+			if err != nil {
+				return fmt.Errorf("column %s: %w", column, err)
+			}
+			value = data
+		}
+		upd.Set("? = ?", bun.Ident(column), value)
+	}
+	return nil
+}
+
+// nonEmpty matches rows with a non-empty value in column
+func nonEmpty(column string) schema.QueryWithArgs {
+	return bun.SafeQuery("(? IS NOT NULL AND ? <> '')", bun.Ident(column), bun.Ident(column))
+}
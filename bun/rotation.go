@@ -0,0 +1,491 @@
+// Package govault - Bun adapter key rotation planning and execution
+package bun
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/muhammadluth/govault/internal"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/schema"
+)
+
+// DefaultRotationBatchSize is used when PlanRotation gets no batch size
+const DefaultRotationBatchSize = 500
+
+// rotationSampleSize is the number of values re-encrypted to calibrate
+// the runtime estimate
+const rotationSampleSize = 100
+
+// RotationPlan is the machine-readable result of PlanRotation. It holds
+// everything ExecuteRotation needs, so it can be reviewed, stored as JSON
+// and executed later.
+type RotationPlan struct {
+	TargetKeyID       string              `json:"target_key_id"`
+	BatchSize         int                 `json:"batch_size"`
+	CreatedAt         time.Time           `json:"created_at"`
+	AffectedRows      int64               `json:"affected_rows"`
+	EstimatedDuration time.Duration       `json:"estimated_duration"`
+	Tables            []RotationTablePlan `json:"tables"`
+}
+
+// RotationTablePlan is the rotation plan of one table
+type RotationTablePlan struct {
+	Table             string               `json:"table"`
+	PrimaryKey        string               `json:"primary_key"`
+	TotalRows         int64                `json:"total_rows"`
+	AffectedRows      int64                `json:"affected_rows"`
+	Batches           int64                `json:"batches"`
+	EstimatedDuration time.Duration        `json:"estimated_duration"`
+	LockImpact        RotationLockImpact   `json:"lock_impact"`
+	Columns           []RotationColumnPlan `json:"columns"`
+	// Skipped explains why the table cannot be rotated by ExecuteRotation
+	Skipped string `json:"skipped,omitempty"`
+}
+
+// RotationColumnPlan is the rotation plan of one encrypted column
+type RotationColumnPlan struct {
+	Column        string `json:"column"`
+	Deterministic bool   `json:"deterministic"`
+	// Binary is set for storage:"binary" columns, see EncodeBinary
+	Binary       bool  `json:"binary,omitempty"`
+	AffectedRows int64 `json:"affected_rows"`
+	// KeyCounts counts non-empty values per key ID; values that do not
+	// belong to a known key are counted under the empty key
+	KeyCounts map[string]int64 `json:"key_counts"`
+	// Skipped explains why the column is not rotated, e.g. codec fields
+	Skipped string `json:"skipped,omitempty"`
+
+	field *internal.FieldSpec
+}
+
+// RotationLockImpact describes the locks held while a table is rotated.
+// Rows are updated in one transaction per batch; no table locks are taken.
+type RotationLockImpact struct {
+	RowsLockedPerBatch int           `json:"rows_locked_per_batch"`
+	LockDuration       time.Duration `json:"lock_duration"`
+	TableLock          bool          `json:"table_lock"`
+}
+
+// RotationResult reports what ExecuteRotation did
+type RotationResult struct {
+	Tables   []RotationTableResult `json:"tables"`
+	Duration time.Duration         `json:"duration"`
+}
+
+// RotationTableResult reports the rotation of one table
+type RotationTableResult struct {
+	Table   string `json:"table"`
+	Rotated int64  `json:"rotated"`
+	// Conflicts counts rows changed concurrently and left for a later run
+	Conflicts int64 `json:"conflicts"`
+	// Skipped counts values left as they are because they are plaintext
+	// or fail to decrypt, e.g. corrupted ones
+	Skipped int64 `json:"skipped"`
+}
+
+// PlanRotation estimates the work of re-encrypting the encrypted columns of
+// models with targetKeyID without changing any data: affected rows per
+// table and column, projected runtime at batchSize and lock impact.
+func (db *BunDB) PlanRotation(ctx context.Context, models []any, targetKeyID string, batchSize int) (*RotationPlan, error) {
+	if !hasKey(db.govault, targetKeyID) {
+		return nil, fmt.Errorf("target key ID '%s' not found in keys", targetKeyID)
+	}
+	if batchSize <= 0 {
+		batchSize = DefaultRotationBatchSize
+	}
+
+	roundTrip, err := db.measureRoundTrip(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	plan := &RotationPlan{
+		TargetKeyID: targetKeyID,
+		BatchSize:   batchSize,
+		CreatedAt:   time.Now(),
+	}
+
+	for _, model := range models {
+		spec := db.govault.ModelSpec(model)
+		if spec == nil {
+			return nil, fmt.Errorf("model must be a struct, got %T", model)
+		}
+
+		tablePlan, err := db.planTable(ctx, spec, targetKeyID, batchSize, roundTrip)
+		if err != nil {
+			return nil, err
+		}
+		plan.Tables = append(plan.Tables, *tablePlan)
+		plan.AffectedRows += tablePlan.AffectedRows
+		plan.EstimatedDuration += tablePlan.EstimatedDuration
+	}
+
+	return plan, nil
+}
+
+// planTable counts the rows of one table that are not on the target key
+func (db *BunDB) planTable(ctx context.Context, spec *internal.ModelSpec, targetKeyID string, batchSize int, roundTrip time.Duration) (*RotationTablePlan, error) {
+	tablePlan := &RotationTablePlan{Table: spec.Table}
+
+	pks := db.DB.Table(spec.Type).PKs
+	if len(pks) == 1 {
+		tablePlan.PrimaryKey = pks[0].Name
+	} else {
+		tablePlan.Skipped = "rotation requires a single column primary key"
+	}
+
+	var rotated []RotationColumnPlan
+	for _, field := range spec.Fields {
+		columnPlan := RotationColumnPlan{Column: field.Column, Deterministic: field.Deterministic, Binary: field.Binary, field: field}
+		if field.Codec != "" {
+			columnPlan.Skipped = fmt.Sprintf("codec %s is not rotated per key", field.Codec)
+			tablePlan.Columns = append(tablePlan.Columns, columnPlan)
+			continue
+		}
+
+		counts, err := db.countKeys(ctx, spec.Table, field.Column, field.Binary)
+		if err != nil {
+			return nil, err
+		}
+		columnPlan.KeyCounts = counts
+		for keyID, n := range counts {
+			if keyID != targetKeyID {
+				columnPlan.AffectedRows += n
+			}
+		}
+		tablePlan.Columns = append(tablePlan.Columns, columnPlan)
+		rotated = append(rotated, columnPlan)
+	}
+
+	var total, affected int64
+	q := db.DB.NewSelect().
+		TableExpr("?", bun.Ident(spec.Table)).
+		ColumnExpr("count(*)")
+	if len(rotated) > 0 {
+		q = q.ColumnExpr("coalesce(sum(CASE WHEN ? THEN 1 ELSE 0 END), 0)", needsRotation(rotated, targetKeyID))
+	} else {
+		q = q.ColumnExpr("0")
+	}
+	if err := q.Scan(ctx, &total, &affected); err != nil {
+		return nil, fmt.Errorf("failed to count rows of %s: %w", spec.Table, err)
+	}
+	tablePlan.TotalRows = total
+	tablePlan.AffectedRows = affected
+	tablePlan.Batches = (affected + int64(batchSize) - 1) / int64(batchSize)
+
+	if len(rotated) == 0 || affected == 0 {
+		return tablePlan, nil
+	}
+
+	perValue, err := db.measureReencrypt(ctx, spec.Table, rotated[0], targetKeyID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Every row costs one UPDATE round trip plus the crypto for its columns;
+	// every batch adds the select and the commit.
+	perRow := roundTrip + perValue*time.Duration(len(rotated))
+	tablePlan.EstimatedDuration = time.Duration(affected)*perRow + time.Duration(tablePlan.Batches)*2*roundTrip
+
+	rowsPerBatch := batchSize
+	if affected < int64(batchSize) {
+		rowsPerBatch = int(affected)
+	}
+	tablePlan.LockImpact = RotationLockImpact{
+		RowsLockedPerBatch: rowsPerBatch,
+		LockDuration:       time.Duration(rowsPerBatch)*perRow + roundTrip,
+	}
+	return tablePlan, nil
+}
+
+// countKeys counts the non-empty values of a column per key ID
+func (db *BunDB) countKeys(ctx context.Context, table, column string, binary bool) (map[string]int64, error) {
+	keyIDs := db.govault.GetKeyIDs()
+
+	q := db.DB.NewSelect().
+		TableExpr("?", bun.Ident(table)).
+		ColumnExpr("count(*)").
+		Where("? IS NOT NULL", bun.Ident(column)).
+		Where("? <> ''", bun.Ident(column))
+	for _, keyID := range keyIDs {
+		q = q.ColumnExpr("coalesce(sum(CASE WHEN ? THEN 1 ELSE 0 END), 0)", onKey(column, binary, keyID))
+	}
+
+	dest := make([]any, len(keyIDs)+1)
+	counts := make([]int64, len(keyIDs)+1)
+	for i := range counts {
+		dest[i] = &counts[i]
+	}
+	if err := q.Scan(ctx, dest...); err != nil {
+		return nil, fmt.Errorf("failed to count keys of %s.%s: %w", table, column, err)
+	}
+
+	result := make(map[string]int64)
+	unknown := counts[0]
+	for i, keyID := range keyIDs {
+		if n := counts[i+1]; n > 0 {
+			result[keyID] = n
+			unknown -= n
+		}
+	}
+	if unknown > 0 {
+		result[""] = unknown
+	}
+	return result, nil
+}
+
+// measureRoundTrip times a trivial query to estimate the database latency
+func (db *BunDB) measureRoundTrip(ctx context.Context) (time.Duration, error) {
+	const samples = 3
+	start := time.Now()
+	for i := 0; i < samples; i++ {
+		var one int
+		if err := db.DB.NewSelect().ColumnExpr("1").Scan(ctx, &one); err != nil {
+			return 0, fmt.Errorf("failed to measure database latency: %w", err)
+		}
+	}
+	return time.Since(start) / samples, nil
+}
+
+// measureReencrypt times decrypting and re-encrypting sampled values of a
+// column and returns the average cost per value
+func (db *BunDB) measureReencrypt(ctx context.Context, table string, column RotationColumnPlan, targetKeyID string) (time.Duration, error) {
+	var values [][]byte
+	err := db.DB.NewSelect().
+		TableExpr("?", bun.Ident(table)).
+		ColumnExpr("?", bun.Ident(column.Column)).
+		Where("?", needsRotation([]RotationColumnPlan{column}, targetKeyID)).
+		Limit(rotationSampleSize).
+		Scan(ctx, &values)
+	if err != nil {
+		return 0, fmt.Errorf("failed to sample %s.%s: %w", table, column.Column, err)
+	}
+	if len(values) == 0 {
+		return 0, nil
+	}
+
+	start := time.Now()
+	for _, v := range values {
+		// Values that fail to decode or decrypt are reported by the executor
+		if text, err := storedText(v, column.Binary); err == nil {
+			_, _ = reencrypt(db.govault, text, column, targetKeyID)
+		}
+	}
+	return time.Since(start) / time.Duration(len(values)), nil
+}
+
+// ExecuteRotation re-encrypts the columns of plan with its target key in
+// batches of plan.BatchSize. Values that are plaintext or fail to decrypt
+// are skipped and counted. The catalog records the rotation of the tables
+// rotated completely, see MarkRotated.
+func (db *BunDB) ExecuteRotation(ctx context.Context, plan *RotationPlan) (*RotationResult, error) {
+	if err := checkRewriteMode(db.govault); err != nil {
+		return nil, err
+	}
+	if !hasKey(db.govault, plan.TargetKeyID) {
+		return nil, fmt.Errorf("target key ID '%s' not found in keys", plan.TargetKeyID)
+	}
+	batchSize := plan.BatchSize
+	if batchSize <= 0 {
+		batchSize = DefaultRotationBatchSize
+	}
+
+	start := time.Now()
+	result := &RotationResult{}
+	for _, tablePlan := range plan.Tables {
+		if tablePlan.Skipped != "" {
+			continue
+		}
+
+		columns := tablePlan.rotatedColumns()
+		if len(columns) == 0 {
+			continue
+		}
+
+		total, err := db.rewriteColumns(ctx, db.rotation(tablePlan, columns, plan.TargetKeyID, batchSize))
+		result.Tables = append(result.Tables, RotationTableResult{
+			Table:     tablePlan.Table,
+			Rotated:   total.rewritten,
+			Conflicts: total.conflicts,
+			Skipped:   total.skipped,
+		})
+		if err == nil && total.conflicts == 0 && total.skipped == 0 {
+			err = markRotated(ctx, db.DB, tablePlan.Table, columnNames(columns), time.Now())
+		}
+		if err != nil {
+			result.Duration = time.Since(start)
+			return result, fmt.Errorf("failed to rotate %s: %w", tablePlan.Table, err)
+		}
+	}
+
+	result.Duration = time.Since(start)
+	return result, nil
+}
+
+// rotatedColumns returns the columns ExecuteRotation re-encrypts
+func (p RotationTablePlan) rotatedColumns() []RotationColumnPlan {
+	var columns []RotationColumnPlan
+	for _, c := range p.Columns {
+		if c.Skipped == "" {
+			columns = append(columns, c)
+		}
+	}
+	return columns
+}
+
+// rotation returns the rewrite re-encrypting columns of tablePlan with
+// targetKeyID. Values that are plaintext or fail to decrypt are skipped.
+func (db *BunDB) rotation(tablePlan RotationTablePlan, columns []RotationColumnPlan, targetKeyID string, batchSize int) *rewrite {
+	rw := &rewrite{
+		table:      tablePlan.Table,
+		primaryKey: tablePlan.PrimaryKey,
+		where:      []schema.QueryWithArgs{needsRotation(columns, targetKeyID)},
+		batchSize:  batchSize,
+	}
+	for _, c := range columns {
+		rw.columns = append(rw.columns, rewriteColumn{name: c.Column, binary: c.Binary})
+	}
+	rw.row = func(pk any, values []string) (map[string]any, error) {
+		set := make(map[string]any)
+		for i, c := range columns {
+			if values[i] == "" || isOnKey(values[i], targetKeyID) {
+				continue
+			}
+			if !internal.IsEncrypted(values[i]) {
+				rw.skipped++
+				continue
+			}
+			rotated, err := reencrypt(db.govault, values[i], c, targetKeyID)
+			if err != nil && skippable(err) {
+				rw.skipped++
+				continue
+			}
+			if err != nil {
+				return nil, fmt.Errorf("column %s: %w", c.Column, err)
+			}
+			set[c.Column] = rotated
+		}
+		return set, nil
+	}
+	return rw
+}
+
+// reencrypt decrypts a native ciphertext and encrypts it with targetKeyID,
+// keeping the column's deterministic and padding settings
+func reencrypt(govault *internal.GovaultDB, ciphertext string, column RotationColumnPlan, targetKeyID string) (string, error) {
+	plaintext, err := govault.DecryptStrict(ciphertext)
+	if err != nil {
+		return "", err
+	}
+	field := column.field
+	if field == nil {
+		field = &internal.FieldSpec{Column: column.Column, Deterministic: column.Deterministic}
+	}
+	return govault.EncryptField(field, "", plaintext, targetKeyID)
+}
+
+// skippable reports whether reencrypt failed on the value rather than on
+// the vault, e.g. a value that is corrupted or of an unknown key
+func skippable(err error) bool {
+	for _, target := range []error{
+		internal.ErrKeyUnavailable, internal.ErrRateLimited, internal.ErrOperationNotAllowed,
+		internal.ErrDecryptBudgetExceeded, context.Canceled, context.DeadlineExceeded,
+	} {
+		if errors.Is(err, target) {
+			return false
+		}
+	}
+	return true
+}
+
+// columnNames returns the column names of columns
+func columnNames(columns []RotationColumnPlan) []string {
+	names := make([]string, len(columns))
+	for i, c := range columns {
+		names[i] = c.Column
+	}
+	return names
+}
+
+// needsRotation matches rows with a non-empty value of any column that is
+// not encrypted with keyID
+func needsRotation(columns []RotationColumnPlan, keyID string) schema.QueryWithArgs {
+	conds := make([]any, 0, len(columns))
+	for _, c := range columns {
+		current := []any{
+			hasPrefix(c.Column, c.Binary, internal.FormatPrefix+keyID+"|"),
+			hasPrefix(c.Column, c.Binary, internal.HPKEFormatPrefix+keyID+"|"),
+		}
+		if c.Binary {
+			current = append(current, onBinaryKey(c.Column, keyID))
+		}
+		conds = append(conds, bun.SafeQuery("(? IS NOT NULL AND ? <> '' AND NOT ?)",
+			bun.Ident(c.Column), bun.Ident(c.Column), anyOf(current)))
+	}
+	return anyOf(conds)
+}
+
+// onKey matches values of column encrypted with keyID, in the current,
+// HPKE or legacy unprefixed format, or in the binary format when the
+// column is binary
+func onKey(column string, binary bool, keyID string) schema.QueryWithArgs {
+	conds := []any{
+		hasPrefix(column, binary, internal.FormatPrefix+keyID+"|"),
+		hasPrefix(column, binary, internal.HPKEFormatPrefix+keyID+"|"),
+		hasPrefix(column, binary, keyID+"|"),
+	}
+	if binary {
+		conds = append(conds, onBinaryKey(column, keyID))
+	}
+	return anyOf(conds)
+}
+
+// hasPrefix matches the values of column starting with prefix: with LIKE
+// in text columns, bytewise in binary ones
+func hasPrefix(column string, binary bool, prefix string) schema.QueryWithArgs {
+	if binary {
+		return bun.SafeQuery("substr(?, 1, ?) = ?", bun.Ident(column), len(prefix), []byte(prefix))
+	}
+	return bun.SafeQuery("? LIKE ? ESCAPE '!'", bun.Ident(column), likeEscape(prefix)+"%")
+}
+
+// onBinaryKey matches the values of a binary column that EncodeBinary
+// wrote for ciphertexts of keyID, whatever their flags
+func onBinaryKey(column, keyID string) schema.QueryWithArgs {
+	header, key := internal.BinaryKeyPrefix(keyID)
+	return bun.SafeQuery("(substr(?, 1, ?) = ? AND substr(?, ?, ?) = ?)",
+		bun.Ident(column), len(header), header,
+		bun.Ident(column), len(header)+2, len(key), key)
+}
+
+// anyOf joins conds with OR
+func anyOf(conds []any) schema.QueryWithArgs {
+	return bun.SafeQuery("("+strings.TrimSuffix(strings.Repeat("? OR ", len(conds)), " OR ")+")", conds...)
+}
+
+// isOnKey reports whether ciphertext is in the current or HPKE format with
+// keyID
+func isOnKey(ciphertext, keyID string) bool {
+	return strings.HasPrefix(ciphertext, internal.FormatPrefix+keyID+"|") ||
+		strings.HasPrefix(ciphertext, internal.HPKEFormatPrefix+keyID+"|")
+}
+
+func hasKey(govault *internal.GovaultDB, keyID string) bool {
+	for _, id := range govault.GetKeyIDs() {
+		if id == keyID {
+			return true
+		}
+	}
+	return false
+}
+
+// likeEscape escapes the wildcards of s for LIKE ... ESCAPE '!'. The
+// escape character is not a backslash, which MySQL also reads as escaping
+// the closing quote of the ESCAPE literal.
+func likeEscape(s string) string {
+	return strings.NewReplacer(`!`, `!!`, `%`, `!%`, `_`, `!_`).Replace(s)
+}
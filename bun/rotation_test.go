@@ -0,0 +1,183 @@
+// Package govault - Bun adapter key rotation tests
+package bun_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/muhammadluth/govault"
+	gb "github.com/muhammadluth/govault/bun"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uptrace/bun"
+)
+
+type TestRotationUser struct {
+	bun.BaseModel `bun:"table:test_rotation_users"`
+	ID            int64  `bun:"id,pk,autoincrement"`
+	Email         string `bun:"email" encrypted:"true"`
+	SSN           string `bun:"ssn" encrypted:"true" deterministic:"true"`
+	Name          string `bun:"name"`
+}
+
+func TestBunRotation(t *testing.T) {
+	db, govaultDB, cleanup := setupTestDB(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	_, err := db.NewCreateTable().Model((*TestRotationUser)(nil)).IfNotExists().Exec(ctx)
+	require.NoError(t, err)
+	defer db.NewDropTable().Model((*TestRotationUser)(nil)).IfExists().Exec(ctx)
+
+	for i := 0; i < 7; i++ {
+		_, err := db.WithKey("1").NewInsert().Model(&TestRotationUser{
+			Email: fmt.Sprintf("user%d@example.com", i),
+			SSN:   fmt.Sprintf("000-00-000%d", i),
+		}).Exec(ctx)
+		require.NoError(t, err)
+	}
+	_, err = db.WithKey("2").NewInsert().Model(&TestRotationUser{Email: "already@example.com"}).Exec(ctx)
+	require.NoError(t, err)
+	_, err = db.NewInsert().Model(&TestRotationUser{Name: "no secrets"}).Exec(ctx)
+	require.NoError(t, err)
+
+	models := []any{(*TestRotationUser)(nil)}
+
+	t.Run("plan", func(t *testing.T) {
+		plan, err := db.PlanRotation(ctx, models, "2", 3)
+		require.NoError(t, err)
+
+		require.Len(t, plan.Tables, 1)
+		table := plan.Tables[0]
+		assert.Equal(t, "test_rotation_users", table.Table)
+		assert.Equal(t, "id", table.PrimaryKey)
+		assert.EqualValues(t, 9, table.TotalRows)
+		assert.EqualValues(t, 7, table.AffectedRows)
+		assert.EqualValues(t, 3, table.Batches)
+		assert.Equal(t, 3, table.LockImpact.RowsLockedPerBatch)
+		assert.False(t, table.LockImpact.TableLock)
+		assert.Positive(t, plan.EstimatedDuration)
+
+		require.Len(t, table.Columns, 2)
+		assert.Equal(t, map[string]int64{"1": 7, "2": 1}, table.Columns[0].KeyCounts)
+		assert.EqualValues(t, 7, table.Columns[0].AffectedRows)
+		assert.True(t, table.Columns[1].Deterministic)
+
+		// The plan survives a JSON round trip
+		data, err := json.Marshal(plan)
+		require.NoError(t, err)
+		var decoded gb.RotationPlan
+		require.NoError(t, json.Unmarshal(data, &decoded))
+		assert.Equal(t, plan.Tables, decoded.Tables)
+	})
+
+	t.Run("unknown target key", func(t *testing.T) {
+		_, err := db.PlanRotation(ctx, models, "missing", 3)
+		assert.Error(t, err)
+	})
+
+	t.Run("execute", func(t *testing.T) {
+		plan, err := db.PlanRotation(ctx, models, "2", 3)
+		require.NoError(t, err)
+
+		result, err := db.ExecuteRotation(ctx, plan)
+		require.NoError(t, err)
+		require.Len(t, result.Tables, 1)
+		assert.EqualValues(t, 7, result.Tables[0].Rotated)
+		assert.Zero(t, result.Tables[0].Conflicts)
+
+		plan, err = db.PlanRotation(ctx, models, "2", 3)
+		require.NoError(t, err)
+		assert.Zero(t, plan.AffectedRows)
+
+		var users []TestRotationUser
+		err = db.NewSelect().Model(&users).Order("id").Scan(ctx, &users)
+		require.NoError(t, err)
+		assert.Equal(t, "user3@example.com", users[3].Email)
+		assert.Equal(t, "000-00-0003", users[3].SSN)
+
+		// Deterministic columns stay searchable with the new key
+		lookup, err := govaultDB.EncryptField(&govault.FieldSpec{Deterministic: true}, "", "000-00-0003", "2")
+		require.NoError(t, err)
+		var found TestRotationUser
+		err = db.NewSelect().Model(&found).Where("ssn = ?", lookup).Scan(ctx, &found)
+		require.NoError(t, err)
+		assert.Equal(t, users[3].ID, found.ID)
+	})
+}
+
+type TestRotationAccount struct {
+	bun.BaseModel `bun:"table:test_rotation_accounts"`
+	ID            string `bun:"id,pk"`
+	Email         string `bun:"email" encrypted:"true"`
+}
+
+func TestBunRotationTextKeys(t *testing.T) {
+	db, _, cleanup := setupTestDB(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	_, err := db.NewCreateTable().Model((*TestRotationAccount)(nil)).IfNotExists().Exec(ctx)
+	require.NoError(t, err)
+	defer db.NewDropTable().Model((*TestRotationAccount)(nil)).IfExists().Exec(ctx)
+	defer db.NewDropTable().Model((*gb.RotationJob)(nil)).IfExists().Exec(ctx)
+	defer db.NewDropTable().Model((*gb.CatalogColumn)(nil)).IfExists().Exec(ctx)
+
+	models := []any{(*TestRotationAccount)(nil)}
+	require.NoError(t, db.SyncCatalog(ctx, models...))
+
+	for i := 0; i < 7; i++ {
+		_, err := db.WithKey("1").NewInsert().Model(&TestRotationAccount{
+			ID:    fmt.Sprintf("%08x-0000-4000-8000-%012x", 7-i, i),
+			Email: fmt.Sprintf("user%d@example.com", i),
+		}).Exec(ctx)
+		require.NoError(t, err)
+	}
+	// Rows written around govault: plaintext and a value of an unknown key
+	_, err = db.DB.NewRaw("INSERT INTO test_rotation_accounts (id, email) VALUES (?, ?), (?, ?)",
+		"plain", "plain@example.com", "unknown", "gv1:9|AAAAAAAAAAAAAAAA|AAAAAAAAAAAAAAAAAAAAAAAA").Exec(ctx)
+	require.NoError(t, err)
+
+	rotatedAt := func() time.Time {
+		var column gb.CatalogColumn
+		err := db.DB.NewSelect().Model(&column).Where("table_name = ?", "test_rotation_accounts").Scan(ctx)
+		require.NoError(t, err)
+		return column.LastRotatedAt
+	}
+
+	t.Run("job walks text keys and skips bad values", func(t *testing.T) {
+		plan, err := db.PlanRotation(ctx, models, "2", 3)
+		require.NoError(t, err)
+		job, err := db.CreateRotationJob(ctx, plan)
+		require.NoError(t, err)
+
+		job, err = db.RunRotationJob(ctx, job.ID)
+		require.NoError(t, err)
+		assert.Equal(t, gb.JobCompleted, job.Status)
+		progress := job.Progress["test_rotation_accounts"]
+		assert.EqualValues(t, 7, progress.Rotated)
+		assert.EqualValues(t, 2, progress.Skipped)
+		assert.True(t, rotatedAt().IsZero(), "a rotation that skipped values is not recorded")
+	})
+
+	t.Run("complete rotation is recorded", func(t *testing.T) {
+		_, err := db.DB.NewDelete().TableExpr("test_rotation_accounts").Where("id IN (?)", bun.In([]string{"plain", "unknown"})).Exec(ctx)
+		require.NoError(t, err)
+
+		plan, err := db.PlanRotation(ctx, models, "1", 3)
+		require.NoError(t, err)
+		result, err := db.ExecuteRotation(ctx, plan)
+		require.NoError(t, err)
+		assert.EqualValues(t, 7, result.Tables[0].Rotated)
+		assert.Zero(t, result.Tables[0].Skipped)
+		assert.False(t, rotatedAt().IsZero())
+
+		var accounts []TestRotationAccount
+		require.NoError(t, db.NewSelect().Model(&accounts).Order("id").Scan(ctx, &accounts))
+		require.Len(t, accounts, 7)
+		assert.Equal(t, "user6@example.com", accounts[0].Email)
+	})
+}
@@ -0,0 +1,34 @@
+// Package govault - Bun adapter selection of non-encrypted columns
+package bun
+
+import (
+	"errors"
+	"slices"
+)
+
+// SelectSafe selects the columns of the model except its encrypted ones,
+// so that endpoints opt in to PII rather than receive it by default.
+// Encrypted columns named in include, or added later with Column, are
+// selected. Call it after Model; the excluded fields keep their zero value.
+func (q *BunSelectQuery) SelectSafe(include ...string) *BunSelectQuery {
+	table, spec := q.modelSpec()
+	if table == nil {
+		if q.GetModel() == nil {
+			return q.Err(errors.New("SelectSafe requires a model"))
+		}
+		// Models without encrypted columns are safe as they are
+		return q
+	}
+
+	var exclude []string
+	for _, field := range spec.Fields {
+		if _, ok := table.FieldMap[field.Column]; !ok || slices.Contains(include, field.Column) {
+			continue
+		}
+		exclude = append(exclude, field.Column)
+	}
+	if len(exclude) == 0 {
+		return q
+	}
+	return q.ExcludeColumn(exclude...)
+}
@@ -0,0 +1,45 @@
+// Package govault - Bun adapter safe select tests
+package bun_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSelectSafe(t *testing.T) {
+	db, _, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	ctx := context.Background()
+
+	user := &TestUser{Name: "Jane", Email: "jane@example.com", Phone: "+62812345678", Address: "Jakarta"}
+	_, err := db.NewInsert().Model(user).Exec(ctx)
+	require.NoError(t, err)
+
+	t.Run("encrypted columns are excluded", func(t *testing.T) {
+		var retrieved TestUser
+		err := db.NewSelect().Model(&retrieved).SelectSafe().Where("id = ?", user.ID).Scan(ctx, &retrieved)
+		require.NoError(t, err)
+		assert.Equal(t, "Jane", retrieved.Name)
+		assert.Equal(t, "Jakarta", retrieved.Address)
+		assert.Empty(t, retrieved.Email)
+		assert.Empty(t, retrieved.Phone)
+	})
+
+	t.Run("included columns are selected and decrypted", func(t *testing.T) {
+		var retrieved TestUser
+		err := db.NewSelect().Model(&retrieved).SelectSafe("email").Where("id = ?", user.ID).Scan(ctx, &retrieved)
+		require.NoError(t, err)
+		assert.Equal(t, "jane@example.com", retrieved.Email)
+		assert.Empty(t, retrieved.Phone)
+	})
+
+	t.Run("a model is required", func(t *testing.T) {
+		var name string
+		err := db.NewSelect().SelectSafe().Table("test_users").Column("name").Scan(ctx, &name)
+		assert.ErrorContains(t, err, "requires a model")
+	})
+}
@@ -0,0 +1,96 @@
+// Package govault - Bun adapter schema bridge
+package bun
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+
+	"github.com/muhammadluth/govault/internal"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect"
+	"github.com/uptrace/bun/schema"
+)
+
+// bridgedFields records the bun fields that already carry govault hooks
+var bridgedFields sync.Map // map[*schema.Field]struct{}
+
+// RegisterEncryptedModels installs govault append/scan hooks on the
+// encrypted fields of models in the bun schema of db
+func RegisterEncryptedModels(db *bun.DB, govault *internal.GovaultDB, models ...any) error {
+	for _, model := range models {
+		spec := govault.ModelSpec(model)
+		if spec == nil {
+			return fmt.Errorf("model must be a struct, got %T", model)
+		}
+		// Encryption runs before the binary conversion, decryption after
+		bindBinaryFields(db, govault, model)
+
+		table := db.Table(spec.Type)
+		for _, fieldSpec := range spec.Fields {
+			field, ok := table.FieldMap[fieldSpec.Column]
+			if !ok || field.IndirectType.Kind() != reflect.String {
+				continue
+			}
+			if _, loaded := bridgedFields.LoadOrStore(field, struct{}{}); loaded {
+				continue
+			}
+			field.Append = encryptingAppender(govault, fieldSpec, spec.Table, field.Append)
+			field.Scan = decryptingScanner(govault, fieldSpec, spec.Table, field.Scan)
+		}
+	}
+	return nil
+}
+
+// encryptingAppender normalizes and encrypts plaintext values before
+// appending them. Values that are already ciphertext, e.g. from wrapper
+// queries, and nil pointers are appended unchanged.
+func encryptingAppender(govault *internal.GovaultDB, fieldSpec *internal.FieldSpec, table string, next schema.AppenderFunc) schema.AppenderFunc {
+	return func(gen schema.QueryGen, b []byte, v reflect.Value) []byte {
+		if v.Kind() == reflect.Pointer && v.IsNil() {
+			return next(gen, b, v)
+		}
+		plaintext := reflect.Indirect(v).String()
+		if (plaintext == "" && !govault.EncryptsEmpty(fieldSpec)) || internal.IsFieldEncrypted(fieldSpec, plaintext) {
+			return next(gen, b, v)
+		}
+
+		plaintext, err := internal.NormalizeField(fieldSpec, plaintext)
+		if err != nil {
+			return dialect.AppendError(b, fmt.Errorf("failed to normalize field %s: %w", fieldSpec.Name, err))
+		}
+		encrypted, err := govault.EncryptField(fieldSpec, table, plaintext, "")
+		if err != nil {
+			return dialect.AppendError(b, fmt.Errorf("failed to encrypt field %s: %w", fieldSpec.Name, err))
+		}
+		if v.Kind() == reflect.Pointer {
+			ptr := reflect.New(v.Type().Elem())
+			ptr.Elem().SetString(encrypted)
+			return next(gen, b, ptr)
+		}
+		return next(gen, b, reflect.ValueOf(encrypted).Convert(v.Type()))
+	}
+}
+
+// decryptingScanner decrypts ciphertext after the default scanner ran
+func decryptingScanner(govault *internal.GovaultDB, fieldSpec *internal.FieldSpec, table string, next schema.ScannerFunc) schema.ScannerFunc {
+	return func(dest reflect.Value, src any) error {
+		if err := next(dest, src); err != nil {
+			return err
+		}
+
+		target := reflect.Indirect(dest)
+		if target.Kind() != reflect.String {
+			return nil
+		}
+
+		decrypted, ok, err := govault.DecryptField(fieldSpec, table, target.String())
+		if err != nil {
+			return fmt.Errorf("failed to decrypt field %s: %w", fieldSpec.Name, err)
+		}
+		if ok {
+			target.SetString(decrypted)
+		}
+		return nil
+	}
+}
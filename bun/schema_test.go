@@ -0,0 +1,156 @@
+// Package govault - Bun adapter schema bridge tests
+package bun_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/muhammadluth/govault"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uptrace/bun"
+)
+
+type TestBridgeUser struct {
+	bun.BaseModel `bun:"table:test_bridge_users"`
+	ID            int64  `bun:"id,pk,autoincrement"`
+	Email         string `bun:"email" encrypted:"true"`
+	SSN           string `bun:"ssn" encrypted:"true" deterministic:"true"`
+	Name          string `bun:"name"`
+}
+
+func TestBunSchemaBridge(t *testing.T) {
+	db, govaultDB, cleanup := setupTestDB(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	require.NoError(t, db.RegisterModel((*TestBridgeUser)(nil)))
+	// Registering twice must not wrap the hooks twice
+	require.NoError(t, db.RegisterModel((*TestBridgeUser)(nil)))
+
+	_, err := db.DB.NewCreateTable().Model((*TestBridgeUser)(nil)).IfNotExists().Exec(ctx)
+	require.NoError(t, err)
+	defer db.DB.NewDropTable().Model((*TestBridgeUser)(nil)).IfExists().Exec(ctx)
+
+	readRaw := func(t *testing.T, id int64) (string, string) {
+		var raw struct {
+			Email string `bun:"email"`
+			SSN   string `bun:"ssn"`
+		}
+		err := db.DB.NewSelect().Table("test_bridge_users").Column("email", "ssn").Where("id = ?", id).Scan(ctx, &raw)
+		require.NoError(t, err)
+		return raw.Email, raw.SSN
+	}
+
+	t.Run("direct bun insert and select", func(t *testing.T) {
+		user := &TestBridgeUser{Email: "bridge@example.com", SSN: "123-45-6789", Name: "Bridge"}
+		_, err := db.DB.NewInsert().Model(user).Exec(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, "bridge@example.com", user.Email, "model is not modified in place")
+
+		email, ssn := readRaw(t, user.ID)
+		assert.True(t, govault.IsEncrypted(email))
+		decrypted, err := govaultDB.Decrypt(email)
+		require.NoError(t, err)
+		assert.Equal(t, "bridge@example.com", decrypted)
+
+		lookup, err := govaultDB.EncryptField(&govault.FieldSpec{Deterministic: true}, "", "123-45-6789", "")
+		require.NoError(t, err)
+		assert.Equal(t, lookup, ssn)
+
+		var retrieved TestBridgeUser
+		err = db.DB.NewSelect().Model(&retrieved).Where("id = ?", user.ID).Scan(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, "bridge@example.com", retrieved.Email)
+		assert.Equal(t, "123-45-6789", retrieved.SSN)
+		assert.Equal(t, "Bridge", retrieved.Name)
+	})
+
+	t.Run("wrapper insert is not encrypted twice", func(t *testing.T) {
+		user := &TestBridgeUser{Email: "wrapped@example.com"}
+		_, err := db.NewInsert().Model(user).Exec(ctx)
+		require.NoError(t, err)
+
+		email, _ := readRaw(t, user.ID)
+		decrypted, err := govaultDB.Decrypt(email)
+		require.NoError(t, err)
+		assert.Equal(t, "wrapped@example.com", decrypted)
+
+		var retrieved TestBridgeUser
+		err = db.NewSelect().Model(&retrieved).Where("id = ?", user.ID).Scan(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, "wrapped@example.com", retrieved.Email)
+	})
+
+	t.Run("direct bun update", func(t *testing.T) {
+		user := &TestBridgeUser{Email: "before@example.com"}
+		_, err := db.DB.NewInsert().Model(user).Exec(ctx)
+		require.NoError(t, err)
+
+		user.Email = "after@example.com"
+		_, err = db.DB.NewUpdate().Model(user).Column("email").WherePK().Exec(ctx)
+		require.NoError(t, err)
+
+		var retrieved TestBridgeUser
+		err = db.DB.NewSelect().Model(&retrieved).Where("id = ?", user.ID).Scan(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, "after@example.com", retrieved.Email)
+	})
+}
+
+type TestBridgePointerUser struct {
+	bun.BaseModel `bun:"table:test_bridge_pointer_users"`
+	ID            int64   `bun:"id,pk,autoincrement"`
+	Email         *string `bun:"email" encrypted:"true"`
+	Phone         *string `bun:"phone" encrypted:"true"`
+}
+
+func TestBunSchemaBridgePointers(t *testing.T) {
+	db, govaultDB, cleanup := setupTestDB(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	require.NoError(t, db.RegisterModel((*TestBridgePointerUser)(nil)))
+	_, err := db.DB.NewCreateTable().Model((*TestBridgePointerUser)(nil)).IfNotExists().Exec(ctx)
+	require.NoError(t, err)
+	defer db.DB.NewDropTable().Model((*TestBridgePointerUser)(nil)).IfExists().Exec(ctx)
+
+	email := "pointer@example.com"
+	user := &TestBridgePointerUser{Email: &email}
+	_, err = db.DB.NewInsert().Model(user).Exec(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "pointer@example.com", *user.Email, "model is not modified in place")
+
+	var raw struct {
+		Email string  `bun:"email"`
+		Phone *string `bun:"phone"`
+	}
+	require.NoError(t, db.DB.NewSelect().Table("test_bridge_pointer_users").Column("email", "phone").Where("id = ?", user.ID).Scan(ctx, &raw))
+	assert.True(t, govault.IsEncrypted(raw.Email))
+	assert.Nil(t, raw.Phone, "nil pointers stay NULL")
+	decrypted, err := govaultDB.Decrypt(raw.Email)
+	require.NoError(t, err)
+	assert.Equal(t, "pointer@example.com", decrypted)
+
+	var retrieved TestBridgePointerUser
+	require.NoError(t, db.DB.NewSelect().Model(&retrieved).Where("id = ?", user.ID).Scan(ctx))
+	require.NotNil(t, retrieved.Email)
+	assert.Equal(t, "pointer@example.com", *retrieved.Email)
+	assert.Nil(t, retrieved.Phone)
+}
+
+type TestBadRelationUser struct {
+	bun.BaseModel `bun:"table:test_bad_relation_users"`
+	ID            int64           `bun:"id,pk,autoincrement"`
+	Email         string          `bun:"email" encrypted:"true"`
+	Profile       *TestBridgeUser `bun:"rel:has-one,join:missing=id"`
+}
+
+func TestBunRegisterModelErrors(t *testing.T) {
+	db, _, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	assert.EqualError(t, db.RegisterModel(TestBridgeUser{}), "models must be pointers to structs, got bun_test.TestBridgeUser")
+	assert.ErrorContains(t, db.RegisterModel((*TestBadRelationUser)(nil)), "must have column missing")
+	assert.NoError(t, db.RegisterModel((*TestBridgeUser)(nil)))
+}
@@ -4,6 +4,7 @@ package bun
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"github.com/muhammadluth/govault/internal"
 	"github.com/uptrace/bun"
@@ -13,16 +14,29 @@ import (
 type BunSelectQuery struct {
 	*bun.SelectQuery
 	govault *internal.GovaultDB
+	keyID   string
+	// allowEncrypted disables checkEncryptedColumns
+	allowEncrypted bool
+	// blindIndexes enables rewriteBlindIndexes in Scan
+	blindIndexes bool
+	// conn runs the rewritten statements, the database by default
+	conn bun.IDB
+	// timeout bounds Scan, Count and Exists, zero means no limit
+	timeout time.Duration
 }
 
 // Conn sets the database connection
 func (q *BunSelectQuery) Conn(db bun.IConn) *BunSelectQuery {
 	q.SelectQuery.Conn(db)
+	if idb, ok := db.(bun.IDB); ok {
+		q.conn = idb
+	}
 	return q
 }
 
 // Model sets the model for select
 func (q *BunSelectQuery) Model(model any) *BunSelectQuery {
+	bindBinaryFields(q.DB(), q.govault, model)
 	q.SelectQuery.Model(model)
 	return q
 }
@@ -239,7 +253,8 @@ func (q *BunSelectQuery) ExceptAll(other *BunSelectQuery) *BunSelectQuery {
 	return q
 }
 
-// Relation adds a relation to the query
+// Relation adds a relation to the query. The apply function receives the
+// sub-query with the key of q.
 func (q *BunSelectQuery) Relation(name string, apply ...func(*BunSelectQuery) *BunSelectQuery) *BunSelectQuery {
 	if len(apply) > 1 {
 		q.Err(fmt.Errorf("only one apply function is supported"))
@@ -250,7 +265,7 @@ func (q *BunSelectQuery) Relation(name string, apply ...func(*BunSelectQuery) *B
 		q.SelectQuery.Relation(name)
 	} else {
 		q.SelectQuery.Relation(name, func(sq *bun.SelectQuery) *bun.SelectQuery {
-			wrapped := &BunSelectQuery{SelectQuery: sq, govault: q.govault}
+			wrapped := &BunSelectQuery{SelectQuery: sq, govault: q.govault, keyID: q.keyID}
 			return apply[0](wrapped).SelectQuery
 		})
 	}
@@ -337,42 +352,84 @@ func (q *BunSelectQuery) Comment(comment string) *BunSelectQuery {
 
 // Count returns the count of rows
 func (q *BunSelectQuery) Count(ctx context.Context) (int, error) {
+	ctx, cancel := withTimeout(ctx, q.timeout)
+	defer cancel()
+
+	if err := q.checkEncryptedColumns(); err != nil {
+		return 0, err
+	}
 	return q.SelectQuery.Count(ctx)
 }
 
 // Exists checks if any rows match the query
 func (q *BunSelectQuery) Exists(ctx context.Context) (bool, error) {
+	ctx, cancel := withTimeout(ctx, q.timeout)
+	defer cancel()
+
+	if err := q.checkEncryptedColumns(); err != nil {
+		return false, err
+	}
 	return q.SelectQuery.Exists(ctx)
 }
 
+// WithKey sets the encryption key for this query and its relations
+func (q *BunSelectQuery) WithKey(keyID string) *BunSelectQuery {
+	q.keyID = keyID
+	return q
+}
+
+// KeyID returns the key of the query, empty for the default key
+func (q *BunSelectQuery) KeyID() string {
+	return q.keyID
+}
+
 // Scan executes the query and decrypts results
 func (q *BunSelectQuery) Scan(ctx context.Context, dest ...any) error {
+	ctx, cancel := withTimeout(ctx, q.timeout)
+	defer cancel()
+
+	if q.blindIndexes {
+		if table, spec := q.modelSpec(); spec != nil {
+			return q.scanBlindIndexes(ctx, table, spec, dest...)
+		}
+	}
+	if err := q.checkEncryptedColumns(); err != nil {
+		return err
+	}
 	err := q.SelectQuery.Scan(ctx, dest...)
 	if err != nil {
 		return err
 	}
 
 	for _, d := range dest {
-		if err := q.govault.DecryptRecursive(d); err != nil {
+		if err := q.govault.DecryptRecursiveContext(ctx, d); err != nil {
 			return err
 		}
 	}
-
-	return nil
+	return q.govault.AfterDecrypt(ctx, internal.OperationSelect, dest...)
 }
 
 // ScanAndCount scans results and returns count
 func (q *BunSelectQuery) ScanAndCount(ctx context.Context, dest ...any) (int, error) {
+	ctx, cancel := withTimeout(ctx, q.timeout)
+	defer cancel()
+
+	if err := q.checkEncryptedColumns(); err != nil {
+		return 0, err
+	}
 	count, err := q.SelectQuery.ScanAndCount(ctx, dest...)
 	if err != nil {
 		return count, err
 	}
 
 	for _, d := range dest {
-		if err := q.govault.DecryptRecursive(d); err != nil {
+		if err := q.govault.DecryptRecursiveContext(ctx, d); err != nil {
 			return count, err
 		}
 	}
+	if err := q.govault.AfterDecrypt(ctx, internal.OperationSelect, dest...); err != nil {
+		return count, err
+	}
 
 	return count, nil
 }
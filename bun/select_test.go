@@ -447,6 +447,36 @@ func TestBunRelationDecryption(t *testing.T) {
 		// The Bio should be decrypted
 		assert.Equal(t, "This is a secret bio", users[0].Profile.Bio)
 	})
+
+	t.Run("Relation apply inherits key", func(t *testing.T) {
+		var seen []string
+		apply := func(q *gb.BunSelectQuery) *gb.BunSelectQuery {
+			seen = append(seen, q.KeyID())
+			return q
+		}
+
+		var fromDB TestUserWithProfile
+		err := db.WithKey("2").NewSelect().
+			Model(&fromDB).
+			Relation("Profile", apply).
+			Where("u.id = ?", user.ID).
+			Scan(ctx, &fromDB)
+		require.NoError(t, err)
+		assert.Equal(t, "This is a secret bio", fromDB.Profile.Bio)
+
+		var fromQuery TestUserWithProfile
+		err = db.NewSelect().
+			WithKey("1").
+			Model(&fromQuery).
+			Relation("Profile", apply).
+			Where("u.id = ?", user.ID).
+			Scan(ctx, &fromQuery)
+		require.NoError(t, err)
+
+		require.NotEmpty(t, seen)
+		assert.Equal(t, "2", seen[0])
+		assert.Equal(t, "1", seen[len(seen)-1])
+	})
 }
 
 func TestBunSelectApply(t *testing.T) {
@@ -0,0 +1,124 @@
+// Package govault - Bun adapter shard routing
+package bun
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"sync"
+)
+
+// ErrNoShardKey is returned by ShardRouter.DB for contexts not returned by
+// WithShard
+var ErrNoShardKey = errors.New("no shard key in context")
+
+// shardContextKey is the context key of the shard key set with WithShard
+type shardContextKey struct{}
+
+// WithShard returns a context routing ShardRouter.DB to the shard of
+// shardKey, e.g. a tenant or user ID
+func WithShard(ctx context.Context, shardKey string) context.Context {
+	return context.WithValue(ctx, shardContextKey{}, shardKey)
+}
+
+// ShardFromContext returns the shard key set with WithShard
+func ShardFromContext(ctx context.Context) (string, bool) {
+	key, ok := ctx.Value(shardContextKey{}).(string)
+	return key, ok
+}
+
+// ShardRouter picks among databases wrapped with the same keys by shard
+// key. Keys are mapped with jump consistent hashing, so appending a shard
+// moves only the keys it takes over.
+type ShardRouter struct {
+	shards []*BunDB
+}
+
+// NewShardRouter returns a router over shards, in order. The shards must
+// share the GovaultDB of their keys, e.g. the named databases of one.
+func NewShardRouter(shards ...*BunDB) (*ShardRouter, error) {
+	if len(shards) == 0 {
+		return nil, fmt.Errorf("at least one shard is required")
+	}
+	for i, shard := range shards {
+		if shard == nil {
+			return nil, fmt.Errorf("shard %d is nil", i)
+		}
+		if shard.govault != shards[0].govault {
+			return nil, fmt.Errorf("shard %d does not share the keys of shard 0", i)
+		}
+	}
+	return &ShardRouter{shards: shards}, nil
+}
+
+// Shards returns the databases of r, in order
+func (r *ShardRouter) Shards() []*BunDB {
+	return r.shards
+}
+
+// Shard returns the index of the shard of shardKey
+func (r *ShardRouter) Shard(shardKey string) int {
+	h := fnv.New64a()
+	h.Write([]byte(shardKey))
+	return jumpHash(h.Sum64(), len(r.shards))
+}
+
+// DB returns the shard of the key set on ctx with WithShard
+func (r *ShardRouter) DB(ctx context.Context) (*BunDB, error) {
+	key, ok := ShardFromContext(ctx)
+	if !ok {
+		return nil, ErrNoShardKey
+	}
+	return r.shards[r.Shard(key)], nil
+}
+
+// jumpHash maps key to one of n buckets, see Lamping and Veach, "A Fast,
+// Minimal Memory, Consistent Hash Algorithm"
+func jumpHash(key uint64, n int) int {
+	var b, j int64 = -1, 0
+	for j < int64(n) {
+		b = j
+		key = key*2862933555777941757 + 1
+		j = int64(float64(b+1) * (float64(int64(1)<<31) / float64((key>>33)+1)))
+	}
+	return int(b)
+}
+
+// SelectShards runs the select built by query on every shard of r in
+// parallel and returns the decrypted rows of all shards, in shard order.
+// query receives a select with its model set to the rows of one shard.
+// Failed shards are reported together and no rows are returned.
+//
+//	users, err := gb.SelectShards[User](ctx, router, func(q *gb.BunSelectQuery) *gb.BunSelectQuery {
+//		return q.Where("created_at > ?", since)
+//	})
+func SelectShards[T any](ctx context.Context, r *ShardRouter, query func(*BunSelectQuery) *BunSelectQuery) ([]T, error) {
+	results := make([][]T, len(r.shards))
+	errs := make([]error, len(r.shards))
+
+	var wg sync.WaitGroup
+	for i, shard := range r.shards {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			q := shard.NewSelect().Model(&results[i])
+			if query != nil {
+				q = query(q)
+			}
+			if err := q.Scan(ctx, &results[i]); err != nil {
+				errs[i] = fmt.Errorf("shard %d: %w", i, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	var rows []T
+	for _, shardRows := range results {
+		rows = append(rows, shardRows...)
+	}
+	return rows, nil
+}
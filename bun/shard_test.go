@@ -0,0 +1,95 @@
+package bun_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/muhammadluth/govault"
+	"github.com/muhammadluth/govault/govaulttest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect/pgdialect"
+
+	gb "github.com/muhammadluth/govault/bun"
+)
+
+func TestShardRouterConsistency(t *testing.T) {
+	shards := func(n int) *gb.ShardRouter {
+		dbs := make([]*gb.BunDB, n)
+		for i := range dbs {
+			dbs[i] = &gb.BunDB{}
+		}
+		r, err := gb.NewShardRouter(dbs...)
+		require.NoError(t, err)
+		return r
+	}
+	four, five := shards(4), shards(5)
+
+	moved := 0
+	counts := make([]int, 4)
+	for i := range 1000 {
+		key := fmt.Sprintf("tenant-%d", i)
+		shard := four.Shard(key)
+		counts[shard]++
+		assert.Equal(t, shard, four.Shard(key), "stable")
+		if next := five.Shard(key); next != shard {
+			assert.Equal(t, 4, next, "keys only move to the new shard")
+			moved++
+		}
+	}
+	for _, count := range counts {
+		assert.Greater(t, count, 150)
+	}
+	assert.Less(t, moved, 300)
+
+	db, err := four.DB(gb.WithShard(context.Background(), "tenant-7"))
+	require.NoError(t, err)
+	assert.Same(t, four.Shards()[four.Shard("tenant-7")], db)
+	_, err = four.DB(context.Background())
+	assert.ErrorIs(t, err, gb.ErrNoShardKey)
+
+	_, err = gb.NewShardRouter()
+	assert.Error(t, err)
+}
+
+func TestSelectShards(t *testing.T) {
+	first := openPostgres(t)
+	defer first.Close()
+
+	govaultDB, err := govault.NewWithOptions(
+		govault.WithBun(bun.NewDB(first, pgdialect.New())),
+		govault.WithNamedBun("s0", bun.NewDB(openPostgres(t), pgdialect.New())),
+		govault.WithNamedBun("s1", bun.NewDB(openPostgres(t), pgdialect.New())),
+		govault.WithKeys(govaulttest.EphemeralKeys(1)),
+		govault.WithDefaultKey("1"),
+	)
+	require.NoError(t, err)
+	router, err := govaultDB.ShardRouter("s0", "s1")
+	require.NoError(t, err)
+	_, err = govaultDB.ShardRouter("s0", "missing")
+	assert.ErrorContains(t, err, "database 'missing' not found")
+
+	ctx := context.Background()
+	db := router.Shards()[0]
+	_, err = db.NewCreateTable().Model((*TestUser)(nil)).IfNotExists().Exec(ctx)
+	require.NoError(t, err)
+	defer db.NewDropTable().Model((*TestUser)(nil)).IfExists().Exec(ctx)
+
+	for _, tenant := range []string{"a", "b", "c"} {
+		shard, err := router.DB(govault.WithShard(ctx, tenant))
+		require.NoError(t, err)
+		_, err = shard.NewInsert().Model(&TestUser{Name: tenant, Email: tenant + "@example.com"}).Exec(ctx)
+		require.NoError(t, err)
+	}
+
+	// The test shards share one database, so every row is seen per shard
+	users, err := gb.SelectShards[TestUser](ctx, router, func(q *gb.BunSelectQuery) *gb.BunSelectQuery {
+		return q.Where("name = ?", "b")
+	})
+	require.NoError(t, err)
+	require.Len(t, users, 2)
+	assert.Equal(t, "b@example.com", users[0].Email)
+	assert.Equal(t, "b@example.com", users[1].Email)
+}
@@ -0,0 +1,188 @@
+// Package govault - Bun adapter storage overhead reports
+package bun
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/muhammadluth/govault/internal"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect"
+)
+
+// SizeSampleSize is the number of non-empty values sampled per column
+const SizeSampleSize = 1000
+
+// SizeReport estimates the storage overhead of the encryption of a table,
+// for capacity planning. Averages are measured on a sample of rows and
+// extrapolated to all of them.
+type SizeReport struct {
+	Table   string       `json:"table"`
+	Rows    int64        `json:"rows"`
+	Columns []ColumnSize `json:"columns"`
+	// OverheadBytes is the estimated storage added by encryption, the sum
+	// of the columns
+	OverheadBytes int64 `json:"overhead_bytes"`
+}
+
+// ColumnSize is the size estimate of one encrypted column. Lengths are in
+// bytes as stored, without the per-value overhead of the database.
+type ColumnSize struct {
+	Column  string `json:"column"`
+	Sampled int    `json:"sampled"`
+	// Values counts the non-empty values of the column
+	Values           int64   `json:"values"`
+	AvgPlaintext     float64 `json:"avg_plaintext"`
+	AvgCiphertext    float64 `json:"avg_ciphertext"`
+	Ratio            float64 `json:"ratio"` // ciphertext to plaintext length
+	BlindIndex       string  `json:"blind_index,omitempty"`
+	AvgBlindIndex    float64 `json:"avg_blind_index,omitempty"`
+	BlindIndexValues int64   `json:"blind_index_values,omitempty"`
+	// IndexBytes is the size of the database indexes on the blind index
+	// column, -1 when the dialect does not report it
+	IndexBytes int64 `json:"index_bytes,omitempty"`
+	// OverheadBytes is the estimated storage added by encryption: the
+	// growth of the values, the blind index values and their indexes
+	OverheadBytes int64 `json:"overhead_bytes"`
+	// Plaintext counts sampled values stored unencrypted, which add no
+	// overhead
+	Plaintext int `json:"plaintext,omitempty"`
+	// Undecryptable counts sampled values that could not be decrypted and
+	// are left out of the averages
+	Undecryptable int `json:"undecryptable,omitempty"`
+}
+
+// SizeReport measures the encrypted columns of model: average plaintext and
+// ciphertext lengths on a sample of SizeSampleSize values, the blind index
+// values and, on PostgreSQL, the size of the indexes on them. No data is
+// changed.
+func (db *BunDB) SizeReport(ctx context.Context, model any) (*SizeReport, error) {
+	spec := db.govault.ModelSpec(model)
+	if spec == nil {
+		return nil, fmt.Errorf("model must be a struct, got %T", model)
+	}
+
+	report := &SizeReport{Table: spec.Table}
+	rows, err := db.DB.NewSelect().TableExpr("?", bun.Ident(spec.Table)).Count(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count rows of %s: %w", spec.Table, err)
+	}
+	report.Rows = int64(rows)
+
+	for _, field := range spec.Fields {
+		column, err := db.columnSize(ctx, spec.Table, field)
+		if err != nil {
+			return nil, err
+		}
+		report.Columns = append(report.Columns, *column)
+		report.OverheadBytes += column.OverheadBytes
+	}
+	return report, nil
+}
+
+// columnSize measures one encrypted column and its blind index
+func (db *BunDB) columnSize(ctx context.Context, table string, field *internal.FieldSpec) (*ColumnSize, error) {
+	column := &ColumnSize{Column: field.Column, BlindIndex: field.BlindIndex}
+
+	values, count, err := db.sampleColumn(ctx, table, field.Column)
+	if err != nil {
+		return nil, err
+	}
+	column.Sampled, column.Values = len(values), count
+
+	var plaintextBytes, ciphertextBytes int
+	for _, stored := range values {
+		value := stored
+		if field.Binary {
+			if value, err = internal.DecodeBinary([]byte(stored)); err != nil {
+				column.Undecryptable++
+				continue
+			}
+		}
+		plaintext, ok, err := db.govault.DecryptField(field, table, value)
+		if err != nil {
+			column.Undecryptable++
+			continue
+		}
+		if !ok {
+			column.Plaintext++
+			plaintext = value
+		}
+		plaintextBytes += len(plaintext)
+		ciphertextBytes += len(stored)
+	}
+	if measured := column.Sampled - column.Undecryptable; measured > 0 {
+		column.AvgPlaintext = float64(plaintextBytes) / float64(measured)
+		column.AvgCiphertext = float64(ciphertextBytes) / float64(measured)
+		if plaintextBytes > 0 {
+			column.Ratio = float64(ciphertextBytes) / float64(plaintextBytes)
+		}
+	}
+	overhead := (column.AvgCiphertext - column.AvgPlaintext) * float64(column.Values)
+
+	if field.BlindIndex != "" {
+		indexes, count, err := db.sampleColumn(ctx, table, field.BlindIndex)
+		if err != nil {
+			return nil, err
+		}
+		column.BlindIndexValues = count
+		if len(indexes) > 0 {
+			var indexBytes int
+			for _, index := range indexes {
+				indexBytes += len(index)
+			}
+			column.AvgBlindIndex = float64(indexBytes) / float64(len(indexes))
+		}
+		overhead += column.AvgBlindIndex * float64(count)
+
+		if column.IndexBytes, err = db.indexBytes(ctx, table, field.BlindIndex); err != nil {
+			return nil, err
+		}
+		if column.IndexBytes > 0 {
+			overhead += float64(column.IndexBytes)
+		}
+	}
+
+	column.OverheadBytes = int64(overhead)
+	return column, nil
+}
+
+// sampleColumn reads up to SizeSampleSize non-empty values of a column as
+// stored and counts all of them
+func (db *BunDB) sampleColumn(ctx context.Context, table, column string) ([]string, int64, error) {
+	var values []string
+	q := db.DB.NewSelect().
+		TableExpr("?", bun.Ident(table)).
+		ColumnExpr("?", bun.Ident(column)).
+		Where("? IS NOT NULL", bun.Ident(column)).
+		Where("? <> ''", bun.Ident(column))
+	if err := q.Limit(SizeSampleSize).Scan(ctx, &values); err != nil {
+		return nil, 0, fmt.Errorf("failed to sample %s.%s: %w", table, column, err)
+	}
+	if len(values) < SizeSampleSize {
+		return values, int64(len(values)), nil
+	}
+
+	count, err := q.Limit(0).Count(ctx)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to count %s.%s: %w", table, column, err)
+	}
+	return values, int64(count), nil
+}
+
+// indexBytes returns the size of the indexes covering a column, -1 when
+// the dialect does not report it
+func (db *BunDB) indexBytes(ctx context.Context, table, column string) (int64, error) {
+	if db.DB.Dialect().Name() != dialect.PG {
+		return -1, nil
+	}
+	var size int64
+	err := db.DB.NewRaw(`SELECT coalesce(sum(pg_relation_size(i.indexrelid)), 0)
+		FROM pg_index i
+		JOIN pg_attribute a ON a.attrelid = i.indrelid AND a.attnum = ANY(i.indkey)
+		WHERE i.indrelid = to_regclass(?) AND a.attname = ?`, table, column).Scan(ctx, &size)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read index size of %s.%s: %w", table, column, err)
+	}
+	return size, nil
+}
@@ -0,0 +1,65 @@
+// Package govault - Bun adapter storage overhead report tests
+package bun_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBunSizeReport(t *testing.T) {
+	db, _, cleanup := setupTestDB(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	_, err := db.NewCreateTable().Model((*TestCatalogUser)(nil)).IfNotExists().Exec(ctx)
+	require.NoError(t, err)
+	defer db.NewDropTable().Model((*TestCatalogUser)(nil)).IfExists().Exec(ctx)
+
+	for _, name := range []string{"a", "b", "c", "d"} {
+		user := &TestCatalogUser{Email: name + "@example.com", Phone: "+6281111000" + name, Name: name}
+		_, err := db.NewInsert().Model(user).Exec(ctx)
+		require.NoError(t, err)
+	}
+	_, err = db.NewInsert().Model(&TestCatalogUser{Email: "e@example.com", Name: "e"}).Exec(ctx)
+	require.NoError(t, err)
+
+	report, err := db.SizeReport(ctx, (*TestCatalogUser)(nil))
+	require.NoError(t, err)
+	assert.Equal(t, "test_catalog_users", report.Table)
+	assert.Equal(t, int64(5), report.Rows)
+	require.Len(t, report.Columns, 2)
+
+	email := report.Columns[0]
+	assert.Equal(t, "email", email.Column)
+	assert.Equal(t, int64(5), email.Values)
+	assert.Equal(t, 5, email.Sampled)
+	assert.Equal(t, float64(len("a@example.com")), email.AvgPlaintext)
+	assert.Greater(t, email.AvgCiphertext, email.AvgPlaintext)
+	assert.Greater(t, email.Ratio, 1.0)
+	assert.Empty(t, email.BlindIndex)
+	assert.Zero(t, email.Undecryptable)
+
+	phone := report.Columns[1]
+	assert.Equal(t, "phone", phone.Column)
+	assert.Equal(t, int64(4), phone.Values, "empty values are not counted")
+	assert.Equal(t, "phone_bidx", phone.BlindIndex)
+	assert.Equal(t, int64(4), phone.BlindIndexValues)
+	assert.Positive(t, phone.AvgBlindIndex)
+	assert.Greater(t, phone.OverheadBytes, int64((phone.AvgCiphertext-phone.AvgPlaintext)*4))
+
+	assert.Equal(t, email.OverheadBytes+phone.OverheadBytes, report.OverheadBytes)
+
+	t.Run("plaintext rows are reported", func(t *testing.T) {
+		_, err := db.DB.NewInsert().Model(&TestCatalogUser{Email: "not encrypted", Name: "f"}).Exec(ctx)
+		require.NoError(t, err)
+
+		report, err := db.SizeReport(ctx, (*TestCatalogUser)(nil))
+		require.NoError(t, err)
+		assert.Equal(t, 1, report.Columns[0].Plaintext)
+		assert.Zero(t, report.Columns[0].Undecryptable)
+		assert.Equal(t, int64(6), report.Columns[0].Values)
+	})
+}
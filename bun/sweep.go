@@ -0,0 +1,200 @@
+// Package govault - Bun adapter retention sweeper for encryptttl fields
+package bun
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/muhammadluth/govault/internal"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/schema"
+)
+
+// SweepAction is what SweepExpired does with expired values
+type SweepAction string
+
+const (
+	// SweepNull sets expired values to NULL
+	SweepNull SweepAction = "null"
+	// SweepTombstone re-encrypts expired values under a tombstone key.
+	// Destroying that key later erases them all at once.
+	SweepTombstone SweepAction = "tombstone"
+)
+
+// SweepOptions configures SweepExpired
+type SweepOptions struct {
+	Action         SweepAction
+	TombstoneKeyID string // required by SweepTombstone
+	BatchSize      int    // defaults to DefaultRotationBatchSize
+}
+
+// SweepResult reports the values erased by SweepExpired per column
+type SweepResult struct {
+	Columns []SweepColumnResult `json:"columns"`
+}
+
+// SweepColumnResult is the outcome of one encryptttl column
+type SweepColumnResult struct {
+	Table  string `json:"table"`
+	Column string `json:"column"`
+	Swept  int64  `json:"swept"`
+	// Conflicts counts rows changed concurrently, swept by the next run
+	Conflicts int64 `json:"conflicts"`
+}
+
+// SweepExpired erases the values of fields tagged with encryptttl whose row
+// timestamp is older than the TTL. The timestamp column is set with
+// encryptttlcolumn and defaults to updated_at, then created_at. Models with
+// neither count from the encryption time embedded in each value, see
+// Config.EmbedEncryptedAt; values without one are kept. Blind index,
+// token index and bloom filter columns of erased values are set to NULL
+// too.
+func (db *BunDB) SweepExpired(ctx context.Context, models []any, opts SweepOptions) (*SweepResult, error) {
+	if err := checkRewriteMode(db.govault); err != nil {
+		return nil, err
+	}
+	if err := checkSweepAction(db.govault, opts.Action, opts.TombstoneKeyID); err != nil {
+		return nil, err
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = DefaultRotationBatchSize
+	}
+
+	now := time.Now()
+	result := &SweepResult{}
+	for _, model := range models {
+		spec := db.govault.ModelSpec(model)
+		if spec == nil {
+			return nil, fmt.Errorf("model must be a struct, got %T", model)
+		}
+
+		for _, field := range spec.Fields {
+			if field.TTL == 0 {
+				continue
+			}
+			if opts.Action == SweepTombstone && field.Codec != "" {
+				return nil, fmt.Errorf("%s.%s: tombstone sweeps require the native format, codec %s is not keyed", spec.Table, field.Column, field.Codec)
+			}
+
+			pks := db.DB.Table(spec.Type).PKs
+			if len(pks) != 1 {
+				return nil, fmt.Errorf("%s: sweeping requires a single column primary key", spec.Table)
+			}
+
+			columnResult, err := db.sweepColumn(ctx, spec, field, pks[0].Name, now.Add(-field.TTL), opts)
+			if err != nil {
+				return nil, err
+			}
+			result.Columns = append(result.Columns, *columnResult)
+		}
+	}
+	return result, nil
+}
+
+// sweepColumn erases the values of field in rows older than cutoff
+func (db *BunDB) sweepColumn(ctx context.Context, spec *internal.ModelSpec, field *internal.FieldSpec, primaryKey string, cutoff time.Time, opts SweepOptions) (*SweepColumnResult, error) {
+	rw := &rewrite{
+		table:      spec.Table,
+		primaryKey: primaryKey,
+		columns:    []rewriteColumn{{name: field.Column, binary: field.Binary}},
+		where:      []schema.QueryWithArgs{nonEmpty(field.Column)},
+		batchSize:  opts.BatchSize,
+		row: func(pk any, values []string) (map[string]any, error) {
+			if field.TTLColumn == "" {
+				encryptedAt, ok := internal.EncryptedAt(values[0])
+				if !ok || !encryptedAt.Before(cutoff) {
+					return nil, nil
+				}
+			}
+			set, err := eraseValue(db.govault, field, spec.Table, values[0], opts.Action, opts.TombstoneKeyID)
+			if err != nil {
+				return nil, fmt.Errorf("column %s: %w", field.Column, err)
+			}
+			return set, nil
+		},
+	}
+	if field.TTLColumn != "" {
+		rw.where = append(rw.where, bun.SafeQuery("? < ?", bun.Ident(field.TTLColumn), cutoff))
+	}
+	if opts.Action == SweepTombstone {
+		rw.where = append(rw.where, bun.SafeQuery("NOT ?", onKey(field.Column, field.Binary, opts.TombstoneKeyID)))
+	}
+
+	total, err := db.rewriteColumns(ctx, rw)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sweep %s.%s: %w", spec.Table, field.Column, err)
+	}
+	return &SweepColumnResult{Table: spec.Table, Column: field.Column, Swept: total.rewritten, Conflicts: total.conflicts}, nil
+}
+
+// checkSweepAction validates the action erasing values
+func checkSweepAction(govault *internal.GovaultDB, action SweepAction, tombstoneKeyID string) error {
+	switch action {
+	case SweepNull:
+	case SweepTombstone:
+		if !hasKey(govault, tombstoneKeyID) {
+			return fmt.Errorf("tombstone key ID '%s' not found in keys", tombstoneKeyID)
+		}
+	default:
+		return fmt.Errorf("unknown sweep action '%s'", action)
+	}
+	return nil
+}
+
+// eraseValue returns the columns to set to erase value, a stored value of
+// field in the text format: the value itself, NULL or re-encrypted under
+// the tombstone key, and its blind index, plaintext hash, token index and
+// bloom filter columns set to NULL
+func eraseValue(govault *internal.GovaultDB, field *internal.FieldSpec, table, value string, action SweepAction, tombstoneKeyID string) (map[string]any, error) {
+	set := map[string]any{field.Column: nil}
+	if action == SweepTombstone {
+		tombstone, err := tombstone(govault, field, table, value, tombstoneKeyID)
+		if err != nil {
+			return nil, err
+		}
+		set[field.Column] = tombstone
+	}
+	for _, companion := range []string{field.BlindIndex, field.PlainHash, field.TokenIndex, field.Bloom} {
+		if companion != "" {
+			set[companion] = nil
+		}
+	}
+	return set, nil
+}
+
+// tombstone re-encrypts value under the tombstone key
+func tombstone(govault *internal.GovaultDB, field *internal.FieldSpec, table, value, tombstoneKeyID string) (string, error) {
+	plaintext, ok, err := govault.DecryptFieldStrict(field, table, value)
+	if err != nil {
+		return "", err
+	}
+	if !ok {
+		plaintext = value
+	}
+	return govault.EncryptField(field, table, plaintext, tombstoneKeyID)
+}
+
+// RunSweeper calls SweepExpired every interval until ctx is done, passing
+// every result or error to report, which may be nil. interval must be
+// positive.
+func (db *BunDB) RunSweeper(ctx context.Context, interval time.Duration, models []any, opts SweepOptions, report func(*SweepResult, error)) error {
+	if interval <= 0 {
+		return fmt.Errorf("interval must be positive, got %s", interval)
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		result, err := db.SweepExpired(ctx, models, opts)
+		if report != nil {
+			report(result, err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
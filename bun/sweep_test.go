@@ -0,0 +1,144 @@
+// Package govault - Bun adapter retention sweeper tests
+package bun_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/muhammadluth/govault"
+	gb "github.com/muhammadluth/govault/bun"
+	"github.com/muhammadluth/govault/govaulttest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect/pgdialect"
+)
+
+type TestSweepUser struct {
+	bun.BaseModel `bun:"table:test_sweep_users"`
+	ID            int64     `bun:"id,pk,autoincrement"`
+	Email         string    `bun:"email" encrypted:"true" encryptttl:"720h" blindindex:"email_bidx"`
+	EmailBidx     string    `bun:"email_bidx"`
+	Phone         string    `bun:"phone" encrypted:"true"`
+	UpdatedAt     time.Time `bun:"updated_at,notnull"`
+}
+
+func TestBunSweepExpired(t *testing.T) {
+	db, govaultDB, cleanup := setupTestDB(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	_, err := db.NewCreateTable().Model((*TestSweepUser)(nil)).IfNotExists().Exec(ctx)
+	require.NoError(t, err)
+	defer db.NewDropTable().Model((*TestSweepUser)(nil)).IfExists().Exec(ctx)
+
+	insert := func(email string, age time.Duration) *TestSweepUser {
+		u := &TestSweepUser{Email: email, Phone: "555-0100", UpdatedAt: time.Now().Add(-age)}
+		_, err := db.NewInsert().Model(u).Exec(ctx)
+		require.NoError(t, err)
+		return u
+	}
+	expired := insert("old@example.com", 40*24*time.Hour)
+	fresh := insert("new@example.com", time.Hour)
+
+	models := []any{(*TestSweepUser)(nil)}
+
+	t.Run("tombstone", func(t *testing.T) {
+		result, err := db.SweepExpired(ctx, models, gb.SweepOptions{Action: gb.SweepTombstone, TombstoneKeyID: "1"})
+		require.NoError(t, err)
+		require.Len(t, result.Columns, 1)
+		assert.EqualValues(t, 1, result.Columns[0].Swept)
+
+		var raw struct {
+			Email     string
+			EmailBidx *string
+		}
+		err = db.DB.NewSelect().Table("test_sweep_users").Column("email", "email_bidx").Where("id = ?", expired.ID).Scan(ctx, &raw)
+		require.NoError(t, err)
+		keyID, err := govaultDB.GetKeyIDFromEncryptedData(raw.Email)
+		require.NoError(t, err)
+		assert.Equal(t, "1", keyID)
+		assert.Nil(t, raw.EmailBidx)
+
+		// Already tombstoned values are not swept again
+		result, err = db.SweepExpired(ctx, models, gb.SweepOptions{Action: gb.SweepTombstone, TombstoneKeyID: "1"})
+		require.NoError(t, err)
+		assert.Zero(t, result.Columns[0].Swept)
+	})
+
+	t.Run("null", func(t *testing.T) {
+		result, err := db.SweepExpired(ctx, models, gb.SweepOptions{Action: gb.SweepNull, BatchSize: 1})
+		require.NoError(t, err)
+		assert.EqualValues(t, 1, result.Columns[0].Swept)
+
+		var users []TestSweepUser
+		require.NoError(t, db.NewSelect().Model(&users).Order("id").Scan(ctx, &users))
+		require.Len(t, users, 2)
+		assert.Empty(t, users[0].Email)
+		assert.Equal(t, "555-0100", users[0].Phone)
+		assert.Equal(t, fresh.ID, users[1].ID)
+		assert.Equal(t, "new@example.com", users[1].Email)
+	})
+
+	t.Run("invalid options", func(t *testing.T) {
+		_, err := db.SweepExpired(ctx, models, gb.SweepOptions{Action: gb.SweepTombstone, TombstoneKeyID: "missing"})
+		assert.Error(t, err)
+		_, err = db.SweepExpired(ctx, models, gb.SweepOptions{Action: "shred"})
+		assert.Error(t, err)
+		err = db.RunSweeper(ctx, 0, models, gb.SweepOptions{}, nil)
+		assert.ErrorContains(t, err, "interval must be positive")
+	})
+}
+
+type TestSweepEvent struct {
+	bun.BaseModel `bun:"table:test_sweep_events"`
+	ID            int64  `bun:"id,pk,autoincrement"`
+	Payload       string `bun:"payload" encrypted:"true" encryptttl:"3s"`
+}
+
+func TestBunSweepEncryptedAt(t *testing.T) {
+	sqlDB := openPostgres(t)
+	defer sqlDB.Close()
+	govaultDB, err := govault.NewWithOptions(
+		govault.WithBun(bun.NewDB(sqlDB, pgdialect.New())),
+		govault.WithKeys(govaulttest.EphemeralKeys(1)),
+		govault.WithDefaultKey("1"),
+		govault.WithEmbedEncryptedAt(),
+	)
+	require.NoError(t, err)
+	db := govaultDB.BunDB()
+	ctx := context.Background()
+
+	_, err = db.NewCreateTable().Model((*TestSweepEvent)(nil)).IfNotExists().Exec(ctx)
+	require.NoError(t, err)
+	defer db.NewDropTable().Model((*TestSweepEvent)(nil)).IfExists().Exec(ctx)
+
+	// Values without a timestamp are kept
+	plain, err := govault.New(govault.Config{Keys: govaulttest.EphemeralKeys(1), DefaultKeyID: "1"})
+	require.NoError(t, err)
+	untimed, err := plain.Encrypt("untimed")
+	require.NoError(t, err)
+	_, err = db.DB.NewInsert().Model(&map[string]any{"payload": untimed}).TableExpr("test_sweep_events").Exec(ctx)
+	require.NoError(t, err)
+
+	old := &TestSweepEvent{Payload: "old"}
+	_, err = db.NewInsert().Model(old).Exec(ctx)
+	require.NoError(t, err)
+	time.Sleep(4 * time.Second)
+	fresh := &TestSweepEvent{Payload: "fresh"}
+	_, err = db.NewInsert().Model(fresh).Exec(ctx)
+	require.NoError(t, err)
+
+	result, err := db.SweepExpired(ctx, []any{(*TestSweepEvent)(nil)}, gb.SweepOptions{Action: gb.SweepNull})
+	require.NoError(t, err)
+	require.Len(t, result.Columns, 1)
+	assert.EqualValues(t, 1, result.Columns[0].Swept)
+
+	var events []TestSweepEvent
+	require.NoError(t, db.DB.NewSelect().Model(&events).Order("id").Scan(ctx))
+	require.Len(t, events, 3)
+	assert.Equal(t, untimed, events[0].Payload)
+	assert.Empty(t, events[1].Payload)
+	assert.NotEmpty(t, events[2].Payload)
+}
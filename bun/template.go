@@ -0,0 +1,215 @@
+// Package govault - Bun adapter predicate templates on encrypted columns
+package bun
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/muhammadluth/govault/internal"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/schema"
+)
+
+// Template is an SQL predicate searching encrypted columns, see Q
+type Template struct {
+	query string
+	args  []any
+}
+
+// Q returns a predicate template for WhereQ and NewRawQ:
+//
+//	db.NewSelect().Model(&users).WhereQ(gb.Q("email = {enc:email} AND name = ?", email, name))
+//
+// A {enc:column} placeholder takes the next argument like ? does, and
+// expands according to the encryption of the column: to the blind index of
+// the argument when the column has one, the references to the column in
+// the template then reading its blind index column instead, or else to the
+// ciphertext of the argument for deterministic columns. Randomized columns
+// without a blind index cannot be searched. Raw queries have no model, their
+// columns are qualified by their table, {enc:users.email}.
+func Q(template string, args ...any) Template {
+	return Template{query: template, args: args}
+}
+
+// encPlaceholder matches {enc:column} and {enc:table.column}
+var encPlaceholder = regexp.MustCompile(`^\{enc:(?:([A-Za-z_][A-Za-z0-9_]*)\.)?([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// expand returns the query and the arguments of the template, resolving
+// unqualified columns in spec and qualified ones in the tables known to bun
+func (t Template) expand(govault *internal.GovaultDB, tables *schema.Tables, spec *internal.ModelSpec, keyID string) (string, []any, error) {
+	var (
+		b        strings.Builder
+		args     []any
+		argIndex int
+		indexes  = make(map[string]string) // column -> blind index column
+	)
+	nextArg := func() (any, error) {
+		if argIndex >= len(t.args) {
+			return nil, fmt.Errorf("template %q has more placeholders than arguments", t.query)
+		}
+		argIndex++
+		return t.args[argIndex-1], nil
+	}
+
+	for i := 0; i < len(t.query); i++ {
+		c := t.query[i]
+		switch {
+		case c == '\\' && i+1 < len(t.query) && t.query[i+1] == '?':
+			b.WriteString(`\?`)
+			i++
+		case c == '?' && (i+1 == len(t.query) || !isWordByte(t.query[i+1])):
+			arg, err := nextArg()
+			if err != nil {
+				return "", nil, err
+			}
+			b.WriteByte('?')
+			args = append(args, arg)
+		case c == '{' && encPlaceholder.MatchString(t.query[i:]):
+			m := encPlaceholder.FindStringSubmatch(t.query[i:])
+			i += len(m[0]) - 1
+			arg, err := nextArg()
+			if err != nil {
+				return "", nil, err
+			}
+			plaintext, ok := arg.(string)
+			if !ok {
+				return "", nil, fmt.Errorf("%s must be given a string, got %T", m[0], arg)
+			}
+
+			fieldSpec, field, err := templateField(govault, tables, spec, m[1], m[2])
+			if err != nil {
+				return "", nil, err
+			}
+			var value string
+			switch {
+			case field.BlindIndex != "":
+				// BlindIndex normalizes the plaintext itself
+				value, err = govault.BlindIndex(reflect.Zero(fieldSpec.Type).Interface(), field.Name, plaintext)
+				indexes[field.Column] = field.BlindIndex
+			case field.Deterministic:
+				if plaintext, err = internal.NormalizeField(field, plaintext); err == nil {
+					value, err = govault.EncryptField(field, fieldSpec.Table, plaintext, keyID)
+				}
+			default:
+				return "", nil, fmt.Errorf("%s.%s is randomized and has no blind index, it cannot be searched: %w", fieldSpec.Table, field.Column, ErrEncryptedColumn)
+			}
+			if err != nil {
+				return "", nil, err
+			}
+			b.WriteByte('?')
+			if field.Binary && field.BlindIndex == "" {
+				data, err := internal.EncodeBinary(value)
+				if err != nil {
+					return "", nil, err
+				}
+				args = append(args, data)
+				continue
+			}
+			args = append(args, value)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	if argIndex != len(t.args) {
+		return "", nil, fmt.Errorf("template %q has %d placeholders, got %d arguments", t.query, argIndex, len(t.args))
+	}
+	return rewriteColumns(b.String(), indexes), args, nil
+}
+
+// templateField returns the encrypted field of column, in the model of
+// table when set, otherwise in spec
+func templateField(govault *internal.GovaultDB, tables *schema.Tables, spec *internal.ModelSpec, table, column string) (*internal.ModelSpec, *internal.FieldSpec, error) {
+	if table != "" {
+		t := tables.ByName(table)
+		if t == nil {
+			return nil, nil, fmt.Errorf("{enc:%s.%s}: no model of table %s is known, register it first", table, column, table)
+		}
+		spec = govault.ModelSpec(reflect.Zero(t.Type).Interface())
+	}
+	if spec == nil {
+		return nil, nil, fmt.Errorf("{enc:%s}: without a model with encrypted fields, qualify the column by its table", column)
+	}
+	field := fieldOfColumn(spec, column)
+	if field == nil {
+		return nil, nil, fmt.Errorf("%s.%s is not encrypted", spec.Table, column)
+	}
+	return spec, field, nil
+}
+
+// rewriteColumns replaces the references to the columns of indexes in
+// query by their blind index columns
+func rewriteColumns(query string, indexes map[string]string) string {
+	if len(indexes) == 0 {
+		return query
+	}
+	tokens := tokenizeSQL(query)
+	var edits []sqlEdit
+	for i, t := range tokens {
+		index, ok := indexes[t.text]
+		if !ok || (t.kind != tokWord && t.kind != tokIdent) {
+			continue
+		}
+		// A function of the same name is not a column
+		if i+1 < len(tokens) && tokens[i+1].text == "(" {
+			continue
+		}
+		text := index
+		if t.kind == tokIdent {
+			text = quoteIdent(index)
+		}
+		edits = append(edits, sqlEdit{t.start, t.end, text})
+	}
+	sort.Slice(edits, func(i, j int) bool { return edits[i].start > edits[j].start })
+	for _, e := range edits {
+		query = query[:e.start] + e.text + query[e.end:]
+	}
+	return query
+}
+
+// modelTableSpec returns the bun table and the encryption spec of model,
+// if it has encrypted fields
+func modelTableSpec(govault *internal.GovaultDB, model bun.Model) (*schema.Table, *internal.ModelSpec) {
+	tableModel, ok := model.(interface{ Table() *schema.Table })
+	if !ok || tableModel.Table() == nil {
+		return nil, nil
+	}
+	table := tableModel.Table()
+	spec := govault.ModelSpec(reflect.Zero(table.Type).Interface())
+	if spec == nil || len(spec.Fields) == 0 {
+		return nil, nil
+	}
+	return table, spec
+}
+
+// WhereQ adds a WHERE clause expanded from a template, see Q
+func (q *BunSelectQuery) WhereQ(t Template) *BunSelectQuery {
+	_, spec := q.modelSpec()
+	query, args, err := t.expand(q.govault, q.DB().Dialect().Tables(), spec, q.keyID)
+	if err != nil {
+		return q.Err(err)
+	}
+	return q.Where(query, args...)
+}
+
+// WhereQ adds a WHERE clause expanded from a template, see Q
+func (q *BunUpdateQuery) WhereQ(t Template) *BunUpdateQuery {
+	_, spec := modelTableSpec(q.govault, q.GetModel())
+	query, args, err := t.expand(q.govault, q.DB().Dialect().Tables(), spec, q.keyID)
+	if err != nil {
+		return q.Err(err)
+	}
+	return q.Where(query, args...)
+}
+
+// WhereQ adds a WHERE clause expanded from a template, see Q
+func (q *BunDeleteQuery) WhereQ(t Template) *BunDeleteQuery {
+	_, spec := modelTableSpec(q.govault, q.GetModel())
+	query, args, err := t.expand(q.govault, q.DB().Dialect().Tables(), spec, q.keyID)
+	if err != nil {
+		return q.Err(err)
+	}
+	return q.Where(query, args...)
+}
@@ -0,0 +1,88 @@
+package bun_test
+
+import (
+	"context"
+	"testing"
+
+	gb "github.com/muhammadluth/govault/bun"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBunTemplate(t *testing.T) {
+	db, _, cleanup := setupTestDB(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	_, err := db.NewCreateTable().Model((*TestCatalogUser)(nil)).IfNotExists().Exec(ctx)
+	require.NoError(t, err)
+	defer db.NewDropTable().Model((*TestCatalogUser)(nil)).IfExists().Exec(ctx)
+
+	for _, phone := range []string{"+62811110000", "+62822220000", "+62811110000"} {
+		_, err := db.NewInsert().Model(&TestCatalogUser{Email: phone + "@example.com", Phone: phone}).Exec(ctx)
+		require.NoError(t, err)
+	}
+
+	t.Run("blind index", func(t *testing.T) {
+		var users []TestCatalogUser
+		err := db.NewSelect().Model(&users).WhereQ(gb.Q("phone = {enc:phone}", "+62811110000")).Scan(ctx, &users)
+		require.NoError(t, err)
+		require.Len(t, users, 2)
+		assert.Equal(t, "+62811110000", users[0].Phone)
+	})
+
+	t.Run("deterministic and plain arguments", func(t *testing.T) {
+		var users []TestCatalogUser
+		err := db.NewSelect().Model(&users).
+			WhereQ(gb.Q("(?TableAlias.email = {enc:email} OR \"phone\" = {enc:phone}) AND id > ?", "+62822220000@example.com", "+62811110000", 0)).
+			Scan(ctx, &users)
+		require.NoError(t, err)
+		assert.Len(t, users, 3)
+	})
+
+	t.Run("raw", func(t *testing.T) {
+		var n int
+		err := db.NewRawQ(gb.Q("SELECT count(*) FROM test_catalog_users WHERE phone = {enc:test_catalog_users.phone}", "+62822220000")).Scan(ctx, &n)
+		require.NoError(t, err)
+		assert.Equal(t, 1, n)
+	})
+
+	t.Run("update and delete", func(t *testing.T) {
+		res, err := db.NewUpdate().Model((*TestCatalogUser)(nil)).
+			Set("email = email").
+			WhereQ(gb.Q("email = {enc:email}", "+62822220000@example.com")).
+			Exec(ctx)
+		require.NoError(t, err)
+		n, _ := res.RowsAffected()
+		assert.Equal(t, int64(1), n)
+
+		res, err = db.NewDelete().Model((*TestCatalogUser)(nil)).WhereQ(gb.Q("phone = {enc:phone}", "+62811110000")).Exec(ctx)
+		require.NoError(t, err)
+		n, _ = res.RowsAffected()
+		assert.Equal(t, int64(2), n)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		var users []TestCatalogUser
+		err := db.NewSelect().Model(&users).WhereQ(gb.Q("name = {enc:name}", "x")).Scan(ctx, &users)
+		assert.ErrorContains(t, err, "test_catalog_users.name is not encrypted")
+
+		err = db.NewSelect().Model(&users).WhereQ(gb.Q("email = {enc:email} AND id = ?", "x")).Scan(ctx, &users)
+		assert.ErrorContains(t, err, "more placeholders than arguments")
+
+		err = db.NewSelect().Model(&users).WhereQ(gb.Q("email = {enc:email}", "x", 1)).Scan(ctx, &users)
+		assert.ErrorContains(t, err, "has 1 placeholders, got 2 arguments")
+
+		err = db.NewSelect().Model(&users).WhereQ(gb.Q("email = {enc:email}", 1)).Scan(ctx, &users)
+		assert.ErrorContains(t, err, "{enc:email} must be given a string")
+
+		err = db.NewSelect().Model((*TestUser)(nil)).WhereQ(gb.Q("email = {enc:email}", "x")).Scan(ctx)
+		assert.ErrorIs(t, err, gb.ErrEncryptedColumn)
+
+		var n int
+		err = db.NewRawQ(gb.Q("SELECT count(*) FROM test_catalog_users WHERE phone = {enc:phone}", "x")).Scan(ctx, &n)
+		assert.ErrorContains(t, err, "qualify the column by its table")
+		err = db.NewRawQ(gb.Q("SELECT 1 WHERE x = {enc:unknown_table.x}", "x")).Scan(ctx, &n)
+		assert.ErrorContains(t, err, "no model of table unknown_table is known")
+	})
+}
@@ -0,0 +1,65 @@
+// Package govault - Bun adapter per-query timeouts
+package bun
+
+import (
+	"context"
+	"time"
+)
+
+// withTimeout derives a context with a deadline of d from ctx, or returns
+// ctx when d is not positive
+func withTimeout(ctx context.Context, d time.Duration) (context.Context, context.CancelFunc) {
+	if d <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, d)
+}
+
+// Timeout bounds each execution of the query, including the decryption of
+// its results, to d. Zero means no limit.
+func (q *BunSelectQuery) Timeout(d time.Duration) *BunSelectQuery {
+	q.timeout = d
+	return q
+}
+
+// Timeout bounds each execution of the query, including the decryption of
+// returned rows, to d. Zero means no limit.
+func (q *BunInsertQuery) Timeout(d time.Duration) *BunInsertQuery {
+	q.timeout = d
+	return q
+}
+
+// Timeout bounds each execution of the query, including the decryption of
+// returned rows, to d. Zero means no limit.
+func (q *BunUpdateQuery) Timeout(d time.Duration) *BunUpdateQuery {
+	q.timeout = d
+	return q
+}
+
+// Timeout bounds each execution of the query, including the decryption of
+// returned rows, to d. Zero means no limit.
+func (q *BunDeleteQuery) Timeout(d time.Duration) *BunDeleteQuery {
+	q.timeout = d
+	return q
+}
+
+// Timeout bounds each execution of the query, including the decryption of
+// its results, to d. Zero means no limit.
+func (q *BunRawQuery) Timeout(d time.Duration) *BunRawQuery {
+	q.timeout = d
+	return q
+}
+
+// Timeout bounds each execution of the query, including the catalog
+// update, to d. Zero means no limit.
+func (q *BunAddColumnQuery) Timeout(d time.Duration) *BunAddColumnQuery {
+	q.timeout = d
+	return q
+}
+
+// Timeout bounds each execution of the query, including the catalog
+// update, to d. Zero means no limit.
+func (q *BunDropColumnQuery) Timeout(d time.Duration) *BunDropColumnQuery {
+	q.timeout = d
+	return q
+}
@@ -0,0 +1,46 @@
+// Package govault - Bun adapter per-query timeout tests
+package bun_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBunQueryTimeout(t *testing.T) {
+	db, _, cleanup := setupTestDB(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	user := &TestUser{Name: "Timeout", Email: "timeout@example.com", Phone: "+62811110000"}
+	_, err := db.NewInsert().Model(user).Timeout(time.Minute).Exec(ctx)
+	require.NoError(t, err)
+
+	t.Run("within the timeout", func(t *testing.T) {
+		var found TestUser
+		err := db.NewSelect().Model(&found).Where("id = ?", user.ID).Timeout(time.Minute).Scan(ctx, &found)
+		require.NoError(t, err)
+		assert.Equal(t, "timeout@example.com", found.Email)
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		var found TestUser
+		err := db.NewSelect().Model(&found).Where("id = ?", user.ID).Timeout(time.Nanosecond).Scan(ctx, &found)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+		_, err = db.NewUpdate().Model(&TestUser{ID: user.ID, Name: "Late"}).Column("name").WherePK().Timeout(time.Nanosecond).Exec(ctx)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+		_, err = db.NewRaw("SELECT 1").Timeout(time.Nanosecond).Exec(ctx)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+
+	t.Run("zero means no limit", func(t *testing.T) {
+		n, err := db.NewSelect().Model((*TestUser)(nil)).Timeout(0).Count(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, 1, n)
+	})
+}
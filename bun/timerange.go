@@ -0,0 +1,36 @@
+// Package govault - Bun adapter coarse range filters on encrypted times
+package bun
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/muhammadluth/govault/internal"
+	"github.com/uptrace/bun"
+)
+
+// WhereTimeRange filters rows whose encrypted time in column may fall
+// between from and to, inclusive, using the plaintext year or decade
+// column set with the generalize tag. The filter is coarse: rows of the
+// whole first and last year, or decade, match, so compare the decrypted
+// values when the exact range matters. The model must be set first.
+func (q *BunSelectQuery) WhereTimeRange(column string, from, to time.Time) *BunSelectQuery {
+	var spec *internal.ModelSpec
+	if model := q.GetModel(); model != nil {
+		spec = q.govault.ModelSpec(model.Value())
+	}
+	if spec == nil {
+		return q.Err(fmt.Errorf("WhereTimeRange requires a model"))
+	}
+	target, unit, ok := spec.Generalized(column)
+	if !ok {
+		return q.Err(fmt.Errorf("%s.%s has no generalized column", spec.Table, column))
+	}
+	if to.Before(from) {
+		return q.Err(fmt.Errorf("time range of %s.%s ends before it starts", spec.Table, column))
+	}
+
+	q.SelectQuery.Where("?TableAlias.? BETWEEN ? AND ?", bun.Ident(target),
+		internal.Generalize(unit, from), internal.Generalize(unit, to))
+	return q
+}
@@ -0,0 +1,60 @@
+// Package govault - Bun adapter time range filter tests
+package bun_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/muhammadluth/govault"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uptrace/bun"
+)
+
+type TestPatient struct {
+	bun.BaseModel `bun:"table:test_patients"`
+	ID            int64                    `bun:"id,pk,autoincrement"`
+	BirthDate     govault.Field[time.Time] `bun:"birth_date,type:text" generalize:"birth_year"`
+	BirthYear     int                      `bun:"birth_year"`
+}
+
+func TestBunWhereTimeRange(t *testing.T) {
+	db, govaultDB, cleanup := setupTestDB(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	govault.SetFieldVault(govaultDB)
+	defer govault.SetFieldVault(nil)
+
+	_, err := db.NewCreateTable().Model((*TestPatient)(nil)).IfNotExists().Exec(ctx)
+	require.NoError(t, err)
+	defer db.NewDropTable().Model((*TestPatient)(nil)).IfExists().Exec(ctx)
+
+	for _, year := range []int{1970, 1985, 1990, 2001} {
+		patient := &TestPatient{BirthDate: govault.NewField(time.Date(year, 6, 1, 0, 0, 0, 0, time.UTC))}
+		_, err := db.NewInsert().Model(patient).Exec(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, year, patient.BirthYear)
+	}
+
+	t.Run("rows of the years in range match", func(t *testing.T) {
+		var patients []TestPatient
+		err := db.NewSelect().Model(&patients).
+			WhereTimeRange("birth_date", time.Date(1985, 12, 1, 0, 0, 0, 0, time.UTC), time.Date(1990, 1, 1, 0, 0, 0, 0, time.UTC)).
+			Order("birth_year").
+			Scan(ctx)
+		require.NoError(t, err)
+		require.Len(t, patients, 2, "the filter is coarse")
+		assert.Equal(t, 1985, patients[0].BirthDate.V.Year())
+		assert.Equal(t, 1990, patients[1].BirthDate.V.Year())
+	})
+
+	t.Run("columns without generalize fail", func(t *testing.T) {
+		var patients []TestPatient
+		err := db.NewSelect().Model(&patients).
+			WhereTimeRange("birth_year", time.Now(), time.Now()).
+			Scan(ctx)
+		assert.ErrorContains(t, err, "has no generalized column")
+	})
+}
@@ -0,0 +1,194 @@
+// Package govault - Bun adapter change detection of encrypted fields
+package bun
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"github.com/muhammadluth/govault/internal"
+	"github.com/uptrace/bun"
+)
+
+// SkipUnchanged makes Exec and Scan keep the stored ciphertext of the
+// encrypted fields whose plaintext did not change, instead of writing a
+// new random ciphertext, so that audit logs and CDC streams only show real
+// changes. The rows of the model are read by primary key before the update
+// and compared on the plaintext hash of the field, see the plainhash tag;
+// without one a differing blind index tells a change, otherwise the stored
+// value is decrypted and compared. Values stored under another key
+// than the new ciphertext, or with another algorithm than the field's, are
+// rewritten, so key rotation and algorithm migration still apply.
+func (q *BunUpdateQuery) SkipUnchanged() *BunUpdateQuery {
+	q.skipUnchanged = true
+	return q
+}
+
+// keepUnchanged replaces the new ciphertexts of unchanged fields of the
+// model with the stored ones
+func (q *BunUpdateQuery) keepUnchanged(ctx context.Context) error {
+	if !q.skipUnchanged || q.model == nil {
+		return nil
+	}
+	spec := q.govault.ModelSpec(q.model)
+	if spec == nil {
+		return nil
+	}
+	var fields []*internal.FieldSpec
+	columns := make([]string, 0, len(spec.Fields)+1)
+	for _, field := range spec.Fields {
+		// Deterministic ciphertexts of unchanged values are equal already
+		if field.Deterministic {
+			continue
+		}
+		fields = append(fields, field)
+		columns = append(columns, field.Column)
+		if field.PlainHash != "" {
+			columns = append(columns, field.PlainHash)
+		}
+		if field.BlindIndex != "" {
+			columns = append(columns, field.BlindIndex)
+		}
+	}
+	if len(fields) == 0 {
+		return nil
+	}
+
+	table := q.DB().Table(spec.Type)
+	if len(table.PKs) != 1 {
+		return fmt.Errorf("SkipUnchanged requires a single column primary key, %s has %d", spec.Table, len(table.PKs))
+	}
+	pk := table.PKs[0]
+
+	structs := modelStructs(q.model)
+	byKey := make(map[string]reflect.Value, len(structs))
+	ids := make([]any, 0, len(structs))
+	for _, strct := range structs {
+		id := pk.Value(strct).Interface()
+		byKey[rowKey(id)] = strct
+		ids = append(ids, id)
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	var rows []map[string]any
+	err := q.DB().NewSelect().
+		Conn(q.conn).
+		TableExpr("?", bun.Ident(spec.Table)).
+		Column(append(columns, pk.Name)...).
+		Where("? IN (?)", bun.Ident(pk.Name), bun.In(ids)).
+		Scan(ctx, &rows)
+	if err != nil {
+		return fmt.Errorf("failed to read stored values of %s: %w", spec.Table, err)
+	}
+
+	for _, row := range rows {
+		strct, ok := byKey[rowKey(row[pk.Name])]
+		if !ok {
+			continue
+		}
+		for _, field := range fields {
+			if err := q.keepField(spec, field, strct, row); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// keepField sets field of strct to its stored ciphertext when the
+// plaintext is the same
+func (q *BunUpdateQuery) keepField(spec *internal.ModelSpec, field *internal.FieldSpec, strct reflect.Value, row map[string]any) error {
+	target := strct.FieldByIndex(field.Index)
+	if !target.CanSet() || target.Kind() != reflect.String {
+		return nil
+	}
+	stored, updated := rowKey(row[field.Column]), target.String()
+	if field.Binary {
+		var err error
+		if stored, err = internal.DecodeBinary([]byte(stored)); err != nil {
+			return nil
+		}
+	}
+	if stored == "" || updated == "" || stored == updated {
+		return nil
+	}
+	if internal.NeedsAlgorithmMigration(field, stored) {
+		return nil
+	}
+
+	storedKey, err := q.govault.GetKeyIDFromEncryptedData(stored)
+	if err != nil {
+		return nil
+	}
+	if updatedKey, err := q.govault.GetKeyIDFromEncryptedData(updated); err != nil || updatedKey != storedKey {
+		return nil
+	}
+	if field.PlainHash != "" {
+		if index, ok := spec.ColumnIndex(field.PlainHash); ok && strct.FieldByIndex(index).Kind() == reflect.String {
+			if hash := rowKey(row[field.PlainHash]); hash != "" {
+				if strct.FieldByIndex(index).String() == hash {
+					target.SetString(stored)
+				}
+				return nil
+			}
+		}
+	}
+	if field.BlindIndex != "" {
+		if index, ok := spec.ColumnIndex(field.BlindIndex); ok && strct.FieldByIndex(index).Kind() == reflect.String {
+			// Blind indexes are truncated, only a difference is conclusive
+			if strct.FieldByIndex(index).String() != rowKey(row[field.BlindIndex]) {
+				return nil
+			}
+		}
+	}
+
+	storedPlaintext, ok, err := q.govault.DecryptFieldStrict(field, spec.Table, stored)
+	if err != nil || !ok {
+		return nil
+	}
+	updatedPlaintext, ok, err := q.govault.DecryptFieldStrict(field, spec.Table, updated)
+	if err != nil {
+		return fmt.Errorf("failed to decrypt field %s: %w", field.Name, err)
+	}
+	if ok && storedPlaintext == updatedPlaintext {
+		target.SetString(stored)
+	}
+	return nil
+}
+
+// modelStructs returns the addressable structs of a model: a struct, or a
+// slice of structs or struct pointers, behind a pointer
+func modelStructs(model any) []reflect.Value {
+	v := reflect.Indirect(reflect.ValueOf(model))
+	switch v.Kind() {
+	case reflect.Struct:
+		return []reflect.Value{v}
+	case reflect.Slice, reflect.Array:
+		structs := make([]reflect.Value, 0, v.Len())
+		for i := 0; i < v.Len(); i++ {
+			elem := reflect.Indirect(v.Index(i))
+			if elem.Kind() == reflect.Struct && elem.CanAddr() {
+				structs = append(structs, elem)
+			}
+		}
+		return structs
+	}
+	return nil
+}
+
+// rowKey returns the text of a scanned value, e.g. a primary key read as
+// []byte by some drivers
+func rowKey(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case []byte:
+		return string(v)
+	case string:
+		return v
+	default:
+		return fmt.Sprint(v)
+	}
+}
@@ -0,0 +1,93 @@
+package bun_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBunUpdateSkipUnchanged(t *testing.T) {
+	db, _, cleanup := setupTestDB(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	_, err := db.NewCreateTable().Model((*TestCatalogUser)(nil)).IfNotExists().Exec(ctx)
+	require.NoError(t, err)
+	defer db.NewDropTable().Model((*TestCatalogUser)(nil)).IfExists().Exec(ctx)
+
+	// stored reads the ciphertexts of the row as stored
+	stored := func(t *testing.T, id int64) (email, phone, name string) {
+		t.Helper()
+		err := db.DB.NewSelect().
+			Table("test_catalog_users").
+			Column("email", "phone", "name").
+			Where("id = ?", id).
+			Scan(ctx, &email, &phone, &name)
+		require.NoError(t, err)
+		return email, phone, name
+	}
+
+	user := &TestCatalogUser{Email: "skip@example.com", Phone: "+62811110000", Name: "before"}
+	_, err = db.NewInsert().Model(user).Exec(ctx)
+	require.NoError(t, err)
+	_, phone, _ := stored(t, user.ID)
+
+	t.Run("unchanged values keep their ciphertext", func(t *testing.T) {
+		update := &TestCatalogUser{ID: user.ID, Email: "skip@example.com", Phone: "+62811110000", Name: "after"}
+		_, err := db.NewUpdate().Model(update).WherePK().SkipUnchanged().Exec(ctx)
+		require.NoError(t, err)
+
+		_, gotPhone, gotName := stored(t, user.ID)
+		assert.Equal(t, phone, gotPhone)
+		assert.Equal(t, "after", gotName)
+		assert.Equal(t, phone, update.Phone, "the model holds the stored ciphertext")
+	})
+
+	t.Run("changed values are rewritten", func(t *testing.T) {
+		update := &TestCatalogUser{ID: user.ID, Email: "skip@example.com", Phone: "+62822220000", Name: "after"}
+		_, err := db.NewUpdate().Model(update).WherePK().SkipUnchanged().Exec(ctx)
+		require.NoError(t, err)
+
+		_, gotPhone, _ := stored(t, user.ID)
+		assert.NotEqual(t, phone, gotPhone)
+
+		var got TestCatalogUser
+		require.NoError(t, db.NewSelect().Model(&got).Where("id = ?", user.ID).Scan(ctx, &got))
+		assert.Equal(t, "+62822220000", got.Phone)
+		phone = gotPhone
+	})
+
+	t.Run("bulk updates compare every row", func(t *testing.T) {
+		other := &TestCatalogUser{Email: "other@example.com", Phone: "+62833330000", Name: "other"}
+		_, err := db.NewInsert().Model(other).Exec(ctx)
+		require.NoError(t, err)
+		_, otherPhone, _ := stored(t, other.ID)
+
+		tx, err := db.BeginTx(ctx, nil)
+		require.NoError(t, err)
+		updates := []*TestCatalogUser{
+			{ID: user.ID, Email: "skip@example.com", Phone: "+62822220000", Name: "bulk"},
+			{ID: other.ID, Email: "other@example.com", Phone: "+62844440000", Name: "bulk"},
+		}
+		_, err = tx.NewUpdate().Model(&updates).Column("phone", "name").Bulk().SkipUnchanged().Exec(ctx)
+		require.NoError(t, err)
+		require.NoError(t, tx.Commit())
+
+		_, gotPhone, gotName := stored(t, user.ID)
+		assert.Equal(t, phone, gotPhone)
+		assert.Equal(t, "bulk", gotName)
+		_, gotOtherPhone, _ := stored(t, other.ID)
+		assert.NotEqual(t, otherPhone, gotOtherPhone)
+	})
+
+	t.Run("updates without SkipUnchanged rewrite", func(t *testing.T) {
+		update := &TestCatalogUser{ID: user.ID, Email: "skip@example.com", Phone: "+62822220000", Name: "again"}
+		_, err := db.NewUpdate().Model(update).WherePK().Exec(ctx)
+		require.NoError(t, err)
+
+		_, gotPhone, _ := stored(t, user.ID)
+		assert.NotEqual(t, phone, gotPhone)
+	})
+}
@@ -0,0 +1,196 @@
+// Package govault - Bun adapter unique constraints on encrypted columns
+package bun
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/muhammadluth/govault/internal"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect"
+	"github.com/uptrace/bun/schema"
+)
+
+// UniqueBackfillBatchSize is the number of rows hashed per batch by
+// EnsureUniqueEncrypted
+const UniqueBackfillBatchSize = 500
+
+// UniqueResult reports what EnsureUniqueEncrypted did
+type UniqueResult struct {
+	// Column holds the UNIQUE index: the plaintext hash column, or the
+	// encrypted column itself when it is deterministic
+	Column string `json:"column"`
+	Index  string `json:"index"`
+	// ColumnAdded is set when the plaintext hash column was created
+	ColumnAdded bool `json:"column_added"`
+	// Backfilled counts the rows whose plaintext hash was computed
+	Backfilled int64 `json:"backfilled"`
+}
+
+// EnsureUniqueEncrypted makes the plaintexts of an encrypted field, given by
+// its Go name, unique. Randomized ciphertexts cannot be unique, so the
+// recommended pattern is a plaintext hash column with a UNIQUE index:
+//
+//	Email     string `bun:"email" encrypted:"true" plainhash:"email_hash"`
+//	EmailHash string `bun:"email_hash,nullzero"`
+//
+// It adds the hash column when missing, hashes the rows written before the
+// column existed and creates the index, and can be run again safely. The
+// hash column must be nullzero so that empty values do not collide.
+// Deterministic fields without a hash column are indexed directly; their
+// uniqueness only holds among values under the same key.
+//
+// Blind indexes are truncated and would reject distinct values sharing an
+// index, so they are not used. Existing duplicates fail the call; see
+// Duplicates.
+func (db *BunDB) EnsureUniqueEncrypted(ctx context.Context, model any, fieldName string) (*UniqueResult, error) {
+	if err := checkRewriteMode(db.govault); err != nil {
+		return nil, err
+	}
+	spec := db.govault.ModelSpec(model)
+	if spec == nil {
+		return nil, fmt.Errorf("model must be a struct, got %T", model)
+	}
+	field := spec.Field(fieldName)
+	if field == nil {
+		return nil, fmt.Errorf("field %s is not encrypted", fieldName)
+	}
+	table := db.DB.Table(spec.Type)
+
+	result := &UniqueResult{}
+	switch {
+	case field.PlainHash != "":
+		hashField, ok := table.FieldMap[field.PlainHash]
+		if !ok {
+			return nil, fmt.Errorf("%s.%s: plaintext hash column %s not found in the model", spec.Table, field.Column, field.PlainHash)
+		}
+		if !hashField.NullZero {
+			return nil, fmt.Errorf("%s.%s must be nullzero, empty values would collide", spec.Table, field.PlainHash)
+		}
+		if len(table.PKs) != 1 {
+			return nil, fmt.Errorf("backfilling requires a single column primary key, %s has %d", spec.Table, len(table.PKs))
+		}
+		result.Column = field.PlainHash
+
+		exists, err := db.columnExists(ctx, spec.Table, field.PlainHash)
+		if err != nil {
+			return nil, err
+		}
+		if !exists {
+			if _, err := db.NewAddColumn().Field(model, hashField.GoName).Exec(ctx); err != nil {
+				return nil, fmt.Errorf("failed to add %s.%s: %w", spec.Table, field.PlainHash, err)
+			}
+			result.ColumnAdded = true
+		}
+		if result.Backfilled, err = db.backfillPlainHash(ctx, model, spec, field, table.PKs[0].Name); err != nil {
+			return nil, err
+		}
+	case field.Deterministic:
+		if column, ok := table.FieldMap[field.Column]; !ok || !column.NullZero {
+			return nil, fmt.Errorf("%s.%s must be nullzero, empty values would collide", spec.Table, field.Column)
+		}
+		result.Column = field.Column
+	default:
+		return nil, fmt.Errorf("%s.%s is randomized, add a plainhash column to make it unique", spec.Table, field.Column)
+	}
+
+	duplicated, err := db.DB.NewSelect().
+		TableExpr("?", bun.Ident(spec.Table)).
+		ColumnExpr("?", bun.Ident(result.Column)).
+		Where("? <> ''", bun.Ident(result.Column)).
+		GroupExpr("?", bun.Ident(result.Column)).
+		Having("count(*) > 1").
+		Count(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count duplicates of %s.%s: %w", spec.Table, result.Column, err)
+	}
+	if duplicated > 0 {
+		return nil, fmt.Errorf("%d plaintexts of %s.%s are duplicated, deduplicate them first", duplicated, spec.Table, field.Column)
+	}
+
+	result.Index = spec.Table + "_" + result.Column + "_key"
+	if err := db.createUniqueIndex(ctx, spec.Table, result.Index, result.Column); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// columnExists reports whether a table has a column. The columns of an
+// empty result are compared since SQLite reads unknown quoted identifiers
+// as strings.
+func (db *BunDB) columnExists(ctx context.Context, table, column string) (bool, error) {
+	rows, err := db.DB.NewSelect().
+		TableExpr("?", bun.Ident(table)).
+		ColumnExpr("*").
+		Limit(0).
+		Rows(ctx)
+	if err != nil {
+		return false, fmt.Errorf("failed to read %s: %w", table, err)
+	}
+	defer rows.Close()
+	columns, err := rows.Columns()
+	if err != nil {
+		return false, fmt.Errorf("failed to read the columns of %s: %w", table, err)
+	}
+	return slices.Contains(columns, column), nil
+}
+
+// backfillPlainHash hashes the non-empty values of field whose hash is
+// missing, in batches ordered by primary key
+func (db *BunDB) backfillPlainHash(ctx context.Context, model any, spec *internal.ModelSpec, field *internal.FieldSpec, pk string) (int64, error) {
+	hash := bun.Ident(field.PlainHash)
+	total, err := db.rewriteColumns(ctx, &rewrite{
+		table:      spec.Table,
+		primaryKey: pk,
+		columns:    []rewriteColumn{{name: field.Column, binary: field.Binary}},
+		where: []schema.QueryWithArgs{
+			bun.SafeQuery("(? IS NULL OR ? = '')", hash, hash),
+			bun.SafeQuery("? IS NOT NULL", bun.Ident(field.Column)),
+		},
+		batchSize: UniqueBackfillBatchSize,
+		row: func(pk any, values []string) (map[string]any, error) {
+			plaintext, ok, err := db.govault.DecryptFieldStrict(field, spec.Table, values[0])
+			if err != nil {
+				return nil, err
+			}
+			if !ok {
+				plaintext = values[0]
+			}
+			if plaintext == "" {
+				return nil, nil
+			}
+			sum, err := db.govault.PlainHash(model, field.Name, plaintext)
+			if err != nil {
+				return nil, err
+			}
+			return map[string]any{field.PlainHash: sum}, nil
+		},
+	})
+	if err != nil {
+		return total.rewritten, fmt.Errorf("failed to backfill %s.%s: %w", spec.Table, field.PlainHash, err)
+	}
+	return total.rewritten, nil
+}
+
+// createUniqueIndex creates a UNIQUE index unless it exists
+func (db *BunDB) createUniqueIndex(ctx context.Context, table, index, column string) error {
+	q := db.DB.NewCreateIndex().Unique().Table(table).Index(index).Column(column)
+	if db.DB.Dialect().Name() == dialect.MySQL {
+		var n int
+		err := db.DB.NewRaw(`SELECT count(*) FROM information_schema.statistics
+			WHERE table_schema = DATABASE() AND table_name = ? AND index_name = ?`, table, index).Scan(ctx, &n)
+		if err != nil {
+			return fmt.Errorf("failed to look up index %s: %w", index, err)
+		}
+		if n > 0 {
+			return nil
+		}
+	} else {
+		q = q.IfNotExists()
+	}
+	if _, err := q.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to create unique index %s: %w", index, err)
+	}
+	return nil
+}
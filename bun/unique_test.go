@@ -0,0 +1,77 @@
+package bun_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uptrace/bun"
+)
+
+type TestUniqueUser struct {
+	bun.BaseModel `bun:"table:test_unique_users"`
+	ID            int64  `bun:"id,pk,autoincrement"`
+	Email         string `bun:"email" encrypted:"true" plainhash:"email_hash"`
+	EmailHash     string `bun:"email_hash,nullzero"`
+	Name          string `bun:"name" encrypted:"true"`
+}
+
+// TestLegacyUniqueUser is TestUniqueUser before the hash column was added
+type TestLegacyUniqueUser struct {
+	bun.BaseModel `bun:"table:test_unique_users"`
+	ID            int64  `bun:"id,pk,autoincrement"`
+	Email         string `bun:"email" encrypted:"true"`
+	Name          string `bun:"name" encrypted:"true"`
+}
+
+func TestBunEnsureUniqueEncrypted(t *testing.T) {
+	db, gv, cleanup := setupTestDB(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	_, err := db.NewCreateTable().Model((*TestLegacyUniqueUser)(nil)).IfNotExists().Exec(ctx)
+	require.NoError(t, err)
+	defer db.NewDropTable().Model((*TestUniqueUser)(nil)).IfExists().Exec(ctx)
+
+	for _, email := range []string{"a@example.com", "b@example.com", ""} {
+		_, err := db.NewInsert().Model(&TestLegacyUniqueUser{Email: email, Name: "legacy"}).Exec(ctx)
+		require.NoError(t, err)
+	}
+
+	t.Run("the hash column is added and backfilled", func(t *testing.T) {
+		result, err := db.EnsureUniqueEncrypted(ctx, (*TestUniqueUser)(nil), "Email")
+		require.NoError(t, err)
+		assert.Equal(t, "email_hash", result.Column)
+		assert.Equal(t, "test_unique_users_email_hash_key", result.Index)
+		assert.True(t, result.ColumnAdded)
+		assert.Equal(t, int64(2), result.Backfilled)
+
+		hash, err := gv.PlainHash((*TestUniqueUser)(nil), "Email", "a@example.com")
+		require.NoError(t, err)
+		count, err := db.NewSelect().Model((*TestUniqueUser)(nil)).Where("email_hash = ?", hash).Count(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, 1, count)
+	})
+
+	t.Run("duplicate plaintexts are rejected", func(t *testing.T) {
+		_, err := db.NewInsert().Model(&TestUniqueUser{Email: "a@example.com"}).Exec(ctx)
+		assert.Error(t, err)
+		_, err = db.NewInsert().Model(&TestUniqueUser{Email: "c@example.com"}).Exec(ctx)
+		assert.NoError(t, err)
+		_, err = db.NewInsert().Model(&TestUniqueUser{Name: "no email"}).Exec(ctx)
+		assert.NoError(t, err, "empty values do not collide")
+	})
+
+	t.Run("running again is a no-op", func(t *testing.T) {
+		result, err := db.EnsureUniqueEncrypted(ctx, (*TestUniqueUser)(nil), "Email")
+		require.NoError(t, err)
+		assert.False(t, result.ColumnAdded)
+		assert.Zero(t, result.Backfilled)
+	})
+
+	t.Run("randomized fields without a hash are refused", func(t *testing.T) {
+		_, err := db.EnsureUniqueEncrypted(ctx, (*TestUniqueUser)(nil), "Name")
+		assert.ErrorContains(t, err, "plainhash")
+	})
+}
@@ -5,7 +5,7 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
-	"reflect"
+	"time"
 
 	"github.com/muhammadluth/govault/internal"
 	"github.com/uptrace/bun"
@@ -14,21 +14,32 @@ import (
 // BunUpdateQuery wraps bun.UpdateQuery
 type BunUpdateQuery struct {
 	*bun.UpdateQuery
+	conn    bun.IConn
 	govault *internal.GovaultDB
 	keyID   string
+	timeout time.Duration
+	// encrypted is set once Model encrypted a model with keyID
+	encrypted bool
+	// model is the last model, compared by SkipUnchanged
+	model         any
+	skipUnchanged bool
 }
 
 // Conn sets the database connection
 func (q *BunUpdateQuery) Conn(db bun.IConn) *BunUpdateQuery {
+	q.conn = db
 	q.UpdateQuery.Conn(db)
 	return q
 }
 
 // Model sets the model and encrypts fields
 func (q *BunUpdateQuery) Model(model any) *BunUpdateQuery {
+	bindBinaryFields(q.DB(), q.govault, model)
 	if err := q.encryptModel(model); err != nil {
 		return q.Err(err)
 	}
+	q.encrypted = q.encrypted || modelEncrypted(model)
+	q.model = model
 	q.UpdateQuery.Model(model)
 	return q
 }
@@ -115,6 +126,12 @@ func (q *BunUpdateQuery) OmitZero() *BunUpdateQuery {
 	return q
 }
 
+// Bulk updates the rows of a slice model in one query
+func (q *BunUpdateQuery) Bulk() *BunUpdateQuery {
+	q.UpdateQuery.Bulk()
+	return q
+}
+
 // Join adds a JOIN clause
 func (q *BunUpdateQuery) Join(join string, args ...any) *BunUpdateQuery {
 	q.UpdateQuery.Join(join, args...)
@@ -219,83 +236,55 @@ func (q *BunUpdateQuery) Comment(comment string) *BunUpdateQuery {
 	return q
 }
 
-// Exec executes the update query
+// Exec executes the update query. Rows returned into dest, e.g. a slice for
+// a multi-row RETURNING, are decrypted.
 func (q *BunUpdateQuery) Exec(ctx context.Context, dest ...any) (sql.Result, error) {
+	ctx, cancel := withTimeout(ctx, q.timeout)
+	defer cancel()
+
+	if err := q.keepUnchanged(ctx); err != nil {
+		return nil, err
+	}
 	res, err := q.UpdateQuery.Exec(ctx, dest...)
 	if err != nil {
 		return res, err
 	}
-	if len(dest) > 0 {
-		for _, d := range dest {
-			if err := q.govault.DecryptRecursive(d); err != nil {
-				return res, err
-			}
-		}
-	}
-	return res, nil
+	return res, decryptReturning(ctx, q.govault, internal.OperationUpdate, dest)
 }
 
 // Scan executes the query and scans the result
 func (q *BunUpdateQuery) Scan(ctx context.Context, dest ...any) error {
-	err := q.UpdateQuery.Scan(ctx, dest...)
-	if err != nil {
+	ctx, cancel := withTimeout(ctx, q.timeout)
+	defer cancel()
+
+	if err := q.keepUnchanged(ctx); err != nil {
 		return err
 	}
-	for _, d := range dest {
-		if err := q.govault.DecryptRecursive(d); err != nil {
-			return err
-		}
+	if err := q.UpdateQuery.Scan(ctx, dest...); err != nil {
+		return err
 	}
-	return nil
+	return decryptReturning(ctx, q.govault, internal.OperationUpdate, dest)
 }
 
-// WithKey sets the encryption key for this query
+// WithKey sets the encryption key for this query. It must be called
+// before Model, which encrypts the model.
 func (q *BunUpdateQuery) WithKey(keyID string) *BunUpdateQuery {
+	if q.encrypted && keyID != q.keyID {
+		return q.Err(fmt.Errorf("WithKey(%q) called after Model, the model is already encrypted", keyID))
+	}
 	q.keyID = keyID
 	return q
 }
 
+// KeyID returns the key of the query, empty for the default key
+func (q *BunUpdateQuery) KeyID() string {
+	return q.keyID
+}
+
 // encryptModel encrypts fields tagged with encrypted:"true"
 func (q *BunUpdateQuery) encryptModel(model any) error {
-	val := reflect.ValueOf(model)
-	if val.Kind() == reflect.Ptr {
-		val = val.Elem()
-	}
-
-	if val.Kind() != reflect.Struct {
-		return nil
-	}
-
-	typ := val.Type()
-	for i := 0; i < val.NumField(); i++ {
-		field := val.Field(i)
-		fieldType := typ.Field(i)
-
-		if !field.CanSet() {
-			continue
-		}
-
-		if fieldType.Tag.Get("encrypted") == "true" {
-			if field.Kind() == reflect.String {
-				plaintext := field.String()
-				if plaintext != "" {
-					var encrypted string
-					var err error
-
-					if q.keyID != "" {
-						encrypted, err = q.govault.Encrypt(plaintext, q.keyID)
-					} else {
-						encrypted, err = q.govault.Encrypt(plaintext)
-					}
-
-					if err != nil {
-						return fmt.Errorf("failed to encrypt field %s: %w", fieldType.Name, err)
-					}
-					field.SetString(encrypted)
-				}
-			}
-		}
+	if err := q.govault.BeforeEncrypt(internal.OperationUpdate, model); err != nil {
+		return err
 	}
-
-	return nil
+	return q.govault.EncryptModel(model, q.keyID)
 }
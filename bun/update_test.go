@@ -72,8 +72,8 @@ func TestBunUpdate(t *testing.T) {
 		require.NoError(t, err)
 
 		// Should be encrypted
-		assert.Contains(t, raw.Email, "|")
-		parts := strings.Split(raw.Email, "|")
+		assert.True(t, strings.HasPrefix(raw.Email, "gv1:"))
+		parts := strings.Split(strings.TrimPrefix(raw.Email, "gv1:"), "|")
 		assert.Equal(t, "2", parts[0]) // Active key
 	})
 }
@@ -192,3 +192,43 @@ func TestBunUpdateExotic(t *testing.T) {
 		db.NewUpdate().Model(user).UseIndex("idx").IgnoreIndex("idx").ForceIndex("idx").Comment("test").Exec(ctx)
 	})
 }
+
+func TestBunUpdateFromValues(t *testing.T) {
+	db, _, cleanup := setupTestDB(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	users := []TestUser{
+		{Name: "Values A", Email: "a@example.com", Phone: "+62811110000"},
+		{Name: "Values B", Email: "b@example.com", Phone: "+62822220000"},
+	}
+	_, err := db.NewInsert().Model(&users).Exec(ctx)
+	require.NoError(t, err)
+
+	changes := []TestUser{
+		{ID: users[0].ID, Name: "Values A", Email: "new-a@example.com"},
+		{ID: users[1].ID, Name: "Values B", Email: "new-b@example.com"},
+	}
+	values := db.NewValues(&changes)
+	_, err = db.NewUpdate().
+		With("_data", values).
+		Model((*TestUser)(nil)).
+		TableExpr("_data").
+		Set("email = _data.email").
+		Where("u.id = _data.id").
+		Exec(ctx)
+	require.NoError(t, err)
+
+	for i, want := range []string{"new-a@example.com", "new-b@example.com"} {
+		var raw TestUser
+		err := db.DB.NewSelect().Model(&raw).Where("id = ?", users[i].ID).Scan(ctx)
+		require.NoError(t, err)
+		assert.True(t, strings.HasPrefix(raw.Email, "gv1:"), "bulk updates store ciphertext")
+		assert.True(t, strings.HasPrefix(raw.Phone, "gv1:"), "bulk inserts store ciphertext")
+
+		var user TestUser
+		err = db.NewSelect().Model(&user).Where("id = ?", users[i].ID).Scan(ctx, &user)
+		require.NoError(t, err)
+		assert.Equal(t, want, user.Email)
+	}
+}
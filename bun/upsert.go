@@ -0,0 +1,68 @@
+// Package govault - Bun adapter upserts by encrypted natural keys
+package bun
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect"
+)
+
+// InsertOrUpdateByEncrypted inserts model, a struct or a slice of structs,
+// or updates the rows already holding the same plaintext in the encrypted
+// column, e.g. "email". Randomized ciphertexts never conflict, so the
+// conflict is detected on a column derived from the plaintext, in order of
+// preference:
+//
+//   - the plaintext hash column of the field, see the plainhash tag
+//   - the column itself when the field is deterministic; a value stored
+//     under a key other than the current one is not matched
+//   - the blind index column; blind indexes are truncated, so distinct
+//     plaintexts sharing an index update each other's row
+//
+// The column needs a UNIQUE index, see EnsureUniqueEncrypted. On conflict
+// every column but the primary key is set from the new row, companion
+// columns included.
+func (db *BunDB) InsertOrUpdateByEncrypted(ctx context.Context, model any, column string) (sql.Result, error) {
+	spec := db.govault.ModelSpec(model)
+	if spec == nil {
+		return nil, fmt.Errorf("model must be a struct, got %T", model)
+	}
+	field := fieldOfColumn(spec, column)
+	if field == nil {
+		return nil, fmt.Errorf("column %s.%s is not encrypted", spec.Table, column)
+	}
+	var target string
+	switch {
+	case field.PlainHash != "":
+		target = field.PlainHash
+	case field.Deterministic:
+		target = field.Column
+	case field.BlindIndex != "":
+		target = field.BlindIndex
+	default:
+		return nil, fmt.Errorf("%s.%s is randomized, add a plainhash column to upsert by it", spec.Table, column)
+	}
+
+	q := db.NewInsert().Model(model)
+	mysql := db.DB.Dialect().Name() == dialect.MySQL
+	if mysql {
+		// MySQL detects conflicts on every unique index, there is no target
+		q = q.On("DUPLICATE KEY UPDATE")
+	} else {
+		q = q.On("CONFLICT (?) DO UPDATE", bun.Ident(target))
+	}
+	for _, f := range db.DB.Table(spec.Type).Fields {
+		if f.IsPK || f.Name == target {
+			continue
+		}
+		if mysql {
+			q = q.Set("? = VALUES(?)", bun.Ident(f.Name), bun.Ident(f.Name))
+		} else {
+			q = q.Set("? = EXCLUDED.?", bun.Ident(f.Name), bun.Ident(f.Name))
+		}
+	}
+	return q.Exec(ctx)
+}
@@ -0,0 +1,65 @@
+package bun_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBunInsertOrUpdateByEncrypted(t *testing.T) {
+	db, _, cleanup := setupTestDB(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	t.Run("conflicts are detected on the plaintext hash", func(t *testing.T) {
+		_, err := db.NewCreateTable().Model((*TestUniqueUser)(nil)).IfNotExists().Exec(ctx)
+		require.NoError(t, err)
+		defer db.NewDropTable().Model((*TestUniqueUser)(nil)).IfExists().Exec(ctx)
+		_, err = db.EnsureUniqueEncrypted(ctx, (*TestUniqueUser)(nil), "Email")
+		require.NoError(t, err)
+
+		_, err = db.InsertOrUpdateByEncrypted(ctx, &TestUniqueUser{Email: "up@example.com", Name: "first"}, "email")
+		require.NoError(t, err)
+		_, err = db.InsertOrUpdateByEncrypted(ctx, &TestUniqueUser{Email: "up@example.com", Name: "second"}, "email")
+		require.NoError(t, err)
+
+		var users []TestUniqueUser
+		require.NoError(t, db.NewSelect().Model(&users).Scan(ctx, &users))
+		require.Len(t, users, 1)
+		assert.Equal(t, "up@example.com", users[0].Email)
+		assert.Equal(t, "second", users[0].Name)
+	})
+
+	t.Run("deterministic columns are their own target", func(t *testing.T) {
+		_, err := db.NewCreateTable().Model((*TestCatalogUser)(nil)).IfNotExists().Exec(ctx)
+		require.NoError(t, err)
+		defer db.NewDropTable().Model((*TestCatalogUser)(nil)).IfExists().Exec(ctx)
+		_, err = db.NewCreateIndex().Model((*TestCatalogUser)(nil)).Unique().Index("test_catalog_users_email_key").Column("email").Exec(ctx)
+		require.NoError(t, err)
+
+		users := []*TestCatalogUser{
+			{Email: "a@example.com", Phone: "+62811110000", Name: "a"},
+			{Email: "b@example.com", Phone: "+62822220000", Name: "b"},
+		}
+		_, err = db.InsertOrUpdateByEncrypted(ctx, &users, "email")
+		require.NoError(t, err)
+		_, err = db.InsertOrUpdateByEncrypted(ctx, &TestCatalogUser{Email: "a@example.com", Phone: "+62833330000", Name: "a2"}, "email")
+		require.NoError(t, err)
+
+		var got []TestCatalogUser
+		require.NoError(t, db.NewSelect().Model(&got).Order("id").Scan(ctx, &got))
+		require.Len(t, got, 2)
+		assert.Equal(t, "a2", got[0].Name)
+		assert.Equal(t, "+62833330000", got[0].Phone)
+		assert.Equal(t, "b", got[1].Name)
+	})
+
+	t.Run("randomized columns without a derived column are refused", func(t *testing.T) {
+		_, err := db.InsertOrUpdateByEncrypted(ctx, &TestUniqueUser{Name: "x"}, "name")
+		assert.ErrorContains(t, err, "randomized")
+		_, err = db.InsertOrUpdateByEncrypted(ctx, &TestCatalogUser{}, "missing")
+		assert.ErrorContains(t, err, "not encrypted")
+	})
+}
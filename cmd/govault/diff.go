@@ -0,0 +1,45 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"io"
+
+	"github.com/muhammadluth/govault"
+	"github.com/muhammadluth/govault/internal"
+)
+
+// runDiff prints the CiphertextDiff of the two ciphertexts given as
+// arguments as JSON. With -config the values are decrypted with its keys
+// and their plaintexts compared.
+func runDiff(args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("diff", flag.ExitOnError)
+	configPath := fs.String("config", "", "govault config file holding the keys, to compare plaintexts")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 2 {
+		return errors.New("two ciphertexts are required")
+	}
+	a, b := fs.Arg(0), fs.Arg(1)
+
+	var diff *govault.CiphertextDiff
+	if *configPath != "" {
+		config, err := govault.LoadConfig(*configPath)
+		if err != nil {
+			return err
+		}
+		vault, err := internal.New(*config)
+		if err != nil {
+			return err
+		}
+		diff = vault.DiffCiphertexts(a, b)
+	} else {
+		diff = govault.DiffCiphertexts(a, b)
+	}
+
+	enc := json.NewEncoder(out)
+	enc.SetIndent("", "  ")
+	return enc.Encode(diff)
+}
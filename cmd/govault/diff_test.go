@@ -0,0 +1,42 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/muhammadluth/govault"
+	"github.com/muhammadluth/govault/internal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiff(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "govault.yaml")
+	require.NoError(t, os.WriteFile(configPath, []byte("default_key: k1\nkeys:\n  k1:\n    value: 727d37a0-a5f2-4d67-af47-83039c8e\n"), 0o600))
+	config, err := govault.LoadConfig(configPath)
+	require.NoError(t, err)
+	vault, err := internal.New(*config)
+	require.NoError(t, err)
+	a, err := vault.Encrypt("Jane")
+	require.NoError(t, err)
+	b, err := vault.Encrypt("Jane Doe")
+	require.NoError(t, err)
+
+	var out bytes.Buffer
+	require.NoError(t, runDiff([]string{a, b}, &out))
+	var diff govault.CiphertextDiff
+	require.NoError(t, json.Unmarshal(out.Bytes(), &diff))
+	assert.True(t, diff.SameKey)
+	assert.False(t, diff.Decrypted, "no keys without -config")
+
+	out.Reset()
+	require.NoError(t, runDiff([]string{"-config", configPath, a, b}, &out))
+	require.NoError(t, json.Unmarshal(out.Bytes(), &diff))
+	assert.True(t, diff.Decrypted)
+	assert.Equal(t, "Jane{+ Doe+}", diff.Diff)
+
+	assert.Error(t, runDiff([]string{a}, &out))
+}
@@ -0,0 +1,347 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"unicode"
+
+	"github.com/muhammadluth/govault/internal"
+)
+
+// genField is an encrypted field of a generated model
+type genField struct {
+	Name       string
+	Var        string // package variable holding the FieldSpec
+	Spec       string // FieldSpec literal
+	BlindIndex string // Go name of the blind index field
+	Normalize  bool
+}
+
+// genModel is a struct with encrypted fields
+type genModel struct {
+	Name   string
+	Table  string
+	Fields []genField
+}
+
+// genPackage is the input of the generator template
+type genPackage struct {
+	Package string
+	Models  []genModel
+}
+
+// generate parses the Go files in dir and returns the generated source for
+// all structs with encrypted fields, restricted to types when not empty
+func generate(dir string, types []string) ([]byte, error) {
+	filenames, err := filepath.Glob(filepath.Join(dir, "*.go"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(filenames)
+
+	fset := token.NewFileSet()
+	var files []*ast.File
+	for _, filename := range filenames {
+		base := filepath.Base(filename)
+		if strings.HasSuffix(base, "_test.go") || base == defaultOutput {
+			continue
+		}
+		file, err := parser.ParseFile(fset, filename, nil, 0)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, file)
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no Go files found in %s", dir)
+	}
+
+	wanted := make(map[string]bool, len(types))
+	for _, t := range types {
+		wanted[t] = true
+	}
+
+	out := genPackage{Package: files[0].Name.Name}
+	for _, file := range files {
+		if file.Name.Name != out.Package {
+			return nil, fmt.Errorf("found packages %s and %s in %s", out.Package, file.Name.Name, dir)
+		}
+		for _, decl := range file.Decls {
+			gen, ok := decl.(*ast.GenDecl)
+			if !ok || gen.Tok != token.TYPE {
+				continue
+			}
+			for _, s := range gen.Specs {
+				typeSpec := s.(*ast.TypeSpec)
+				st, ok := typeSpec.Type.(*ast.StructType)
+				if !ok || (len(wanted) > 0 && !wanted[typeSpec.Name.Name]) {
+					continue
+				}
+				model, err := parseModel(fset, typeSpec.Name.Name, st)
+				if err != nil {
+					return nil, err
+				}
+				if len(model.Fields) > 0 {
+					out.Models = append(out.Models, model)
+				}
+			}
+		}
+	}
+
+	if len(out.Models) == 0 {
+		return nil, fmt.Errorf("no structs with encrypted fields found in %s", dir)
+	}
+
+	var buf bytes.Buffer
+	if err := genTemplate.Execute(&buf, out); err != nil {
+		return nil, err
+	}
+	return format.Source(buf.Bytes())
+}
+
+// unsupportedOptions are the tag options of encrypted fields that change
+// the stored value or fill companion columns, which the generated code does
+// not do. Generating code for them would write values EncryptModel does not
+// read back the same way, so they fail the generation instead.
+var unsupportedOptions = []string{
+	"codec", "preset", "storage", "encryptpad", "envelope", "encryptarray",
+	"serializer", "transform", "generalize", "generalizeunit",
+	"encryptttl", "encryptttlcolumn", "plainhash", "tokenindex",
+	"bloom", "bloomfpr", "bloomitems", "bloomsep", "bucket", "bucketbounds",
+	"geohash", "geohashprecision", "emaildomain", "emaildomainhash",
+	"pan", "panbin", "panlast4", "pantoken",
+}
+
+// parseModel collects the encrypted fields of a struct and rejects tag
+// combinations the generated code cannot handle
+func parseModel(fset *token.FileSet, name string, st *ast.StructType) (genModel, error) {
+	model := genModel{Name: name}
+	columns := make(map[string]string)
+
+	type tagged struct {
+		field genField
+		spec  internal.FieldSpec
+		pos   token.Pos
+	}
+	var encrypted []tagged
+	var baseModelTag string
+
+	for _, f := range st.Fields.List {
+		if f.Tag == nil {
+			continue
+		}
+		raw, err := strconv.Unquote(f.Tag.Value)
+		if err != nil {
+			return model, err
+		}
+		tag := reflect.StructTag(raw)
+		if len(f.Names) == 0 {
+			if sel, ok := f.Type.(*ast.SelectorExpr); ok && sel.Sel.Name == "BaseModel" {
+				baseModelTag = tag.Get("bun")
+			}
+			continue
+		}
+
+		for _, ident := range f.Names {
+			column := internal.ColumnName(ident.Name, tag.Get("bun"))
+			if column != "" {
+				columns[column] = ident.Name
+			}
+
+			if tag.Get("encrypted") != "true" {
+				continue
+			}
+
+			pos := fset.Position(ident.Pos())
+			if !ident.IsExported() {
+				return model, fmt.Errorf("%s: %s.%s: encrypted field must be exported", pos, name, ident.Name)
+			}
+			if t, ok := f.Type.(*ast.Ident); !ok || t.Name != "string" {
+				return model, fmt.Errorf("%s: %s.%s: encrypted field must be a string", pos, name, ident.Name)
+			}
+			for _, option := range unsupportedOptions {
+				if _, ok := tag.Lookup(option); ok {
+					return model, fmt.Errorf("%s: %s.%s: %s fields are not supported by gen, use the query wrappers", pos, name, ident.Name, option)
+				}
+			}
+
+			spec := internal.FieldSpec{
+				Name:          ident.Name,
+				Column:        column,
+				Algorithm:     internal.Algorithm(tag.Get("algorithm")),
+				Deterministic: tag.Get("deterministic") == "true",
+				BlindIndex:    tag.Get("blindindex"),
+			}
+			for _, n := range strings.Split(tag.Get("normalize"), ",") {
+				if n = strings.TrimSpace(n); n != "" {
+					spec.Normalizers = append(spec.Normalizers, n)
+				}
+			}
+			if spec.BlindIndex != "" {
+				spec.BlindIndexBits = internal.DefaultBlindIndexBits
+				if bits, err := strconv.Atoi(tag.Get("blindindexbits")); err == nil && bits > 0 {
+					spec.BlindIndexBits = bits
+				}
+			}
+			encrypted = append(encrypted, tagged{
+				field: genField{Name: ident.Name, Var: lowerFirst(name) + ident.Name + "Field"},
+				spec:  spec,
+				pos:   ident.Pos(),
+			})
+		}
+	}
+
+	model.Table = internal.TableName(name, baseModelTag)
+	for _, e := range encrypted {
+		if column := e.spec.BlindIndex; column != "" {
+			goName, ok := columns[column]
+			if !ok {
+				return model, fmt.Errorf("%s: %s.%s: blind index column %q not found", fset.Position(e.pos), name, e.field.Name, column)
+			}
+			e.field.BlindIndex = goName
+		}
+		e.field.Spec = specLiteral(e.spec)
+		e.field.Normalize = len(e.spec.Normalizers) > 0
+		model.Fields = append(model.Fields, e.field)
+	}
+
+	return model, nil
+}
+
+// specLiteral returns the Go literal of the FieldSpec settings gen reads
+// from the tags
+func specLiteral(spec internal.FieldSpec) string {
+	fields := []string{
+		fmt.Sprintf("Name: %q", spec.Name),
+		fmt.Sprintf("Column: %q", spec.Column),
+	}
+	if spec.Algorithm != "" {
+		fields = append(fields, fmt.Sprintf("Algorithm: %q", spec.Algorithm))
+	}
+	if spec.Deterministic {
+		fields = append(fields, "Deterministic: true")
+	}
+	if spec.BlindIndex != "" {
+		fields = append(fields, fmt.Sprintf("BlindIndex: %q", spec.BlindIndex), fmt.Sprintf("BlindIndexBits: %d", spec.BlindIndexBits))
+	}
+	if len(spec.Normalizers) > 0 {
+		fields = append(fields, fmt.Sprintf("Normalizers: %#v", spec.Normalizers))
+	}
+	return "&govault.FieldSpec{" + strings.Join(fields, ", ") + "}"
+}
+
+// lowerFirst lowercases the first letter of a Go name
+func lowerFirst(s string) string {
+	if s == "" {
+		return s
+	}
+	r := []rune(s)
+	r[0] = unicode.ToLower(r[0])
+	return string(r)
+}
+
+// writeGenerated writes the generated source next to the models
+func writeGenerated(dir, output string, src []byte) error {
+	return os.WriteFile(filepath.Join(dir, output), src, 0o644)
+}
+
+var genTemplate = template.Must(template.New("gen").Parse(`// Code generated by govault gen. DO NOT EDIT.
+
+package {{.Package}}
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/muhammadluth/govault"
+	"github.com/uptrace/bun"
+)
+{{range $m := .Models}}
+var (
+{{- range .Fields}}
+	{{.Var}} = {{.Spec}}
+{{- end}}
+)
+
+// Encrypt{{$m.Name}} encrypts the encrypted fields of m in place. keyID
+// selects the key; empty means the default key.
+func Encrypt{{$m.Name}}(gv *govault.GovaultDB, m *{{$m.Name}}, keyID string) error {
+{{- range .Fields}}
+	if m.{{.Name}} != "" && !govault.IsEncrypted(m.{{.Name}}) {
+{{- if .Normalize}}
+		plaintext, err := govault.NormalizeField({{.Var}}, m.{{.Name}})
+		if err != nil {
+			return fmt.Errorf("failed to normalize field {{.Name}}: %w", err)
+		}
+{{- else}}
+		plaintext := m.{{.Name}}
+{{- end}}
+{{- if .BlindIndex}}
+		idx, err := gv.BlindIndexField({{.Var}}, "{{$m.Table}}", plaintext)
+		if err != nil {
+			return fmt.Errorf("failed to compute blind index for field {{.Name}}: %w", err)
+		}
+		m.{{.BlindIndex}} = idx
+{{- end}}
+		v, err := gv.EncryptField({{.Var}}, "{{$m.Table}}", plaintext, keyID)
+		if err != nil {
+			return fmt.Errorf("failed to encrypt field {{.Name}}: %w", err)
+		}
+		m.{{.Name}} = v
+	}
+{{- end}}
+	return nil
+}
+
+// Decrypt{{$m.Name}} decrypts the encrypted fields of m in place
+func Decrypt{{$m.Name}}(gv *govault.GovaultDB, m *{{$m.Name}}) error {
+{{- range .Fields}}
+	if govault.IsEncrypted(m.{{.Name}}) {
+		v, err := gv.Decrypt(m.{{.Name}})
+		if err != nil {
+			return fmt.Errorf("failed to decrypt field {{.Name}}: %w", err)
+		}
+		m.{{.Name}} = v
+	}
+{{- end}}
+	return nil
+}
+
+// Insert{{$m.Name}} encrypts m and inserts it with a plain bun query
+func Insert{{$m.Name}}(ctx context.Context, gv *govault.GovaultDB, db bun.IDB, m *{{$m.Name}}) error {
+	if err := Encrypt{{$m.Name}}(gv, m, ""); err != nil {
+		return err
+	}
+	_, err := db.NewInsert().Model(m).Exec(ctx)
+	return err
+}
+
+// Select{{$m.Name}}s runs a select built by apply and decrypts every row
+func Select{{$m.Name}}s(ctx context.Context, gv *govault.GovaultDB, db bun.IDB, apply func(*bun.SelectQuery) *bun.SelectQuery) ([]{{$m.Name}}, error) {
+	var rows []{{$m.Name}}
+	q := db.NewSelect().Model(&rows)
+	if apply != nil {
+		q = apply(q)
+	}
+	if err := q.Scan(ctx); err != nil {
+		return nil, err
+	}
+	for i := range rows {
+		if err := Decrypt{{$m.Name}}(gv, &rows[i]); err != nil {
+			return nil, err
+		}
+	}
+	return rows, nil
+}
+{{end}}`))
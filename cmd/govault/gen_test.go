@@ -0,0 +1,105 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files of testdata")
+
+func writeModels(t *testing.T, src string) string {
+	t.Helper()
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "models.go"), []byte(src), 0o644))
+	return dir
+}
+
+func TestGenerate(t *testing.T) {
+	dir := writeModels(t, `package models
+
+type User struct {
+	ID        int64  `+"`bun:\"id,pk\"`"+`
+	Email     string `+"`bun:\"email\" encrypted:\"true\" deterministic:\"true\"`"+`
+	Phone     string `+"`bun:\"phone\" encrypted:\"true\" blindindex:\"phone_bidx\"`"+`
+	PhoneBidx string `+"`bun:\"phone_bidx\"`"+`
+}
+
+type Plain struct {
+	Name string `+"`bun:\"name\"`"+`
+}
+`)
+
+	src, err := generate(dir, nil)
+	require.NoError(t, err)
+
+	out := string(src)
+	assert.Contains(t, out, "package models")
+	assert.Contains(t, out, "func EncryptUser(gv *govault.GovaultDB, m *User, keyID string) error")
+	assert.Contains(t, out, "func DecryptUser(gv *govault.GovaultDB, m *User) error")
+	assert.Contains(t, out, `userEmailField = &govault.FieldSpec{Name: "Email", Column: "email", Deterministic: true}`)
+	assert.Contains(t, out, `userPhoneField = &govault.FieldSpec{Name: "Phone", Column: "phone", BlindIndex: "phone_bidx", BlindIndexBits: 32}`)
+	assert.Contains(t, out, `gv.EncryptField(userEmailField, "users", plaintext, keyID)`)
+	assert.Contains(t, out, `idx, err := gv.BlindIndexField(userPhoneField, "users", plaintext)`)
+	assert.Contains(t, out, "m.PhoneBidx = idx")
+	assert.NotContains(t, out, "gv.BlindIndex(", "blind indexes are computed without reflection")
+	assert.NotContains(t, out, "EncryptPlain")
+}
+
+// TestGenerateGolden compares the output for a model using every option gen
+// supports with testdata/gen/govault_gen.golden. Run with -update after an
+// intended change of the generated code.
+func TestGenerateGolden(t *testing.T) {
+	dir := filepath.Join("testdata", "gen")
+	src, err := generate(dir, nil)
+	require.NoError(t, err)
+
+	golden := filepath.Join(dir, "govault_gen.golden")
+	if *update {
+		require.NoError(t, os.WriteFile(golden, src, 0o644))
+	}
+	want, err := os.ReadFile(golden)
+	require.NoError(t, err)
+	assert.Equal(t, string(want), string(src))
+
+	// Options gen cannot honour fail the generation, at the field
+	_, err = generate(filepath.Join("testdata", "genunsupported"), nil)
+	assert.EqualError(t, err, filepath.Join("testdata", "genunsupported", "models.go")+":9:2: Document.Body: storage fields are not supported by gen, use the query wrappers")
+}
+
+func TestGenerateErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		field string
+		err   string
+	}{
+		{"non string", "Age int `encrypted:\"true\"`", "must be a string"},
+		{"unexported", "secret string `encrypted:\"true\"`", "must be exported"},
+		{"codec", "SSN string `encrypted:\"true\" codec:\"tink\"`", "codec fields are not supported"},
+		{"preset", "SSN string `encrypted:\"true\" preset:\"ssn\"`", "preset fields are not supported"},
+		{"binary storage", "Email string `encrypted:\"true\" storage:\"binary\"`", "models.go:4:2: User.Email: storage fields are not supported"},
+		{"padding", "Email string `encrypted:\"true\" encryptpad:\"64\"`", "encryptpad fields are not supported"},
+		{"plain hash", "Email string `encrypted:\"true\" plainhash:\"email_hash\"`", "plainhash fields are not supported"},
+		{"token index", "Name string `encrypted:\"true\" tokenindex:\"name_tokens\"`", "tokenindex fields are not supported"},
+		{"bloom", "Name string `encrypted:\"true\" bloom:\"name_bloom\"`", "bloom fields are not supported"},
+		{"bucket", "Salary string `encrypted:\"true\" bucket:\"salary_bucket\"`", "bucket fields are not supported"},
+		{"geohash", "Location string `encrypted:\"true\" geohash:\"location_geohash\"`", "geohash fields are not supported"},
+		{"email domain", "Email string `encrypted:\"true\" emaildomain:\"email_domain\"`", "emaildomain fields are not supported"},
+		{"pan", "Card string `encrypted:\"true\" pan:\"true\" panlast4:\"card_last4\"`", "pan fields are not supported"},
+		{"envelope", "SSN string `encrypted:\"true\" envelope:\"true\"`", "envelope fields are not supported"},
+		{"missing blind index", "SSN string `encrypted:\"true\" blindindex:\"ssn_bidx\"`", `blind index column "ssn_bidx" not found`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := writeModels(t, "package models\n\ntype User struct {\n\t"+tt.field+"\n}\n")
+			_, err := generate(dir, nil)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.err)
+		})
+	}
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/muhammadluth/govault"
+)
+
+// runInspect prints the header metadata of each ciphertext given as an
+// argument, or read one per line from in without arguments, as JSON lines
+func runInspect(args []string, in io.Reader, out io.Writer) error {
+	values := args
+	if len(values) == 0 {
+		scanner := bufio.NewScanner(in)
+		scanner.Buffer(nil, govault.MaxCiphertextLength)
+		for scanner.Scan() {
+			if line := strings.TrimSpace(scanner.Text()); line != "" {
+				values = append(values, line)
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			return err
+		}
+	}
+
+	enc := json.NewEncoder(out)
+	for i, value := range values {
+		info, err := govault.InspectCiphertext(value)
+		if err != nil {
+			return fmt.Errorf("value %d: %w", i+1, err)
+		}
+		if err := enc.Encode(info); err != nil {
+			return err
+		}
+	}
+	return nil
+}
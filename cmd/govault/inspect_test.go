@@ -0,0 +1,31 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/muhammadluth/govault"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInspect(t *testing.T) {
+	value := "gv1:k1|AAAAAAAAAAAAAAAA|AAAAAAAAAAAAAAAAAAAAAA=="
+	var out bytes.Buffer
+	require.NoError(t, runInspect(nil, strings.NewReader(value+"\n\n"+value+"\n"), &out))
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	require.Len(t, lines, 2)
+	var info govault.CiphertextInfo
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &info))
+	assert.Equal(t, "gv1", info.Format)
+	assert.Equal(t, "k1", info.KeyID)
+	assert.Equal(t, govault.AlgorithmAESGCM, info.Algorithm)
+	assert.Equal(t, 12, info.NonceSize)
+
+	err := runInspect([]string{value, "plain"}, nil, &out)
+	assert.ErrorIs(t, err, govault.ErrInvalidCiphertext)
+	assert.ErrorContains(t, err, "value 2")
+}
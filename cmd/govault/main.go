@@ -0,0 +1,101 @@
+// Command govault provides tooling for govault users.
+//
+// Usage:
+//
+//	govault gen [-dir .] [-out govault_gen.go] [-types User,Order]
+//	govault inspect [ciphertext ...]
+//	govault diff [-config govault.yaml] ciphertext ciphertext
+//	govault sign-config -key signing.key [-genkey] [manifest ...]
+//
+// gen reads the model structs of a package and writes non-reflective
+// Encrypt<Model>/Decrypt<Model> functions plus typed bun query helpers.
+// Field settings are read from the struct tags only. gen supports the
+// algorithm, deterministic, blindindex, blindindexbits and normalize
+// options and fails on fields with any other encryption option, e.g.
+// codec, preset, storage or plainhash; those fields and the columns of
+// Config.Fields need the query wrappers. It is meant to be run from a
+// go:generate directive:
+//
+//	//go:generate go run github.com/muhammadluth/govault/cmd/govault gen
+//
+// inspect prints the header metadata of ciphertexts, given as arguments or
+// one per line on stdin, as JSON lines. It needs no key and never decrypts.
+//
+// diff compares two ciphertexts of the same field: their format, key and
+// algorithm and, with the keys of -config, their plaintexts. The diff of
+// the plaintexts is printed in the clear; keep it out of tickets.
+//
+// sign-config writes the <manifest>.sig files read by LoadSignedConfig and
+// prints the base64 public key to verify them with. -genkey creates the
+// signing key file first.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+const defaultOutput = "govault_gen.go"
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+
+	switch os.Args[1] {
+	case "gen":
+		if err := runGen(os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "govault gen: %v\n", err)
+			os.Exit(1)
+		}
+	case "inspect":
+		if err := runInspect(os.Args[2:], os.Stdin, os.Stdout); err != nil {
+			fmt.Fprintf(os.Stderr, "govault inspect: %v\n", err)
+			os.Exit(1)
+		}
+	case "diff":
+		if err := runDiff(os.Args[2:], os.Stdout); err != nil {
+			fmt.Fprintf(os.Stderr, "govault diff: %v\n", err)
+			os.Exit(1)
+		}
+	case "sign-config":
+		if err := runSignConfig(os.Args[2:], os.Stdout); err != nil {
+			fmt.Fprintf(os.Stderr, "govault sign-config: %v\n", err)
+			os.Exit(1)
+		}
+	default:
+		usage()
+		os.Exit(2)
+	}
+}
+
+func runGen(args []string) error {
+	fs := flag.NewFlagSet("gen", flag.ExitOnError)
+	dir := fs.String("dir", ".", "package directory containing the models")
+	out := fs.String("out", defaultOutput, "output file name, written to dir")
+	types := fs.String("types", "", "comma separated list of types (default: all with encrypted fields)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	var typeList []string
+	if *types != "" {
+		typeList = strings.Split(*types, ",")
+	}
+
+	src, err := generate(*dir, typeList)
+	if err != nil {
+		return err
+	}
+	return writeGenerated(*dir, *out, src)
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: govault gen [-dir .] [-out govault_gen.go] [-types User,Order]")
+	fmt.Fprintln(os.Stderr, "       govault inspect [ciphertext ...]")
+	fmt.Fprintln(os.Stderr, "       govault diff [-config govault.yaml] ciphertext ciphertext")
+	fmt.Fprintln(os.Stderr, "       govault sign-config -key signing.key [-genkey] [manifest ...]")
+}
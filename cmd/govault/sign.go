@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/muhammadluth/govault"
+)
+
+// runSignConfig writes the signature file of each manifest given as an
+// argument and prints the public key to verify them with. The key file
+// holds a base64 ed25519 seed; -genkey creates it.
+func runSignConfig(args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("sign-config", flag.ExitOnError)
+	keyPath := fs.String("key", "", "file of the base64 ed25519 signing key seed")
+	genKey := fs.Bool("genkey", false, "create the signing key file, which must not exist")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *keyPath == "" {
+		return errors.New("-key is required")
+	}
+
+	var privateKey ed25519.PrivateKey
+	if *genKey {
+		seed := make([]byte, ed25519.SeedSize)
+		if _, err := rand.Read(seed); err != nil {
+			return err
+		}
+		f, err := os.OpenFile(*keyPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(f, base64.StdEncoding.EncodeToString(seed))
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return err
+		}
+		privateKey = ed25519.NewKeyFromSeed(seed)
+	} else {
+		encoded, err := os.ReadFile(*keyPath)
+		if err != nil {
+			return err
+		}
+		seed, err := base64.StdEncoding.DecodeString(string(bytes.TrimSpace(encoded)))
+		if err != nil || len(seed) != ed25519.SeedSize {
+			return fmt.Errorf("%s is not a base64 ed25519 seed", *keyPath)
+		}
+		privateKey = ed25519.NewKeyFromSeed(seed)
+	}
+
+	for _, path := range fs.Args() {
+		manifest, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(path+govault.ManifestSignatureSuffix, govault.SignConfig(manifest, privateKey), 0o644); err != nil {
+			return err
+		}
+	}
+	_, err := fmt.Fprintln(out, base64.StdEncoding.EncodeToString(privateKey.Public().(ed25519.PublicKey)))
+	return err
+}
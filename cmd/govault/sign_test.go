@@ -0,0 +1,39 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/muhammadluth/govault"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSignConfig(t *testing.T) {
+	dir := t.TempDir()
+	keyPath := filepath.Join(dir, "signing.key")
+	manifest := filepath.Join(dir, "govault.yaml")
+	require.NoError(t, os.WriteFile(manifest, []byte("default_key: k1\nkeys:\n  k1:\n    value: 727d37a0-a5f2-4d67-af47-83039c8e\n"), 0o600))
+
+	var out bytes.Buffer
+	require.NoError(t, runSignConfig([]string{"-key", keyPath, "-genkey", manifest}, &out))
+	publicKey, err := base64.StdEncoding.DecodeString(strings.TrimSpace(out.String()))
+	require.NoError(t, err)
+
+	config, err := govault.LoadSignedConfig(manifest, ed25519.PublicKey(publicKey))
+	require.NoError(t, err)
+	assert.Equal(t, "k1", config.DefaultKeyID)
+
+	// Signing again with the same key prints the same public key
+	var again bytes.Buffer
+	require.NoError(t, runSignConfig([]string{"-key", keyPath, manifest}, &again))
+	assert.Equal(t, out.String(), again.String())
+
+	assert.Error(t, runSignConfig([]string{"-key", keyPath, "-genkey"}, &out), "existing keys are not overwritten")
+	assert.Error(t, runSignConfig([]string{manifest}, &out))
+}
@@ -0,0 +1,14 @@
+package models
+
+import "github.com/uptrace/bun"
+
+type Customer struct {
+	bun.BaseModel `bun:"table:customers"`
+
+	ID        int64  `bun:"id,pk,autoincrement"`
+	Email     string `bun:"email" encrypted:"true" deterministic:"true" normalize:"lower,trim" class:"pii-high"`
+	Phone     string `bun:"phone" encrypted:"true" blindindex:"phone_bidx" blindindexbits:"16"`
+	PhoneBidx string `bun:"phone_bidx"`
+	Notes     string `bun:"notes" encrypted:"true" algorithm:"xchacha20poly1305"`
+	Name      string `bun:"name"`
+}
@@ -0,0 +1,10 @@
+package models
+
+import "github.com/uptrace/bun"
+
+type Document struct {
+	bun.BaseModel `bun:"table:documents"`
+
+	ID   int64  `bun:"id,pk,autoincrement"`
+	Body string `bun:"body,type:bytea" encrypted:"true" storage:"binary" encryptpad:"256"`
+}
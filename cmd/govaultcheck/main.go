@@ -0,0 +1,19 @@
+// Command govaultcheck runs the govaultcheck analyzer.
+//
+// Usage:
+//
+//	govaultcheck [-keys primary,secondary] ./...
+//
+// It can be wired into builds with a go:generate directive:
+//
+//	//go:generate go run github.com/muhammadluth/govault/cmd/govaultcheck ./...
+package main
+
+import (
+	"github.com/muhammadluth/govault/govaultcheck"
+	"golang.org/x/tools/go/analysis/singlechecker"
+)
+
+func main() {
+	singlechecker.Main(govaultcheck.Analyzer)
+}
@@ -0,0 +1,217 @@
+// Command govaultd serves the encryption of a govault key config over
+// HTTP and gRPC, see package govaultd.
+//
+// Usage:
+//
+//	govaultd -config govault.yaml -tokens tokens.txt -tls-cert cert.pem -tls-key key.pem
+//	         [-addr :8443] [-grpc-addr :9443] [-public-key base64]
+//
+// -config is the file read by govault.LoadConfig, or by LoadSignedConfig
+// when -public-key is set. -tokens holds the accepted bearer tokens, one
+// per line, optionally followed by their permissions:
+//
+//	# ingest writes only
+//	4f1c... encrypt
+//	# reporting reads only
+//	9a7e... decrypt
+//	b2d0... encrypt,decrypt
+//
+// Tokens without permissions have all of them, as does the one of the
+// GOVAULTD_TOKEN environment variable. -grpc-addr also serves the gRPC
+// API. Both are served over TLS; serving without needs -insecure, e.g.
+// behind a TLS terminating proxy.
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/muhammadluth/govault"
+	"github.com/muhammadluth/govault/govaultd"
+	"github.com/muhammadluth/govault/internal"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+)
+
+// tokenEnv names the environment variable of an extra accepted token
+const tokenEnv = "GOVAULTD_TOKEN"
+
+func main() {
+	if err := run(os.Args[1:]); err != nil {
+		fmt.Fprintf(os.Stderr, "govaultd: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string) error {
+	fs := flag.NewFlagSet("govaultd", flag.ExitOnError)
+	addr := fs.String("addr", ":8443", "address to serve the HTTP API on")
+	grpcAddr := fs.String("grpc-addr", "", "address to serve the gRPC API on, none when empty")
+	configPath := fs.String("config", "", "govault config file")
+	publicKey := fs.String("public-key", "", "base64 ed25519 key verifying the config signature")
+	tokensPath := fs.String("tokens", "", "file of the accepted bearer tokens, one per line")
+	tlsCert := fs.String("tls-cert", "", "TLS certificate file")
+	tlsKey := fs.String("tls-key", "", "TLS private key file")
+	insecure := fs.Bool("insecure", false, "serve without TLS when -tls-cert and -tls-key are not set")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *configPath == "" {
+		return errors.New("-config is required")
+	}
+	if (*tlsCert == "") != (*tlsKey == "") {
+		return errors.New("-tls-cert and -tls-key are set together")
+	}
+	if *tlsCert == "" && !*insecure {
+		return errors.New("-tls-cert and -tls-key are required, or -insecure to serve without TLS")
+	}
+
+	vault, tokens, err := load(*configPath, *publicKey, *tokensPath)
+	if err != nil {
+		return err
+	}
+
+	server := &http.Server{
+		Addr:              *addr,
+		Handler:           govaultd.NewHandler(vault, tokens...),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// The servers stop together: when either fails, or on a signal
+	var grpcServer *grpc.Server
+	grpcErr := make(chan error, 1)
+	if *grpcAddr != "" {
+		var opts []grpc.ServerOption
+		if *tlsCert != "" {
+			creds, err := credentials.NewServerTLSFromFile(*tlsCert, *tlsKey)
+			if err != nil {
+				return fmt.Errorf("failed to load TLS certificate: %w", err)
+			}
+			opts = append(opts, grpc.Creds(creds))
+		}
+		lis, err := net.Listen("tcp", *grpcAddr)
+		if err != nil {
+			return err
+		}
+		grpcServer = govaultd.NewGRPCServer(vault, tokens, opts...)
+		log.Printf("govaultd serving gRPC on %s", *grpcAddr)
+		go func() {
+			grpcErr <- grpcServer.Serve(lis)
+			stop()
+		}()
+	}
+	go func() {
+		<-ctx.Done()
+		shutdown, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		_ = server.Shutdown(shutdown)
+		if grpcServer != nil {
+			grpcServer.GracefulStop()
+		}
+	}()
+
+	log.Printf("govaultd listening on %s", *addr)
+	if *tlsCert != "" {
+		err = server.ListenAndServeTLS(*tlsCert, *tlsKey)
+	} else {
+		err = server.ListenAndServe()
+	}
+	stop()
+	if errors.Is(err, http.ErrServerClosed) {
+		err = nil
+	}
+	if grpcServer != nil {
+		if serveErr := <-grpcErr; err == nil {
+			err = serveErr
+		}
+	}
+	return err
+}
+
+// load reads the config and the tokens to serve
+func load(configPath, publicKey, tokensPath string) (*internal.GovaultDB, []govaultd.Token, error) {
+	var config *govault.Config
+	var err error
+	if publicKey != "" {
+		key, decodeErr := base64.StdEncoding.DecodeString(publicKey)
+		if decodeErr != nil || len(key) != ed25519.PublicKeySize {
+			return nil, nil, errors.New("-public-key is not a base64 ed25519 public key")
+		}
+		config, err = govault.LoadSignedConfig(configPath, ed25519.PublicKey(key))
+	} else {
+		config, err = govault.LoadConfig(configPath)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	vault, err := internal.New(*config)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	tokens, err := loadTokens(tokensPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	if token := os.Getenv(tokenEnv); token != "" {
+		tokens = append(tokens, govaultd.Token{Value: token, Permissions: govaultd.PermAll})
+	}
+	if len(tokens) == 0 {
+		return nil, nil, fmt.Errorf("no tokens: set -tokens or %s", tokenEnv)
+	}
+	return vault, tokens, nil
+}
+
+// loadTokens reads the tokens file, skipping blank lines and # comments.
+// An empty path has no tokens.
+func loadTokens(path string) ([]govaultd.Token, error) {
+	if path == "" {
+		return nil, nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var tokens []govaultd.Token
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		token := govaultd.Token{Value: fields[0], Permissions: govaultd.PermAll}
+		switch len(fields) {
+		case 1:
+		case 2:
+			if token.Permissions, err = govaultd.ParsePermissions(fields[1]); err != nil {
+				return nil, fmt.Errorf("tokens line %d: %w", n, err)
+			}
+		default:
+			return nil, fmt.Errorf("tokens line %d: want a token and its permissions", n)
+		}
+		tokens = append(tokens, token)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read tokens: %w", err)
+	}
+	return tokens, nil
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/muhammadluth/govault/govaultd"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "govault.yaml")
+	require.NoError(t, os.WriteFile(configPath, []byte("default_key: k1\nkeys:\n  k1:\n    value: 727d37a0-a5f2-4d67-af47-83039c8e\n"), 0o600))
+	tokensPath := filepath.Join(dir, "tokens.txt")
+	require.NoError(t, os.WriteFile(tokensPath, []byte("# billing\nt1\n\n  t2  \nt4 encrypt\n"), 0o600))
+
+	tokens, err := loadTokens(tokensPath)
+	require.NoError(t, err)
+	assert.Equal(t, []govaultd.Token{
+		{Value: "t1", Permissions: govaultd.PermAll},
+		{Value: "t2", Permissions: govaultd.PermAll},
+		{Value: "t4", Permissions: govaultd.PermEncrypt},
+	}, tokens)
+
+	t.Setenv(tokenEnv, "t3")
+	vault, tokens, err := load(configPath, "", tokensPath)
+	require.NoError(t, err)
+	handler := govaultd.NewHandler(vault, tokens...)
+	for _, token := range []string{"t2", "t3"} {
+		req := httptest.NewRequest(http.MethodGet, govaultd.PathKeys, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), `"default_key_id":"k1"`)
+	}
+
+	t.Setenv(tokenEnv, "")
+	_, _, err = load(configPath, "", "")
+	assert.ErrorContains(t, err, tokenEnv, "starting without tokens fails")
+
+	_, _, err = load(configPath, "not-a-key", tokensPath)
+	assert.Error(t, err)
+
+	require.NoError(t, os.WriteFile(tokensPath, []byte("t1 admin\n"), 0o600))
+	_, err = loadTokens(tokensPath)
+	assert.ErrorContains(t, err, "tokens line 1")
+}
+
+func TestRunRequiresTLS(t *testing.T) {
+	err := run([]string{"-config", "govault.yaml"})
+	assert.ErrorContains(t, err, "-insecure")
+	err = run([]string{"-config", "govault.yaml", "-tls-cert", "cert.pem"})
+	assert.ErrorContains(t, err, "set together")
+}
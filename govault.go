@@ -1,7 +1,16 @@
 package govault
 
 import (
+	"context"
+	"crypto/ed25519"
+	"database/sql"
+	"encoding/json"
 	"fmt"
+	"iter"
+	"maps"
+	"net/http"
+	"slices"
+	"time"
 
 	"github.com/muhammadluth/govault/internal"
 
@@ -17,9 +26,555 @@ const (
 	AdapterNameGoPg = internal.AdapterNameGoPg
 )
 
+// Codecs selectable per field with the codec tag
+const (
+	CodecCipherSweet = internal.CodecCipherSweet
+	CodecTink        = internal.CodecTink
+)
+
+// Algorithm is the AEAD of native ciphertexts, selected per field with the
+// algorithm tag
+type Algorithm = internal.Algorithm
+
+const (
+	AlgorithmAESGCM            = internal.AlgorithmAESGCM
+	AlgorithmXChaCha20Poly1305 = internal.AlgorithmXChaCha20Poly1305
+)
+
+// AlgorithmOf returns the algorithm a native ciphertext is sealed with
+func AlgorithmOf(value string) (Algorithm, error) {
+	return internal.AlgorithmOf(value)
+}
+
+// FieldConfig declares the encryption of a column outside of struct tags,
+// see Config.Fields
+type FieldConfig = internal.FieldConfig
+
+// EnvelopeCodec reads a foreign AES-256-GCM ciphertext format, see
+// Config.EnvelopeCodecs
+type EnvelopeCodec = internal.EnvelopeCodec
+
+// Envelope is a ciphertext taken apart by an EnvelopeCodec
+type Envelope = internal.Envelope
+
+// LoadConfig reads a YAML or JSON config file with ${NAME} environment
+// references. Set the database, and the KeyProvider for kms keys, before
+// passing the result to New.
+func LoadConfig(path string) (*Config, error) {
+	return internal.LoadConfig(path)
+}
+
+// ErrManifestSignature is returned by LoadSignedConfig for manifests whose
+// signature is missing or does not verify
+var ErrManifestSignature = internal.ErrManifestSignature
+
+// ManifestSignatureSuffix is appended to the path of a manifest to find
+// its signature file
+const ManifestSignatureSuffix = internal.ManifestSignatureSuffix
+
+// LoadSignedConfig is LoadConfig for a manifest signed with SignConfig or
+// govault sign-config, verified against publicKey so a tampered deployment
+// config can't downgrade encryption. ${NAME} references are rejected.
+func LoadSignedConfig(path string, publicKey ed25519.PublicKey) (*Config, error) {
+	return internal.LoadSignedConfig(path, publicKey)
+}
+
+// SignConfig returns the content of the signature file of manifest
+func SignConfig(manifest []byte, privateKey ed25519.PrivateKey) []byte {
+	return internal.SignConfig(manifest, privateKey)
+}
+
+// KeyIDSource returns the key ID GovaultDB.WatchDefaultKey switches to
+type KeyIDSource = internal.KeyIDSource
+
+// EnvKeyID reads the key ID from an environment variable
+func EnvKeyID(name string) KeyIDSource {
+	return internal.EnvKeyID(name)
+}
+
+// FileKeyID reads the key ID from a file, e.g. a mounted secret
+func FileKeyID(path string) KeyIDSource {
+	return internal.FileKeyID(path)
+}
+
+// DefaultKeyIDFile is the file of a key directory naming the default key
+const DefaultKeyIDFile = internal.DefaultKeyIDFile
+
+// LoadKeyDir reads a directory of key files, e.g. a mounted Kubernetes
+// Secret, see GovaultDB.WatchKeyDir
+func LoadKeyDir(dir, encoding string) (map[string][]byte, string, error) {
+	return internal.LoadKeyDir(dir, encoding)
+}
+
+// Re-export client modes from internal
+type Mode = internal.Mode
+
+const (
+	ModeReadWrite   = internal.ModeReadWrite
+	ModeEncryptOnly = internal.ModeEncryptOnly
+	ModeDecryptOnly = internal.ModeDecryptOnly
+)
+
+// ErrOperationNotAllowed is wrapped by errors of operations forbidden by
+// Config.Mode
+var ErrOperationNotAllowed = internal.ErrOperationNotAllowed
+
+// FormatPrefix is the prefix of values written by Encrypt
+const FormatPrefix = internal.FormatPrefix
+
+// HPKEFormatPrefix is the prefix of values encrypted with an HPKE key
+const HPKEFormatPrefix = internal.HPKEFormatPrefix
+
+// MaxCiphertextLength bounds the encrypted values accepted by Decrypt
+const MaxCiphertextLength = internal.MaxCiphertextLength
+
+// CiphertextInfo is the header metadata of a ciphertext, see
+// InspectCiphertext
+type CiphertextInfo = internal.CiphertextInfo
+
+// Formats reported by InspectCiphertext
+const (
+	CiphertextFormatNative      = internal.CiphertextFormatNative
+	CiphertextFormatLegacy      = internal.CiphertextFormatLegacy
+	CiphertextFormatHPKE        = internal.CiphertextFormatHPKE
+	CiphertextFormatCipherSweet = internal.CiphertextFormatCipherSweet
+)
+
+// InspectCiphertext returns the format, key ID, algorithm and flags of a
+// ciphertext without decrypting it. GovaultDB.InspectCiphertext also tells
+// deterministic values apart.
+func InspectCiphertext(value string) (*CiphertextInfo, error) {
+	return internal.InspectCiphertext(value)
+}
+
+// CiphertextDiff compares two ciphertexts of the same field, see
+// DiffCiphertexts
+type CiphertextDiff = internal.CiphertextDiff
+
+// DiffCiphertexts compares the format, key and algorithm of two
+// ciphertexts without keys. GovaultDB.DiffCiphertexts also decrypts both
+// and shows how their plaintexts differ.
+func DiffCiphertexts(a, b string) *CiphertextDiff {
+	return internal.DiffCiphertexts(a, b)
+}
+
+// EncryptedAt returns the encryption time embedded in a native ciphertext,
+// see Config.EmbedEncryptedAt
+func EncryptedAt(value string) (time.Time, bool) {
+	return internal.EncryptedAt(value)
+}
+
+// FormatError reports a malformed encrypted value
+type FormatError = internal.FormatError
+
+// ErrInvalidCiphertext is matched by every *FormatError
+var ErrInvalidCiphertext = internal.ErrInvalidCiphertext
+
+// GenerateHPKEKey returns a new X25519 key pair for Config.HPKEPublicKeys
+// and Config.HPKEPrivateKeys
+func GenerateHPKEKey() (publicKey, privateKey []byte, err error) {
+	return internal.GenerateHPKEKey()
+}
+
+// IsEncrypted reports whether value is a govault ciphertext, including the
+// legacy unprefixed format
+func IsEncrypted(value string) bool {
+	return internal.IsEncrypted(value)
+}
+
+// EncodeBinary converts a ciphertext for a binary column, see the
+// storage:"binary" tag
+func EncodeBinary(value string) ([]byte, error) {
+	return internal.EncodeBinary(value)
+}
+
+// DecodeBinary converts a value of a binary column back to the text format
+func DecodeBinary(data []byte) (string, error) {
+	return internal.DecodeBinary(data)
+}
+
+// Tokenize splits plaintext into the words indexed by tokenindex fields and
+// searched by BunSelectQuery.WhereToken
+func Tokenize(plaintext string) []string {
+	return internal.Tokenize(plaintext)
+}
+
+// FormatLatLon returns the plaintext of a location field with a geohash
+// tag, "lat,lon"
+func FormatLatLon(lat, lon float64) string {
+	return internal.FormatLatLon(lat, lon)
+}
+
+// ParseLatLon parses the plaintext of a location field, "lat,lon"
+func ParseLatLon(plaintext string) (lat, lon float64, err error) {
+	return internal.ParseLatLon(plaintext)
+}
+
+// SplitEmail returns the local part and the domain of an email address
+func SplitEmail(address string) (local, domain string, err error) {
+	return internal.SplitEmail(address)
+}
+
+// ValidPAN reports whether pan is 12 to 19 digits passing the Luhn check
+func ValidPAN(pan string) bool {
+	return internal.ValidPAN(pan)
+}
+
+// MaskPAN returns pan with every digit but the BIN and the last four
+// replaced by *
+func MaskPAN(pan string) string {
+	return internal.MaskPAN(pan)
+}
+
+// Field is a column type that encrypts on Value and decrypts on Scan, an
+// alternative to the encrypted tag that works with any ORM
+type Field[T any] = internal.Field[T]
+
+// NewField returns a valid Field holding v
+func NewField[T any](v T) Field[T] {
+	return internal.NewField(v)
+}
+
+// SetFieldVault sets the vault used by Field values not bound with
+// Field.WithVault
+func SetFieldVault(g *GovaultDB) {
+	if g == nil {
+		internal.SetFieldVault(nil)
+		return
+	}
+	internal.SetFieldVault(g.GovaultDB)
+}
+
+// Re-export canary types from internal
+type Canary = internal.Canary
+type CanaryHit = internal.CanaryHit
+type CanaryAlert = internal.CanaryAlert
+type CanaryDetector = internal.CanaryDetector
+
+// CanaryAlertTimeout bounds the alerts of CanaryDetector writers and the
+// requests of CanaryWebhook
+const CanaryAlertTimeout = internal.CanaryAlertTimeout
+
+// NewCanaryDetector returns a detector for canaries, see BunDB.CanaryDetector
+// to load the planted ones
+func NewCanaryDetector(canaries ...Canary) *CanaryDetector {
+	return internal.NewCanaryDetector(canaries...)
+}
+
+// CanaryWebhook returns an alert posting canary hits as JSON to url
+func CanaryWebhook(url string, client *http.Client) CanaryAlert {
+	return internal.CanaryWebhook(url, client)
+}
+
+// Re-export Tink keyset types from internal
+type TinkKeyset = internal.TinkKeyset
+type TinkAEAD = internal.TinkAEAD
+
+// LoadTinkKeyset parses a cleartext Tink JSON keyset. Use its Keys and
+// PrimaryKeyID as Config.Keys and Config.DefaultKeyID.
+func LoadTinkKeyset(data []byte) (*TinkKeyset, error) {
+	return internal.LoadTinkKeyset(data)
+}
+
+// LoadEncryptedTinkKeyset parses a Tink JSON keyset encrypted with kek,
+// e.g. a KMS-backed tink.AEAD
+func LoadEncryptedTinkKeyset(data []byte, kek TinkAEAD) (*TinkKeyset, error) {
+	return internal.LoadEncryptedTinkKeyset(data, kek)
+}
+
+// Re-export key provider types from internal
+type KeyProvider = internal.KeyProvider
+type KeyProviderFunc = internal.KeyProviderFunc
+type ProviderKey = internal.ProviderKey
+
+// Re-export provider fallbacks from internal
+type Fallback = internal.Fallback
+
+const (
+	FallbackFailClosed = internal.FallbackFailClosed
+	FallbackCiphertext = internal.FallbackCiphertext
+	FallbackMask       = internal.FallbackMask
+	FallbackMaskValue  = internal.FallbackMaskValue
+)
+
+// ErrKeyUnavailable is wrapped by errors of provider keys that could not be
+// unwrapped
+var ErrKeyUnavailable = internal.ErrKeyUnavailable
+
+// TinkAEADProvider returns a provider unwrapping with the AEAD registered
+// for each key URI
+func TinkAEADProvider(aeads map[string]TinkAEAD) KeyProvider {
+	return internal.TinkAEADProvider(aeads)
+}
+
+// StaticKeyProvider returns the data key stored for each key URI, e.g. for
+// development without a KMS
+func StaticKeyProvider(keys map[string][]byte) KeyProvider {
+	return internal.StaticKeyProvider(keys)
+}
+
+// ChainProviders returns a provider trying providers in order until one
+// unwraps the key
+func ChainProviders(providers ...KeyProvider) KeyProvider {
+	return internal.ChainProviders(providers...)
+}
+
+// CacheProvider caches the keys unwrapped by provider for ttl, zero meaning
+// forever
+func CacheProvider(provider KeyProvider, ttl time.Duration) KeyProvider {
+	return internal.CacheProvider(provider, ttl)
+}
+
+// Re-export key archive types from internal
+type KeyArchive = internal.KeyArchive
+type KeyArchiveFunc = internal.KeyArchiveFunc
+
+// StaticKeyArchive returns an archive of keys held in memory
+func StaticKeyArchive(keys map[string][]byte) KeyArchive {
+	return internal.StaticKeyArchive(keys)
+}
+
+// Re-export key escrow types from internal
+type EscrowOptions = internal.EscrowOptions
+type Escrow = internal.Escrow
+type EscrowShare = internal.EscrowShare
+type EscrowRecord = internal.EscrowRecord
+
+const (
+	EscrowOperationExport  = internal.EscrowOperationExport
+	EscrowOperationRecover = internal.EscrowOperationRecover
+)
+
+// RecoverEscrow returns the keys of an escrow from the shares of enough
+// approvers, see GovaultDB.ExportEscrow
+func RecoverEscrow(ctx context.Context, escrow *Escrow, shares []EscrowShare, purpose string, audit func(ctx context.Context, record *EscrowRecord, err error)) (map[string][]byte, error) {
+	return internal.RecoverEscrow(ctx, escrow, shares, purpose, audit)
+}
+
+// NonceCheck configures nonce reuse detection, see Config.NonceCheck
+type NonceCheck = internal.NonceCheck
+
+// ErrNonceReuse is returned by encryptions reusing a nonce
+var ErrNonceReuse = internal.ErrNonceReuse
+
+// Re-export decrypt failure detection types from internal
+type FailureDetector = internal.FailureDetector
+type FailureEvent = internal.FailureEvent
+type FailureStat = internal.FailureStat
+
+// Re-export rate limit types from internal
+type RateLimit = internal.RateLimit
+type RateLimitError = internal.RateLimitError
+
+// RateLimitAllKeys is the Config.RateLimits entry of the keys without one
+const RateLimitAllKeys = internal.RateLimitAllKeys
+
+// ErrRateLimited is matched by the errors of operations over the rate
+// limit of their key
+var ErrRateLimited = internal.ErrRateLimited
+
+// Re-export read transforms from internal
+type Transform = internal.Transform
+
+const (
+	TransformHash    = internal.TransformHash
+	TransformMonth   = internal.TransformMonth
+	TransformYear    = internal.TransformYear
+	TransformZip3    = internal.TransformZip3
+	TransformLast4   = internal.TransformLast4
+	TransformRedact  = internal.TransformRedact
+	TransformMaskPAN = internal.TransformMaskPAN
+
+	TransformAnyRole = internal.TransformAnyRole
+)
+
+// RegisterTransform makes t available to transform tags under name
+func RegisterTransform(name string, t Transform) {
+	internal.RegisterTransform(name, t)
+}
+
+// WithTransformRole returns a context whose reads through the wrapper
+// queries apply the transforms configured for role
+func WithTransformRole(ctx context.Context, role string) context.Context {
+	return internal.WithTransformRole(ctx, role)
+}
+
+// Normalizer canonicalizes a plaintext before it is encrypted and
+// indexed, selected per field with the normalize tag
+type Normalizer = internal.Normalizer
+
+const (
+	NormalizeTrim  = internal.NormalizeTrim
+	NormalizeLower = internal.NormalizeLower
+	NormalizeEmail = internal.NormalizeEmail
+	NormalizeE164  = internal.NormalizeE164
+	NormalizePAN   = internal.NormalizePAN
+	NormalizeSSN   = internal.NormalizeSSN
+	NormalizeNIK   = internal.NormalizeNIK
+	NormalizeIBAN  = internal.NormalizeIBAN
+)
+
+// RegisterNormalizer makes n available to normalize tags under name
+func RegisterNormalizer(name string, n Normalizer) {
+	internal.RegisterNormalizer(name, n)
+}
+
+// NormalizeField runs the normalizers of field on plaintext, in order, as
+// done before encrypting and indexing it
+func NormalizeField(field *FieldSpec, plaintext string) (string, error) {
+	return internal.NormalizeField(field, plaintext)
+}
+
+// Preset bundles the recommended settings of a class of values, applied
+// with the preset tag
+type Preset = internal.Preset
+
+const (
+	PresetSSN  = internal.PresetSSN
+	PresetNIK  = internal.PresetNIK
+	PresetIBAN = internal.PresetIBAN
+)
+
+// RegisterPreset makes p available to preset tags under name
+func RegisterPreset(name string, p Preset) {
+	internal.RegisterPreset(name, p)
+}
+
+// PhoneNormalizer returns a normalizer formatting phone numbers as E.164,
+// reading numbers without a country code as national ones of countryCode
+func PhoneNormalizer(countryCode string) Normalizer {
+	return internal.PhoneNormalizer(countryCode)
+}
+
+// Re-export purpose of use propagation from internal
+type Access = internal.Access
+type AccessOptions = internal.AccessOptions
+
+const (
+	AccessPurposeHeader = internal.AccessPurposeHeader
+	AccessActorHeader   = internal.AccessActorHeader
+)
+
+// WithAccess returns a context declaring who decrypts and why
+func WithAccess(ctx context.Context, access Access) context.Context {
+	return internal.WithAccess(ctx, access)
+}
+
+// AccessFromContext returns the access set with WithAccess, or zero
+func AccessFromContext(ctx context.Context) Access {
+	return internal.AccessFromContext(ctx)
+}
+
+// AccessMiddleware returns HTTP middleware setting the Access of every
+// request on its context
+func AccessMiddleware(opts AccessOptions) func(http.Handler) http.Handler {
+	return internal.AccessMiddleware(opts)
+}
+
+// AccessFromMetadata returns ctx with the Access read from md, e.g. the
+// metadata.MD of a gRPC interceptor
+func AccessFromMetadata(ctx context.Context, md map[string][]string, opts AccessOptions) (context.Context, error) {
+	return internal.AccessFromMetadata(ctx, md, opts)
+}
+
+// Re-export request decrypt budgets from internal
+type DecryptBudget = internal.DecryptBudget
+
+// ErrDecryptBudgetExceeded is matched by the errors of reads over the
+// budget set with WithDecryptBudget
+var ErrDecryptBudgetExceeded = internal.ErrDecryptBudgetExceeded
+
+// WithDecryptBudget returns a context whose reads through the wrapper
+// queries decrypt at most the fields and bytes of budget in total
+func WithDecryptBudget(ctx context.Context, budget DecryptBudget) context.Context {
+	return internal.WithDecryptBudget(ctx, budget)
+}
+
+// DecryptBudgetSpent returns the fields and bytes decrypted so far under the
+// budget of ctx
+func DecryptBudgetSpent(ctx context.Context) (fields int, bytes int64) {
+	return internal.DecryptBudgetSpent(ctx)
+}
+
+// Re-export Field serializers from internal
+type Serializer = internal.Serializer
+
+const (
+	SerializerJSON    = internal.SerializerJSON
+	SerializerMsgpack = internal.SerializerMsgpack
+	SerializerProto   = internal.SerializerProto
+)
+
+// RegisterSerializer makes s available to serializer tags under id
+func RegisterSerializer(id string, s Serializer) {
+	internal.RegisterSerializer(id, s)
+}
+
+// DecryptedCopy returns a deep copy of model with its encrypted fields
+// decrypted, leaving model encrypted
+func DecryptedCopy[T any](g *GovaultDB, model T) (T, error) {
+	return internal.DecryptedCopy(g.GovaultDB, model)
+}
+
+// RedactedCopy returns a deep copy of model whose non-empty encrypted
+// fields are replaced by FallbackMaskValue
+func RedactedCopy[T any](model T) T {
+	return internal.RedactedCopy(model)
+}
+
+// GuardJSON wraps v so that json.Marshal masks its fields tagged
+// redactjson:"true", unless ctx was returned by WithJSONUnlock
+func GuardJSON(ctx context.Context, v any) json.Marshaler {
+	return internal.GuardJSON(ctx, v)
+}
+
+// WithJSONUnlock returns a context whose GuardJSON values encode the fields
+// tagged redactjson:"true" as they are
+func WithJSONUnlock(ctx context.Context) context.Context {
+	return internal.WithJSONUnlock(ctx)
+}
+
+// SchemaMigration upgrades a Field value of one schema version to the next
+type SchemaMigration = internal.SchemaMigration
+
+// RegisterSchema versions the plaintext of the Field values of type T:
+// migrations[0] upgrades version 1 to 2 and so on. Older values are
+// upgraded on Scan, so stored rows need no rewrite when T changes.
+func RegisterSchema[T any](migrations ...SchemaMigration) {
+	internal.RegisterSchema[T](migrations...)
+}
+
+// Re-export model hooks from internal
+type ModelHook = internal.ModelHook
+type ModelEvent = internal.ModelEvent
+type Operation = internal.Operation
+
+const (
+	OperationSelect = internal.OperationSelect
+	OperationInsert = internal.OperationInsert
+	OperationUpdate = internal.OperationUpdate
+	OperationDelete = internal.OperationDelete
+	OperationRaw    = internal.OperationRaw
+	OperationValues = internal.OperationValues
+)
+
+// Cryptor is the value encryption API of GovaultDB. Code that only
+// encrypts and decrypts values can depend on it and be unit tested with
+// govaulttest.MockCryptor, without keys.
+type Cryptor interface {
+	Encrypt(plaintext string, keyID ...string) (string, error)
+	Decrypt(encryptedData string) (string, error)
+	DecryptRecursive(value any) error
+	GetKeyIDs() []string
+	GetDefaultKeyID() string
+}
+
+var _ Cryptor = (*GovaultDB)(nil)
+
 // GovaultDB is now a wrapper struct embedding the internal type
 type GovaultDB struct {
 	*internal.GovaultDB
+	named map[string]*gb.BunDB // Config.BunDBs by name
 }
 
 // New creates a new govault DB and returns the wrapper
@@ -34,8 +589,34 @@ func New(config Config) (*GovaultDB, error) {
 		return nil, err
 	}
 
+	named, err := wrapNamed(config, internalGovault)
+	if err != nil {
+		return nil, err
+	}
+
 	internalGovault.DB = adapter
-	return &GovaultDB{internalGovault}, nil // Return the wrapper
+	return &GovaultDB{GovaultDB: internalGovault, named: named}, nil // Return the wrapper
+}
+
+// wrapNamed wraps the databases of Config.BunDBs with govault
+func wrapNamed(config Config, govault *internal.GovaultDB) (map[string]*gb.BunDB, error) {
+	if len(config.BunDBs) == 0 {
+		return nil, nil
+	}
+	if config.AdapterName != AdapterNameBun {
+		return nil, fmt.Errorf("named databases require the bun adapter")
+	}
+	named := make(map[string]*gb.BunDB, len(config.BunDBs))
+	for name, db := range config.BunDBs {
+		if name == "" {
+			return nil, fmt.Errorf("named database without a name, use BunDB")
+		}
+		if db == nil {
+			return nil, fmt.Errorf("BunDB '%s' is nil", name)
+		}
+		named[name] = gb.BunWrapQueries(db, govault).(*gb.BunDB)
+	}
+	return named, nil
 }
 
 // detectAdapter detects which ORM adapter to use
@@ -55,6 +636,16 @@ func detectAdapter(config Config, govault *internal.GovaultDB) (any, error) {
 		// db := GoPgWrapQueries(config.GoPgDB, govault)
 		// return db, nil
 	}
+	if factory, ok := lookupAdapter(config.AdapterName); ok {
+		if config.AdapterDB == nil {
+			return nil, fmt.Errorf("AdapterDB is nil")
+		}
+		db, err := factory(config.AdapterDB, govault)
+		if err != nil {
+			return nil, fmt.Errorf("adapter %s: %w", config.AdapterName, err)
+		}
+		return db, nil
+	}
 	return nil, fmt.Errorf("unsupported ORM: %s", config.AdapterName)
 }
 
@@ -66,6 +657,243 @@ func (g *GovaultDB) BunDB() *gb.BunDB {
 	return nil
 }
 
+// BunDBFor returns the database registered under name in Config.BunDBs, or
+// BunDB for the empty name. Its queries encrypt and decrypt with the keys
+// of g. It returns nil for unknown names.
+func (g *GovaultDB) BunDBFor(name string) *gb.BunDB {
+	if name == "" {
+		return g.BunDB()
+	}
+	return g.named[name]
+}
+
+// BunDBNames returns the names of Config.BunDBs in order
+func (g *GovaultDB) BunDBNames() []string {
+	return slices.Sorted(maps.Keys(g.named))
+}
+
+// Re-export shard routing types from the bun adapter
+type ShardRouter = gb.ShardRouter
+
+// ErrNoShardKey is returned by ShardRouter.DB for contexts without a shard
+// key
+var ErrNoShardKey = gb.ErrNoShardKey
+
+// WithShard returns a context routing ShardRouter.DB to the shard of
+// shardKey
+func WithShard(ctx context.Context, shardKey string) context.Context {
+	return gb.WithShard(ctx, shardKey)
+}
+
+// ShardRouter returns a router over the databases of Config.BunDBs named
+// shards, in order, see the bun adapter NewShardRouter
+func (g *GovaultDB) ShardRouter(shards ...string) (*ShardRouter, error) {
+	dbs := make([]*gb.BunDB, len(shards))
+	for i, name := range shards {
+		if dbs[i] = g.BunDBFor(name); dbs[i] == nil {
+			return nil, fmt.Errorf("database '%s' not found", name)
+		}
+	}
+	return gb.NewShardRouter(dbs...)
+}
+
+// KeyStatus is the state of one key reported by KeyStatuses
+type KeyStatus = internal.KeyStatus
+
+// ComplianceReport lists the classified columns of a set of models and
+// the keys protecting them, see GovaultDB.ComplianceReport
+type ComplianceReport = internal.ComplianceReport
+
+// ComplianceField describes the protection of a classified column
+type ComplianceField = internal.ComplianceField
+
+// HealthCheck fails unless the required keys are usable and the databases
+// answer a ping
+func (g *GovaultDB) HealthCheck(ctx context.Context) error {
+	if err := g.GovaultDB.HealthCheck(ctx); err != nil {
+		return err
+	}
+	if bunDB := g.BunDB(); bunDB != nil {
+		if err := bunDB.PingContext(ctx); err != nil {
+			return fmt.Errorf("failed to ping database: %w", err)
+		}
+	}
+	for _, name := range g.BunDBNames() {
+		if err := g.named[name].PingContext(ctx); err != nil {
+			return fmt.Errorf("failed to ping database '%s': %w", name, err)
+		}
+	}
+	return nil
+}
+
+// DriftIssue describes a mismatch reported by CheckDrift
+type DriftIssue = gb.DriftIssue
+
+// CheckDrift compares the struct tags of models with the govault_columns
+// catalog and a sample of stored values, see BunDB.CheckDrift
+func (g *GovaultDB) CheckDrift(ctx context.Context, models ...any) ([]DriftIssue, error) {
+	if bunDB := g.BunDB(); bunDB != nil {
+		return bunDB.CheckDrift(ctx, models...)
+	}
+	return nil, fmt.Errorf("drift detection is not supported by this adapter")
+}
+
+// ErrEncryptedColumn is wrapped by errors of operations that need the
+// plaintext order or value of an encrypted column, e.g. BunSelectQuery.Paginate
+var ErrEncryptedColumn = gb.ErrEncryptedColumn
+
+// Re-export rotation plan types from the bun adapter
+type RotationPlan = gb.RotationPlan
+type RotationResult = gb.RotationResult
+type RotationJob = gb.RotationJob
+
+// PlanRotation estimates the work of re-encrypting models with
+// targetKeyID, see BunDB.PlanRotation
+func (g *GovaultDB) PlanRotation(ctx context.Context, models []any, targetKeyID string, batchSize int) (*RotationPlan, error) {
+	if bunDB := g.BunDB(); bunDB != nil {
+		return bunDB.PlanRotation(ctx, models, targetKeyID, batchSize)
+	}
+	return nil, fmt.Errorf("rotation is not supported by this adapter")
+}
+
+// ExecuteRotation runs a plan returned by PlanRotation, see
+// BunDB.ExecuteRotation
+func (g *GovaultDB) ExecuteRotation(ctx context.Context, plan *RotationPlan) (*RotationResult, error) {
+	if bunDB := g.BunDB(); bunDB != nil {
+		return bunDB.ExecuteRotation(ctx, plan)
+	}
+	return nil, fmt.Errorf("rotation is not supported by this adapter")
+}
+
+// Re-export online rotation types from internal
+type OnlineRotation = internal.OnlineRotation
+type RotationStage = internal.RotationStage
+type RotationVerification = internal.RotationVerification
+
+const (
+	StageIdle       = internal.StageIdle
+	StageDualRead   = internal.StageDualRead
+	StageEncryptNew = internal.StageEncryptNew
+	StageVerified   = internal.StageVerified
+	StageFinalized  = internal.StageFinalized
+)
+
+// VerifyRotation samples models for data still depending on oldKeyID, see
+// BunDB.VerifyRotation
+func (g *GovaultDB) VerifyRotation(ctx context.Context, models []any, oldKeyID string, sampleSize int) (*RotationVerification, error) {
+	if bunDB := g.BunDB(); bunDB != nil {
+		return bunDB.VerifyRotation(ctx, models, oldKeyID, sampleSize)
+	}
+	return nil, fmt.Errorf("rotation is not supported by this adapter")
+}
+
+// Re-export retention sweep types from the bun adapter
+type SweepAction = gb.SweepAction
+type SweepOptions = gb.SweepOptions
+type SweepResult = gb.SweepResult
+
+const (
+	SweepNull      = gb.SweepNull
+	SweepTombstone = gb.SweepTombstone
+)
+
+// SweepExpired erases values of encryptttl fields older than their TTL, see
+// BunDB.SweepExpired
+func (g *GovaultDB) SweepExpired(ctx context.Context, models []any, opts SweepOptions) (*SweepResult, error) {
+	if bunDB := g.BunDB(); bunDB != nil {
+		return bunDB.SweepExpired(ctx, models, opts)
+	}
+	return nil, fmt.Errorf("sweeping is not supported by this adapter")
+}
+
+// Re-export soft delete erasure types from the bun adapter
+type EraseOptions = gb.EraseOptions
+type PurgeOptions = gb.PurgeOptions
+type PurgeResult = gb.PurgeResult
+
+// PurgeDeleted erases the encrypted values of soft-deleted rows, see
+// BunDB.PurgeDeleted
+func (g *GovaultDB) PurgeDeleted(ctx context.Context, models []any, opts PurgeOptions) (*PurgeResult, error) {
+	if bunDB := g.BunDB(); bunDB != nil {
+		return bunDB.PurgeDeleted(ctx, models, opts)
+	}
+	return nil, fmt.Errorf("purging is not supported by this adapter")
+}
+
+// Re-export decrypted copy types from the bun adapter
+type MaterializeOptions = gb.MaterializeOptions
+type MaterializeResult = gb.MaterializeResult
+
+// Materialize copies a table with its encrypted columns decrypted into
+// another table, see BunDB.Materialize
+func (g *GovaultDB) Materialize(ctx context.Context, model any, opts MaterializeOptions) (*MaterializeResult, error) {
+	if bunDB := g.BunDB(); bunDB != nil {
+		return bunDB.Materialize(ctx, model, opts)
+	}
+	return nil, fmt.Errorf("materializing is not supported by this adapter")
+}
+
+// Re-export bulk loading types from the bun adapter
+type CopyOptions = gb.CopyOptions
+type CopyResult = gb.CopyResult
+
+const DefaultCopyBatchSize = gb.DefaultCopyBatchSize
+
+// CopyFrom bulk loads models with their encrypted fields encrypted in
+// parallel, see BunDB.CopyFrom
+func (g *GovaultDB) CopyFrom(ctx context.Context, models iter.Seq[any], opts CopyOptions) (*CopyResult, error) {
+	if bunDB := g.BunDB(); bunDB != nil {
+		return bunDB.CopyFrom(ctx, models, opts)
+	}
+	return nil, fmt.Errorf("bulk loading is not supported by this adapter")
+}
+
+// Re-export storage report types from the bun adapter
+type SizeReport = gb.SizeReport
+type ColumnSize = gb.ColumnSize
+
+// SizeReport estimates the storage overhead of the encrypted columns of
+// model, see BunDB.SizeReport
+func (g *GovaultDB) SizeReport(ctx context.Context, model any) (*SizeReport, error) {
+	if bunDB := g.BunDB(); bunDB != nil {
+		return bunDB.SizeReport(ctx, model)
+	}
+	return nil, fmt.Errorf("size reports are not supported by this adapter")
+}
+
+// Re-export algorithm migration types from the bun adapter
+type AlgorithmMigrationOptions = gb.AlgorithmMigrationOptions
+type AlgorithmMigrationResult = gb.AlgorithmMigrationResult
+
+// MigrateAlgorithm rewrites the values of models sealed with another
+// algorithm than their field's, see BunDB.MigrateAlgorithm
+func (g *GovaultDB) MigrateAlgorithm(ctx context.Context, models []any, opts AlgorithmMigrationOptions) (*AlgorithmMigrationResult, error) {
+	if bunDB := g.BunDB(); bunDB != nil {
+		return bunDB.MigrateAlgorithm(ctx, models, opts)
+	}
+	return nil, fmt.Errorf("algorithm migration is not supported by this adapter")
+}
+
+type UniqueResult = gb.UniqueResult
+
+// EnsureUniqueEncrypted enforces unique plaintexts on an encrypted field of
+// model, see BunDB.EnsureUniqueEncrypted
+func (g *GovaultDB) EnsureUniqueEncrypted(ctx context.Context, model any, fieldName string) (*UniqueResult, error) {
+	if bunDB := g.BunDB(); bunDB != nil {
+		return bunDB.EnsureUniqueEncrypted(ctx, model, fieldName)
+	}
+	return nil, fmt.Errorf("unique constraints are not supported by this adapter")
+}
+
+// InsertOrUpdateByEncrypted upserts model by the plaintext of an encrypted
+// column, see BunDB.InsertOrUpdateByEncrypted
+func (g *GovaultDB) InsertOrUpdateByEncrypted(ctx context.Context, model any, column string) (sql.Result, error) {
+	if bunDB := g.BunDB(); bunDB != nil {
+		return bunDB.InsertOrUpdateByEncrypted(ctx, model, column)
+	}
+	return nil, fmt.Errorf("upserts are not supported by this adapter")
+}
+
 // // GoPgDB returns the underlying go-pg database
 // func (g *GovaultDB) GoPgDB() *GoPgDB {
 // 	if goPgDB, ok := g.DB.(*GoPgDB); ok {
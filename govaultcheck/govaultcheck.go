@@ -0,0 +1,300 @@
+// Package govaultcheck provides a static analyzer that flags common govault
+// mistakes at build time:
+//
+//   - encrypted tag on an unexported field, which reflection cannot set
+//   - encrypted tag on a non-string field, which is silently left in plaintext
+//   - Set("column = ?") on an encrypted column, which bypasses encryption
+//   - WithKey with a literal key ID that is not a known key
+//   - encryptttl values that are not positive durations, which disable the
+//     retention policy
+//   - encryptpad values that are not lists of positive sizes, which disable
+//     padding
+//
+// Known key IDs are collected from govault.Config literals in the analyzed
+// package and from the -keys flag. The WithKey check is skipped when no key
+// IDs are known.
+package govaultcheck
+
+import (
+	"go/ast"
+	"go/constant"
+	"go/types"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/passes/inspect"
+	"golang.org/x/tools/go/ast/inspector"
+)
+
+const doc = `check govault struct tags and query usage
+
+Reports encrypted tags on unexported fields or on fields other than strings
+and string slices, Set clauses that write encrypted columns unencrypted,
+WithKey calls with unknown key IDs, invalid encryptttl durations and invalid
+encryptpad sizes.`
+
+// Analyzer is the govaultcheck analyzer
+var Analyzer = &analysis.Analyzer{
+	Name:     "govaultcheck",
+	Doc:      doc,
+	Run:      run,
+	Requires: []*analysis.Analyzer{inspect.Analyzer},
+}
+
+// keys is a comma separated list of key IDs accepted by WithKey
+var keys string
+
+func init() {
+	Analyzer.Flags.StringVar(&keys, "keys", "", "comma separated list of known key IDs")
+}
+
+func run(pass *analysis.Pass) (any, error) {
+	insp := pass.ResultOf[inspect.Analyzer].(*inspector.Inspector)
+
+	known := make(map[string]bool)
+	for _, k := range strings.Split(keys, ",") {
+		if k = strings.TrimSpace(k); k != "" {
+			known[k] = true
+		}
+	}
+
+	insp.Preorder([]ast.Node{(*ast.StructType)(nil), (*ast.CompositeLit)(nil)}, func(n ast.Node) {
+		switch n := n.(type) {
+		case *ast.StructType:
+			checkStruct(pass, n)
+		case *ast.CompositeLit:
+			collectKeys(pass, n, known)
+		}
+	})
+
+	insp.Preorder([]ast.Node{(*ast.CallExpr)(nil)}, func(n ast.Node) {
+		call := n.(*ast.CallExpr)
+		sel, ok := call.Fun.(*ast.SelectorExpr)
+		if !ok {
+			return
+		}
+		switch sel.Sel.Name {
+		case "Set":
+			checkSet(pass, call, sel)
+		case "WithKey":
+			checkWithKey(pass, call, known)
+		}
+	})
+
+	return nil, nil
+}
+
+// checkStruct reports encrypted tags the runtime cannot honour
+func checkStruct(pass *analysis.Pass, st *ast.StructType) {
+	for _, f := range st.Fields.List {
+		if f.Tag == nil {
+			continue
+		}
+		tag, err := strconv.Unquote(f.Tag.Value)
+		if err != nil || reflect.StructTag(tag).Get("encrypted") != "true" {
+			continue
+		}
+
+		if ttl, ok := reflect.StructTag(tag).Lookup("encryptttl"); ok {
+			if d, err := time.ParseDuration(ttl); err != nil || d <= 0 {
+				pass.Reportf(f.Tag.Pos(), "encryptttl %q is not a positive duration, the field is never swept", ttl)
+			}
+		}
+		if buckets, ok := reflect.StructTag(tag).Lookup("encryptpad"); ok && !validPadBuckets(buckets) {
+			pass.Reportf(f.Tag.Pos(), "encryptpad %q is not a list of positive sizes, the field is not padded", buckets)
+		}
+		switch algorithm := reflect.StructTag(tag).Get("algorithm"); algorithm {
+		case "", "aes-gcm", "xchacha20poly1305":
+		default:
+			pass.Reportf(f.Tag.Pos(), "algorithm %q is unknown, writes of the field fail", algorithm)
+		}
+
+		for _, name := range f.Names {
+			if !name.IsExported() {
+				pass.Reportf(name.Pos(), "encrypted tag on unexported field %s is ignored", name.Name)
+			}
+		}
+
+		t := pass.TypesInfo.TypeOf(f.Type)
+		if t == nil {
+			continue
+		}
+		if !isString(t) {
+			if s, ok := t.Underlying().(*types.Slice); !ok || !isString(s.Elem()) {
+				pass.Reportf(f.Type.Pos(), "encrypted tag on %s field is ignored, only string and string slice fields are encrypted", t)
+			}
+		}
+	}
+}
+
+// isString reports whether t is of a string kind
+func isString(t types.Type) bool {
+	b, ok := t.Underlying().(*types.Basic)
+	return ok && b.Kind() == types.String
+}
+
+// validPadBuckets reports whether tag is a comma separated list of positive
+// sizes
+func validPadBuckets(tag string) bool {
+	for _, size := range strings.Split(tag, ",") {
+		if n, err := strconv.Atoi(strings.TrimSpace(size)); err != nil || n <= 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// checkSet reports Set("column = ?") on a query whose model encrypts column
+func checkSet(pass *analysis.Pass, call *ast.CallExpr, sel *ast.SelectorExpr) {
+	if len(call.Args) == 0 {
+		return
+	}
+	expr, ok := stringConst(pass, call.Args[0])
+	if !ok {
+		return
+	}
+	column, _, ok := strings.Cut(expr, "=")
+	if !ok {
+		return
+	}
+	column = strings.Trim(strings.TrimSpace(column), `"`)
+
+	model := chainModel(pass, sel.X)
+	if model == nil {
+		return
+	}
+	if field := encryptedColumn(model, column); field != "" {
+		pass.Reportf(call.Args[0].Pos(), "Set writes encrypted column %s (%s.%s) without encryption", column, model.Obj().Name(), field)
+	}
+}
+
+// checkWithKey reports WithKey with a literal key ID that is not known
+func checkWithKey(pass *analysis.Pass, call *ast.CallExpr, known map[string]bool) {
+	if len(known) == 0 || len(call.Args) != 1 {
+		return
+	}
+	keyID, ok := stringConst(pass, call.Args[0])
+	if !ok || keyID == "" || known[keyID] {
+		return
+	}
+	pass.Reportf(call.Args[0].Pos(), "WithKey uses unknown key ID %q", keyID)
+}
+
+// collectKeys records the literal keys of Config{Keys: map[string][]byte{...}}
+func collectKeys(pass *analysis.Pass, lit *ast.CompositeLit, known map[string]bool) {
+	named, ok := types.Unalias(pass.TypesInfo.TypeOf(lit)).(*types.Named)
+	if !ok || named.Obj().Name() != "Config" || named.Obj().Pkg() == nil ||
+		!strings.HasPrefix(named.Obj().Pkg().Path(), "github.com/muhammadluth/govault") {
+		return
+	}
+
+	for _, elt := range lit.Elts {
+		kv, ok := elt.(*ast.KeyValueExpr)
+		if !ok {
+			continue
+		}
+		if id, ok := kv.Key.(*ast.Ident); !ok || id.Name != "Keys" {
+			continue
+		}
+		keysLit, ok := kv.Value.(*ast.CompositeLit)
+		if !ok {
+			continue
+		}
+		for _, e := range keysLit.Elts {
+			if entry, ok := e.(*ast.KeyValueExpr); ok {
+				if k, ok := stringConst(pass, entry.Key); ok {
+					known[k] = true
+				}
+			}
+		}
+	}
+}
+
+// chainModel walks a method chain such as db.NewUpdate().Model(m).Where(...)
+// back to its Model call and returns the named struct type of the model
+func chainModel(pass *analysis.Pass, expr ast.Expr) *types.Named {
+	for {
+		call, ok := ast.Unparen(expr).(*ast.CallExpr)
+		if !ok {
+			return nil
+		}
+		sel, ok := call.Fun.(*ast.SelectorExpr)
+		if !ok {
+			return nil
+		}
+		if sel.Sel.Name == "Model" && len(call.Args) == 1 {
+			return structType(pass.TypesInfo.TypeOf(call.Args[0]))
+		}
+		expr = sel.X
+	}
+}
+
+// structType unwraps pointers and slices down to a named struct type
+func structType(t types.Type) *types.Named {
+	for t != nil {
+		switch u := types.Unalias(t).(type) {
+		case *types.Pointer:
+			t = u.Elem()
+		case *types.Slice:
+			t = u.Elem()
+		case *types.Named:
+			if _, ok := u.Underlying().(*types.Struct); ok {
+				return u
+			}
+			return nil
+		default:
+			return nil
+		}
+	}
+	return nil
+}
+
+// encryptedColumn returns the Go name of the encrypted field stored in column
+func encryptedColumn(named *types.Named, column string) string {
+	st := named.Underlying().(*types.Struct)
+	for i := 0; i < st.NumFields(); i++ {
+		tag := reflect.StructTag(st.Tag(i))
+		if tag.Get("encrypted") != "true" {
+			continue
+		}
+		name := strings.SplitN(tag.Get("bun"), ",", 2)[0]
+		if name == "" {
+			name = underscore(st.Field(i).Name())
+		}
+		if name == column {
+			return st.Field(i).Name()
+		}
+	}
+	return ""
+}
+
+// stringConst returns the value of a constant string expression
+func stringConst(pass *analysis.Pass, expr ast.Expr) (string, bool) {
+	tv, ok := pass.TypesInfo.Types[expr]
+	if !ok || tv.Value == nil || tv.Value.Kind() != constant.String {
+		return "", false
+	}
+	return constant.StringVal(tv.Value), true
+}
+
+// underscore converts a Go field name to bun's default column name, a copy
+// of bun's internal.Underscore: UserID is user_id, HTTPServer http_server
+func underscore(s string) string {
+	b := make([]byte, 0, len(s)+5)
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c >= 'A' && c <= 'Z' {
+			if i > 0 && i+1 < len(s) && (isLower(s[i-1]) || isLower(s[i+1])) {
+				b = append(b, '_')
+			}
+			c += 'a' - 'A'
+		}
+		b = append(b, c)
+	}
+	return string(b)
+}
+
+func isLower(c byte) bool { return c >= 'a' && c <= 'z' }
@@ -0,0 +1,12 @@
+package govaultcheck_test
+
+import (
+	"testing"
+
+	"github.com/muhammadluth/govault/govaultcheck"
+	"golang.org/x/tools/go/analysis/analysistest"
+)
+
+func TestAnalyzer(t *testing.T) {
+	analysistest.Run(t, analysistest.TestData(), govaultcheck.Analyzer, "a")
+}
@@ -0,0 +1,59 @@
+package a
+
+import "github.com/muhammadluth/govault"
+
+type Query struct{}
+
+func (q *Query) Model(model any) *Query               { return q }
+func (q *Query) Set(query string, args ...any) *Query { return q }
+func (q *Query) Where(query string, args ...any) *Query {
+	return q
+}
+func (q *Query) WithKey(keyID string) *Query { return q }
+
+type User struct {
+	ID        int64
+	Email     string `bun:"email" encrypted:"true"`
+	Phone     string `encrypted:"true"`
+	secret    string `encrypted:"true"` // want `encrypted tag on unexported field secret is ignored`
+	Age       int    `encrypted:"true"` // want `encrypted tag on int field is ignored, only string and string slice fields are encrypted`
+	Name      string `bun:"name"`
+	PhoneBidx string
+	Address   string   `encrypted:"true" encryptttl:"720h"`
+	Notes     string   `encrypted:"true" encryptttl:"30d"` // want `encryptttl "30d" is not a positive duration, the field is never swept`
+	Nickname  string   `encrypted:"true" encryptpad:"16,32,64"`
+	Bio       string   `encrypted:"true" encryptpad:"64b"` // want `encryptpad "64b" is not a list of positive sizes, the field is not padded`
+	Passport  string   `encrypted:"true" algorithm:"xchacha20poly1305"`
+	Visa      string   `encrypted:"true" algorithm:"chacha"` // want `algorithm "chacha" is unknown, writes of the field fail`
+	HTTPHost  string   `encrypted:"true"`
+	Aliases   []string `encrypted:"true"`
+	Codes     []Code   `encrypted:"true" encryptarray:"whole"`
+	Scores    []int    `encrypted:"true"` // want `encrypted tag on \[\]int field is ignored, only string and string slice fields are encrypted`
+}
+
+type Code string
+
+var config = govault.Config{
+	Keys: map[string][]byte{
+		"primary":   nil,
+		"secondary": nil,
+	},
+	DefaultKeyID: "primary",
+}
+
+func queries(q *Query, u *User) {
+	q.Model(u).Set("email = ?", "x")                 // want `Set writes encrypted column email \(User.Email\) without encryption`
+	q.Model(u).Where("id = 1").Set("phone = ?", "x") // want `Set writes encrypted column phone \(User.Phone\) without encryption`
+	q.Model(u).Set("name = ?", "x")
+	q.Model(u).Set("http_host = ?", "x")       // want `Set writes encrypted column http_host \(User.HTTPHost\) without encryption`
+	q.Model((*User)(nil)).Set("email = email") // want `Set writes encrypted column email \(User.Email\) without encryption`
+	q.Set("email = ?", "x")
+
+	q.WithKey("primary")
+	q.WithKey("secondary")
+	q.WithKey("rotated") // want `WithKey uses unknown key ID "rotated"`
+	q.WithKey("")
+
+	keyID := "dynamic"
+	q.WithKey(keyID)
+}
@@ -0,0 +1,6 @@
+package govault
+
+type Config struct {
+	Keys         map[string][]byte
+	DefaultKeyID string
+}
@@ -0,0 +1,260 @@
+package govaultd
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/muhammadluth/govault"
+	"github.com/muhammadluth/govault/internal"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// keysTTL is how long Client caches the key IDs of the server
+const keysTTL = time.Minute
+
+// defaultTimeout bounds the calls of a Client made without a deadline
+const defaultTimeout = 10 * time.Second
+
+// Client is a govault.Cryptor encrypting and decrypting through the gRPC
+// service of a govaultd server, holding no keys itself:
+//
+//	client, err := govaultd.NewClient("govaultd:9443", token)
+//	var cryptor govault.Cryptor = client
+//
+// The ciphertexts are those of the server's key config, readable by any
+// GovaultDB sharing it.
+type Client struct {
+	conn  *grpc.ClientConn
+	token string
+
+	mu        sync.Mutex
+	keys      KeysResponse
+	keysFetch time.Time
+}
+
+var _ govault.Cryptor = (*Client)(nil)
+
+// NewClient returns a Client of the server at target, a gRPC target such
+// as "govaultd:9443", authenticating with token. The connection uses TLS
+// with the system roots unless opts set other transport credentials. No
+// connection is made until the first call.
+func NewClient(target, token string, opts ...grpc.DialOption) (*Client, error) {
+	opts = append([]grpc.DialOption{
+		grpc.WithTransportCredentials(credentials.NewTLS(nil)),
+		grpc.WithDefaultCallOptions(grpc.ForceCodec(wireCodec{})),
+	}, opts...)
+	conn, err := grpc.NewClient(target, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("govaultd: %w", err)
+	}
+	return &Client{conn: conn, token: token}, nil
+}
+
+// Close closes the connection of the client
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+// Error is a failed call. It wraps the govault error its code stands for,
+// e.g. govault.ErrOperationNotAllowed, or govault.ErrKeyUnavailable while
+// the server can't be reached.
+type Error struct {
+	Code    codes.Code
+	Message string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("govaultd: %s (%s)", e.Message, e.Code)
+}
+
+func (e *Error) Unwrap() error {
+	switch e.Code {
+	case codes.PermissionDenied:
+		return govault.ErrOperationNotAllowed
+	case codes.ResourceExhausted:
+		return govault.ErrRateLimited
+	case codes.Unavailable:
+		return govault.ErrKeyUnavailable
+	}
+	return nil
+}
+
+// Encrypt encrypts plaintext with keyID, or the default key of the server
+func (c *Client) Encrypt(plaintext string, keyID ...string) (string, error) {
+	return c.EncryptContext(context.Background(), EncryptRequest{Plaintext: plaintext, KeyID: firstKeyID(keyID)})
+}
+
+// EncryptDeterministic is Encrypt producing the same ciphertext for the
+// same plaintext and key, as stored in the columns of deterministic fields
+func (c *Client) EncryptDeterministic(plaintext string, keyID ...string) (string, error) {
+	return c.EncryptContext(context.Background(), EncryptRequest{Plaintext: plaintext, KeyID: firstKeyID(keyID), Deterministic: true})
+}
+
+// EncryptContext calls MethodEncrypt
+func (c *Client) EncryptContext(ctx context.Context, req EncryptRequest) (string, error) {
+	var resp CiphertextResponse
+	if err := c.call(ctx, MethodEncrypt, &req, &resp); err != nil {
+		return "", err
+	}
+	return resp.Ciphertext, nil
+}
+
+// Decrypt decrypts a ciphertext of the server's key config
+func (c *Client) Decrypt(encryptedData string) (string, error) {
+	return c.DecryptContext(context.Background(), encryptedData)
+}
+
+// DecryptContext is Decrypt with a context
+func (c *Client) DecryptContext(ctx context.Context, encryptedData string) (string, error) {
+	if encryptedData == "" {
+		return "", nil
+	}
+	var resp PlaintextResponse
+	if err := c.call(ctx, MethodDecrypt, &DecryptRequest{Ciphertext: encryptedData}, &resp); err != nil {
+		return "", err
+	}
+	return resp.Plaintext, nil
+}
+
+// ReEncrypt encrypts a ciphertext again with keyID, or the default key of
+// the server, without its plaintext leaving the server
+func (c *Client) ReEncrypt(ctx context.Context, encryptedData, keyID string) (string, error) {
+	var resp CiphertextResponse
+	if err := c.call(ctx, MethodReEncrypt, &ReEncryptRequest{Ciphertext: encryptedData, KeyID: keyID}, &resp); err != nil {
+		return "", err
+	}
+	return resp.Ciphertext, nil
+}
+
+// DecryptRecursive decrypts the string fields tagged with encrypted:"true"
+// of value, a pointer, and of the structs it holds in fields, slices and
+// maps, in a single request. Values that are not ciphertexts, and fields
+// of custom codecs, are left as is.
+func (c *Client) DecryptRecursive(value any) error {
+	return c.DecryptRecursiveContext(context.Background(), value)
+}
+
+// DecryptRecursiveContext is DecryptRecursive with a context
+func (c *Client) DecryptRecursiveContext(ctx context.Context, value any) error {
+	var fields []reflect.Value
+	collectEncrypted(reflect.ValueOf(value), &fields)
+	if len(fields) == 0 {
+		return nil
+	}
+
+	req := DecryptBatchRequest{Ciphertexts: make([]string, len(fields))}
+	for i, field := range fields {
+		req.Ciphertexts[i] = field.String()
+	}
+	var resp PlaintextsResponse
+	if err := c.call(ctx, MethodDecryptBatch, &req, &resp); err != nil {
+		return err
+	}
+	if len(resp.Plaintexts) != len(fields) {
+		return fmt.Errorf("govaultd: %d plaintexts for %d ciphertexts", len(resp.Plaintexts), len(fields))
+	}
+	for i, field := range fields {
+		field.SetString(resp.Plaintexts[i])
+	}
+	return nil
+}
+
+// collectEncrypted appends the settable encrypted string fields of the
+// structs in v to fields
+func collectEncrypted(v reflect.Value, fields *[]reflect.Value) {
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if !v.IsNil() {
+			collectEncrypted(v.Elem(), fields)
+		}
+	case reflect.Slice, reflect.Array:
+		for i := range v.Len() {
+			collectEncrypted(v.Index(i), fields)
+		}
+	case reflect.Map:
+		for iter := v.MapRange(); iter.Next(); {
+			collectEncrypted(iter.Value(), fields)
+		}
+	case reflect.Struct:
+		if spec := internal.GetModelSpec(reflect.Zero(v.Type()).Interface()); spec != nil && v.CanSet() {
+			for _, field := range spec.Fields {
+				f := v.FieldByIndex(field.Index)
+				if f.Kind() == reflect.String && f.CanSet() && field.Codec == "" && internal.IsEncrypted(f.String()) {
+					*fields = append(*fields, f)
+				}
+			}
+		}
+		for i := range v.NumField() {
+			if v.Type().Field(i).IsExported() {
+				collectEncrypted(v.Field(i), fields)
+			}
+		}
+	}
+}
+
+// GetKeyIDs returns the key IDs of the server, cached for a minute. It is
+// empty while the server can't be reached.
+func (c *Client) GetKeyIDs() []string {
+	return c.serverKeys().KeyIDs
+}
+
+// GetDefaultKeyID returns the default key ID of the server, cached for a
+// minute
+func (c *Client) GetDefaultKeyID() string {
+	return c.serverKeys().DefaultKeyID
+}
+
+// serverKeys returns the cached keys of the server, fetching them when
+// stale. The last known keys are kept when fetching fails. The lock is not
+// held during the fetch, so a slow server does not block readers of the
+// cache; concurrent callers may fetch at the same time.
+func (c *Client) serverKeys() KeysResponse {
+	c.mu.Lock()
+	if time.Since(c.keysFetch) < keysTTL {
+		defer c.mu.Unlock()
+		return c.keys
+	}
+	c.mu.Unlock()
+
+	var keys KeysResponse
+	err := c.call(context.Background(), MethodKeys, &keysRequest{}, &keys)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err == nil {
+		c.keys = keys
+		c.keysFetch = time.Now()
+	}
+	return c.keys
+}
+
+// call invokes method with the token, bounded by defaultTimeout when ctx
+// has no deadline
+func (c *Client) call(ctx context.Context, method string, in, out wireMessage) error {
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, defaultTimeout)
+		defer cancel()
+	}
+	ctx = metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+c.token)
+	if err := c.conn.Invoke(ctx, method, in, out); err != nil {
+		st := status.Convert(err)
+		return &Error{Code: st.Code(), Message: st.Message()}
+	}
+	return nil
+}
+
+// firstKeyID returns the optional key ID argument of Encrypt
+func firstKeyID(keyID []string) string {
+	if len(keyID) > 0 {
+		return keyID[0]
+	}
+	return ""
+}
@@ -0,0 +1,144 @@
+package govaultd_test
+
+import (
+	"net"
+	"testing"
+
+	"github.com/muhammadluth/govault"
+	"github.com/muhammadluth/govault/govaultd"
+	"github.com/muhammadluth/govault/govaulttest"
+	"github.com/muhammadluth/govault/internal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// serveGRPC serves vault over gRPC on a local port and returns its address
+func serveGRPC(t *testing.T, vault govaultd.Vault, tokens ...govaultd.Token) string {
+	t.Helper()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	server := govaultd.NewGRPCServer(vault, tokens)
+	go func() { _ = server.Serve(lis) }()
+	t.Cleanup(server.Stop)
+	return lis.Addr().String()
+}
+
+// newClient returns a plaintext client of addr
+func newClient(t *testing.T, addr, token string) *govaultd.Client {
+	t.Helper()
+	client, err := govaultd.NewClient(addr, token, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = client.Close() })
+	return client
+}
+
+type Account struct {
+	ID    int64  `bun:"id,pk"`
+	Email string `bun:"email" encrypted:"true"`
+	Phone string `bun:"phone" encrypted:"true"`
+	Name  string `bun:"name"`
+}
+
+func TestClient(t *testing.T) {
+	vault, err := internal.New(govaulttest.Config(2))
+	require.NoError(t, err)
+	addr := serveGRPC(t, vault, full)
+
+	var cryptor govault.Cryptor = newClient(t, addr, token)
+
+	t.Run("keys", func(t *testing.T) {
+		assert.ElementsMatch(t, vault.GetKeyIDs(), cryptor.GetKeyIDs())
+		assert.Equal(t, "2", cryptor.GetDefaultKeyID())
+	})
+
+	t.Run("interoperates with local keys", func(t *testing.T) {
+		ciphertext, err := cryptor.Encrypt("john@example.com", "1")
+		require.NoError(t, err)
+		plaintext, err := vault.Decrypt(ciphertext)
+		require.NoError(t, err)
+		assert.Equal(t, "john@example.com", plaintext)
+
+		local, err := vault.Encrypt("jane@example.com")
+		require.NoError(t, err)
+		plaintext, err = cryptor.Decrypt(local)
+		require.NoError(t, err)
+		assert.Equal(t, "jane@example.com", plaintext)
+	})
+
+	t.Run("decrypt recursive", func(t *testing.T) {
+		email, err := vault.Encrypt("john@example.com")
+		require.NoError(t, err)
+		phone, err := vault.Encrypt("+6281234567")
+		require.NoError(t, err)
+		accounts := []*Account{
+			{ID: 1, Email: email, Phone: phone, Name: "John"},
+			{ID: 2, Email: "not encrypted yet", Name: "Jane"},
+		}
+		require.NoError(t, cryptor.DecryptRecursive(&accounts))
+		assert.Equal(t, &Account{ID: 1, Email: "john@example.com", Phone: "+6281234567", Name: "John"}, accounts[0])
+		assert.Equal(t, "not encrypted yet", accounts[1].Email)
+	})
+
+	t.Run("reencrypt", func(t *testing.T) {
+		client := newClient(t, addr, token)
+		ciphertext, err := vault.Encrypt("john@example.com", "1")
+		require.NoError(t, err)
+		rotated, err := client.ReEncrypt(t.Context(), ciphertext, "")
+		require.NoError(t, err)
+		keyID, err := vault.GetKeyIDFromEncryptedData(rotated)
+		require.NoError(t, err)
+		assert.Equal(t, "2", keyID)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		_, err := newClient(t, addr, "wrong").Decrypt("x")
+		var remote *govaultd.Error
+		require.ErrorAs(t, err, &remote)
+		assert.Equal(t, codes.Unauthenticated, remote.Code)
+
+		_, err = newClient(t, addr, token).Decrypt("not a ciphertext")
+		require.ErrorAs(t, err, &remote)
+		assert.Equal(t, codes.InvalidArgument, remote.Code)
+
+		config := govaulttest.Config(1)
+		config.Mode = govault.ModeEncryptOnly
+		encryptOnly, err := internal.New(config)
+		require.NoError(t, err)
+		restricted := serveGRPC(t, encryptOnly, full)
+		_, err = newClient(t, restricted, token).Decrypt("gv1:1|x|y")
+		assert.ErrorIs(t, err, govault.ErrOperationNotAllowed)
+	})
+
+	t.Run("permissions", func(t *testing.T) {
+		addr := serveGRPC(t, vault, govaultd.Token{Value: "writer", Permissions: govaultd.PermEncrypt})
+		writer := newClient(t, addr, "writer")
+		ciphertext, err := writer.Encrypt("x")
+		require.NoError(t, err)
+		_, err = writer.Decrypt(ciphertext)
+		assert.ErrorIs(t, err, govault.ErrOperationNotAllowed)
+		_, err = writer.ReEncrypt(t.Context(), ciphertext, "")
+		assert.ErrorIs(t, err, govault.ErrOperationNotAllowed)
+		assert.NotEmpty(t, writer.GetKeyIDs())
+	})
+
+	t.Run("generated clients interoperate", func(t *testing.T) {
+		// DecryptRequest and PlaintextResponse have the wire format of
+		// StringValue, so the standard protobuf codec stands in for
+		// stubs generated from govaultd.proto
+		conn, err := grpc.NewClient(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+		require.NoError(t, err)
+		defer conn.Close()
+
+		ciphertext, err := vault.Encrypt("john@example.com")
+		require.NoError(t, err)
+		ctx := metadata.AppendToOutgoingContext(t.Context(), "authorization", "Bearer "+token)
+		var resp wrapperspb.StringValue
+		require.NoError(t, conn.Invoke(ctx, govaultd.MethodDecrypt, wrapperspb.String(ciphertext), &resp))
+		assert.Equal(t, "john@example.com", resp.GetValue())
+	})
+}
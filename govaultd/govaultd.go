@@ -0,0 +1,362 @@
+// Package govaultd serves the value encryption of a govault key config
+// over HTTP and gRPC, so services not written in Go can read and write
+// the columns of govault applications without reimplementing the
+// ciphertext format. The cmd/govaultd command runs it:
+//
+//	govaultd -config govault.yaml -tokens tokens.txt -tls-cert cert.pem -tls-key key.pem -grpc-addr :9443
+//
+// Every HTTP request carries one of the tokens as an Authorization: Bearer
+// header. Bodies are JSON:
+//
+//	POST /v1/encrypt   {"plaintext": "...", "key_id": "2", "deterministic": false}
+//	                   -> {"ciphertext": "..."}
+//	POST /v1/decrypt   {"ciphertext": "..."} -> {"plaintext": "..."}
+//	POST /v1/decrypt-batch {"ciphertexts": ["...", ...]} -> {"plaintexts": ["...", ...]}
+//	POST /v1/reencrypt {"ciphertext": "...", "key_id": "2"} -> {"ciphertext": "..."}
+//	GET  /v1/keys      -> {"key_ids": ["1", "2"], "default_key_id": "2"}
+//
+// An empty key_id is the default key. Failed requests answer an
+// ErrorResponse. The gRPC service, see NewGRPCServer, has the same calls
+// and messages, described by govaultd.proto. govaultd serves both over
+// TLS, see its -tls-cert flag.
+//
+// Tokens carry Permissions: encrypt-only tokens suit writers, e.g. an
+// ingest service, and decrypt-only ones readers. Reencrypting needs both
+// and listing the keys either.
+//
+// Only the native formats are served. Fields with a codec tag, e.g.
+// CipherSweet ones, derive their keys from the table and column, which the
+// API does not take: their values fail to decrypt, and values encrypted
+// by the API are not in their format.
+//
+// Client calls the gRPC service from Go and implements govault.Cryptor, so
+// code depending on a Cryptor runs without local keys by switching its
+// constructor.
+package govaultd
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/muhammadluth/govault"
+)
+
+// Paths of the endpoints
+const (
+	PathEncrypt = "/v1/encrypt"
+	PathDecrypt = "/v1/decrypt"
+	// PathDecryptBatch decrypts the values of a row in one round trip
+	PathDecryptBatch = "/v1/decrypt-batch"
+	PathReEncrypt    = "/v1/reencrypt"
+	PathKeys         = "/v1/keys"
+)
+
+// MaxRequestSize bounds the bodies accepted by the handler
+const MaxRequestSize = 4 * govault.MaxCiphertextLength
+
+// Vault is the encryption the handler serves, implemented by
+// govault.GovaultDB
+type Vault interface {
+	govault.Cryptor
+	EncryptField(field *govault.FieldSpec, table, plaintext, keyID string) (string, error)
+	DecryptStrict(ciphertext string) (string, error)
+	InspectCiphertext(value string) (*govault.CiphertextInfo, error)
+}
+
+// EncryptRequest is the body of PathEncrypt
+type EncryptRequest struct {
+	Plaintext     string `json:"plaintext"`
+	KeyID         string `json:"key_id,omitempty"`
+	Deterministic bool   `json:"deterministic,omitempty"`
+}
+
+// DecryptRequest is the body of PathDecrypt
+type DecryptRequest struct {
+	Ciphertext string `json:"ciphertext"`
+}
+
+// DecryptBatchRequest is the body of PathDecryptBatch
+type DecryptBatchRequest struct {
+	Ciphertexts []string `json:"ciphertexts"`
+}
+
+// ReEncryptRequest is the body of PathReEncrypt. The ciphertext is
+// decrypted and encrypted again with KeyID, deterministically if it was.
+type ReEncryptRequest struct {
+	Ciphertext string `json:"ciphertext"`
+	KeyID      string `json:"key_id,omitempty"`
+}
+
+// CiphertextResponse answers PathEncrypt and PathReEncrypt
+type CiphertextResponse struct {
+	Ciphertext string `json:"ciphertext"`
+}
+
+// PlaintextResponse answers PathDecrypt
+type PlaintextResponse struct {
+	Plaintext string `json:"plaintext"`
+}
+
+// PlaintextsResponse answers PathDecryptBatch, in the order of the
+// ciphertexts
+type PlaintextsResponse struct {
+	Plaintexts []string `json:"plaintexts"`
+}
+
+// KeysResponse answers PathKeys
+type KeysResponse struct {
+	KeyIDs       []string `json:"key_ids"`
+	DefaultKeyID string   `json:"default_key_id"`
+}
+
+// ErrorResponse answers failed requests
+type ErrorResponse struct {
+	Error string `json:"error"`
+}
+
+// Permissions are the operations a token may call
+type Permissions uint8
+
+const (
+	// PermEncrypt allows PathEncrypt
+	PermEncrypt Permissions = 1 << iota
+	// PermDecrypt allows PathDecrypt and PathDecryptBatch
+	PermDecrypt
+
+	// PermAll allows every endpoint, including PathReEncrypt
+	PermAll = PermEncrypt | PermDecrypt
+)
+
+// ParsePermissions parses a comma separated list of "encrypt" and
+// "decrypt"
+func ParsePermissions(s string) (Permissions, error) {
+	var perms Permissions
+	for _, name := range strings.Split(s, ",") {
+		switch strings.TrimSpace(name) {
+		case "encrypt":
+			perms |= PermEncrypt
+		case "decrypt":
+			perms |= PermDecrypt
+		default:
+			return 0, fmt.Errorf("unknown permission %q", name)
+		}
+	}
+	return perms, nil
+}
+
+// Token is a bearer token accepted by the handler and what it may call
+type Token struct {
+	Value       string
+	Permissions Permissions
+}
+
+// service runs the operations of the API for both transports
+type service struct {
+	vault Vault
+	// tokens are the SHA-256 sums of the accepted tokens, compared in
+	// constant time, with their permissions
+	tokens []acceptedToken
+}
+
+type acceptedToken struct {
+	sum   [sha256.Size]byte
+	perms Permissions
+}
+
+func newService(vault Vault, tokens []Token) *service {
+	s := &service{vault: vault}
+	for _, token := range tokens {
+		if token.Value != "" && token.Permissions != 0 {
+			s.tokens = append(s.tokens, acceptedToken{sha256.Sum256([]byte(token.Value)), token.Permissions})
+		}
+	}
+	return s
+}
+
+// authorize returns the permissions of the token of an Authorization
+// header, zero without one of the tokens
+func (s *service) authorize(authorization string) Permissions {
+	token, ok := strings.CutPrefix(authorization, "Bearer ")
+	if !ok || token == "" {
+		return 0
+	}
+	sum := sha256.Sum256([]byte(token))
+	var perms Permissions
+	for _, accepted := range s.tokens {
+		// -1 masks in the permissions of a match, 0 those of the others
+		match := subtle.ConstantTimeCompare(sum[:], accepted.sum[:])
+		perms |= accepted.perms & Permissions(-match)
+	}
+	return perms
+}
+
+func (s *service) encrypt(req EncryptRequest) (*CiphertextResponse, error) {
+	ciphertext, err := s.encryptWith(req.Plaintext, req.KeyID, req.Deterministic)
+	if err != nil {
+		return nil, err
+	}
+	return &CiphertextResponse{Ciphertext: ciphertext}, nil
+}
+
+func (s *service) decrypt(req DecryptRequest) (*PlaintextResponse, error) {
+	plaintext, err := s.vault.Decrypt(req.Ciphertext)
+	if err != nil {
+		return nil, err
+	}
+	return &PlaintextResponse{Plaintext: plaintext}, nil
+}
+
+func (s *service) decryptBatch(req DecryptBatchRequest) (*PlaintextsResponse, error) {
+	plaintexts := make([]string, len(req.Ciphertexts))
+	for i, ciphertext := range req.Ciphertexts {
+		plaintext, err := s.vault.Decrypt(ciphertext)
+		if err != nil {
+			return nil, fmt.Errorf("value %d: %w", i, err)
+		}
+		plaintexts[i] = plaintext
+	}
+	return &PlaintextsResponse{Plaintexts: plaintexts}, nil
+}
+
+func (s *service) reencrypt(req ReEncryptRequest) (*CiphertextResponse, error) {
+	info, err := s.vault.InspectCiphertext(req.Ciphertext)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := s.vault.DecryptStrict(req.Ciphertext)
+	if err != nil {
+		return nil, err
+	}
+	ciphertext, err := s.encryptWith(plaintext, req.KeyID, info.Deterministic)
+	if err != nil {
+		return nil, err
+	}
+	return &CiphertextResponse{Ciphertext: ciphertext}, nil
+}
+
+func (s *service) keys() *KeysResponse {
+	return &KeysResponse{
+		KeyIDs:       s.vault.GetKeyIDs(),
+		DefaultKeyID: s.vault.GetDefaultKeyID(),
+	}
+}
+
+// encryptWith encrypts plaintext with keyID, the default key when empty
+func (s *service) encryptWith(plaintext, keyID string, deterministic bool) (string, error) {
+	if deterministic {
+		return s.vault.EncryptField(&govault.FieldSpec{Deterministic: true}, "", plaintext, keyID)
+	}
+	var keyIDs []string
+	if keyID != "" {
+		keyIDs = []string{keyID}
+	}
+	return s.vault.Encrypt(plaintext, keyIDs...)
+}
+
+// notAllowed is the error of a call the token has no permission for
+func notAllowed(call string) error {
+	return fmt.Errorf("token may not call %s: %w", call, govault.ErrOperationNotAllowed)
+}
+
+// handler serves the HTTP endpoints of a service
+type handler struct {
+	*service
+	mux *http.ServeMux
+}
+
+// NewHandler returns the HTTP handler serving vault to the clients
+// presenting one of tokens, within its permissions. Without tokens every
+// request is rejected.
+func NewHandler(vault Vault, tokens ...Token) http.Handler {
+	h := &handler{service: newService(vault, tokens), mux: http.NewServeMux()}
+	h.mux.HandleFunc("POST "+PathEncrypt, serveJSON(PermEncrypt, h.encrypt))
+	h.mux.HandleFunc("POST "+PathDecrypt, serveJSON(PermDecrypt, h.decrypt))
+	h.mux.HandleFunc("POST "+PathDecryptBatch, serveJSON(PermDecrypt, h.decryptBatch))
+	h.mux.HandleFunc("POST "+PathReEncrypt, serveJSON(PermAll, h.reencrypt))
+	h.mux.HandleFunc("GET "+PathKeys, func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, h.keys())
+	})
+	return h
+}
+
+// permissionsKey holds the Permissions of the token of a request in its
+// context
+type permissionsKey struct{}
+
+func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	perms := h.authorize(r.Header.Get("Authorization"))
+	if perms == 0 {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="govaultd"`)
+		writeError(w, http.StatusUnauthorized, errors.New("unauthorized"))
+		return
+	}
+	h.mux.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), permissionsKey{}, perms)))
+}
+
+// serveJSON returns the endpoint decoding a Req body for call and writing
+// its response, answering 403 to the tokens lacking perms
+func serveJSON[Req, Resp any](perms Permissions, call func(Req) (Resp, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if granted, _ := r.Context().Value(permissionsKey{}).(Permissions); granted&perms != perms {
+			writeError(w, http.StatusForbidden, notAllowed(r.URL.Path))
+			return
+		}
+		var req Req
+		if !readRequest(w, r, &req) {
+			return
+		}
+		resp, err := call(req)
+		if err != nil {
+			writeError(w, errorStatus(err), err)
+			return
+		}
+		writeJSON(w, http.StatusOK, resp)
+	}
+}
+
+// readRequest decodes the JSON body of r into v, answering malformed
+// bodies itself
+func readRequest(w http.ResponseWriter, r *http.Request, v any) bool {
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, MaxRequestSize))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(v); err != nil {
+		status := http.StatusBadRequest
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			status = http.StatusRequestEntityTooLarge
+		}
+		writeError(w, status, fmt.Errorf("invalid request: %w", err))
+		return false
+	}
+	return true
+}
+
+// errorStatus returns the HTTP status of a failed vault operation
+func errorStatus(err error) int {
+	switch {
+	case errors.Is(err, govault.ErrOperationNotAllowed):
+		return http.StatusForbidden
+	case errors.Is(err, govault.ErrRateLimited):
+		return http.StatusTooManyRequests
+	case errors.Is(err, govault.ErrKeyUnavailable):
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusUnprocessableEntity
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, ErrorResponse{Error: err.Error()})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
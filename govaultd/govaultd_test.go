@@ -0,0 +1,144 @@
+package govaultd_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/muhammadluth/govault"
+	"github.com/muhammadluth/govault/govaultd"
+	"github.com/muhammadluth/govault/govaulttest"
+	"github.com/muhammadluth/govault/internal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const token = "s3cret"
+
+// full is token with every permission
+var full = govaultd.Token{Value: token, Permissions: govaultd.PermAll}
+
+// call sends body to path and decodes the response into out
+func call(t *testing.T, handler http.Handler, method, path, auth string, body, out any) int {
+	t.Helper()
+	var payload bytes.Buffer
+	if body != nil {
+		require.NoError(t, json.NewEncoder(&payload).Encode(body))
+	}
+	req := httptest.NewRequest(method, path, &payload)
+	if auth != "" {
+		req.Header.Set("Authorization", "Bearer "+auth)
+	}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if out != nil {
+		require.NoError(t, json.NewDecoder(rec.Body).Decode(out))
+	}
+	return rec.Code
+}
+
+func TestHandler(t *testing.T) {
+	vault, err := internal.New(govaulttest.Config(2))
+	require.NoError(t, err)
+	handler := govaultd.NewHandler(vault, govaultd.Token{Value: "other", Permissions: govaultd.PermAll}, full)
+
+	t.Run("authentication", func(t *testing.T) {
+		var resp govaultd.ErrorResponse
+		assert.Equal(t, http.StatusUnauthorized, call(t, handler, http.MethodGet, govaultd.PathKeys, "", nil, &resp))
+		assert.Equal(t, http.StatusUnauthorized, call(t, handler, http.MethodGet, govaultd.PathKeys, "wrong", nil, &resp))
+		assert.Equal(t, "unauthorized", resp.Error)
+		assert.Equal(t, http.StatusUnauthorized, call(t, govaultd.NewHandler(vault), http.MethodGet, govaultd.PathKeys, token, nil, nil),
+			"a handler without tokens rejects every request")
+	})
+
+	t.Run("keys", func(t *testing.T) {
+		var resp govaultd.KeysResponse
+		require.Equal(t, http.StatusOK, call(t, handler, http.MethodGet, govaultd.PathKeys, token, nil, &resp))
+		assert.ElementsMatch(t, vault.GetKeyIDs(), resp.KeyIDs)
+		assert.Equal(t, vault.GetDefaultKeyID(), resp.DefaultKeyID)
+	})
+
+	t.Run("round trip", func(t *testing.T) {
+		var encrypted govaultd.CiphertextResponse
+		require.Equal(t, http.StatusOK, call(t, handler, http.MethodPost, govaultd.PathEncrypt, token,
+			govaultd.EncryptRequest{Plaintext: "john@example.com"}, &encrypted))
+		plaintext, err := vault.Decrypt(encrypted.Ciphertext)
+		require.NoError(t, err)
+		assert.Equal(t, "john@example.com", plaintext, "the server writes the format of the library")
+
+		local, err := vault.Encrypt("jane@example.com", "2")
+		require.NoError(t, err)
+		var decrypted govaultd.PlaintextResponse
+		require.Equal(t, http.StatusOK, call(t, handler, http.MethodPost, govaultd.PathDecrypt, token,
+			govaultd.DecryptRequest{Ciphertext: local}, &decrypted))
+		assert.Equal(t, "jane@example.com", decrypted.Plaintext)
+	})
+
+	t.Run("reencrypt keeps deterministic values deterministic", func(t *testing.T) {
+		deterministic, err := vault.EncryptField(&internal.FieldSpec{Deterministic: true}, "", "john@example.com", "1")
+		require.NoError(t, err)
+
+		var resp govaultd.CiphertextResponse
+		require.Equal(t, http.StatusOK, call(t, handler, http.MethodPost, govaultd.PathReEncrypt, token,
+			govaultd.ReEncryptRequest{Ciphertext: deterministic, KeyID: "2"}, &resp))
+		keyID, err := vault.GetKeyIDFromEncryptedData(resp.Ciphertext)
+		require.NoError(t, err)
+		assert.Equal(t, "2", keyID)
+		expected, err := vault.EncryptField(&internal.FieldSpec{Deterministic: true}, "", "john@example.com", "2")
+		require.NoError(t, err)
+		assert.Equal(t, expected, resp.Ciphertext)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		var resp govaultd.ErrorResponse
+		assert.Equal(t, http.StatusUnprocessableEntity, call(t, handler, http.MethodPost, govaultd.PathDecrypt, token,
+			govaultd.DecryptRequest{Ciphertext: "not a ciphertext"}, &resp))
+		assert.NotEmpty(t, resp.Error)
+		assert.Equal(t, http.StatusUnprocessableEntity, call(t, handler, http.MethodPost, govaultd.PathEncrypt, token,
+			govaultd.EncryptRequest{Plaintext: "x", KeyID: "missing"}, &resp))
+		assert.Equal(t, http.StatusBadRequest, call(t, handler, http.MethodPost, govaultd.PathEncrypt, token,
+			map[string]string{"text": "x"}, &resp), "unknown fields are rejected")
+		assert.Equal(t, http.StatusMethodNotAllowed, call(t, handler, http.MethodGet, govaultd.PathEncrypt, token, nil, nil))
+	})
+
+	t.Run("permissions", func(t *testing.T) {
+		handler := govaultd.NewHandler(vault,
+			govaultd.Token{Value: "writer", Permissions: govaultd.PermEncrypt},
+			govaultd.Token{Value: "reader", Permissions: govaultd.PermDecrypt})
+		ciphertext, err := vault.Encrypt("x")
+		require.NoError(t, err)
+
+		var resp govaultd.ErrorResponse
+		assert.Equal(t, http.StatusOK, call(t, handler, http.MethodPost, govaultd.PathEncrypt, "writer", govaultd.EncryptRequest{Plaintext: "x"}, nil))
+		assert.Equal(t, http.StatusForbidden, call(t, handler, http.MethodPost, govaultd.PathDecrypt, "writer", govaultd.DecryptRequest{Ciphertext: ciphertext}, &resp))
+		assert.Contains(t, resp.Error, "may not call "+govaultd.PathDecrypt)
+		assert.Equal(t, http.StatusForbidden, call(t, handler, http.MethodPost, govaultd.PathDecryptBatch, "writer", govaultd.DecryptBatchRequest{Ciphertexts: []string{ciphertext}}, nil))
+
+		assert.Equal(t, http.StatusOK, call(t, handler, http.MethodPost, govaultd.PathDecrypt, "reader", govaultd.DecryptRequest{Ciphertext: ciphertext}, nil))
+		assert.Equal(t, http.StatusForbidden, call(t, handler, http.MethodPost, govaultd.PathEncrypt, "reader", govaultd.EncryptRequest{Plaintext: "x"}, nil))
+		assert.Equal(t, http.StatusForbidden, call(t, handler, http.MethodPost, govaultd.PathReEncrypt, "reader", govaultd.ReEncryptRequest{Ciphertext: ciphertext}, nil),
+			"reencrypting needs both")
+		assert.Equal(t, http.StatusOK, call(t, handler, http.MethodGet, govaultd.PathKeys, "reader", nil, nil))
+
+		perms, err := govaultd.ParsePermissions("decrypt, encrypt")
+		require.NoError(t, err)
+		assert.Equal(t, govaultd.PermAll, perms)
+		_, err = govaultd.ParsePermissions("admin")
+		assert.Error(t, err)
+	})
+
+	t.Run("modes", func(t *testing.T) {
+		config := govaulttest.Config(1)
+		config.Mode = govault.ModeEncryptOnly
+		encryptOnly, err := internal.New(config)
+		require.NoError(t, err)
+		ciphertext, err := encryptOnly.Encrypt("x")
+		require.NoError(t, err)
+
+		var resp govaultd.ErrorResponse
+		assert.Equal(t, http.StatusForbidden, call(t, govaultd.NewHandler(encryptOnly, full), http.MethodPost, govaultd.PathDecrypt, token,
+			govaultd.DecryptRequest{Ciphertext: ciphertext}, &resp))
+	})
+}
@@ -0,0 +1,110 @@
+package govaultd
+
+import (
+	"context"
+	"errors"
+
+	"github.com/muhammadluth/govault"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// ServiceName is the gRPC service of govaultd.proto
+const ServiceName = "govaultd.v1.Govault"
+
+// Methods of the gRPC service, as full method names
+const (
+	MethodEncrypt      = "/" + ServiceName + "/Encrypt"
+	MethodDecrypt      = "/" + ServiceName + "/Decrypt"
+	MethodDecryptBatch = "/" + ServiceName + "/DecryptBatch"
+	MethodReEncrypt    = "/" + ServiceName + "/ReEncrypt"
+	MethodKeys         = "/" + ServiceName + "/Keys"
+)
+
+// NewGRPCServer returns a gRPC server of the Govault service of
+// govaultd.proto, serving vault to the clients presenting one of tokens in
+// their authorization metadata, as "Bearer <token>", within its
+// permissions. opts configure the server, e.g. its TLS credentials. The
+// server encodes the messages itself and serves no other service.
+func NewGRPCServer(vault Vault, tokens []Token, opts ...grpc.ServerOption) *grpc.Server {
+	server := grpc.NewServer(append(opts, grpc.ForceServerCodec(wireCodec{}))...)
+	server.RegisterService(&grpc.ServiceDesc{
+		ServiceName: ServiceName,
+		HandlerType: (*any)(nil),
+		Methods: []grpc.MethodDesc{
+			unary("Encrypt", PermEncrypt, (*service).encrypt),
+			unary("Decrypt", PermDecrypt, (*service).decrypt),
+			unary("DecryptBatch", PermDecrypt, (*service).decryptBatch),
+			unary("ReEncrypt", PermAll, (*service).reencrypt),
+			unary("Keys", 0, func(s *service, _ keysRequest) (*KeysResponse, error) {
+				return s.keys(), nil
+			}),
+		},
+		Metadata: "govaultd.proto",
+	}, newService(vault, tokens))
+	return server
+}
+
+// unary returns the method name of the service, decoding a Req for call
+// and failing for the tokens lacking perms. Any valid token may call
+// methods without perms.
+func unary[Req any, Resp wireMessage](name string, perms Permissions, call func(*service, Req) (Resp, error)) grpc.MethodDesc {
+	fullMethod := "/" + ServiceName + "/" + name
+	return grpc.MethodDesc{
+		MethodName: name,
+		Handler: func(srv any, ctx context.Context, dec func(any) error, interceptor grpc.UnaryServerInterceptor) (any, error) {
+			req := new(Req)
+			if err := dec(req); err != nil {
+				return nil, err
+			}
+			handle := func(ctx context.Context, req any) (any, error) {
+				s := srv.(*service)
+				if err := s.authorizeRPC(ctx, perms, fullMethod); err != nil {
+					return nil, err
+				}
+				resp, err := call(s, *req.(*Req))
+				if err != nil {
+					return nil, status.Error(errorCode(err), err.Error())
+				}
+				return resp, nil
+			}
+			if interceptor == nil {
+				return handle(ctx, req)
+			}
+			return interceptor(ctx, req, &grpc.UnaryServerInfo{Server: srv, FullMethod: fullMethod}, handle)
+		},
+	}
+}
+
+// authorizeRPC checks the token of the metadata of an incoming call
+func (s *service) authorizeRPC(ctx context.Context, perms Permissions, method string) error {
+	md, _ := metadata.FromIncomingContext(ctx)
+	var granted Permissions
+	if values := md.Get("authorization"); len(values) == 1 {
+		granted = s.authorize(values[0])
+	}
+	switch {
+	case granted == 0:
+		return status.Error(codes.Unauthenticated, "unauthorized")
+	case granted&perms != perms:
+		return status.Error(codes.PermissionDenied, notAllowed(method).Error())
+	}
+	return nil
+}
+
+// errorCode returns the gRPC code of a failed vault operation, the
+// counterpart of errorStatus
+func errorCode(err error) codes.Code {
+	switch {
+	case errors.Is(err, govault.ErrOperationNotAllowed):
+		return codes.PermissionDenied
+	case errors.Is(err, govault.ErrRateLimited):
+		return codes.ResourceExhausted
+	case errors.Is(err, govault.ErrKeyUnavailable):
+		return codes.Unavailable
+	default:
+		return codes.InvalidArgument
+	}
+}
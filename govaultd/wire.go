@@ -0,0 +1,257 @@
+package govaultd
+
+import (
+	"fmt"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// wireMessage is a message of govaultd.proto. The messages are encoded by
+// hand in the protobuf wire format, so the package needs no generated code
+// while clients generated from govaultd.proto interoperate.
+type wireMessage interface {
+	marshalWire() []byte
+	unmarshalWire(b []byte) error
+}
+
+// wireCodec is the gRPC codec of the wire messages. It is named "proto"
+// so requests carry the content type of generated clients.
+type wireCodec struct{}
+
+func (wireCodec) Name() string { return "proto" }
+
+func (wireCodec) Marshal(v any) ([]byte, error) {
+	m, ok := v.(wireMessage)
+	if !ok {
+		return nil, fmt.Errorf("govaultd: cannot marshal %T", v)
+	}
+	return m.marshalWire(), nil
+}
+
+func (wireCodec) Unmarshal(data []byte, v any) error {
+	m, ok := v.(wireMessage)
+	if !ok {
+		return fmt.Errorf("govaultd: cannot unmarshal into %T", v)
+	}
+	return m.unmarshalWire(data)
+}
+
+// wireField is a field read by readWire
+type wireField struct {
+	num    protowire.Number
+	typ    protowire.Type
+	bytes  []byte
+	varint uint64
+}
+
+// string returns the value of a string field
+func (f wireField) string() (string, error) {
+	if f.typ != protowire.BytesType {
+		return "", fmt.Errorf("govaultd: field %d has wire type %d, want a string", f.num, f.typ)
+	}
+	return string(f.bytes), nil
+}
+
+// bool returns the value of a bool field
+func (f wireField) bool() (bool, error) {
+	if f.typ != protowire.VarintType {
+		return false, fmt.Errorf("govaultd: field %d has wire type %d, want a bool", f.num, f.typ)
+	}
+	return f.varint != 0, nil
+}
+
+// readWire calls field for every field of b. Fields of other wire types
+// than strings and varints are skipped, as unknown fields are.
+func readWire(b []byte, field func(f wireField) error) error {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return fmt.Errorf("govaultd: invalid message: %w", protowire.ParseError(n))
+		}
+		b = b[n:]
+
+		f := wireField{num: num, typ: typ}
+		switch typ {
+		case protowire.BytesType:
+			f.bytes, n = protowire.ConsumeBytes(b)
+		case protowire.VarintType:
+			f.varint, n = protowire.ConsumeVarint(b)
+		default:
+			n = protowire.ConsumeFieldValue(num, typ, b)
+		}
+		if n < 0 {
+			return fmt.Errorf("govaultd: invalid message: %w", protowire.ParseError(n))
+		}
+		b = b[n:]
+
+		if typ == protowire.BytesType || typ == protowire.VarintType {
+			if err := field(f); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// appendString appends a string field, omitted when empty as in proto3
+func appendString(b []byte, num protowire.Number, s string) []byte {
+	if s == "" {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendString(b, s)
+}
+
+// appendStrings appends a repeated string field, keeping empty elements
+func appendStrings(b []byte, num protowire.Number, values []string) []byte {
+	for _, s := range values {
+		b = protowire.AppendTag(b, num, protowire.BytesType)
+		b = protowire.AppendString(b, s)
+	}
+	return b
+}
+
+// appendBool appends a bool field, omitted when false as in proto3
+func appendBool(b []byte, num protowire.Number, v bool) []byte {
+	if !v {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.VarintType)
+	return protowire.AppendVarint(b, 1)
+}
+
+func (r *EncryptRequest) marshalWire() []byte {
+	b := appendString(nil, 1, r.Plaintext)
+	b = appendString(b, 2, r.KeyID)
+	return appendBool(b, 3, r.Deterministic)
+}
+
+func (r *EncryptRequest) unmarshalWire(b []byte) error {
+	return readWire(b, func(f wireField) (err error) {
+		switch f.num {
+		case 1:
+			r.Plaintext, err = f.string()
+		case 2:
+			r.KeyID, err = f.string()
+		case 3:
+			r.Deterministic, err = f.bool()
+		}
+		return err
+	})
+}
+
+func (r *DecryptRequest) marshalWire() []byte {
+	return appendString(nil, 1, r.Ciphertext)
+}
+
+func (r *DecryptRequest) unmarshalWire(b []byte) error {
+	return readWire(b, func(f wireField) (err error) {
+		if f.num == 1 {
+			r.Ciphertext, err = f.string()
+		}
+		return err
+	})
+}
+
+func (r *DecryptBatchRequest) marshalWire() []byte {
+	return appendStrings(nil, 1, r.Ciphertexts)
+}
+
+func (r *DecryptBatchRequest) unmarshalWire(b []byte) error {
+	return readWire(b, func(f wireField) error {
+		if f.num != 1 {
+			return nil
+		}
+		s, err := f.string()
+		r.Ciphertexts = append(r.Ciphertexts, s)
+		return err
+	})
+}
+
+func (r *ReEncryptRequest) marshalWire() []byte {
+	b := appendString(nil, 1, r.Ciphertext)
+	return appendString(b, 2, r.KeyID)
+}
+
+func (r *ReEncryptRequest) unmarshalWire(b []byte) error {
+	return readWire(b, func(f wireField) (err error) {
+		switch f.num {
+		case 1:
+			r.Ciphertext, err = f.string()
+		case 2:
+			r.KeyID, err = f.string()
+		}
+		return err
+	})
+}
+
+// keysRequest is the empty request of the Keys method
+type keysRequest struct{}
+
+func (*keysRequest) marshalWire() []byte { return nil }
+
+func (*keysRequest) unmarshalWire(b []byte) error {
+	return readWire(b, func(wireField) error { return nil })
+}
+
+func (r *CiphertextResponse) marshalWire() []byte {
+	return appendString(nil, 1, r.Ciphertext)
+}
+
+func (r *CiphertextResponse) unmarshalWire(b []byte) error {
+	return readWire(b, func(f wireField) (err error) {
+		if f.num == 1 {
+			r.Ciphertext, err = f.string()
+		}
+		return err
+	})
+}
+
+func (r *PlaintextResponse) marshalWire() []byte {
+	return appendString(nil, 1, r.Plaintext)
+}
+
+func (r *PlaintextResponse) unmarshalWire(b []byte) error {
+	return readWire(b, func(f wireField) (err error) {
+		if f.num == 1 {
+			r.Plaintext, err = f.string()
+		}
+		return err
+	})
+}
+
+func (r *PlaintextsResponse) marshalWire() []byte {
+	return appendStrings(nil, 1, r.Plaintexts)
+}
+
+func (r *PlaintextsResponse) unmarshalWire(b []byte) error {
+	return readWire(b, func(f wireField) error {
+		if f.num != 1 {
+			return nil
+		}
+		s, err := f.string()
+		r.Plaintexts = append(r.Plaintexts, s)
+		return err
+	})
+}
+
+func (r *KeysResponse) marshalWire() []byte {
+	b := appendStrings(nil, 1, r.KeyIDs)
+	return appendString(b, 2, r.DefaultKeyID)
+}
+
+func (r *KeysResponse) unmarshalWire(b []byte) error {
+	return readWire(b, func(f wireField) error {
+		switch f.num {
+		case 1:
+			s, err := f.string()
+			r.KeyIDs = append(r.KeyIDs, s)
+			return err
+		case 2:
+			var err error
+			r.DefaultKeyID, err = f.string()
+			return err
+		}
+		return nil
+	})
+}
@@ -0,0 +1,93 @@
+package govaulttest
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Dialects of the integration test databases
+const (
+	Postgres = "postgres"
+	MySQL    = "mysql"
+)
+
+// DB locates an integration test database
+type DB struct {
+	Dialect  string
+	Host     string
+	Port     int
+	User     string
+	Password string
+	Database string
+	// DSN, when set, overrides the other fields
+	DSN string
+}
+
+// defaultDBs are the local databases assumed when only a host is set, and
+// the credentials of the containers started by the test suites
+var defaultDBs = map[string]DB{
+	Postgres: {Dialect: Postgres, Host: "localhost", Port: 5432, User: "postgres", Password: "postgres", Database: "govault"},
+	MySQL:    {Dialect: MySQL, Host: "localhost", Port: 3306, User: "govault", Password: "govault", Database: "govault"},
+}
+
+// DBFromEnv reads the test database of dialect from the environment, e.g.
+// for Postgres GOVAULT_TEST_POSTGRES_DSN, or GOVAULT_TEST_POSTGRES_HOST and
+// the optional _PORT, _USER, _PASSWORD and _DATABASE. It reports false when
+// neither the DSN nor the host is set, with the defaults filled in, which
+// suites use to configure a container or skip.
+func DBFromEnv(dialect string) (DB, bool, error) {
+	db, known := defaultDBs[dialect]
+	if !known {
+		return DB{}, false, fmt.Errorf("unknown test database dialect '%s'", dialect)
+	}
+
+	prefix := "GOVAULT_TEST_" + strings.ToUpper(dialect) + "_"
+	if dsn := os.Getenv(prefix + "DSN"); dsn != "" {
+		db.DSN = dsn
+		return db, true, nil
+	}
+	host := os.Getenv(prefix + "HOST")
+	if host == "" {
+		return db, false, nil
+	}
+
+	db.Host = host
+	if port := os.Getenv(prefix + "PORT"); port != "" {
+		p, err := strconv.Atoi(port)
+		if err != nil {
+			return DB{}, false, fmt.Errorf("invalid %sPORT: %w", prefix, err)
+		}
+		db.Port = p
+	}
+	for name, field := range map[string]*string{"USER": &db.User, "PASSWORD": &db.Password, "DATABASE": &db.Database} {
+		if value, ok := os.LookupEnv(prefix + name); ok {
+			*field = value
+		}
+	}
+	return db, true, nil
+}
+
+// ConnString returns the DSN of db for the pgdriver or go-sql-driver/mysql
+// drivers
+func (db DB) ConnString() string {
+	if db.DSN != "" {
+		return db.DSN
+	}
+	switch db.Dialect {
+	case MySQL:
+		return fmt.Sprintf("%s:%s@tcp(%s)/%s?parseTime=true", db.User, db.Password, net.JoinHostPort(db.Host, strconv.Itoa(db.Port)), db.Database)
+	default:
+		u := url.URL{
+			Scheme:   "postgres",
+			User:     url.UserPassword(db.User, db.Password),
+			Host:     net.JoinHostPort(db.Host, strconv.Itoa(db.Port)),
+			Path:     "/" + db.Database,
+			RawQuery: "sslmode=disable",
+		}
+		return u.String()
+	}
+}
@@ -0,0 +1,59 @@
+// Package govaulttest provides random keys for tests, so test suites and CI
+// pipelines need no real secrets and no keys are committed with the tests:
+//
+//	govaultDB, err := govault.New(govaulttest.Config(2))
+//
+// The keys are generated once per process and held in memory only: data
+// encrypted by one test can be decrypted by another, but not by a later run.
+//
+// DBFromEnv locates the integration test databases shared by the adapter
+// test suites.
+//
+// MockCryptor implements govault.Cryptor without keys, for unit tests of
+// code that only encrypts and decrypts values.
+package govaulttest
+
+import (
+	"crypto/rand"
+	"strconv"
+	"sync"
+
+	"github.com/muhammadluth/govault"
+)
+
+// KeySize is the size of the generated keys, for AES-256
+const KeySize = 32
+
+var (
+	mu   sync.Mutex
+	keys [][]byte
+)
+
+// EphemeralKeys returns n random keys with the IDs "1" to "n". The same ID
+// holds the same key for the lifetime of the process.
+func EphemeralKeys(n int) map[string][]byte {
+	mu.Lock()
+	defer mu.Unlock()
+
+	for len(keys) < n {
+		key := make([]byte, KeySize)
+		// rand.Read never returns an error
+		_, _ = rand.Read(key)
+		keys = append(keys, key)
+	}
+
+	result := make(map[string][]byte, n)
+	for i := range n {
+		result[strconv.Itoa(i+1)] = append([]byte(nil), keys[i]...)
+	}
+	return result
+}
+
+// Config returns a Config with EphemeralKeys(n) and key "n" as the default
+// key. The caller sets the database and the other settings.
+func Config(n int) govault.Config {
+	return govault.Config{
+		Keys:         EphemeralKeys(n),
+		DefaultKeyID: strconv.Itoa(n),
+	}
+}
@@ -0,0 +1,40 @@
+package govaulttest_test
+
+import (
+	"testing"
+
+	"github.com/muhammadluth/govault/govaulttest"
+	"github.com/muhammadluth/govault/internal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEphemeralKeys(t *testing.T) {
+	keys := govaulttest.EphemeralKeys(2)
+	require.Len(t, keys, 2)
+	assert.Len(t, keys["1"], govaulttest.KeySize)
+	assert.NotEqual(t, keys["1"], keys["2"])
+
+	more := govaulttest.EphemeralKeys(3)
+	assert.Equal(t, keys["1"], more["1"], "keys are stable within the process")
+	assert.Equal(t, keys["2"], more["2"])
+
+	keys["1"][0]++
+	assert.NotEqual(t, keys["1"], govaulttest.EphemeralKeys(1)["1"], "callers get copies")
+}
+
+func TestConfig(t *testing.T) {
+	config := govaulttest.Config(2)
+	// No adapter needed to encrypt values
+	govaultDB, err := internal.New(config)
+	require.NoError(t, err)
+	assert.Equal(t, "2", govaultDB.GetDefaultKeyID())
+
+	other, err := internal.New(govaulttest.Config(2))
+	require.NoError(t, err)
+	ciphertext, err := govaultDB.Encrypt("secret")
+	require.NoError(t, err)
+	plaintext, err := other.Decrypt(ciphertext)
+	require.NoError(t, err)
+	assert.Equal(t, "secret", plaintext)
+}
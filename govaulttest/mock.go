@@ -0,0 +1,182 @@
+package govaulttest
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/muhammadluth/govault"
+	"github.com/muhammadluth/govault/internal"
+)
+
+// MockPrefix starts the values encrypted by MockCryptor
+const MockPrefix = "mock:"
+
+// MockCiphertext returns the value MockCryptor encrypts plaintext to with
+// keyID, for assertions
+func MockCiphertext(keyID, plaintext string) string {
+	return MockPrefix + keyID + ":" + plaintext
+}
+
+// MockCall is a call recorded by MockCryptor
+type MockCall struct {
+	Method string
+	Args   []any
+}
+
+// MockCryptor is a govault.Cryptor for unit tests of code depending on
+// one, without keys. Encrypt is deterministic and keeps the plaintext
+// readable, see MockCiphertext, so tests can assert on stored values. The
+// zero value has the single key "1".
+type MockCryptor struct {
+	// KeyIDs are the keys reported by GetKeyIDs and accepted by Encrypt,
+	// {"1"} when empty
+	KeyIDs []string
+	// DefaultKeyID is the key of Encrypt without one, the first of KeyIDs
+	// when empty
+	DefaultKeyID string
+	// EncryptErr and DecryptErr, when set, fail every Encrypt, and every
+	// Decrypt and DecryptRecursive
+	EncryptErr error
+	DecryptErr error
+
+	mu    sync.Mutex
+	calls []MockCall
+}
+
+var _ govault.Cryptor = (*MockCryptor)(nil)
+
+// Calls returns the calls made so far, in order
+func (m *MockCryptor) Calls() []MockCall {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return slices.Clone(m.calls)
+}
+
+// record appends a call
+func (m *MockCryptor) record(method string, args ...any) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.calls = append(m.calls, MockCall{Method: method, Args: args})
+}
+
+// Encrypt returns MockCiphertext of plaintext with keyID, or the default
+// key. Empty strings are returned as is, like GovaultDB does by default.
+func (m *MockCryptor) Encrypt(plaintext string, keyID ...string) (string, error) {
+	m.record("Encrypt", plaintext, keyID)
+	if m.EncryptErr != nil {
+		return "", m.EncryptErr
+	}
+	if plaintext == "" {
+		return "", nil
+	}
+
+	id := m.GetDefaultKeyID()
+	if len(keyID) > 0 && keyID[0] != "" {
+		id = keyID[0]
+	}
+	if !slices.Contains(m.GetKeyIDs(), id) {
+		return "", fmt.Errorf("key ID '%s' not found", id)
+	}
+	return MockCiphertext(id, plaintext), nil
+}
+
+// Decrypt returns the plaintext of a value of Encrypt. Other values fail
+// with govault.ErrInvalidCiphertext.
+func (m *MockCryptor) Decrypt(encryptedData string) (string, error) {
+	m.record("Decrypt", encryptedData)
+	if m.DecryptErr != nil {
+		return "", m.DecryptErr
+	}
+	return m.decrypt(encryptedData)
+}
+
+// decrypt parses a MockCiphertext
+func (m *MockCryptor) decrypt(value string) (string, error) {
+	if value == "" {
+		return "", nil
+	}
+	rest, ok := strings.CutPrefix(value, MockPrefix)
+	keyID, plaintext, found := strings.Cut(rest, ":")
+	if !ok || !found {
+		return "", fmt.Errorf("%w: not a mock ciphertext", govault.ErrInvalidCiphertext)
+	}
+	if !slices.Contains(m.GetKeyIDs(), keyID) {
+		return "", fmt.Errorf("key ID '%s' not found", keyID)
+	}
+	return plaintext, nil
+}
+
+// DecryptRecursive decrypts the string fields tagged with encrypted:"true"
+// of value, a pointer, and of the structs it holds in fields, slices and
+// maps. Values that are not mock ciphertexts are left as is.
+func (m *MockCryptor) DecryptRecursive(value any) error {
+	m.record("DecryptRecursive", value)
+	if m.DecryptErr != nil {
+		return m.DecryptErr
+	}
+	return m.decryptValue(reflect.ValueOf(value))
+}
+
+// decryptValue walks v for structs with encrypted fields
+func (m *MockCryptor) decryptValue(v reflect.Value) error {
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+		return m.decryptValue(v.Elem())
+	case reflect.Slice, reflect.Array:
+		for i := range v.Len() {
+			if err := m.decryptValue(v.Index(i)); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		for iter := v.MapRange(); iter.Next(); {
+			if err := m.decryptValue(iter.Value()); err != nil {
+				return err
+			}
+		}
+	case reflect.Struct:
+		if spec := internal.GetModelSpec(reflect.Zero(v.Type()).Interface()); spec != nil && v.CanSet() {
+			for _, field := range spec.Fields {
+				f := v.FieldByIndex(field.Index)
+				if f.Kind() != reflect.String || !f.CanSet() || !strings.HasPrefix(f.String(), MockPrefix) {
+					continue
+				}
+				plaintext, err := m.decrypt(f.String())
+				if err != nil {
+					return fmt.Errorf("field %s: %w", field.Name, err)
+				}
+				f.SetString(plaintext)
+			}
+		}
+		for i := range v.NumField() {
+			if v.Type().Field(i).IsExported() {
+				if err := m.decryptValue(v.Field(i)); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// GetKeyIDs returns KeyIDs, {"1"} when empty
+func (m *MockCryptor) GetKeyIDs() []string {
+	if len(m.KeyIDs) == 0 {
+		return []string{"1"}
+	}
+	return m.KeyIDs
+}
+
+// GetDefaultKeyID returns DefaultKeyID, the first key ID when empty
+func (m *MockCryptor) GetDefaultKeyID() string {
+	if m.DefaultKeyID != "" {
+		return m.DefaultKeyID
+	}
+	return m.GetKeyIDs()[0]
+}
@@ -0,0 +1,75 @@
+package govaulttest_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/muhammadluth/govault"
+	"github.com/muhammadluth/govault/govaulttest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type mockProfile struct {
+	Bio string `encrypted:"true"`
+}
+
+type mockUser struct {
+	Name     string
+	Email    string `encrypted:"true"`
+	Profile  *mockProfile
+	Previous []mockProfile
+}
+
+// signup stands for consumer code depending on the interface
+func signup(c govault.Cryptor, email string) (string, error) {
+	return c.Encrypt(email)
+}
+
+func TestMockCryptor(t *testing.T) {
+	mock := &govaulttest.MockCryptor{KeyIDs: []string{"1", "2"}, DefaultKeyID: "2"}
+
+	encrypted, err := signup(mock, "ada@example.com")
+	require.NoError(t, err)
+	assert.Equal(t, govaulttest.MockCiphertext("2", "ada@example.com"), encrypted)
+
+	again, err := mock.Encrypt("ada@example.com")
+	require.NoError(t, err)
+	assert.Equal(t, encrypted, again, "deterministic")
+
+	plaintext, err := mock.Decrypt(encrypted)
+	require.NoError(t, err)
+	assert.Equal(t, "ada@example.com", plaintext)
+
+	_, err = mock.Encrypt("x", "3")
+	assert.Error(t, err)
+	_, err = mock.Decrypt("plain")
+	assert.ErrorIs(t, err, govault.ErrInvalidCiphertext)
+
+	t.Run("recursive", func(t *testing.T) {
+		users := []mockUser{{
+			Name:     "Ada",
+			Email:    govaulttest.MockCiphertext("1", "ada@example.com"),
+			Profile:  &mockProfile{Bio: govaulttest.MockCiphertext("1", "hello")},
+			Previous: []mockProfile{{Bio: govaulttest.MockCiphertext("2", "old")}},
+		}}
+		require.NoError(t, mock.DecryptRecursive(&users))
+		assert.Equal(t, "ada@example.com", users[0].Email)
+		assert.Equal(t, "hello", users[0].Profile.Bio)
+		assert.Equal(t, "old", users[0].Previous[0].Bio)
+		assert.Equal(t, "Ada", users[0].Name)
+	})
+
+	t.Run("errors and calls", func(t *testing.T) {
+		failing := &govaulttest.MockCryptor{EncryptErr: errors.New("boom")}
+		_, err := failing.Encrypt("x")
+		assert.EqualError(t, err, "boom")
+		assert.Equal(t, []string{"1"}, failing.GetKeyIDs())
+		assert.Equal(t, "1", failing.GetDefaultKeyID())
+
+		calls := failing.Calls()
+		require.Len(t, calls, 1)
+		assert.Equal(t, "Encrypt", calls[0].Method)
+		assert.Equal(t, "x", calls[0].Args[0])
+	})
+}
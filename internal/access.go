@@ -0,0 +1,125 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// Headers read by AccessMiddleware and AccessFromMetadata by default
+const (
+	AccessPurposeHeader = "Govault-Purpose"
+	AccessActorHeader   = "Govault-Actor"
+)
+
+// Access declares who decrypts and why. It is set on the context of a
+// request with WithAccess, usually by AccessMiddleware, and recorded by
+// the audit records of escrow exports and materialized copies; model hooks
+// can read it with AccessFromContext.
+type Access struct {
+	Purpose string `json:"purpose,omitempty"`
+	Actor   string `json:"actor,omitempty"`
+}
+
+type accessKey struct{}
+
+// WithAccess returns a context declaring access
+func WithAccess(ctx context.Context, access Access) context.Context {
+	return context.WithValue(ctx, accessKey{}, access)
+}
+
+// AccessFromContext returns the access set with WithAccess, or zero
+func AccessFromContext(ctx context.Context) Access {
+	if ctx == nil {
+		return Access{}
+	}
+	access, _ := ctx.Value(accessKey{}).(Access)
+	return access
+}
+
+// AccessOptions configures AccessMiddleware and AccessFromMetadata
+type AccessOptions struct {
+	// Extract reads the access of an HTTP request, by default from the
+	// AccessPurposeHeader and AccessActorHeader headers. Headers are set by
+	// the caller, so the actor should rather come from the authenticated
+	// session unless a trusted proxy sets it.
+	Extract func(r *http.Request) (Access, error)
+	// RequirePurpose rejects requests without a purpose
+	RequirePurpose bool
+	// Purposes are the purposes accepted, empty accepts any
+	Purposes []string
+}
+
+// errPurposeRequired is returned for requests without a purpose when
+// AccessOptions.RequirePurpose is set
+var errPurposeRequired = errors.New("a purpose of use is required")
+
+// check validates access against the options
+func (o AccessOptions) check(access Access) error {
+	if access.Purpose == "" {
+		if o.RequirePurpose {
+			return errPurposeRequired
+		}
+		return nil
+	}
+	if len(o.Purposes) > 0 && !slices.Contains(o.Purposes, access.Purpose) {
+		return fmt.Errorf("purpose '%s': %w", access.Purpose, ErrOperationNotAllowed)
+	}
+	return nil
+}
+
+// AccessMiddleware returns HTTP middleware setting the Access of every
+// request on its context. Requests failing extraction or without a
+// required purpose get 400, those with a purpose not accepted 403.
+func AccessMiddleware(opts AccessOptions) func(http.Handler) http.Handler {
+	extract := opts.Extract
+	if extract == nil {
+		extract = func(r *http.Request) (Access, error) {
+			return Access{
+				Purpose: strings.TrimSpace(r.Header.Get(AccessPurposeHeader)),
+				Actor:   strings.TrimSpace(r.Header.Get(AccessActorHeader)),
+			}, nil
+		}
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			access, err := extract(r)
+			if err == nil {
+				err = opts.check(access)
+			}
+			if err != nil {
+				status := http.StatusBadRequest
+				if errors.Is(err, ErrOperationNotAllowed) {
+					status = http.StatusForbidden
+				}
+				http.Error(w, err.Error(), status)
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(WithAccess(r.Context(), access)))
+		})
+	}
+}
+
+// AccessFromMetadata returns ctx with the Access read from the lower case
+// AccessPurposeHeader and AccessActorHeader keys of md, e.g. the
+// metadata.MD of a gRPC interceptor. opts.Extract is not used. Errors of
+// purposes not accepted wrap ErrOperationNotAllowed.
+//
+//	md, _ := metadata.FromIncomingContext(ctx)
+//	ctx, err := govault.AccessFromMetadata(ctx, md, opts)
+func AccessFromMetadata(ctx context.Context, md map[string][]string, opts AccessOptions) (context.Context, error) {
+	first := func(key string) string {
+		if values := md[strings.ToLower(key)]; len(values) > 0 {
+			return strings.TrimSpace(values[0])
+		}
+		return ""
+	}
+	access := Access{Purpose: first(AccessPurposeHeader), Actor: first(AccessActorHeader)}
+	if err := opts.check(access); err != nil {
+		return ctx, err
+	}
+	return WithAccess(ctx, access), nil
+}
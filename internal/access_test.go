@@ -0,0 +1,99 @@
+package internal_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/muhammadluth/govault/internal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAccessMiddleware(t *testing.T) {
+	var seen internal.Access
+	handler := internal.AccessMiddleware(internal.AccessOptions{
+		RequirePurpose: true,
+		Purposes:       []string{"support", "billing"},
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = internal.AccessFromContext(r.Context())
+	}))
+
+	serve := func(purpose, actor string) int {
+		r := httptest.NewRequest(http.MethodGet, "/customers/1", nil)
+		if purpose != "" {
+			r.Header.Set(internal.AccessPurposeHeader, purpose)
+		}
+		if actor != "" {
+			r.Header.Set(internal.AccessActorHeader, actor)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w.Code
+	}
+
+	t.Run("the access is set on the context", func(t *testing.T) {
+		assert.Equal(t, http.StatusOK, serve("support", "agent-7"))
+		assert.Equal(t, internal.Access{Purpose: "support", Actor: "agent-7"}, seen)
+	})
+
+	t.Run("requests without a purpose are rejected", func(t *testing.T) {
+		assert.Equal(t, http.StatusBadRequest, serve("", "agent-7"))
+	})
+
+	t.Run("purposes not accepted are forbidden", func(t *testing.T) {
+		assert.Equal(t, http.StatusForbidden, serve("marketing", "agent-7"))
+	})
+
+	t.Run("extractors replace the headers", func(t *testing.T) {
+		handler := internal.AccessMiddleware(internal.AccessOptions{
+			Extract: func(r *http.Request) (internal.Access, error) {
+				return internal.Access{Purpose: "export", Actor: "session-user"}, nil
+			},
+		})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			seen = internal.AccessFromContext(r.Context())
+		}))
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set(internal.AccessActorHeader, "spoofed")
+		handler.ServeHTTP(httptest.NewRecorder(), r)
+		assert.Equal(t, "session-user", seen.Actor)
+	})
+}
+
+func TestAccessFromMetadata(t *testing.T) {
+	opts := internal.AccessOptions{Purposes: []string{"support"}}
+
+	ctx, err := internal.AccessFromMetadata(context.Background(), map[string][]string{
+		"govault-purpose": {"support"},
+		"govault-actor":   {"svc-tickets"},
+	}, opts)
+	require.NoError(t, err)
+	assert.Equal(t, internal.Access{Purpose: "support", Actor: "svc-tickets"}, internal.AccessFromContext(ctx))
+
+	_, err = internal.AccessFromMetadata(context.Background(), map[string][]string{"govault-purpose": {"marketing"}}, opts)
+	assert.ErrorIs(t, err, internal.ErrOperationNotAllowed)
+
+	assert.Zero(t, internal.AccessFromContext(context.Background()))
+}
+
+func TestAccessAuditRecords(t *testing.T) {
+	g, err := internal.New(internal.Config{
+		Keys:         map[string][]byte{"1": reloadKey1},
+		DefaultKeyID: "1",
+	})
+	require.NoError(t, err)
+
+	var record internal.EscrowRecord
+	ctx := internal.WithAccess(context.Background(), internal.Access{Purpose: "INC-42", Actor: "oncall"})
+	_, _, err = g.ExportEscrow(ctx, internal.EscrowOptions{
+		Approvers: []string{"alice", "bob"},
+		Threshold: 2,
+		Audit: func(ctx context.Context, r *internal.EscrowRecord, err error) {
+			record = *r
+		},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "INC-42", record.Purpose)
+	assert.Equal(t, "oncall", record.Actor)
+}
@@ -0,0 +1,106 @@
+package internal
+
+import (
+	"crypto/cipher"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"strings"
+
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/hkdf"
+)
+
+// Algorithm is the AEAD a native ciphertext is sealed with, selected per
+// field with the algorithm tag or FieldConfig.Algorithm
+type Algorithm string
+
+const (
+	// AlgorithmAESGCM is AES-256-GCM with 12 byte random nonces, the
+	// default
+	AlgorithmAESGCM Algorithm = "aes-gcm"
+	// AlgorithmXChaCha20Poly1305 is XChaCha20-Poly1305 with 24 byte
+	// nonces, safe to draw at random for any number of values per key
+	AlgorithmXChaCha20Poly1305 Algorithm = "xchacha20poly1305"
+)
+
+// xchachaFlag marks XChaCha20-Poly1305 values in the flags of gv1, so
+// reads pick the algorithm of each value whatever the field says
+const xchachaFlag = "x"
+
+// xchachaDomain separates the XChaCha20-Poly1305 key from the AES key
+// derived from the same key material
+const xchachaDomain = "govault xchacha20poly1305"
+
+// normalize returns a with the empty algorithm mapped to AES-GCM
+func (a Algorithm) normalize() Algorithm {
+	if a == "" {
+		return AlgorithmAESGCM
+	}
+	return a
+}
+
+// checkAlgorithm rejects unknown algorithms
+func checkAlgorithm(a Algorithm) error {
+	switch a.normalize() {
+	case AlgorithmAESGCM, AlgorithmXChaCha20Poly1305:
+		return nil
+	}
+	return fmt.Errorf("unknown algorithm '%s'", a)
+}
+
+// nonceSize returns the nonce size of a
+func (a Algorithm) nonceSize() int {
+	if a.normalize() == AlgorithmXChaCha20Poly1305 {
+		return chacha20poly1305.NonceSizeX
+	}
+	return gcmNonceSize
+}
+
+// newXChaCha returns the XChaCha20-Poly1305 cipher of key material derived
+// with HKDF, so no key bytes are shared between two algorithms
+func newXChaCha(keyBytes []byte) (cipher.AEAD, error) {
+	derived := make([]byte, chacha20poly1305.KeySize)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, keyBytes, nil, []byte(xchachaDomain)), derived); err != nil {
+		return nil, fmt.Errorf("failed to derive xchacha20poly1305 key: %w", err)
+	}
+	return chacha20poly1305.NewX(derived)
+}
+
+// aead returns the cipher of key for algorithm a
+func (k *Key) aead(a Algorithm) (cipher.AEAD, error) {
+	if k.hpke != nil {
+		return nil, fmt.Errorf("key '%s' is an HPKE key", k.ID)
+	}
+	switch a.normalize() {
+	case AlgorithmAESGCM:
+		return k.cipher, nil
+	case AlgorithmXChaCha20Poly1305:
+		return k.xchacha, nil
+	}
+	return nil, fmt.Errorf("unknown algorithm '%s'", a)
+}
+
+// AlgorithmOf returns the algorithm of a native ciphertext, AES-GCM for
+// the legacy format
+func AlgorithmOf(value string) (Algorithm, error) {
+	if strings.HasPrefix(value, HPKEFormatPrefix) {
+		return "", fmt.Errorf("HPKE ciphertexts have no algorithm")
+	}
+	env, err := parseEnvelope(value)
+	if err != nil {
+		return "", err
+	}
+	return env.algorithm.normalize(), nil
+}
+
+// NeedsAlgorithmMigration reports whether value is a native ciphertext
+// sealed with another algorithm than field is configured with. Values in
+// other formats never need one.
+func NeedsAlgorithmMigration(field *FieldSpec, value string) bool {
+	if field == nil || field.Codec != "" || value == "" {
+		return false
+	}
+	algorithm, err := AlgorithmOf(value)
+	return err == nil && algorithm != field.Algorithm.normalize()
+}
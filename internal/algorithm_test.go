@@ -0,0 +1,92 @@
+package internal_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/muhammadluth/govault/internal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type algorithmUser struct {
+	ID    int64  `bun:"id,pk"`
+	Email string `bun:"email" encrypted:"true" algorithm:"xchacha20poly1305"`
+	Phone string `bun:"phone" encrypted:"true"`
+	Token string `bun:"token" encrypted:"true" algorithm:"xchacha20poly1305" deterministic:"true"`
+}
+
+type algorithmLegacyUser struct {
+	ID    int64  `bun:"id,pk"`
+	Email string `bun:"email" encrypted:"true"`
+}
+
+func TestAlgorithmAgility(t *testing.T) {
+	g, err := internal.New(internal.Config{
+		Keys:         map[string][]byte{"1": []byte("727d37a0-a5f2-4d67-af47-83039c8e")},
+		DefaultKeyID: "1",
+	})
+	require.NoError(t, err)
+
+	t.Run("fields are sealed with their algorithm", func(t *testing.T) {
+		user := &algorithmUser{Email: "ada@example.com", Phone: "+62811", Token: "tok"}
+		require.NoError(t, g.EncryptModel(user, ""))
+		assert.True(t, strings.HasSuffix(user.Email, "|x"))
+		assert.False(t, strings.HasSuffix(user.Phone, "|x"))
+
+		algorithm, err := internal.AlgorithmOf(user.Email)
+		require.NoError(t, err)
+		assert.Equal(t, internal.AlgorithmXChaCha20Poly1305, algorithm)
+		algorithm, err = internal.AlgorithmOf(user.Phone)
+		require.NoError(t, err)
+		assert.Equal(t, internal.AlgorithmAESGCM, algorithm)
+
+		again := &algorithmUser{Token: "tok"}
+		require.NoError(t, g.EncryptModel(again, ""))
+		assert.Equal(t, user.Token, again.Token, "deterministic")
+
+		require.NoError(t, g.DecryptRecursive(user))
+		assert.Equal(t, "ada@example.com", user.Email)
+		assert.Equal(t, "+62811", user.Phone)
+		assert.Equal(t, "tok", user.Token)
+	})
+
+	t.Run("reads honor the header of each value", func(t *testing.T) {
+		legacy := &algorithmLegacyUser{Email: "old@example.com"}
+		require.NoError(t, g.EncryptModel(legacy, ""))
+
+		// A column switched to XChaCha still reads its AES-GCM values
+		user := &algorithmUser{Email: legacy.Email}
+		require.NoError(t, g.DecryptRecursive(user))
+		assert.Equal(t, "old@example.com", user.Email)
+
+		spec := internal.GetModelSpec(&algorithmUser{})
+		assert.True(t, internal.NeedsAlgorithmMigration(spec.Field("Email"), legacy.Email))
+		assert.False(t, internal.NeedsAlgorithmMigration(spec.Field("Phone"), legacy.Email))
+		assert.False(t, internal.NeedsAlgorithmMigration(spec.Field("Email"), "plain"))
+	})
+
+	t.Run("binary storage keeps the algorithm", func(t *testing.T) {
+		user := &algorithmUser{Email: "bin@example.com"}
+		require.NoError(t, g.EncryptModel(user, ""))
+
+		data, err := internal.EncodeBinary(user.Email)
+		require.NoError(t, err)
+		decoded, err := internal.DecodeBinary(data)
+		require.NoError(t, err)
+		assert.Equal(t, user.Email, decoded)
+	})
+
+	t.Run("unknown algorithms are rejected", func(t *testing.T) {
+		field := &internal.FieldSpec{Name: "Email", Column: "email", Algorithm: "rot13"}
+		_, err := g.EncryptField(field, "users", "x", "")
+		assert.ErrorContains(t, err, "unknown algorithm 'rot13'")
+	})
+
+	t.Run("misordered flags are malformed", func(t *testing.T) {
+		encrypted, err := g.EncryptField(&internal.FieldSpec{Algorithm: internal.AlgorithmXChaCha20Poly1305}, "", "x", "")
+		require.NoError(t, err)
+		_, err = g.Decrypt(encrypted + "|p")
+		assert.ErrorIs(t, err, internal.ErrInvalidCiphertext)
+	})
+}
@@ -0,0 +1,99 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sort"
+)
+
+// KeyArchive loads long-retired keys from cold storage, see
+// Config.ArchivedKeys
+type KeyArchive interface {
+	// LoadKey returns the 32 byte key keyID
+	LoadKey(ctx context.Context, keyID string) ([]byte, error)
+}
+
+// KeyArchiveFunc adapts a function to a KeyArchive
+type KeyArchiveFunc func(ctx context.Context, keyID string) ([]byte, error)
+
+// LoadKey calls f
+func (f KeyArchiveFunc) LoadKey(ctx context.Context, keyID string) ([]byte, error) {
+	return f(ctx, keyID)
+}
+
+// StaticKeyArchive returns an archive of keys held in memory, e.g. read
+// from an offline backup by an investigation tool
+func StaticKeyArchive(keys map[string][]byte) KeyArchive {
+	return KeyArchiveFunc(func(ctx context.Context, keyID string) ([]byte, error) {
+		key, exists := keys[keyID]
+		if !exists {
+			return nil, fmt.Errorf("key '%s' is not archived", keyID)
+		}
+		return bytes.Clone(key), nil
+	})
+}
+
+// lookupDecryptKey returns the key with the given ID to decrypt, like
+// lookupKey, loading it from the archive when the key set does not hold it.
+// Its use is counted by the rate limit of the key.
+func (g *GovaultDB) lookupDecryptKey(keyID string) (*Key, error) {
+	_, exists := g.keyEntry(keyID)
+	if !exists && g.archive != nil {
+		key, err := g.archivedKey(keyID)
+		if err != nil {
+			return nil, fmt.Errorf("%w. Available: %v", err, g.GetKeyIDs())
+		}
+		if err := g.allowKey(keyID); err != nil {
+			return nil, err
+		}
+		return key, nil
+	}
+
+	if exists {
+		if err := g.allowKey(keyID); err != nil {
+			return nil, err
+		}
+	}
+	key, err := g.lookupKey(keyID)
+	if err != nil {
+		return nil, fmt.Errorf("%w. Available: %v", err, g.GetKeyIDs())
+	}
+	return key, nil
+}
+
+// archivedKey returns the archived key with the given ID, loading it on
+// first use
+func (g *GovaultDB) archivedKey(keyID string) (*Key, error) {
+	if key, ok := g.archived.Load(keyID); ok {
+		return key.(*Key), nil
+	}
+	value, err := g.archive.LoadKey(context.Background(), keyID)
+	if err != nil {
+		return nil, fmt.Errorf("encryption key '%s' not found, loading it from the archive failed: %w", keyID, err)
+	}
+	key, err := newKey(keyID, value)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize archived key '%s': %w", keyID, err)
+	}
+	key.DecryptOnly = true
+	actual, _ := g.archived.LoadOrStore(keyID, key)
+	return actual.(*Key), nil
+}
+
+// ArchivedKeyIDs returns the IDs of the archived keys loaded so far
+func (g *GovaultDB) ArchivedKeyIDs() []string {
+	var ids []string
+	g.archived.Range(func(keyID, _ any) bool {
+		ids = append(ids, keyID.(string))
+		return true
+	})
+	sort.Strings(ids)
+	return ids
+}
+
+// UnloadArchivedKeys drops the archived keys loaded so far from memory, e.g.
+// once an investigation is over. They are loaded again on next use.
+func (g *GovaultDB) UnloadArchivedKeys() {
+	g.archived.Clear()
+}
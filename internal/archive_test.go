@@ -0,0 +1,86 @@
+package internal_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/muhammadluth/govault/internal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestArchivedKeys(t *testing.T) {
+	retired, err := internal.New(internal.Config{
+		Keys:         map[string][]byte{"1": reloadKey1},
+		DefaultKeyID: "1",
+	})
+	require.NoError(t, err)
+	old, err := retired.Encrypt("old backup")
+	require.NoError(t, err)
+
+	loads := 0
+	archive := internal.StaticKeyArchive(map[string][]byte{"1": reloadKey1})
+	g, err := internal.New(internal.Config{
+		Keys:         map[string][]byte{"2": reloadKey2},
+		DefaultKeyID: "2",
+		ArchivedKeys: internal.KeyArchiveFunc(func(ctx context.Context, keyID string) ([]byte, error) {
+			loads++
+			return archive.LoadKey(ctx, keyID)
+		}),
+	})
+	require.NoError(t, err)
+
+	t.Run("retired keys are loaded on demand", func(t *testing.T) {
+		assert.Empty(t, g.ArchivedKeyIDs())
+		for range 2 {
+			plaintext, err := g.Decrypt(old)
+			require.NoError(t, err)
+			assert.Equal(t, "old backup", plaintext)
+		}
+		assert.Equal(t, 1, loads, "archived keys are cached")
+		assert.Equal(t, []string{"1"}, g.ArchivedKeyIDs())
+	})
+
+	t.Run("archived keys stay out of the key set", func(t *testing.T) {
+		assert.Equal(t, []string{"2"}, g.GetKeyIDs())
+		_, err := g.Encrypt("new", "1")
+		assert.Error(t, err)
+
+		current, err := g.Encrypt("new")
+		require.NoError(t, err)
+		keyID, err := g.GetKeyIDFromEncryptedData(current)
+		require.NoError(t, err)
+		assert.Equal(t, "2", keyID)
+	})
+
+	t.Run("unloaded keys are loaded again", func(t *testing.T) {
+		g.UnloadArchivedKeys()
+		assert.Empty(t, g.ArchivedKeyIDs())
+		_, err := g.Decrypt(old)
+		require.NoError(t, err)
+		assert.Equal(t, 2, loads)
+	})
+
+	t.Run("keys missing from the archive fail", func(t *testing.T) {
+		other, err := internal.New(internal.Config{
+			Keys:         map[string][]byte{"3": reloadKey3},
+			DefaultKeyID: "3",
+		})
+		require.NoError(t, err)
+		value, err := other.Encrypt("x")
+		require.NoError(t, err)
+		_, err = g.Decrypt(value)
+		assert.ErrorContains(t, err, "not archived")
+	})
+
+	t.Run("decrypt-only instances may hold archived keys only", func(t *testing.T) {
+		investigation, err := internal.New(internal.Config{
+			Mode:         internal.ModeDecryptOnly,
+			ArchivedKeys: archive,
+		})
+		require.NoError(t, err)
+		plaintext, err := investigation.Decrypt(old)
+		require.NoError(t, err)
+		assert.Equal(t, "old backup", plaintext)
+	})
+}
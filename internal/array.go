@@ -0,0 +1,108 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+// errArrayCompanion rejects companion columns, e.g. blind indexes, on
+// []string fields, whose elements have no single plaintext to index
+var errArrayCompanion = errors.New("companion columns are not supported on arrays")
+
+// encryptArray normalizes and encrypts a []string field in place, per
+// element or as a whole, see FieldSpec.Array. Empty arrays are left as is.
+func (g *GovaultDB) encryptArray(field reflect.Value, fieldSpec *FieldSpec, table, keyID string) error {
+	if fieldSpec.BlindIndex != "" || fieldSpec.PlainHash != "" || fieldSpec.TokenIndex != "" || fieldSpec.Bloom != "" || fieldSpec.Bucket != "" || fieldSpec.Geohash != "" || fieldSpec.EmailDomain != "" ||
+		fieldSpec.PANBIN != "" || fieldSpec.PANLast4 != "" || fieldSpec.PANToken != "" {
+		return errArrayCompanion
+	}
+	if field.Len() == 0 {
+		return nil
+	}
+
+	if fieldSpec.Array == ArrayWhole {
+		elements := make([]string, field.Len())
+		for i := range elements {
+			element, err := NormalizeField(fieldSpec, field.Index(i).String())
+			if err != nil {
+				return fmt.Errorf("element %d: %w", i, err)
+			}
+			elements[i] = element
+		}
+		plaintext, err := json.Marshal(elements)
+		if err != nil {
+			return err
+		}
+		encrypted, err := g.EncryptField(fieldSpec, table, string(plaintext), keyID)
+		if err != nil {
+			return err
+		}
+		whole := reflect.MakeSlice(field.Type(), 1, 1)
+		whole.Index(0).SetString(encrypted)
+		field.Set(whole)
+		return nil
+	}
+
+	for i := 0; i < field.Len(); i++ {
+		elem := field.Index(i)
+		plaintext := elem.String()
+		if plaintext == "" && !g.EncryptsEmpty(fieldSpec) {
+			continue
+		}
+		plaintext, err := NormalizeField(fieldSpec, plaintext)
+		if err != nil {
+			return fmt.Errorf("element %d: %w", i, err)
+		}
+		encrypted, err := g.EncryptField(fieldSpec, table, plaintext, keyID)
+		if err != nil {
+			return fmt.Errorf("element %d: %w", i, err)
+		}
+		elem.SetString(encrypted)
+	}
+	return nil
+}
+
+// decryptArray decrypts a []string field written by encryptArray.
+// Elements that are not ciphertexts are kept, so arrays encrypted per
+// element may be migrated one element at a time.
+func (g *GovaultDB) decryptArray(ctx context.Context, field reflect.Value, fieldSpec *FieldSpec, table, role string, budget *decryptBudget) error {
+	if fieldSpec.Array == ArrayWhole && field.Len() == 1 && IsFieldEncrypted(fieldSpec, field.Index(0).String()) {
+		decrypted, ok, err := g.decryptString(ctx, fieldSpec, table, fieldSpec.Name, field.Index(0).String(), "", budget)
+		if err != nil || !ok || decrypted == FallbackMaskValue {
+			if ok {
+				field.Index(0).SetString(decrypted)
+			}
+			return err
+		}
+		var elements []string
+		if err := json.Unmarshal([]byte(decrypted), &elements); err != nil {
+			return fmt.Errorf("failed to decrypt field %s: not a whole array: %w", fieldSpec.Name, err)
+		}
+		whole := reflect.MakeSlice(field.Type(), len(elements), len(elements))
+		for i, element := range elements {
+			if role != "" {
+				if element, err = g.TransformField(fieldSpec, table, role, element); err != nil {
+					return fmt.Errorf("failed to transform field %s: %w", fieldSpec.Name, err)
+				}
+			}
+			whole.Index(i).SetString(element)
+		}
+		field.Set(whole)
+		return nil
+	}
+
+	for i := 0; i < field.Len(); i++ {
+		elem := field.Index(i)
+		decrypted, ok, err := g.decryptString(ctx, fieldSpec, table, fieldSpec.Name, elem.String(), role, budget)
+		if err != nil {
+			return fmt.Errorf("element %d: %w", i, err)
+		}
+		if ok {
+			elem.SetString(decrypted)
+		}
+	}
+	return nil
+}
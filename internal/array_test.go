@@ -0,0 +1,80 @@
+package internal_test
+
+import (
+	"testing"
+
+	"github.com/muhammadluth/govault/internal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type arrayUser struct {
+	ID     int64    `bun:"id,pk"`
+	Phones []string `bun:"phones,array" encrypted:"true"`
+	Emails []string `bun:"emails,array" encrypted:"true" encryptarray:"whole" encryptpad:"64"`
+	Tags   []string `bun:"tags,array"`
+}
+
+type arrayIndexedUser struct {
+	Phones     []string `bun:"phones,array" encrypted:"true" blindindex:"phones_bidx"`
+	PhonesBidx string   `bun:"phones_bidx"`
+}
+
+func TestArrayFields(t *testing.T) {
+	g, err := internal.New(internal.Config{
+		Keys:         map[string][]byte{"1": []byte("727d37a0-a5f2-4d67-af47-83039c8e")},
+		DefaultKeyID: "1",
+	})
+	require.NoError(t, err)
+
+	spec := internal.GetModelSpec(&arrayUser{})
+	assert.Equal(t, internal.ArrayElements, spec.Field("Phones").Array)
+	assert.Equal(t, internal.ArrayWhole, spec.Field("Emails").Array)
+	assert.Nil(t, spec.Field("Tags"))
+
+	t.Run("elements are encrypted on their own", func(t *testing.T) {
+		user := &arrayUser{Phones: []string{"+62811", "", "+62812"}, Tags: []string{"vip"}}
+		require.NoError(t, g.EncryptModel(user, ""))
+		require.Len(t, user.Phones, 3)
+		assert.True(t, internal.IsEncrypted(user.Phones[0]))
+		assert.Empty(t, user.Phones[1])
+		assert.True(t, internal.IsEncrypted(user.Phones[2]))
+		assert.Equal(t, []string{"vip"}, user.Tags)
+
+		require.NoError(t, g.DecryptRecursive(user))
+		assert.Equal(t, []string{"+62811", "", "+62812"}, user.Phones)
+	})
+
+	t.Run("whole arrays are one padded value", func(t *testing.T) {
+		short := &arrayUser{Emails: []string{"a@example.com"}}
+		long := &arrayUser{Emails: []string{"a@example.com", "b@example.com"}}
+		require.NoError(t, g.EncryptModel(short, ""))
+		require.NoError(t, g.EncryptModel(long, ""))
+		require.Len(t, short.Emails, 1)
+		require.Len(t, long.Emails, 1)
+		assert.Equal(t, len(short.Emails[0]), len(long.Emails[0]))
+
+		require.NoError(t, g.DecryptRecursive(long))
+		assert.Equal(t, []string{"a@example.com", "b@example.com"}, long.Emails)
+	})
+
+	t.Run("empty arrays are left as is", func(t *testing.T) {
+		user := &arrayUser{Emails: []string{}}
+		require.NoError(t, g.EncryptModel(user, ""))
+		assert.Nil(t, user.Phones)
+		assert.Empty(t, user.Emails)
+	})
+
+	t.Run("plaintext elements are kept", func(t *testing.T) {
+		encrypted, err := g.Encrypt("+62811")
+		require.NoError(t, err)
+		user := &arrayUser{Phones: []string{encrypted, "+62812"}}
+		require.NoError(t, g.DecryptRecursive(user))
+		assert.Equal(t, []string{"+62811", "+62812"}, user.Phones)
+	})
+
+	t.Run("companion columns are rejected", func(t *testing.T) {
+		err := g.EncryptModel(&arrayIndexedUser{Phones: []string{"+62811"}}, "")
+		assert.ErrorContains(t, err, "not supported on arrays")
+	})
+}
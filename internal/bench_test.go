@@ -0,0 +1,112 @@
+package internal_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/muhammadluth/govault/internal"
+)
+
+// Rows with five text columns, of which none, two or all five are encrypted
+type (
+	benchRow0 struct {
+		ID      int64
+		Name    string `bun:"name"`
+		Email   string `bun:"email"`
+		Phone   string `bun:"phone"`
+		Address string `bun:"address"`
+		Notes   string `bun:"notes"`
+	}
+	benchRow2 struct {
+		ID      int64
+		Name    string `bun:"name"`
+		Email   string `bun:"email" encrypted:"true"`
+		Phone   string `bun:"phone" encrypted:"true"`
+		Address string `bun:"address"`
+		Notes   string `bun:"notes"`
+	}
+	benchRow5 struct {
+		ID      int64
+		Name    string `bun:"name" encrypted:"true"`
+		Email   string `bun:"email" encrypted:"true"`
+		Phone   string `bun:"phone" encrypted:"true"`
+		Address string `bun:"address" encrypted:"true"`
+		Notes   string `bun:"notes" encrypted:"true"`
+	}
+)
+
+const benchRows = 1000
+
+func newBenchDB(b *testing.B) *internal.GovaultDB {
+	g, err := internal.New(internal.Config{
+		Keys:         map[string][]byte{"1": []byte("727d37a0-a5f2-4d67-af47-83039c8e")},
+		DefaultKeyID: "1",
+	})
+	if err != nil {
+		b.Fatal(err)
+	}
+	return g
+}
+
+// encryptedBenchRows returns benchRows rows of T passed through EncryptModel
+func encryptedBenchRows[T benchRow0 | benchRow2 | benchRow5](b *testing.B, g *internal.GovaultDB) []T {
+	rows := make([]T, benchRows)
+	for i := range rows {
+		row := any(&rows[i])
+		switch r := row.(type) {
+		case *benchRow0:
+			*r = benchRow0{int64(i), "Jane Doe", "jane@example.com", "+62811112222", "Jl. Sudirman 1", "none"}
+		case *benchRow2:
+			*r = benchRow2{int64(i), "Jane Doe", "jane@example.com", "+62811112222", "Jl. Sudirman 1", "none"}
+		case *benchRow5:
+			*r = benchRow5{int64(i), "Jane Doe", "jane@example.com", "+62811112222", "Jl. Sudirman 1", "none"}
+		}
+		if err := g.EncryptModel(row, ""); err != nil {
+			b.Fatal(err)
+		}
+	}
+	return rows
+}
+
+// BenchmarkDecryptRows measures the decryption govault adds to a select of
+// 1k rows, which is its whole per-query overhead on reads
+func BenchmarkDecryptRows(b *testing.B) {
+	g := newBenchDB(b)
+	b.Run("encrypted=0", benchDecrypt(g, encryptedBenchRows[benchRow0](b, g)))
+	b.Run("encrypted=2", benchDecrypt(g, encryptedBenchRows[benchRow2](b, g)))
+	b.Run("encrypted=5", benchDecrypt(g, encryptedBenchRows[benchRow5](b, g)))
+}
+
+func benchDecrypt[T any](g *internal.GovaultDB, stored []T) func(b *testing.B) {
+	return func(b *testing.B) {
+		rows := make([]T, len(stored))
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			copy(rows, stored)
+			if err := g.DecryptRecursive(&rows); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+// BenchmarkEncryptRows measures the encryption govault adds to an insert
+// of 1k rows
+func BenchmarkEncryptRows(b *testing.B) {
+	g := newBenchDB(b)
+	for _, encrypted := range []int{0, 2, 5} {
+		b.Run(fmt.Sprintf("encrypted=%d", encrypted), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				switch encrypted {
+				case 0:
+					encryptedBenchRows[benchRow0](b, g)
+				case 2:
+					encryptedBenchRows[benchRow2](b, g)
+				case 5:
+					encryptedBenchRows[benchRow5](b, g)
+				}
+			}
+		})
+	}
+}
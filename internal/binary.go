@@ -0,0 +1,129 @@
+package internal
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"strings"
+)
+
+// binaryFormatMagic starts the values stored by encrypted fields with the
+// storage:"binary" tag. The NUL byte keeps it apart from text values.
+const binaryFormatMagic = "\x00gv"
+
+// binaryFormatVersion is the version byte following binaryFormatMagic:
+// the gv1 format with binary nonce and ciphertext
+const binaryFormatVersion = 1
+
+// binaryFlagPadded marks values whose plaintext is padded, the |p of gv1
+const binaryFlagPadded = 1
+
+// binaryFlagXChaCha marks XChaCha20-Poly1305 values, the |x of gv1
+const binaryFlagXChaCha = 2
+
+// binaryFlagEncryptedAt marks values with an encryption time, the |t of
+// gv1, stored as 8 big-endian bytes of Unix seconds after the key ID
+const binaryFlagEncryptedAt = 4
+
+// EncodeBinary converts a ciphertext for a binary column, e.g. BYTEA or
+// BLOB: magic, version, flags, key ID length, key ID, nonce and ciphertext.
+// It is about a third smaller than the base64 of the text format. Values
+// in other formats, and plaintext, are stored as their text.
+func EncodeBinary(value string) ([]byte, error) {
+	if !strings.HasPrefix(value, FormatPrefix) {
+		return []byte(value), nil
+	}
+	env, err := parseEnvelope(value)
+	if err != nil {
+		return nil, err
+	}
+	if len(env.keyID) > 255 {
+		return nil, &FormatError{Part: "key ID", Reason: "exceeds 255 bytes"}
+	}
+
+	var flags byte
+	if env.padded {
+		flags |= binaryFlagPadded
+	}
+	if env.algorithm.normalize() == AlgorithmXChaCha20Poly1305 {
+		flags |= binaryFlagXChaCha
+	}
+	if env.encryptedAt != 0 {
+		flags |= binaryFlagEncryptedAt
+	}
+	b := make([]byte, 0, len(binaryFormatMagic)+11+len(env.keyID)+len(env.nonce)+len(env.ciphertext))
+	b = append(b, binaryFormatMagic...)
+	b = append(b, binaryFormatVersion, flags, byte(len(env.keyID)))
+	b = append(b, env.keyID...)
+	if env.encryptedAt != 0 {
+		b = binary.BigEndian.AppendUint64(b, uint64(env.encryptedAt))
+	}
+	b = append(b, env.nonce...)
+	return append(b, env.ciphertext...), nil
+}
+
+// BinaryKeyPrefix returns the parts of the binary encoding shared by the
+// ciphertexts of keyID, to match them in SQL: every value starts with
+// header, followed by its flags byte and then key, the key ID with its
+// length.
+func BinaryKeyPrefix(keyID string) (header, key []byte) {
+	header = append([]byte(binaryFormatMagic), binaryFormatVersion)
+	key = append([]byte{byte(len(keyID))}, keyID...)
+	return header, key
+}
+
+// DecodeBinary converts a value read from a binary column back to the text
+// format, the inverse of EncodeBinary. Values without the binary magic are
+// returned as text.
+func DecodeBinary(data []byte) (string, error) {
+	if !bytes.HasPrefix(data, []byte(binaryFormatMagic)) {
+		return string(data), nil
+	}
+	if len(data) > MaxCiphertextLength {
+		return "", &FormatError{Part: "value", Reason: fmt.Sprintf("exceeds %d bytes", MaxCiphertextLength)}
+	}
+	data = data[len(binaryFormatMagic):]
+	if len(data) < 3 {
+		return "", &FormatError{Part: "value", Reason: "is truncated"}
+	}
+	version, flags, keyIDLength := data[0], data[1], int(data[2])
+	if version != binaryFormatVersion {
+		return "", &FormatError{Part: "version", Reason: fmt.Sprintf("%d is unknown", version)}
+	}
+	if flags&^(binaryFlagPadded|binaryFlagXChaCha|binaryFlagEncryptedAt) != 0 {
+		return "", &FormatError{Part: "flag", Reason: fmt.Sprintf("%#x is unknown", flags)}
+	}
+	algorithm := AlgorithmAESGCM
+	if flags&binaryFlagXChaCha != 0 {
+		algorithm = AlgorithmXChaCha20Poly1305
+	}
+	nonceSize := algorithm.nonceSize()
+	data = data[3:]
+	if len(data) < keyIDLength {
+		return "", &FormatError{Part: "value", Reason: "is truncated"}
+	}
+	env := &envelope{
+		keyID:     string(data[:keyIDLength]),
+		padded:    flags&binaryFlagPadded != 0,
+		algorithm: algorithm,
+	}
+	data = data[keyIDLength:]
+	if flags&binaryFlagEncryptedAt != 0 {
+		if len(data) < 8 {
+			return "", &FormatError{Part: "value", Reason: "is truncated"}
+		}
+		env.encryptedAt = int64(binary.BigEndian.Uint64(data))
+		if env.encryptedAt <= 0 {
+			return "", &FormatError{Part: "flag", Reason: "has an invalid encryption time"}
+		}
+		data = data[8:]
+	}
+	if len(data) < nonceSize+gcmTagSize {
+		return "", &FormatError{Part: "value", Reason: "is truncated"}
+	}
+	env.nonce, env.ciphertext = data[:nonceSize], data[nonceSize:]
+	if err := checkKeyID(env.keyID); err != nil {
+		return "", err
+	}
+	return env.String(), nil
+}
@@ -0,0 +1,90 @@
+package internal_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/muhammadluth/govault/internal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBinaryFormat(t *testing.T) {
+	g, err := internal.New(internal.Config{
+		Keys:                map[string][]byte{"1": []byte("727d37a0-a5f2-4d67-af47-83039c8e")},
+		DefaultKeyID:        "1",
+		EncryptEmptyStrings: true,
+	})
+	require.NoError(t, err)
+
+	t.Run("round trips and is smaller than the text", func(t *testing.T) {
+		encrypted, err := g.Encrypt(strings.Repeat("john@example.com ", 8))
+		require.NoError(t, err)
+
+		data, err := internal.EncodeBinary(encrypted)
+		require.NoError(t, err)
+		assert.True(t, strings.HasPrefix(string(data), "\x00gv\x01"), "magic and format version")
+		assert.Less(t, len(data)*4, len(encrypted)*3+16)
+
+		decoded, err := internal.DecodeBinary(data)
+		require.NoError(t, err)
+		assert.Equal(t, encrypted, decoded)
+	})
+
+	t.Run("key prefix matches the encoding", func(t *testing.T) {
+		encrypted, err := g.Encrypt("john@example.com")
+		require.NoError(t, err)
+		data, err := internal.EncodeBinary(encrypted)
+		require.NoError(t, err)
+
+		header, key := internal.BinaryKeyPrefix("1")
+		assert.Equal(t, header, data[:len(header)])
+		assert.Equal(t, key, data[len(header)+1:len(header)+1+len(key)])
+	})
+
+	t.Run("padding is recorded in the flags", func(t *testing.T) {
+		encrypted, err := g.Encrypt("")
+		require.NoError(t, err)
+		require.True(t, strings.HasSuffix(encrypted, "|p"))
+
+		data, err := internal.EncodeBinary(encrypted)
+		require.NoError(t, err)
+		decoded, err := internal.DecodeBinary(data)
+		require.NoError(t, err)
+		assert.Equal(t, encrypted, decoded)
+		plaintext, err := g.Decrypt(decoded)
+		require.NoError(t, err)
+		assert.Empty(t, plaintext)
+	})
+
+	t.Run("other values are stored as text", func(t *testing.T) {
+		for _, value := range []string{"", "plain", "legacy:abc"} {
+			data, err := internal.EncodeBinary(value)
+			require.NoError(t, err)
+			assert.Equal(t, value, string(data))
+			decoded, err := internal.DecodeBinary(data)
+			require.NoError(t, err)
+			assert.Equal(t, value, decoded)
+		}
+	})
+
+	t.Run("malformed values fail", func(t *testing.T) {
+		encrypted, err := g.Encrypt("john@example.com")
+		require.NoError(t, err)
+		data, err := internal.EncodeBinary(encrypted)
+		require.NoError(t, err)
+
+		unknownVersion := append([]byte(nil), data...)
+		unknownVersion[3] = 9
+		truncated := data[:10]
+		for name, value := range map[string][]byte{"version": unknownVersion, "truncated": truncated, "empty": []byte("\x00gv")} {
+			_, err := internal.DecodeBinary(value)
+			var formatErr *internal.FormatError
+			assert.True(t, errors.As(err, &formatErr), name)
+		}
+
+		_, err = internal.EncodeBinary(internal.FormatPrefix + "not base64")
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,130 @@
+package internal
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// bloomDomain separates bloom filter keys from blind index keys
+const bloomDomain = "govault bloom filter"
+
+// Bloom filter defaults used when the tags are not set
+const (
+	defaultBloomItems     = 16
+	defaultBloomFPR       = 0.01
+	defaultBloomSeparator = ","
+)
+
+// parseBloom reads the bloomitems, bloomfpr and bloomsep tags
+func parseBloom(field *FieldSpec, tag reflect.StructTag) {
+	field.BloomItems = defaultBloomItems
+	if n, err := strconv.Atoi(tag.Get("bloomitems")); err == nil && n > 0 {
+		field.BloomItems = n
+	}
+	field.BloomFPR = defaultBloomFPR
+	if p, err := strconv.ParseFloat(tag.Get("bloomfpr"), 64); err == nil && p > 0 && p < 1 {
+		field.BloomFPR = p
+	}
+	field.BloomSeparator = defaultBloomSeparator
+	if sep := tag.Get("bloomsep"); sep != "" {
+		field.BloomSeparator = sep
+	}
+}
+
+// BloomSize returns the number of bits and of hash functions of the bloom
+// filter of field, sized for BloomItems members at BloomFPR
+func (f *FieldSpec) BloomSize() (bits, hashes int) {
+	n := float64(f.BloomItems)
+	m := math.Ceil(-n * math.Log(f.BloomFPR) / (math.Ln2 * math.Ln2))
+	k := math.Round(m / n * math.Ln2)
+	return int(m), int(math.Max(k, 1))
+}
+
+// BloomMembers splits plaintext into the members of a bloom field:
+// trimmed, non-empty values between separators
+func BloomMembers(field *FieldSpec, plaintext string) []string {
+	var members []string
+	for _, member := range strings.Split(plaintext, field.BloomSeparator) {
+		if member = strings.TrimSpace(member); member != "" {
+			members = append(members, member)
+		}
+	}
+	return members
+}
+
+// BloomFilter computes the bloom filter of the members of plaintext stored
+// in the column named by the bloom tag: a string of '0' and '1' with one
+// character per bit, set at positions derived from an HMAC of each member
+// keyed by a subkey of the blind index key.
+//
+// Membership queries may return false positives, at about BloomFPR up to
+// BloomItems members and more beyond. The filter reveals the approximate
+// number of members of a value, and values sharing members share set bits.
+func (g *GovaultDB) BloomFilter(field *FieldSpec, table, plaintext string) (string, error) {
+	bits, _ := field.BloomSize()
+	filter := []byte(strings.Repeat("0", bits))
+	for _, member := range BloomMembers(field, plaintext) {
+		positions, err := g.bloomPositions(field, table, member)
+		if err != nil {
+			return "", err
+		}
+		for _, pos := range positions {
+			filter[pos] = '1'
+		}
+	}
+	return string(filter), nil
+}
+
+// BloomPattern returns the LIKE pattern matching the bloom filters that may
+// contain member: '1' at the bits of member and '_' elsewhere
+func (g *GovaultDB) BloomPattern(field *FieldSpec, table, member string) (string, error) {
+	bits, _ := field.BloomSize()
+	pattern := []byte(strings.Repeat("_", bits))
+	positions, err := g.bloomPositions(field, table, strings.TrimSpace(member))
+	if err != nil {
+		return "", err
+	}
+	for _, pos := range positions {
+		pattern[pos] = '1'
+	}
+	return string(pattern), nil
+}
+
+// bloomPositions derives the bits of member by double hashing its HMAC
+func (g *GovaultDB) bloomPositions(field *FieldSpec, table, member string) ([]int, error) {
+	subKey, err := g.blindIndexSubKey(bloomDomain, table, field.Column)
+	if err != nil {
+		return nil, err
+	}
+	mac := hmac.New(sha256.New, subKey)
+	mac.Write([]byte(member))
+	sum := mac.Sum(nil)
+
+	bits, hashes := field.BloomSize()
+	h1 := binary.BigEndian.Uint64(sum[0:8])
+	h2 := binary.BigEndian.Uint64(sum[8:16]) | 1
+	positions := make([]int, hashes)
+	for i := range positions {
+		positions[i] = int((h1 + uint64(i)*h2) % uint64(bits))
+	}
+	return positions, nil
+}
+
+// setBloom writes the bloom filter of plaintext into the companion column
+func (g *GovaultDB) setBloom(val reflect.Value, spec *ModelSpec, field *FieldSpec, plaintext string) error {
+	target, err := companionField(val, spec, field.Bloom, "bloom filter")
+	if err != nil {
+		return err
+	}
+	filter, err := g.BloomFilter(field, spec.Table, plaintext)
+	if err != nil {
+		return err
+	}
+	target.SetString(filter)
+	return nil
+}
@@ -0,0 +1,72 @@
+package internal_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/muhammadluth/govault/internal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// bloomLike reports whether filter matches a LIKE pattern of '1' and '_'
+func bloomLike(filter, pattern string) bool {
+	if len(filter) != len(pattern) {
+		return false
+	}
+	for i := range pattern {
+		if pattern[i] == '1' && filter[i] != '1' {
+			return false
+		}
+	}
+	return true
+}
+
+func TestBloomFilter(t *testing.T) {
+	type article struct {
+		Tags      string `bun:"tags" encrypted:"true" bloom:"tags_bloom"`
+		TagsBloom string `bun:"tags_bloom"`
+		Labels    string `bun:"labels" encrypted:"true" bloom:"labels_bloom" bloomitems:"100" bloomfpr:"0.001" bloomsep:";"`
+	}
+
+	g, err := internal.New(internal.Config{
+		Keys:         map[string][]byte{"1": reloadKey1},
+		DefaultKeyID: "1",
+	})
+	require.NoError(t, err)
+	spec := internal.GetModelSpec((*article)(nil))
+
+	tags := spec.Field("Tags")
+	bits, hashes := tags.BloomSize()
+	assert.Equal(t, 154, bits)
+	assert.Equal(t, 7, hashes)
+	bits, hashes = spec.Field("Labels").BloomSize()
+	assert.Equal(t, 1438, bits)
+	assert.Equal(t, 10, hashes)
+	assert.Equal(t, []string{"a b", "c"}, internal.BloomMembers(spec.Field("Labels"), " a b ;;c; "))
+
+	a := &article{Tags: "go, crypto ,databases"}
+	require.NoError(t, g.EncryptModel(a, ""))
+	require.Len(t, a.TagsBloom, 154)
+	assert.Equal(t, "", strings.Trim(a.TagsBloom, "01"))
+
+	for _, member := range []string{"go", "crypto", "databases", " go "} {
+		pattern, err := g.BloomPattern(tags, spec.Table, member)
+		require.NoError(t, err)
+		assert.True(t, bloomLike(a.TagsBloom, pattern), member)
+	}
+
+	// False positives stay near the configured rate
+	filter, err := g.BloomFilter(tags, spec.Table, "t1,t2,t3,t4,t5,t6,t7,t8,t9,t10,t11,t12,t13,t14,t15,t16")
+	require.NoError(t, err)
+	falsePositives := 0
+	for i := 0; i < 2000; i++ {
+		pattern, err := g.BloomPattern(tags, spec.Table, fmt.Sprintf("other%d", i))
+		require.NoError(t, err)
+		if bloomLike(filter, pattern) {
+			falsePositives++
+		}
+	}
+	assert.Less(t, falsePositives, 60, "about 20 of 2000 expected")
+}
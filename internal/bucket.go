@@ -0,0 +1,133 @@
+package internal
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// bucketDomain separates bucket keys from blind index keys
+const bucketDomain = "govault numeric bucket"
+
+// parseBucketBounds parses comma separated ascending bounds. Any invalid
+// or unordered bound disables the bucket.
+func parseBucketBounds(tag string) []float64 {
+	if tag == "" {
+		return nil
+	}
+	var bounds []float64
+	for _, bound := range strings.Split(tag, ",") {
+		n, err := strconv.ParseFloat(strings.TrimSpace(bound), 64)
+		if err != nil {
+			return nil
+		}
+		bounds = append(bounds, n)
+	}
+	if !ascending(bounds) {
+		return nil
+	}
+	return bounds
+}
+
+// ascending reports whether bounds is not empty and strictly ascending
+func ascending(bounds []float64) bool {
+	for i := 1; i < len(bounds); i++ {
+		if bounds[i] <= bounds[i-1] {
+			return false
+		}
+	}
+	return len(bounds) > 0
+}
+
+// BucketID returns the keyed ID of the bucket of value for field, bound
+// to the table and column.
+//
+// Buckets give numeric encrypted fields coarse range filters. The bounds
+// of the bucket tag split the numbers into ranges, and the ID of the range
+// of the plaintext is stored in an integer column:
+//
+//	Salary       string `bun:"salary" encrypted:"true" bucket:"salary_bucket" bucketbounds:"30000,60000,100000"`
+//	SalaryBucket int64  `bun:"salary_bucket"`
+//
+// Here salaries below 30000 share a bucket, then 30000 up to 60000, and
+// so on. IDs grow with the bounds by keyed random steps, so range filters
+// compare IDs, see BucketRange, while the IDs do not tell the bounds.
+//
+// Leakage: anyone reading the table learns which rows share a bucket and
+// the order of the buckets, i.e. the distribution of the values over the
+// bounds; with the bounds, known or guessed, the range of each value. The
+// values within a bucket and their order stay hidden. Use few wide
+// buckets, and none on fields whose rough magnitude is itself sensitive.
+func (g *GovaultDB) BucketID(field *FieldSpec, table string, value float64) (int64, error) {
+	if field.Bucket == "" {
+		return 0, fmt.Errorf("field %s has no bucket", field.Name)
+	}
+	subKey, err := g.blindIndexSubKey(bucketDomain, table, field.Column)
+	if err != nil {
+		return 0, err
+	}
+
+	// The ID of bucket i sums i+1 keyed steps of 1 to 65536
+	bucket := sort.Search(len(field.BucketBounds), func(i int) bool { return field.BucketBounds[i] > value })
+	var id int64
+	var index [8]byte
+	for i := 0; i <= bucket; i++ {
+		binary.BigEndian.PutUint64(index[:], uint64(i))
+		mac := hmac.New(sha256.New, subKey)
+		mac.Write(index[:])
+		id += 1 + int64(binary.BigEndian.Uint16(mac.Sum(nil)))
+	}
+	return id, nil
+}
+
+// BucketRange returns the IDs of the buckets of low and high, the bounds
+// of a filter on the bucket column matching every value between them.
+// The filter is coarse: values of the same buckets outside of the range
+// match too.
+func (g *GovaultDB) BucketRange(field *FieldSpec, table string, low, high float64) (int64, int64, error) {
+	if high < low {
+		return 0, 0, fmt.Errorf("range of field %s ends before it starts", field.Name)
+	}
+	lowID, err := g.BucketID(field, table, low)
+	if err != nil {
+		return 0, 0, err
+	}
+	highID, err := g.BucketID(field, table, high)
+	if err != nil {
+		return 0, 0, err
+	}
+	return lowID, highID, nil
+}
+
+// setBucket writes the bucket ID of plaintext, a number, into the
+// companion column. Empty values store zero.
+func (g *GovaultDB) setBucket(val reflect.Value, spec *ModelSpec, field *FieldSpec, plaintext string) error {
+	index, ok := spec.ColumnIndex(field.Bucket)
+	if !ok {
+		return fmt.Errorf("bucket column '%s' not found in model", field.Bucket)
+	}
+	target := val.FieldByIndex(index)
+	if !target.CanSet() || !target.CanInt() {
+		return fmt.Errorf("bucket column '%s' must be a settable integer field", field.Bucket)
+	}
+	if plaintext == "" {
+		target.SetInt(0)
+		return nil
+	}
+
+	value, err := strconv.ParseFloat(strings.TrimSpace(plaintext), 64)
+	if err != nil {
+		return fmt.Errorf("value of a bucketed field is not a number")
+	}
+	id, err := g.BucketID(field, spec.Table, value)
+	if err != nil {
+		return err
+	}
+	target.SetInt(id)
+	return nil
+}
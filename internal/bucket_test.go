@@ -0,0 +1,73 @@
+package internal_test
+
+import (
+	"testing"
+
+	"github.com/muhammadluth/govault/internal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type bucketEmployee struct {
+	ID           int64  `bun:"id,pk"`
+	Salary       string `bun:"salary" encrypted:"true" bucket:"salary_bucket" bucketbounds:"30000, 60000,100000"`
+	SalaryBucket int64  `bun:"salary_bucket"`
+	Bonus        string `bun:"bonus" encrypted:"true" bucket:"bonus_bucket" bucketbounds:"100,50"`
+	BonusBucket  int64  `bun:"bonus_bucket"`
+}
+
+type bucketContractor struct {
+	Rate       string `bun:"rate" encrypted:"true" bucket:"rate_bucket" bucketbounds:"30000,60000,100000"`
+	RateBucket int64  `bun:"rate_bucket"`
+}
+
+func TestBuckets(t *testing.T) {
+	g, err := internal.New(internal.Config{
+		Keys:         map[string][]byte{"1": []byte("727d37a0-a5f2-4d67-af47-83039c8e")},
+		DefaultKeyID: "1",
+	})
+	require.NoError(t, err)
+
+	spec := internal.GetModelSpec(&bucketEmployee{})
+	assert.Equal(t, []float64{30000, 60000, 100000}, spec.Field("Salary").BucketBounds)
+	assert.Empty(t, spec.Field("Bonus").Bucket, "unordered bounds disable the bucket")
+
+	bucketOf := func(salary string) int64 {
+		e := &bucketEmployee{Salary: salary}
+		require.NoError(t, g.EncryptModel(e, ""))
+		return e.SalaryBucket
+	}
+
+	t.Run("values of a bucket share its ID", func(t *testing.T) {
+		assert.Equal(t, bucketOf("30000"), bucketOf("59999.5"))
+		assert.NotEqual(t, bucketOf("29999"), bucketOf("30000"))
+	})
+
+	t.Run("IDs follow the order of the buckets", func(t *testing.T) {
+		ids := []int64{bucketOf("-5"), bucketOf("45000"), bucketOf("75000"), bucketOf("1e9")}
+		for i := 1; i < len(ids); i++ {
+			assert.Greater(t, ids[i], ids[i-1])
+		}
+	})
+
+	t.Run("IDs are keyed per column", func(t *testing.T) {
+		c := &bucketContractor{Rate: "45000"}
+		require.NoError(t, g.EncryptModel(c, ""))
+		assert.NotEqual(t, bucketOf("45000"), c.RateBucket)
+	})
+
+	t.Run("ranges cover the buckets of their ends", func(t *testing.T) {
+		low, high, err := g.BucketRange(spec.Field("Salary"), spec.Table, 40000, 70000)
+		require.NoError(t, err)
+		assert.Equal(t, bucketOf("30000"), low)
+		assert.Equal(t, bucketOf("99999"), high)
+
+		_, _, err = g.BucketRange(spec.Field("Salary"), spec.Table, 2, 1)
+		assert.Error(t, err)
+	})
+
+	t.Run("non numbers are rejected", func(t *testing.T) {
+		err := g.EncryptModel(&bucketEmployee{Salary: "a lot"}, "")
+		assert.ErrorContains(t, err, "not a number")
+	})
+}
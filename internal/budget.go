@@ -0,0 +1,79 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+)
+
+// ErrDecryptBudgetExceeded is matched by the errors of reads exceeding the
+// budget set with WithDecryptBudget
+var ErrDecryptBudgetExceeded = errors.New("decrypt budget exceeded")
+
+// DecryptBudget caps the decryptions of a request, e.g. a list endpoint
+// accidentally selecting every row, see WithDecryptBudget. Zero limits are
+// unlimited.
+type DecryptBudget struct {
+	// MaxFields is the number of field values that may be decrypted
+	MaxFields int
+	// MaxBytes is the total ciphertext size that may be decrypted
+	MaxBytes int64
+	// Mask returns FallbackMaskValue for the fields over the budget instead
+	// of failing the read
+	Mask bool
+}
+
+// decryptBudget counts the decryptions of a context. It is shared by the
+// queries of a request, possibly concurrent.
+type decryptBudget struct {
+	DecryptBudget
+	fields atomic.Int64
+	bytes  atomic.Int64
+}
+
+type decryptBudgetKey struct{}
+
+// WithDecryptBudget returns a context whose reads through the wrapper
+// queries decrypt at most the fields and bytes of budget in total. Values
+// over the budget fail the read with ErrDecryptBudgetExceeded, or are
+// masked with budget.Mask. Plain bun queries decrypting through
+// RegisterEncryptedModels are not counted.
+func WithDecryptBudget(ctx context.Context, budget DecryptBudget) context.Context {
+	return context.WithValue(ctx, decryptBudgetKey{}, &decryptBudget{DecryptBudget: budget})
+}
+
+// DecryptBudgetSpent returns the fields and bytes decrypted so far under the
+// budget of ctx, including those over it, e.g. for request logs
+func DecryptBudgetSpent(ctx context.Context) (fields int, bytes int64) {
+	budget := decryptBudgetOf(ctx)
+	if budget == nil {
+		return 0, 0
+	}
+	return int(budget.fields.Load()), budget.bytes.Load()
+}
+
+func decryptBudgetOf(ctx context.Context) *decryptBudget {
+	if ctx == nil {
+		return nil
+	}
+	budget, _ := ctx.Value(decryptBudgetKey{}).(*decryptBudget)
+	return budget
+}
+
+// spend charges the decryption of a ciphertext of size bytes, failing once
+// the budget is exceeded
+func (b *decryptBudget) spend(size int) error {
+	if b == nil {
+		return nil
+	}
+	fields := b.fields.Add(1)
+	bytes := b.bytes.Add(int64(size))
+	if b.MaxFields > 0 && fields > int64(b.MaxFields) {
+		return fmt.Errorf("%w: more than %d fields", ErrDecryptBudgetExceeded, b.MaxFields)
+	}
+	if b.MaxBytes > 0 && bytes > b.MaxBytes {
+		return fmt.Errorf("%w: more than %d bytes", ErrDecryptBudgetExceeded, b.MaxBytes)
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package internal_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/muhammadluth/govault/internal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecryptBudget(t *testing.T) {
+	g := newTransformDB(t)
+	rows := func() []transformPatient {
+		return []transformPatient{
+			encryptedPatient(t, g, "ada@example.com"),
+			encryptedPatient(t, g, "grace@example.com"),
+		}
+	}
+
+	t.Run("reads within the budget decrypt", func(t *testing.T) {
+		ctx := internal.WithDecryptBudget(context.Background(), internal.DecryptBudget{MaxFields: 10})
+		patients := rows()
+		require.NoError(t, g.DecryptRecursiveContext(ctx, &patients))
+		assert.Equal(t, "grace@example.com", patients[1].Email)
+
+		fields, bytes := internal.DecryptBudgetSpent(ctx)
+		assert.Equal(t, 10, fields)
+		assert.Greater(t, bytes, int64(0))
+	})
+
+	t.Run("reads over the field budget fail", func(t *testing.T) {
+		ctx := internal.WithDecryptBudget(context.Background(), internal.DecryptBudget{MaxFields: 7})
+		patients := rows()
+		err := g.DecryptRecursiveContext(ctx, &patients)
+		assert.ErrorIs(t, err, internal.ErrDecryptBudgetExceeded)
+	})
+
+	t.Run("the budget is shared by the reads of a context", func(t *testing.T) {
+		stored := rows()
+		ctx := internal.WithDecryptBudget(context.Background(), internal.DecryptBudget{MaxBytes: int64(len(stored[0].Email)) * 5})
+		first := stored[0]
+		require.NoError(t, g.DecryptRecursiveContext(ctx, &first))
+		second := stored[1]
+		assert.ErrorIs(t, g.DecryptRecursiveContext(ctx, &second), internal.ErrDecryptBudgetExceeded)
+	})
+
+	t.Run("fields over the budget are masked", func(t *testing.T) {
+		ctx := internal.WithDecryptBudget(context.Background(), internal.DecryptBudget{MaxFields: 5, Mask: true})
+		patients := rows()
+		require.NoError(t, g.DecryptRecursiveContext(ctx, &patients))
+		assert.Equal(t, "ada@example.com", patients[0].Email)
+		assert.Equal(t, internal.FallbackMaskValue, patients[1].Email)
+		assert.Equal(t, internal.FallbackMaskValue, patients[1].Name)
+	})
+
+	t.Run("contexts without a budget are unlimited", func(t *testing.T) {
+		patients := rows()
+		require.NoError(t, g.DecryptRecursiveContext(context.Background(), &patients))
+		fields, _ := internal.DecryptBudgetSpent(context.Background())
+		assert.Zero(t, fields)
+	})
+}
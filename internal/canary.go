@@ -0,0 +1,226 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// CanaryPrefix starts generated canary plaintexts
+const CanaryPrefix = "gvcanary-"
+
+// CanaryAlertTimeout bounds the alerts of a CanaryDetector writer and the
+// requests of CanaryWebhook
+const CanaryAlertTimeout = 10 * time.Second
+
+// Canary is a known plaintext planted in an encrypted column. Seeing it
+// anywhere outside the database means decrypted data leaked.
+type Canary struct {
+	ID        string
+	Table     string
+	Column    string
+	Plaintext string
+	CreatedAt time.Time
+}
+
+// CanaryHit is a canary found in data passed to a CanaryDetector
+type CanaryHit struct {
+	Canary Canary
+	// Source names where the data came from, e.g. a log stream or export
+	Source string
+	At     time.Time
+}
+
+// CanaryAlert is called for every canary hit
+type CanaryAlert func(ctx context.Context, hit CanaryHit) error
+
+// NewCanaryValue returns a random plaintext suitable as a canary
+func NewCanaryValue() (string, error) {
+	b := make([]byte, 12)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate canary: %w", err)
+	}
+	return CanaryPrefix + hex.EncodeToString(b), nil
+}
+
+// CanaryDetector searches data for canary plaintexts and raises alerts
+type CanaryDetector struct {
+	mu       sync.RWMutex
+	canaries []Canary
+	alerts   []CanaryAlert
+	maxLen   int
+}
+
+// NewCanaryDetector returns a detector for canaries
+func NewCanaryDetector(canaries ...Canary) *CanaryDetector {
+	d := &CanaryDetector{}
+	d.Add(canaries...)
+	return d
+}
+
+// Add registers more canaries
+func (d *CanaryDetector) Add(canaries ...Canary) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, c := range canaries {
+		if c.Plaintext == "" {
+			continue
+		}
+		d.canaries = append(d.canaries, c)
+		if len(c.Plaintext) > d.maxLen {
+			d.maxLen = len(c.Plaintext)
+		}
+	}
+}
+
+// OnHit registers an alert called for every hit
+func (d *CanaryDetector) OnHit(alert CanaryAlert) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.alerts = append(d.alerts, alert)
+}
+
+// Check searches data for canaries, calls the alerts for every hit and
+// returns the hits together with the joined alert errors
+func (d *CanaryDetector) Check(ctx context.Context, source string, data []byte) ([]CanaryHit, error) {
+	hits := d.find(source, data, 0)
+	return hits, d.alert(ctx, hits)
+}
+
+// find returns the canaries found in data that end after offset
+func (d *CanaryDetector) find(source string, data []byte, offset int) []CanaryHit {
+	d.mu.RLock()
+	canaries := d.canaries
+	d.mu.RUnlock()
+
+	var hits []CanaryHit
+	for _, c := range canaries {
+		needle := []byte(c.Plaintext)
+		for start := 0; ; {
+			i := bytes.Index(data[start:], needle)
+			if i < 0 {
+				break
+			}
+			if start+i+len(needle) > offset {
+				hits = append(hits, CanaryHit{Canary: c, Source: source, At: time.Now()})
+				break
+			}
+			start += i + 1
+		}
+	}
+	return hits
+}
+
+// alert calls the alerts for every hit and joins their errors
+func (d *CanaryDetector) alert(ctx context.Context, hits []CanaryHit) error {
+	d.mu.RLock()
+	alerts := d.alerts
+	d.mu.RUnlock()
+
+	var errs []error
+	for _, hit := range hits {
+		for _, alert := range alerts {
+			if err := alert(ctx, hit); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Writer returns a writer that passes writes to w and checks them for
+// canaries, e.g. to wrap a log output or an export file. Canaries split
+// across writes are detected. Alerts run in the background, bounded by
+// CanaryAlertTimeout, so a slow alert never holds up a write; their errors
+// are logged to slog.Default(). Use Check to handle alert errors.
+func (d *CanaryDetector) Writer(ctx context.Context, source string, w io.Writer) io.Writer {
+	return &canaryWriter{ctx: ctx, source: source, w: w, d: d}
+}
+
+type canaryWriter struct {
+	mu     sync.Mutex
+	ctx    context.Context
+	source string
+	w      io.Writer
+	d      *CanaryDetector
+	tail   []byte
+}
+
+func (cw *canaryWriter) Write(p []byte) (int, error) {
+	cw.mu.Lock()
+	defer cw.mu.Unlock()
+
+	buf := append(cw.tail, p...)
+	if hits := cw.d.find(cw.source, buf, len(cw.tail)); len(hits) > 0 {
+		go cw.alert(hits)
+	}
+
+	cw.d.mu.RLock()
+	keep := cw.d.maxLen - 1
+	cw.d.mu.RUnlock()
+	if keep < 0 {
+		keep = 0
+	}
+	if len(buf) > keep {
+		buf = buf[len(buf)-keep:]
+	}
+	cw.tail = append([]byte(nil), buf...)
+
+	return cw.w.Write(p)
+}
+
+// alert calls the alerts for hits within CanaryAlertTimeout
+func (cw *canaryWriter) alert(hits []CanaryHit) {
+	ctx, cancel := context.WithTimeout(cw.ctx, CanaryAlertTimeout)
+	defer cancel()
+	if err := cw.d.alert(ctx, hits); err != nil {
+		slog.Default().Warn("govault: canary alert failed", "source", cw.source, "error", err)
+	}
+}
+
+// CanaryWebhook returns an alert posting every hit as JSON to url, within
+// CanaryAlertTimeout. A nil client uses http.DefaultClient.
+func CanaryWebhook(url string, client *http.Client) CanaryAlert {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return func(ctx context.Context, hit CanaryHit) error {
+		body, err := json.Marshal(map[string]any{
+			"canary_id": hit.Canary.ID,
+			"table":     hit.Canary.Table,
+			"column":    hit.Canary.Column,
+			"source":    hit.Source,
+			"at":        hit.At,
+		})
+		if err != nil {
+			return err
+		}
+
+		ctx, cancel := context.WithTimeout(ctx, CanaryAlertTimeout)
+		defer cancel()
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+		if err != nil {
+			return fmt.Errorf("failed to create canary webhook request: %w", err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return fmt.Errorf("failed to send canary webhook: %w", err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode >= 300 {
+			return fmt.Errorf("canary webhook returned %s", resp.Status)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,123 @@
+package internal_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/muhammadluth/govault/internal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCanaryDetector(t *testing.T) {
+	ctx := context.Background()
+	value, err := internal.NewCanaryValue()
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(value, internal.CanaryPrefix))
+
+	canary := internal.Canary{ID: "c1", Table: "users", Column: "email", Plaintext: value}
+	d := internal.NewCanaryDetector(canary, internal.Canary{ID: "c2", Plaintext: "honey@example.com"})
+
+	alerted := make(chan internal.CanaryHit, 10)
+	d.OnHit(func(_ context.Context, hit internal.CanaryHit) error {
+		alerted <- hit
+		return nil
+	})
+
+	t.Run("check", func(t *testing.T) {
+		hits, err := d.Check(ctx, "export.csv", []byte("id,email\n1,"+value+"\n2,"+value+"\n"))
+		require.NoError(t, err)
+		require.Len(t, hits, 1)
+		assert.Equal(t, "c1", hits[0].Canary.ID)
+		assert.Equal(t, "export.csv", hits[0].Source)
+		assert.Equal(t, "c1", (<-alerted).Canary.ID)
+
+		hits, err = d.Check(ctx, "export.csv", []byte("nothing to see"))
+		require.NoError(t, err)
+		assert.Empty(t, hits)
+	})
+
+	t.Run("writer detects canaries split across writes", func(t *testing.T) {
+		var out bytes.Buffer
+		w := d.Writer(ctx, "app.log", &out)
+
+		line := "user=" + value + " logged in\n"
+		for i := 0; i < len(line); i += 7 {
+			end := min(i+7, len(line))
+			_, err := w.Write([]byte(line[i:end]))
+			require.NoError(t, err)
+		}
+		assert.Equal(t, line, out.String())
+		hit := <-alerted
+		assert.Equal(t, "app.log", hit.Source)
+
+		// The tail of a previous write is not reported again
+		_, err := w.Write([]byte("next line\n"))
+		require.NoError(t, err)
+		assert.Empty(t, alerted, "hits are found during the write")
+	})
+
+	t.Run("writer doesn't wait for alerts", func(t *testing.T) {
+		d := internal.NewCanaryDetector(canary)
+		deadlines := make(chan bool, 1)
+		d.OnHit(func(ctx context.Context, _ internal.CanaryHit) error {
+			_, ok := ctx.Deadline()
+			<-ctx.Done()
+			deadlines <- ok
+			return ctx.Err()
+		})
+
+		ctx, cancel := context.WithCancel(ctx)
+		var out bytes.Buffer
+		_, err := d.Writer(ctx, "app.log", &out).Write([]byte(value))
+		require.NoError(t, err)
+		assert.Equal(t, value, out.String())
+
+		cancel()
+		assert.True(t, <-deadlines, "alerts are bounded by CanaryAlertTimeout")
+	})
+
+	t.Run("alert errors are returned", func(t *testing.T) {
+		d := internal.NewCanaryDetector(canary)
+		d.OnHit(func(context.Context, internal.CanaryHit) error { return errors.New("pager down") })
+		hits, err := d.Check(ctx, "x", []byte(value))
+		assert.Len(t, hits, 1)
+		assert.EqualError(t, err, "pager down")
+	})
+}
+
+func TestCanaryWebhook(t *testing.T) {
+	var received map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&received))
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	d := internal.NewCanaryDetector(internal.Canary{ID: "c1", Table: "users", Column: "email", Plaintext: "honey@example.com"})
+	d.OnHit(internal.CanaryWebhook(srv.URL, nil))
+
+	_, err := d.Check(context.Background(), "export", []byte("honey@example.com"))
+	require.NoError(t, err)
+	assert.Equal(t, "c1", received["canary_id"])
+	assert.Equal(t, "users", received["table"])
+	assert.Equal(t, "email", received["column"])
+	assert.NotContains(t, received, "plaintext")
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer failing.Close()
+
+	d = internal.NewCanaryDetector(internal.Canary{ID: "c1", Plaintext: "honey@example.com"})
+	d.OnHit(internal.CanaryWebhook(failing.URL, nil))
+	_, err = d.Check(context.Background(), "export", []byte("honey@example.com"))
+	assert.ErrorContains(t, err, "500")
+}
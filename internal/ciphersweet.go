@@ -0,0 +1,143 @@
+package internal
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strings"
+
+	"golang.org/x/crypto/blake2b"
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/hkdf"
+)
+
+// CodecCipherSweet reads and writes CipherSweet ModernCrypto ("nacl:")
+// ciphertexts and fast blind indexes
+const CodecCipherSweet = "ciphersweet"
+
+const cipherSweetPrefix = "nacl:"
+
+// Domain separation constants used by CipherSweet's key provider
+var (
+	cipherSweetFieldDomain = strings.Repeat("\xB4", 32)
+	cipherSweetIndexDomain = strings.Repeat("\x7E", 32)
+)
+
+type cipherSweetCodec struct{}
+
+func (cipherSweetCodec) IsEncrypted(value string) bool {
+	return strings.HasPrefix(value, cipherSweetPrefix)
+}
+
+func (c cipherSweetCodec) Encrypt(g *GovaultDB, field *FieldSpec, table, plaintext, _ string) (string, error) {
+	key, err := g.cipherSweetKey(table, cipherSweetFieldDomain+field.Column)
+	if err != nil {
+		return "", err
+	}
+
+	aead, err := chacha20poly1305.NewX(key)
+	if err != nil {
+		return "", fmt.Errorf("failed to create cipher: %w", err)
+	}
+
+	nonce, err := g.newNonce(aead.NonceSize())
+	if err != nil {
+		return "", err
+	}
+
+	sealed := aead.Seal(nonce, nonce, []byte(plaintext), nonce)
+	if err := g.RecordNonce(cipherSweetPrefix+table+"."+field.Column, nonce, sealed[len(nonce):]); err != nil {
+		return "", err
+	}
+	return cipherSweetPrefix + base64.URLEncoding.EncodeToString(sealed), nil
+}
+
+func (c cipherSweetCodec) Decrypt(g *GovaultDB, field *FieldSpec, table, ciphertext string) (string, error) {
+	if !c.IsEncrypted(ciphertext) {
+		return "", &FormatError{Part: "value", Reason: "has no " + cipherSweetPrefix + " prefix"}
+	}
+
+	data, err := base64.URLEncoding.DecodeString(strings.TrimPrefix(ciphertext, cipherSweetPrefix))
+	if err != nil {
+		return "", &FormatError{Part: "ciphertext", Reason: "is not valid base64"}
+	}
+	if len(data) < chacha20poly1305.NonceSizeX+chacha20poly1305.Overhead {
+		return "", &FormatError{Part: "ciphertext", Reason: "is too short"}
+	}
+
+	key, err := g.cipherSweetKey(table, cipherSweetFieldDomain+field.Column)
+	if err != nil {
+		return "", err
+	}
+
+	aead, err := chacha20poly1305.NewX(key)
+	if err != nil {
+		return "", fmt.Errorf("failed to create cipher: %w", err)
+	}
+
+	nonce := data[:chacha20poly1305.NonceSizeX]
+	plaintext, err := aead.Open(nil, nonce, data[chacha20poly1305.NonceSizeX:], nonce)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt: %w", err)
+	}
+	return string(plaintext), nil
+}
+
+func (c cipherSweetCodec) BlindIndex(g *GovaultDB, field *FieldSpec, table, plaintext string) (string, error) {
+	rootKey, err := g.cipherSweetKey(table, cipherSweetIndexDomain+field.Column)
+	if err != nil {
+		return "", err
+	}
+
+	mac := hmac.New(sha256.New, rootKey)
+	mac.Write(cipherSweetPack(table, field.Column, field.BlindIndex))
+	indexKey := mac.Sum(nil)
+
+	// BLAKE2b output is never shorter than 128 bits before masking
+	size := (field.BlindIndexBits + 7) / 8
+	if size < 16 {
+		size = 16
+	}
+	hash, err := blake2b.New(size, indexKey)
+	if err != nil {
+		return "", fmt.Errorf("failed to create blind index hash: %w", err)
+	}
+	hash.Write([]byte(plaintext))
+	return hex.EncodeToString(truncateBits(hash.Sum(nil), field.BlindIndexBits)), nil
+}
+
+// KeyIDs returns the root key, from which every field key is derived
+func (cipherSweetCodec) KeyIDs(g *GovaultDB, _ string) []string {
+	return []string{g.cipherSweetKeyID}
+}
+
+// cipherSweetKey derives a per-field key from the CipherSweet root key
+// using HKDF-SHA384 with the table name as salt
+func (g *GovaultDB) cipherSweetKey(table, info string) ([]byte, error) {
+	root, err := g.lookupKey(g.cipherSweetKeyID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load ciphersweet key: %w", err)
+	}
+
+	key := make([]byte, 32)
+	if _, err := io.ReadFull(hkdf.New(sha512.New384, root.Value, []byte(table), []byte(info)), key); err != nil {
+		return nil, fmt.Errorf("failed to derive ciphersweet key: %w", err)
+	}
+	return key, nil
+}
+
+// cipherSweetPack mirrors CipherSweet's Util::pack: a little-endian piece
+// count followed by each piece prefixed with its 64-bit length
+func cipherSweetPack(pieces ...string) []byte {
+	out := binary.LittleEndian.AppendUint32(nil, uint32(len(pieces)))
+	for _, piece := range pieces {
+		out = binary.LittleEndian.AppendUint64(out, uint64(len(piece)))
+		out = append(out, piece...)
+	}
+	return out
+}
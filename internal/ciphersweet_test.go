@@ -0,0 +1,59 @@
+package internal_test
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+
+	"github.com/muhammadluth/govault/internal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// contact is the contacts table of the CipherSweet documentation
+type contact struct {
+	SSN            string `bun:"ssn" encrypted:"true" codec:"ciphersweet" blindindex:"contact_ssn"`
+	ContactSSN     string `bun:"contact_ssn"`
+	LongSSN        string `bun:"long_ssn" encrypted:"true" codec:"ciphersweet" blindindex:"contact_long_ssn" blindindexbits:"20"`
+	ContactLongSSN string `bun:"contact_long_ssn"`
+}
+
+// The expected values were computed outside govault from the CipherSweet
+// ModernCrypto spec, with the root key of its documentation: the field key
+// is HKDF-SHA384(root, salt "contacts", info 0xB4*32 || "ssn"), the value
+// XChaCha20-Poly1305 with the nonce as associated data, and the fast blind
+// index BLAKE2b keyed by HMAC-SHA256 over pack(table, field, index name)
+// of the 0x7E*32 subkey, masked to its bits.
+const (
+	cipherSweetRootKey    = "4e1c44f87b4cdf21808762970b356891db180a9dd9850e7baf2a79ff3ab8a2fc"
+	cipherSweetCiphertext = "nacl:AAECAwQFBgcICQoLDA0ODxAREhMUFRYXJphW1g92sA2JzD_I2GEjN2U2LsZoQPdbZJ8t"
+)
+
+func TestCipherSweetKnownAnswer(t *testing.T) {
+	root, err := hex.DecodeString(cipherSweetRootKey)
+	require.NoError(t, err)
+	// The nonce of SSN, then the one of LongSSN
+	nonces := make([]byte, 48)
+	for i := range nonces {
+		nonces[i] = byte(i)
+	}
+	g, err := internal.New(internal.Config{
+		Keys:             map[string][]byte{"1": root},
+		DefaultKeyID:     "1",
+		CipherSweetKeyID: "1",
+		RandReader:       bytes.NewReader(nonces),
+	})
+	require.NoError(t, err)
+
+	c := contact{SSN: "111-11-1111", LongSSN: "111-11-1111"}
+	require.NoError(t, g.EncryptModel(&c, ""))
+	assert.Equal(t, cipherSweetCiphertext, c.SSN)
+	assert.Equal(t, "250cc96e", c.ContactSSN)
+	assert.Equal(t, "e8c2c0", c.ContactLongSSN, "20 bits keep the high nibble of the third byte")
+
+	spec := g.ModelSpec(&contact{})
+	plaintext, encrypted, err := g.DecryptField(spec.Field("SSN"), spec.Table, cipherSweetCiphertext)
+	require.NoError(t, err)
+	assert.True(t, encrypted)
+	assert.Equal(t, "111-11-1111", plaintext)
+}
@@ -0,0 +1,125 @@
+package internal
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+
+	"golang.org/x/crypto/hkdf"
+)
+
+// Codec reads and writes a foreign ciphertext format for fields tagged with
+// codec:"<name>", so columns shared with other stacks keep their format
+type Codec interface {
+	// Encrypt encrypts plaintext for the given field. keyID is the key
+	// requested for the query and may be empty.
+	Encrypt(g *GovaultDB, field *FieldSpec, table, plaintext, keyID string) (string, error)
+	// Decrypt decrypts a value produced by Encrypt
+	Decrypt(g *GovaultDB, field *FieldSpec, table, ciphertext string) (string, error)
+	// IsEncrypted reports whether value is a ciphertext of this codec
+	IsEncrypted(value string) bool
+	// BlindIndex computes the blind index of plaintext for the given field
+	BlindIndex(g *GovaultDB, field *FieldSpec, table, plaintext string) (string, error)
+	// KeyIDs returns the IDs of the keys Decrypt needs for ciphertext
+	KeyIDs(g *GovaultDB, ciphertext string) []string
+}
+
+var codecs = map[string]Codec{
+	CodecCipherSweet: cipherSweetCodec{},
+	CodecTink:        tinkCodec{},
+}
+
+// lookupCodec returns the codec registered under name
+func lookupCodec(name string) (Codec, error) {
+	codec, ok := codecs[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown codec '%s'", name)
+	}
+	return codec, nil
+}
+
+// blindIndexDomain separates native blind index keys from encryption keys
+const blindIndexDomain = "govault blind index"
+
+// BlindIndex computes the blind index of plaintext for the named field of
+// model, using the field's codec, after its normalizers. The result is what gets stored in the
+// column named by the blindindex tag and can be used in equality lookups.
+func (g *GovaultDB) BlindIndex(model any, fieldName, plaintext string) (string, error) {
+	spec := g.ModelSpec(model)
+	if spec == nil {
+		return "", fmt.Errorf("model must be a struct, got %T", model)
+	}
+	field := spec.Field(fieldName)
+	if field == nil {
+		return "", fmt.Errorf("field %s is not encrypted", fieldName)
+	}
+	plaintext, err := NormalizeField(field, plaintext)
+	if err != nil {
+		return "", err
+	}
+	return g.blindIndex(field, spec.Table, plaintext)
+}
+
+// BlindIndexField computes the blind index of plaintext for field of table
+// without looking up a model, e.g. from generated code. plaintext must
+// already be normalized, see NormalizeField.
+func (g *GovaultDB) BlindIndexField(field *FieldSpec, table, plaintext string) (string, error) {
+	return g.blindIndex(field, table, plaintext)
+}
+
+func (g *GovaultDB) blindIndex(field *FieldSpec, table, plaintext string) (string, error) {
+	if field.Codec != "" {
+		codec, err := lookupCodec(field.Codec)
+		if err != nil {
+			return "", err
+		}
+		return codec.BlindIndex(g, field, table, plaintext)
+	}
+	return g.nativeBlindIndex(field, table, plaintext)
+}
+
+// nativeBlindIndex computes an HMAC-SHA256 blind index keyed by a subkey of
+// the blind index key, bound to the table and column
+func (g *GovaultDB) nativeBlindIndex(field *FieldSpec, table, plaintext string) (string, error) {
+	subKey, err := g.blindIndexSubKey(blindIndexDomain, table, field.Column)
+	if err != nil {
+		return "", err
+	}
+
+	mac := hmac.New(sha256.New, subKey)
+	mac.Write([]byte(plaintext))
+	return hex.EncodeToString(truncateBits(mac.Sum(nil), field.BlindIndexBits)), nil
+}
+
+// blindIndexSubKey derives the key of a table column from the blind index
+// key, separated by domain
+func (g *GovaultDB) blindIndexSubKey(domain, table, column string) ([]byte, error) {
+	key, err := g.lookupKey(g.blindIndexKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load blind index key: %w", err)
+	}
+
+	subKey := make([]byte, 32)
+	kdf := hkdf.New(sha256.New, key.Value, []byte(domain), []byte(table+"."+column))
+	if _, err := io.ReadFull(kdf, subKey); err != nil {
+		return nil, fmt.Errorf("failed to derive blind index key: %w", err)
+	}
+	return subKey, nil
+}
+
+// truncateBits keeps the first bits bits of b, zeroing the unused low bits of
+// the last byte
+func truncateBits(b []byte, bits int) []byte {
+	n := (bits + 7) / 8
+	if n > len(b) {
+		n = len(b)
+		bits = n * 8
+	}
+	out := append([]byte(nil), b[:n]...)
+	if rem := bits % 8; rem != 0 {
+		out[n-1] &= byte(0xff << (8 - rem))
+	}
+	return out
+}
@@ -0,0 +1,134 @@
+package internal
+
+import (
+	"sort"
+	"time"
+)
+
+// Data classification. The class tag labels a column with the class of
+// the data it holds, encrypted or not:
+//
+//	Email string `bun:"email" encrypted:"true" class:"pii-high"`
+//	City  string `bun:"city" class:"pii-low"`
+//
+// Classes are free-form; ComplianceReport lists every classified column
+// with how it is protected.
+
+// classifiedColumn is a column of a model with a class tag but no
+// encryption
+type classifiedColumn struct {
+	name   string
+	column string
+	class  string
+}
+
+// ComplianceField describes the protection of a classified column
+type ComplianceField struct {
+	Table     string `json:"table"`
+	Column    string `json:"column"`
+	Field     string `json:"field"`
+	Class     string `json:"class"`
+	Encrypted bool   `json:"encrypted"`
+	// Format, Algorithm and Deterministic describe the ciphertexts of
+	// encrypted columns
+	Format        string    `json:"format,omitempty"`
+	Algorithm     Algorithm `json:"algorithm,omitempty"`
+	Deterministic bool      `json:"deterministic,omitempty"`
+	// KeyID is the key new values are written with
+	KeyID          string   `json:"key_id,omitempty"`
+	BlindIndex     string   `json:"blind_index,omitempty"`
+	BlindIndexBits int      `json:"blind_index_bits,omitempty"`
+	Normalizers    []string `json:"normalizers,omitempty"`
+	Preset         string   `json:"preset,omitempty"`
+	// TTL is the retention of the values, zero means forever
+	TTL time.Duration `json:"ttl,omitempty"`
+	// LastRotatedAt is when the column was last re-encrypted, zero when
+	// unknown. Adapters with a catalog of rotations fill it in.
+	LastRotatedAt time.Time `json:"last_rotated_at,omitzero"`
+}
+
+// ComplianceReport lists the classified columns of a set of models and
+// the keys protecting them, e.g. as evidence for an audit
+type ComplianceReport struct {
+	GeneratedAt     time.Time         `json:"generated_at"`
+	DefaultKeyID    string            `json:"default_key_id"`
+	BlindIndexKeyID string            `json:"blind_index_key_id"`
+	Keys            []KeyStatus       `json:"keys"`
+	Fields          []ComplianceField `json:"fields"`
+}
+
+// ComplianceReport reports the classified columns of models, sorted by
+// table and column, including those of nested models. Encrypted columns
+// without a class tag are left out.
+func (g *GovaultDB) ComplianceReport(models ...any) *ComplianceReport {
+	report := &ComplianceReport{
+		GeneratedAt:     time.Now().UTC(),
+		DefaultKeyID:    g.GetDefaultKeyID(),
+		BlindIndexKeyID: g.blindIndexKey,
+		Keys:            g.KeyStatuses(),
+		Fields:          []ComplianceField{},
+	}
+	seen := make(map[*ModelSpec]bool)
+	for _, model := range models {
+		if spec := g.ModelSpec(model); spec != nil {
+			g.reportSpec(report, spec, seen)
+		}
+	}
+	sort.Slice(report.Fields, func(i, j int) bool {
+		a, b := report.Fields[i], report.Fields[j]
+		if a.Table != b.Table {
+			return a.Table < b.Table
+		}
+		return a.Column < b.Column
+	})
+	return report
+}
+
+// reportSpec adds the classified columns of spec and its nested models
+func (g *GovaultDB) reportSpec(report *ComplianceReport, spec *ModelSpec, seen map[*ModelSpec]bool) {
+	if seen[spec] {
+		return
+	}
+	seen[spec] = true
+
+	for _, field := range spec.Fields {
+		if field.Class == "" {
+			continue
+		}
+		entry := ComplianceField{
+			Table:         spec.Table,
+			Column:        field.Column,
+			Field:         field.Name,
+			Class:         field.Class,
+			Encrypted:     true,
+			Format:        field.Format(),
+			Deterministic: field.Deterministic,
+			KeyID:         report.DefaultKeyID,
+			Normalizers:   field.Normalizers,
+			Preset:        field.Preset,
+			TTL:           field.TTL,
+		}
+		switch field.Codec {
+		case "":
+			entry.Algorithm = field.Algorithm.normalize()
+		case CodecCipherSweet:
+			entry.KeyID = g.cipherSweetKeyID
+		}
+		if field.BlindIndex != "" {
+			entry.BlindIndex = field.BlindIndex
+			entry.BlindIndexBits = field.BlindIndexBits
+		}
+		report.Fields = append(report.Fields, entry)
+	}
+	for _, column := range spec.classified {
+		report.Fields = append(report.Fields, ComplianceField{
+			Table:  spec.Table,
+			Column: column.column,
+			Field:  column.name,
+			Class:  column.class,
+		})
+	}
+	for _, nested := range spec.nested {
+		g.reportSpec(report, nested.spec, seen)
+	}
+}
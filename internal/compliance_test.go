@@ -0,0 +1,67 @@
+package internal_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/muhammadluth/govault/internal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type classifiedAddress struct {
+	Street string `bun:"street" encrypted:"true" class:"pii-high"`
+	City   string `bun:"city" class:"pii-low"`
+}
+
+type classifiedPatient struct {
+	ID        int64             `bun:"id,pk"`
+	Email     string            `bun:"email" encrypted:"true" class:"pii-high" blindindex:"email_bidx"`
+	EmailBidx string            `bun:"email_bidx"`
+	Diagnosis string            `bun:"diagnosis" encrypted:"true" class:"phi" encryptttl:"720h"`
+	Notes     string            `bun:"notes" encrypted:"true"`
+	Country   string            `bun:"country" class:"internal"`
+	Home      classifiedAddress `bun:"embed:home_"`
+}
+
+func TestComplianceReport(t *testing.T) {
+	g, err := internal.New(internal.Config{
+		Keys: map[string][]byte{
+			"1": []byte("727d37a0-a5f2-4d67-af47-83039c8e"),
+			"2": []byte("0c4b1e5d-2f6a-4a8e-9b3c-7d1e5f2a"),
+		},
+		DefaultKeyID:      "2",
+		DecryptOnlyKeyIDs: []string{"1"},
+	})
+	require.NoError(t, err)
+
+	report := g.ComplianceReport(&classifiedPatient{}, nil)
+	assert.Equal(t, "2", report.DefaultKeyID)
+	require.Len(t, report.Keys, 2)
+	assert.True(t, report.Keys[0].DecryptOnly)
+
+	var columns []string
+	for _, field := range report.Fields {
+		columns = append(columns, field.Column)
+	}
+	assert.Equal(t, []string{"country", "diagnosis", "email", "home_city", "home_street"}, columns, "unclassified columns are left out")
+
+	email := report.Fields[2]
+	assert.Equal(t, "classified_patients", email.Table)
+	assert.Equal(t, "pii-high", email.Class)
+	assert.True(t, email.Encrypted)
+	assert.Equal(t, internal.AlgorithmAESGCM, email.Algorithm)
+	assert.Equal(t, "2", email.KeyID)
+	assert.Equal(t, "email_bidx", email.BlindIndex)
+	assert.Equal(t, 32, email.BlindIndexBits)
+
+	country := report.Fields[0]
+	assert.Equal(t, "internal", country.Class)
+	assert.False(t, country.Encrypted, "plaintext classified columns are reported too")
+	assert.Empty(t, country.KeyID)
+
+	data, err := json.Marshal(report)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"class":"phi"`)
+	assert.NotContains(t, string(data), "last_rotated_at", "unknown rotations are omitted")
+}
@@ -0,0 +1,274 @@
+package internal
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// FieldConfig declares the encryption of a column outside of struct tags,
+// see Config.Fields. Set values override the tags of the field.
+type FieldConfig struct {
+	Codec          string  `yaml:"codec"`
+	Envelope       string  `yaml:"envelope"`
+	Algorithm      string  `yaml:"algorithm"` // aes-gcm (default) or xchacha20poly1305
+	Deterministic  bool    `yaml:"deterministic"`
+	BlindIndex     string  `yaml:"blind_index"`
+	BlindIndexBits int     `yaml:"blind_index_bits"`
+	PlainHash      string  `yaml:"plain_hash"`
+	TokenIndex     string  `yaml:"token_index"`
+	Bloom          string  `yaml:"bloom"`
+	BloomItems     int     `yaml:"bloom_items"`
+	BloomFPR       float64 `yaml:"bloom_fpr"`
+	Storage        string  `yaml:"storage"` // binary for BYTEA or BLOB columns
+	// Bucket and BucketBounds set a bucket column, see BucketID
+	Bucket       string    `yaml:"bucket"`
+	BucketBounds []float64 `yaml:"bucket_bounds"`
+	// Geohash and GeohashPrecision set a geohash column, see GeohashIndex
+	Geohash          string `yaml:"geohash"`
+	GeohashPrecision int    `yaml:"geohash_precision"`
+	// EmailDomain and EmailDomainHash set a domain column, see EmailDomain
+	EmailDomain     string `yaml:"email_domain"`
+	EmailDomainHash bool   `yaml:"email_domain_hash"`
+	// Normalize names the normalizers of the field, see Normalizer
+	Normalize []string `yaml:"normalize"`
+	// PAN, PANBIN, PANLast4 and PANToken set a card number field, see
+	// NormalizePAN
+	PAN      bool   `yaml:"pan"`
+	PANBIN   string `yaml:"pan_bin"`
+	PANLast4 string `yaml:"pan_last4"`
+	PANToken string `yaml:"pan_token"`
+	// Preset names the preset of the field, see Preset
+	Preset string `yaml:"preset"`
+	// Class is the data classification of the column, see ComplianceReport
+	Class string `yaml:"class"`
+}
+
+// fileConfig is the schema of the files read by LoadConfig
+type fileConfig struct {
+	Adapter                  AdapterName                       `yaml:"adapter"`
+	Mode                     Mode                              `yaml:"mode"`
+	DefaultKey               string                            `yaml:"default_key"`
+	DecryptOnlyKeys          []string                          `yaml:"decrypt_only_keys"`
+	BlindIndexKey            string                            `yaml:"blind_index_key"`
+	CipherSweetKey           string                            `yaml:"ciphersweet_key"`
+	Region                   string                            `yaml:"region"`
+	FailoverRegions          []string                          `yaml:"failover_regions"`
+	ProviderTimeout          time.Duration                     `yaml:"provider_timeout"`
+	ProviderBreakerThreshold int                               `yaml:"provider_breaker_threshold"`
+	ProviderBreakerCooldown  time.Duration                     `yaml:"provider_breaker_cooldown"`
+	Fallback                 Fallback                          `yaml:"fallback"`
+	EncryptEmptyStrings      bool                              `yaml:"encrypt_empty_strings"`
+	EmbedEncryptedAt         bool                              `yaml:"embed_encrypted_at"`
+	Keys                     map[string]keySource              `yaml:"keys"`
+	KeyDir                   *keyDirSource                     `yaml:"key_dir"`
+	Fields                   map[string]map[string]FieldConfig `yaml:"fields"`
+}
+
+// keySource locates the bytes of a key: exactly one of Value, Env and File.
+// Keys with KMS are wrapped data keys unwrapped by Config.KeyProvider.
+type keySource struct {
+	Value    string     `yaml:"value"`
+	Env      string     `yaml:"env"`
+	File     string     `yaml:"file"`
+	Encoding string     `yaml:"encoding"` // raw (default), base64 or hex
+	KMS      *kmsSource `yaml:"kms"`
+}
+
+// keyDirSource locates a directory of key files, see LoadKeyDir
+type keyDirSource struct {
+	Path     string `yaml:"path"`
+	Encoding string `yaml:"encoding"`
+}
+
+// kmsSource holds the ProviderKey fields other than the wrapped key
+type kmsSource struct {
+	KeyURIs       map[string]string `yaml:"key_uris"`
+	RegionWrapped map[string]string `yaml:"region_wrapped"` // base64
+}
+
+// envReference matches ${NAME} in config files
+var envReference = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// LoadConfig reads a YAML or JSON config file. ${NAME} references in
+// scalar values are replaced by environment variables, which must be set.
+// Values are substituted after parsing, so a variable can't add keys or
+// structure to the file. Relative key files are resolved against the
+// directory of path. The database, and the KeyProvider for kms keys, are
+// set by the caller.
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config: %w", err)
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse config %s: %w", path, err)
+	}
+	var missing []string
+	substituteEnv(&doc, &missing)
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("environment variables not set: %s", strings.Join(missing, ", "))
+	}
+	if data, err = yaml.Marshal(&doc); err != nil {
+		return nil, fmt.Errorf("failed to parse config %s: %w", path, err)
+	}
+	return parseConfig(path, data)
+}
+
+// substituteEnv replaces the ${NAME} references in the scalar values of
+// node, appending the names of unset variables to missing. Plain scalars
+// lose their tag so that substituted numbers and booleans resolve as such.
+func substituteEnv(node *yaml.Node, missing *[]string) {
+	if node.Kind == yaml.ScalarNode && envReference.MatchString(node.Value) {
+		node.Value = envReference.ReplaceAllStringFunc(node.Value, func(ref string) string {
+			name := envReference.FindStringSubmatch(ref)[1]
+			value, ok := os.LookupEnv(name)
+			if !ok {
+				*missing = append(*missing, name)
+			}
+			return value
+		})
+		if node.Style == 0 {
+			node.Tag = ""
+		}
+	}
+	for _, child := range node.Content {
+		substituteEnv(child, missing)
+	}
+}
+
+// parseConfig parses the config file read from path
+func parseConfig(path string, data []byte) (*Config, error) {
+	var file fileConfig
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&file); err != nil {
+		return nil, fmt.Errorf("failed to parse config %s: %w", path, err)
+	}
+
+	config := &Config{
+		AdapterName:              file.Adapter,
+		DefaultKeyID:             file.DefaultKey,
+		Mode:                     file.Mode,
+		Region:                   file.Region,
+		FailoverRegions:          file.FailoverRegions,
+		ProviderTimeout:          file.ProviderTimeout,
+		ProviderBreakerThreshold: file.ProviderBreakerThreshold,
+		ProviderBreakerCooldown:  file.ProviderBreakerCooldown,
+		Fallback:                 file.Fallback,
+		DecryptOnlyKeyIDs:        file.DecryptOnlyKeys,
+		BlindIndexKeyID:          file.BlindIndexKey,
+		CipherSweetKeyID:         file.CipherSweetKey,
+		EncryptEmptyStrings:      file.EncryptEmptyStrings,
+		EmbedEncryptedAt:         file.EmbedEncryptedAt,
+		Fields:                   file.Fields,
+	}
+
+	for keyID, source := range file.Keys {
+		key, err := source.load(filepath.Dir(path))
+		if err != nil {
+			return nil, fmt.Errorf("failed to load key '%s': %w", keyID, err)
+		}
+		if source.KMS == nil {
+			if config.Keys == nil {
+				config.Keys = make(map[string][]byte)
+			}
+			config.Keys[keyID] = key
+			continue
+		}
+
+		providerKey := ProviderKey{Wrapped: key, KeyURIs: source.KMS.KeyURIs}
+		for region, wrapped := range source.KMS.RegionWrapped {
+			decoded, err := base64.StdEncoding.DecodeString(wrapped)
+			if err != nil {
+				return nil, fmt.Errorf("failed to decode wrapped key '%s' of region '%s': %w", keyID, region, err)
+			}
+			if providerKey.RegionWrapped == nil {
+				providerKey.RegionWrapped = make(map[string][]byte)
+			}
+			providerKey.RegionWrapped[region] = decoded
+		}
+		if config.ProviderKeys == nil {
+			config.ProviderKeys = make(map[string]ProviderKey)
+		}
+		config.ProviderKeys[keyID] = providerKey
+	}
+
+	if file.KeyDir != nil {
+		dir := file.KeyDir.Path
+		if !filepath.IsAbs(dir) {
+			dir = filepath.Join(filepath.Dir(path), dir)
+		}
+		keys, defaultKeyID, err := LoadKeyDir(dir, file.KeyDir.Encoding)
+		if err != nil {
+			return nil, err
+		}
+		for keyID, key := range keys {
+			if _, exists := file.Keys[keyID]; exists {
+				return nil, fmt.Errorf("key '%s' is both in keys and key_dir", keyID)
+			}
+			if config.Keys == nil {
+				config.Keys = make(map[string][]byte)
+			}
+			config.Keys[keyID] = key
+		}
+		if config.DefaultKeyID == "" {
+			config.DefaultKeyID = defaultKeyID
+		}
+	}
+
+	return config, nil
+}
+
+// load returns the decoded key bytes
+func (s keySource) load(dir string) ([]byte, error) {
+	var raw string
+	switch {
+	case s.Value != "" && s.Env == "" && s.File == "":
+		raw = s.Value
+	case s.Env != "" && s.Value == "" && s.File == "":
+		value, ok := os.LookupEnv(s.Env)
+		if !ok {
+			return nil, fmt.Errorf("environment variable %s is not set", s.Env)
+		}
+		raw = value
+	case s.File != "" && s.Value == "" && s.Env == "":
+		path := s.File
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(dir, path)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read key file: %w", err)
+		}
+		raw = strings.TrimRight(string(data), "\r\n")
+	default:
+		return nil, fmt.Errorf("exactly one of value, env and file is required")
+	}
+
+	return decodeKey(raw, s.Encoding)
+}
+
+// decodeKey decodes raw key bytes in encoding: raw (default), base64 or hex
+func decodeKey(raw, encoding string) ([]byte, error) {
+	switch encoding {
+	case "", "raw":
+		return []byte(raw), nil
+	case "base64":
+		return base64.StdEncoding.DecodeString(strings.TrimSpace(raw))
+	case "hex":
+		return hex.DecodeString(strings.TrimSpace(raw))
+	default:
+		return nil, fmt.Errorf("unknown encoding '%s'", encoding)
+	}
+}
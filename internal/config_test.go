@@ -0,0 +1,213 @@
+package internal_test
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/muhammadluth/govault/internal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	configKey1 = []byte("727d37a0-a5f2-4d67-af47-83039c8e")
+	configKey2 = []byte("e778dc27-9b04-44c3-a862-feba061c")
+	configKey3 = []byte("e778dc27-9b04-44c3-a862-83039c8e")
+)
+
+type configCustomer struct {
+	Email     string `bun:"email"`
+	EmailBidx string `bun:"email_bidx"`
+	SSN       string `bun:"ssn" encrypted:"true"`
+	Name      string `bun:"name"`
+}
+
+// writeConfig writes a config file and returns its path
+func writeConfig(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func TestLoadConfig(t *testing.T) {
+	t.Setenv("GOVAULT_TEST_DEFAULT_KEY", "k2")
+	t.Setenv("GOVAULT_TEST_KEY2", base64.StdEncoding.EncodeToString(configKey2))
+	t.Setenv("GOVAULT_TEST_KEY3", string(configKey3))
+
+	path := writeConfig(t, "govault.yaml", `
+adapter: bun
+default_key: ${GOVAULT_TEST_DEFAULT_KEY}
+decrypt_only_keys: [k1]
+blind_index_key: k3
+provider_timeout: 2s
+keys:
+  k1:
+    file: k1.hex
+    encoding: hex
+  k2:
+    env: GOVAULT_TEST_KEY2
+    encoding: base64
+  k3:
+    value: ${GOVAULT_TEST_KEY3}
+fields:
+  config_customers:
+    email:
+      blind_index: email_bidx
+      blind_index_bits: 16
+    ssn:
+      deterministic: true
+`)
+	require.NoError(t, os.WriteFile(filepath.Join(filepath.Dir(path), "k1.hex"), []byte(hex.EncodeToString(configKey1)+"\n"), 0o600))
+
+	config, err := internal.LoadConfig(path)
+	require.NoError(t, err)
+	assert.Equal(t, internal.AdapterNameBun, config.AdapterName)
+	assert.Equal(t, "k2", config.DefaultKeyID)
+	assert.Equal(t, []string{"k1"}, config.DecryptOnlyKeyIDs)
+	assert.Equal(t, 2*time.Second, config.ProviderTimeout)
+	assert.Equal(t, map[string][]byte{"k1": configKey1, "k2": configKey2, "k3": configKey3}, config.Keys)
+
+	g, err := internal.New(*config)
+	require.NoError(t, err)
+
+	spec := g.ModelSpec(configCustomer{})
+	require.Len(t, spec.Fields, 2)
+	email := spec.Field("Email")
+	require.NotNil(t, email, "declared fields are encrypted without tags")
+	assert.Equal(t, "email_bidx", email.BlindIndex)
+	assert.Equal(t, 16, email.BlindIndexBits)
+	assert.True(t, spec.Field("SSN").Deterministic)
+	assert.Nil(t, spec.Field("Name"))
+
+	c := configCustomer{Email: "ada@example.com", SSN: "123-45-6789", Name: "Ada"}
+	require.NoError(t, g.EncryptModel(&c, ""))
+	assert.True(t, internal.IsEncrypted(c.Email))
+	assert.NotEmpty(t, c.EmailBidx)
+	assert.Equal(t, "Ada", c.Name)
+
+	other := configCustomer{SSN: "123-45-6789"}
+	require.NoError(t, g.EncryptModel(&other, ""))
+	assert.Equal(t, c.SSN, other.SSN)
+
+	require.NoError(t, g.DecryptRecursive(&c))
+	assert.Equal(t, configCustomer{Email: "ada@example.com", EmailBidx: c.EmailBidx, SSN: "123-45-6789", Name: "Ada"}, c,
+		"declared fields are decrypted without tags")
+
+	// Declarations stay with the instance
+	assert.Len(t, internal.GetModelSpec(configCustomer{}).Fields, 1)
+	plain, err := internal.New(internal.Config{Keys: map[string][]byte{"k1": configKey1}, DefaultKeyID: "k1"})
+	require.NoError(t, err)
+	assert.Nil(t, plain.ModelSpec(configCustomer{}).Field("Email"))
+	c = configCustomer{Email: "ada@example.com"}
+	require.NoError(t, plain.EncryptModel(&c, ""))
+	assert.Equal(t, "ada@example.com", c.Email)
+}
+
+func TestLoadConfigEnvValues(t *testing.T) {
+	t.Setenv("GOVAULT_TEST_KEY1", string(configKey1))
+	t.Setenv("GOVAULT_TEST_DEFAULT_KEY", "k1\ndecrypt_only_keys: [k1]\nkeys: {evil: {value: "+string(configKey2)+"}}")
+	t.Setenv("GOVAULT_TEST_BITS", "16")
+
+	path := writeConfig(t, "govault.yaml", `
+default_key: ${GOVAULT_TEST_DEFAULT_KEY}
+keys:
+  k1:
+    value: ${GOVAULT_TEST_KEY1}
+fields:
+  config_customers:
+    email:
+      blind_index: email_bidx
+      blind_index_bits: ${GOVAULT_TEST_BITS}
+`)
+	config, err := internal.LoadConfig(path)
+	require.NoError(t, err)
+	assert.Equal(t, map[string][]byte{"k1": configKey1}, config.Keys, "values can't add keys")
+	assert.Empty(t, config.DecryptOnlyKeyIDs)
+	assert.Contains(t, config.DefaultKeyID, "evil", "the whole value is one scalar")
+	assert.Equal(t, 16, config.Fields["config_customers"]["email"].BlindIndexBits, "numbers resolve after substitution")
+
+	_, err = internal.LoadConfig(writeConfig(t, "missing.yaml", "default_key: ${GOVAULT_TEST_UNSET_VARIABLE}\n"))
+	assert.ErrorContains(t, err, "GOVAULT_TEST_UNSET_VARIABLE")
+}
+
+func TestLoadConfigJSON(t *testing.T) {
+	path := writeConfig(t, "govault.json", `{
+  "default_key": "k1",
+  "region": "eu-west-1",
+  "keys": {
+    "k1": {"value": "`+string(configKey1)+`"},
+    "kms": {
+      "value": "`+base64.StdEncoding.EncodeToString([]byte("wrapped"))+`",
+      "encoding": "base64",
+      "kms": {
+        "key_uris": {"eu-west-1": "aws-kms://eu", "us-east-1": "aws-kms://us"},
+        "region_wrapped": {"us-east-1": "`+base64.StdEncoding.EncodeToString([]byte("wrapped-us"))+`"}
+      }
+    }
+  }
+}`)
+
+	config, err := internal.LoadConfig(path)
+	require.NoError(t, err)
+	assert.Equal(t, "eu-west-1", config.Region)
+	assert.Equal(t, map[string][]byte{"k1": configKey1}, config.Keys)
+	assert.Equal(t, internal.ProviderKey{
+		Wrapped:       []byte("wrapped"),
+		KeyURIs:       map[string]string{"eu-west-1": "aws-kms://eu", "us-east-1": "aws-kms://us"},
+		RegionWrapped: map[string][]byte{"us-east-1": []byte("wrapped-us")},
+	}, config.ProviderKeys["kms"])
+}
+
+func TestLoadConfigKeyDir(t *testing.T) {
+	path := writeConfig(t, "govault.yaml", `
+keys:
+  k1:
+    value: `+string(configKey1)+`
+key_dir:
+  path: secrets
+  encoding: hex
+`)
+	dir := filepath.Join(filepath.Dir(path), "secrets")
+	require.NoError(t, os.Mkdir(dir, 0o700))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "k2"), []byte(hex.EncodeToString(configKey2)), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, internal.DefaultKeyIDFile), []byte("k2\n"), 0o600))
+
+	config, err := internal.LoadConfig(path)
+	require.NoError(t, err)
+	assert.Equal(t, "k2", config.DefaultKeyID)
+	assert.Equal(t, map[string][]byte{"k1": configKey1, "k2": configKey2}, config.Keys)
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "k1"), []byte(hex.EncodeToString(configKey1)), 0o600))
+	_, err = internal.LoadConfig(path)
+	assert.ErrorContains(t, err, "key 'k1' is both in keys and key_dir")
+}
+
+func TestLoadConfigErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		err     string
+	}{
+		{"unset variable", "default_key: ${GOVAULT_TEST_UNSET}\n", "environment variables not set: GOVAULT_TEST_UNSET"},
+		{"unknown setting", "default_kye: k1\n", "field default_kye not found"},
+		{"two sources", "keys:\n  k1:\n    value: a\n    env: B\n", "exactly one of value, env and file is required"},
+		{"unset key variable", "keys:\n  k1:\n    env: GOVAULT_TEST_UNSET\n", "environment variable GOVAULT_TEST_UNSET is not set"},
+		{"missing file", "keys:\n  k1:\n    file: missing.key\n", "failed to read key file"},
+		{"bad encoding", "keys:\n  k1:\n    value: a\n    encoding: rot13\n", "unknown encoding 'rot13'"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := internal.LoadConfig(writeConfig(t, "govault.yaml", tt.content))
+			assert.ErrorContains(t, err, tt.err)
+		})
+	}
+
+	_, err := internal.LoadConfig(filepath.Join(t.TempDir(), "missing.yaml"))
+	assert.ErrorContains(t, err, "failed to read config")
+}
@@ -0,0 +1,165 @@
+package internal
+
+import (
+	"reflect"
+)
+
+// DecryptedCopy returns a deep copy of model with its encrypted fields
+// decrypted, leaving model encrypted, e.g. to derive a view model from a
+// canonical object. model is a struct, a pointer to one or a slice of them.
+func DecryptedCopy[T any](g *GovaultDB, model T) (T, error) {
+	c := deepCopyOf(model)
+	if err := withCopyTarget(&c, g.DecryptRecursive); err != nil {
+		var zero T
+		return zero, err
+	}
+	return c, nil
+}
+
+// RedactedCopy returns a deep copy of model whose non-empty encrypted
+// fields are replaced by FallbackMaskValue, e.g. for logs
+func RedactedCopy[T any](model T) T {
+	c := deepCopyOf(model)
+	_ = withCopyTarget(&c, func(target any) error {
+		redactRecursive(reflect.ValueOf(target), "encrypted")
+		return nil
+	})
+	return c
+}
+
+// withCopyTarget calls fn with the pointer to pass the copy c to the
+// recursive walks: c itself when it holds a pointer, else a pointer to it
+func withCopyTarget[T any](c *T, fn func(target any) error) error {
+	v := reflect.ValueOf(c).Elem()
+	if v.Kind() == reflect.Interface && !v.IsNil() {
+		// Values held by interfaces are not addressable
+		if v = v.Elem(); v.Kind() != reflect.Ptr {
+			ptr := reflect.New(v.Type())
+			ptr.Elem().Set(v)
+			if err := fn(ptr.Interface()); err != nil {
+				return err
+			}
+			reflect.ValueOf(c).Elem().Set(ptr.Elem())
+			return nil
+		}
+	}
+	if v.Kind() == reflect.Ptr {
+		return fn(v.Interface())
+	}
+	return fn(c)
+}
+
+func deepCopyOf[T any](model T) T {
+	v := reflect.ValueOf(&model).Elem()
+	return deepCopy(v, make(map[copiedPointer]reflect.Value)).Interface().(T)
+}
+
+// copiedPointer identifies a pointer already copied, so that cycles and
+// shared pointers are preserved
+type copiedPointer struct {
+	addr uintptr
+	typ  reflect.Type
+}
+
+// deepCopy copies v, sharing nothing reachable through pointers, slices,
+// maps and interfaces with it. Unexported fields are copied shallowly.
+func deepCopy(v reflect.Value, seen map[copiedPointer]reflect.Value) reflect.Value {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			return reflect.Zero(v.Type())
+		}
+		key := copiedPointer{addr: v.Pointer(), typ: v.Type()}
+		if c, ok := seen[key]; ok {
+			return c
+		}
+		c := reflect.New(v.Type().Elem())
+		seen[key] = c
+		c.Elem().Set(deepCopy(v.Elem(), seen))
+		return c
+	case reflect.Interface:
+		c := reflect.New(v.Type()).Elem()
+		if !v.IsNil() {
+			c.Set(deepCopy(v.Elem(), seen))
+		}
+		return c
+	case reflect.Struct:
+		c := reflect.New(v.Type()).Elem()
+		c.Set(v)
+		for i := 0; i < v.NumField(); i++ {
+			if c.Field(i).CanSet() {
+				c.Field(i).Set(deepCopy(v.Field(i), seen))
+			}
+		}
+		return c
+	case reflect.Slice:
+		if v.IsNil() {
+			return reflect.Zero(v.Type())
+		}
+		c := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		for i := 0; i < v.Len(); i++ {
+			c.Index(i).Set(deepCopy(v.Index(i), seen))
+		}
+		return c
+	case reflect.Array:
+		c := reflect.New(v.Type()).Elem()
+		for i := 0; i < v.Len(); i++ {
+			c.Index(i).Set(deepCopy(v.Index(i), seen))
+		}
+		return c
+	case reflect.Map:
+		if v.IsNil() {
+			return reflect.Zero(v.Type())
+		}
+		c := reflect.MakeMapWithSize(v.Type(), v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			c.SetMapIndex(deepCopy(iter.Key(), seen), deepCopy(iter.Value(), seen))
+		}
+		return c
+	default:
+		return v
+	}
+}
+
+// redactRecursive masks the fields reachable from val whose tag is "true",
+// walking them the way decryptRecursive does. Non-empty strings become
+// FallbackMaskValue, other values their zero value.
+func redactRecursive(val reflect.Value, tag string) {
+	if val.Kind() == reflect.Ptr {
+		if val.IsNil() {
+			return
+		}
+		val = val.Elem()
+	}
+
+	switch val.Kind() {
+	case reflect.Slice:
+		for i := 0; i < val.Len(); i++ {
+			if elem := val.Index(i); elem.Kind() == reflect.Ptr || elem.Kind() == reflect.Struct {
+				redactRecursive(elem, tag)
+			}
+		}
+	case reflect.Struct:
+		typ := val.Type()
+		for i := 0; i < val.NumField(); i++ {
+			field := val.Field(i)
+			if !field.CanSet() {
+				continue
+			}
+			if typ.Field(i).Tag.Get(tag) == "true" {
+				switch {
+				case field.Kind() == reflect.String && field.String() != "":
+					field.SetString(FallbackMaskValue)
+				case field.Kind() != reflect.String:
+					field.SetZero()
+				}
+				continue
+			}
+			switch field.Kind() {
+			case reflect.Struct, reflect.Ptr, reflect.Slice:
+				redactRecursive(field, tag)
+			}
+		}
+	}
+}
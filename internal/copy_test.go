@@ -0,0 +1,83 @@
+package internal_test
+
+import (
+	"testing"
+
+	"github.com/muhammadluth/govault/internal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type copyAddress struct {
+	Street string `bun:"street" encrypted:"true"`
+	City   string `bun:"city"`
+}
+
+type copyCustomer struct {
+	Name      string `bun:"name"`
+	Email     string `bun:"email" encrypted:"true"`
+	Phone     string `bun:"phone" encrypted:"true"`
+	Address   *copyAddress
+	Previous  []copyAddress
+	Tags      map[string]string
+	unchanged string
+}
+
+func TestCopies(t *testing.T) {
+	g := newTransformDB(t)
+
+	encryptedCustomer := func(t *testing.T) *copyCustomer {
+		t.Helper()
+		c := &copyCustomer{
+			Name:      "Ada",
+			Email:     "ada@example.com",
+			Address:   &copyAddress{Street: "1 Main St", City: "London"},
+			Previous:  []copyAddress{{Street: "2 Old Rd", City: "Leeds"}},
+			Tags:      map[string]string{"tier": "gold"},
+			unchanged: "kept",
+		}
+		require.NoError(t, g.EncryptModel(c, ""))
+		return c
+	}
+
+	t.Run("decrypted copies leave the original encrypted", func(t *testing.T) {
+		c := encryptedCustomer(t)
+		email, street := c.Email, c.Address.Street
+
+		decrypted, err := internal.DecryptedCopy(g, c)
+		require.NoError(t, err)
+		assert.Equal(t, "ada@example.com", decrypted.Email)
+		assert.Equal(t, "1 Main St", decrypted.Address.Street)
+		assert.Equal(t, "2 Old Rd", decrypted.Previous[0].Street)
+		assert.Equal(t, "kept", decrypted.unchanged)
+		assert.NotSame(t, c.Address, decrypted.Address)
+
+		assert.Equal(t, email, c.Email)
+		assert.Equal(t, street, c.Address.Street)
+		assert.True(t, internal.IsEncrypted(c.Previous[0].Street))
+
+		decrypted.Tags["tier"] = "silver"
+		assert.Equal(t, "gold", c.Tags["tier"], "maps are copied")
+	})
+
+	t.Run("struct values and slices are copied", func(t *testing.T) {
+		c := encryptedCustomer(t)
+		decrypted, err := internal.DecryptedCopy(g, []copyCustomer{*c})
+		require.NoError(t, err)
+		assert.Equal(t, "ada@example.com", decrypted[0].Email)
+		assert.True(t, internal.IsEncrypted(c.Email))
+	})
+
+	t.Run("redacted copies mask non-empty encrypted fields", func(t *testing.T) {
+		c := encryptedCustomer(t)
+		redacted := internal.RedactedCopy(*c)
+		assert.Equal(t, internal.FallbackMaskValue, redacted.Email)
+		assert.Empty(t, redacted.Phone)
+		assert.Equal(t, internal.FallbackMaskValue, redacted.Address.Street)
+		assert.Equal(t, "London", redacted.Address.City)
+		assert.Equal(t, internal.FallbackMaskValue, redacted.Previous[0].Street)
+		assert.Equal(t, "Ada", redacted.Name)
+
+		assert.True(t, internal.IsEncrypted(c.Address.Street))
+	})
+}
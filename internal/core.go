@@ -1,11 +1,20 @@
 package internal
 
 import (
+	"bytes"
+	"context"
 	"crypto/aes"
 	"crypto/cipher"
+	"errors"
 	"fmt"
+	"io"
+	"log/slog"
+	"maps"
 	"sort"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/go-pg/pg/v10"
 	"github.com/uptrace/bun"
@@ -21,9 +30,16 @@ const (
 
 // Key represents an encryption key with its ID
 type Key struct {
-	ID     string
-	Value  []byte
-	cipher cipher.AEAD
+	ID    string
+	Value []byte
+	// DecryptOnly keys decrypt existing data but are never used to encrypt
+	DecryptOnly bool
+	cipher      cipher.AEAD
+	xchacha     cipher.AEAD
+	// wrapped is set for provider keys; cipher is nil until unwrapped
+	wrapped *wrappedKey
+	// hpke is set for asymmetric keys, which have no Value or cipher
+	hpke *hpkeKey
 }
 
 // Config holds the configuration for govault
@@ -32,29 +48,178 @@ type Config struct {
 	Keys         map[string][]byte
 	DefaultKeyID string
 	DebugMode    bool
-	BunDB        *bun.DB
-	GoPgDB       *pg.DB
+
+	// Mode restricts the instance to encrypt-only or decrypt-only operation
+	Mode Mode
+
+	// HPKEPublicKeys are X25519 recipient keys: values encrypted with them
+	// can only be decrypted with the matching HPKEPrivateKeys entry, so
+	// write-only services hold no decryption key. A key ID may appear in
+	// both maps.
+	HPKEPublicKeys  map[string][]byte
+	HPKEPrivateKeys map[string][]byte
+
+	// KeyProvider unwraps ProviderKeys on first use. Region is the local
+	// region tried first; FailoverRegions orders the regions tried next,
+	// remaining regions follow alphabetically.
+	KeyProvider     KeyProvider
+	ProviderKeys    map[string]ProviderKey
+	Region          string
+	FailoverRegions []string
+
+	// ProviderTimeout bounds every KeyProvider call; zero means no limit.
+	// After ProviderBreakerThreshold consecutive failures a region is
+	// skipped for ProviderBreakerCooldown (default 30s); zero threshold
+	// disables the circuit breaker.
+	ProviderTimeout          time.Duration
+	ProviderBreakerThreshold int
+	ProviderBreakerCooldown  time.Duration
+
+	// Fallback decides what reads, Decrypt included, return for values
+	// whose provider key is unavailable. Writes, and DecryptStrict, always
+	// fail closed.
+	Fallback Fallback
+
+	// DecryptOnlyKeyIDs lists keys that may decrypt but not encrypt, e.g.
+	// retired keys after a rotation. The default key cannot be one of them.
+	DecryptOnlyKeyIDs []string
+
+	// BlindIndexKeyID selects the key used for native blind indexes.
+	// Defaults to DefaultKeyID; keep it fixed across rotations.
+	BlindIndexKeyID string
+
+	// CipherSweetKeyID selects the root key for fields using the
+	// ciphersweet codec. Defaults to DefaultKeyID.
+	CipherSweetKeyID string
+
+	// EncryptEmptyStrings encrypts empty strings instead of storing them
+	// as is, so rows without a value cannot be told apart from the others.
+	// Plaintexts are padded to 16 byte buckets so ciphertext lengths don't
+	// reveal empties either. Fields tagged bun:",nullzero" still store NULL.
+	EncryptEmptyStrings bool
+
+	// NonceCheck enables nonce reuse detection, see NonceCheck
+	NonceCheck *NonceCheck
+
+	// EmbedEncryptedAt records the encryption time in the header of native
+	// randomized values, authenticated with the ciphertext, so sweepers and
+	// rotation tools can tell their age without a row timestamp. It adds
+	// about 12 bytes per value. Deterministic values never embed it, as it
+	// would make equal plaintexts differ.
+	EmbedEncryptedAt bool
+
+	// Fields declares encrypted columns per table and column in addition
+	// to struct tags, e.g. from LoadConfig. Declarations apply to this
+	// instance only, see GovaultDB.ModelSpec.
+	Fields map[string]map[string]FieldConfig
+
+	// ModelHooks see the models of the wrapper queries in plaintext. As
+	// they can leak it, they only run with ExposePlaintextToHooks set.
+	ModelHooks             []ModelHook
+	ExposePlaintextToHooks bool
+
+	// EnvelopeCodecs read the ciphertexts of foreign AES-256-GCM formats by
+	// format, e.g. "enc1$". Values starting with a format are read by its
+	// codec, and so are all values of fields tagged envelope:"<format>".
+	EnvelopeCodecs map[string]EnvelopeCodec
+
+	// RateLimits caps the encryptions and decryptions per second of each key
+	// by key ID, so that a runaway batch job cannot hammer the KMS or
+	// saturate the CPU; the RateLimitAllKeys entry applies to the keys
+	// without one. Operations over the limit fail with a RateLimitError.
+	// Blind indexes and codecs deriving their own keys are not limited.
+	RateLimits map[string]RateLimit
+
+	// FailureDetector masks the fields of a key and table instead of
+	// failing their reads when their decryptions fail in bulk
+	FailureDetector *FailureDetector
+
+	// ArchivedKeys loads long-retired keys on demand, to decrypt old backups
+	// and snapshots. Archived keys only decrypt values whose key ID is not
+	// in the key set; they are held apart from it, are not listed by
+	// GetKeyIDs and stay in memory until UnloadArchivedKeys.
+	ArchivedKeys KeyArchive
+
+	// RandReader is the source of the nonces of randomized values,
+	// crypto/rand by default. Replay tests and simulations set a seeded
+	// reader, e.g. a math/rand/v2 ChaCha8, to reproduce exact ciphertexts;
+	// never set it in production, where reused nonces leak plaintext.
+	// Reads are serialized. HPKE values and key generation always use
+	// crypto/rand.
+	RandReader io.Reader
+
+	// Logger receives warnings about operations bypassing the usual
+	// safeguards, e.g. DecryptWithKey. Defaults to slog.Default().
+	Logger *slog.Logger
+
+	BunDB  *bun.DB
+	GoPgDB *pg.DB
+
+	// AdapterDB is the database of an adapter registered with
+	// RegisterAdapter, passed to its factory
+	AdapterDB any
+
+	// BunDBs are further bun databases by name, e.g. an archive database,
+	// wrapped with the keys of this instance. The name "" is BunDB.
+	BunDBs map[string]*bun.DB
 }
 
 // GovaultDB is the main vault database struct
 type GovaultDB struct {
+	mu               sync.Mutex // serializes writers of keySet
+	keySet           atomic.Pointer[keySet]
+	blindIndexKey    string
+	cipherSweetKeyID string
+	fallback         Fallback
+	mode             Mode
+	encryptEmpty     bool
+	embedEncryptedAt bool
+	nonces           *nonceTracker
+	modelHooks       []ModelHook
+	logger           *slog.Logger
+	envelopeCodecs   map[string]EnvelopeCodec
+	archive          KeyArchive
+	archived         sync.Map // key ID to *Key loaded from archive
+	rateLimits       map[string]RateLimit
+	limiters         sync.Map // key ID to *rateLimiter
+	failures         *failureDetector
+	random           io.Reader                         // Config.RandReader, nil for crypto/rand
+	fields           map[string]map[string]FieldConfig // Config.Fields
+	specs            sync.Map                          // reflect.Type to declaredSpec
+	DB               any
+}
+
+// keySet is an immutable snapshot of the keys, read without locking on
+// every query. Writers store a modified copy.
+type keySet struct {
 	keys       map[string]*Key
 	defaultKey string
-	DB         any
 }
 
 // New creates a new govault DB with the given configuration
 func New(config Config) (*GovaultDB, error) {
-	if len(config.Keys) == 0 {
+	if len(config.Keys) == 0 && len(config.ProviderKeys) == 0 && len(config.HPKEPublicKeys) == 0 && len(config.HPKEPrivateKeys) == 0 && config.ArchivedKeys == nil {
 		return nil, fmt.Errorf("at least one encryption key is required")
 	}
 
-	if config.DefaultKeyID == "" {
+	switch config.Mode {
+	case ModeReadWrite, ModeEncryptOnly, ModeDecryptOnly:
+	default:
+		return nil, fmt.Errorf("unknown mode '%s'", config.Mode)
+	}
+
+	if config.DefaultKeyID == "" && config.Mode != ModeDecryptOnly {
 		return nil, fmt.Errorf("default key ID is required")
 	}
 
-	if _, exists := config.Keys[config.DefaultKeyID]; !exists {
-		return nil, fmt.Errorf("default key ID '%s' not found in keys", config.DefaultKeyID)
+	if err := checkModelHooks(config); err != nil {
+		return nil, err
+	}
+	if err := checkEnvelopeCodecs(config.EnvelopeCodecs); err != nil {
+		return nil, err
+	}
+	if err := checkRateLimits(config.RateLimits); err != nil {
+		return nil, err
 	}
 
 	// Initialize keys
@@ -67,10 +232,104 @@ func New(config Config) (*GovaultDB, error) {
 		keys[keyID] = key
 	}
 
+	for _, hpkeKeys := range []map[string][]byte{config.HPKEPublicKeys, config.HPKEPrivateKeys} {
+		for keyID := range hpkeKeys {
+			if key, exists := keys[keyID]; exists && key.hpke == nil {
+				return nil, fmt.Errorf("key ID '%s' is both a key and an HPKE key", keyID)
+			}
+			if _, exists := keys[keyID]; exists {
+				continue
+			}
+			key, err := newHPKEKey(keyID, config.HPKEPublicKeys[keyID], config.HPKEPrivateKeys[keyID])
+			if err != nil {
+				return nil, fmt.Errorf("failed to initialize key '%s': %w", keyID, err)
+			}
+			keys[keyID] = key
+		}
+	}
+
+	if len(config.ProviderKeys) > 0 && config.KeyProvider == nil {
+		return nil, fmt.Errorf("provider keys require a key provider")
+	}
+	switch config.Fallback {
+	case FallbackFailClosed, FallbackCiphertext, FallbackMask:
+	default:
+		return nil, fmt.Errorf("unknown fallback '%s'", config.Fallback)
+	}
+	provider := newGuardedProvider(config)
+	for keyID, providerKey := range config.ProviderKeys {
+		if _, exists := keys[keyID]; exists {
+			return nil, fmt.Errorf("key ID '%s' is both a key and a provider key", keyID)
+		}
+		wrapped, err := newWrappedKey(config, providerKey, provider)
+		if err != nil {
+			return nil, fmt.Errorf("failed to initialize provider key '%s': %w", keyID, err)
+		}
+		keys[keyID] = &Key{ID: keyID, wrapped: wrapped}
+	}
+
+	if _, exists := keys[config.DefaultKeyID]; !exists && config.DefaultKeyID != "" {
+		return nil, fmt.Errorf("default key ID '%s' not found in keys", config.DefaultKeyID)
+	}
+
+	for _, keyID := range config.DecryptOnlyKeyIDs {
+		key, exists := keys[keyID]
+		if !exists {
+			return nil, fmt.Errorf("decrypt-only key ID '%s' not found in keys", keyID)
+		}
+		if keyID == config.DefaultKeyID {
+			return nil, fmt.Errorf("default key ID '%s' cannot be decrypt-only", keyID)
+		}
+		key.DecryptOnly = true
+	}
+
+	// Blind indexes and ciphersweet need symmetric keys; an HPKE default key
+	// leaves them unset unless configured explicitly
+	defaultSymmetric := config.DefaultKeyID
+	if key, exists := keys[defaultSymmetric]; exists && key.hpke != nil {
+		defaultSymmetric = ""
+	}
+
+	blindIndexKey := config.BlindIndexKeyID
+	if blindIndexKey == "" {
+		blindIndexKey = defaultSymmetric
+	}
+	if key, exists := keys[blindIndexKey]; !exists && blindIndexKey != "" {
+		return nil, fmt.Errorf("blind index key ID '%s' not found in keys", blindIndexKey)
+	} else if exists && key.hpke != nil {
+		return nil, fmt.Errorf("blind index key ID '%s' cannot be an HPKE key", blindIndexKey)
+	}
+
+	cipherSweetKeyID := config.CipherSweetKeyID
+	if cipherSweetKeyID == "" {
+		cipherSweetKeyID = defaultSymmetric
+	}
+	if key, exists := keys[cipherSweetKeyID]; !exists && cipherSweetKeyID != "" {
+		return nil, fmt.Errorf("ciphersweet key ID '%s' not found in keys", cipherSweetKeyID)
+	} else if exists && key.hpke != nil {
+		return nil, fmt.Errorf("ciphersweet key ID '%s' cannot be an HPKE key", cipherSweetKeyID)
+	}
+
 	govault := &GovaultDB{
-		keys:       keys,
-		defaultKey: config.DefaultKeyID,
+		blindIndexKey:    blindIndexKey,
+		cipherSweetKeyID: cipherSweetKeyID,
+		fallback:         config.Fallback,
+		mode:             config.Mode,
+		encryptEmpty:     config.EncryptEmptyStrings,
+		embedEncryptedAt: config.EmbedEncryptedAt,
+		nonces:           newNonceTracker(config.NonceCheck),
+		modelHooks:       config.ModelHooks,
+		logger:           config.Logger,
+		envelopeCodecs:   maps.Clone(config.EnvelopeCodecs),
+		archive:          config.ArchivedKeys,
+		rateLimits:       maps.Clone(config.RateLimits),
+		failures:         newFailureDetector(config.FailureDetector),
+		fields:           cloneFields(config.Fields),
 	}
+	if config.RandReader != nil {
+		govault.random = &lockedReader{r: config.RandReader}
+	}
+	govault.keySet.Store(&keySet{keys: keys, defaultKey: config.DefaultKeyID})
 
 	return govault, nil
 }
@@ -91,17 +350,24 @@ func newKey(keyID string, keyBytes []byte) (*Key, error) {
 		return nil, fmt.Errorf("failed to create GCM: %w", err)
 	}
 
+	xchacha, err := newXChaCha(keyBytes)
+	if err != nil {
+		return nil, err
+	}
+
 	return &Key{
-		ID:     keyID,
-		Value:  keyBytes,
-		cipher: aead,
+		ID:      keyID,
+		Value:   keyBytes,
+		cipher:  aead,
+		xchacha: xchacha,
 	}, nil
 }
 
 // GetKeyIDs returns all available key IDs
 func (g *GovaultDB) GetKeyIDs() []string {
-	ids := make([]string, 0, len(g.keys))
-	for id := range g.keys {
+	keys := g.keySet.Load().keys
+	ids := make([]string, 0, len(keys))
+	for id := range keys {
 		ids = append(ids, id)
 	}
 	sort.Strings(ids)
@@ -110,7 +376,223 @@ func (g *GovaultDB) GetKeyIDs() []string {
 
 // GetDefaultKeyID returns the default key ID
 func (g *GovaultDB) GetDefaultKeyID() string {
-	return g.defaultKey
+	return g.keySet.Load().defaultKey
+}
+
+// updateKeys applies update to a copy of the key set and stores it, unless
+// update fails
+func (g *GovaultDB) updateKeys(update func(next *keySet) error) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	current := g.keySet.Load()
+	next := &keySet{keys: maps.Clone(current.keys), defaultKey: current.defaultKey}
+	if err := update(next); err != nil {
+		return err
+	}
+	g.keySet.Store(next)
+	return nil
+}
+
+// AddKey adds a key at runtime, e.g. the new key of an online rotation.
+// Adding an existing key ID with the same bytes is a no-op.
+func (g *GovaultDB) AddKey(keyID string, keyBytes []byte) error {
+	key, err := newKey(keyID, keyBytes)
+	if err != nil {
+		return fmt.Errorf("failed to initialize key '%s': %w", keyID, err)
+	}
+
+	return g.updateKeys(func(next *keySet) error {
+		if existing, exists := next.keys[keyID]; exists {
+			if existing.hpke != nil || !bytes.Equal(existing.Value, keyBytes) {
+				return fmt.Errorf("key ID '%s' already exists with different key bytes", keyID)
+			}
+			return nil
+		}
+		next.keys[keyID] = key
+		return nil
+	})
+}
+
+// ReloadKeys replaces the keys of Config.Keys, e.g. after a secret was
+// updated: keys not in keys are removed and new ones added. Provider and
+// HPKE keys are kept. An empty defaultKeyID keeps the default key. The
+// key set is unchanged on error, and in-flight queries finish with the
+// keys they started with.
+func (g *GovaultDB) ReloadKeys(keys map[string][]byte, defaultKeyID string) error {
+	loaded := make(map[string]*Key, len(keys))
+	for keyID, keyBytes := range keys {
+		key, err := newKey(keyID, keyBytes)
+		if err != nil {
+			return fmt.Errorf("failed to initialize key '%s': %w", keyID, err)
+		}
+		loaded[keyID] = key
+	}
+
+	return g.updateKeys(func(next *keySet) error {
+		for keyID, key := range next.keys {
+			if _, exists := loaded[keyID]; !exists && key.wrapped == nil && key.hpke == nil {
+				delete(next.keys, keyID)
+			}
+		}
+		for keyID, key := range loaded {
+			existing, exists := next.keys[keyID]
+			switch {
+			case !exists:
+				next.keys[keyID] = key
+			case existing.wrapped != nil || existing.hpke != nil:
+				return fmt.Errorf("key ID '%s' is both a key and a provider or HPKE key", keyID)
+			case !bytes.Equal(existing.Value, key.Value):
+				return fmt.Errorf("key ID '%s' already exists with different key bytes", keyID)
+			}
+		}
+
+		if defaultKeyID != "" {
+			next.defaultKey = defaultKeyID
+		}
+		if key, exists := next.keys[next.defaultKey]; !exists && next.defaultKey != "" {
+			return fmt.Errorf("default key ID '%s' not found in keys", next.defaultKey)
+		} else if exists && key.DecryptOnly {
+			return fmt.Errorf("default key ID '%s' cannot be decrypt-only", next.defaultKey)
+		}
+		for _, keyID := range []string{g.blindIndexKey, g.cipherSweetKeyID} {
+			if _, exists := next.keys[keyID]; !exists && keyID != "" {
+				return fmt.Errorf("key ID '%s' is in use and cannot be removed", keyID)
+			}
+		}
+		return nil
+	})
+}
+
+// IsDecryptOnly reports whether keyID may only be used to decrypt
+func (g *GovaultDB) IsDecryptOnly(keyID string) bool {
+	key, exists := g.keyEntry(keyID)
+	return exists && key.DecryptOnly
+}
+
+// keyEntry returns the key with the given ID, without unwrapping it
+func (g *GovaultDB) keyEntry(keyID string) (*Key, bool) {
+	key, exists := g.keySet.Load().keys[keyID]
+	return key, exists
+}
+
+// lookupKey returns the key with the given ID, unwrapping provider keys
+// without a deadline unless readyKeys unwrapped them first
+func (g *GovaultDB) lookupKey(keyID string) (*Key, error) {
+	key, exists := g.keyEntry(keyID)
+	if !exists {
+		return nil, fmt.Errorf("encryption key '%s' not found", keyID)
+	}
+	return g.unwrapKey(context.Background(), key)
+}
+
+// encryptionKey returns the key to encrypt with; empty keyID means the
+// default key. Decrypt-only keys are rejected.
+func (g *GovaultDB) encryptionKey(keyID string) (*Key, error) {
+	set := g.keySet.Load()
+	if keyID == "" {
+		keyID = set.defaultKey
+	}
+	key, exists := set.keys[keyID]
+	if !exists {
+		return nil, fmt.Errorf("encryption key '%s' not found", keyID)
+	}
+	if key.DecryptOnly {
+		return nil, fmt.Errorf("encryption key '%s' is decrypt-only", keyID)
+	}
+	if err := g.allowKey(keyID); err != nil {
+		return nil, err
+	}
+	return g.unwrapKey(context.Background(), key)
+}
+
+// unwrapKey returns key ready for use. Provider keys are unwrapped once and
+// replaced by their unwrapped form.
+func (g *GovaultDB) unwrapKey(ctx context.Context, key *Key) (*Key, error) {
+	if key.wrapped == nil || key.cipher != nil {
+		return key, nil
+	}
+
+	value, err := key.wrapped.unwrap(ctx, key.ID)
+	if err != nil {
+		return nil, err
+	}
+	unwrapped, err := newKey(key.ID, value)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize provider key '%s': %w", key.ID, err)
+	}
+
+	unwrapped.wrapped = key.wrapped
+	result := unwrapped
+	_ = g.updateKeys(func(next *keySet) error {
+		current, exists := next.keys[key.ID]
+		if !exists || current.wrapped != key.wrapped {
+			return errKeyReplaced
+		}
+		if current.cipher != nil {
+			result = current
+			return errKeyReplaced
+		}
+		// Keep flags changed since key was read
+		unwrapped.DecryptOnly = current.DecryptOnly
+		next.keys[key.ID] = unwrapped
+		return nil
+	})
+	return result, nil
+}
+
+// readyKeys unwraps the provider keys among keyIDs with ctx, so that the
+// operation using them next finds them unwrapped instead of calling the
+// provider without the caller's deadline. Unknown key IDs are left for that
+// operation to report.
+func (g *GovaultDB) readyKeys(ctx context.Context, keyIDs ...string) error {
+	for _, keyID := range keyIDs {
+		if key, exists := g.keyEntry(keyID); exists {
+			if _, err := g.unwrapKey(ctx, key); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// errKeyReplaced leaves the key set unchanged when an unwrapped key is
+// stale or was unwrapped concurrently
+var errKeyReplaced = errors.New("key replaced")
+
+// SetDefaultKey makes keyID the key used when none is specified. It is
+// safe to call while other goroutines encrypt.
+func (g *GovaultDB) SetDefaultKey(keyID string) error {
+	return g.updateKeys(func(next *keySet) error {
+		key, exists := next.keys[keyID]
+		if !exists {
+			return fmt.Errorf("default key ID '%s' not found in keys", keyID)
+		}
+		if key.DecryptOnly {
+			return fmt.Errorf("default key ID '%s' cannot be decrypt-only", keyID)
+		}
+		next.defaultKey = keyID
+		return nil
+	})
+}
+
+// setDecryptOnly marks keyID as decrypt-only or allows it to encrypt again.
+// Keys are replaced, not mutated, so readers holding the old *Key are safe.
+func (g *GovaultDB) setDecryptOnly(keyID string, decryptOnly bool) error {
+	return g.updateKeys(func(next *keySet) error {
+		key, exists := next.keys[keyID]
+		if !exists {
+			return fmt.Errorf("key ID '%s' not found in keys", keyID)
+		}
+		if decryptOnly && keyID == next.defaultKey {
+			return fmt.Errorf("default key ID '%s' cannot be decrypt-only", keyID)
+		}
+
+		updated := *key
+		updated.DecryptOnly = decryptOnly
+		next.keys[keyID] = &updated
+		return nil
+	})
 }
 
 // GetKeyIDFromEncryptedData extracts key_id from encrypted data
@@ -119,10 +601,25 @@ func (g *GovaultDB) GetKeyIDFromEncryptedData(encryptedData string) (string, err
 		return "", nil
 	}
 
-	parts := strings.SplitN(encryptedData, "|", 2)
-	if len(parts) < 1 {
-		return "", fmt.Errorf("invalid encrypted data format")
+	if strings.HasPrefix(encryptedData, HPKEFormatPrefix) {
+		v, err := parseHPKE(encryptedData)
+		if err != nil {
+			return "", err
+		}
+		return v.keyID, nil
 	}
+	env, err := parseEnvelope(encryptedData)
+	if err != nil {
+		return "", err
+	}
+	return env.keyID, nil
+}
 
-	return parts[0], nil
+// warn logs a warning to the configured logger
+func (g *GovaultDB) warn(msg string, args ...any) {
+	logger := g.logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+	logger.Warn(msg, args...)
 }
@@ -0,0 +1,120 @@
+package internal
+
+import "strings"
+
+// CiphertextDiff compares two ciphertexts of the same logical field, e.g.
+// the values of a column in two rows expected to match, for debugging
+type CiphertextDiff struct {
+	A *CiphertextInfo `json:"a,omitempty"`
+	B *CiphertextInfo `json:"b,omitempty"`
+	// AError and BError tell why a value could not be inspected or
+	// decrypted
+	AError string `json:"a_error,omitempty"`
+	BError string `json:"b_error,omitempty"`
+
+	// SameFormat, SameKey and SameAlgorithm compare the headers. They are
+	// false when either value can't be inspected.
+	SameFormat    bool `json:"same_format"`
+	SameKey       bool `json:"same_key"`
+	SameAlgorithm bool `json:"same_algorithm"`
+
+	// Decrypted is set when both values were decrypted. Equal then tells
+	// whether the plaintexts match, and Diff shows how they differ, as the
+	// common prefix and suffix around [-removed-]{+added+} text. Diff holds
+	// plaintext: keep it out of logs and tickets.
+	Decrypted bool   `json:"decrypted"`
+	Equal     bool   `json:"equal"`
+	Diff      string `json:"diff,omitempty"`
+}
+
+// DiffCiphertexts compares the headers of two ciphertexts without keys,
+// see GovaultDB.DiffCiphertexts
+func DiffCiphertexts(a, b string) *CiphertextDiff {
+	diff := &CiphertextDiff{}
+	var err error
+	if diff.A, err = InspectCiphertext(a); err != nil {
+		diff.AError = err.Error()
+	}
+	if diff.B, err = InspectCiphertext(b); err != nil {
+		diff.BError = err.Error()
+	}
+	diff.compareHeaders()
+	return diff
+}
+
+// DiffCiphertexts compares the headers of two ciphertexts, including the
+// foreign formats of Config.EnvelopeCodecs, and decrypts both to compare
+// their plaintexts when the keys are available
+func (g *GovaultDB) DiffCiphertexts(a, b string) *CiphertextDiff {
+	diff := &CiphertextDiff{}
+	diff.A, diff.AError = g.inspectHeader(a)
+	diff.B, diff.BError = g.inspectHeader(b)
+	diff.compareHeaders()
+	if diff.AError != "" || diff.BError != "" {
+		return diff
+	}
+
+	plainA, err := g.DecryptStrict(a)
+	if err != nil {
+		diff.AError = err.Error()
+	}
+	plainB, err := g.DecryptStrict(b)
+	if err != nil {
+		diff.BError = err.Error()
+	}
+	if diff.AError != "" || diff.BError != "" {
+		return diff
+	}
+	diff.Decrypted = true
+	diff.Equal = plainA == plainB
+	if !diff.Equal {
+		diff.Diff = plaintextDiff(plainA, plainB)
+	}
+	return diff
+}
+
+// inspectHeader is GovaultDB.InspectCiphertext with the error as a string,
+// the header alone for values the keys can't open
+func (g *GovaultDB) inspectHeader(value string) (*CiphertextInfo, string) {
+	info, err := g.InspectCiphertext(value)
+	if err != nil {
+		return nil, err.Error()
+	}
+	return info, info.Error
+}
+
+// compareHeaders sets the Same fields from A and B
+func (d *CiphertextDiff) compareHeaders() {
+	if d.A == nil || d.B == nil {
+		return
+	}
+	d.SameFormat = d.A.Format == d.B.Format && d.A.Version == d.B.Version
+	d.SameKey = d.A.KeyID == d.B.KeyID
+	d.SameAlgorithm = d.A.Algorithm == d.B.Algorithm
+}
+
+// plaintextDiff returns a and b as their common prefix and suffix around
+// the differing middle, [-from a-]{+from b+}
+func plaintextDiff(a, b string) string {
+	ra, rb := []rune(a), []rune(b)
+	prefix := 0
+	for prefix < len(ra) && prefix < len(rb) && ra[prefix] == rb[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(ra)-prefix && suffix < len(rb)-prefix &&
+		ra[len(ra)-1-suffix] == rb[len(rb)-1-suffix] {
+		suffix++
+	}
+
+	var out strings.Builder
+	out.WriteString(string(ra[:prefix]))
+	if removed := ra[prefix : len(ra)-suffix]; len(removed) > 0 {
+		out.WriteString("[-" + string(removed) + "-]")
+	}
+	if added := rb[prefix : len(rb)-suffix]; len(added) > 0 {
+		out.WriteString("{+" + string(added) + "+}")
+	}
+	out.WriteString(string(ra[len(ra)-suffix:]))
+	return out.String()
+}
@@ -0,0 +1,81 @@
+package internal_test
+
+import (
+	"testing"
+
+	"github.com/muhammadluth/govault/internal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiffCiphertexts(t *testing.T) {
+	g, err := internal.New(internal.Config{
+		Keys: map[string][]byte{
+			"1": []byte("727d37a0-a5f2-4d67-af47-83039c8e"),
+			"2": []byte("a3c1e2f4-5b6d-4e8f-9a0b-1c2d3e4f"),
+		},
+		DefaultKeyID: "1",
+	})
+	require.NoError(t, err)
+	encrypt := func(plaintext, keyID string) string {
+		t.Helper()
+		ciphertext, err := g.Encrypt(plaintext, keyID)
+		require.NoError(t, err)
+		return ciphertext
+	}
+
+	t.Run("same plaintext", func(t *testing.T) {
+		diff := g.DiffCiphertexts(encrypt("john@example.com", "1"), encrypt("john@example.com", "1"))
+		assert.True(t, diff.SameFormat)
+		assert.True(t, diff.SameKey)
+		assert.True(t, diff.SameAlgorithm)
+		assert.True(t, diff.Decrypted)
+		assert.True(t, diff.Equal)
+		assert.Empty(t, diff.Diff)
+	})
+
+	t.Run("different key and plaintext", func(t *testing.T) {
+		diff := g.DiffCiphertexts(encrypt("john@example.com", "1"), encrypt("john@exämple.co", "2"))
+		assert.True(t, diff.SameFormat)
+		assert.False(t, diff.SameKey)
+		assert.Equal(t, "1", diff.A.KeyID)
+		assert.Equal(t, "2", diff.B.KeyID)
+		assert.True(t, diff.Decrypted)
+		assert.False(t, diff.Equal)
+		assert.Equal(t, "john@ex[-ample.com-]{+ämple.co+}", diff.Diff)
+	})
+
+	t.Run("plaintext diffs", func(t *testing.T) {
+		diff := g.DiffCiphertexts(encrypt("0812-3456", "1"), encrypt("08123456", "1"))
+		assert.Equal(t, "0812[---]3456", diff.Diff)
+		diff = g.DiffCiphertexts(encrypt("Jane", "1"), encrypt("Jane Doe", "1"))
+		assert.Equal(t, "Jane{+ Doe+}", diff.Diff)
+	})
+
+	t.Run("values that don't decrypt", func(t *testing.T) {
+		other, err := internal.New(internal.Config{
+			Keys:         map[string][]byte{"1": []byte("0123456789abcdef0123456789abcdef")},
+			DefaultKeyID: "1",
+		})
+		require.NoError(t, err)
+		foreign, err := other.Encrypt("john@example.com")
+		require.NoError(t, err)
+
+		diff := g.DiffCiphertexts(encrypt("john@example.com", "1"), foreign)
+		assert.True(t, diff.SameKey, "the headers alone match")
+		assert.False(t, diff.Decrypted)
+		assert.Empty(t, diff.AError)
+		assert.NotEmpty(t, diff.BError)
+
+		diff = g.DiffCiphertexts("plain", foreign)
+		assert.NotEmpty(t, diff.AError)
+		assert.False(t, diff.SameKey)
+	})
+
+	t.Run("headers only", func(t *testing.T) {
+		diff := internal.DiffCiphertexts(encrypt("a", "1"), encrypt("b", "2"))
+		assert.True(t, diff.SameFormat)
+		assert.False(t, diff.SameKey)
+		assert.False(t, diff.Decrypted)
+	})
+}
@@ -0,0 +1,75 @@
+package internal
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// emailDomainDomain separates email domain keys from blind index keys
+const emailDomainDomain = "govault email domain"
+
+// SplitEmail returns the local part and the domain of an email address,
+// split at its last @
+func SplitEmail(address string) (local, domain string, err error) {
+	i := strings.LastIndex(address, "@")
+	if i <= 0 || i == len(address)-1 {
+		return "", "", fmt.Errorf("value of an email field is not an email address")
+	}
+	return address[:i], address[i+1:], nil
+}
+
+// EmailDomain returns the value of the domain column of an email field
+// for domain, stored in the column named by its emaildomain tag:
+//
+//	Email       string `bun:"email" encrypted:"true" emaildomain:"email_domain"`
+//	EmailDomain string `bun:"email_domain"`
+//
+// The domain is lowercased and kept as is, or with emaildomainhash:"true"
+// replaced by its keyed hash, a subkey of the blind index key bound to
+// the table and column. Plain domains serve analytics and routing, hashed
+// ones only group and match addresses by domain. The encrypted column
+// holds the whole address, so reads never depend on the domain column.
+func (g *GovaultDB) EmailDomain(field *FieldSpec, table, domain string) (string, error) {
+	if field.EmailDomain == "" {
+		return "", fmt.Errorf("field %s has no email domain", field.Name)
+	}
+	domain = strings.ToLower(strings.TrimSpace(domain))
+	if !field.EmailDomainHash {
+		return domain, nil
+	}
+
+	subKey, err := g.blindIndexSubKey(emailDomainDomain, table, field.Column)
+	if err != nil {
+		return "", err
+	}
+	mac := hmac.New(sha256.New, subKey)
+	mac.Write([]byte(domain))
+	return hex.EncodeToString(mac.Sum(nil)), nil
+}
+
+// setEmailDomain writes the domain of plaintext, an email address, into
+// the companion column
+func (g *GovaultDB) setEmailDomain(val reflect.Value, spec *ModelSpec, field *FieldSpec, plaintext string) error {
+	target, err := companionField(val, spec, field.EmailDomain, "email domain")
+	if err != nil {
+		return err
+	}
+	if plaintext == "" {
+		target.SetString("")
+		return nil
+	}
+	_, domain, err := SplitEmail(plaintext)
+	if err != nil {
+		return err
+	}
+	value, err := g.EmailDomain(field, spec.Table, domain)
+	if err != nil {
+		return err
+	}
+	target.SetString(value)
+	return nil
+}
@@ -0,0 +1,61 @@
+package internal_test
+
+import (
+	"testing"
+
+	"github.com/muhammadluth/govault/internal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type emailCustomer struct {
+	ID          int64  `bun:"id,pk"`
+	Email       string `bun:"email" encrypted:"true" emaildomain:"email_domain"`
+	EmailDomain string `bun:"email_domain"`
+	Backup      string `bun:"backup" encrypted:"true" emaildomain:"backup_domain" emaildomainhash:"true"`
+	BackupHash  string `bun:"backup_domain"`
+}
+
+func TestEmailDomain(t *testing.T) {
+	g, err := internal.New(internal.Config{
+		Keys:         map[string][]byte{"1": []byte("727d37a0-a5f2-4d67-af47-83039c8e")},
+		DefaultKeyID: "1",
+	})
+	require.NoError(t, err)
+
+	t.Run("domains are kept or hashed", func(t *testing.T) {
+		c := &emailCustomer{Email: "Ada@Example.COM", Backup: "ada@example.com"}
+		require.NoError(t, g.EncryptModel(c, ""))
+		assert.True(t, internal.IsEncrypted(c.Email))
+		assert.Equal(t, "example.com", c.EmailDomain)
+		assert.Len(t, c.BackupHash, 64)
+		assert.NotContains(t, c.BackupHash, "example")
+
+		other := &emailCustomer{Backup: "bob@EXAMPLE.com"}
+		require.NoError(t, g.EncryptModel(other, ""))
+		assert.Equal(t, c.BackupHash, other.BackupHash)
+
+		require.NoError(t, g.DecryptRecursive(c))
+		assert.Equal(t, "Ada@Example.COM", c.Email, "the whole address is encrypted")
+	})
+
+	t.Run("query values match the column", func(t *testing.T) {
+		c := &emailCustomer{Backup: "ada@example.com"}
+		require.NoError(t, g.EncryptModel(c, ""))
+		spec := internal.GetModelSpec(c)
+		value, err := g.EmailDomain(spec.Field("Backup"), spec.Table, " Example.com")
+		require.NoError(t, err)
+		assert.Equal(t, c.BackupHash, value)
+	})
+
+	t.Run("invalid addresses are rejected", func(t *testing.T) {
+		for _, address := range []string{"ada", "@example.com", "ada@"} {
+			err := g.EncryptModel(&emailCustomer{Email: address}, "")
+			assert.ErrorContains(t, err, "not an email address", address)
+		}
+		local, domain, err := internal.SplitEmail(`"a@b"@example.com`)
+		require.NoError(t, err)
+		assert.Equal(t, `"a@b"`, local)
+		assert.Equal(t, "example.com", domain)
+	})
+}
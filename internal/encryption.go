@@ -1,91 +1,318 @@
 package internal
 
 import (
-	"crypto/rand"
-	"encoding/base64"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"errors"
 	"fmt"
+	"io"
 	"reflect"
 	"strings"
+	"time"
+
+	"golang.org/x/crypto/hkdf"
 )
 
 // Encrypt encrypts plaintext with the specified key (or default if not specified)
 func (g *GovaultDB) Encrypt(plaintext string, keyID ...string) (string, error) {
-	if plaintext == "" {
-		return "", nil
+	var targetKeyID string
+	if len(keyID) > 0 {
+		targetKeyID = keyID[0]
 	}
+	return g.encrypt(plaintext, targetKeyID, "", g.paddingFor(nil))
+}
 
-	// Determine which key to use
-	targetKeyID := g.defaultKey
+// EncryptContext is Encrypt unwrapping the provider key with ctx
+func (g *GovaultDB) EncryptContext(ctx context.Context, plaintext string, keyID ...string) (string, error) {
+	targetKeyID := g.GetDefaultKeyID()
 	if len(keyID) > 0 && keyID[0] != "" {
 		targetKeyID = keyID[0]
 	}
+	if err := g.readyKeys(ctx, targetKeyID); err != nil {
+		return "", err
+	}
+	return g.Encrypt(plaintext, keyID...)
+}
+
+// encrypt encrypts plaintext with keyID and algorithm, padded to buckets
+// unless nil
+func (g *GovaultDB) encrypt(plaintext, keyID string, algorithm Algorithm, buckets []int) (string, error) {
+	if err := g.allowEncrypt(); err != nil {
+		return "", err
+	}
+
+	if plaintext == "" && !g.encryptEmpty {
+		return "", nil
+	}
 
-	key, exists := g.keys[targetKeyID]
-	if !exists {
-		return "", fmt.Errorf("encryption key '%s' not found", targetKeyID)
+	key, err := g.encryptionKey(keyID)
+	if err != nil {
+		return "", err
+	}
+	data := []byte(plaintext)
+	if buckets != nil {
+		data = pad(data, buckets)
+	}
+	if key.hpke != nil {
+		return sealHPKE(key, data, buckets != nil)
+	}
+	aead, err := key.aead(algorithm)
+	if err != nil {
+		return "", err
 	}
 
 	// Generate nonce
-	nonce := make([]byte, key.cipher.NonceSize())
-	if _, err := rand.Read(nonce); err != nil {
-		return "", fmt.Errorf("failed to generate nonce: %w", err)
+	nonce, err := g.newNonce(aead.NonceSize())
+	if err != nil {
+		return "", err
+	}
+
+	// Format: gv1:key_id|nonce|encrypted_data
+	env := &envelope{
+		keyID:     key.ID,
+		nonce:     nonce,
+		padded:    buckets != nil,
+		algorithm: algorithm,
+	}
+	if g.embedEncryptedAt {
+		env.encryptedAt = time.Now().Unix()
 	}
 
 	// Encrypt
-	ciphertext := key.cipher.Seal(nil, nonce, []byte(plaintext), nil)
+	env.ciphertext = aead.Seal(nil, nonce, data, env.aad())
+	if err := g.RecordNonce(key.ID, nonce, env.ciphertext); err != nil {
+		return "", err
+	}
+	return env.String(), nil
+}
 
-	// Encode to base64
-	nonceB64 := base64.StdEncoding.EncodeToString(nonce)
-	ciphertextB64 := base64.StdEncoding.EncodeToString(ciphertext)
+// paddingFor returns the buckets values of field are padded to: the
+// encryptpad tag, or paddingBlockSize with EncryptEmptyStrings
+func (g *GovaultDB) paddingFor(field *FieldSpec) []int {
+	if field != nil && len(field.PadBuckets) > 0 {
+		return field.PadBuckets
+	}
+	if g.encryptEmpty {
+		return []int{paddingBlockSize}
+	}
+	return nil
+}
+
+// deterministicDomain separates the synthetic nonce key from the encryption key
+const deterministicDomain = "govault deterministic nonce"
 
-	// Format: key_id|nonce|encrypted_data
-	return fmt.Sprintf("%s|%s|%s", targetKeyID, nonceB64, ciphertextB64), nil
+// encryptDeterministic encrypts the values of deterministic fields, so that
+// the same plaintext and key always produce the same ciphertext, which
+// allows equality lookups and unique indexes on the encrypted column. The
+// nonce is derived from an HMAC of the plaintext, so this leaks equality
+// between rows and nothing else. Values are padded to buckets unless nil.
+func (g *GovaultDB) encryptDeterministic(plaintext, keyID string, algorithm Algorithm, buckets []int) (string, error) {
+	if err := g.allowEncrypt(); err != nil {
+		return "", err
+	}
+
+	if plaintext == "" && !g.encryptEmpty {
+		return "", nil
+	}
+
+	key, err := g.encryptionKey(keyID)
+	if err != nil {
+		return "", err
+	}
+	if key.hpke != nil {
+		return "", fmt.Errorf("deterministic encryption requires a symmetric key, '%s' is an HPKE key", key.ID)
+	}
+	aead, err := key.aead(algorithm)
+	if err != nil {
+		return "", err
+	}
+
+	nonce, err := deterministicNonce(key, plaintext, aead.NonceSize())
+	if err != nil {
+		return "", err
+	}
+
+	data := []byte(plaintext)
+	if buckets != nil {
+		data = pad(data, buckets)
+	}
+	env := &envelope{
+		keyID:     key.ID,
+		nonce:     nonce,
+		padded:    buckets != nil,
+		algorithm: algorithm,
+	}
+	env.ciphertext = aead.Seal(nil, nonce, data, env.aad())
+	if err := g.RecordNonce(key.ID, nonce, env.ciphertext); err != nil {
+		return "", err
+	}
+	return env.String(), nil
+}
+
+// deterministicNonce returns the synthetic nonce of plaintext under key: an
+// HMAC of the plaintext with a key derived from key, truncated to size
+func deterministicNonce(key *Key, plaintext string, size int) ([]byte, error) {
+	nonceKey := make([]byte, 32)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, key.Value, nil, []byte(deterministicDomain)), nonceKey); err != nil {
+		return nil, fmt.Errorf("failed to derive nonce key: %w", err)
+	}
+	mac := hmac.New(sha256.New, nonceKey)
+	mac.Write([]byte(plaintext))
+	return mac.Sum(nil)[:size], nil
+}
+
+// DecryptContext is Decrypt unwrapping the provider key of encryptedData
+// with ctx
+func (g *GovaultDB) DecryptContext(ctx context.Context, encryptedData string) (string, error) {
+	if err := g.readyKeys(ctx, g.decryptKeyIDs(nil, encryptedData)...); err != nil {
+		if fallback, ok := g.fallbackValue(encryptedData, err); ok {
+			return fallback, nil
+		}
+		return "", err
+	}
+	return g.Decrypt(encryptedData)
+}
+
+// decryptKeyIDs returns the IDs of the keys decrypting value, a ciphertext
+// of field, or nil when value is not one
+func (g *GovaultDB) decryptKeyIDs(field *FieldSpec, value string) []string {
+	if field != nil && field.Codec != "" {
+		codec, err := lookupCodec(field.Codec)
+		if err != nil || !codec.IsEncrypted(value) {
+			return nil
+		}
+		return codec.KeyIDs(g, value)
+	}
+	if env, ok := g.parseForeign(field, value); ok {
+		return []string{env.KeyID}
+	}
+	if keyID, err := g.GetKeyIDFromEncryptedData(value); err == nil && keyID != "" {
+		return []string{keyID}
+	}
+	return nil
 }
 
-// Decrypt decrypts ciphertext using the key specified in the data
+// Decrypt decrypts ciphertext using the key specified in the data. When
+// the key is unavailable, the configured Fallback applies: encryptedData is
+// returned as is with FallbackCiphertext and masked with FallbackMask.
 func (g *GovaultDB) Decrypt(encryptedData string) (string, error) {
+	plaintext, err := g.DecryptStrict(encryptedData)
+	if fallback, ok := g.fallbackValue(encryptedData, err); ok {
+		return fallback, nil
+	}
+	return plaintext, err
+}
+
+// DecryptStrict is Decrypt without the Fallback, for callers writing the
+// plaintext back, e.g. to re-encrypt it, which must never store a
+// ciphertext or a mask as the plaintext
+func (g *GovaultDB) DecryptStrict(encryptedData string) (string, error) {
+	if err := g.allowDecrypt(); err != nil {
+		return "", err
+	}
+
 	if encryptedData == "" {
 		return "", nil
 	}
 
-	// Parse format: key_id|nonce|encrypted_data
-	parts := strings.SplitN(encryptedData, "|", 3)
-	if len(parts) != 3 {
-		return "", fmt.Errorf("invalid encrypted data format")
+	if strings.HasPrefix(encryptedData, HPKEFormatPrefix) {
+		return g.openHPKE(encryptedData)
+	}
+	if env, ok := g.parseForeign(nil, encryptedData); ok {
+		return g.decryptEnvelope(env)
 	}
 
-	keyID := parts[0]
-	nonceB64 := parts[1]
-	ciphertextB64 := parts[2]
+	// Parse format: gv1:key_id|nonce|encrypted_data (prefix optional for legacy data)
+	env, err := parseEnvelope(encryptedData)
+	if err != nil {
+		return "", err
+	}
 
 	// Get key
-	key, exists := g.keys[keyID]
-	if !exists {
-		return "", fmt.Errorf("encryption key '%s' not found. Available: %v", keyID, g.GetKeyIDs())
+	key, err := g.lookupDecryptKey(env.keyID)
+	if err != nil {
+		return "", err
+	}
+	return openEnvelope(env, key)
+}
+
+// DecryptWithKey decrypts ciphertext with keyID, ignoring the key ID it
+// embeds. It recovers data written under a wrong key label but with the
+// right key material. Every call is logged as a warning to Config.Logger.
+func (g *GovaultDB) DecryptWithKey(encryptedData, keyID string) (string, error) {
+	if err := g.allowDecrypt(); err != nil {
+		return "", err
+	}
+	if keyID == "" {
+		return "", fmt.Errorf("key ID is required")
+	}
+
+	if encryptedData == "" {
+		return "", nil
 	}
 
-	// Decode from base64
-	nonce, err := base64.StdEncoding.DecodeString(nonceB64)
+	if strings.HasPrefix(encryptedData, HPKEFormatPrefix) {
+		return "", fmt.Errorf("HPKE ciphertexts cannot be decrypted with another key")
+	}
+
+	env, err := parseEnvelope(encryptedData)
 	if err != nil {
-		return "", fmt.Errorf("failed to decode nonce: %w", err)
+		return "", err
 	}
 
-	ciphertext, err := base64.StdEncoding.DecodeString(ciphertextB64)
+	key, err := g.lookupDecryptKey(keyID)
 	if err != nil {
-		return "", fmt.Errorf("failed to decode ciphertext: %w", err)
+		return "", err
+	}
+	plaintext, err := openEnvelope(env, key)
+	g.warn("govault: decrypted with a forced key",
+		"key_id", key.ID,
+		"labeled_key_id", env.keyID,
+		"ok", err == nil)
+	return plaintext, err
+}
+
+// openEnvelope decrypts env with key, using the algorithm of env
+func openEnvelope(env *envelope, key *Key) (string, error) {
+	aead, err := key.aead(env.algorithm)
+	if err != nil {
+		return "", err
+	}
+
+	if len(env.nonce) != aead.NonceSize() {
+		return "", &FormatError{Part: "nonce", Reason: fmt.Sprintf("is %d bytes, want %d", len(env.nonce), aead.NonceSize())}
 	}
 
 	// Decrypt
-	plaintext, err := key.cipher.Open(nil, nonce, ciphertext, nil)
+	plaintext, err := aead.Open(nil, env.nonce, env.ciphertext, env.aad())
 	if err != nil {
 		return "", fmt.Errorf("failed to decrypt: %w", err)
 	}
+	if env.padded {
+		if plaintext, err = unpad(plaintext); err != nil {
+			return "", err
+		}
+	}
 
 	return string(plaintext), nil
 }
 
 // DecryptRecursive handles decryption recursively
 func (g *GovaultDB) DecryptRecursive(value interface{}) error {
+	return g.decryptRecursive(context.Background(), value, nil, "", nil)
+}
+
+// DecryptRecursiveContext is DecryptRecursive applying the transforms of the
+// role set on ctx with WithTransformRole and the budget set with
+// WithDecryptBudget. Provider keys are unwrapped with ctx.
+func (g *GovaultDB) DecryptRecursiveContext(ctx context.Context, value interface{}) error {
+	return g.decryptRecursive(ctx, value, nil, TransformRole(ctx), decryptBudgetOf(ctx))
+}
+
+// decryptRecursive decrypts value with spec, the spec of a nested model,
+// or the spec of its type when nil
+func (g *GovaultDB) decryptRecursive(ctx context.Context, value interface{}, spec *ModelSpec, role string, budget *decryptBudget) error {
 	if value == nil {
 		return nil
 	}
@@ -105,14 +332,14 @@ func (g *GovaultDB) DecryptRecursive(value interface{}) error {
 			if elem.Kind() == reflect.Ptr {
 				// Recurse into ptr element
 				if !elem.IsNil() {
-					if err := g.DecryptRecursive(elem.Interface()); err != nil {
+					if err := g.decryptRecursive(ctx, elem.Interface(), spec, role, budget); err != nil {
 						return err
 					}
 				}
 			} else if elem.Kind() == reflect.Struct {
 				// If strictly a struct, check if addressable
 				if elem.CanAddr() {
-					if err := g.DecryptRecursive(elem.Addr().Interface()); err != nil {
+					if err := g.decryptRecursive(ctx, elem.Addr().Interface(), spec, role, budget); err != nil {
 						return err
 					}
 				}
@@ -124,6 +351,9 @@ func (g *GovaultDB) DecryptRecursive(value interface{}) error {
 	// Handle single struct
 	if val.Kind() == reflect.Struct {
 		typ := val.Type()
+		if spec == nil || spec.Type != typ {
+			spec = g.modelSpecOf(typ)
+		}
 		for i := 0; i < val.NumField(); i++ {
 			field := val.Field(i)
 			fieldType := typ.Field(i)
@@ -132,40 +362,44 @@ func (g *GovaultDB) DecryptRecursive(value interface{}) error {
 				continue
 			}
 
-			// Decrypt if tagged
-			if fieldType.Tag.Get("encrypted") == "true" {
-				if field.Kind() == reflect.String {
-					ciphertext := field.String()
-					if ciphertext != "" && strings.Contains(ciphertext, "|") {
-						decrypted, err := g.Decrypt(ciphertext)
-						if err != nil {
-							return fmt.Errorf("failed to decrypt field %s: %w", fieldType.Name, err)
-						}
+			// Decrypt the fields of the spec, tagged or declared
+			// by Config.Fields
+			if fieldSpec := spec.Field(fieldType.Name); fieldSpec != nil || fieldType.Tag.Get("encrypted") == "true" {
+				switch {
+				case field.Kind() == reflect.String:
+					decrypted, ok, err := g.decryptString(ctx, fieldSpec, spec.Table, fieldType.Name, field.String(), role, budget)
+					if err != nil {
+						return err
+					}
+					if ok {
 						field.SetString(decrypted)
 					}
+				case fieldSpec != nil && fieldSpec.Array != "":
+					if err := g.decryptArray(ctx, field, fieldSpec, spec.Table, role, budget); err != nil {
+						return err
+					}
 				}
 			} else {
 				// Recurse for nested structs/slices
+				nested := spec.nestedSpec(i)
 				if field.Kind() == reflect.Struct {
 					if field.CanAddr() {
-						if err := g.DecryptRecursive(field.Addr().Interface()); err != nil {
+						if err := g.decryptRecursive(ctx, field.Addr().Interface(), nested, role, budget); err != nil {
 							return err
 						}
 					}
 				} else if field.Kind() == reflect.Ptr {
 					if !field.IsNil() {
-						if err := g.DecryptRecursive(field.Interface()); err != nil {
+						if err := g.decryptRecursive(ctx, field.Interface(), nested, role, budget); err != nil {
 							return err
 						}
 					}
 				} else if field.Kind() == reflect.Slice {
 					// We need to pass the slice itself
 					if field.CanAddr() {
-						if err := g.DecryptRecursive(field.Addr().Interface()); err != nil {
+						if err := g.decryptRecursive(ctx, field.Addr().Interface(), nested, role, budget); err != nil {
 							return err
 						}
-					} else {
-						// Slice field value is a slice header, we can index it directly?
 					}
 				}
 			}
@@ -174,3 +408,340 @@ func (g *GovaultDB) DecryptRecursive(value interface{}) error {
 
 	return nil
 }
+
+// decryptString decrypts the value of the field named name for
+// DecryptRecursive, spending budget and applying the transform of role. It
+// reports false when the value is left as is.
+func (g *GovaultDB) decryptString(ctx context.Context, fieldSpec *FieldSpec, table, name, value, role string, budget *decryptBudget) (string, bool, error) {
+	if IsFieldEncrypted(fieldSpec, value) {
+		if err := budget.spend(len(value)); err != nil {
+			if !budget.Mask {
+				return "", false, fmt.Errorf("failed to decrypt field %s: %w", name, err)
+			}
+			return FallbackMaskValue, true, nil
+		}
+	}
+	decrypted, ok, err := g.DecryptFieldContext(ctx, fieldSpec, table, value)
+	if err != nil {
+		return "", false, fmt.Errorf("failed to decrypt field %s: %w", name, err)
+	}
+	// Plaintext values are transformed too, ciphertext
+	// left by FallbackCiphertext is not
+	if !ok && value != "" && !IsFieldEncrypted(fieldSpec, value) {
+		decrypted, ok = value, true
+	}
+	if ok && role != "" {
+		if decrypted, err = g.TransformField(fieldSpec, table, role, decrypted); err != nil {
+			return "", false, fmt.Errorf("failed to transform field %s: %w", name, err)
+		}
+	}
+	return decrypted, ok, nil
+}
+
+// IsFieldEncrypted reports whether value is a ciphertext in the format of
+// the given field
+func IsFieldEncrypted(field *FieldSpec, value string) bool {
+	if field != nil && field.Codec != "" {
+		codec, err := lookupCodec(field.Codec)
+		return err == nil && codec.IsEncrypted(value)
+	}
+	return IsEncrypted(value)
+}
+
+// DecryptField decrypts a single field value using the field's codec. It
+// reports false when the value is not a ciphertext and was left untouched.
+// Values whose key is unavailable are handled by the configured Fallback,
+// and values whose breaker is open, see FailureDetector, are masked.
+func (g *GovaultDB) DecryptField(field *FieldSpec, table, value string) (string, bool, error) {
+	return g.DecryptFieldContext(context.Background(), field, table, value)
+}
+
+// DecryptFieldContext is DecryptField unwrapping the provider keys of value
+// with ctx, so a slow provider fails with the deadline of ctx
+func (g *GovaultDB) DecryptFieldContext(ctx context.Context, field *FieldSpec, table, value string) (string, bool, error) {
+	plaintext, ok, err := g.decryptFieldDetected(ctx, field, table, value)
+	if fallback, handled := g.fallbackValue(value, err); handled {
+		if fallback == value {
+			// FallbackCiphertext leaves value untouched
+			return "", false, nil
+		}
+		return fallback, true, nil
+	}
+	return plaintext, ok, err
+}
+
+// DecryptFieldStrict is DecryptField without the Fallback and the masks
+// of the FailureDetector, for callers writing the plaintext back, e.g. to
+// re-encrypt it, or checking that values decrypt
+func (g *GovaultDB) DecryptFieldStrict(field *FieldSpec, table, value string) (string, bool, error) {
+	return g.decryptField(context.Background(), field, table, value)
+}
+
+// fallbackValue returns what reads return for value when decrypting it
+// failed with err, per the configured Fallback, and false when the
+// Fallback does not apply and err must be returned
+func (g *GovaultDB) fallbackValue(value string, err error) (string, bool) {
+	if !errors.Is(err, ErrKeyUnavailable) {
+		return "", false
+	}
+	switch g.fallback {
+	case FallbackCiphertext:
+		return value, true
+	case FallbackMask:
+		return FallbackMaskValue, true
+	}
+	return "", false
+}
+
+func (g *GovaultDB) decryptField(ctx context.Context, field *FieldSpec, table, value string) (string, bool, error) {
+	if value == "" {
+		return "", false, nil
+	}
+	if err := g.readyKeys(ctx, g.decryptKeyIDs(field, value)...); err != nil {
+		return "", false, err
+	}
+
+	if field != nil && field.Codec != "" {
+		if err := g.allowDecrypt(); err != nil {
+			return "", false, err
+		}
+		codec, err := lookupCodec(field.Codec)
+		if err != nil {
+			return "", false, err
+		}
+		if !codec.IsEncrypted(value) {
+			return "", false, nil
+		}
+		plaintext, err := codec.Decrypt(g, field, table, value)
+		return plaintext, err == nil, err
+	}
+
+	if env, ok := g.parseForeign(field, value); ok {
+		if err := g.allowDecrypt(); err != nil {
+			return "", false, err
+		}
+		plaintext, err := g.decryptEnvelope(env)
+		return plaintext, err == nil, err
+	}
+
+	if !IsEncrypted(value) {
+		return "", false, nil
+	}
+	plaintext, err := g.DecryptStrict(value)
+	return plaintext, err == nil, err
+}
+
+// EncryptModel encrypts fields tagged with encrypted:"true" in place and
+// fills their blind index columns. model is a struct or a slice of structs,
+// or a pointer to either. The tagged fields of structs embedded with
+// bun:"embed:" or stored in JSON columns are encrypted too. keyID selects
+// the key; empty means the default key.
+func (g *GovaultDB) EncryptModel(model any, keyID string) error {
+	val := reflect.ValueOf(model)
+	if val.Kind() == reflect.Ptr {
+		val = val.Elem()
+	}
+
+	if val.Kind() == reflect.Slice || val.Kind() == reflect.Array {
+		for i := 0; i < val.Len(); i++ {
+			elem := val.Index(i)
+			if elem.Kind() != reflect.Ptr {
+				if !elem.CanAddr() {
+					continue
+				}
+				elem = elem.Addr()
+			}
+			if err := g.EncryptModel(elem.Interface(), keyID); err != nil {
+				return fmt.Errorf("element %d: %w", i, err)
+			}
+		}
+		return nil
+	}
+	if val.Kind() != reflect.Struct {
+		return nil
+	}
+	return g.encryptStruct(val, g.modelSpecOf(val.Type()), keyID)
+}
+
+// EncryptModelContext is EncryptModel unwrapping the provider keys it may
+// need with ctx: keyID or the default key, the blind index key and the
+// ciphersweet key
+func (g *GovaultDB) EncryptModelContext(ctx context.Context, model any, keyID string) error {
+	targetKeyID := keyID
+	if targetKeyID == "" {
+		targetKeyID = g.GetDefaultKeyID()
+	}
+	if err := g.readyKeys(ctx, targetKeyID, g.blindIndexKey, g.cipherSweetKeyID); err != nil {
+		return err
+	}
+	return g.EncryptModel(model, keyID)
+}
+
+// encryptStruct encrypts the fields of val, a struct of spec, and of its
+// nested models
+func (g *GovaultDB) encryptStruct(val reflect.Value, spec *ModelSpec, keyID string) error {
+	for _, serialized := range spec.serialized {
+		setSerializer(val.FieldByIndex(serialized.index), serialized.serializer)
+	}
+	if err := setGeneralized(val, spec); err != nil {
+		return err
+	}
+	for _, fieldSpec := range spec.Fields {
+		field := val.FieldByIndex(fieldSpec.Index)
+		if fieldSpec.Array != "" && field.CanSet() {
+			if err := g.encryptArray(field, fieldSpec, spec.Table, keyID); err != nil {
+				return fmt.Errorf("failed to encrypt field %s: %w", fieldSpec.Name, err)
+			}
+			continue
+		}
+		if !field.CanSet() || field.Kind() != reflect.String {
+			continue
+		}
+
+		plaintext := field.String()
+		if plaintext == "" && !g.EncryptsEmpty(fieldSpec) {
+			continue
+		}
+		plaintext, err := NormalizeField(fieldSpec, plaintext)
+		if err != nil {
+			return fmt.Errorf("failed to normalize field %s: %w", fieldSpec.Name, err)
+		}
+
+		if fieldSpec.BlindIndex != "" {
+			if err := g.setBlindIndex(val, spec, fieldSpec, plaintext); err != nil {
+				return fmt.Errorf("failed to compute blind index for field %s: %w", fieldSpec.Name, err)
+			}
+		}
+		if fieldSpec.PlainHash != "" {
+			if err := g.setPlainHash(val, spec, fieldSpec, plaintext); err != nil {
+				return fmt.Errorf("failed to compute plaintext hash for field %s: %w", fieldSpec.Name, err)
+			}
+		}
+		if fieldSpec.TokenIndex != "" {
+			if err := g.setTokenIndex(val, spec, fieldSpec, plaintext); err != nil {
+				return fmt.Errorf("failed to compute token index for field %s: %w", fieldSpec.Name, err)
+			}
+		}
+		if fieldSpec.Bloom != "" {
+			if err := g.setBloom(val, spec, fieldSpec, plaintext); err != nil {
+				return fmt.Errorf("failed to compute bloom filter for field %s: %w", fieldSpec.Name, err)
+			}
+		}
+		if fieldSpec.Bucket != "" {
+			if err := g.setBucket(val, spec, fieldSpec, plaintext); err != nil {
+				return fmt.Errorf("failed to compute bucket for field %s: %w", fieldSpec.Name, err)
+			}
+		}
+		if fieldSpec.Geohash != "" {
+			if err := g.setGeohash(val, spec, fieldSpec, plaintext); err != nil {
+				return fmt.Errorf("failed to compute geohash for field %s: %w", fieldSpec.Name, err)
+			}
+		}
+		if fieldSpec.EmailDomain != "" {
+			if err := g.setEmailDomain(val, spec, fieldSpec, plaintext); err != nil {
+				return fmt.Errorf("failed to compute email domain for field %s: %w", fieldSpec.Name, err)
+			}
+		}
+		if fieldSpec.PANBIN != "" || fieldSpec.PANLast4 != "" || fieldSpec.PANToken != "" {
+			if err := g.setPANColumns(val, spec, fieldSpec, plaintext); err != nil {
+				return fmt.Errorf("failed to compute card columns for field %s: %w", fieldSpec.Name, err)
+			}
+		}
+
+		encrypted, err := g.EncryptField(fieldSpec, spec.Table, plaintext, keyID)
+		if err != nil {
+			return fmt.Errorf("failed to encrypt field %s: %w", fieldSpec.Name, err)
+		}
+		field.SetString(encrypted)
+	}
+
+	for _, nested := range spec.nested {
+		if err := g.encryptNested(val.FieldByIndex(nested.index), nested.spec, keyID); err != nil {
+			return fmt.Errorf("field %s: %w", nested.name, err)
+		}
+	}
+	return nil
+}
+
+// encryptNested encrypts the structs of spec held by v, directly or
+// through pointers, slices and arrays
+func (g *GovaultDB) encryptNested(v reflect.Value, spec *ModelSpec, keyID string) error {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			return nil
+		}
+		return g.encryptNested(v.Elem(), spec, keyID)
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			if err := g.encryptNested(v.Index(i), spec, keyID); err != nil {
+				return fmt.Errorf("element %d: %w", i, err)
+			}
+		}
+	case reflect.Struct:
+		return g.encryptStruct(v, spec, keyID)
+	}
+	return nil
+}
+
+// EncryptsEmpty reports whether empty values of field are encrypted rather
+// than stored as empty, see Config.EncryptEmptyStrings
+func (g *GovaultDB) EncryptsEmpty(field *FieldSpec) bool {
+	return g.encryptEmpty && (field == nil || !field.NullZero)
+}
+
+// EncryptField encrypts a single field value using the field's codec.
+// Codecs write a foreign format that can't carry padding, so fields with
+// both a codec and encryptpad fail.
+func (g *GovaultDB) EncryptField(field *FieldSpec, table, plaintext, keyID string) (string, error) {
+	if field.Codec != "" {
+		if err := g.allowEncrypt(); err != nil {
+			return "", err
+		}
+		if len(field.PadBuckets) > 0 {
+			return "", fmt.Errorf("codec %s does not support encryptpad", field.Codec)
+		}
+		codec, err := lookupCodec(field.Codec)
+		if err != nil {
+			return "", err
+		}
+		return codec.Encrypt(g, field, table, plaintext, keyID)
+	}
+	if err := checkAlgorithm(field.Algorithm); err != nil {
+		return "", err
+	}
+	if field.Deterministic {
+		return g.encryptDeterministic(plaintext, keyID, field.Algorithm, g.paddingFor(field))
+	}
+	return g.encrypt(plaintext, keyID, field.Algorithm, g.paddingFor(field))
+}
+
+// setBlindIndex writes the blind index of plaintext into the companion column
+func (g *GovaultDB) setBlindIndex(val reflect.Value, spec *ModelSpec, field *FieldSpec, plaintext string) error {
+	target, err := companionField(val, spec, field.BlindIndex, "blind index")
+	if err != nil {
+		return err
+	}
+
+	blindIndex, err := g.blindIndex(field, spec.Table, plaintext)
+	if err != nil {
+		return err
+	}
+	target.SetString(blindIndex)
+	return nil
+}
+
+// companionField returns the string field of the companion column of an
+// encrypted field, e.g. its blind index
+func companionField(val reflect.Value, spec *ModelSpec, column, kind string) (reflect.Value, error) {
+	index, ok := spec.ColumnIndex(column)
+	if !ok {
+		return reflect.Value{}, fmt.Errorf("%s column '%s' not found in model", kind, column)
+	}
+
+	target := val.FieldByIndex(index)
+	if !target.CanSet() || target.Kind() != reflect.String {
+		return reflect.Value{}, fmt.Errorf("%s column '%s' must be a settable string field", kind, column)
+	}
+	return target, nil
+}
@@ -0,0 +1,162 @@
+package internal
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Envelope is an AES-256-GCM ciphertext taken apart by an EnvelopeCodec
+type Envelope struct {
+	// KeyID is the govault key holding the key material
+	KeyID string
+	Nonce []byte
+	// Ciphertext is the sealed data followed by the GCM tag
+	Ciphertext []byte
+	// AAD is the additional authenticated data, if the format binds any
+	AAD []byte
+}
+
+// EnvelopeCodec parses and serializes the ciphertexts of a foreign
+// AES-256-GCM format, e.g. of an in-house legacy scheme, whose keys are
+// loaded into govault. Values read through a codec are decrypted with
+// govault's keys and written back in the native format, which migrates
+// them as rows are updated.
+type EnvelopeCodec interface {
+	// Parse takes value apart, or returns an error when it is not in the
+	// format of the codec
+	Parse(value string) (*Envelope, error)
+	// Serialize formats env, the inverse of Parse
+	Serialize(env *Envelope) (string, error)
+}
+
+// checkEnvelopeCodecs rejects codecs that would shadow the native formats
+func checkEnvelopeCodecs(codecs map[string]EnvelopeCodec) error {
+	for format, codec := range codecs {
+		if codec == nil {
+			return fmt.Errorf("envelope codec '%s' is nil", format)
+		}
+		if format == "" || strings.HasPrefix(format, FormatPrefix) || strings.HasPrefix(format, HPKEFormatPrefix) {
+			return fmt.Errorf("invalid envelope format '%s'", format)
+		}
+	}
+	return nil
+}
+
+// envelopeCodec returns the codec reading value: the codec of field when
+// its envelope tag is set, otherwise the codec whose format prefixes value.
+// Native ciphertexts have none.
+func (g *GovaultDB) envelopeCodec(field *FieldSpec, value string) (EnvelopeCodec, bool) {
+	format, ok := g.envelopeFormat(field, value)
+	if !ok {
+		return nil, false
+	}
+	return g.envelopeCodecs[format], true
+}
+
+// envelopeFormat returns the format of the codec reading value, see
+// envelopeCodec
+func (g *GovaultDB) envelopeFormat(field *FieldSpec, value string) (string, bool) {
+	if strings.HasPrefix(value, FormatPrefix) || strings.HasPrefix(value, HPKEFormatPrefix) {
+		return "", false
+	}
+	if field != nil && field.Envelope != "" {
+		_, ok := g.envelopeCodecs[field.Envelope]
+		return field.Envelope, ok
+	}
+
+	// The longest format wins when formats prefix each other
+	formats := make([]string, 0, len(g.envelopeCodecs))
+	for format := range g.envelopeCodecs {
+		if strings.HasPrefix(value, format) {
+			formats = append(formats, format)
+		}
+	}
+	if len(formats) == 0 {
+		return "", false
+	}
+	sort.Slice(formats, func(i, j int) bool { return len(formats[i]) > len(formats[j]) })
+	return formats[0], true
+}
+
+// parseForeign parses value with the envelope codec reading it. It reports
+// false for native ciphertexts and values in no registered format.
+func (g *GovaultDB) parseForeign(field *FieldSpec, value string) (*Envelope, bool) {
+	codec, ok := g.envelopeCodec(field, value)
+	if !ok {
+		return nil, false
+	}
+	env, err := codec.Parse(value)
+	return env, err == nil
+}
+
+// decryptEnvelope decrypts a parsed foreign ciphertext
+func (g *GovaultDB) decryptEnvelope(env *Envelope) (string, error) {
+	key, err := g.lookupDecryptKey(env.KeyID)
+	if err != nil {
+		return "", err
+	}
+	aead, err := envelopeCipher(key, len(env.Nonce))
+	if err != nil {
+		return "", err
+	}
+	plaintext, err := aead.Open(nil, env.Nonce, env.Ciphertext, env.AAD)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt: %w", err)
+	}
+	return string(plaintext), nil
+}
+
+// EncryptEnvelope encrypts plaintext in the named envelope format instead
+// of the native one, for writers that must keep producing the legacy
+// format during a migration. keyID selects the key; empty means the
+// default key.
+func (g *GovaultDB) EncryptEnvelope(format, plaintext, keyID string) (string, error) {
+	if err := g.allowEncrypt(); err != nil {
+		return "", err
+	}
+	codec, ok := g.envelopeCodecs[format]
+	if !ok {
+		return "", fmt.Errorf("unknown envelope format '%s'", format)
+	}
+	key, err := g.encryptionKey(keyID)
+	if err != nil {
+		return "", err
+	}
+	aead, err := envelopeCipher(key, 0)
+	if err != nil {
+		return "", err
+	}
+
+	nonce, err := g.newNonce(aead.NonceSize())
+	if err != nil {
+		return "", err
+	}
+	ciphertext := aead.Seal(nil, nonce, []byte(plaintext), nil)
+	if err := g.RecordNonce(key.ID, nonce, ciphertext); err != nil {
+		return "", err
+	}
+	return codec.Serialize(&Envelope{KeyID: key.ID, Nonce: nonce, Ciphertext: ciphertext})
+}
+
+// envelopeCipher returns the AES-GCM cipher of key for nonces of
+// nonceSize bytes, the standard size when zero
+func envelopeCipher(key *Key, nonceSize int) (cipher.AEAD, error) {
+	if key.hpke != nil {
+		return nil, fmt.Errorf("key '%s' is an HPKE key", key.ID)
+	}
+	if nonceSize == 0 || nonceSize == key.cipher.NonceSize() {
+		return key.cipher, nil
+	}
+	block, err := aes.NewCipher(key.Value)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCMWithNonceSize(block, nonceSize)
+	if err != nil {
+		return nil, fmt.Errorf("invalid nonce: %w", err)
+	}
+	return aead, nil
+}
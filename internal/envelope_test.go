@@ -0,0 +1,126 @@
+package internal_test
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/muhammadluth/govault/internal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// dollarCodec reads enc1$<key id>$<hex nonce>$<hex ciphertext>
+type dollarCodec struct{}
+
+func (dollarCodec) Parse(value string) (*internal.Envelope, error) {
+	parts := strings.Split(value, "$")
+	if len(parts) != 4 || parts[0] != "enc1" {
+		return nil, fmt.Errorf("not an enc1 value")
+	}
+	nonce, err := hex.DecodeString(parts[2])
+	if err != nil {
+		return nil, err
+	}
+	ciphertext, err := hex.DecodeString(parts[3])
+	if err != nil {
+		return nil, err
+	}
+	return &internal.Envelope{KeyID: parts[1], Nonce: nonce, Ciphertext: ciphertext}, nil
+}
+
+func (dollarCodec) Serialize(env *internal.Envelope) (string, error) {
+	return "enc1$" + env.KeyID + "$" + hex.EncodeToString(env.Nonce) + "$" + hex.EncodeToString(env.Ciphertext), nil
+}
+
+// bareCodec reads base64 of a 16 byte nonce and the ciphertext, always
+// under key 1
+type bareCodec struct{}
+
+func (bareCodec) Parse(value string) (*internal.Envelope, error) {
+	data, err := base64.StdEncoding.DecodeString(value)
+	if err != nil || len(data) < 32 {
+		return nil, fmt.Errorf("not a bare value")
+	}
+	return &internal.Envelope{KeyID: "1", Nonce: data[:16], Ciphertext: data[16:]}, nil
+}
+
+func (bareCodec) Serialize(env *internal.Envelope) (string, error) {
+	return base64.StdEncoding.EncodeToString(append(env.Nonce, env.Ciphertext...)), nil
+}
+
+type legacyUser struct {
+	Email string `bun:"email" encrypted:"true"`
+	Phone string `bun:"phone" encrypted:"true" envelope:"bare"`
+}
+
+func TestEnvelopeCodecs(t *testing.T) {
+	key := []byte("727d37a0-a5f2-4d67-af47-83039c8e")
+	g, err := internal.New(internal.Config{
+		Keys:         map[string][]byte{"1": key},
+		DefaultKeyID: "1",
+		EnvelopeCodecs: map[string]internal.EnvelopeCodec{
+			"enc1$": dollarCodec{},
+			"bare":  bareCodec{},
+		},
+	})
+	require.NoError(t, err)
+
+	legacy, err := g.EncryptEnvelope("enc1$", "ayu@example.com", "")
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(legacy, "enc1$1$"))
+
+	// A 16 byte nonce, as written by the legacy scheme
+	block, err := aes.NewCipher(key)
+	require.NoError(t, err)
+	aead, err := cipher.NewGCMWithNonceSize(block, 16)
+	require.NoError(t, err)
+	nonce := []byte("0123456789abcdef")
+	bare := base64.StdEncoding.EncodeToString(append(nonce, aead.Seal(nil, nonce, []byte("+62811110000"), nil)...))
+
+	t.Run("decrypt by format prefix", func(t *testing.T) {
+		plaintext, err := g.Decrypt(legacy)
+		require.NoError(t, err)
+		assert.Equal(t, "ayu@example.com", plaintext)
+	})
+
+	t.Run("read legacy and write native", func(t *testing.T) {
+		user := &legacyUser{Email: legacy, Phone: bare}
+		require.NoError(t, g.DecryptRecursive(user))
+		assert.Equal(t, "ayu@example.com", user.Email)
+		assert.Equal(t, "+62811110000", user.Phone)
+
+		require.NoError(t, g.EncryptModel(user, ""))
+		assert.True(t, strings.HasPrefix(user.Email, internal.FormatPrefix))
+		assert.True(t, strings.HasPrefix(user.Phone, internal.FormatPrefix))
+
+		require.NoError(t, g.DecryptRecursive(user))
+		assert.Equal(t, "ayu@example.com", user.Email)
+		assert.Equal(t, "+62811110000", user.Phone)
+	})
+
+	t.Run("values in no format are plaintext", func(t *testing.T) {
+		user := &legacyUser{Email: "plain@example.com", Phone: "not base64!"}
+		require.NoError(t, g.DecryptRecursive(user))
+		assert.Equal(t, "plain@example.com", user.Email)
+		assert.Equal(t, "not base64!", user.Phone)
+	})
+
+	t.Run("tampered value", func(t *testing.T) {
+		_, err := g.Decrypt(legacy[:len(legacy)-2] + "00")
+		assert.ErrorContains(t, err, "failed to decrypt")
+	})
+
+	t.Run("native formats cannot be shadowed", func(t *testing.T) {
+		_, err := internal.New(internal.Config{
+			Keys:           map[string][]byte{"1": key},
+			DefaultKeyID:   "1",
+			EnvelopeCodecs: map[string]internal.EnvelopeCodec{internal.FormatPrefix: dollarCodec{}},
+		})
+		assert.ErrorContains(t, err, "invalid envelope format")
+	})
+}
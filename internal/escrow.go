@@ -0,0 +1,305 @@
+package internal
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"time"
+)
+
+// escrowVersion is the version of the Escrow format
+const escrowVersion = 1
+
+// Escrow operations recorded by EscrowRecord
+const (
+	EscrowOperationExport  = "export"
+	EscrowOperationRecover = "recover"
+)
+
+// EscrowOptions configures ExportEscrow
+type EscrowOptions struct {
+	// KeyIDs are the keys exported; empty means every symmetric key
+	KeyIDs []string
+	// Approvers receive one share each
+	Approvers []string
+	// Threshold is the number of shares recovering the keys, at least 2
+	Threshold int
+	// Purpose is recorded in the audit record, by default the purpose of
+	// the Access of ctx
+	Purpose string
+	// Audit receives the record of every export, successful or not. It is
+	// required: copies of key material must be accounted for.
+	Audit func(ctx context.Context, record *EscrowRecord, err error)
+}
+
+// Escrow is a copy of keys sealed under a random escrow key, which is split
+// among approvers. It holds no secret on its own and can be stored
+// anywhere, e.g. next to the app config.
+type Escrow struct {
+	Version   int       `json:"version"`
+	ID        string    `json:"id"`
+	KeyIDs    []string  `json:"key_ids"`
+	Approvers []string  `json:"approvers"`
+	Threshold int       `json:"threshold"`
+	Created   time.Time `json:"created"`
+	// Sealed is the AES-256-GCM nonce and ciphertext of the keys
+	Sealed []byte `json:"sealed"`
+}
+
+// EscrowShare is the share of the escrow key handed to one approver
+type EscrowShare struct {
+	EscrowID string `json:"escrow_id"`
+	Approver string `json:"approver"`
+	X        byte   `json:"x"`
+	Y        []byte `json:"y"`
+}
+
+// EscrowRecord is the audit record of an export or a recovery
+type EscrowRecord struct {
+	Operation string    `json:"operation"`
+	EscrowID  string    `json:"escrow_id,omitempty"`
+	KeyIDs    []string  `json:"key_ids"`
+	Approvers []string  `json:"approvers"`
+	Threshold int       `json:"threshold"`
+	Purpose   string    `json:"purpose,omitempty"`
+	Actor     string    `json:"actor,omitempty"`
+	Time      time.Time `json:"time"`
+}
+
+// setAccess records the actor of the Access of ctx, and its purpose unless
+// one was given
+func (r *EscrowRecord) setAccess(ctx context.Context) {
+	access := AccessFromContext(ctx)
+	if r.Purpose == "" {
+		r.Purpose = access.Purpose
+	}
+	r.Actor = access.Actor
+}
+
+// ExportEscrow seals a copy of keys for escrow. The returned shares go to
+// the approvers, one each, and must not be stored with the escrow: any
+// opts.Threshold of them recover the keys with RecoverEscrow, fewer
+// reveal nothing about them. Provider keys are unwrapped to be exported;
+// HPKE keys cannot be exported.
+func (g *GovaultDB) ExportEscrow(ctx context.Context, opts EscrowOptions) (*Escrow, []EscrowShare, error) {
+	if opts.Audit == nil {
+		return nil, nil, fmt.Errorf("an audit function is required to export keys")
+	}
+	record := &EscrowRecord{
+		Operation: EscrowOperationExport,
+		Approvers: opts.Approvers,
+		Threshold: opts.Threshold,
+		Purpose:   opts.Purpose,
+		Time:      time.Now(),
+	}
+	record.setAccess(ctx)
+	escrow, shares, err := g.exportEscrow(ctx, opts, record)
+	if err != nil {
+		err = fmt.Errorf("failed to export escrow: %w", err)
+	}
+	opts.Audit(ctx, record, err)
+	if err != nil {
+		return nil, nil, err
+	}
+	return escrow, shares, nil
+}
+
+func (g *GovaultDB) exportEscrow(ctx context.Context, opts EscrowOptions, record *EscrowRecord) (*Escrow, []EscrowShare, error) {
+	if err := checkApprovers(opts.Approvers, opts.Threshold); err != nil {
+		return nil, nil, err
+	}
+
+	keyIDs := slices.Clone(opts.KeyIDs)
+	if len(keyIDs) == 0 {
+		for _, keyID := range g.GetKeyIDs() {
+			if key, _ := g.keyEntry(keyID); key.hpke == nil {
+				keyIDs = append(keyIDs, keyID)
+			}
+		}
+	}
+	if len(keyIDs) == 0 {
+		return nil, nil, fmt.Errorf("no keys to export")
+	}
+	slices.Sort(keyIDs)
+	record.KeyIDs = keyIDs
+
+	keys := make(map[string][]byte, len(keyIDs))
+	for _, keyID := range keyIDs {
+		key, exists := g.keyEntry(keyID)
+		if !exists {
+			return nil, nil, fmt.Errorf("encryption key '%s' not found", keyID)
+		}
+		if key.hpke != nil {
+			return nil, nil, fmt.Errorf("HPKE key '%s' cannot be exported", keyID)
+		}
+		key, err := g.unwrapKey(ctx, key)
+		if err != nil {
+			return nil, nil, err
+		}
+		keys[keyID] = key.Value
+	}
+	plaintext, err := json.Marshal(keys)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer clear(plaintext)
+
+	escrowKey := make([]byte, 32)
+	if _, err := rand.Read(escrowKey); err != nil {
+		return nil, nil, fmt.Errorf("failed to generate escrow key: %w", err)
+	}
+	defer clear(escrowKey)
+
+	escrow := &Escrow{
+		Version:   escrowVersion,
+		KeyIDs:    keyIDs,
+		Approvers: slices.Clone(opts.Approvers),
+		Threshold: opts.Threshold,
+		Created:   record.Time.UTC(),
+	}
+	aead, err := escrowCipher(escrowKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	escrow.Sealed = aead.Seal(nonce, nonce, plaintext, escrow.associatedData())
+	sum := sha256.Sum256(escrow.Sealed)
+	escrow.ID = hex.EncodeToString(sum[:8])
+	record.EscrowID = escrow.ID
+
+	split, err := splitSecret(escrowKey, len(opts.Approvers), opts.Threshold)
+	if err != nil {
+		return nil, nil, err
+	}
+	shares := make([]EscrowShare, len(split))
+	for i, share := range split {
+		shares[i] = EscrowShare{EscrowID: escrow.ID, Approver: opts.Approvers[i], X: share.x, Y: share.y}
+	}
+	return escrow, shares, nil
+}
+
+// RecoverEscrow combines the shares of at least escrow.Threshold approvers
+// and returns the escrowed keys by key ID, e.g. for Config.Keys or
+// StaticKeyArchive. audit receives the record of the attempt, successful
+// or not, naming the approvers whose shares were given.
+func RecoverEscrow(ctx context.Context, escrow *Escrow, shares []EscrowShare, purpose string, audit func(ctx context.Context, record *EscrowRecord, err error)) (map[string][]byte, error) {
+	if audit == nil {
+		return nil, fmt.Errorf("an audit function is required to recover keys")
+	}
+	record := &EscrowRecord{
+		Operation: EscrowOperationRecover,
+		EscrowID:  escrow.ID,
+		KeyIDs:    escrow.KeyIDs,
+		Threshold: escrow.Threshold,
+		Purpose:   purpose,
+		Time:      time.Now(),
+	}
+	record.setAccess(ctx)
+	for _, share := range shares {
+		record.Approvers = append(record.Approvers, share.Approver)
+	}
+	keys, err := recoverEscrow(escrow, shares)
+	if err != nil {
+		err = fmt.Errorf("failed to recover escrow %s: %w", escrow.ID, err)
+	}
+	audit(ctx, record, err)
+	if err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+func recoverEscrow(escrow *Escrow, shares []EscrowShare) (map[string][]byte, error) {
+	if escrow.Version != escrowVersion {
+		return nil, fmt.Errorf("unknown escrow version %d", escrow.Version)
+	}
+	if len(shares) < escrow.Threshold {
+		return nil, fmt.Errorf("%d shares given, %d required", len(shares), escrow.Threshold)
+	}
+	// The quorum counts approvers, not shares
+	seen := make(map[string]bool, len(shares))
+	points := make([]shamirShare, 0, len(shares))
+	for _, share := range shares {
+		if share.EscrowID != escrow.ID {
+			return nil, fmt.Errorf("share of %s belongs to escrow %s", share.Approver, share.EscrowID)
+		}
+		if !slices.Contains(escrow.Approvers, share.Approver) {
+			return nil, fmt.Errorf("%s is not an approver", share.Approver)
+		}
+		if seen[share.Approver] {
+			return nil, fmt.Errorf("approver %s gave more than one share", share.Approver)
+		}
+		seen[share.Approver] = true
+		points = append(points, shamirShare{x: share.X, y: share.Y})
+	}
+
+	escrowKey, err := combineShares(points)
+	if err != nil {
+		return nil, err
+	}
+	defer clear(escrowKey)
+	aead, err := escrowCipher(escrowKey)
+	if err != nil {
+		return nil, err
+	}
+	if len(escrow.Sealed) < aead.NonceSize() {
+		return nil, fmt.Errorf("sealed keys are truncated")
+	}
+	nonce, sealed := escrow.Sealed[:aead.NonceSize()], escrow.Sealed[aead.NonceSize():]
+	plaintext, err := aead.Open(nil, nonce, sealed, escrow.associatedData())
+	if err != nil {
+		return nil, fmt.Errorf("the shares do not recover the escrow key")
+	}
+	defer clear(plaintext)
+
+	var keys map[string][]byte
+	if err := json.Unmarshal(plaintext, &keys); err != nil {
+		return nil, fmt.Errorf("failed to parse escrowed keys: %w", err)
+	}
+	return keys, nil
+}
+
+// associatedData binds the metadata of the escrow to the sealed keys
+func (e *Escrow) associatedData() []byte {
+	data, _ := json.Marshal([]any{"govault escrow", e.Version, e.Threshold, e.KeyIDs, e.Approvers, e.Created.UnixNano()})
+	return data
+}
+
+// escrowCipher returns the AES-256-GCM cipher of an escrow key
+func escrowCipher(escrowKey []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(escrowKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+// checkApprovers validates an M-of-N split among approvers
+func checkApprovers(approvers []string, threshold int) error {
+	if threshold < 2 {
+		return fmt.Errorf("threshold must be at least 2, got %d", threshold)
+	}
+	if threshold > len(approvers) {
+		return fmt.Errorf("threshold %d exceeds the %d approvers", threshold, len(approvers))
+	}
+	if len(approvers) > 255 {
+		return fmt.Errorf("at most 255 approvers are supported, got %d", len(approvers))
+	}
+	seen := make(map[string]bool, len(approvers))
+	for _, approver := range approvers {
+		if approver == "" || seen[approver] {
+			return fmt.Errorf("approver %q is empty or given twice", approver)
+		}
+		seen[approver] = true
+	}
+	return nil
+}
@@ -0,0 +1,107 @@
+package internal_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/muhammadluth/govault/internal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeyEscrow(t *testing.T) {
+	ctx := context.Background()
+	g, err := internal.New(internal.Config{
+		Keys:         map[string][]byte{"1": reloadKey1, "2": reloadKey2},
+		DefaultKeyID: "2",
+	})
+	require.NoError(t, err)
+
+	var records []internal.EscrowRecord
+	audit := func(ctx context.Context, record *internal.EscrowRecord, err error) {
+		records = append(records, *record)
+	}
+
+	escrow, shares, err := g.ExportEscrow(ctx, internal.EscrowOptions{
+		Approvers: []string{"alice", "bob", "carol", "dave", "erin"},
+		Threshold: 3,
+		Purpose:   "PII-123",
+		Audit:     audit,
+	})
+	require.NoError(t, err)
+	require.Len(t, shares, 5)
+	assert.Equal(t, []string{"1", "2"}, escrow.KeyIDs)
+	assert.NotContains(t, string(escrow.Sealed), string(reloadKey1))
+	require.Len(t, records, 1)
+	assert.Equal(t, internal.EscrowOperationExport, records[0].Operation)
+	assert.Equal(t, escrow.ID, records[0].EscrowID)
+
+	// The escrow is stored as JSON, e.g. in app config
+	data, err := json.Marshal(escrow)
+	require.NoError(t, err)
+	var stored internal.Escrow
+	require.NoError(t, json.Unmarshal(data, &stored))
+
+	t.Run("any quorum of shares recovers the keys", func(t *testing.T) {
+		for _, quorum := range [][]internal.EscrowShare{
+			{shares[0], shares[1], shares[2]},
+			{shares[4], shares[1], shares[3]},
+			shares,
+		} {
+			keys, err := internal.RecoverEscrow(ctx, &stored, quorum, "incident", audit)
+			require.NoError(t, err)
+			assert.Equal(t, map[string][]byte{"1": reloadKey1, "2": reloadKey2}, keys)
+		}
+		last := records[len(records)-1]
+		assert.Equal(t, internal.EscrowOperationRecover, last.Operation)
+		assert.Equal(t, "incident", last.Purpose)
+		assert.Len(t, last.Approvers, 5)
+	})
+
+	t.Run("fewer or forged shares fail", func(t *testing.T) {
+		_, err := internal.RecoverEscrow(ctx, &stored, shares[:2], "", audit)
+		assert.ErrorContains(t, err, "2 shares given, 3 required")
+
+		forged := shares[2]
+		forged.Y = append([]byte(nil), forged.Y...)
+		forged.Y[0] ^= 1
+		_, err = internal.RecoverEscrow(ctx, &stored, []internal.EscrowShare{shares[0], shares[1], forged}, "", audit)
+		assert.ErrorContains(t, err, "do not recover")
+
+		_, err = internal.RecoverEscrow(ctx, &stored, []internal.EscrowShare{shares[0], shares[0], shares[1]}, "", audit)
+		assert.ErrorContains(t, err, "approver alice gave more than one share")
+
+		// One approver holding a second share is still one approval
+		relabeled := shares[1]
+		relabeled.Approver = shares[0].Approver
+		_, err = internal.RecoverEscrow(ctx, &stored, []internal.EscrowShare{shares[0], relabeled, shares[2]}, "", audit)
+		assert.ErrorContains(t, err, "approver alice gave more than one share")
+
+		tampered := stored
+		tampered.Threshold = 2
+		_, err = internal.RecoverEscrow(ctx, &tampered, shares[:3], "", audit)
+		assert.Error(t, err, "the metadata is authenticated")
+	})
+
+	t.Run("exports are audited and validated", func(t *testing.T) {
+		_, _, err := g.ExportEscrow(ctx, internal.EscrowOptions{Approvers: []string{"a", "b"}, Threshold: 2})
+		assert.ErrorContains(t, err, "audit function is required")
+
+		for _, opts := range []internal.EscrowOptions{
+			{Approvers: []string{"a", "b"}, Threshold: 1},
+			{Approvers: []string{"a", "b"}, Threshold: 3},
+			{Approvers: []string{"a", "a"}, Threshold: 2},
+			{Approvers: []string{"a", "b"}, Threshold: 2, KeyIDs: []string{"missing"}},
+		} {
+			opts.Audit = audit
+			before := len(records)
+			_, _, err := g.ExportEscrow(ctx, opts)
+			assert.Error(t, err)
+			assert.Len(t, records, before+1, "failed exports are audited")
+		}
+	})
+
+	_, err = internal.RecoverEscrow(ctx, &stored, shares, "", nil)
+	assert.ErrorContains(t, err, "audit function is required")
+}
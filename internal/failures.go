@@ -0,0 +1,226 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Defaults of FailureDetector
+const (
+	defaultFailureWindow        = time.Minute
+	defaultFailureMinOperations = 20
+	defaultFailureThreshold     = 0.5
+	defaultFailureCooldown      = 30 * time.Second
+)
+
+// FailureDetector trips a breaker per key and table when decryptions fail
+// in bulk, e.g. after a deploy with a missing key: while it is open, field
+// decryptions return FallbackMaskValue without being attempted, so that
+// reads degrade to masked values instead of failing, see
+// Config.FailureDetector.
+type FailureDetector struct {
+	// Window is the period over which errors are counted, default 1m
+	Window time.Duration
+	// MinOperations is the number of decryptions in a window below which
+	// the breaker does not trip, default 20
+	MinOperations int
+	// Threshold is the share of failed decryptions tripping the breaker,
+	// default 0.5
+	Threshold float64
+	// Cooldown is how long the breaker stays open, default 30s
+	Cooldown time.Duration
+	// Report receives every trip and reset of a breaker, e.g. to page. It
+	// is called synchronously and must not block.
+	Report func(event FailureEvent)
+}
+
+// FailureEvent is a trip or reset of the breaker of a key and table. KeyID
+// is empty for values whose key ID cannot be read, e.g. of codecs.
+type FailureEvent struct {
+	KeyID      string    `json:"key_id"`
+	Table      string    `json:"table"`
+	Tripped    bool      `json:"tripped"`
+	Operations int       `json:"operations"`
+	Errors     int       `json:"errors"`
+	Time       time.Time `json:"time"`
+}
+
+// FailureStat is the current window of a key and table
+type FailureStat struct {
+	KeyID      string `json:"key_id"`
+	Table      string `json:"table"`
+	Operations int    `json:"operations"`
+	Errors     int    `json:"errors"`
+	Open       bool   `json:"open"`
+}
+
+// failureKey identifies a breaker
+type failureKey struct {
+	keyID string
+	table string
+}
+
+// failureWindow counts the decryptions of one breaker
+type failureWindow struct {
+	started    time.Time
+	operations int
+	errors     int
+	openUntil  time.Time
+}
+
+// failureDetector holds the breakers of a FailureDetector
+type failureDetector struct {
+	config FailureDetector
+
+	mu      sync.Mutex
+	windows map[failureKey]*failureWindow
+}
+
+func newFailureDetector(config *FailureDetector) *failureDetector {
+	if config == nil {
+		return nil
+	}
+	d := &failureDetector{config: *config, windows: make(map[failureKey]*failureWindow)}
+	if d.config.Window <= 0 {
+		d.config.Window = defaultFailureWindow
+	}
+	if d.config.MinOperations <= 0 {
+		d.config.MinOperations = defaultFailureMinOperations
+	}
+	if d.config.Threshold <= 0 || d.config.Threshold > 1 {
+		d.config.Threshold = defaultFailureThreshold
+	}
+	if d.config.Cooldown <= 0 {
+		d.config.Cooldown = defaultFailureCooldown
+	}
+	return d
+}
+
+// open reports whether the breaker of key is open, resetting it once its
+// cooldown is over
+func (d *failureDetector) open(key failureKey, now time.Time) bool {
+	d.mu.Lock()
+	w := d.windows[key]
+	if w == nil || w.openUntil.IsZero() {
+		d.mu.Unlock()
+		return false
+	}
+	if now.Before(w.openUntil) {
+		d.mu.Unlock()
+		return true
+	}
+	event := d.event(key, w, false, now)
+	*w = failureWindow{started: now}
+	d.mu.Unlock()
+
+	d.report(event)
+	return false
+}
+
+// record counts a decryption, tripping the breaker when failures exceed the
+// threshold
+func (d *failureDetector) record(key failureKey, failed bool, now time.Time) {
+	d.mu.Lock()
+	w := d.windows[key]
+	if w == nil {
+		w = &failureWindow{started: now}
+		d.windows[key] = w
+	}
+	if now.Sub(w.started) > d.config.Window {
+		*w = failureWindow{started: now, openUntil: w.openUntil}
+	}
+	w.operations++
+	if failed {
+		w.errors++
+	}
+	tripped := w.openUntil.IsZero() &&
+		w.operations >= d.config.MinOperations &&
+		float64(w.errors) >= d.config.Threshold*float64(w.operations)
+	var event FailureEvent
+	if tripped {
+		w.openUntil = now.Add(d.config.Cooldown)
+		event = d.event(key, w, true, now)
+	}
+	d.mu.Unlock()
+
+	if tripped {
+		d.report(event)
+	}
+}
+
+func (d *failureDetector) event(key failureKey, w *failureWindow, tripped bool, now time.Time) FailureEvent {
+	return FailureEvent{
+		KeyID:      key.keyID,
+		Table:      key.table,
+		Tripped:    tripped,
+		Operations: w.operations,
+		Errors:     w.errors,
+		Time:       now,
+	}
+}
+
+func (d *failureDetector) report(event FailureEvent) {
+	if d.config.Report != nil {
+		d.config.Report(event)
+	}
+}
+
+// stats returns the windows of all breakers, by key ID and table
+func (d *failureDetector) stats(now time.Time) []FailureStat {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	stats := make([]FailureStat, 0, len(d.windows))
+	for key, w := range d.windows {
+		stats = append(stats, FailureStat{
+			KeyID:      key.keyID,
+			Table:      key.table,
+			Operations: w.operations,
+			Errors:     w.errors,
+			Open:       now.Before(w.openUntil),
+		})
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].KeyID != stats[j].KeyID {
+			return stats[i].KeyID < stats[j].KeyID
+		}
+		return stats[i].Table < stats[j].Table
+	})
+	return stats
+}
+
+// FailureStats returns the decryptions and failures counted by
+// Config.FailureDetector per key and table, e.g. for metrics; nil without
+// a detector
+func (g *GovaultDB) FailureStats() []FailureStat {
+	if g.failures == nil {
+		return nil
+	}
+	return g.failures.stats(time.Now())
+}
+
+// decryptFieldDetected is decryptField counted by the failure detector
+func (g *GovaultDB) decryptFieldDetected(ctx context.Context, field *FieldSpec, table, value string) (string, bool, error) {
+	if g.failures == nil || value == "" {
+		return g.decryptField(ctx, field, table, value)
+	}
+
+	key := failureKey{table: table}
+	if field == nil || field.Codec == "" {
+		key.keyID, _ = g.GetKeyIDFromEncryptedData(value)
+	}
+	if g.failures.open(key, time.Now()) && IsFieldEncrypted(field, value) {
+		return FallbackMaskValue, true, nil
+	}
+
+	plaintext, ok, err := g.decryptField(ctx, field, table, value)
+	// Rate limits and modes reject operations and ended contexts abandon
+	// them, they are not failures
+	if (ok || err != nil) && !errors.Is(err, ErrRateLimited) && !errors.Is(err, ErrOperationNotAllowed) && (err == nil || ctx.Err() == nil) {
+		g.failures.record(key, err != nil, time.Now())
+	}
+	return plaintext, ok, err
+}
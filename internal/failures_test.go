@@ -0,0 +1,96 @@
+package internal_test
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/muhammadluth/govault/internal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFailureDetector(t *testing.T) {
+	type patient struct {
+		Name string `bun:"name" encrypted:"true"`
+	}
+	spec := internal.GetModelSpec((*patient)(nil))
+	field := spec.Field("Name")
+
+	writer, err := internal.New(internal.Config{
+		Keys:         map[string][]byte{"1": reloadKey1, "2": reloadKey2},
+		DefaultKeyID: "1",
+	})
+	require.NoError(t, err)
+	missing, err := writer.Encrypt("Jane")
+	require.NoError(t, err)
+	present, err := writer.Encrypt("John", "2")
+	require.NoError(t, err)
+
+	var mu sync.Mutex
+	var events []internal.FailureEvent
+	// The deploy lost key 1
+	g, err := internal.New(internal.Config{
+		Keys:         map[string][]byte{"2": reloadKey2},
+		DefaultKeyID: "2",
+		FailureDetector: &internal.FailureDetector{
+			MinOperations: 4,
+			Cooldown:      50 * time.Millisecond,
+			Report: func(event internal.FailureEvent) {
+				mu.Lock()
+				defer mu.Unlock()
+				events = append(events, event)
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	t.Run("errors below the minimum fail the reads", func(t *testing.T) {
+		for range 3 {
+			_, _, err := g.DecryptField(field, spec.Table, missing)
+			assert.Error(t, err)
+		}
+		assert.Empty(t, events)
+	})
+
+	t.Run("a spike trips the breaker of the key and table", func(t *testing.T) {
+		var wg sync.WaitGroup
+		for range 8 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, _, _ = g.DecryptField(field, spec.Table, missing)
+			}()
+		}
+		wg.Wait()
+
+		require.Len(t, events, 1)
+		assert.Equal(t, "1", events[0].KeyID)
+		assert.Equal(t, spec.Table, events[0].Table)
+		assert.True(t, events[0].Tripped)
+
+		plaintext, ok, err := g.DecryptField(field, spec.Table, missing)
+		require.NoError(t, err)
+		assert.True(t, ok)
+		assert.Equal(t, internal.FallbackMaskValue, plaintext, "open breakers mask")
+
+		plaintext, _, err = g.DecryptField(field, spec.Table, present)
+		require.NoError(t, err)
+		assert.Equal(t, "John", plaintext, "other keys are unaffected")
+
+		stats := g.FailureStats()
+		require.Len(t, stats, 2)
+		assert.True(t, stats[0].Open)
+		assert.False(t, stats[1].Open)
+	})
+
+	t.Run("the breaker resets after the cooldown", func(t *testing.T) {
+		time.Sleep(60 * time.Millisecond)
+		_, _, err := g.DecryptField(field, spec.Table, missing)
+		assert.Error(t, err)
+		require.Len(t, events, 2)
+		assert.False(t, events[1].Tripped)
+	})
+
+	assert.Nil(t, writer.FailureStats())
+}
@@ -0,0 +1,213 @@
+package internal
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sync/atomic"
+	"time"
+)
+
+// fieldVault is the package-level vault used by Field values without one
+var fieldVault atomic.Pointer[GovaultDB]
+
+// SetFieldVault sets the vault used by Field values that are not bound to
+// one with WithVault. Passing nil clears it.
+func SetFieldVault(g *GovaultDB) {
+	fieldVault.Store(g)
+}
+
+// Field is a column type that encrypts in Value and decrypts in Scan, so it
+// works with any database/sql based ORM without wrapper queries.
+//
+// String kinds are stored exactly like tag-encrypted columns; []byte is
+// stored as its bytes and other types are encoded by a Serializer before
+// encryption, JSON unless set by the serializer tag or WithSerializer.
+// Types whose fields change can be versioned with RegisterSchema.
+// A zero Valid stores NULL. ORMs that derive column types from Go types
+// should be told the column is text, e.g. bun:"email,type:text".
+type Field[T any] struct {
+	V     T
+	Valid bool
+
+	vault      *GovaultDB
+	keyID      string
+	serializer string
+}
+
+// NewField returns a valid Field holding v
+func NewField[T any](v T) Field[T] {
+	return Field[T]{V: v, Valid: true}
+}
+
+// WithVault binds f to g instead of the package-level vault
+func (f Field[T]) WithVault(g *GovaultDB) Field[T] {
+	f.vault = g
+	return f
+}
+
+// WithKey selects the key used by Value; empty means the default key
+func (f Field[T]) WithKey(keyID string) Field[T] {
+	f.keyID = keyID
+	return f
+}
+
+// WithSerializer selects the serializer used by Value; empty means JSON.
+// Scan reads the serializer recorded in the value, so values written with
+// other serializers keep decoding.
+func (f Field[T]) WithSerializer(id string) Field[T] {
+	f.serializer = id
+	return f
+}
+
+// setSerializer sets the serializer from the serializer tag, see
+// GovaultDB.EncryptModel
+func (f *Field[T]) setSerializer(id string) {
+	f.serializer = id
+}
+
+// setSerializer applies the serializer tag to a Field column, or a non-nil
+// pointer to one
+func setSerializer(field reflect.Value, id string) {
+	if field.Kind() != reflect.Ptr {
+		if !field.CanAddr() {
+			return
+		}
+		field = field.Addr()
+	}
+	if field.IsNil() {
+		return
+	}
+	if f, ok := field.Interface().(interface{ setSerializer(string) }); ok {
+		f.setSerializer(id)
+	}
+}
+
+// timeValue returns V of a Field[time.Time], for the generalize tag
+func (f Field[T]) timeValue() (time.Time, bool) {
+	t, ok := any(f.V).(time.Time)
+	return t, ok && f.Valid
+}
+
+// Value implements driver.Valuer and returns the ciphertext of V
+func (f Field[T]) Value() (driver.Value, error) {
+	if !f.Valid {
+		return nil, nil
+	}
+
+	g, err := f.getVault()
+	if err != nil {
+		return nil, err
+	}
+
+	plaintext, err := f.encode()
+	if err != nil {
+		return nil, err
+	}
+
+	ciphertext, err := g.Encrypt(plaintext, f.keyID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt field: %w", err)
+	}
+	return ciphertext, nil
+}
+
+// Scan implements sql.Scanner and decrypts src into V. Values that are not
+// ciphertext are read as plaintext.
+func (f *Field[T]) Scan(src any) error {
+	var data string
+	switch v := src.(type) {
+	case nil:
+		var zero T
+		f.V, f.Valid = zero, false
+		return nil
+	case string:
+		data = v
+	case []byte:
+		data = string(v)
+	default:
+		return fmt.Errorf("cannot scan %T into govault field", src)
+	}
+
+	if IsEncrypted(data) {
+		g, err := f.getVault()
+		if err != nil {
+			return err
+		}
+		if data, err = g.Decrypt(data); err != nil {
+			return fmt.Errorf("failed to decrypt field: %w", err)
+		}
+	}
+
+	if err := f.decode(data); err != nil {
+		return err
+	}
+	f.Valid = true
+	return nil
+}
+
+// MarshalJSON encodes V, or null when f is not valid
+func (f Field[T]) MarshalJSON() ([]byte, error) {
+	if !f.Valid {
+		return []byte("null"), nil
+	}
+	return json.Marshal(f.V)
+}
+
+// UnmarshalJSON decodes into V; null clears Valid
+func (f *Field[T]) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		var zero T
+		f.V, f.Valid = zero, false
+		return nil
+	}
+	if err := json.Unmarshal(data, &f.V); err != nil {
+		return err
+	}
+	f.Valid = true
+	return nil
+}
+
+func (f Field[T]) getVault() (*GovaultDB, error) {
+	if f.vault != nil {
+		return f.vault, nil
+	}
+	if g := fieldVault.Load(); g != nil {
+		return g, nil
+	}
+	return nil, fmt.Errorf("no vault for govault field, call SetFieldVault or WithVault")
+}
+
+func (f Field[T]) encode() (string, error) {
+	v := reflect.ValueOf(&f.V).Elem()
+	switch {
+	case v.Kind() == reflect.String:
+		return v.String(), nil
+	case v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Uint8:
+		return string(v.Bytes()), nil
+	}
+
+	data, err := serialize(f.serializer, SchemaVersion(reflect.TypeFor[T]()), f.V)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode field: %w", err)
+	}
+	return data, nil
+}
+
+func (f *Field[T]) decode(data string) error {
+	v := reflect.ValueOf(&f.V).Elem()
+	switch {
+	case v.Kind() == reflect.String:
+		v.SetString(data)
+		return nil
+	case v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Uint8:
+		v.SetBytes([]byte(data))
+		return nil
+	}
+
+	if err := deserialize(data, &f.V); err != nil {
+		return fmt.Errorf("failed to decode field: %w", err)
+	}
+	return nil
+}
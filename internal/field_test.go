@@ -0,0 +1,254 @@
+package internal_test
+
+import (
+	"encoding/json"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/muhammadluth/govault/internal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+func newTestVault(t *testing.T) *internal.GovaultDB {
+	t.Helper()
+	g, err := internal.New(internal.Config{
+		Keys: map[string][]byte{
+			"1": []byte("727d37a0-a5f2-4d67-af47-83039c8e"),
+			"2": []byte("e778dc27-9b04-44c3-a862-feba061c"),
+		},
+		DefaultKeyID: "2",
+	})
+	require.NoError(t, err)
+	return g
+}
+
+type address struct {
+	City string `json:"city"`
+	Zip  string `json:"zip"`
+}
+
+func TestField(t *testing.T) {
+	g := newTestVault(t)
+
+	t.Run("string round trip", func(t *testing.T) {
+		v, err := internal.NewField("secret@example.com").WithVault(g).Value()
+		require.NoError(t, err)
+		assert.True(t, internal.IsEncrypted(v.(string)))
+
+		keyID, err := g.GetKeyIDFromEncryptedData(v.(string))
+		require.NoError(t, err)
+		assert.Equal(t, "2", keyID)
+
+		out := internal.Field[string]{}.WithVault(g)
+		require.NoError(t, out.Scan(v))
+		assert.True(t, out.Valid)
+		assert.Equal(t, "secret@example.com", out.V)
+	})
+
+	t.Run("struct round trip with key", func(t *testing.T) {
+		in := internal.NewField(address{City: "Jakarta", Zip: "10110"}).WithVault(g).WithKey("1")
+		v, err := in.Value()
+		require.NoError(t, err)
+
+		keyID, err := g.GetKeyIDFromEncryptedData(v.(string))
+		require.NoError(t, err)
+		assert.Equal(t, "1", keyID)
+
+		out := internal.Field[address]{}.WithVault(g)
+		require.NoError(t, out.Scan([]byte(v.(string))))
+		assert.Equal(t, in.V, out.V)
+	})
+
+	t.Run("null", func(t *testing.T) {
+		v, err := internal.Field[string]{}.WithVault(g).Value()
+		require.NoError(t, err)
+		assert.Nil(t, v)
+
+		out := internal.NewField("stale")
+		require.NoError(t, out.Scan(nil))
+		assert.False(t, out.Valid)
+		assert.Empty(t, out.V)
+	})
+
+	t.Run("plaintext is read as is", func(t *testing.T) {
+		var out internal.Field[string]
+		require.NoError(t, out.Scan("legacy plaintext"))
+		assert.Equal(t, "legacy plaintext", out.V)
+	})
+
+	t.Run("package level vault", func(t *testing.T) {
+		_, err := internal.NewField("x").Value()
+		require.Error(t, err)
+
+		internal.SetFieldVault(g)
+		defer internal.SetFieldVault(nil)
+
+		v, err := internal.NewField(42).Value()
+		require.NoError(t, err)
+
+		var out internal.Field[int]
+		require.NoError(t, out.Scan(v))
+		assert.Equal(t, 42, out.V)
+	})
+
+	t.Run("json", func(t *testing.T) {
+		data, err := json.Marshal(struct {
+			A internal.Field[string] `json:"a"`
+			B internal.Field[string] `json:"b"`
+		}{A: internal.NewField("x")})
+		require.NoError(t, err)
+		assert.JSONEq(t, `{"a":"x","b":null}`, string(data))
+	})
+}
+
+// reversedJSON is JSON stored backwards, a serializer JSON cannot read
+type reversedJSON struct{}
+
+func (reversedJSON) Marshal(v any) ([]byte, error) {
+	data, err := json.Marshal(v)
+	slices.Reverse(data)
+	return data, err
+}
+
+func (reversedJSON) Unmarshal(data []byte, v any) error {
+	data = slices.Clone(data)
+	slices.Reverse(data)
+	return json.Unmarshal(data, v)
+}
+
+type serializedProfile struct {
+	Home   internal.Field[address] `bun:"home" serializer:"msgpack"`
+	Office internal.Field[address] `bun:"office"`
+}
+
+func TestFieldSerializers(t *testing.T) {
+	g := newTestVault(t)
+	internal.RegisterSerializer("reversed", reversedJSON{})
+	home := address{City: "Jakarta", Zip: "10110"}
+
+	plaintextOf := func(t *testing.T, v any) string {
+		plaintext, err := g.Decrypt(v.(string))
+		require.NoError(t, err)
+		return plaintext
+	}
+
+	t.Run("json is not recorded", func(t *testing.T) {
+		v, err := internal.NewField(home).WithVault(g).Value()
+		require.NoError(t, err)
+		assert.JSONEq(t, `{"city":"Jakarta","zip":"10110"}`, plaintextOf(t, v))
+	})
+
+	for _, id := range []string{internal.SerializerMsgpack, "reversed"} {
+		t.Run(id+" round trip", func(t *testing.T) {
+			v, err := internal.NewField(home).WithVault(g).WithSerializer(id).Value()
+			require.NoError(t, err)
+			assert.True(t, strings.HasPrefix(plaintextOf(t, v), "\x00"+id+"\x00"), "the serializer is recorded")
+
+			// Reads pick the recorded serializer, whatever the reader's
+			out := internal.Field[address]{}.WithVault(g).WithSerializer(internal.SerializerJSON)
+			require.NoError(t, out.Scan(v))
+			assert.Equal(t, home, out.V)
+		})
+	}
+
+	t.Run("tag sets the serializer", func(t *testing.T) {
+		profile := &serializedProfile{
+			Home:   internal.NewField(home).WithVault(g),
+			Office: internal.NewField(home).WithVault(g),
+		}
+		require.NoError(t, g.EncryptModel(profile, ""))
+
+		v, err := profile.Home.Value()
+		require.NoError(t, err)
+		assert.True(t, strings.HasPrefix(plaintextOf(t, v), "\x00msgpack\x00"))
+		v, err = profile.Office.Value()
+		require.NoError(t, err)
+		assert.True(t, strings.HasPrefix(plaintextOf(t, v), "{"))
+	})
+
+	t.Run("unknown serializers fail", func(t *testing.T) {
+		_, err := internal.NewField(home).WithVault(g).WithSerializer("yaml").Value()
+		assert.ErrorContains(t, err, "unknown serializer 'yaml'")
+
+		v, err := g.Encrypt("\x00yaml\x00city: Jakarta")
+		require.NoError(t, err)
+		out := internal.Field[address]{}.WithVault(g)
+		assert.ErrorContains(t, out.Scan(v), "unknown serializer 'yaml'")
+	})
+}
+
+// versionedProfile is at schema version 3: name was renamed to full_name
+// in version 2 and country added in version 3
+type versionedProfile struct {
+	FullName string `json:"full_name" msgpack:"full_name"`
+	Country  string `json:"country" msgpack:"country"`
+}
+
+func TestFieldSchemaVersions(t *testing.T) {
+	g := newTestVault(t)
+
+	// Written before the type was versioned
+	legacy, err := g.Encrypt(`{"name":"Budi"}`)
+	require.NoError(t, err)
+
+	internal.RegisterSchema[versionedProfile](
+		func(value any) (any, error) {
+			m := value.(map[string]any)
+			m["full_name"] = m["name"]
+			delete(m, "name")
+			return m, nil
+		},
+		func(value any) (any, error) {
+			m := value.(map[string]any)
+			m["country"] = "ID"
+			return m, nil
+		},
+	)
+	assert.Equal(t, 3, internal.SchemaVersion(reflect.TypeFor[versionedProfile]()))
+
+	scan := func(t *testing.T, v any) (versionedProfile, error) {
+		out := internal.Field[versionedProfile]{}.WithVault(g)
+		err := out.Scan(v)
+		return out.V, err
+	}
+
+	t.Run("unversioned values are upgraded from version 1", func(t *testing.T) {
+		got, err := scan(t, legacy)
+		require.NoError(t, err)
+		assert.Equal(t, versionedProfile{FullName: "Budi", Country: "ID"}, got)
+	})
+
+	t.Run("values record the current version", func(t *testing.T) {
+		v, err := internal.NewField(versionedProfile{FullName: "Siti", Country: "SG"}).WithVault(g).Value()
+		require.NoError(t, err)
+		plaintext, err := g.Decrypt(v.(string))
+		require.NoError(t, err)
+		assert.True(t, strings.HasPrefix(plaintext, "\x01\x03json\x00"))
+
+		got, err := scan(t, v)
+		require.NoError(t, err)
+		assert.Equal(t, versionedProfile{FullName: "Siti", Country: "SG"}, got, "current values are not migrated")
+	})
+
+	t.Run("migrations start at the recorded version", func(t *testing.T) {
+		data, err := msgpack.Marshal(map[string]any{"full_name": "Andi"})
+		require.NoError(t, err)
+		v, err := g.Encrypt("\x01\x02msgpack\x00" + string(data))
+		require.NoError(t, err)
+
+		got, err := scan(t, v)
+		require.NoError(t, err)
+		assert.Equal(t, versionedProfile{FullName: "Andi", Country: "ID"}, got)
+	})
+
+	t.Run("newer versions fail", func(t *testing.T) {
+		v, err := g.Encrypt("\x01\x04json\x00{}")
+		require.NoError(t, err)
+		_, err = scan(t, v)
+		assert.ErrorContains(t, err, "schema version 4")
+	})
+}
@@ -0,0 +1,605 @@
+package internal
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"maps"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jinzhu/inflection"
+)
+
+// FieldSpec describes how a single struct field is encrypted
+type FieldSpec struct {
+	Index            []int
+	Name             string
+	Column           string
+	Codec            string    // empty means the native govault format
+	Algorithm        Algorithm // AEAD of native values, empty means AES-GCM
+	Envelope         string    // EnvelopeCodecs format of the foreign values, if any
+	Deterministic    bool      // same plaintext always yields the same ciphertext
+	BlindIndex       string    // column receiving the blind index, if any
+	BlindIndexBits   int
+	PlainHash        string            // column receiving the keyed hash of the plaintext, if any
+	TokenIndex       string            // column receiving the token set of the words, if any
+	Bloom            string            // column receiving the bloom filter of the members, if any
+	BloomItems       int               // expected number of members
+	BloomFPR         float64           // false positive rate at BloomItems members
+	BloomSeparator   string            // separates the members of the plaintext
+	Bucket           string            // column receiving the keyed bucket ID of the number, if any
+	BucketBounds     []float64         // ascending bounds between the buckets
+	Geohash          string            // column receiving the keyed geohash cell of the location, if any
+	GeohashPrecision int               // geohash characters of the cells
+	EmailDomain      string            // column receiving the domain of the address, if any
+	EmailDomainHash  bool              // the domain column holds the keyed hash of the domain
+	NullZero         bool              // bun:",nullzero", empty values are stored as NULL
+	Binary           bool              // stored in a binary column, see EncodeBinary
+	TTL              time.Duration     // retention of the value, zero means forever
+	TTLColumn        string            // timestamp column the TTL counts from
+	PadBuckets       []int             // ascending plaintext sizes to pad to
+	Transforms       map[string]string // transform applied on read, per role
+	Array            string            // ArrayElements or ArrayWhole for []string fields
+	Normalizers      []string          // normalizers applied before encryption, in order
+	PANBIN           string            // column receiving the BIN of the card number, if any
+	PANLast4         string            // column receiving the last four digits of the card number, if any
+	PANToken         string            // column receiving the token of the card number, if any
+	Preset           string            // preset filling the settings left unset, see Preset
+	Class            string            // data classification, e.g. pii-high
+}
+
+// Encryption of []string fields, e.g. text[] columns, set by the
+// encryptarray tag
+const (
+	// ArrayElements encrypts each element on its own, the default
+	ArrayElements = "elements"
+	// ArrayWhole encrypts the JSON of the array as one value, stored as
+	// its only element, hiding the number and sizes of the elements
+	ArrayWhole = "whole"
+)
+
+// Format returns the ciphertext format written for the field
+func (f *FieldSpec) Format() string {
+	switch f.Codec {
+	case "":
+		return strings.TrimSuffix(FormatPrefix, ":")
+	case CodecCipherSweet:
+		return strings.TrimSuffix(cipherSweetPrefix, ":")
+	default:
+		return f.Codec
+	}
+}
+
+// ModelSpec holds the encryption metadata of a struct type
+type ModelSpec struct {
+	Type    reflect.Type
+	Table   string
+	Fields  []*FieldSpec
+	byName  map[string]*FieldSpec
+	columns map[string][]int
+	// serialized holds the Field columns with a serializer tag
+	serialized []serializedField
+	// nested holds the struct fields with encrypted fields of their own
+	nested []nestedModel
+	// generalized holds the Field[time.Time] columns with a generalize tag
+	generalized []generalizedField
+	// classified holds the columns with a class tag that are not encrypted
+	classified []classifiedColumn
+}
+
+// nestedModel is a struct field whose encrypted fields are stored in the
+// table of the model: in its columns, flattened by bun:"embed:" or an
+// anonymous field, or in one JSON column:
+//
+//	Home    Address   `bun:"embed:home_"`
+//	Contact Contact   `bun:"contact,type:jsonb"`
+//	Aliases []Contact `bun:"aliases,type:jsonb"`
+//
+// Its spec has the table of the model, and the columns of its fields are
+// prefixed with the embed prefix, or the JSON column and a dot, e.g.
+// home_street and contact.phone, so blind indexes and declared fields are
+// keyed apart from the same struct elsewhere.
+type nestedModel struct {
+	index []int
+	name  string
+	spec  *ModelSpec
+}
+
+// serializedField is a Field column whose serializer is set by its tag:
+//
+//	Address govault.Field[Address] `bun:"address,type:text" serializer:"msgpack"`
+type serializedField struct {
+	index      []int
+	serializer string
+}
+
+// DefaultBlindIndexBits is used when blindindexbits is not set
+const DefaultBlindIndexBits = 32
+
+var modelSpecs sync.Map // reflect.Type -> *ModelSpec
+
+// specGeneration counts the changes of the registries specs are built
+// from, e.g. RegisterPreset, so instance caches notice them
+var specGeneration atomic.Uint64
+
+// invalidateSpecs drops the cached specs after a registry change
+func invalidateSpecs() {
+	modelSpecs.Clear()
+	specGeneration.Add(1)
+}
+
+// declaredSpec is a spec built with the Config.Fields of an instance, as
+// of a specGeneration
+type declaredSpec struct {
+	generation uint64
+	spec       *ModelSpec
+}
+
+// cloneFields copies Config.Fields, so later changes of the caller's maps
+// don't reach the specs
+func cloneFields(fields map[string]map[string]FieldConfig) map[string]map[string]FieldConfig {
+	if len(fields) == 0 {
+		return nil
+	}
+	clone := make(map[string]map[string]FieldConfig, len(fields))
+	for table, columns := range fields {
+		clone[table] = maps.Clone(columns)
+	}
+	return clone
+}
+
+// ModelSpec is GetModelSpec including the columns declared by
+// Config.Fields of g. Declarations apply to this instance only.
+func (g *GovaultDB) ModelSpec(model any) *ModelSpec {
+	typ := modelType(model)
+	if typ == nil {
+		return nil
+	}
+	return g.modelSpecOf(typ)
+}
+
+// modelSpecOf is modelSpecOf with the declarations of g
+func (g *GovaultDB) modelSpecOf(typ reflect.Type) *ModelSpec {
+	if len(g.fields) == 0 {
+		return modelSpecOf(typ)
+	}
+	generation := specGeneration.Load()
+	if cached, ok := g.specs.Load(typ); ok && cached.(declaredSpec).generation == generation {
+		return cached.(declaredSpec).spec
+	}
+	spec := buildSpec(typ, nil, "", g.fields, make(map[reflect.Type]*ModelSpec))
+	g.specs.Store(typ, declaredSpec{generation: generation, spec: spec})
+	return spec
+}
+
+// apply overrides the tag settings of field with the set values of c
+func (c FieldConfig) apply(field *FieldSpec) {
+	if c.Codec != "" {
+		field.Codec = c.Codec
+	}
+	if c.Envelope != "" {
+		field.Envelope = c.Envelope
+	}
+	if c.Algorithm != "" {
+		field.Algorithm = Algorithm(c.Algorithm)
+	}
+	if c.Deterministic {
+		field.Deterministic = true
+	}
+	if c.BlindIndex != "" {
+		field.BlindIndex = c.BlindIndex
+	}
+	if c.BlindIndexBits > 0 {
+		field.BlindIndexBits = c.BlindIndexBits
+	}
+	if c.PlainHash != "" {
+		field.PlainHash = c.PlainHash
+	}
+	if c.TokenIndex != "" {
+		field.TokenIndex = c.TokenIndex
+	}
+	if c.Bloom != "" {
+		field.Bloom = c.Bloom
+	}
+	if c.BloomItems > 0 {
+		field.BloomItems = c.BloomItems
+	}
+	if c.BloomFPR > 0 && c.BloomFPR < 1 {
+		field.BloomFPR = c.BloomFPR
+	}
+	if c.Storage == "binary" {
+		field.Binary = true
+	}
+	if len(c.Normalize) > 0 {
+		field.Normalizers = c.Normalize
+	}
+	if c.Preset != "" {
+		field.Preset = c.Preset
+	}
+	if c.Class != "" {
+		field.Class = c.Class
+	}
+	if c.PAN {
+		field.setPAN()
+	}
+	if c.PANBIN != "" {
+		field.PANBIN = c.PANBIN
+	}
+	if c.PANLast4 != "" {
+		field.PANLast4 = c.PANLast4
+	}
+	if c.PANToken != "" {
+		field.PANToken = c.PANToken
+	}
+	if c.EmailDomain != "" {
+		field.EmailDomain = c.EmailDomain
+	}
+	if c.EmailDomainHash {
+		field.EmailDomainHash = true
+	}
+	if c.Geohash != "" {
+		field.Geohash = c.Geohash
+	}
+	if c.GeohashPrecision > 0 && c.GeohashPrecision <= maxGeohashPrecision {
+		field.GeohashPrecision = c.GeohashPrecision
+	}
+	if c.Bucket != "" && ascending(c.BucketBounds) {
+		field.Bucket, field.BucketBounds = c.Bucket, c.BucketBounds
+	}
+}
+
+// GetModelSpec returns the cached encryption metadata for the struct behind
+// model, or nil when model is not a struct (or pointer/slice of structs).
+// It reads the struct tags only, see GovaultDB.ModelSpec.
+func GetModelSpec(model any) *ModelSpec {
+	typ := modelType(model)
+	if typ == nil {
+		return nil
+	}
+	return modelSpecOf(typ)
+}
+
+// modelType returns the struct type behind model, or nil
+func modelType(model any) reflect.Type {
+	if model == nil {
+		return nil
+	}
+	typ := reflect.TypeOf(model)
+	for typ.Kind() == reflect.Ptr || typ.Kind() == reflect.Slice || typ.Kind() == reflect.Array {
+		typ = typ.Elem()
+	}
+	if typ.Kind() != reflect.Struct {
+		return nil
+	}
+	return typ
+}
+
+// modelSpecOf returns the cached encryption metadata for typ
+func modelSpecOf(typ reflect.Type) *ModelSpec {
+	if spec, ok := modelSpecs.Load(typ); ok {
+		return spec.(*ModelSpec)
+	}
+	spec, _ := modelSpecs.LoadOrStore(typ, buildSpec(typ, nil, "", nil, make(map[reflect.Type]*ModelSpec)))
+	return spec.(*ModelSpec)
+}
+
+// buildSpec builds the spec of typ, a nested model of parent when set,
+// whose columns start with prefix, with the columns declared by table and
+// column. visiting holds the specs being built, so recursive types end.
+func buildSpec(typ reflect.Type, parent *ModelSpec, prefix string, declarations map[string]map[string]FieldConfig, visiting map[reflect.Type]*ModelSpec) *ModelSpec {
+	spec := &ModelSpec{
+		Type:    typ,
+		byName:  make(map[string]*FieldSpec),
+		columns: make(map[string][]int),
+	}
+	visiting[typ] = spec
+	defer delete(visiting, typ)
+
+	if parent != nil {
+		spec.Table = parent.Table
+	} else {
+		var baseModelTag string
+		for i := 0; i < typ.NumField(); i++ {
+			if sf := typ.Field(i); sf.Anonymous && sf.Type.Name() == "BaseModel" {
+				baseModelTag = sf.Tag.Get("bun")
+			}
+		}
+		spec.Table = TableName(typ.Name(), baseModelTag)
+	}
+
+	// presetIndexes holds the blind index columns of presets, set once
+	// every column of the model is known
+	presetIndexes := make(map[*FieldSpec]string)
+	for i := 0; i < typ.NumField(); i++ {
+		sf := typ.Field(i)
+		bunTag := sf.Tag.Get("bun")
+		if sf.Anonymous && sf.Type.Name() == "BaseModel" {
+			continue
+		}
+
+		column := ColumnName(sf.Name, bunTag)
+		if inner, nestedPrefix, ok := nestedStruct(sf, column); ok {
+			if nested := visiting[inner]; nested != nil {
+				// Recursive types reuse the spec being built
+				spec.nested = append(spec.nested, nestedModel{index: sf.Index, name: sf.Name, spec: nested})
+			} else if nested := buildSpec(inner, spec, prefix+nestedPrefix, declarations, visiting); len(nested.Fields) > 0 || len(nested.nested) > 0 || len(nested.classified) > 0 {
+				spec.nested = append(spec.nested, nestedModel{index: sf.Index, name: sf.Name, spec: nested})
+			}
+		}
+		if column == "" {
+			continue
+		}
+		spec.columns[column] = sf.Index
+		if serializer := sf.Tag.Get("serializer"); serializer != "" {
+			spec.serialized = append(spec.serialized, serializedField{index: sf.Index, serializer: serializer})
+		}
+		if generalized, ok := parseGeneralize(sf, column); ok {
+			spec.generalized = append(spec.generalized, generalized)
+		}
+
+		declared, isDeclared := declarations[spec.Table][prefix+column]
+		if sf.Tag.Get("encrypted") != "true" && !isDeclared {
+			if class := sf.Tag.Get("class"); class != "" {
+				spec.classified = append(spec.classified, classifiedColumn{name: sf.Name, column: prefix + column, class: class})
+			}
+			continue
+		}
+
+		field := &FieldSpec{
+			Index:         sf.Index,
+			Name:          sf.Name,
+			Column:        prefix + column,
+			Codec:         sf.Tag.Get("codec"),
+			Algorithm:     Algorithm(sf.Tag.Get("algorithm")),
+			Envelope:      sf.Tag.Get("envelope"),
+			Deterministic: sf.Tag.Get("deterministic") == "true",
+			BlindIndex:    sf.Tag.Get("blindindex"),
+			PlainHash:     sf.Tag.Get("plainhash"),
+			TokenIndex:    sf.Tag.Get("tokenindex"),
+			Bloom:         sf.Tag.Get("bloom"),
+			NullZero:      hasTagFlag(bunTag, "nullzero"),
+			Binary:        sf.Tag.Get("storage") == "binary",
+		}
+		if bits, err := strconv.Atoi(sf.Tag.Get("blindindexbits")); err == nil && bits > 0 {
+			field.BlindIndexBits = bits
+		}
+		parseBloom(field, sf.Tag)
+		field.EmailDomain = sf.Tag.Get("emaildomain")
+		field.EmailDomainHash = sf.Tag.Get("emaildomainhash") == "true"
+		field.Geohash = sf.Tag.Get("geohash")
+		field.GeohashPrecision = parseGeohashPrecision(sf.Tag.Get("geohashprecision"))
+		if bounds := parseBucketBounds(sf.Tag.Get("bucketbounds")); len(bounds) > 0 {
+			field.Bucket, field.BucketBounds = sf.Tag.Get("bucket"), bounds
+		}
+		if ttl, err := time.ParseDuration(sf.Tag.Get("encryptttl")); err == nil && ttl > 0 {
+			field.TTL = ttl
+			field.TTLColumn = sf.Tag.Get("encryptttlcolumn")
+		}
+		field.Transforms = parseTransforms(sf.Tag.Get("transform"))
+		field.Normalizers = parseNormalizers(sf.Tag.Get("normalize"))
+		if sf.Tag.Get("pan") == "true" {
+			field.setPAN()
+		}
+		field.PANBIN = sf.Tag.Get("panbin")
+		field.PANLast4 = sf.Tag.Get("panlast4")
+		field.PANToken = sf.Tag.Get("pantoken")
+		field.Preset = sf.Tag.Get("preset")
+		field.Class = sf.Tag.Get("class")
+		field.PadBuckets = parsePadBuckets(sf.Tag.Get("encryptpad"))
+		if sf.Type.Kind() == reflect.Slice && sf.Type.Elem().Kind() == reflect.String {
+			field.Array = ArrayElements
+			if sf.Tag.Get("encryptarray") == ArrayWhole {
+				field.Array = ArrayWhole
+			}
+		}
+		if isDeclared {
+			declared.apply(field)
+		}
+		if applyPreset(field) && field.BlindIndex == "" {
+			presetIndexes[field] = column + "_bidx"
+		}
+		if field.BlindIndexBits == 0 {
+			field.BlindIndexBits = DefaultBlindIndexBits
+		}
+		spec.Fields = append(spec.Fields, field)
+		spec.byName[sf.Name] = field
+	}
+
+	for field, column := range presetIndexes {
+		if _, ok := spec.columns[column]; ok {
+			field.BlindIndex = column
+		}
+	}
+
+	// TTLs count from updated_at, or created_at, unless set per field
+	for _, field := range spec.Fields {
+		if field.TTL == 0 || field.TTLColumn != "" || parent != nil {
+			continue
+		}
+		for _, column := range []string{"updated_at", "created_at"} {
+			if _, ok := spec.columns[column]; ok {
+				field.TTLColumn = column
+				break
+			}
+		}
+	}
+
+	return spec
+}
+
+// nestedStruct returns the struct type of a field that may be a nested
+// model, and the prefix of its columns: the prefix of bun:"embed:", none
+// for an anonymous field, or the JSON column and a dot. JSON columns hold
+// a struct, a pointer to one, or a slice or array of either; types that
+// encode themselves, like time.Time and Field, are not nested models.
+func nestedStruct(sf reflect.StructField, column string) (reflect.Type, string, bool) {
+	if sf.Tag.Get("encrypted") == "true" || !sf.IsExported() {
+		return nil, "", false
+	}
+	name := strings.SplitN(sf.Tag.Get("bun"), ",", 2)[0]
+	typ := sf.Type
+	if typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	if embedPrefix, ok := strings.CutPrefix(name, "embed:"); ok {
+		return typ, embedPrefix, typ.Kind() == reflect.Struct
+	}
+	if sf.Anonymous && name == "" {
+		return typ, "", typ.Kind() == reflect.Struct
+	}
+	if column == "" {
+		return nil, "", false
+	}
+
+	if typ.Kind() == reflect.Slice || typ.Kind() == reflect.Array {
+		typ = typ.Elem()
+		if typ.Kind() == reflect.Ptr {
+			typ = typ.Elem()
+		}
+	}
+	if typ.Kind() != reflect.Struct || typ == timeType {
+		return nil, "", false
+	}
+	ptr := reflect.PointerTo(typ)
+	if ptr.Implements(scannerType) || ptr.Implements(valuerType) {
+		return nil, "", false
+	}
+	return typ, column + ".", true
+}
+
+var (
+	timeType    = reflect.TypeFor[time.Time]()
+	scannerType = reflect.TypeFor[sql.Scanner]()
+	valuerType  = reflect.TypeFor[driver.Valuer]()
+)
+
+// parseTransforms parses role=transform pairs separated by commas
+func parseTransforms(tag string) map[string]string {
+	if tag == "" {
+		return nil
+	}
+	transforms := make(map[string]string)
+	for _, pair := range strings.Split(tag, ",") {
+		role, name, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if ok && role != "" && name != "" {
+			transforms[role] = name
+		}
+	}
+	return transforms
+}
+
+// parsePadBuckets parses comma separated bucket sizes. Any invalid size
+// disables padding.
+func parsePadBuckets(tag string) []int {
+	if tag == "" {
+		return nil
+	}
+	var buckets []int
+	for _, size := range strings.Split(tag, ",") {
+		n, err := strconv.Atoi(strings.TrimSpace(size))
+		if err != nil || n <= 0 {
+			return nil
+		}
+		buckets = append(buckets, n)
+	}
+	sort.Ints(buckets)
+	return buckets
+}
+
+// Field returns the spec of the encrypted field with the given Go name
+func (m *ModelSpec) Field(name string) *FieldSpec {
+	return m.byName[name]
+}
+
+// nestedSpec returns the spec of the nested model in the field with the
+// given index, nil when it is not one
+func (m *ModelSpec) nestedSpec(index int) *ModelSpec {
+	for _, nested := range m.nested {
+		if len(nested.index) == 1 && nested.index[0] == index {
+			return nested.spec
+		}
+	}
+	return nil
+}
+
+// ColumnIndex returns the field index mapped to a column
+func (m *ModelSpec) ColumnIndex(column string) ([]int, bool) {
+	index, ok := m.columns[column]
+	return index, ok
+}
+
+// TableName derives the table name of a model type the way bun does, from
+// the bun tag of its BaseModel field, if any
+func TableName(typeName, baseModelTag string) string {
+	if table := tagOption(baseModelTag, "table"); table != "" {
+		return table
+	}
+	return inflection.Plural(underscore(typeName))
+}
+
+// ColumnName derives the column name of a field the way bun does, from its
+// bun tag. It is empty for fields without a column.
+func ColumnName(fieldName, bunTag string) string {
+	if bunTag == "-" {
+		return ""
+	}
+	name := strings.SplitN(bunTag, ",", 2)[0]
+	if strings.Contains(name, ":") {
+		// Relations and other option-only tags have no column
+		if strings.HasPrefix(name, "rel:") || strings.HasPrefix(name, "m2m:") || strings.HasPrefix(name, "embed:") {
+			return ""
+		}
+		name = ""
+	}
+	if name == "" {
+		name = underscore(fieldName)
+	}
+	return name
+}
+
+// tagOption returns the value of key:value in a bun tag
+func tagOption(bunTag, key string) string {
+	for _, opt := range strings.Split(bunTag, ",") {
+		if v, ok := strings.CutPrefix(opt, key+":"); ok {
+			return v
+		}
+	}
+	return ""
+}
+
+// hasTagFlag reports whether a bun tag sets the given option
+func hasTagFlag(bunTag, flag string) bool {
+	for _, opt := range strings.Split(bunTag, ",")[1:] {
+		if opt == flag {
+			return true
+		}
+	}
+	return false
+}
+
+// underscore converts a Go name to snake_case exactly as bun names tables
+// and columns, e.g. UserID to user_id and HTTPServer to http_server. bun
+// keeps its version internal, so this is a copy.
+func underscore(s string) string {
+	b := make([]byte, 0, len(s)+5)
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if isUpperASCII(c) {
+			if i > 0 && i+1 < len(s) && (isLowerASCII(s[i-1]) || isLowerASCII(s[i+1])) {
+				b = append(b, '_')
+			}
+			c += 'a' - 'A'
+		}
+		b = append(b, c)
+	}
+	return string(b)
+}
+
+func isUpperASCII(c byte) bool { return c >= 'A' && c <= 'Z' }
+
+func isLowerASCII(c byte) bool { return c >= 'a' && c <= 'z' }
@@ -0,0 +1,156 @@
+package internal_test
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/muhammadluth/govault/internal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uptrace/bun/dialect/pgdialect"
+)
+
+func TestFieldTTL(t *testing.T) {
+	type session struct {
+		ID        int64
+		Token     string    `bun:"token" encrypted:"true" encryptttl:"720h"`
+		IP        string    `bun:"ip" encrypted:"true" encryptttl:"24h" encryptttlcolumn:"seen_at"`
+		Email     string    `bun:"email" encrypted:"true"`
+		Bad       string    `bun:"bad" encrypted:"true" encryptttl:"30d"`
+		SeenAt    time.Time `bun:"seen_at"`
+		CreatedAt time.Time `bun:"created_at"`
+	}
+
+	spec := internal.GetModelSpec((*session)(nil))
+	require.NotNil(t, spec)
+
+	assert.Equal(t, 720*time.Hour, spec.Field("Token").TTL)
+	assert.Equal(t, "created_at", spec.Field("Token").TTLColumn)
+	assert.Equal(t, 24*time.Hour, spec.Field("IP").TTL)
+	assert.Equal(t, "seen_at", spec.Field("IP").TTLColumn)
+	assert.Zero(t, spec.Field("Email").TTL)
+	assert.Zero(t, spec.Field("Bad").TTL)
+}
+
+func TestFieldPadBuckets(t *testing.T) {
+	type profile struct {
+		Name  string `bun:"name" encrypted:"true" encryptpad:"64, 16,32"`
+		Bio   string `bun:"bio" encrypted:"true" encryptpad:"16,x"`
+		Email string `bun:"email" encrypted:"true"`
+	}
+
+	spec := internal.GetModelSpec((*profile)(nil))
+	require.NotNil(t, spec)
+
+	assert.Equal(t, []int{16, 32, 64}, spec.Field("Name").PadBuckets)
+	assert.Nil(t, spec.Field("Bio").PadBuckets)
+	assert.Nil(t, spec.Field("Email").PadBuckets)
+}
+
+type nestedAddress struct {
+	Street     string `bun:"street" encrypted:"true" blindindex:"street_bidx"`
+	StreetBidx string `bun:"street_bidx"`
+	City       string `bun:"city"`
+}
+
+type NestedAudit struct {
+	Note string `bun:"note" encrypted:"true"`
+}
+
+type nestedContact struct {
+	Phone string         `json:"phone" encrypted:"true"`
+	Next  *nestedContact `json:"next"`
+}
+
+type nestedUser struct {
+	ID int64 `bun:"id,pk"`
+	NestedAudit
+	Home     nestedAddress   `bun:"embed:home_"`
+	Work     *nestedAddress  `bun:"embed:work_"`
+	Contact  nestedContact   `bun:"contact,type:jsonb"`
+	Aliases  []nestedContact `bun:"aliases,type:jsonb"`
+	Previous *nestedAddress  `bun:"previous"`
+	Seen     time.Time       `bun:"seen"`
+}
+
+func TestNestedModels(t *testing.T) {
+	g, err := internal.New(internal.Config{
+		Keys:         map[string][]byte{"1": []byte("727d37a0-a5f2-4d67-af47-83039c8e")},
+		DefaultKeyID: "1",
+	})
+	require.NoError(t, err)
+
+	newUser := func() *nestedUser {
+		return &nestedUser{
+			NestedAudit: NestedAudit{Note: "vip"},
+			Home:        nestedAddress{Street: "Jl. Sudirman 1", City: "Jakarta"},
+			Work:        &nestedAddress{Street: "Jl. Sudirman 1", City: "Jakarta"},
+			Contact:     nestedContact{Phone: "+62811", Next: &nestedContact{Phone: "+62812"}},
+			Aliases:     []nestedContact{{Phone: "+62813"}},
+		}
+	}
+
+	t.Run("nested fields are encrypted and decrypted", func(t *testing.T) {
+		user := newUser()
+		require.NoError(t, g.EncryptModel(user, ""))
+		for _, value := range []string{user.Note, user.Home.Street, user.Work.Street, user.Contact.Phone, user.Contact.Next.Phone, user.Aliases[0].Phone} {
+			assert.True(t, internal.IsEncrypted(value), value)
+		}
+		assert.Equal(t, "Jakarta", user.Home.City)
+
+		require.NoError(t, g.DecryptRecursive(user))
+		want := newUser()
+		want.Home.StreetBidx, want.Work.StreetBidx = user.Home.StreetBidx, user.Work.StreetBidx
+		assert.Equal(t, want, user)
+	})
+
+	t.Run("embedded columns are keyed by their prefix", func(t *testing.T) {
+		user := newUser()
+		require.NoError(t, g.EncryptModel(user, ""))
+		assert.NotEmpty(t, user.Home.StreetBidx)
+		assert.NotEqual(t, user.Home.StreetBidx, user.Work.StreetBidx)
+	})
+
+	t.Run("nil pointers and empty slices are skipped", func(t *testing.T) {
+		user := &nestedUser{Home: nestedAddress{Street: "x"}}
+		require.NoError(t, g.EncryptModel(user, ""))
+		assert.True(t, internal.IsEncrypted(user.Home.Street))
+		assert.Nil(t, user.Work)
+	})
+
+	t.Run("plain struct columns are not nested models", func(t *testing.T) {
+		user := &nestedUser{Previous: &nestedAddress{Street: "plain"}}
+		require.NoError(t, g.EncryptModel(user, ""))
+		assert.True(t, internal.IsEncrypted(user.Previous.Street), "struct columns are JSON in bun")
+
+		spec := internal.GetModelSpec(user)
+		assert.Len(t, spec.Fields, 0, "nested fields are not fields of the model")
+	})
+}
+
+type HTTPServerLog struct {
+	ID       int64
+	UserID   string `encrypted:"true"`
+	HTTPHost string `encrypted:"true"`
+	ClientIP string `encrypted:"true"`
+	PersonA  string `encrypted:"true"`
+	APIKeyV2 string `encrypted:"true"`
+}
+
+func TestNamesMatchBun(t *testing.T) {
+	spec := internal.GetModelSpec(&HTTPServerLog{})
+	require.NotNil(t, spec)
+	table := pgdialect.New().Tables().Get(reflect.TypeOf(HTTPServerLog{}))
+
+	assert.Equal(t, table.Name, spec.Table)
+	require.Len(t, spec.Fields, 5)
+	for _, field := range spec.Fields {
+		column, ok := table.FieldMap[field.Column]
+		if assert.True(t, ok, "bun has no column %s", field.Column) {
+			assert.Equal(t, field.Name, column.GoName)
+		}
+	}
+	assert.Equal(t, "user_id", spec.Field("UserID").Column)
+	assert.Equal(t, "http_host", spec.Field("HTTPHost").Column)
+}
@@ -0,0 +1,292 @@
+package internal
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// FormatPrefix marks values written by govault so they can be told apart
+// from plaintext without guessing
+const FormatPrefix = "gv1:"
+
+// formatSeparator separates the parts of an encrypted value
+const formatSeparator = "|"
+
+// gcmNonceSize is the nonce size used by AES-GCM
+const gcmNonceSize = 12
+
+// gcmTagSize is the authentication tag size of AES-GCM, the shortest
+// valid ciphertext
+const gcmTagSize = 16
+
+// MaxCiphertextLength bounds the encrypted values accepted by the parsers
+const MaxCiphertextLength = 16 << 20
+
+// maxKeyIDLength bounds the key IDs accepted by the parsers
+const maxKeyIDLength = 255
+
+// ErrInvalidCiphertext is matched by every *FormatError
+var ErrInvalidCiphertext = errors.New("invalid ciphertext")
+
+// FormatError reports a malformed encrypted value, e.g. one corrupted in
+// the database
+type FormatError struct {
+	Part   string // the offending part, e.g. "nonce"
+	Reason string
+}
+
+func (e *FormatError) Error() string {
+	return fmt.Sprintf("invalid encrypted data: %s %s", e.Part, e.Reason)
+}
+
+// Is matches ErrInvalidCiphertext
+func (e *FormatError) Is(target error) bool {
+	return target == ErrInvalidCiphertext
+}
+
+// paddedFlag is appended as a fourth part to values whose plaintext was
+// padded with pad
+const paddedFlag = "p"
+
+// paddingBlockSize is the bucket of values padded by EncryptEmptyStrings
+const paddingBlockSize = 16
+
+// envelope is the parsed form of an encrypted value
+type envelope struct {
+	keyID      string
+	nonce      []byte
+	ciphertext []byte
+	padded     bool // plaintext padded with pad
+	legacy     bool // written before FormatPrefix was introduced
+	algorithm  Algorithm
+	// encryptedAt is the Unix time of the encryption, zero when not
+	// embedded, see Config.EmbedEncryptedAt
+	encryptedAt int64
+}
+
+// encryptedAtFlag starts the flag holding the encryption time in Unix
+// seconds, e.g. |t1767225600
+const encryptedAtFlag = "t"
+
+// String serializes the envelope as gv1:key_id|nonce|encrypted_data, with
+// a trailing |p when the plaintext is padded, |x for XChaCha20-Poly1305
+// and |t<unix seconds> for an embedded encryption time
+func (e *envelope) String() string {
+	parts := []string{
+		e.keyID,
+		base64.StdEncoding.EncodeToString(e.nonce),
+		base64.StdEncoding.EncodeToString(e.ciphertext),
+	}
+	parts = append(parts, e.flags()...)
+	return FormatPrefix + strings.Join(parts, formatSeparator)
+}
+
+// flags returns the flags of e in the order they are serialized
+func (e *envelope) flags() []string {
+	var flags []string
+	if e.padded {
+		flags = append(flags, paddedFlag)
+	}
+	if e.algorithm.normalize() == AlgorithmXChaCha20Poly1305 {
+		flags = append(flags, xchachaFlag)
+	}
+	if e.encryptedAt != 0 {
+		flags = append(flags, e.timestampFlag())
+	}
+	return flags
+}
+
+// parseEnvelope parses both the prefixed format and the legacy
+// key_id|nonce|encrypted_data format. Malformed values fail with a
+// *FormatError.
+func parseEnvelope(data string) (*envelope, error) {
+	if len(data) > MaxCiphertextLength {
+		return nil, &FormatError{Part: "value", Reason: fmt.Sprintf("exceeds %d bytes", MaxCiphertextLength)}
+	}
+	legacy := !strings.HasPrefix(data, FormatPrefix)
+	body := strings.TrimPrefix(data, FormatPrefix)
+
+	parts := strings.Split(body, formatSeparator)
+	padded := false
+	algorithm := AlgorithmAESGCM
+	var encryptedAt int64
+	if len(parts) > 3 && len(parts) <= 6 && !legacy {
+		// Flags follow in order: padding, algorithm, encryption time
+		flags := parts[3:]
+		if flags[0] == paddedFlag {
+			padded, flags = true, flags[1:]
+		}
+		if len(flags) > 0 && flags[0] == xchachaFlag {
+			algorithm, flags = AlgorithmXChaCha20Poly1305, flags[1:]
+		}
+		if len(flags) > 0 {
+			if seconds, ok := parseTimestampFlag(flags[0]); ok {
+				encryptedAt, flags = seconds, flags[1:]
+			}
+		}
+		switch {
+		case len(flags) > 0 && !isFlag(flags[0]):
+			return nil, &FormatError{Part: "flag", Reason: fmt.Sprintf("%q is unknown", flags[0])}
+		case len(flags) == 0:
+			parts = parts[:3]
+		}
+	}
+	if len(parts) != 3 {
+		return nil, &FormatError{Part: "value", Reason: fmt.Sprintf("has %d parts, want 3", len(parts))}
+	}
+
+	if err := checkKeyID(parts[0]); err != nil {
+		return nil, err
+	}
+	nonce, err := decodePart("nonce", parts[1], algorithm.nonceSize(), algorithm.nonceSize())
+	if err != nil {
+		return nil, err
+	}
+	ciphertext, err := decodePart("ciphertext", parts[2], gcmTagSize, MaxCiphertextLength)
+	if err != nil {
+		return nil, err
+	}
+
+	return &envelope{
+		keyID:       parts[0],
+		nonce:       nonce,
+		ciphertext:  ciphertext,
+		padded:      padded,
+		legacy:      legacy,
+		algorithm:   algorithm,
+		encryptedAt: encryptedAt,
+	}, nil
+}
+
+// EncryptedAt returns the encryption time embedded in a native ciphertext
+// in the text or binary encoding, see Config.EmbedEncryptedAt. ok is false
+// for values without one, including those in no native format.
+func EncryptedAt(value string) (t time.Time, ok bool) {
+	if strings.HasPrefix(value, binaryFormatMagic) {
+		decoded, err := DecodeBinary([]byte(value))
+		if err != nil {
+			return time.Time{}, false
+		}
+		value = decoded
+	}
+	env, err := parseEnvelope(value)
+	if err != nil || env.encryptedAt == 0 {
+		return time.Time{}, false
+	}
+	return time.Unix(env.encryptedAt, 0), true
+}
+
+// timestampFlag returns the flag of the encryption time of e
+func (e *envelope) timestampFlag() string {
+	return encryptedAtFlag + strconv.FormatInt(e.encryptedAt, 10)
+}
+
+// aad returns the additional data sealed with e: its flags as serialized,
+// so none can be added, stripped or changed, or nil without flags
+func (e *envelope) aad() []byte {
+	flags := e.flags()
+	if len(flags) == 0 {
+		return nil
+	}
+	return []byte(strings.Join(flags, formatSeparator))
+}
+
+// parseTimestampFlag parses a positive |t<unix seconds> flag
+func parseTimestampFlag(flag string) (int64, bool) {
+	digits, ok := strings.CutPrefix(flag, encryptedAtFlag)
+	if !ok || digits == "" || digits[0] == '0' || digits[0] == '+' {
+		return 0, false
+	}
+	seconds, err := strconv.ParseInt(digits, 10, 64)
+	return seconds, err == nil && seconds > 0
+}
+
+// isFlag reports whether flag is a known flag of gv1
+func isFlag(flag string) bool {
+	_, timestamp := parseTimestampFlag(flag)
+	return flag == paddedFlag || flag == xchachaFlag || timestamp
+}
+
+// checkKeyID rejects empty, oversized and non-printable key IDs
+func checkKeyID(keyID string) error {
+	switch {
+	case keyID == "":
+		return &FormatError{Part: "key ID", Reason: "is empty"}
+	case len(keyID) > maxKeyIDLength:
+		return &FormatError{Part: "key ID", Reason: fmt.Sprintf("exceeds %d bytes", maxKeyIDLength)}
+	case !utf8.ValidString(keyID) || strings.IndexFunc(keyID, unicode.IsControl) >= 0:
+		return &FormatError{Part: "key ID", Reason: "is not printable"}
+	}
+	return nil
+}
+
+// decodePart decodes strict, padded standard base64 of minSize to maxSize
+// bytes. Unlike base64.DecodeString it rejects embedded newlines.
+func decodePart(part, value string, minSize, maxSize int) ([]byte, error) {
+	if strings.ContainsAny(value, "\r\n") {
+		return nil, &FormatError{Part: part, Reason: "is not valid base64"}
+	}
+	decoded, err := base64.StdEncoding.Strict().DecodeString(value)
+	if err != nil {
+		return nil, &FormatError{Part: part, Reason: "is not valid base64"}
+	}
+	if len(decoded) < minSize || len(decoded) > maxSize {
+		if minSize == maxSize {
+			return nil, &FormatError{Part: part, Reason: fmt.Sprintf("is %d bytes, want %d", len(decoded), minSize)}
+		}
+		return nil, &FormatError{Part: part, Reason: fmt.Sprintf("is %d bytes, want %d to %d", len(decoded), minSize, maxSize)}
+	}
+	return decoded, nil
+}
+
+// pad appends 0x80 and zeros (ISO/IEC 7816-4) up to the smallest of the
+// ascending buckets that fits, or a multiple of the largest one, so
+// plaintexts of different lengths within a bucket look alike
+func pad(plaintext []byte, buckets []int) []byte {
+	size := len(plaintext) + 1
+	target := 0
+	for _, bucket := range buckets {
+		if bucket >= size {
+			target = bucket
+			break
+		}
+	}
+	if target == 0 {
+		largest := buckets[len(buckets)-1]
+		target = (size + largest - 1) / largest * largest
+	}
+
+	padded := make([]byte, target)
+	copy(padded, plaintext)
+	padded[len(plaintext)] = 0x80
+	return padded
+}
+
+// unpad removes the padding added by pad
+func unpad(padded []byte) ([]byte, error) {
+	i := bytes.LastIndexByte(padded, 0x80)
+	if i < 0 || len(bytes.Trim(padded[i+1:], "\x00")) != 0 {
+		return nil, fmt.Errorf("invalid plaintext padding")
+	}
+	return padded[:i], nil
+}
+
+// IsEncrypted reports whether value looks like data produced by Encrypt.
+// Prefixed values are always treated as encrypted. Unprefixed values are only
+// treated as legacy ciphertext when they parse as a complete envelope, so
+// plaintext that merely contains pipes is left alone.
+func IsEncrypted(value string) bool {
+	if strings.HasPrefix(value, FormatPrefix) || strings.HasPrefix(value, HPKEFormatPrefix) {
+		return true
+	}
+
+	_, err := parseEnvelope(value)
+	return err == nil
+}
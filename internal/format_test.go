@@ -0,0 +1,384 @@
+package internal_test
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/muhammadluth/govault/internal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type emptyUser struct {
+	Email      string `bun:"email" encrypted:"true" blindindex:"email_bidx"`
+	Nickname   string `bun:"nickname,nullzero" encrypted:"true"`
+	EmailIndex string `bun:"email_bidx"`
+}
+
+func TestEncryptEmptyStrings(t *testing.T) {
+	keys := map[string][]byte{"1": []byte("727d37a0-a5f2-4d67-af47-83039c8e")}
+	plain, err := internal.New(internal.Config{Keys: keys, DefaultKeyID: "1"})
+	require.NoError(t, err)
+	g, err := internal.New(internal.Config{Keys: keys, DefaultKeyID: "1", EncryptEmptyStrings: true})
+	require.NoError(t, err)
+
+	t.Run("empty strings are left alone by default", func(t *testing.T) {
+		encrypted, err := plain.Encrypt("")
+		require.NoError(t, err)
+		assert.Empty(t, encrypted)
+	})
+
+	t.Run("padding hides the plaintext length", func(t *testing.T) {
+		var lengths []int
+		for _, plaintext := range []string{"", "a", "fifteen chars!!"} {
+			encrypted, err := g.Encrypt(plaintext)
+			require.NoError(t, err)
+			assert.True(t, strings.HasSuffix(encrypted, "|p"))
+			lengths = append(lengths, len(encrypted))
+
+			decrypted, err := g.Decrypt(encrypted)
+			require.NoError(t, err)
+			assert.Equal(t, plaintext, decrypted)
+		}
+		assert.Equal(t, lengths[0], lengths[1])
+		assert.Equal(t, lengths[0], lengths[2])
+
+		long, err := g.Encrypt("sixteen chars!!!")
+		require.NoError(t, err)
+		assert.Greater(t, len(long), lengths[0])
+	})
+
+	t.Run("deterministic values are padded", func(t *testing.T) {
+		a, err := g.EncryptField(&internal.FieldSpec{Deterministic: true}, "", "", "")
+		require.NoError(t, err)
+		b, err := g.EncryptField(&internal.FieldSpec{Deterministic: true}, "", "", "")
+		require.NoError(t, err)
+		assert.Equal(t, a, b)
+
+		decrypted, err := g.Decrypt(a)
+		require.NoError(t, err)
+		assert.Empty(t, decrypted)
+	})
+
+	t.Run("padded and unpadded values read on both", func(t *testing.T) {
+		unpadded, err := plain.Encrypt("héllo")
+		require.NoError(t, err)
+		padded, err := g.Encrypt("héllo")
+		require.NoError(t, err)
+
+		for _, reader := range []*internal.GovaultDB{plain, g} {
+			for _, value := range []string{unpadded, padded} {
+				decrypted, err := reader.Decrypt(value)
+				require.NoError(t, err)
+				assert.Equal(t, "héllo", decrypted)
+			}
+		}
+	})
+
+	t.Run("models", func(t *testing.T) {
+		user := &emptyUser{}
+		require.NoError(t, g.EncryptModel(user, ""))
+		assert.True(t, internal.IsEncrypted(user.Email))
+		assert.NotEmpty(t, user.EmailIndex)
+		assert.Empty(t, user.Nickname, "nullzero fields store NULL")
+
+		require.NoError(t, g.DecryptRecursive(user))
+		assert.Empty(t, user.Email)
+
+		// NULL columns scan to empty strings and stay empty
+		null := &emptyUser{}
+		require.NoError(t, g.DecryptRecursive(null))
+		assert.Empty(t, null.Email)
+	})
+
+	t.Run("unknown flags are rejected", func(t *testing.T) {
+		encrypted, err := g.Encrypt("x")
+		require.NoError(t, err)
+		_, err = g.Decrypt(strings.TrimSuffix(encrypted, "p") + "z")
+		assert.ErrorIs(t, err, internal.ErrInvalidCiphertext)
+		assert.ErrorContains(t, err, "flag")
+	})
+}
+
+type paddedUser struct {
+	Name  string `bun:"name" encrypted:"true" encryptpad:"16,32,64"`
+	Token string `bun:"token" encrypted:"true" deterministic:"true" encryptpad:"32"`
+	Email string `bun:"email" encrypted:"true"`
+}
+
+func TestPadBuckets(t *testing.T) {
+	g, err := internal.New(internal.Config{
+		Keys:         map[string][]byte{"1": []byte("727d37a0-a5f2-4d67-af47-83039c8e")},
+		DefaultKeyID: "1",
+	})
+	require.NoError(t, err)
+
+	encryptedLength := func(name string) int {
+		u := &paddedUser{Name: name}
+		require.NoError(t, g.EncryptModel(u, ""))
+		return len(u.Name)
+	}
+
+	// 15 bytes plus the 0x80 marker fit the 16 byte bucket
+	assert.Equal(t, encryptedLength("J"), encryptedLength("Jonathan-Livin"))
+	assert.Equal(t, encryptedLength("Jonathan-Livingston"), encryptedLength("Jonathan Livingston Seagull"))
+	assert.Less(t, encryptedLength("J"), encryptedLength("Jonathan-Livingston"))
+	assert.Equal(t, encryptedLength(strings.Repeat("x", 40)), encryptedLength(strings.Repeat("x", 63)))
+	assert.Equal(t, encryptedLength(strings.Repeat("x", 64)), encryptedLength(strings.Repeat("x", 127)), "larger values pad to multiples of the largest bucket")
+
+	u := &paddedUser{Name: "J", Token: "abc", Email: "j@example.com"}
+	require.NoError(t, g.EncryptModel(u, ""))
+	assert.True(t, strings.HasSuffix(u.Name, "|p"))
+	assert.True(t, strings.HasSuffix(u.Token, "|p"))
+	assert.False(t, strings.HasSuffix(u.Email, "|p"), "fields without encryptpad are not padded")
+
+	same := &paddedUser{Token: "abc"}
+	require.NoError(t, g.EncryptModel(same, ""))
+	assert.Equal(t, u.Token, same.Token, "padding keeps deterministic values deterministic")
+
+	require.NoError(t, g.DecryptRecursive(u))
+	assert.Equal(t, &paddedUser{Name: "J", Token: "abc", Email: "j@example.com"}, u)
+
+	t.Run("codecs can't pad", func(t *testing.T) {
+		g, err := internal.New(internal.Config{
+			Keys:             map[string][]byte{"1": []byte("727d37a0-a5f2-4d67-af47-83039c8e")},
+			DefaultKeyID:     "1",
+			CipherSweetKeyID: "1",
+		})
+		require.NoError(t, err)
+		err = g.EncryptModel(&paddedCodecUser{Phone: "555-0100"}, "")
+		assert.ErrorContains(t, err, "does not support encryptpad")
+	})
+}
+
+type paddedCodecUser struct {
+	Phone string `bun:"phone" encrypted:"true" codec:"ciphersweet" encryptpad:"16"`
+}
+
+func TestFlagsAuthenticated(t *testing.T) {
+	g, err := internal.New(internal.Config{
+		Keys:         map[string][]byte{"1": []byte("727d37a0-a5f2-4d67-af47-83039c8e")},
+		DefaultKeyID: "1",
+	})
+	require.NoError(t, err)
+
+	padded, err := g.EncryptField(&internal.FieldSpec{PadBuckets: []int{16}}, "", "J", "")
+	require.NoError(t, err)
+	// Ends in 0x80, so it would unpad cleanly if the flag were not sealed
+	plain, err := g.Encrypt("J\u2000")
+	require.NoError(t, err)
+	xchacha, err := g.EncryptField(&internal.FieldSpec{PadBuckets: []int{16}, Algorithm: internal.AlgorithmXChaCha20Poly1305}, "", "J", "")
+	require.NoError(t, err)
+	require.True(t, strings.HasSuffix(xchacha, "|p|x"))
+
+	for name, tampered := range map[string]string{
+		"padding stripped":        strings.TrimSuffix(padded, "|p"),
+		"padding added":           plain + "|p",
+		"timestamp added":         padded + "|t1767225600",
+		"padding stripped from x": strings.Replace(xchacha, "|p|x", "|x", 1),
+		"timestamp added after x": xchacha + "|t1767225600",
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := g.Decrypt(tampered)
+			assert.ErrorContains(t, err, "failed to decrypt")
+		})
+	}
+}
+
+func TestMalformedCiphertext(t *testing.T) {
+	g := newTransformDB(t)
+	valid, err := g.Encrypt("hello")
+	require.NoError(t, err)
+	parts := strings.Split(strings.TrimPrefix(valid, internal.FormatPrefix), "|")
+	nonce, ciphertext := parts[1], parts[2]
+
+	tests := []struct {
+		name  string
+		value string
+		part  string
+	}{
+		{"too few parts", "gv1:1|" + nonce, "value"},
+		{"too many parts", "gv1:1|" + nonce + "|" + ciphertext + "|p|p", "value"},
+		{"unknown flag", "gv1:1|" + nonce + "|" + ciphertext + "|z", "flag"},
+		{"empty key ID", "gv1:|" + nonce + "|" + ciphertext, "key ID"},
+		{"control character in key ID", "gv1:1\x00|" + nonce + "|" + ciphertext, "key ID"},
+		{"long key ID", "gv1:" + strings.Repeat("k", 256) + "|" + nonce + "|" + ciphertext, "key ID"},
+		{"nonce not base64", "gv1:1|not base64!|" + ciphertext, "nonce"},
+		{"nonce with newline", "gv1:1|" + nonce[:8] + "\n" + nonce[8:] + "|" + ciphertext, "nonce"},
+		{"ciphertext unpadded", "gv1:1|" + nonce + "|" + strings.Repeat("A", 30), "ciphertext"}, // 22 bytes without its == padding
+		{"short nonce", "gv1:1|AAAA|" + ciphertext, "nonce"},
+		{"short ciphertext", "gv1:1|" + nonce + "|AAAA", "ciphertext"},
+		{"oversized", "gv1:1|" + nonce + "|" + strings.Repeat("A", internal.MaxCiphertextLength), "value"},
+		{"hpke encapsulated key", internal.HPKEFormatPrefix + "1|AAAA|" + ciphertext, "encapsulated key"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := g.Decrypt(tt.value)
+			require.ErrorIs(t, err, internal.ErrInvalidCiphertext)
+			var formatErr *internal.FormatError
+			require.ErrorAs(t, err, &formatErr)
+			assert.Equal(t, tt.part, formatErr.Part)
+
+			_, err = g.GetKeyIDFromEncryptedData(tt.value)
+			assert.ErrorIs(t, err, internal.ErrInvalidCiphertext)
+		})
+	}
+
+	t.Run("legacy values must parse to be treated as encrypted", func(t *testing.T) {
+		legacy := strings.TrimPrefix(valid, internal.FormatPrefix)
+		assert.True(t, internal.IsEncrypted(legacy))
+		assert.False(t, internal.IsEncrypted("1|"+nonce+"|AAAA"))
+		assert.False(t, internal.IsEncrypted("a|b|c"))
+	})
+}
+
+func FuzzDecrypt(f *testing.F) {
+	g := newTransformDB(f)
+	valid, err := g.Encrypt("hello")
+	require.NoError(f, err)
+	padded, err := g.EncryptField(&internal.FieldSpec{Deterministic: true}, "", "hello", "")
+	require.NoError(f, err)
+
+	for _, seed := range []string{valid, padded, strings.TrimPrefix(valid, internal.FormatPrefix), valid + "|p", "gv1:", "gv1:||", "gvh1:1||", "a|b|c", ""} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, value string) {
+		plaintext, err := g.Decrypt(value)
+		if err != nil || value == "" {
+			return
+		}
+		// Anything that decrypts was produced with the key
+		assert.True(t, internal.IsEncrypted(value))
+		assert.Equal(t, "hello", plaintext, "value %q", value)
+	})
+}
+
+func FuzzEncryptRoundTrip(f *testing.F) {
+	g := newTransformDB(f)
+	for _, seed := range []string{"", "a", "héllo|world", "gv1:1|x|y", "\x00\x80"} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, plaintext string) {
+		if plaintext == "" {
+			return
+		}
+		encrypted, err := g.Encrypt(plaintext)
+		require.NoError(t, err)
+		assert.True(t, internal.IsEncrypted(encrypted))
+
+		keyID, err := g.GetKeyIDFromEncryptedData(encrypted)
+		require.NoError(t, err)
+		assert.Equal(t, "1", keyID)
+
+		decrypted, err := g.Decrypt(encrypted)
+		require.NoError(t, err)
+		assert.Equal(t, plaintext, decrypted)
+	})
+}
+
+func TestDecryptWithKey(t *testing.T) {
+	var logs bytes.Buffer
+	g, err := internal.New(internal.Config{
+		Keys: map[string][]byte{
+			"1": []byte("727d37a0-a5f2-4d67-af47-83039c8e"),
+			"2": []byte("e778dc27-9b04-44c3-a862-feba061c"),
+		},
+		DefaultKeyID: "1",
+		Logger:       slog.New(slog.NewTextHandler(&logs, nil)),
+	})
+	require.NoError(t, err)
+
+	encrypted, err := g.Encrypt("mislabeled")
+	require.NoError(t, err)
+	mislabeled := strings.Replace(encrypted, "gv1:1|", "gv1:2|", 1)
+
+	_, err = g.Decrypt(mislabeled)
+	require.Error(t, err)
+
+	t.Run("forced key", func(t *testing.T) {
+		plaintext, err := g.DecryptWithKey(mislabeled, "1")
+		require.NoError(t, err)
+		assert.Equal(t, "mislabeled", plaintext)
+		assert.Contains(t, logs.String(), "level=WARN")
+		assert.Contains(t, logs.String(), "key_id=1 labeled_key_id=2 ok=true")
+	})
+
+	t.Run("wrong key material", func(t *testing.T) {
+		logs.Reset()
+		_, err := g.DecryptWithKey(encrypted, "2")
+		require.Error(t, err)
+		assert.Contains(t, logs.String(), "key_id=2 labeled_key_id=1 ok=false")
+	})
+
+	t.Run("unknown key", func(t *testing.T) {
+		_, err := g.DecryptWithKey(mislabeled, "3")
+		assert.ErrorContains(t, err, "encryption key '3' not found")
+	})
+}
+
+func TestEmbedEncryptedAt(t *testing.T) {
+	g, err := internal.New(internal.Config{
+		Keys:             map[string][]byte{"1": []byte("727d37a0-a5f2-4d67-af47-83039c8e")},
+		DefaultKeyID:     "1",
+		EmbedEncryptedAt: true,
+	})
+	require.NoError(t, err)
+
+	before := time.Now().Truncate(time.Second)
+	encrypted, err := g.Encrypt("hello")
+	require.NoError(t, err)
+	assert.Contains(t, encrypted, "|t")
+
+	encryptedAt, ok := internal.EncryptedAt(encrypted)
+	require.True(t, ok)
+	assert.False(t, encryptedAt.Before(before))
+	assert.WithinDuration(t, time.Now(), encryptedAt, time.Minute)
+
+	decrypted, err := g.Decrypt(encrypted)
+	require.NoError(t, err)
+	assert.Equal(t, "hello", decrypted)
+
+	t.Run("the timestamp is authenticated", func(t *testing.T) {
+		i := strings.LastIndex(encrypted, "|t")
+		_, err := g.Decrypt(encrypted[:i] + "|t1000000000")
+		assert.ErrorContains(t, err, "failed to decrypt")
+		_, err = g.Decrypt(encrypted[:i])
+		assert.ErrorContains(t, err, "failed to decrypt")
+	})
+
+	t.Run("binary storage keeps the timestamp", func(t *testing.T) {
+		data, err := internal.EncodeBinary(encrypted)
+		require.NoError(t, err)
+		decoded, err := internal.DecodeBinary(data)
+		require.NoError(t, err)
+		assert.Equal(t, encrypted, decoded)
+
+		binaryAt, ok := internal.EncryptedAt(string(data))
+		require.True(t, ok)
+		assert.Equal(t, encryptedAt, binaryAt)
+	})
+
+	t.Run("inspect reports the timestamp", func(t *testing.T) {
+		info, err := internal.InspectCiphertext(encrypted)
+		require.NoError(t, err)
+		assert.True(t, info.EncryptedAt.Equal(encryptedAt))
+	})
+
+	t.Run("deterministic values have none", func(t *testing.T) {
+		deterministic, err := g.EncryptField(&internal.FieldSpec{Deterministic: true}, "", "hello", "")
+		require.NoError(t, err)
+		_, ok := internal.EncryptedAt(deterministic)
+		assert.False(t, ok)
+	})
+
+	t.Run("invalid timestamps are malformed", func(t *testing.T) {
+		i := strings.LastIndex(encrypted, "|t")
+		for _, flag := range []string{"|t", "|t0", "|t-5", "|t+5", "|t05"} {
+			_, err := g.Decrypt(encrypted[:i] + flag)
+			assert.ErrorIs(t, err, internal.ErrInvalidCiphertext, flag)
+		}
+	})
+}
@@ -0,0 +1,119 @@
+package internal
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+// Units of the plaintext column kept next to an encrypted time, set by the
+// generalizeunit tag
+const (
+	GeneralizeYear   = "year" // the default
+	GeneralizeDecade = "decade"
+)
+
+// generalizedField is a Field[time.Time] column whose year or decade is
+// kept in a plaintext column for coarse range filters:
+//
+//	BirthDate govault.Field[time.Time] `bun:"birth_date,type:text" generalize:"birth_year"`
+//	BirthYear int                      `bun:"birth_year"`
+//
+// The plaintext column reveals the year, or decade, of every value.
+type generalizedField struct {
+	index  []int
+	column string
+	target string // column of the generalized value
+	unit   string
+}
+
+// parseGeneralize reads the generalize and generalizeunit tags of the
+// field stored in column. Unknown units disable the generalization.
+func parseGeneralize(sf reflect.StructField, column string) (generalizedField, bool) {
+	target := sf.Tag.Get("generalize")
+	if target == "" {
+		return generalizedField{}, false
+	}
+	unit := sf.Tag.Get("generalizeunit")
+	switch unit {
+	case "":
+		unit = GeneralizeYear
+	case GeneralizeYear, GeneralizeDecade:
+	default:
+		return generalizedField{}, false
+	}
+	return generalizedField{index: sf.Index, column: column, target: target, unit: unit}, true
+}
+
+// Generalize returns the year of t, or its decade as its first year, e.g.
+// 1990 for 1994
+func Generalize(unit string, t time.Time) int {
+	if unit == GeneralizeDecade {
+		return t.Year() / 10 * 10
+	}
+	return t.Year()
+}
+
+// Generalized returns the plaintext column and unit of the Field[time.Time]
+// stored in column, if it has a generalize tag
+func (m *ModelSpec) Generalized(column string) (target, unit string, ok bool) {
+	for _, g := range m.generalized {
+		if g.column == column {
+			return g.target, g.unit, true
+		}
+	}
+	return "", "", false
+}
+
+// timeField is implemented by Field[time.Time]
+type timeField interface {
+	timeValue() (time.Time, bool)
+}
+
+// setGeneralized writes the generalized values of the time fields of val,
+// a struct of spec, into their plaintext columns: integers, pointers to
+// integers or strings. Invalid fields store zero, or nil.
+func setGeneralized(val reflect.Value, spec *ModelSpec) error {
+	for _, g := range spec.generalized {
+		field := val.FieldByIndex(g.index)
+		f, ok := field.Interface().(timeField)
+		if field.Kind() == reflect.Ptr && field.IsNil() {
+			f = nil
+		} else if !ok {
+			return fmt.Errorf("generalized column '%s' must be a govault.Field[time.Time]", g.column)
+		}
+		index, ok := spec.ColumnIndex(g.target)
+		if !ok {
+			return fmt.Errorf("generalized column '%s' not found in model", g.target)
+		}
+		target := val.FieldByIndex(index)
+		if !target.CanSet() {
+			return fmt.Errorf("generalized column '%s' must be settable", g.target)
+		}
+
+		var t time.Time
+		var valid bool
+		if f != nil {
+			t, valid = f.timeValue()
+		}
+		if !valid {
+			target.SetZero()
+			continue
+		}
+		generalized := Generalize(g.unit, t)
+		if target.Kind() == reflect.Ptr {
+			target.Set(reflect.New(target.Type().Elem()))
+			target = target.Elem()
+		}
+		switch target.Kind() {
+		case reflect.Int, reflect.Int16, reflect.Int32, reflect.Int64:
+			target.SetInt(int64(generalized))
+		case reflect.String:
+			target.SetString(strconv.Itoa(generalized))
+		default:
+			return fmt.Errorf("generalized column '%s' must be an integer or string field", g.target)
+		}
+	}
+	return nil
+}
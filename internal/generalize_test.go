@@ -0,0 +1,66 @@
+package internal_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/muhammadluth/govault/internal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type generalizedPatient struct {
+	ID          int64                         `bun:"id,pk"`
+	BirthDate   internal.Field[time.Time]     `bun:"birth_date,type:text" generalize:"birth_year"`
+	BirthYear   int                           `bun:"birth_year"`
+	DiagnosedAt internal.Field[time.Time]     `bun:"diagnosed_at,type:text" generalize:"diagnosed_decade" generalizeunit:"decade"`
+	Decade      *int16                        `bun:"diagnosed_decade"`
+	DeathDate   *internal.Field[time.Time]    `bun:"death_date,type:text" generalize:"death_year"`
+	DeathYear   string                        `bun:"death_year"`
+	Weight      internal.Field[time.Duration] `bun:"weight,type:text" generalize:"weight_year" generalizeunit:"month"`
+}
+
+func TestGeneralizedTimes(t *testing.T) {
+	g, err := internal.New(internal.Config{
+		Keys:         map[string][]byte{"1": []byte("727d37a0-a5f2-4d67-af47-83039c8e")},
+		DefaultKeyID: "1",
+	})
+	require.NoError(t, err)
+
+	spec := internal.GetModelSpec(&generalizedPatient{})
+	target, unit, ok := spec.Generalized("diagnosed_at")
+	require.True(t, ok)
+	assert.Equal(t, "diagnosed_decade", target)
+	assert.Equal(t, internal.GeneralizeDecade, unit)
+	_, _, ok = spec.Generalized("weight")
+	assert.False(t, ok, "unknown units are ignored")
+
+	t.Run("plaintext columns are filled", func(t *testing.T) {
+		death := internal.NewField(time.Date(2021, 3, 1, 0, 0, 0, 0, time.UTC))
+		p := &generalizedPatient{
+			BirthDate:   internal.NewField(time.Date(1987, 6, 15, 0, 0, 0, 0, time.UTC)),
+			DiagnosedAt: internal.NewField(time.Date(2019, 1, 2, 0, 0, 0, 0, time.UTC)),
+			DeathDate:   &death,
+		}
+		require.NoError(t, g.EncryptModel(p, ""))
+		assert.Equal(t, 1987, p.BirthYear)
+		require.NotNil(t, p.Decade)
+		assert.Equal(t, int16(2010), *p.Decade)
+		assert.Equal(t, "2021", p.DeathYear)
+	})
+
+	t.Run("invalid times clear the columns", func(t *testing.T) {
+		decade := int16(1990)
+		p := &generalizedPatient{BirthYear: 1950, Decade: &decade, DeathYear: "2000"}
+		require.NoError(t, g.EncryptModel(p, ""))
+		assert.Zero(t, p.BirthYear)
+		assert.Nil(t, p.Decade)
+		assert.Empty(t, p.DeathYear)
+	})
+
+	t.Run("generalize", func(t *testing.T) {
+		day := time.Date(1994, 12, 31, 0, 0, 0, 0, time.UTC)
+		assert.Equal(t, 1994, internal.Generalize(internal.GeneralizeYear, day))
+		assert.Equal(t, 1990, internal.Generalize(internal.GeneralizeDecade, day))
+	})
+}
@@ -0,0 +1,185 @@
+package internal
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// geohashDomain separates geohash keys from blind index keys
+const geohashDomain = "govault geohash"
+
+// Geohash precisions, in characters, of the geohash tag. A cell is about
+// 5km wide at 5, 1.2km at 6 and 150m at 7.
+const (
+	defaultGeohashPrecision = 6
+	maxGeohashPrecision     = 12
+)
+
+const geohashAlphabet = "0123456789bcdefghjkmnpqrstuvwxyz"
+
+// FormatLatLon returns the plaintext of a location field, "lat,lon"
+func FormatLatLon(lat, lon float64) string {
+	return strconv.FormatFloat(lat, 'f', -1, 64) + "," + strconv.FormatFloat(lon, 'f', -1, 64)
+}
+
+// ParseLatLon parses the plaintext of a location field, "lat,lon" in
+// degrees
+func ParseLatLon(plaintext string) (lat, lon float64, err error) {
+	latText, lonText, ok := strings.Cut(plaintext, ",")
+	if !ok {
+		return 0, 0, fmt.Errorf("location is not lat,lon")
+	}
+	if lat, err = strconv.ParseFloat(strings.TrimSpace(latText), 64); err != nil || lat < -90 || lat > 90 {
+		return 0, 0, fmt.Errorf("latitude is not a number of degrees between -90 and 90")
+	}
+	if lon, err = strconv.ParseFloat(strings.TrimSpace(lonText), 64); err != nil || lon < -180 || lon > 180 {
+		return 0, 0, fmt.Errorf("longitude is not a number of degrees between -180 and 180")
+	}
+	return lat, lon, nil
+}
+
+// parseGeohashPrecision reads the geohashprecision tag
+func parseGeohashPrecision(tag string) int {
+	if n, err := strconv.Atoi(tag); err == nil && n > 0 && n <= maxGeohashPrecision {
+		return n
+	}
+	return defaultGeohashPrecision
+}
+
+// encodeGeohash returns the geohash of a point with precision characters
+func encodeGeohash(lat, lon float64, precision int) string {
+	minLat, maxLat, minLon, maxLon := -90.0, 90.0, -180.0, 180.0
+	hash := make([]byte, 0, precision)
+	even := true
+	var bits, ch int
+	for len(hash) < precision {
+		if even {
+			mid := (minLon + maxLon) / 2
+			if lon >= mid {
+				ch |= 1 << (4 - bits)
+				minLon = mid
+			} else {
+				maxLon = mid
+			}
+		} else {
+			mid := (minLat + maxLat) / 2
+			if lat >= mid {
+				ch |= 1 << (4 - bits)
+				minLat = mid
+			} else {
+				maxLat = mid
+			}
+		}
+		even = !even
+		if bits++; bits == 5 {
+			hash = append(hash, geohashAlphabet[ch])
+			bits, ch = 0, 0
+		}
+	}
+	return string(hash)
+}
+
+// geohashCellSize returns the height and width in degrees of the cells of
+// a precision
+func geohashCellSize(precision int) (height, width float64) {
+	lonBits := (5*precision + 1) / 2
+	latBits := 5 * precision / 2
+	return 180 / math.Exp2(float64(latBits)), 360 / math.Exp2(float64(lonBits))
+}
+
+// geohashArea returns the geohashes of the cell of a point and of the
+// cells around it, which hold every point nearer than a cell size
+func geohashArea(lat, lon float64, precision int) []string {
+	height, width := geohashCellSize(precision)
+	seen := make(map[string]bool, 9)
+	var hashes []string
+	for _, dLat := range []float64{-height, 0, height} {
+		for _, dLon := range []float64{-width, 0, width} {
+			cellLat := math.Max(-90, math.Min(90, lat+dLat))
+			cellLon := lon + dLon
+			if cellLon >= 180 {
+				cellLon -= 360
+			} else if cellLon < -180 {
+				cellLon += 360
+			}
+			if hash := encodeGeohash(cellLat, cellLon, precision); !seen[hash] {
+				seen[hash] = true
+				hashes = append(hashes, hash)
+			}
+		}
+	}
+	return hashes
+}
+
+// GeohashIndex returns the keyed hash of the geohash cell of a point for
+// field, stored in the column named by its geohash tag:
+//
+//	Location     string `bun:"location" encrypted:"true" geohash:"location_cell" geohashprecision:"6"`
+//	LocationCell string `bun:"location_cell"`
+//
+// The location is written as "lat,lon", see FormatLatLon. The cell is
+// keyed by a subkey of the blind index key bound to the table and column,
+// so the column tells which rows share a cell, but not where it is.
+func (g *GovaultDB) GeohashIndex(field *FieldSpec, table string, lat, lon float64) (string, error) {
+	if field.Geohash == "" {
+		return "", fmt.Errorf("field %s has no geohash", field.Name)
+	}
+	return g.geohashIndex(field, table, encodeGeohash(lat, lon, field.GeohashPrecision))
+}
+
+// GeohashNear returns the keyed hashes of the cell of a point and of the
+// eight cells around it: the values of the geohash column of field for
+// every location nearer to the point than one cell, and some farther.
+func (g *GovaultDB) GeohashNear(field *FieldSpec, table string, lat, lon float64) ([]string, error) {
+	if field.Geohash == "" {
+		return nil, fmt.Errorf("field %s has no geohash", field.Name)
+	}
+	var indexes []string
+	for _, hash := range geohashArea(lat, lon, field.GeohashPrecision) {
+		index, err := g.geohashIndex(field, table, hash)
+		if err != nil {
+			return nil, err
+		}
+		indexes = append(indexes, index)
+	}
+	return indexes, nil
+}
+
+func (g *GovaultDB) geohashIndex(field *FieldSpec, table, hash string) (string, error) {
+	subKey, err := g.blindIndexSubKey(geohashDomain, table, field.Column)
+	if err != nil {
+		return "", err
+	}
+	mac := hmac.New(sha256.New, subKey)
+	mac.Write([]byte(hash))
+	return hex.EncodeToString(truncateBits(mac.Sum(nil), 128)), nil
+}
+
+// setGeohash writes the geohash index of plaintext, a location, into the
+// companion column
+func (g *GovaultDB) setGeohash(val reflect.Value, spec *ModelSpec, field *FieldSpec, plaintext string) error {
+	target, err := companionField(val, spec, field.Geohash, "geohash")
+	if err != nil {
+		return err
+	}
+	if plaintext == "" {
+		target.SetString("")
+		return nil
+	}
+	lat, lon, err := ParseLatLon(plaintext)
+	if err != nil {
+		return err
+	}
+	index, err := g.GeohashIndex(field, spec.Table, lat, lon)
+	if err != nil {
+		return err
+	}
+	target.SetString(index)
+	return nil
+}
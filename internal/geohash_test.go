@@ -0,0 +1,72 @@
+package internal_test
+
+import (
+	"testing"
+
+	"github.com/muhammadluth/govault/internal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type geohashShop struct {
+	ID           int64  `bun:"id,pk"`
+	Location     string `bun:"location" encrypted:"true" geohash:"location_cell" geohashprecision:"5"`
+	LocationCell string `bun:"location_cell"`
+}
+
+type geohashCourier struct {
+	Location     string `bun:"location" encrypted:"true" geohash:"location_cell"`
+	LocationCell string `bun:"location_cell"`
+}
+
+func TestGeohash(t *testing.T) {
+	g, err := internal.New(internal.Config{
+		Keys:         map[string][]byte{"1": []byte("727d37a0-a5f2-4d67-af47-83039c8e")},
+		DefaultKeyID: "1",
+	})
+	require.NoError(t, err)
+
+	spec := internal.GetModelSpec(&geohashShop{})
+	assert.Equal(t, 5, spec.Field("Location").GeohashPrecision)
+	assert.Equal(t, 6, internal.GetModelSpec(&geohashCourier{}).Field("Location").GeohashPrecision)
+
+	cellOf := func(lat, lon float64) string {
+		shop := &geohashShop{Location: internal.FormatLatLon(lat, lon)}
+		require.NoError(t, g.EncryptModel(shop, ""))
+		assert.True(t, internal.IsEncrypted(shop.Location))
+		return shop.LocationCell
+	}
+
+	t.Run("points of a cell share its index", func(t *testing.T) {
+		// Both in geohash u4pru
+		assert.Equal(t, cellOf(57.64911, 10.40744), cellOf(57.65, 10.41))
+		assert.NotEqual(t, cellOf(57.64911, 10.40744), cellOf(-6.2, 106.8))
+	})
+
+	t.Run("near cells cover the neighbours", func(t *testing.T) {
+		near, err := g.GeohashNear(spec.Field("Location"), spec.Table, 57.64911, 10.40744)
+		require.NoError(t, err)
+		assert.Len(t, near, 9)
+		assert.Contains(t, near, cellOf(57.64911, 10.40744))
+		// Across the northern edge of u4pru, in u4r2h
+		assert.Contains(t, near, cellOf(57.7, 10.40744))
+		assert.NotContains(t, near, cellOf(58.5, 10.40744))
+	})
+
+	t.Run("cells are keyed per column", func(t *testing.T) {
+		courier := &geohashCourier{Location: "57.64911,10.40744"}
+		require.NoError(t, g.EncryptModel(courier, ""))
+		assert.NotEqual(t, cellOf(57.64911, 10.40744), courier.LocationCell)
+	})
+
+	t.Run("invalid locations are rejected", func(t *testing.T) {
+		for _, location := range []string{"57.6", "north,10", "91,10", "10,181"} {
+			err := g.EncryptModel(&geohashShop{Location: location}, "")
+			assert.Error(t, err, location)
+		}
+		lat, lon, err := internal.ParseLatLon(" -6.2 , 106.8 ")
+		require.NoError(t, err)
+		assert.Equal(t, -6.2, lat)
+		assert.Equal(t, 106.8, lon)
+	})
+}
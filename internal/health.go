@@ -0,0 +1,104 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// warmParallelism bounds the provider calls made by WarmKeys at once
+const warmParallelism = 8
+
+// KeyStatus is the state of one key reported by KeyStatuses
+type KeyStatus struct {
+	KeyID       string `json:"key_id"`
+	Provider    bool   `json:"provider"`
+	Ready       bool   `json:"ready"`
+	Default     bool   `json:"default"`
+	DecryptOnly bool   `json:"decrypt_only"`
+}
+
+// WarmKeys unwraps all provider keys in parallel, so the first request does
+// not absorb the KMS latency. Call it at startup; keys that fail stay
+// wrapped and are retried on first use. It returns the joined errors.
+func (g *GovaultDB) WarmKeys(ctx context.Context) error {
+	var pending []*Key
+	for _, key := range g.keySet.Load().keys {
+		if key.cipher == nil {
+			pending = append(pending, key)
+		}
+	}
+
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		errs []error
+		sem  = make(chan struct{}, warmParallelism)
+	)
+	for _, key := range pending {
+		wg.Add(1)
+		go func(key *Key) {
+			defer wg.Done()
+			select {
+			case sem <- struct{}{}:
+				defer func() { <-sem }()
+			case <-ctx.Done():
+				mu.Lock()
+				errs = append(errs, fmt.Errorf("failed to warm key '%s': %w", key.ID, ctx.Err()))
+				mu.Unlock()
+				return
+			}
+
+			if _, err := g.unwrapKey(ctx, key); err != nil {
+				mu.Lock()
+				errs = append(errs, err)
+				mu.Unlock()
+			}
+		}(key)
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// KeyStatuses reports the state of every key, sorted by key ID
+func (g *GovaultDB) KeyStatuses() []KeyStatus {
+	set := g.keySet.Load()
+	statuses := make([]KeyStatus, 0, len(set.keys))
+	for id, key := range set.keys {
+		statuses = append(statuses, KeyStatus{
+			KeyID:       id,
+			Provider:    key.wrapped != nil,
+			Ready:       key.cipher != nil,
+			Default:     id == set.defaultKey,
+			DecryptOnly: key.DecryptOnly,
+		})
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].KeyID < statuses[j].KeyID })
+	return statuses
+}
+
+// HealthCheck fails unless the keys needed to encrypt and index are usable:
+// the default, blind index and ciphersweet keys. Wrapped keys are unwrapped
+// with ctx, so a passing check also warms them.
+func (g *GovaultDB) HealthCheck(ctx context.Context) error {
+	required := []string{g.GetDefaultKeyID(), g.blindIndexKey, g.cipherSweetKeyID}
+
+	seen := make(map[string]bool)
+	for _, keyID := range required {
+		if keyID == "" || seen[keyID] {
+			continue
+		}
+		seen[keyID] = true
+
+		key, exists := g.keyEntry(keyID)
+		if !exists {
+			return fmt.Errorf("encryption key '%s' not found", keyID)
+		}
+		if _, err := g.unwrapKey(ctx, key); err != nil {
+			return fmt.Errorf("key '%s' is not ready: %w", keyID, err)
+		}
+	}
+	return nil
+}
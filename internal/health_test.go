@@ -0,0 +1,147 @@
+package internal_test
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/muhammadluth/govault/internal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWarmKeys(t *testing.T) {
+	var calls, inflight, maxInflight atomic.Int32
+	provider := internal.KeyProviderFunc(func(ctx context.Context, region, keyURI string, wrapped []byte) ([]byte, error) {
+		calls.Add(1)
+		n := inflight.Add(1)
+		defer inflight.Add(-1)
+		for {
+			m := maxInflight.Load()
+			if n <= m || maxInflight.CompareAndSwap(m, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		if keyURI == "broken" {
+			return nil, errors.New("access denied")
+		}
+		return wrapped, nil
+	})
+
+	providerKeys := map[string]internal.ProviderKey{}
+	for _, id := range []string{"a", "b", "c", "d"} {
+		providerKeys[id] = internal.ProviderKey{
+			Wrapped: []byte("727d37a0-a5f2-4d67-af47-83039c8e"),
+			KeyURIs: map[string]string{"eu-west-1": "uri-" + id},
+		}
+	}
+	providerKeys["old"] = internal.ProviderKey{KeyURIs: map[string]string{"eu-west-1": "broken"}}
+
+	g, err := internal.New(internal.Config{
+		Keys:         map[string][]byte{"local": []byte("e778dc27-9b04-44c3-a862-feba061c")},
+		KeyProvider:  provider,
+		ProviderKeys: providerKeys,
+		DefaultKeyID: "a",
+	})
+	require.NoError(t, err)
+
+	for _, status := range g.KeyStatuses() {
+		assert.Equal(t, status.KeyID == "local", status.Ready, status.KeyID)
+	}
+
+	err = g.WarmKeys(context.Background())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "'old'")
+	assert.EqualValues(t, 5, calls.Load())
+	assert.Greater(t, maxInflight.Load(), int32(1))
+
+	for _, status := range g.KeyStatuses() {
+		assert.Equal(t, status.KeyID != "old", status.Ready, status.KeyID)
+		assert.Equal(t, status.KeyID != "local", status.Provider, status.KeyID)
+		assert.Equal(t, status.KeyID == "a", status.Default, status.KeyID)
+	}
+
+	// Warm keys are not unwrapped again
+	_, err = g.Encrypt("secret", "b")
+	require.NoError(t, err)
+	assert.EqualValues(t, 5, calls.Load())
+
+	// Only the default, blind index and ciphersweet keys are required
+	assert.NoError(t, g.HealthCheck(context.Background()))
+}
+
+func TestHealthCheck(t *testing.T) {
+	down := internal.KeyProviderFunc(func(ctx context.Context, region, keyURI string, wrapped []byte) ([]byte, error) {
+		return nil, errors.New("kms unavailable")
+	})
+	g, err := internal.New(internal.Config{
+		KeyProvider: down,
+		ProviderKeys: map[string]internal.ProviderKey{
+			"kms": {Wrapped: []byte("727d37a0-a5f2-4d67-af47-83039c8e"), KeyURIs: map[string]string{"eu-west-1": "eu"}},
+		},
+		DefaultKeyID: "kms",
+	})
+	require.NoError(t, err)
+
+	err = g.HealthCheck(context.Background())
+	assert.ErrorIs(t, err, internal.ErrKeyUnavailable)
+
+	assert.NoError(t, newTestVault(t).HealthCheck(context.Background()))
+}
+
+func TestKeysContext(t *testing.T) {
+	release := make(chan struct{})
+	started := make(chan struct{}, 1)
+	provider := internal.KeyProviderFunc(func(ctx context.Context, region, keyURI string, wrapped []byte) ([]byte, error) {
+		started <- struct{}{}
+		select {
+		case <-release:
+			return wrapped, nil
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	})
+	g, err := internal.New(internal.Config{
+		KeyProvider: provider,
+		ProviderKeys: map[string]internal.ProviderKey{
+			"kms": {Wrapped: []byte("727d37a0-a5f2-4d67-af47-83039c8e"), KeyURIs: map[string]string{"eu-west-1": "eu"}},
+		},
+		DefaultKeyID: "kms",
+	})
+	require.NoError(t, err)
+
+	type row struct {
+		Email string `encrypted:"true"`
+	}
+
+	t.Run("encrypt with a deadline", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+		err := g.EncryptModelContext(ctx, &row{Email: "a@example.com"}, "")
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		<-started
+
+		_, err = g.EncryptContext(ctx, "secret")
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+
+	t.Run("warm waits for an unwrap in flight with its ctx", func(t *testing.T) {
+		done := make(chan error, 1)
+		go func() {
+			_, err := g.Encrypt("secret")
+			done <- err
+		}()
+		<-started
+
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+		assert.ErrorIs(t, g.WarmKeys(ctx), context.DeadlineExceeded)
+
+		close(release)
+		require.NoError(t, <-done)
+		assert.NoError(t, g.WarmKeys(context.Background()))
+	})
+}
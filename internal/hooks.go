@@ -0,0 +1,67 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+)
+
+// Operation names the query a ModelEvent belongs to
+type Operation string
+
+const (
+	OperationSelect Operation = "SELECT"
+	OperationInsert Operation = "INSERT"
+	OperationUpdate Operation = "UPDATE"
+	OperationDelete Operation = "DELETE"
+	OperationRaw    Operation = "RAW"
+	OperationValues Operation = "VALUES"
+)
+
+// ModelEvent is passed to model hooks
+type ModelEvent struct {
+	Operation Operation
+	// Model is the plaintext model, or a scan destination
+	Model any
+}
+
+// ModelHook sees the models of the wrapper queries in plaintext, e.g. for
+// response shaping. Hooks run in the order configured and the first error
+// fails the query.
+type ModelHook interface {
+	// BeforeEncrypt is called when a model is set on an insert or update,
+	// before it is encrypted and so before the BeforeQuery hooks of the ORM
+	BeforeEncrypt(event *ModelEvent) error
+	// AfterDecrypt is called with every scan destination once decrypted,
+	// after the AfterQuery hooks of the ORM
+	AfterDecrypt(ctx context.Context, event *ModelEvent) error
+}
+
+// checkModelHooks rejects hooks not explicitly allowed to see plaintext
+func checkModelHooks(config Config) error {
+	if len(config.ModelHooks) > 0 && !config.ExposePlaintextToHooks {
+		return fmt.Errorf("model hooks see plaintext, set ExposePlaintextToHooks to use them")
+	}
+	return nil
+}
+
+// BeforeEncrypt runs the BeforeEncrypt model hooks
+func (g *GovaultDB) BeforeEncrypt(operation Operation, model any) error {
+	for _, hook := range g.modelHooks {
+		if err := hook.BeforeEncrypt(&ModelEvent{Operation: operation, Model: model}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// AfterDecrypt runs the AfterDecrypt model hooks for every destination
+func (g *GovaultDB) AfterDecrypt(ctx context.Context, operation Operation, dest ...any) error {
+	for _, d := range dest {
+		for _, hook := range g.modelHooks {
+			if err := hook.AfterDecrypt(ctx, &ModelEvent{Operation: operation, Model: d}); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
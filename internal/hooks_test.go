@@ -0,0 +1,55 @@
+package internal_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/muhammadluth/govault/internal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingHook records the events it sees and fails with err
+type recordingHook struct {
+	name   string
+	events *[]string
+	err    error
+}
+
+func (h recordingHook) BeforeEncrypt(event *internal.ModelEvent) error {
+	*h.events = append(*h.events, h.name+" before "+string(event.Operation))
+	return h.err
+}
+
+func (h recordingHook) AfterDecrypt(_ context.Context, event *internal.ModelEvent) error {
+	*h.events = append(*h.events, h.name+" after "+string(event.Operation))
+	return h.err
+}
+
+func TestModelHooks(t *testing.T) {
+	var events []string
+	config := internal.Config{
+		Keys:         map[string][]byte{"1": []byte("727d37a0-a5f2-4d67-af47-83039c8e")},
+		DefaultKeyID: "1",
+		ModelHooks:   []internal.ModelHook{recordingHook{name: "a", events: &events}, recordingHook{name: "b", events: &events}},
+	}
+	_, err := internal.New(config)
+	assert.ErrorContains(t, err, "set ExposePlaintextToHooks")
+
+	config.ExposePlaintextToHooks = true
+	g, err := internal.New(config)
+	require.NoError(t, err)
+
+	require.NoError(t, g.BeforeEncrypt(internal.OperationInsert, &struct{}{}))
+	require.NoError(t, g.AfterDecrypt(context.Background(), internal.OperationSelect, &struct{}{}, &struct{}{}))
+	assert.Equal(t, []string{"a before INSERT", "b before INSERT", "a after SELECT", "b after SELECT", "a after SELECT", "b after SELECT"}, events)
+
+	failing := errors.New("rejected")
+	config.ModelHooks = []internal.ModelHook{recordingHook{name: "a", events: &events, err: failing}, recordingHook{name: "b", events: &events}}
+	g, err = internal.New(config)
+	require.NoError(t, err)
+	events = nil
+	assert.ErrorIs(t, g.AfterDecrypt(context.Background(), internal.OperationSelect, &struct{}{}), failing)
+	assert.Equal(t, []string{"a after SELECT"}, events, "the first error stops the pipeline")
+}
@@ -0,0 +1,257 @@
+package internal
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/hkdf"
+)
+
+// HPKEFormatPrefix marks values sealed to a recipient public key, as
+// opposed to FormatPrefix values encrypted with a shared AEAD key
+const HPKEFormatPrefix = "gvh1:"
+
+// HPKE suite: DHKEM(X25519, HKDF-SHA256), HKDF-SHA256, AES-256-GCM, base
+// mode (RFC 9180)
+const (
+	hpkeKEMID    = 0x0020
+	hpkeKDFID    = 0x0001
+	hpkeAEADID   = 0x0002
+	hpkeModeBase = 0x00
+	hpkeKeySize  = 32
+)
+
+// hpkeInfo binds sealed values to govault
+var hpkeInfo = []byte("govault hpke v1")
+
+// hpkeKey is an X25519 recipient key. private is nil on write-only
+// instances.
+type hpkeKey struct {
+	public  *ecdh.PublicKey
+	private *ecdh.PrivateKey
+}
+
+// GenerateHPKEKey returns a new X25519 key pair for Config.HPKEPublicKeys
+// and Config.HPKEPrivateKeys
+func GenerateHPKEKey() (publicKey, privateKey []byte, err error) {
+	priv, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to generate HPKE key: %w", err)
+	}
+	return priv.PublicKey().Bytes(), priv.Bytes(), nil
+}
+
+// newHPKEKey builds a key from its public and/or private bytes. The public
+// key is derived when only the private key is given.
+func newHPKEKey(keyID string, publicKey, privateKey []byte) (*Key, error) {
+	k := &hpkeKey{}
+	if privateKey != nil {
+		priv, err := ecdh.X25519().NewPrivateKey(privateKey)
+		if err != nil {
+			return nil, fmt.Errorf("invalid HPKE private key: %w", err)
+		}
+		k.private = priv
+		k.public = priv.PublicKey()
+	}
+	if publicKey != nil {
+		pub, err := ecdh.X25519().NewPublicKey(publicKey)
+		if err != nil {
+			return nil, fmt.Errorf("invalid HPKE public key: %w", err)
+		}
+		if k.public != nil && !k.public.Equal(pub) {
+			return nil, fmt.Errorf("HPKE public key does not match private key")
+		}
+		k.public = pub
+	}
+	return &Key{ID: keyID, hpke: k}, nil
+}
+
+// hpkeValue is a value produced by sealHPKE
+type hpkeValue struct {
+	keyID      string
+	enc        []byte
+	ciphertext []byte
+	padded     bool
+}
+
+// aad returns the associated data of the value. Padded values
+// authenticate their flag so it can't be stripped; unpadded ones have none,
+// as before padding was supported.
+func (v *hpkeValue) aad() []byte {
+	if v.padded {
+		return []byte(formatSeparator + paddedFlag)
+	}
+	return nil
+}
+
+// sealHPKE encrypts plaintext to the public key as
+// gvh1:key_id|encapsulated_key|encrypted_data, followed by |p when the
+// plaintext was padded with pad
+func sealHPKE(key *Key, plaintext []byte, padded bool) (string, error) {
+	ephemeral, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate ephemeral key: %w", err)
+	}
+	dh, err := ephemeral.ECDH(key.hpke.public)
+	if err != nil {
+		return "", fmt.Errorf("failed to compute shared secret: %w", err)
+	}
+
+	v := &hpkeValue{keyID: key.ID, enc: ephemeral.PublicKey().Bytes(), padded: padded}
+	aead, nonce, err := hpkeContext(dh, v.enc, key.hpke.public.Bytes())
+	if err != nil {
+		return "", err
+	}
+	v.ciphertext = aead.Seal(nil, nonce, plaintext, v.aad())
+
+	parts := []string{
+		v.keyID,
+		base64.StdEncoding.EncodeToString(v.enc),
+		base64.StdEncoding.EncodeToString(v.ciphertext),
+	}
+	if padded {
+		parts = append(parts, paddedFlag)
+	}
+	return HPKEFormatPrefix + strings.Join(parts, formatSeparator), nil
+}
+
+// parseHPKE splits a value produced by sealHPKE into its parts. Malformed
+// values fail with a *FormatError.
+func parseHPKE(data string) (*hpkeValue, error) {
+	if len(data) > MaxCiphertextLength {
+		return nil, &FormatError{Part: "value", Reason: fmt.Sprintf("exceeds %d bytes", MaxCiphertextLength)}
+	}
+	parts := strings.Split(strings.TrimPrefix(data, HPKEFormatPrefix), formatSeparator)
+	if len(parts) != 3 && len(parts) != 4 {
+		return nil, &FormatError{Part: "value", Reason: fmt.Sprintf("has %d parts, want 3 or 4", len(parts))}
+	}
+	if err := checkKeyID(parts[0]); err != nil {
+		return nil, err
+	}
+	v := &hpkeValue{keyID: parts[0]}
+	if len(parts) == 4 {
+		if parts[3] != paddedFlag {
+			return nil, &FormatError{Part: "flag", Reason: fmt.Sprintf("%q is unknown", parts[3])}
+		}
+		v.padded = true
+	}
+	var err error
+	if v.enc, err = decodePart("encapsulated key", parts[1], hpkeKeySize, hpkeKeySize); err != nil {
+		return nil, err
+	}
+	if v.ciphertext, err = decodePart("ciphertext", parts[2], gcmTagSize, MaxCiphertextLength); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// openHPKE decrypts a value produced by sealHPKE
+func (g *GovaultDB) openHPKE(data string) (string, error) {
+	v, err := parseHPKE(data)
+	if err != nil {
+		return "", err
+	}
+
+	key, err := g.lookupDecryptKey(v.keyID)
+	if err != nil {
+		return "", err
+	}
+	if key.hpke == nil {
+		return "", fmt.Errorf("key '%s' is not an HPKE key", key.ID)
+	}
+	if key.hpke.private == nil {
+		return "", fmt.Errorf("HPKE key '%s' has no private key on this instance", key.ID)
+	}
+
+	ephemeral, err := ecdh.X25519().NewPublicKey(v.enc)
+	if err != nil {
+		return "", fmt.Errorf("invalid encapsulated key: %w", err)
+	}
+	dh, err := key.hpke.private.ECDH(ephemeral)
+	if err != nil {
+		return "", fmt.Errorf("failed to compute shared secret: %w", err)
+	}
+
+	aead, nonce, err := hpkeContext(dh, v.enc, key.hpke.public.Bytes())
+	if err != nil {
+		return "", err
+	}
+	plaintext, err := aead.Open(nil, nonce, v.ciphertext, v.aad())
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt: %w", err)
+	}
+	if v.padded {
+		if plaintext, err = unpad(plaintext); err != nil {
+			return "", err
+		}
+	}
+	return string(plaintext), nil
+}
+
+// hpkeContext runs the DHKEM shared secret derivation and the base mode key
+// schedule, returning the AEAD and the nonce of the first message
+func hpkeContext(dh, enc, recipient []byte) (cipher.AEAD, []byte, error) {
+	kemSuite := binary.BigEndian.AppendUint16([]byte("KEM"), hpkeKEMID)
+	eaePRK := hpkeLabeledExtract(kemSuite, nil, "eae_prk", dh)
+	sharedSecret, err := hpkeLabeledExpand(kemSuite, eaePRK, "shared_secret", append(append([]byte{}, enc...), recipient...), hpkeKeySize)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	suite := []byte("HPKE")
+	suite = binary.BigEndian.AppendUint16(suite, hpkeKEMID)
+	suite = binary.BigEndian.AppendUint16(suite, hpkeKDFID)
+	suite = binary.BigEndian.AppendUint16(suite, hpkeAEADID)
+
+	scheduleContext := []byte{hpkeModeBase}
+	scheduleContext = append(scheduleContext, hpkeLabeledExtract(suite, nil, "psk_id_hash", nil)...)
+	scheduleContext = append(scheduleContext, hpkeLabeledExtract(suite, nil, "info_hash", hpkeInfo)...)
+	secret := hpkeLabeledExtract(suite, sharedSecret, "secret", nil)
+
+	key, err := hpkeLabeledExpand(suite, secret, "key", scheduleContext, hpkeKeySize)
+	if err != nil {
+		return nil, nil, err
+	}
+	nonce, err := hpkeLabeledExpand(suite, secret, "base_nonce", scheduleContext, gcmNonceSize)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create GCM: %w", err)
+	}
+	return aead, nonce, nil
+}
+
+func hpkeLabeledExtract(suite, salt []byte, label string, ikm []byte) []byte {
+	labeled := append([]byte("HPKE-v1"), suite...)
+	labeled = append(labeled, label...)
+	labeled = append(labeled, ikm...)
+	return hkdf.Extract(sha256.New, labeled, salt)
+}
+
+func hpkeLabeledExpand(suite, prk []byte, label string, info []byte, length int) ([]byte, error) {
+	labeled := binary.BigEndian.AppendUint16(nil, uint16(length))
+	labeled = append(labeled, "HPKE-v1"...)
+	labeled = append(labeled, suite...)
+	labeled = append(labeled, label...)
+	labeled = append(labeled, info...)
+
+	out := make([]byte, length)
+	if _, err := hkdf.Expand(sha256.New, prk, labeled).Read(out); err != nil {
+		return nil, fmt.Errorf("failed to derive HPKE key: %w", err)
+	}
+	return out, nil
+}
@@ -0,0 +1,54 @@
+//go:build go1.26
+
+package internal_test
+
+import (
+	"crypto/ecdh"
+	"crypto/hpke"
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"github.com/muhammadluth/govault/internal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestHPKEInterop checks the envelope against the standard library HPKE
+func TestHPKEInterop(t *testing.T) {
+	pub, priv, err := internal.GenerateHPKEKey()
+	require.NoError(t, err)
+	info := []byte("govault hpke v1")
+	kem := hpke.DHKEM(ecdh.X25519())
+
+	g, err := internal.New(internal.Config{
+		HPKEPublicKeys:  map[string][]byte{"h1": pub},
+		HPKEPrivateKeys: map[string][]byte{"h1": priv},
+		DefaultKeyID:    "h1",
+	})
+	require.NoError(t, err)
+
+	ciphertext, err := g.Encrypt("from govault")
+	require.NoError(t, err)
+	parts := strings.Split(strings.TrimPrefix(ciphertext, internal.HPKEFormatPrefix), "|")
+	enc, err := base64.StdEncoding.DecodeString(parts[1])
+	require.NoError(t, err)
+	body, err := base64.StdEncoding.DecodeString(parts[2])
+	require.NoError(t, err)
+
+	sk, err := kem.NewPrivateKey(priv)
+	require.NoError(t, err)
+	plaintext, err := hpke.Open(sk, hpke.HKDFSHA256(), hpke.AES256GCM(), info, append(enc, body...))
+	require.NoError(t, err)
+	assert.Equal(t, "from govault", string(plaintext))
+
+	pk, err := kem.NewPublicKey(pub)
+	require.NoError(t, err)
+	sealed, err := hpke.Seal(pk, hpke.HKDFSHA256(), hpke.AES256GCM(), info, []byte("from stdlib"))
+	require.NoError(t, err)
+	decrypted, err := g.Decrypt(internal.HPKEFormatPrefix + "h1|" +
+		base64.StdEncoding.EncodeToString(sealed[:32]) + "|" +
+		base64.StdEncoding.EncodeToString(sealed[32:]))
+	require.NoError(t, err)
+	assert.Equal(t, "from stdlib", decrypted)
+}
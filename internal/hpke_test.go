@@ -0,0 +1,146 @@
+package internal_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/muhammadluth/govault/internal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type hpkeUser struct {
+	Email      string `bun:"email" encrypted:"true" blindindex:"email_bidx"`
+	SSN        string `bun:"ssn" encrypted:"true" deterministic:"true"`
+	EmailIndex string `bun:"email_bidx"`
+}
+
+func TestHPKE(t *testing.T) {
+	pub, priv, err := internal.GenerateHPKEKey()
+	require.NoError(t, err)
+	indexKey := []byte("727d37a0-a5f2-4d67-af47-83039c8e")
+
+	// The ingest service holds the public key and the blind index key only
+	writer, err := internal.New(internal.Config{
+		Keys:            map[string][]byte{"bidx": indexKey},
+		HPKEPublicKeys:  map[string][]byte{"h1": pub},
+		DefaultKeyID:    "h1",
+		BlindIndexKeyID: "bidx",
+		Mode:            internal.ModeEncryptOnly,
+	})
+	require.NoError(t, err)
+
+	reader, err := internal.New(internal.Config{
+		Keys:            map[string][]byte{"bidx": indexKey},
+		HPKEPrivateKeys: map[string][]byte{"h1": priv},
+		BlindIndexKeyID: "bidx",
+		Mode:            internal.ModeDecryptOnly,
+	})
+	require.NoError(t, err)
+
+	ciphertext, err := writer.Encrypt("secret@example.com")
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(ciphertext, internal.HPKEFormatPrefix+"h1|"))
+	assert.True(t, internal.IsEncrypted(ciphertext))
+	keyID, err := writer.GetKeyIDFromEncryptedData(ciphertext)
+	require.NoError(t, err)
+	assert.Equal(t, "h1", keyID)
+
+	again, err := writer.Encrypt("secret@example.com")
+	require.NoError(t, err)
+	assert.NotEqual(t, ciphertext, again)
+
+	plaintext, err := reader.Decrypt(ciphertext)
+	require.NoError(t, err)
+	assert.Equal(t, "secret@example.com", plaintext)
+
+	t.Run("model with blind index", func(t *testing.T) {
+		user := &hpkeUser{Email: "a@example.com"}
+		require.NoError(t, writer.EncryptModel(user, ""))
+		assert.True(t, strings.HasPrefix(user.Email, internal.HPKEFormatPrefix))
+
+		index, err := reader.BlindIndex(&hpkeUser{}, "Email", "a@example.com")
+		require.NoError(t, err)
+		assert.Equal(t, user.EmailIndex, index)
+
+		require.NoError(t, reader.DecryptRecursive(user))
+		assert.Equal(t, "a@example.com", user.Email)
+	})
+
+	t.Run("padded fields", func(t *testing.T) {
+		short := &paddedUser{Name: "J"}
+		require.NoError(t, writer.EncryptModel(short, ""))
+		long := &paddedUser{Name: "Jonathan-Livin"}
+		require.NoError(t, writer.EncryptModel(long, ""))
+		assert.True(t, strings.HasSuffix(short.Name, "|p"))
+		assert.Equal(t, len(short.Name), len(long.Name))
+
+		info, err := internal.InspectCiphertext(short.Name)
+		require.NoError(t, err)
+		assert.True(t, info.Padded)
+
+		require.NoError(t, reader.DecryptRecursive(short))
+		assert.Equal(t, "J", short.Name)
+
+		_, err = reader.Decrypt(strings.TrimSuffix(long.Name, "|p"))
+		assert.Error(t, err, "the flag is authenticated")
+	})
+
+	t.Run("deterministic fields need a symmetric key", func(t *testing.T) {
+		assert.Error(t, writer.EncryptModel(&hpkeUser{SSN: "000-00-0000"}, ""))
+	})
+
+	t.Run("public key only cannot decrypt", func(t *testing.T) {
+		g, err := internal.New(internal.Config{HPKEPublicKeys: map[string][]byte{"h1": pub}, DefaultKeyID: "h1"})
+		require.NoError(t, err)
+		_, err = g.Decrypt(ciphertext)
+		assert.ErrorContains(t, err, "no private key")
+	})
+
+	t.Run("tampered ciphertext", func(t *testing.T) {
+		other, _, err := internal.GenerateHPKEKey()
+		require.NoError(t, err)
+		parts := strings.Split(ciphertext, "|")
+		parts[1] = strings.Split(mustEncrypt(t, other), "|")[1]
+		_, err = reader.Decrypt(strings.Join(parts, "|"))
+		assert.Error(t, err)
+	})
+
+	t.Run("config validation", func(t *testing.T) {
+		otherPub, _, err := internal.GenerateHPKEKey()
+		require.NoError(t, err)
+		_, err = internal.New(internal.Config{
+			HPKEPublicKeys:  map[string][]byte{"h1": otherPub},
+			HPKEPrivateKeys: map[string][]byte{"h1": priv},
+			DefaultKeyID:    "h1",
+		})
+		assert.Error(t, err)
+
+		_, err = internal.New(internal.Config{
+			HPKEPublicKeys:  map[string][]byte{"h1": pub},
+			DefaultKeyID:    "h1",
+			BlindIndexKeyID: "h1",
+		})
+		assert.Error(t, err)
+
+		_, err = internal.New(internal.Config{
+			Keys:           map[string][]byte{"h1": indexKey},
+			HPKEPublicKeys: map[string][]byte{"h1": pub},
+			DefaultKeyID:   "h1",
+		})
+		assert.Error(t, err)
+
+		_, err = internal.New(internal.Config{HPKEPublicKeys: map[string][]byte{"h1": []byte("short")}, DefaultKeyID: "h1"})
+		assert.Error(t, err)
+	})
+}
+
+// mustEncrypt seals "x" to a fresh vault holding only pub
+func mustEncrypt(t *testing.T, pub []byte) string {
+	t.Helper()
+	g, err := internal.New(internal.Config{HPKEPublicKeys: map[string][]byte{"h1": pub}, DefaultKeyID: "h1"})
+	require.NoError(t, err)
+	ciphertext, err := g.Encrypt("x")
+	require.NoError(t, err)
+	return ciphertext
+}
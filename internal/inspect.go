@@ -0,0 +1,165 @@
+package internal
+
+import (
+	"crypto/subtle"
+	"encoding/base64"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+// Ciphertext formats reported by InspectCiphertext
+const (
+	CiphertextFormatNative      = "gv1"
+	CiphertextFormatLegacy      = "legacy"
+	CiphertextFormatHPKE        = "gvh1"
+	CiphertextFormatCipherSweet = "nacl"
+)
+
+// CiphertextInfo is the metadata of a ciphertext read from its header. It
+// holds no plaintext.
+type CiphertextInfo struct {
+	// Format is one of the CiphertextFormat constants, or the format of the
+	// EnvelopeCodec reading a foreign value
+	Format  string `json:"format"`
+	Version int    `json:"version"` // 0 for the legacy unprefixed format
+	// KeyID is empty for formats that don't name their key, e.g. nacl
+	KeyID     string    `json:"key_id,omitempty"`
+	Algorithm Algorithm `json:"algorithm"`
+	Padded    bool      `json:"padded"`
+	Binary    bool      `json:"binary"` // read from the storage:"binary" encoding
+	// Deterministic is only known to GovaultDB.InspectCiphertext, which
+	// checks the nonce against the key; the header does not record it
+	Deterministic bool `json:"deterministic"`
+	NonceSize     int  `json:"nonce_size"`
+	Size          int  `json:"size"` // sealed data and tag
+	// EncryptedAt is zero unless embedded, see Config.EmbedEncryptedAt
+	EncryptedAt time.Time `json:"encrypted_at,omitzero"`
+	// Error is why GovaultDB.InspectCiphertext could not open the value
+	// with its key, e.g. an unknown key or a failed authentication. The
+	// header fields are still set; Deterministic is unknown and false.
+	Error string `json:"error,omitempty"`
+}
+
+// InspectCiphertext returns the header metadata of a govault ciphertext in
+// the text or binary encoding, without decrypting it. Values in no govault
+// format fail with a *FormatError.
+func InspectCiphertext(value string) (*CiphertextInfo, error) {
+	binary := strings.HasPrefix(value, binaryFormatMagic)
+	if binary {
+		decoded, err := DecodeBinary([]byte(value))
+		if err != nil {
+			return nil, err
+		}
+		value = decoded
+	}
+
+	var info *CiphertextInfo
+	switch {
+	case strings.HasPrefix(value, HPKEFormatPrefix):
+		v, err := parseHPKE(value)
+		if err != nil {
+			return nil, err
+		}
+		info = &CiphertextInfo{
+			Format:    CiphertextFormatHPKE,
+			Version:   1,
+			KeyID:     v.keyID,
+			Algorithm: AlgorithmAESGCM,
+			Padded:    v.padded,
+			NonceSize: len(v.enc),
+			Size:      len(v.ciphertext),
+		}
+	case strings.HasPrefix(value, cipherSweetPrefix):
+		sealed, err := base64.URLEncoding.DecodeString(strings.TrimPrefix(value, cipherSweetPrefix))
+		if err != nil || len(sealed) < chacha20poly1305.NonceSizeX+gcmTagSize {
+			return nil, &FormatError{Part: "value", Reason: "is not a valid nacl ciphertext"}
+		}
+		info = &CiphertextInfo{
+			Format:    CiphertextFormatCipherSweet,
+			Version:   1,
+			Algorithm: AlgorithmXChaCha20Poly1305,
+			NonceSize: chacha20poly1305.NonceSizeX,
+			Size:      len(sealed) - chacha20poly1305.NonceSizeX,
+		}
+	default:
+		env, err := parseEnvelope(value)
+		if err != nil {
+			return nil, err
+		}
+		info = &CiphertextInfo{
+			Format:    CiphertextFormatNative,
+			Version:   1,
+			KeyID:     env.keyID,
+			Algorithm: env.algorithm.normalize(),
+			Padded:    env.padded,
+			NonceSize: len(env.nonce),
+			Size:      len(env.ciphertext),
+		}
+		if env.encryptedAt != 0 {
+			info.EncryptedAt = time.Unix(env.encryptedAt, 0).UTC()
+		}
+		if env.legacy {
+			info.Format, info.Version = CiphertextFormatLegacy, 0
+		}
+	}
+	info.Binary = binary
+	return info, nil
+}
+
+// InspectCiphertext is InspectCiphertext reading the foreign formats of
+// Config.EnvelopeCodecs too. For native values it decrypts with the key to
+// tell whether the nonce is the synthetic one of deterministic encryption;
+// the plaintext is discarded. Instances that may not decrypt, see Mode,
+// return the header only. Values the keys cannot open return their header
+// too, with the failure in CiphertextInfo.Error.
+func (g *GovaultDB) InspectCiphertext(value string) (*CiphertextInfo, error) {
+	if env, ok := g.parseForeign(nil, value); ok {
+		format, _ := g.envelopeFormat(nil, value)
+		return &CiphertextInfo{
+			Format:    format,
+			KeyID:     env.KeyID,
+			Algorithm: AlgorithmAESGCM,
+			NonceSize: len(env.Nonce),
+			Size:      len(env.Ciphertext),
+		}, nil
+	}
+
+	info, err := InspectCiphertext(value)
+	if err != nil {
+		return nil, err
+	}
+	if info.Format != CiphertextFormatNative && info.Format != CiphertextFormatLegacy {
+		return info, nil
+	}
+	if g.allowDecrypt() != nil {
+		return info, nil
+	}
+
+	if info.Binary {
+		if value, err = DecodeBinary([]byte(value)); err != nil {
+			return nil, err
+		}
+	}
+	env, err := parseEnvelope(value)
+	if err != nil {
+		return nil, err
+	}
+	key, err := g.lookupDecryptKey(env.keyID)
+	if err != nil {
+		info.Error = err.Error()
+		return info, nil
+	}
+	plaintext, err := openEnvelope(env, key)
+	if err != nil {
+		info.Error = err.Error()
+		return info, nil
+	}
+	nonce, err := deterministicNonce(key, plaintext, len(env.nonce))
+	if err != nil {
+		return nil, err
+	}
+	info.Deterministic = subtle.ConstantTimeCompare(nonce, env.nonce) == 1
+	return info, nil
+}
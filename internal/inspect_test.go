@@ -0,0 +1,131 @@
+package internal_test
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"github.com/muhammadluth/govault/internal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInspectCiphertext(t *testing.T) {
+	pub, priv, err := internal.GenerateHPKEKey()
+	require.NoError(t, err)
+	g, err := internal.New(internal.Config{
+		Keys:            map[string][]byte{"1": []byte("727d37a0-a5f2-4d67-af47-83039c8e")},
+		HPKEPublicKeys:  map[string][]byte{"h1": pub},
+		HPKEPrivateKeys: map[string][]byte{"h1": priv},
+		DefaultKeyID:    "1",
+		EnvelopeCodecs:  map[string]internal.EnvelopeCodec{"enc1$": dollarCodec{}},
+	})
+	require.NoError(t, err)
+
+	t.Run("native header", func(t *testing.T) {
+		field := &internal.FieldSpec{Algorithm: internal.AlgorithmXChaCha20Poly1305, PadBuckets: []int{32}}
+		encrypted, err := g.EncryptField(field, "users", "ada@example.com", "")
+		require.NoError(t, err)
+
+		info, err := internal.InspectCiphertext(encrypted)
+		require.NoError(t, err)
+		assert.Equal(t, &internal.CiphertextInfo{
+			Format:    internal.CiphertextFormatNative,
+			Version:   1,
+			KeyID:     "1",
+			Algorithm: internal.AlgorithmXChaCha20Poly1305,
+			Padded:    true,
+			NonceSize: 24,
+			Size:      32 + 16,
+		}, info)
+	})
+
+	t.Run("deterministic values are told apart with the key", func(t *testing.T) {
+		deterministic, err := g.EncryptField(&internal.FieldSpec{Deterministic: true}, "", "ada@example.com", "")
+		require.NoError(t, err)
+		random, err := g.Encrypt("ada@example.com")
+		require.NoError(t, err)
+
+		info, err := internal.InspectCiphertext(deterministic)
+		require.NoError(t, err)
+		assert.False(t, info.Deterministic, "the header does not record it")
+		info, err = g.InspectCiphertext(deterministic)
+		require.NoError(t, err)
+		assert.True(t, info.Deterministic)
+		info, err = g.InspectCiphertext(random)
+		require.NoError(t, err)
+		assert.False(t, info.Deterministic)
+	})
+
+	t.Run("other formats", func(t *testing.T) {
+		sealed, err := g.Encrypt("x", "h1")
+		require.NoError(t, err)
+		info, err := internal.InspectCiphertext(sealed)
+		require.NoError(t, err)
+		assert.Equal(t, internal.CiphertextFormatHPKE, info.Format)
+		assert.Equal(t, "h1", info.KeyID)
+
+		encrypted, err := g.Encrypt("x")
+		require.NoError(t, err)
+		data, err := internal.EncodeBinary(encrypted)
+		require.NoError(t, err)
+		info, err = internal.InspectCiphertext(string(data))
+		require.NoError(t, err)
+		assert.True(t, info.Binary)
+		assert.Equal(t, internal.CiphertextFormatNative, info.Format)
+
+		legacy := strings.TrimPrefix(encrypted, internal.FormatPrefix)
+		info, err = internal.InspectCiphertext(legacy)
+		require.NoError(t, err)
+		assert.Equal(t, internal.CiphertextFormatLegacy, info.Format)
+		assert.Equal(t, 0, info.Version)
+
+		foreign, err := g.EncryptEnvelope("enc1$", "x", "")
+		require.NoError(t, err)
+		info, err = g.InspectCiphertext(foreign)
+		require.NoError(t, err)
+		assert.Equal(t, "enc1$", info.Format)
+		assert.Equal(t, "1", info.KeyID)
+
+		_, err = internal.InspectCiphertext("plaintext")
+		assert.ErrorIs(t, err, internal.ErrInvalidCiphertext)
+	})
+
+	t.Run("unknown key keeps the header", func(t *testing.T) {
+		other, err := internal.New(internal.Config{
+			Keys:             map[string][]byte{"2": []byte("0b1e5c7a-9d4f-4e2b-8a6c-3f7d1e9b")},
+			DefaultKeyID:     "2",
+			EmbedEncryptedAt: true,
+		})
+		require.NoError(t, err)
+		encrypted, err := other.Encrypt("ada@example.com")
+		require.NoError(t, err)
+
+		info, err := g.InspectCiphertext(encrypted)
+		require.NoError(t, err)
+		assert.Equal(t, "2", info.KeyID)
+		assert.Equal(t, internal.CiphertextFormatNative, info.Format)
+		assert.False(t, info.EncryptedAt.IsZero())
+		assert.False(t, info.Deterministic)
+		assert.Contains(t, info.Error, "2")
+	})
+
+	t.Run("tampered ciphertext keeps the header", func(t *testing.T) {
+		encrypted, err := g.Encrypt("ada@example.com")
+		require.NoError(t, err)
+		i := strings.LastIndex(encrypted, "|") + 1
+		sealed, err := base64.StdEncoding.DecodeString(encrypted[i:])
+		require.NoError(t, err)
+		sealed[0] ^= 1
+		tampered := encrypted[:i] + base64.StdEncoding.EncodeToString(sealed)
+
+		info, err := g.InspectCiphertext(tampered)
+		require.NoError(t, err)
+		assert.Equal(t, "1", info.KeyID)
+		assert.NotEmpty(t, info.Error)
+
+		info, err = g.InspectCiphertext(encrypted)
+		require.NoError(t, err)
+		assert.Empty(t, info.Error)
+	})
+}
@@ -0,0 +1,58 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+)
+
+// redactJSONTag marks the fields masked by GuardJSON
+const redactJSONTag = "redactjson"
+
+type jsonUnlockKey struct{}
+
+// WithJSONUnlock returns a context whose GuardJSON values encode the fields
+// tagged redactjson:"true" as they are, e.g. for an endpoint entitled to
+// the PII it returns
+func WithJSONUnlock(ctx context.Context) context.Context {
+	return context.WithValue(ctx, jsonUnlockKey{}, true)
+}
+
+// JSONUnlocked reports whether ctx was returned by WithJSONUnlock
+func JSONUnlocked(ctx context.Context) bool {
+	if ctx == nil {
+		return false
+	}
+	unlocked, _ := ctx.Value(jsonUnlockKey{}).(bool)
+	return unlocked
+}
+
+// GuardJSON wraps v so that json.Marshal masks its fields tagged
+// redactjson:"true", unless ctx was returned by WithJSONUnlock: non-empty
+// strings become FallbackMaskValue, other values are zeroed. Fields are
+// found the way decryption finds encrypted ones, through nested structs,
+// pointers and slices; v itself is left unchanged.
+//
+//	json.NewEncoder(w).Encode(govault.GuardJSON(r.Context(), user))
+func GuardJSON(ctx context.Context, v any) json.Marshaler {
+	return guardedJSON{unlocked: JSONUnlocked(ctx), v: v}
+}
+
+// guardedJSON is the json.Marshaler returned by GuardJSON
+type guardedJSON struct {
+	unlocked bool
+	v        any
+}
+
+// MarshalJSON encodes the value, masked unless unlocked
+func (g guardedJSON) MarshalJSON() ([]byte, error) {
+	if g.unlocked || g.v == nil {
+		return json.Marshal(g.v)
+	}
+	c := deepCopyOf(g.v)
+	_ = withCopyTarget(&c, func(target any) error {
+		redactRecursive(reflect.ValueOf(target), redactJSONTag)
+		return nil
+	})
+	return json.Marshal(c)
+}
@@ -0,0 +1,53 @@
+package internal_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/muhammadluth/govault/internal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type jsonAccount struct {
+	ID      int64        `json:"id"`
+	Email   string       `json:"email" redactjson:"true"`
+	Phone   string       `json:"phone,omitempty" redactjson:"true"`
+	Balance int          `json:"balance" redactjson:"true"`
+	Owner   *jsonAccount `json:"owner,omitempty"`
+}
+
+func TestGuardJSON(t *testing.T) {
+	account := jsonAccount{
+		ID:      1,
+		Email:   "ada@example.com",
+		Balance: 100,
+		Owner:   &jsonAccount{ID: 2, Email: "grace@example.com"},
+	}
+
+	t.Run("tagged fields are masked", func(t *testing.T) {
+		data, err := json.Marshal(internal.GuardJSON(context.Background(), &account))
+		require.NoError(t, err)
+		assert.JSONEq(t, `{"id":1,"email":"****","balance":0,"owner":{"id":2,"email":"****","balance":0}}`, string(data))
+		assert.Equal(t, "ada@example.com", account.Email, "the value is unchanged")
+		assert.Equal(t, "grace@example.com", account.Owner.Email)
+	})
+
+	t.Run("values and slices are guarded", func(t *testing.T) {
+		data, err := json.Marshal(map[string]any{
+			"account":  internal.GuardJSON(context.Background(), account),
+			"accounts": internal.GuardJSON(context.Background(), []jsonAccount{account}),
+		})
+		require.NoError(t, err)
+		assert.NotContains(t, string(data), "example.com")
+	})
+
+	t.Run("unlocked contexts encode the fields", func(t *testing.T) {
+		ctx := internal.WithJSONUnlock(context.Background())
+		data, err := json.Marshal(internal.GuardJSON(ctx, &account))
+		require.NoError(t, err)
+		assert.Contains(t, string(data), `"email":"ada@example.com"`)
+		assert.Contains(t, string(data), `"balance":100`)
+	})
+}
@@ -0,0 +1,116 @@
+package internal_test
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/muhammadluth/govault/internal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	reloadKey1 = []byte("727d37a0-a5f2-4d67-af47-83039c8e")
+	reloadKey2 = []byte("e778dc27-9b04-44c3-a862-feba061c")
+	reloadKey3 = []byte("e778dc27-9b04-44c3-a862-83039c8e")
+)
+
+func TestReloadKeys(t *testing.T) {
+	g, err := internal.New(internal.Config{
+		Keys:             map[string][]byte{"1": reloadKey1, "2": reloadKey2},
+		DefaultKeyID:     "1",
+		BlindIndexKeyID:  "2",
+		CipherSweetKeyID: "2",
+	})
+	require.NoError(t, err)
+
+	old, err := g.Encrypt("secret")
+	require.NoError(t, err)
+
+	require.NoError(t, g.ReloadKeys(map[string][]byte{"1": reloadKey1, "2": reloadKey2, "3": reloadKey3}, "3"))
+	assert.Equal(t, []string{"1", "2", "3"}, g.GetKeyIDs())
+	assert.Equal(t, "3", g.GetDefaultKeyID())
+
+	plaintext, err := g.Decrypt(old)
+	require.NoError(t, err)
+	assert.Equal(t, "secret", plaintext)
+
+	require.NoError(t, g.ReloadKeys(map[string][]byte{"2": reloadKey2, "3": reloadKey3}, ""))
+	assert.Equal(t, []string{"2", "3"}, g.GetKeyIDs())
+	_, err = g.Decrypt(old)
+	assert.ErrorContains(t, err, "encryption key '1' not found")
+
+	tests := []struct {
+		name    string
+		keys    map[string][]byte
+		keyID   string
+		wantErr string
+	}{
+		{"default removed", map[string][]byte{"2": reloadKey2}, "", "default key ID '3' not found in keys"},
+		{"blind index key removed", map[string][]byte{"3": reloadKey3}, "", "key ID '2' is in use and cannot be removed"},
+		{"bytes changed", map[string][]byte{"2": reloadKey2, "3": reloadKey1}, "", "key ID '3' already exists with different key bytes"},
+		{"invalid key", map[string][]byte{"2": reloadKey2, "3": reloadKey3, "4": []byte("short")}, "", "key must be 32 bytes"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.ErrorContains(t, g.ReloadKeys(tt.keys, tt.keyID), tt.wantErr)
+			assert.Equal(t, []string{"2", "3"}, g.GetKeyIDs(), "failed reloads change nothing")
+			assert.Equal(t, "3", g.GetDefaultKeyID())
+		})
+	}
+}
+
+// TestKeySetConcurrency runs encryptions and decryptions while the key set
+// changes; run with -race
+func TestKeySetConcurrency(t *testing.T) {
+	g, err := internal.New(internal.Config{
+		Keys:         map[string][]byte{"1": reloadKey1, "2": reloadKey2},
+		DefaultKeyID: "1",
+	})
+	require.NoError(t, err)
+
+	stored, err := g.Encrypt("stored")
+	require.NoError(t, err)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 200; j++ {
+				plaintext := fmt.Sprintf("value %d-%d", i, j)
+				ciphertext, err := g.Encrypt(plaintext)
+				if !assert.NoError(t, err) {
+					return
+				}
+				decrypted, err := g.Decrypt(ciphertext)
+				if !assert.NoError(t, err) {
+					return
+				}
+				assert.Equal(t, plaintext, decrypted)
+
+				decrypted, err = g.Decrypt(stored)
+				assert.NoError(t, err)
+				assert.Equal(t, "stored", decrypted)
+				_ = g.KeyStatuses()
+			}
+		}(i)
+	}
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for j := 0; j < 200; j++ {
+			// Keys 1 and 2 stay, so every value written remains readable
+			keys := map[string][]byte{"1": reloadKey1, "2": reloadKey2}
+			if j%2 == 0 {
+				keys["3"] = reloadKey3
+			}
+			assert.NoError(t, g.ReloadKeys(keys, []string{"1", "2"}[j%2]))
+			assert.NoError(t, g.SetDefaultKey("1"))
+			assert.NoError(t, g.AddKey("3", reloadKey3))
+		}
+	}()
+	wg.Wait()
+}
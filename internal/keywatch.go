@@ -0,0 +1,167 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// KeyIDSource returns the key ID to use as the default key. An empty ID
+// leaves the default key unchanged.
+type KeyIDSource func() (string, error)
+
+// EnvKeyID reads the key ID from an environment variable
+func EnvKeyID(name string) KeyIDSource {
+	return func() (string, error) {
+		return os.Getenv(name), nil
+	}
+}
+
+// FileKeyID reads the key ID from a file, e.g. a mounted secret
+func FileKeyID(path string) KeyIDSource {
+	return func() (string, error) {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("failed to read key ID: %w", err)
+		}
+		return strings.TrimSpace(string(data)), nil
+	}
+}
+
+// WatchDefaultKey reads source every interval and makes the key ID it
+// returns the default key, until ctx is done. Every change or error is
+// passed to report, which may be nil.
+func (g *GovaultDB) WatchDefaultKey(ctx context.Context, interval time.Duration, source KeyIDSource, report func(keyID string, err error)) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		keyID, err := source()
+		if err == nil && keyID != "" && keyID != g.GetDefaultKeyID() {
+			err = g.SetDefaultKey(keyID)
+			if err == nil && report != nil {
+				report(keyID, nil)
+			}
+		}
+		if err != nil && report != nil {
+			report(keyID, err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// DefaultKeyIDFile is the file of a key directory naming the default key
+const DefaultKeyIDFile = "default_key_id"
+
+// LoadKeyDir reads a directory of key files, e.g. a mounted Kubernetes
+// Secret: each file holds the key named after it, in encoding (raw, base64
+// or hex), and the DefaultKeyIDFile, if any, names the default key. Hidden
+// files, such as the ..data link of Secret volumes, are skipped.
+func LoadKeyDir(dir, encoding string) (map[string][]byte, string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read key directory: %w", err)
+	}
+
+	keys := make(map[string][]byte)
+	var defaultKeyID string
+	for _, entry := range entries {
+		name := entry.Name()
+		if strings.HasPrefix(name, ".") {
+			continue
+		}
+		// Secret volumes link every file into the ..data directory
+		info, err := os.Stat(filepath.Join(dir, name))
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to read key file '%s': %w", name, err)
+		}
+		if info.IsDir() {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to read key file '%s': %w", name, err)
+		}
+		raw := strings.TrimRight(string(data), "\r\n")
+		if name == DefaultKeyIDFile {
+			defaultKeyID = strings.TrimSpace(raw)
+			continue
+		}
+		key, err := decodeKey(raw, encoding)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to load key '%s': %w", name, err)
+		}
+		keys[name] = key
+	}
+	return keys, defaultKeyID, nil
+}
+
+// keyDirDebounce groups the events of one update of a key directory
+const keyDirDebounce = 100 * time.Millisecond
+
+// WatchKeyDir loads the keys of dir, see LoadKeyDir, with ReloadKeys, then
+// reloads them whenever the directory changes, until ctx is done. Keys
+// removed from dir are removed, and the DefaultKeyIDFile selects the
+// default key. Every reload or error is passed to report, which may be
+// nil. Only a failure to load the directory initially is returned.
+func (g *GovaultDB) WatchKeyDir(ctx context.Context, dir, encoding string, report func(defaultKeyID string, err error)) error {
+	reload := func() error {
+		keys, defaultKeyID, err := LoadKeyDir(dir, encoding)
+		if err == nil {
+			err = g.ReloadKeys(keys, defaultKeyID)
+		}
+		if report != nil {
+			report(g.GetDefaultKeyID(), err)
+		}
+		return err
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("failed to watch key directory: %w", err)
+	}
+	defer watcher.Close()
+	if err := watcher.Add(dir); err != nil {
+		return fmt.Errorf("failed to watch key directory: %w", err)
+	}
+	if err := reload(); err != nil {
+		return err
+	}
+
+	timer := time.NewTimer(0)
+	if !timer.Stop() {
+		<-timer.C
+	}
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case _, ok := <-watcher.Events:
+			if !ok {
+				return fmt.Errorf("key directory watcher closed")
+			}
+			timer.Reset(keyDirDebounce)
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return fmt.Errorf("key directory watcher closed")
+			}
+			if report != nil {
+				report(g.GetDefaultKeyID(), fmt.Errorf("failed to watch key directory: %w", err))
+			}
+		case <-timer.C:
+			_ = reload()
+		}
+	}
+}
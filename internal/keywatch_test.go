@@ -0,0 +1,215 @@
+package internal_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/muhammadluth/govault/internal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newKeyWatchDB(t *testing.T) *internal.GovaultDB {
+	t.Helper()
+	g, err := internal.New(internal.Config{
+		Keys: map[string][]byte{
+			"1": []byte("727d37a0-a5f2-4d67-af47-83039c8e"),
+			"2": []byte("e778dc27-9b04-44c3-a862-feba061c"),
+			"3": []byte("e778dc27-9b04-44c3-a862-83039c8e"),
+		},
+		DefaultKeyID:      "1",
+		DecryptOnlyKeyIDs: []string{"3"},
+	})
+	require.NoError(t, err)
+	return g
+}
+
+func TestSetDefaultKey(t *testing.T) {
+	g := newKeyWatchDB(t)
+
+	require.NoError(t, g.SetDefaultKey("2"))
+	assert.Equal(t, "2", g.GetDefaultKeyID())
+	ciphertext, err := g.Encrypt("secret")
+	require.NoError(t, err)
+	keyID, err := g.GetKeyIDFromEncryptedData(ciphertext)
+	require.NoError(t, err)
+	assert.Equal(t, "2", keyID)
+
+	assert.ErrorContains(t, g.SetDefaultKey("4"), "default key ID '4' not found in keys")
+	assert.ErrorContains(t, g.SetDefaultKey("3"), "cannot be decrypt-only")
+	assert.Equal(t, "2", g.GetDefaultKeyID())
+
+	// Encryptions racing a switch use one of the keys, never fail
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				if i == 0 {
+					_ = g.SetDefaultKey([]string{"1", "2"}[j%2])
+					continue
+				}
+				_, err := g.Encrypt("secret")
+				assert.NoError(t, err)
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+func TestWatchDefaultKey(t *testing.T) {
+	g := newKeyWatchDB(t)
+	path := filepath.Join(t.TempDir(), "default-key")
+	require.NoError(t, os.WriteFile(path, []byte("2\n"), 0o600))
+
+	type event struct {
+		keyID string
+		err   error
+	}
+	events := make(chan event, 10)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- g.WatchDefaultKey(ctx, 10*time.Millisecond, internal.FileKeyID(path), func(keyID string, err error) {
+			events <- event{keyID, err}
+		})
+	}()
+
+	next := func() event {
+		select {
+		case e := <-events:
+			return e
+		case <-time.After(5 * time.Second):
+			t.Fatal("no default key change reported")
+			return event{}
+		}
+	}
+
+	assert.Equal(t, event{"2", nil}, next())
+	assert.Equal(t, "2", g.GetDefaultKeyID())
+
+	require.NoError(t, os.WriteFile(path, []byte("3"), 0o600))
+	e := next()
+	assert.ErrorContains(t, e.err, "cannot be decrypt-only")
+	assert.Equal(t, "2", g.GetDefaultKeyID())
+
+	require.NoError(t, os.WriteFile(path, []byte("1"), 0o600))
+	e = next()
+	for e.err != nil {
+		e = next() // errors repeat until the file is read again
+	}
+	assert.Equal(t, "1", e.keyID)
+	assert.Equal(t, "1", g.GetDefaultKeyID())
+
+	cancel()
+	assert.ErrorIs(t, <-done, context.Canceled)
+}
+
+func TestEnvKeyID(t *testing.T) {
+	t.Setenv("GOVAULT_TEST_DEFAULT_KEY_ID", "2")
+	keyID, err := internal.EnvKeyID("GOVAULT_TEST_DEFAULT_KEY_ID")()
+	require.NoError(t, err)
+	assert.Equal(t, "2", keyID)
+
+	_, err = internal.FileKeyID(filepath.Join(t.TempDir(), "missing"))()
+	assert.ErrorContains(t, err, "failed to read key ID")
+}
+
+// writeSecretVolume lays out dir like a Kubernetes Secret volume: the files
+// live in a timestamped directory that ..data links to, and every key links
+// through ..data. Each call swaps ..data atomically, as the kubelet does.
+func writeSecretVolume(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	version, err := os.MkdirTemp(dir, "..version")
+	require.NoError(t, err)
+	for name, content := range files {
+		require.NoError(t, os.WriteFile(filepath.Join(version, name), []byte(content), 0o600))
+		link := filepath.Join(dir, name)
+		if _, err := os.Lstat(link); os.IsNotExist(err) {
+			require.NoError(t, os.Symlink(filepath.Join("..data", name), link))
+		}
+	}
+	tmp := filepath.Join(dir, "..data_tmp")
+	require.NoError(t, os.Symlink(filepath.Base(version), tmp))
+	require.NoError(t, os.Rename(tmp, filepath.Join(dir, "..data")))
+}
+
+func TestLoadKeyDir(t *testing.T) {
+	dir := t.TempDir()
+	writeSecretVolume(t, dir, map[string]string{
+		"1":                       "727d37a0-a5f2-4d67-af47-83039c8e\n",
+		"2":                       "e778dc27-9b04-44c3-a862-feba061c",
+		internal.DefaultKeyIDFile: "2\n",
+	})
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "nested"), 0o700))
+
+	keys, defaultKeyID, err := internal.LoadKeyDir(dir, "")
+	require.NoError(t, err)
+	assert.Equal(t, map[string][]byte{
+		"1": []byte("727d37a0-a5f2-4d67-af47-83039c8e"),
+		"2": []byte("e778dc27-9b04-44c3-a862-feba061c"),
+	}, keys)
+	assert.Equal(t, "2", defaultKeyID)
+
+	_, _, err = internal.LoadKeyDir(dir, "base64")
+	assert.ErrorContains(t, err, "failed to load key")
+	_, _, err = internal.LoadKeyDir(filepath.Join(dir, "missing"), "")
+	assert.ErrorContains(t, err, "failed to read key directory")
+}
+
+func TestWatchKeyDir(t *testing.T) {
+	dir := t.TempDir()
+	writeSecretVolume(t, dir, map[string]string{
+		"1":                       "727d37a0-a5f2-4d67-af47-83039c8e",
+		internal.DefaultKeyIDFile: "1",
+	})
+	g, err := internal.New(internal.Config{
+		Keys:         map[string][]byte{"1": []byte("727d37a0-a5f2-4d67-af47-83039c8e")},
+		DefaultKeyID: "1",
+	})
+	require.NoError(t, err)
+	old, err := g.Encrypt("secret")
+	require.NoError(t, err)
+
+	reports := make(chan error, 16)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- g.WatchKeyDir(ctx, dir, "", func(defaultKeyID string, err error) { reports <- err })
+	}()
+	require.NoError(t, <-reports)
+
+	writeSecretVolume(t, dir, map[string]string{
+		"1":                       "727d37a0-a5f2-4d67-af47-83039c8e",
+		"2":                       "e778dc27-9b04-44c3-a862-feba061c",
+		internal.DefaultKeyIDFile: "2",
+	})
+	require.Eventually(t, func() bool { return g.GetDefaultKeyID() == "2" }, 5*time.Second, 10*time.Millisecond)
+	plaintext, err := g.Decrypt(old)
+	require.NoError(t, err)
+	assert.Equal(t, "secret", plaintext)
+
+	// A default key missing from the directory is reported, keys are kept
+	writeSecretVolume(t, dir, map[string]string{
+		"1":                       "727d37a0-a5f2-4d67-af47-83039c8e",
+		"2":                       "e778dc27-9b04-44c3-a862-feba061c",
+		internal.DefaultKeyIDFile: "3",
+	})
+	require.Eventually(t, func() bool {
+		select {
+		case err := <-reports:
+			return err != nil
+		default:
+			return false
+		}
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, "2", g.GetDefaultKeyID())
+
+	cancel()
+	assert.ErrorIs(t, <-done, context.Canceled)
+}
@@ -0,0 +1,59 @@
+package internal
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// ErrManifestSignature is returned by LoadSignedConfig for manifests whose
+// signature is missing or does not verify
+var ErrManifestSignature = errors.New("invalid manifest signature")
+
+// ManifestSignatureSuffix is appended to the path of a manifest to find
+// its signature file
+const ManifestSignatureSuffix = ".sig"
+
+// LoadSignedConfig is LoadConfig for a manifest signed with SignConfig,
+// verified against publicKey before anything in it is used. The signature
+// is read from path with ManifestSignatureSuffix appended.
+//
+// The manifest is taken as signed: ${NAME} references are rejected, so the
+// environment can't change the fields, modes or key references it
+// declares. Key bytes are still read from the env and file sources of the
+// manifest, which sign where keys come from but not the keys themselves.
+func LoadSignedConfig(path string, publicKey ed25519.PublicKey) (*Config, error) {
+	if len(publicKey) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("manifest public key is %d bytes, want %d", len(publicKey), ed25519.PublicKeySize)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config: %w", err)
+	}
+	encoded, err := os.ReadFile(path + ManifestSignatureSuffix)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrManifestSignature, err)
+	}
+	signature, err := base64.StdEncoding.DecodeString(string(bytes.TrimSpace(encoded)))
+	if err != nil || len(signature) != ed25519.SignatureSize {
+		return nil, fmt.Errorf("%w: %s is not a base64 ed25519 signature", ErrManifestSignature, path+ManifestSignatureSuffix)
+	}
+	if !ed25519.Verify(publicKey, data, signature) {
+		return nil, fmt.Errorf("%w: %s", ErrManifestSignature, path)
+	}
+
+	if ref := envReference.Find(data); ref != nil {
+		return nil, fmt.Errorf("signed manifest %s references %s, use an env key source instead", path, ref)
+	}
+	return parseConfig(path, data)
+}
+
+// SignConfig returns the content of the signature file of manifest, see
+// LoadSignedConfig
+func SignConfig(manifest []byte, privateKey ed25519.PrivateKey) []byte {
+	signature := ed25519.Sign(privateKey, manifest)
+	return []byte(base64.StdEncoding.EncodeToString(signature) + "\n")
+}
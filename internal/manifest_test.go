@@ -0,0 +1,79 @@
+package internal_test
+
+import (
+	"crypto/ed25519"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/muhammadluth/govault/internal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadSignedConfig(t *testing.T) {
+	publicKey, privateKey, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	t.Setenv("GOVAULT_TEST_KEY1", string(configKey1))
+
+	manifest := `
+default_key: k1
+mode: read_write
+keys:
+  k1:
+    env: GOVAULT_TEST_KEY1
+fields:
+  config_customers:
+    ssn:
+      deterministic: true
+`
+	path := writeConfig(t, "govault.yaml", manifest)
+	sign := func(data string) {
+		require.NoError(t, os.WriteFile(path+internal.ManifestSignatureSuffix, internal.SignConfig([]byte(data), privateKey), 0o600))
+	}
+
+	t.Run("verified manifests load", func(t *testing.T) {
+		sign(manifest)
+		config, err := internal.LoadSignedConfig(path, publicKey)
+		require.NoError(t, err)
+		assert.Equal(t, "k1", config.DefaultKeyID)
+		assert.Equal(t, map[string][]byte{"k1": configKey1}, config.Keys)
+		assert.True(t, config.Fields["config_customers"]["ssn"].Deterministic)
+	})
+
+	t.Run("tampered manifests are rejected", func(t *testing.T) {
+		sign(manifest)
+		tampered := strings.Replace(manifest, "deterministic: true", "deterministic: false", 1)
+		require.NoError(t, os.WriteFile(path, []byte(tampered), 0o600))
+		defer os.WriteFile(path, []byte(manifest), 0o600)
+
+		_, err := internal.LoadSignedConfig(path, publicKey)
+		assert.ErrorIs(t, err, internal.ErrManifestSignature)
+	})
+
+	t.Run("other signers are rejected", func(t *testing.T) {
+		sign(manifest)
+		otherKey, _, err := ed25519.GenerateKey(nil)
+		require.NoError(t, err)
+		_, err = internal.LoadSignedConfig(path, otherKey)
+		assert.ErrorIs(t, err, internal.ErrManifestSignature)
+	})
+
+	t.Run("unsigned manifests are rejected", func(t *testing.T) {
+		unsigned := writeConfig(t, "unsigned.yaml", manifest)
+		_, err := internal.LoadSignedConfig(unsigned, publicKey)
+		assert.ErrorIs(t, err, internal.ErrManifestSignature)
+
+		require.NoError(t, os.WriteFile(unsigned+internal.ManifestSignatureSuffix, []byte("not a signature"), 0o600))
+		_, err = internal.LoadSignedConfig(unsigned, publicKey)
+		assert.ErrorIs(t, err, internal.ErrManifestSignature)
+	})
+
+	t.Run("environment references are rejected", func(t *testing.T) {
+		withRef := strings.Replace(manifest, "mode: read_write", "mode: ${GOVAULT_TEST_MODE}", 1)
+		refPath := writeConfig(t, "ref.yaml", withRef)
+		require.NoError(t, os.WriteFile(refPath+internal.ManifestSignatureSuffix, internal.SignConfig([]byte(withRef), privateKey), 0o600))
+		_, err := internal.LoadSignedConfig(refPath, publicKey)
+		assert.ErrorContains(t, err, "${GOVAULT_TEST_MODE}")
+	})
+}
@@ -0,0 +1,47 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Mode restricts the operations an instance may perform. Rotations,
+// sweeps, purges and migrations both read and write ciphertexts and so
+// need ModeReadWrite.
+type Mode string
+
+const (
+	// ModeReadWrite allows encryption and decryption; this is the default
+	ModeReadWrite Mode = ""
+	// ModeEncryptOnly is for services that only write, e.g. ingestion
+	ModeEncryptOnly Mode = "encrypt-only"
+	// ModeDecryptOnly is for services that only read, e.g. reporting, read
+	// replicas or staging with production keys: writes of encrypted values
+	// fail instead of producing new ciphertexts. No default key is required.
+	ModeDecryptOnly Mode = "decrypt-only"
+)
+
+// ErrOperationNotAllowed is wrapped by errors of operations forbidden by
+// Config.Mode
+var ErrOperationNotAllowed = errors.New("operation not allowed")
+
+// Mode returns the mode the instance was configured with
+func (g *GovaultDB) Mode() Mode {
+	return g.mode
+}
+
+// allowEncrypt fails in decrypt-only mode
+func (g *GovaultDB) allowEncrypt() error {
+	if g.mode == ModeDecryptOnly {
+		return fmt.Errorf("encryption in %s mode: %w", g.mode, ErrOperationNotAllowed)
+	}
+	return nil
+}
+
+// allowDecrypt fails in encrypt-only mode
+func (g *GovaultDB) allowDecrypt() error {
+	if g.mode == ModeEncryptOnly {
+		return fmt.Errorf("decryption in %s mode: %w", g.mode, ErrOperationNotAllowed)
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+package internal_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/muhammadluth/govault/internal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type modeUser struct {
+	Email      string `bun:"email" encrypted:"true" blindindex:"email_bidx"`
+	Phone      string `bun:"phone" encrypted:"true" codec:"ciphersweet"`
+	EmailIndex string `bun:"email_bidx"`
+}
+
+func TestModes(t *testing.T) {
+	keys := map[string][]byte{
+		"1": []byte("727d37a0-a5f2-4d67-af47-83039c8e"),
+		"2": []byte("e778dc27-9b04-44c3-a862-feba061c"),
+	}
+
+	writer, err := internal.New(internal.Config{Keys: map[string][]byte{"2": keys["2"]}, DefaultKeyID: "2", Mode: internal.ModeEncryptOnly})
+	require.NoError(t, err)
+	assert.Equal(t, internal.ModeEncryptOnly, writer.Mode())
+
+	user := &modeUser{Email: "a@example.com", Phone: "+1 555 0100"}
+	require.NoError(t, writer.EncryptModel(user, ""))
+	assert.True(t, internal.IsEncrypted(user.Email))
+	assert.NotEmpty(t, user.EmailIndex)
+
+	_, err = writer.Decrypt(user.Email)
+	assert.ErrorIs(t, err, internal.ErrOperationNotAllowed)
+	assert.ErrorIs(t, writer.DecryptRecursive(&modeUser{Phone: user.Phone}), internal.ErrOperationNotAllowed)
+
+	// A reader needs no default key
+	reader, err := internal.New(internal.Config{Keys: keys, Mode: internal.ModeDecryptOnly, BlindIndexKeyID: "2", CipherSweetKeyID: "2"})
+	require.NoError(t, err)
+	assert.NoError(t, reader.HealthCheck(context.Background()))
+
+	read := *user
+	require.NoError(t, reader.DecryptRecursive(&read))
+	assert.Equal(t, "a@example.com", read.Email)
+	assert.Equal(t, "+1 555 0100", read.Phone)
+
+	index, err := reader.BlindIndex(&modeUser{}, "Email", "a@example.com")
+	require.NoError(t, err)
+	assert.Equal(t, user.EmailIndex, index)
+
+	_, err = reader.Encrypt("secret", "1")
+	assert.ErrorIs(t, err, internal.ErrOperationNotAllowed)
+	_, err = reader.EncryptField(&internal.FieldSpec{Deterministic: true}, "", "secret", "1")
+	assert.ErrorIs(t, err, internal.ErrOperationNotAllowed)
+	assert.ErrorIs(t, reader.EncryptModel(&modeUser{Phone: "x"}, "1"), internal.ErrOperationNotAllowed)
+
+	_, err = internal.New(internal.Config{Keys: keys, Mode: internal.ModeEncryptOnly})
+	assert.Error(t, err)
+	_, err = internal.New(internal.Config{Keys: keys, DefaultKeyID: "1", Mode: "write-only"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,177 @@
+package internal
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"sync"
+)
+
+// ErrNonceReuse is returned by encryptions whose nonce was already used
+// with the same key for a different ciphertext
+var ErrNonceReuse = errors.New("nonce reuse detected")
+
+// defaultNonceCheckCapacity is used when NonceCheck has no capacity set
+const defaultNonceCheckCapacity = 1 << 16
+
+// nonceCheckFalsePositiveRate sizes the filters
+const nonceCheckFalsePositiveRate = 1e-6
+
+// NonceCheck records the nonces used per key and flags a nonce seen before
+// with a different ciphertext. Deterministic values repeat their nonce with
+// the same ciphertext and are not flagged. Meant for tests and fuzzing, or
+// sampled in production.
+//
+// Checking every encryption remembers the nonces exactly, and a reuse
+// fails the encryption with ErrNonceReuse. Sampled checks remember them in
+// bloom filters, whose rare false positives must not fail writes: a reuse
+// is logged as a possible one instead.
+type NonceCheck struct {
+	// Capacity is the number of nonces remembered per key, default 65536.
+	// The sets are cleared when full, which bounds memory at about 100
+	// bytes per nonce, or 7 bytes when sampling.
+	Capacity int
+	// SampleRate is the fraction of encryptions checked, default 1
+	SampleRate float64
+	// OnReuse, if set, is called for detected reuses instead of failing or
+	// logging them; encryption then succeeds.
+	OnReuse func(keyID string)
+}
+
+// nonceTracker holds the filters of a NonceCheck
+type nonceTracker struct {
+	config NonceCheck
+
+	mu      sync.Mutex
+	filters map[string]*nonceFilter
+}
+
+// nonceFilter remembers the nonces and nonce/ciphertext pairs of one key:
+// exactly, mapping the nonce digests to the digest of their first pair,
+// or in bloom filters when sampling
+type nonceFilter struct {
+	exact  map[[sha256.Size]byte][sha256.Size]byte
+	nonces bloomFilter
+	pairs  bloomFilter
+	count  int
+}
+
+// RecordNonce records a nonce used with keyID when Config.NonceCheck is
+// set, reporting reuse as NonceCheck describes. Codecs with their own
+// nonces call it after sealing; keyID only needs to identify the AEAD key.
+func (g *GovaultDB) RecordNonce(keyID string, nonce, ciphertext []byte) error {
+	reused, exact := g.nonces.check(keyID, nonce, ciphertext)
+	switch {
+	case !reused:
+		return nil
+	case g.nonces.config.OnReuse != nil:
+		g.nonces.config.OnReuse(keyID)
+		return nil
+	case !exact:
+		g.warn("possible nonce reuse: a sampled nonce was seen before with another ciphertext", "key_id", keyID)
+		return nil
+	}
+	return fmt.Errorf("%w for key '%s'", ErrNonceReuse, keyID)
+}
+
+func newNonceTracker(config *NonceCheck) *nonceTracker {
+	if config == nil {
+		return nil
+	}
+	t := &nonceTracker{config: *config, filters: make(map[string]*nonceFilter)}
+	if t.config.Capacity <= 0 {
+		t.config.Capacity = defaultNonceCheckCapacity
+	}
+	if t.config.SampleRate <= 0 {
+		t.config.SampleRate = 1
+	}
+	return t
+}
+
+// check records nonce for keyID and reports whether it was used before
+// with another ciphertext, and whether that is exact rather than a bloom
+// filter match. It does nothing when nonce checking is disabled.
+func (t *nonceTracker) check(keyID string, nonce, ciphertext []byte) (reused, exact bool) {
+	sampled := t != nil && t.config.SampleRate < 1
+	if t == nil || (sampled && rand.Float64() >= t.config.SampleRate) {
+		return false, false
+	}
+
+	var pairSum [sha256.Size]byte
+	pair := sha256.New()
+	pair.Write(nonce)
+	pair.Write(ciphertext)
+	pair.Sum(pairSum[:0])
+	nonceSum := sha256.Sum256(nonce)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	f := t.filters[keyID]
+	if f == nil || f.count >= t.config.Capacity {
+		f = &nonceFilter{}
+		if sampled {
+			f.nonces = newBloomFilter(t.config.Capacity)
+			f.pairs = newBloomFilter(t.config.Capacity)
+		} else {
+			f.exact = make(map[[sha256.Size]byte][sha256.Size]byte)
+		}
+		t.filters[keyID] = f
+	}
+	f.count++
+
+	if !sampled {
+		first, seen := f.exact[nonceSum]
+		if !seen {
+			f.exact[nonceSum] = pairSum
+		}
+		return seen && first != pairSum, true
+	}
+	reused = f.nonces.contains(nonceSum[:]) && !f.pairs.contains(pairSum[:])
+	f.nonces.add(nonceSum[:])
+	f.pairs.add(pairSum[:])
+	return reused, false
+}
+
+// bloomFilter is a fixed size bloom filter over SHA-256 digests
+type bloomFilter struct {
+	bits   []uint64
+	hashes int
+}
+
+// newBloomFilter sizes a filter for capacity items at
+// nonceCheckFalsePositiveRate
+func newBloomFilter(capacity int) bloomFilter {
+	m := math.Ceil(-float64(capacity) * math.Log(nonceCheckFalsePositiveRate) / (math.Ln2 * math.Ln2))
+	k := int(math.Round(m / float64(capacity) * math.Ln2))
+	return bloomFilter{bits: make([]uint64, (int(m)+63)/64), hashes: max(k, 1)}
+}
+
+// positions derives the bit positions of digest by double hashing
+func (b bloomFilter) positions(digest []byte, fn func(word int, mask uint64) bool) bool {
+	h1 := binary.BigEndian.Uint64(digest[0:8])
+	h2 := binary.BigEndian.Uint64(digest[8:16]) | 1
+	size := uint64(len(b.bits) * 64)
+	for i := 0; i < b.hashes; i++ {
+		bit := (h1 + uint64(i)*h2) % size
+		if !fn(int(bit/64), 1<<(bit%64)) {
+			return false
+		}
+	}
+	return true
+}
+
+func (b bloomFilter) add(digest []byte) {
+	b.positions(digest, func(word int, mask uint64) bool {
+		b.bits[word] |= mask
+		return true
+	})
+}
+
+func (b bloomFilter) contains(digest []byte) bool {
+	return b.positions(digest, func(word int, mask uint64) bool {
+		return b.bits[word]&mask != 0
+	})
+}
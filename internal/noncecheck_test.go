@@ -0,0 +1,107 @@
+package internal_test
+
+import (
+	"bytes"
+	"log/slog"
+	"sync"
+	"testing"
+
+	"github.com/muhammadluth/govault/internal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type nonceUser struct {
+	Email string `bun:"email" encrypted:"true"`
+	SSN   string `bun:"ssn" encrypted:"true" deterministic:"true"`
+	Phone string `bun:"phone" encrypted:"true" codec:"ciphersweet"`
+}
+
+func newNonceCheckDB(t *testing.T, check *internal.NonceCheck) *internal.GovaultDB {
+	t.Helper()
+	g, err := internal.New(internal.Config{
+		Keys:         map[string][]byte{"1": []byte("727d37a0-a5f2-4d67-af47-83039c8e")},
+		DefaultKeyID: "1",
+		NonceCheck:   check,
+	})
+	require.NoError(t, err)
+	return g
+}
+
+func TestNonceCheck(t *testing.T) {
+	g := newNonceCheckDB(t, &internal.NonceCheck{})
+
+	t.Run("encryption paths record without false alarms", func(t *testing.T) {
+		for i := 0; i < 2000; i++ {
+			u := &nonceUser{Email: "a@example.com", SSN: "123-45-6789", Phone: "+1 555 0100"}
+			require.NoError(t, g.EncryptModel(u, ""))
+		}
+	})
+
+	t.Run("reuse with a different ciphertext fails", func(t *testing.T) {
+		nonce := []byte("0123456789ab")
+		require.NoError(t, g.RecordNonce("custom", nonce, []byte("first")))
+		require.NoError(t, g.RecordNonce("custom", nonce, []byte("first")), "repeated deterministic values are fine")
+		assert.ErrorIs(t, g.RecordNonce("custom", nonce, []byte("second")), internal.ErrNonceReuse)
+		assert.NoError(t, g.RecordNonce("other", nonce, []byte("second")), "nonces are tracked per key")
+	})
+
+	t.Run("disabled by default", func(t *testing.T) {
+		plain := newNonceCheckDB(t, nil)
+		nonce := []byte("0123456789ab")
+		require.NoError(t, plain.RecordNonce("custom", nonce, []byte("first")))
+		assert.NoError(t, plain.RecordNonce("custom", nonce, []byte("second")))
+	})
+}
+
+func TestNonceCheckOnReuse(t *testing.T) {
+	var mu sync.Mutex
+	var reported []string
+	g := newNonceCheckDB(t, &internal.NonceCheck{
+		Capacity: 4,
+		OnReuse: func(keyID string) {
+			mu.Lock()
+			defer mu.Unlock()
+			reported = append(reported, keyID)
+		},
+	})
+
+	nonce := []byte("0123456789ab")
+	require.NoError(t, g.RecordNonce("custom", nonce, []byte("first")))
+	require.NoError(t, g.RecordNonce("custom", nonce, []byte("second")))
+	assert.Equal(t, []string{"custom"}, reported)
+
+	// Full filters are cleared, bounding memory at the cost of history
+	for i := 0; i < 3; i++ {
+		require.NoError(t, g.RecordNonce("custom", []byte{byte(i)}, nil))
+	}
+	require.NoError(t, g.RecordNonce("custom", nonce, []byte("third")))
+	assert.Len(t, reported, 1)
+}
+
+func TestNonceCheckSampling(t *testing.T) {
+	g := newNonceCheckDB(t, &internal.NonceCheck{SampleRate: 0.000001})
+
+	nonce := []byte("0123456789ab")
+	for i := 0; i < 100; i++ {
+		assert.NoError(t, g.RecordNonce("custom", nonce, []byte{byte(i)}))
+	}
+}
+
+func TestNonceCheckSampledReuseIsLogged(t *testing.T) {
+	var logs bytes.Buffer
+	g, err := internal.New(internal.Config{
+		Keys:         map[string][]byte{"1": []byte("727d37a0-a5f2-4d67-af47-83039c8e")},
+		DefaultKeyID: "1",
+		NonceCheck:   &internal.NonceCheck{SampleRate: 0.99999999},
+		Logger:       slog.New(slog.NewTextHandler(&logs, nil)),
+	})
+	require.NoError(t, err)
+
+	// Bloom filter matches may be false positives: writes go on
+	nonce := []byte("0123456789ab")
+	require.NoError(t, g.RecordNonce("custom", nonce, []byte("first")))
+	assert.NoError(t, g.RecordNonce("custom", nonce, []byte("second")))
+	assert.Contains(t, logs.String(), "possible nonce reuse")
+	assert.Contains(t, logs.String(), "key_id=custom")
+}
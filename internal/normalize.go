@@ -0,0 +1,150 @@
+package internal
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// Normalizer canonicalizes a plaintext before it is encrypted and indexed,
+// so that lookups match values written in another format. It is selected
+// per field with the normalize tag, a pipeline applied in order:
+//
+//	Phone string `bun:"phone" encrypted:"true" blindindex:"phone_bidx" normalize:"e164"`
+//	Email string `bun:"email" encrypted:"true" blindindex:"email_bidx" normalize:"email"`
+//
+// The normalized value is what gets stored, and decrypts. Normalizers must
+// be idempotent: lookups normalize again values that may already be.
+type Normalizer func(value string) (string, error)
+
+// Built-in normalizers
+const (
+	// NormalizeTrim removes leading and trailing white space
+	NormalizeTrim = "trim"
+	// NormalizeLower lowercases the value
+	NormalizeLower = "lower"
+	// NormalizeEmail trims and lowercases an email address, rejecting
+	// values that are not one
+	NormalizeEmail = "email"
+	// NormalizeE164 formats an international phone number as E.164, e.g.
+	// "+62 811-234-567" to "+62811234567". Numbers without a country code
+	// are rejected; see PhoneNormalizer for national numbers.
+	NormalizeE164 = "e164"
+)
+
+var (
+	normalizersMu sync.RWMutex
+	normalizers   = map[string]Normalizer{
+		NormalizeTrim:  func(value string) (string, error) { return strings.TrimSpace(value), nil },
+		NormalizeLower: func(value string) (string, error) { return strings.ToLower(value), nil },
+		NormalizeEmail: normalizeEmail,
+		NormalizeE164:  PhoneNormalizer(""),
+		NormalizePAN:   normalizePAN,
+		NormalizeSSN:   normalizeSSN,
+		NormalizeNIK:   normalizeNIK,
+		NormalizeIBAN:  normalizeIBAN,
+	}
+)
+
+// RegisterNormalizer makes n available to normalize tags under name,
+// replacing any normalizer registered before
+func RegisterNormalizer(name string, n Normalizer) {
+	normalizersMu.Lock()
+	defer normalizersMu.Unlock()
+	normalizers[name] = n
+}
+
+// lookupNormalizer returns the normalizer registered under name
+func lookupNormalizer(name string) (Normalizer, error) {
+	normalizersMu.RLock()
+	defer normalizersMu.RUnlock()
+	n, ok := normalizers[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown normalizer '%s'", name)
+	}
+	return n, nil
+}
+
+// parseNormalizers parses normalizer names separated by commas
+func parseNormalizers(tag string) []string {
+	var names []string
+	for _, name := range strings.Split(tag, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// NormalizeField runs the normalizers of field on a non-empty plaintext,
+// in order. Errors do not include the plaintext. Fields of an unknown
+// preset fail, rather than be written without its settings.
+func NormalizeField(field *FieldSpec, plaintext string) (string, error) {
+	if field == nil || plaintext == "" {
+		return plaintext, nil
+	}
+	if field.Preset != "" {
+		if _, err := lookupPreset(field.Preset); err != nil {
+			return "", err
+		}
+	}
+	for _, name := range field.Normalizers {
+		n, err := lookupNormalizer(name)
+		if err != nil {
+			return "", err
+		}
+		if plaintext, err = n(plaintext); err != nil {
+			return "", fmt.Errorf("normalizer '%s': %w", name, err)
+		}
+	}
+	return plaintext, nil
+}
+
+// normalizeEmail is the NormalizeEmail normalizer
+func normalizeEmail(value string) (string, error) {
+	value = strings.ToLower(strings.TrimSpace(value))
+	if _, _, err := SplitEmail(value); err != nil {
+		return "", err
+	}
+	return value, nil
+}
+
+// PhoneNormalizer returns a normalizer formatting phone numbers as E.164.
+// Separators, spaces, dashes, dots, slashes and parentheses, are dropped
+// and a 00 prefix is read as +. With a countryCode, e.g. "62", numbers
+// without one are national: a leading trunk 0 is replaced by the code.
+// Register it for a region:
+//
+//	govault.RegisterNormalizer("e164-id", govault.PhoneNormalizer("62"))
+func PhoneNormalizer(countryCode string) Normalizer {
+	return func(value string) (string, error) {
+		var digits strings.Builder
+		international := false
+		for _, r := range strings.TrimSpace(value) {
+			switch {
+			case r >= '0' && r <= '9':
+				digits.WriteRune(r)
+			case r == '+' && digits.Len() == 0 && !international:
+				international = true
+			case strings.ContainsRune(" -./()", r):
+			default:
+				return "", fmt.Errorf("value is not a phone number")
+			}
+		}
+
+		number := digits.String()
+		switch {
+		case international:
+		case strings.HasPrefix(number, "00"):
+			number = number[2:]
+		case countryCode != "":
+			number = countryCode + strings.TrimPrefix(number, "0")
+		default:
+			return "", fmt.Errorf("phone number has no country code")
+		}
+		if len(number) < 7 || len(number) > 15 || number[0] == '0' {
+			return "", fmt.Errorf("value is not a phone number")
+		}
+		return "+" + number, nil
+	}
+}
@@ -0,0 +1,90 @@
+package internal_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/muhammadluth/govault/internal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type normalizedContact struct {
+	ID        int64    `bun:"id,pk"`
+	Phone     string   `bun:"phone" encrypted:"true" blindindex:"phone_bidx" normalize:"e164"`
+	PhoneBidx string   `bun:"phone_bidx"`
+	Email     string   `bun:"email" encrypted:"true" plainhash:"email_hash" normalize:"email"`
+	EmailHash string   `bun:"email_hash"`
+	Code      string   `bun:"code" encrypted:"true" normalize:"trim, upper"`
+	Others    []string `bun:"others,array" encrypted:"true" normalize:"e164"`
+}
+
+func TestNormalizers(t *testing.T) {
+	g, err := internal.New(internal.Config{
+		Keys:         map[string][]byte{"1": []byte("727d37a0-a5f2-4d67-af47-83039c8e")},
+		DefaultKeyID: "1",
+	})
+	require.NoError(t, err)
+
+	internal.RegisterNormalizer("upper", func(value string) (string, error) {
+		return strings.ToUpper(value), nil
+	})
+
+	t.Run("values are stored normalized", func(t *testing.T) {
+		c := &normalizedContact{
+			Phone:  "+62 (811) 234-567",
+			Email:  " Ada@Example.com ",
+			Code:   " ab1 ",
+			Others: []string{"0062 812.345.678"},
+		}
+		require.NoError(t, g.EncryptModel(c, ""))
+		require.NoError(t, g.DecryptRecursive(c))
+		assert.Equal(t, "+62811234567", c.Phone)
+		assert.Equal(t, "ada@example.com", c.Email)
+		assert.Equal(t, "AB1", c.Code)
+		assert.Equal(t, []string{"+62812345678"}, c.Others)
+	})
+
+	t.Run("lookups match any format", func(t *testing.T) {
+		c := &normalizedContact{Phone: "+62811234567", Email: "ada@example.com"}
+		require.NoError(t, g.EncryptModel(c, ""))
+
+		for _, phone := range []string{"+62 811 234 567", "0062-811-234-567"} {
+			index, err := g.BlindIndex(c, "Phone", phone)
+			require.NoError(t, err)
+			assert.Equal(t, c.PhoneBidx, index, phone)
+		}
+		hash, err := g.PlainHash(c, "Email", "ADA@example.COM")
+		require.NoError(t, err)
+		assert.Equal(t, c.EmailHash, hash)
+	})
+
+	t.Run("invalid values are rejected without the plaintext", func(t *testing.T) {
+		err := g.EncryptModel(&normalizedContact{Phone: "811 234 567"}, "")
+		assert.ErrorContains(t, err, "no country code")
+		assert.NotContains(t, err.Error(), "811")
+
+		err = g.EncryptModel(&normalizedContact{Phone: "call me"}, "")
+		assert.ErrorContains(t, err, "not a phone number")
+	})
+
+	t.Run("national numbers", func(t *testing.T) {
+		normalize := internal.PhoneNormalizer("62")
+		for in, want := range map[string]string{
+			"0811-234-567":   "+62811234567",
+			"811234567":      "+62811234567",
+			"+1 202 555 01":  "+120255501",
+			"00442071838750": "+442071838750",
+		} {
+			got, err := normalize(in)
+			require.NoError(t, err, in)
+			assert.Equal(t, want, got, in)
+		}
+	})
+
+	t.Run("unknown normalizers fail", func(t *testing.T) {
+		field := &internal.FieldSpec{Normalizers: []string{"nope"}}
+		_, err := internal.NormalizeField(field, "x")
+		assert.ErrorContains(t, err, "unknown normalizer 'nope'")
+	})
+}
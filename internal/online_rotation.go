@@ -0,0 +1,169 @@
+package internal
+
+import (
+	"fmt"
+	"sync"
+)
+
+// RotationStage is a step of an OnlineRotation
+type RotationStage string
+
+// Online rotation stages, in order:
+//
+//	idle        the old key is the default, the new key is unknown
+//	dual_read   the new key is loaded decrypt-only; writes use the old key
+//	encrypt_new the new key is the default; both keys decrypt
+//	verified    sampling found no data left on the old key
+//	finalized   the old key is decrypt-only
+//
+// Every instance must reach dual_read before any instance moves to
+// encrypt_new, otherwise instances still on idle cannot read new writes.
+// Existing rows are re-encrypted between encrypt_new and verified, e.g. with
+// a rotation job.
+const (
+	StageIdle       RotationStage = "idle"
+	StageDualRead   RotationStage = "dual_read"
+	StageEncryptNew RotationStage = "encrypt_new"
+	StageVerified   RotationStage = "verified"
+	StageFinalized  RotationStage = "finalized"
+)
+
+// rotationTransitions lists the allowed moves from every stage. Moving back
+// one stage rolls back until the rotation is finalized.
+var rotationTransitions = map[RotationStage][]RotationStage{
+	StageIdle:       {StageDualRead},
+	StageDualRead:   {StageEncryptNew, StageIdle},
+	StageEncryptNew: {StageVerified, StageDualRead},
+	StageVerified:   {StageFinalized, StageEncryptNew},
+	StageFinalized:  nil,
+}
+
+// RotationVerification is the result of sampling stored data during an
+// online rotation
+type RotationVerification struct {
+	Sampled int64 `json:"sampled"`
+	// OnOldKey counts values still encrypted with the old key
+	OnOldKey int64 `json:"on_old_key"`
+	// Undecryptable counts sampled values that failed to decrypt
+	Undecryptable int64 `json:"undecryptable"`
+}
+
+// OK reports whether nothing depends on the old key anymore
+func (v *RotationVerification) OK() bool {
+	return v.OnOldKey == 0 && v.Undecryptable == 0
+}
+
+// OnlineRotation switches the default key from OldKeyID to NewKeyID while
+// traffic continues. Each stage reconfigures the keys of this GovaultDB
+// only; run the same stages on every instance.
+type OnlineRotation struct {
+	mu       sync.Mutex
+	g        *GovaultDB
+	stage    RotationStage
+	OldKeyID string
+	NewKeyID string
+}
+
+// NewOnlineRotation starts a rotation from the current default key to
+// newKeyID. The new key may be added later with AddKey, before DualRead.
+func (g *GovaultDB) NewOnlineRotation(newKeyID string) (*OnlineRotation, error) {
+	oldKeyID := g.GetDefaultKeyID()
+	if newKeyID == "" || newKeyID == oldKeyID {
+		return nil, fmt.Errorf("new key ID must differ from the default key '%s'", oldKeyID)
+	}
+	return &OnlineRotation{g: g, stage: StageIdle, OldKeyID: oldKeyID, NewKeyID: newKeyID}, nil
+}
+
+// Stage returns the current stage
+func (r *OnlineRotation) Stage() RotationStage {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.stage
+}
+
+// DualRead loads the new key for decryption only, so this instance reads
+// data written by instances already encrypting with it
+func (r *OnlineRotation) DualRead() error {
+	return r.transition(StageDualRead, func() error {
+		if _, exists := r.g.keyEntry(r.NewKeyID); !exists {
+			return fmt.Errorf("new key ID '%s' not found in keys, add it first", r.NewKeyID)
+		}
+		if err := r.g.SetDefaultKey(r.OldKeyID); err != nil {
+			return err
+		}
+		return r.g.setDecryptOnly(r.NewKeyID, true)
+	})
+}
+
+// EncryptNew makes the new key the default; the old key still decrypts
+func (r *OnlineRotation) EncryptNew() error {
+	return r.transition(StageEncryptNew, func() error {
+		if err := r.g.setDecryptOnly(r.NewKeyID, false); err != nil {
+			return err
+		}
+		return r.g.SetDefaultKey(r.NewKeyID)
+	})
+}
+
+// Verify records the result of sampling stored data, e.g. from
+// BunDB.VerifyRotation. It fails unless v.OK().
+func (r *OnlineRotation) Verify(v *RotationVerification) error {
+	return r.transition(StageVerified, func() error {
+		if v == nil || !v.OK() {
+			return fmt.Errorf("rotation verification failed: %+v", v)
+		}
+		return nil
+	})
+}
+
+// Finalize marks the old key decrypt-only. The rotation cannot be rolled
+// back afterwards.
+func (r *OnlineRotation) Finalize() error {
+	return r.transition(StageFinalized, func() error {
+		return r.g.setDecryptOnly(r.OldKeyID, true)
+	})
+}
+
+// Rollback moves back one stage and restores its key configuration
+func (r *OnlineRotation) Rollback() error {
+	r.mu.Lock()
+	stage := r.stage
+	r.mu.Unlock()
+
+	switch stage {
+	case StageDualRead:
+		return r.transition(StageIdle, func() error {
+			if err := r.g.SetDefaultKey(r.OldKeyID); err != nil {
+				return err
+			}
+			return r.g.setDecryptOnly(r.NewKeyID, true)
+		})
+	case StageEncryptNew:
+		return r.DualRead()
+	case StageVerified:
+		return r.EncryptNew()
+	}
+	return fmt.Errorf("cannot roll back rotation in stage %s", stage)
+}
+
+// transition applies a move to stage if the state machine allows it
+func (r *OnlineRotation) transition(to RotationStage, apply func() error) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	allowed := false
+	for _, next := range rotationTransitions[r.stage] {
+		if next == to {
+			allowed = true
+		}
+	}
+	if !allowed {
+		return fmt.Errorf("invalid rotation transition from %s to %s", r.stage, to)
+	}
+
+	if err := apply(); err != nil {
+		return fmt.Errorf("failed to enter rotation stage %s: %w", to, err)
+	}
+	r.stage = to
+	return nil
+}
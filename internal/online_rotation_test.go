@@ -0,0 +1,107 @@
+package internal_test
+
+import (
+	"testing"
+
+	"github.com/muhammadluth/govault/internal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOnlineRotation(t *testing.T) {
+	g := newTestVault(t)
+
+	r, err := g.NewOnlineRotation("3")
+	require.NoError(t, err)
+	assert.Equal(t, internal.StageIdle, r.Stage())
+	assert.Equal(t, "2", r.OldKeyID)
+
+	// The new key must be loaded before dual read
+	assert.Error(t, r.DualRead())
+	require.NoError(t, g.AddKey("3", []byte("e778dc27-9b04-44c3-a862-83039c8e")))
+
+	// Stages cannot be skipped
+	assert.Error(t, r.EncryptNew())
+	assert.Error(t, r.Finalize())
+
+	require.NoError(t, r.DualRead())
+	assert.True(t, g.IsDecryptOnly("3"))
+	_, err = g.Encrypt("secret", "3")
+	assert.Error(t, err)
+	oldCiphertext, err := g.Encrypt("secret")
+	require.NoError(t, err)
+	keyID, err := g.GetKeyIDFromEncryptedData(oldCiphertext)
+	require.NoError(t, err)
+	assert.Equal(t, "2", keyID)
+
+	require.NoError(t, r.EncryptNew())
+	assert.Equal(t, "3", g.GetDefaultKeyID())
+	newCiphertext, err := g.Encrypt("secret")
+	require.NoError(t, err)
+	keyID, err = g.GetKeyIDFromEncryptedData(newCiphertext)
+	require.NoError(t, err)
+	assert.Equal(t, "3", keyID)
+
+	// Both keys decrypt during the window
+	for _, ct := range []string{oldCiphertext, newCiphertext} {
+		plaintext, err := g.Decrypt(ct)
+		require.NoError(t, err)
+		assert.Equal(t, "secret", plaintext)
+	}
+
+	assert.Error(t, r.Verify(&internal.RotationVerification{Sampled: 10, OnOldKey: 1}))
+	assert.Equal(t, internal.StageEncryptNew, r.Stage())
+	require.NoError(t, r.Verify(&internal.RotationVerification{Sampled: 10}))
+
+	require.NoError(t, r.Finalize())
+	assert.Equal(t, internal.StageFinalized, r.Stage())
+	assert.True(t, g.IsDecryptOnly("2"))
+	_, err = g.Encrypt("secret", "2")
+	assert.Error(t, err)
+	plaintext, err := g.Decrypt(oldCiphertext)
+	require.NoError(t, err)
+	assert.Equal(t, "secret", plaintext)
+
+	assert.Error(t, r.Rollback())
+}
+
+func TestOnlineRotationRollback(t *testing.T) {
+	g := newTestVault(t)
+	require.NoError(t, g.AddKey("3", []byte("e778dc27-9b04-44c3-a862-83039c8e")))
+
+	r, err := g.NewOnlineRotation("3")
+	require.NoError(t, err)
+	require.NoError(t, r.DualRead())
+	require.NoError(t, r.EncryptNew())
+
+	require.NoError(t, r.Rollback())
+	assert.Equal(t, internal.StageDualRead, r.Stage())
+	assert.Equal(t, "2", g.GetDefaultKeyID())
+	assert.True(t, g.IsDecryptOnly("3"))
+
+	require.NoError(t, r.Rollback())
+	assert.Equal(t, internal.StageIdle, r.Stage())
+	assert.Error(t, r.Rollback())
+}
+
+func TestDecryptOnlyKeys(t *testing.T) {
+	keys := map[string][]byte{
+		"1": []byte("727d37a0-a5f2-4d67-af47-83039c8e"),
+		"2": []byte("e778dc27-9b04-44c3-a862-feba061c"),
+	}
+
+	_, err := internal.New(internal.Config{Keys: keys, DefaultKeyID: "2", DecryptOnlyKeyIDs: []string{"2"}})
+	assert.Error(t, err)
+	_, err = internal.New(internal.Config{Keys: keys, DefaultKeyID: "2", DecryptOnlyKeyIDs: []string{"missing"}})
+	assert.Error(t, err)
+
+	g, err := internal.New(internal.Config{Keys: keys, DefaultKeyID: "2", DecryptOnlyKeyIDs: []string{"1"}})
+	require.NoError(t, err)
+	_, err = g.Encrypt("secret", "1")
+	assert.Error(t, err)
+	_, err = g.EncryptField(&internal.FieldSpec{Deterministic: true}, "", "secret", "1")
+	assert.Error(t, err)
+
+	assert.Error(t, g.AddKey("1", []byte("00000000-0000-0000-0000-000000000")))
+	assert.NoError(t, g.AddKey("1", keys["1"]))
+}
@@ -0,0 +1,190 @@
+package internal
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// panTokenDomain separates card number token keys from blind index keys
+const panTokenDomain = "govault pan token"
+
+// Card number handling. A field with pan:"true" holds a primary account
+// number (PAN): it is normalized to its digits and checked with Luhn
+// before encryption, see NormalizePAN, and may fill companion columns:
+//
+//	Card      string `bun:"card" encrypted:"true" pan:"true" panbin:"card_bin" panlast4:"card_last4" pantoken:"card_token"`
+//	CardBIN   string `bun:"card_bin"`
+//	CardLast4 string `bun:"card_last4"`
+//	CardToken string `bun:"card_token"`
+//
+// The BIN, the first six digits, and the last four digits are the parts
+// PCI DSS lets be shown together; see MaskPAN and the maskpan transform.
+const (
+	// NormalizePAN drops spaces and dashes from a card number and rejects
+	// values of other characters, of fewer than 12 or more than 19 digits,
+	// or failing the Luhn check
+	NormalizePAN = "pan"
+	// TransformMaskPAN shows the BIN and last four digits of a card number
+	TransformMaskPAN = "maskpan"
+)
+
+// setPAN makes field hold a card number: NormalizePAN runs first
+func (f *FieldSpec) setPAN() {
+	if !slices.Contains(f.Normalizers, NormalizePAN) {
+		f.Normalizers = append([]string{NormalizePAN}, f.Normalizers...)
+	}
+}
+
+// panBINLength is the number of digits of the BIN
+const panBINLength = 6
+
+// normalizePAN is the NormalizePAN normalizer
+func normalizePAN(value string) (string, error) {
+	var digits strings.Builder
+	for _, r := range strings.TrimSpace(value) {
+		switch {
+		case r >= '0' && r <= '9':
+			digits.WriteRune(r)
+		case r == ' ' || r == '-':
+		default:
+			return "", fmt.Errorf("value is not a card number")
+		}
+	}
+	pan := digits.String()
+	if !ValidPAN(pan) {
+		return "", fmt.Errorf("value is not a card number")
+	}
+	return pan, nil
+}
+
+// ValidPAN reports whether pan is 12 to 19 digits passing the Luhn check
+func ValidPAN(pan string) bool {
+	if len(pan) < 12 || len(pan) > 19 {
+		return false
+	}
+	for _, r := range pan {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return luhnSum(pan)%10 == 0
+}
+
+// luhnSum returns the Luhn sum of digits, a multiple of 10 when valid
+func luhnSum(digits string) int {
+	sum := 0
+	for i := len(digits) - 1; i >= 0; i-- {
+		d := int(digits[i] - '0')
+		if (len(digits)-i)%2 == 0 {
+			if d *= 2; d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+	}
+	return sum
+}
+
+// MaskPAN returns pan with every digit but the BIN and the last four
+// replaced by *, e.g. 411111******1111. Values too short to keep both
+// parts keep only the last four.
+func MaskPAN(pan string) string {
+	if len(pan) < panBINLength+4 {
+		return maskKeeping(pan, 0)
+	}
+	return maskKeeping(pan, panBINLength)
+}
+
+// maskKeeping masks all but the first keep and the last four characters
+func maskKeeping(value string, keep int) string {
+	runes := []rune(value)
+	for i := keep; i < len(runes)-4; i++ {
+		runes[i] = '*'
+	}
+	return string(runes)
+}
+
+// maskPAN is the TransformMaskPAN transform
+func maskPAN(g *GovaultDB, field *FieldSpec, table, plaintext string) (string, error) {
+	return MaskPAN(plaintext), nil
+}
+
+// PANToken returns the token of a card number for field, stored in the
+// column named by its pantoken tag. A token has the length, BIN and last
+// four digits of the card number, and middle digits derived from it with
+// a subkey of the blind index key bound to the table and column, so
+// systems expecting a card number can store and join it. Tokens always
+// fail the Luhn check, so one is never taken for a card number. Like a
+// blind index, distinct card numbers of the same BIN and last four digits
+// may share a token.
+func (g *GovaultDB) PANToken(field *FieldSpec, table, pan string) (string, error) {
+	if field.PANToken == "" {
+		return "", fmt.Errorf("field %s has no card token", field.Name)
+	}
+	pan, err := normalizePAN(pan)
+	if err != nil {
+		return "", err
+	}
+	subKey, err := g.blindIndexSubKey(panTokenDomain, table, field.Column)
+	if err != nil {
+		return "", err
+	}
+	mac := hmac.New(sha256.New, subKey)
+	mac.Write([]byte(pan))
+	sum := mac.Sum(nil)
+
+	token := []byte(pan)
+	for i := panBINLength; i < len(token)-4; i++ {
+		token[i] = '0' + sum[i%len(sum)]%10
+	}
+	if luhnSum(string(token))%10 == 0 {
+		// Changing one digit always changes the Luhn sum modulo 10
+		i := len(token) - 5
+		token[i] = '0' + (token[i]-'0'+1)%10
+	}
+	return string(token), nil
+}
+
+// setPANColumns writes the BIN, last four digits and token of plaintext, a
+// card number, into the companion columns set for field
+func (g *GovaultDB) setPANColumns(val reflect.Value, spec *ModelSpec, field *FieldSpec, plaintext string) error {
+	pan := ""
+	if plaintext != "" {
+		var err error
+		if pan, err = normalizePAN(plaintext); err != nil {
+			return err
+		}
+	}
+
+	columns := []struct {
+		column, kind string
+		value        func() (string, error)
+	}{
+		{field.PANBIN, "card BIN", func() (string, error) { return pan[:panBINLength], nil }},
+		{field.PANLast4, "card last four", func() (string, error) { return pan[len(pan)-4:], nil }},
+		{field.PANToken, "card token", func() (string, error) { return g.PANToken(field, spec.Table, pan) }},
+	}
+	for _, c := range columns {
+		if c.column == "" {
+			continue
+		}
+		target, err := companionField(val, spec, c.column, c.kind)
+		if err != nil {
+			return err
+		}
+		if pan == "" {
+			target.SetString("")
+			continue
+		}
+		value, err := c.value()
+		if err != nil {
+			return err
+		}
+		target.SetString(value)
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package internal_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/muhammadluth/govault/internal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type payment struct {
+	ID        int64  `bun:"id,pk"`
+	Card      string `bun:"card" encrypted:"true" pan:"true" panbin:"card_bin" panlast4:"card_last4" pantoken:"card_token" transform:"support=maskpan"`
+	CardBIN   string `bun:"card_bin"`
+	CardLast4 string `bun:"card_last4"`
+	CardToken string `bun:"card_token"`
+}
+
+func TestValidPAN(t *testing.T) {
+	assert.True(t, internal.ValidPAN("4111111111111111"))
+	assert.True(t, internal.ValidPAN("378282246310005"))
+	assert.False(t, internal.ValidPAN("4111111111111112"), "Luhn check")
+	assert.False(t, internal.ValidPAN("41111111111"), "too short")
+	assert.False(t, internal.ValidPAN("4111 1111 1111 1111"))
+
+	assert.Equal(t, "411111******1111", internal.MaskPAN("4111111111111111"))
+	assert.Equal(t, "*****1111", internal.MaskPAN("123451111"))
+}
+
+func TestPANColumns(t *testing.T) {
+	g, err := internal.New(internal.Config{
+		Keys:         map[string][]byte{"1": []byte("727d37a0-a5f2-4d67-af47-83039c8e")},
+		DefaultKeyID: "1",
+	})
+	require.NoError(t, err)
+
+	t.Run("companion columns and token", func(t *testing.T) {
+		p := &payment{Card: " 4111-1111-1111-1111 "}
+		require.NoError(t, g.EncryptModel(p, ""))
+		assert.True(t, internal.IsEncrypted(p.Card))
+		assert.Equal(t, "411111", p.CardBIN)
+		assert.Equal(t, "1111", p.CardLast4)
+		require.Len(t, p.CardToken, 16)
+		assert.Equal(t, "411111", p.CardToken[:6])
+		assert.Equal(t, "1111", p.CardToken[12:])
+		assert.NotEqual(t, "4111111111111111", p.CardToken)
+		assert.False(t, internal.ValidPAN(p.CardToken), "tokens fail the Luhn check")
+
+		spec := internal.GetModelSpec(p)
+		token, err := g.PANToken(spec.Field("Card"), spec.Table, "4111 1111 1111 1111")
+		require.NoError(t, err)
+		assert.Equal(t, p.CardToken, token)
+
+		require.NoError(t, g.DecryptRecursive(p))
+		assert.Equal(t, "4111111111111111", p.Card, "the normalized number is stored")
+	})
+
+	t.Run("invalid card numbers are rejected", func(t *testing.T) {
+		err := g.EncryptModel(&payment{Card: "4111111111111112"}, "")
+		require.Error(t, err)
+		assert.NotContains(t, err.Error(), "4111111111111112")
+	})
+
+	t.Run("maskpan transform", func(t *testing.T) {
+		p := &payment{Card: "5555555555554444"}
+		require.NoError(t, g.EncryptModel(p, ""))
+		require.NoError(t, g.DecryptRecursiveContext(internal.WithTransformRole(context.Background(), "support"), p))
+		assert.Equal(t, "555555******4444", p.Card)
+	})
+
+}
@@ -0,0 +1,63 @@
+package internal
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"reflect"
+)
+
+// plainHashDomain separates plaintext hash keys from blind index keys
+const plainHashDomain = "govault plaintext hash"
+
+// PlainHash computes the keyed hash of plaintext for the named field of
+// model, stored in the column named by its plainhash tag:
+//
+//	Email     string `bun:"email" encrypted:"true" plainhash:"email_hash"`
+//	EmailHash string `bun:"email_hash,unique"`
+//
+// Unlike a blind index it is never truncated, so equal hashes mean equal
+// plaintexts: it detects no-op updates, backs unique constraints on the
+// encrypted column and groups duplicates. It is keyed by a subkey of the
+// blind index key bound to the table and column.
+func (g *GovaultDB) PlainHash(model any, fieldName, plaintext string) (string, error) {
+	spec := g.ModelSpec(model)
+	if spec == nil {
+		return "", fmt.Errorf("model must be a struct, got %T", model)
+	}
+	field := spec.Field(fieldName)
+	if field == nil {
+		return "", fmt.Errorf("field %s is not encrypted", fieldName)
+	}
+	plaintext, err := NormalizeField(field, plaintext)
+	if err != nil {
+		return "", err
+	}
+	return g.plainHash(field, spec.Table, plaintext)
+}
+
+func (g *GovaultDB) plainHash(field *FieldSpec, table, plaintext string) (string, error) {
+	subKey, err := g.blindIndexSubKey(plainHashDomain, table, field.Column)
+	if err != nil {
+		return "", err
+	}
+
+	mac := hmac.New(sha256.New, subKey)
+	mac.Write([]byte(plaintext))
+	return hex.EncodeToString(mac.Sum(nil)), nil
+}
+
+// setPlainHash writes the plaintext hash into the companion column
+func (g *GovaultDB) setPlainHash(val reflect.Value, spec *ModelSpec, field *FieldSpec, plaintext string) error {
+	target, err := companionField(val, spec, field.PlainHash, "plaintext hash")
+	if err != nil {
+		return err
+	}
+	hash, err := g.plainHash(field, spec.Table, plaintext)
+	if err != nil {
+		return err
+	}
+	target.SetString(hash)
+	return nil
+}
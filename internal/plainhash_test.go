@@ -0,0 +1,46 @@
+package internal_test
+
+import (
+	"testing"
+
+	"github.com/muhammadluth/govault/internal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPlainHash(t *testing.T) {
+	type account struct {
+		Email       string `bun:"email" encrypted:"true" plainhash:"email_hash" blindindex:"email_bidx"`
+		EmailHash   string `bun:"email_hash"`
+		EmailBidx   string `bun:"email_bidx"`
+		Backup      string `bun:"backup" encrypted:"true" plainhash:"backup_hash"`
+		BackupHash  string `bun:"backup_hash"`
+		Recovery    string `bun:"recovery" encrypted:"true" plainhash:"recovery_hash"`
+		Unsupported string `bun:"unsupported"`
+	}
+
+	g, err := internal.New(internal.Config{
+		Keys:         map[string][]byte{"1": reloadKey1},
+		DefaultKeyID: "1",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "email_hash", internal.GetModelSpec((*account)(nil)).Field("Email").PlainHash)
+
+	a := &account{Email: "john@example.com", Backup: "john@example.com"}
+	require.NoError(t, g.EncryptModel(a, ""))
+	assert.Len(t, a.EmailHash, 64, "plaintext hashes are not truncated")
+	assert.NotEqual(t, a.EmailBidx, a.EmailHash[:len(a.EmailBidx)], "hashes are keyed apart from blind indexes")
+	assert.NotEqual(t, a.EmailHash, a.BackupHash, "hashes are bound to the column")
+
+	hash, err := g.PlainHash((*account)(nil), "Email", "john@example.com")
+	require.NoError(t, err)
+	assert.Equal(t, a.EmailHash, hash)
+	other, err := g.PlainHash((*account)(nil), "Email", "jane@example.com")
+	require.NoError(t, err)
+	assert.NotEqual(t, hash, other)
+
+	_, err = g.PlainHash((*account)(nil), "Unsupported", "x")
+	assert.ErrorContains(t, err, "not encrypted")
+	err = g.EncryptModel(&account{Recovery: "x"}, "")
+	assert.ErrorContains(t, err, "plaintext hash column 'recovery_hash' not found in model")
+}
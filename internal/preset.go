@@ -0,0 +1,217 @@
+package internal
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+	"sync"
+)
+
+// Preset bundles the recommended settings of a class of values, applied
+// to a field with a single preset tag:
+//
+//	SSN     string `bun:"ssn" encrypted:"true" preset:"ssn"`
+//	SSNBidx string `bun:"ssn_bidx"`
+//
+// Settings of the preset fill those the tags, or Config.Fields, leave
+// unset. Presets never make a field deterministic: lookups go through the
+// blind index, the <column>_bidx column when the model has one and no
+// blindindex tag names another.
+type Preset struct {
+	// Normalizers run before encryption, see Normalizer
+	Normalizers []string
+	// BlindIndex enables the blind index of the field
+	BlindIndex bool
+	// BlindIndexBits truncates the blind index. Values of few possible
+	// plaintexts, like national IDs, get short indexes that many rows
+	// share, so a stolen index table does not single values out.
+	BlindIndexBits int
+	// PadBuckets pads plaintexts whose size would tell values apart
+	PadBuckets []int
+	// Mask is the transform read by roles without one of their own, see
+	// TransformAnyRole
+	Mask string
+}
+
+// Built-in presets
+const (
+	// PresetSSN holds a US Social Security number, stored as its nine
+	// digits
+	PresetSSN = "ssn"
+	// PresetNIK holds an Indonesian national identity number (Nomor Induk
+	// Kependudukan), stored as its sixteen digits
+	PresetNIK = "nik"
+	// PresetIBAN holds an International Bank Account Number, stored
+	// without spaces and uppercased
+	PresetIBAN = "iban"
+)
+
+// Normalizers of the built-in presets, rejecting values that are not one
+const (
+	NormalizeSSN  = "ssn"
+	NormalizeNIK  = "nik"
+	NormalizeIBAN = "iban"
+)
+
+// maxIBANLength is the size of the longest IBAN, padded to so the length
+// of an IBAN does not tell its country
+const maxIBANLength = 34
+
+var (
+	presetsMu sync.RWMutex
+	presets   = map[string]Preset{
+		PresetSSN: {
+			Normalizers:    []string{NormalizeSSN},
+			BlindIndex:     true,
+			BlindIndexBits: 16,
+			Mask:           TransformLast4,
+		},
+		PresetNIK: {
+			Normalizers:    []string{NormalizeNIK},
+			BlindIndex:     true,
+			BlindIndexBits: 16,
+			Mask:           TransformLast4,
+		},
+		PresetIBAN: {
+			Normalizers:    []string{NormalizeIBAN},
+			BlindIndex:     true,
+			BlindIndexBits: 24,
+			PadBuckets:     []int{maxIBANLength},
+			Mask:           TransformLast4,
+		},
+	}
+)
+
+// RegisterPreset makes p available to preset tags under name, replacing
+// any preset registered before. Cached specs are dropped so the preset
+// applies to them too.
+func RegisterPreset(name string, p Preset) {
+	presetsMu.Lock()
+	defer presetsMu.Unlock()
+	presets[name] = p
+	invalidateSpecs()
+}
+
+// lookupPreset returns the preset registered under name
+func lookupPreset(name string) (Preset, error) {
+	presetsMu.RLock()
+	defer presetsMu.RUnlock()
+	p, ok := presets[name]
+	if !ok {
+		return Preset{}, fmt.Errorf("unknown preset '%s'", name)
+	}
+	return p, nil
+}
+
+// applyPreset fills the settings of field left unset with those of its
+// preset. It returns whether the preset enables the blind index. Unknown
+// presets are reported by NormalizeField, on every write of the field.
+// Fields with a codec don't take the padding, which codecs can't carry.
+func applyPreset(field *FieldSpec) bool {
+	if field.Preset == "" {
+		return false
+	}
+	p, err := lookupPreset(field.Preset)
+	if err != nil {
+		return false
+	}
+	if len(field.Normalizers) == 0 {
+		field.Normalizers = p.Normalizers
+	}
+	if field.BlindIndexBits == 0 {
+		field.BlindIndexBits = p.BlindIndexBits
+	}
+	if len(field.PadBuckets) == 0 && field.Codec == "" {
+		field.PadBuckets = p.PadBuckets
+	}
+	if _, ok := field.Transforms[TransformAnyRole]; !ok && p.Mask != "" {
+		if field.Transforms == nil {
+			field.Transforms = make(map[string]string)
+		}
+		field.Transforms[TransformAnyRole] = p.Mask
+	}
+	return p.BlindIndex
+}
+
+// digitsOf returns the digits of value, dropping spaces, dashes and dots.
+// It fails on any other character.
+func digitsOf(value, kind string) (string, error) {
+	var digits strings.Builder
+	for _, r := range strings.TrimSpace(value) {
+		switch {
+		case r >= '0' && r <= '9':
+			digits.WriteRune(r)
+		case r == ' ' || r == '-' || r == '.':
+		default:
+			return "", fmt.Errorf("value is not %s", kind)
+		}
+	}
+	return digits.String(), nil
+}
+
+// normalizeSSN is the NormalizeSSN normalizer. Area numbers 000, 666 and
+// 900 to 999, group 00 and serial 0000 are never issued.
+func normalizeSSN(value string) (string, error) {
+	ssn, err := digitsOf(value, "a social security number")
+	if err != nil {
+		return "", err
+	}
+	if len(ssn) != 9 || ssn[:3] == "000" || ssn[:3] == "666" || ssn[0] == '9' ||
+		ssn[3:5] == "00" || ssn[5:] == "0000" {
+		return "", fmt.Errorf("value is not a social security number")
+	}
+	return ssn, nil
+}
+
+// normalizeNIK is the NormalizeNIK normalizer. Digits 7 to 12 hold the
+// birth date as DDMMYY, with 40 added to the day for women.
+func normalizeNIK(value string) (string, error) {
+	nik, err := digitsOf(value, "a national identity number")
+	if err != nil {
+		return "", err
+	}
+	if len(nik) != 16 || nik[:2] == "00" {
+		return "", fmt.Errorf("value is not a national identity number")
+	}
+	day := int(nik[6]-'0')*10 + int(nik[7]-'0')
+	month := int(nik[8]-'0')*10 + int(nik[9]-'0')
+	if day > 40 {
+		day -= 40
+	}
+	if day < 1 || day > 31 || month < 1 || month > 12 {
+		return "", fmt.Errorf("value is not a national identity number")
+	}
+	return nik, nil
+}
+
+// normalizeIBAN is the NormalizeIBAN normalizer, checking the ISO 7064
+// mod 97 check digits
+func normalizeIBAN(value string) (string, error) {
+	iban := strings.ToUpper(strings.Join(strings.Fields(value), ""))
+	if len(iban) < 15 || len(iban) > maxIBANLength ||
+		!isUpperLetter(iban[0]) || !isUpperLetter(iban[1]) || !isDigit(iban[2]) || !isDigit(iban[3]) {
+		return "", fmt.Errorf("value is not an IBAN")
+	}
+
+	// The country code and check digits move to the end, and letters
+	// count as 10 to 35
+	var number strings.Builder
+	for _, c := range []byte(iban[4:] + iban[:4]) {
+		switch {
+		case isDigit(c):
+			number.WriteByte(c)
+		case isUpperLetter(c):
+			fmt.Fprint(&number, int(c-'A')+10)
+		default:
+			return "", fmt.Errorf("value is not an IBAN")
+		}
+	}
+	n, _ := new(big.Int).SetString(number.String(), 10)
+	if new(big.Int).Mod(n, big.NewInt(97)).Int64() != 1 {
+		return "", fmt.Errorf("value is not an IBAN")
+	}
+	return iban, nil
+}
+
+func isDigit(c byte) bool       { return c >= '0' && c <= '9' }
+func isUpperLetter(c byte) bool { return c >= 'A' && c <= 'Z' }
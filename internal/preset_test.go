@@ -0,0 +1,120 @@
+package internal_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/muhammadluth/govault/internal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type citizen struct {
+	ID       int64  `bun:"id,pk"`
+	SSN      string `bun:"ssn" encrypted:"true" preset:"ssn"`
+	SSNBidx  string `bun:"ssn_bidx"`
+	NIK      string `bun:"nik" encrypted:"true" preset:"nik" blindindex:"nik_index" blindindexbits:"20"`
+	NIKIndex string `bun:"nik_index"`
+	IBAN     string `bun:"iban" encrypted:"true" preset:"iban" transform:"audit=redact"`
+}
+
+func TestPresetSettings(t *testing.T) {
+	spec := internal.GetModelSpec(&citizen{})
+
+	ssn := spec.Field("SSN")
+	assert.Equal(t, []string{internal.NormalizeSSN}, ssn.Normalizers)
+	assert.Equal(t, "ssn_bidx", ssn.BlindIndex, "the <column>_bidx column of the model")
+	assert.Equal(t, 16, ssn.BlindIndexBits)
+	assert.False(t, ssn.Deterministic)
+
+	nik := spec.Field("NIK")
+	assert.Equal(t, "nik_index", nik.BlindIndex, "tags win over the preset")
+	assert.Equal(t, 20, nik.BlindIndexBits)
+
+	iban := spec.Field("IBAN")
+	assert.Empty(t, iban.BlindIndex, "the model has no iban_bidx column")
+	assert.Equal(t, []int{34}, iban.PadBuckets)
+	assert.Equal(t, map[string]string{"audit": internal.TransformRedact, internal.TransformAnyRole: internal.TransformLast4}, iban.Transforms)
+}
+
+func TestPresetNormalizers(t *testing.T) {
+	tests := []struct {
+		preset, value, want string
+	}{
+		{internal.PresetSSN, "123-45-6789", "123456789"},
+		{internal.PresetSSN, "666-45-6789", ""},
+		{internal.PresetSSN, "123-00-6789", ""},
+		{internal.PresetSSN, "12345678", ""},
+		{internal.PresetNIK, "3201.0145.0190.0001", "3201014501900001"},
+		{internal.PresetNIK, "3201013201900001", ""},
+		{internal.PresetNIK, "320101450190000", ""},
+		{internal.PresetIBAN, "gb82 west 1234 5698 7654 32", "GB82WEST12345698765432"},
+		{internal.PresetIBAN, "DE89370400440532013000", "DE89370400440532013000"},
+		{internal.PresetIBAN, "DE88370400440532013000", ""},
+	}
+	for _, tt := range tests {
+		field := &internal.FieldSpec{Name: "ID", Preset: tt.preset, Normalizers: []string{tt.preset}}
+		got, err := internal.NormalizeField(field, tt.value)
+		if tt.want == "" {
+			assert.Error(t, err, tt.value)
+			continue
+		}
+		require.NoError(t, err, tt.value)
+		assert.Equal(t, tt.want, got)
+	}
+}
+
+func TestPresets(t *testing.T) {
+	g, err := internal.New(internal.Config{
+		Keys:         map[string][]byte{"1": []byte("727d37a0-a5f2-4d67-af47-83039c8e")},
+		DefaultKeyID: "1",
+	})
+	require.NoError(t, err)
+
+	t.Run("writes and masked reads", func(t *testing.T) {
+		c := &citizen{SSN: "123-45-6789", NIK: "3201014501900001", IBAN: "GB82 WEST 1234 5698 7654 32"}
+		require.NoError(t, g.EncryptModel(c, ""))
+		assert.Len(t, c.SSNBidx, 4, "16 bits")
+		assert.Len(t, c.NIKIndex, 6, "20 bits")
+
+		index, err := g.BlindIndex(c, "SSN", "123 45 6789")
+		require.NoError(t, err)
+		assert.Equal(t, c.SSNBidx, index)
+
+		masked := *c
+		require.NoError(t, g.DecryptRecursiveContext(internal.WithTransformRole(context.Background(), "support"), &masked))
+		assert.Equal(t, "*****6789", masked.SSN)
+		assert.Equal(t, "************0001", masked.NIK)
+
+		audit := *c
+		require.NoError(t, g.DecryptRecursiveContext(internal.WithTransformRole(context.Background(), "audit"), &audit))
+		assert.Equal(t, internal.FallbackMaskValue, audit.IBAN)
+
+		require.NoError(t, g.DecryptRecursive(c))
+		assert.Equal(t, "123456789", c.SSN)
+		assert.Equal(t, "GB82WEST12345698765432", c.IBAN)
+	})
+
+	t.Run("invalid values are rejected", func(t *testing.T) {
+		err := g.EncryptModel(&citizen{SSN: "000-12-3456"}, "")
+		assert.ErrorContains(t, err, "not a social security number")
+	})
+
+	t.Run("unknown presets fail writes", func(t *testing.T) {
+		field := &internal.FieldSpec{Name: "Passport", Column: "passport", Preset: "visa"}
+		_, err := internal.NormalizeField(field, "X1234567")
+		assert.ErrorContains(t, err, "unknown preset 'visa'")
+	})
+
+	t.Run("registered presets", func(t *testing.T) {
+		type traveler struct {
+			Passport string `bun:"passport" encrypted:"true" preset:"passport"`
+		}
+		internal.RegisterPreset("passport", internal.Preset{Normalizers: []string{internal.NormalizeTrim}, Mask: internal.TransformRedact})
+
+		p := &traveler{Passport: " X1234567 "}
+		require.NoError(t, g.EncryptModel(p, ""))
+		require.NoError(t, g.DecryptRecursiveContext(internal.WithTransformRole(context.Background(), "support"), p))
+		assert.Equal(t, internal.FallbackMaskValue, p.Passport)
+	})
+}
@@ -0,0 +1,258 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ErrKeyUnavailable is wrapped by errors of provider keys that could not be
+// unwrapped, e.g. because the KMS timed out or its circuit is open
+var ErrKeyUnavailable = errors.New("key unavailable")
+
+// Fallback decides what reads return when a provider key is unavailable
+type Fallback string
+
+const (
+	// FallbackFailClosed returns the error; this is the default
+	FallbackFailClosed Fallback = ""
+	// FallbackCiphertext leaves the stored ciphertext in the field
+	FallbackCiphertext Fallback = "ciphertext"
+	// FallbackMask replaces the field with FallbackMaskValue
+	FallbackMask Fallback = "mask"
+)
+
+// FallbackMaskValue is returned for fields read with FallbackMask
+const FallbackMaskValue = "****"
+
+// defaultBreakerCooldown is used when the breaker has no cooldown set
+const defaultBreakerCooldown = 30 * time.Second
+
+// KeyProvider unwraps data keys with a key held in an external KMS
+type KeyProvider interface {
+	// Unwrap decrypts wrapped with the KMS key keyURI of region
+	Unwrap(ctx context.Context, region, keyURI string, wrapped []byte) ([]byte, error)
+}
+
+// KeyProviderFunc adapts a function to a KeyProvider
+type KeyProviderFunc func(ctx context.Context, region, keyURI string, wrapped []byte) ([]byte, error)
+
+// Unwrap calls f
+func (f KeyProviderFunc) Unwrap(ctx context.Context, region, keyURI string, wrapped []byte) ([]byte, error) {
+	return f(ctx, region, keyURI, wrapped)
+}
+
+// TinkAEADProvider returns a provider unwrapping with the AEAD registered
+// for each key URI, e.g. from the GetAEAD method of a Tink KMS client
+func TinkAEADProvider(aeads map[string]TinkAEAD) KeyProvider {
+	return KeyProviderFunc(func(ctx context.Context, region, keyURI string, wrapped []byte) ([]byte, error) {
+		aead, exists := aeads[keyURI]
+		if !exists {
+			return nil, fmt.Errorf("no AEAD for key URI '%s'", keyURI)
+		}
+		return aead.Decrypt(wrapped, nil)
+	})
+}
+
+// ProviderKey is a data key wrapped by a KMS key replicated across regions,
+// e.g. an AWS multi-region key. It is unwrapped on first use in the local
+// region, failing over to the other regions.
+type ProviderKey struct {
+	// Wrapped is the data key encrypted by the KMS key
+	Wrapped []byte
+	// KeyURIs maps each region to the KMS key of that region
+	KeyURIs map[string]string
+	// RegionWrapped overrides Wrapped for regions whose KMS key is not a
+	// replica and wrapped the data key separately
+	RegionWrapped map[string][]byte
+}
+
+// guardedProvider applies the timeout and per-region circuit breakers to
+// every call of a KeyProvider
+type guardedProvider struct {
+	provider  KeyProvider
+	timeout   time.Duration
+	threshold int
+	cooldown  time.Duration
+
+	mu       sync.Mutex
+	breakers map[string]*circuitBreaker
+}
+
+// circuitBreaker counts consecutive failures of one region
+type circuitBreaker struct {
+	failures  int
+	openUntil time.Time
+}
+
+func newGuardedProvider(config Config) *guardedProvider {
+	cooldown := config.ProviderBreakerCooldown
+	if cooldown <= 0 {
+		cooldown = defaultBreakerCooldown
+	}
+	return &guardedProvider{
+		provider:  config.KeyProvider,
+		timeout:   config.ProviderTimeout,
+		threshold: config.ProviderBreakerThreshold,
+		cooldown:  cooldown,
+		breakers:  make(map[string]*circuitBreaker),
+	}
+}
+
+// unwrap calls the provider unless the circuit of region is open. Calls
+// exceeding the timeout or outliving ctx are abandoned even if the provider
+// ignores ctx. Calls abandoned because ctx ended do not count against the
+// circuit, the region did nothing wrong.
+func (p *guardedProvider) unwrap(ctx context.Context, region, keyURI string, wrapped []byte) ([]byte, error) {
+	if err := p.allow(region); err != nil {
+		return nil, err
+	}
+
+	caller := ctx
+	if p.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.timeout)
+		defer cancel()
+	}
+
+	type result struct {
+		value []byte
+		err   error
+	}
+	done := make(chan result, 1)
+	go func() {
+		value, err := p.provider.Unwrap(ctx, region, keyURI, wrapped)
+		done <- result{value, err}
+	}()
+
+	var res result
+	select {
+	case res = <-done:
+	case <-ctx.Done():
+		res.err = ctx.Err()
+	}
+
+	if res.err == nil || caller.Err() == nil {
+		p.record(region, res.err)
+	}
+	return res.value, res.err
+}
+
+// allow fails while the circuit of region is open
+func (p *guardedProvider) allow(region string) error {
+	if p.threshold <= 0 {
+		return nil
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if b := p.breakers[region]; b != nil && time.Now().Before(b.openUntil) {
+		return fmt.Errorf("circuit open until %s", b.openUntil.Format(time.RFC3339))
+	}
+	return nil
+}
+
+// record updates the circuit of region with the outcome of a call. A
+// failure after the cooldown, while half-open, reopens it immediately.
+func (p *guardedProvider) record(region string, err error) {
+	if p.threshold <= 0 {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	b := p.breakers[region]
+	if b == nil {
+		b = &circuitBreaker{}
+		p.breakers[region] = b
+	}
+	if err == nil {
+		b.failures = 0
+		return
+	}
+	b.failures++
+	if b.failures >= p.threshold {
+		b.openUntil = time.Now().Add(p.cooldown)
+	}
+}
+
+// wrappedKey unwraps a provider key once and caches the result
+type wrappedKey struct {
+	provider *guardedProvider
+	spec     ProviderKey
+	regions  []string
+
+	lock  chan struct{} // held while unwrapping, waited on with ctx
+	value []byte
+}
+
+// newWrappedKey orders the regions of key by preference: config.Region,
+// then config.FailoverRegions, then the rest alphabetically
+func newWrappedKey(config Config, key ProviderKey, provider *guardedProvider) (*wrappedKey, error) {
+	if len(key.KeyURIs) == 0 {
+		return nil, fmt.Errorf("at least one key URI is required")
+	}
+
+	seen := make(map[string]bool)
+	var regions []string
+	for _, region := range append([]string{config.Region}, config.FailoverRegions...) {
+		if _, exists := key.KeyURIs[region]; exists && !seen[region] {
+			seen[region] = true
+			regions = append(regions, region)
+		}
+	}
+
+	var rest []string
+	for region := range key.KeyURIs {
+		if !seen[region] {
+			rest = append(rest, region)
+		}
+	}
+	sort.Strings(rest)
+
+	return &wrappedKey{
+		provider: provider,
+		spec:     key,
+		regions:  append(regions, rest...),
+		lock:     make(chan struct{}, 1),
+	}, nil
+}
+
+// unwrap returns the data key, trying each region in order until one
+// succeeds or ctx ends. Concurrent callers wait for the first one, as long
+// as their own ctx allows.
+func (w *wrappedKey) unwrap(ctx context.Context, keyID string) ([]byte, error) {
+	select {
+	case w.lock <- struct{}{}:
+		defer func() { <-w.lock }()
+	case <-ctx.Done():
+		return nil, fmt.Errorf("failed to unwrap key '%s': %w", keyID, ctx.Err())
+	}
+
+	if w.value != nil {
+		return w.value, nil
+	}
+
+	var errs []error
+	for _, region := range w.regions {
+		if err := ctx.Err(); err != nil {
+			// Later regions would fail the same way
+			return nil, fmt.Errorf("failed to unwrap key '%s': %w", keyID, errors.Join(append(errs, err)...))
+		}
+		wrapped := w.spec.Wrapped
+		if regionWrapped, exists := w.spec.RegionWrapped[region]; exists {
+			wrapped = regionWrapped
+		}
+
+		value, err := w.provider.unwrap(ctx, region, w.spec.KeyURIs[region], wrapped)
+		if err == nil {
+			w.value = value
+			return value, nil
+		}
+		errs = append(errs, fmt.Errorf("region %s: %w", region, err))
+	}
+	return nil, fmt.Errorf("failed to unwrap key '%s': %w: %w", keyID, ErrKeyUnavailable, errors.Join(errs...))
+}
@@ -0,0 +1,386 @@
+package internal_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/muhammadluth/govault/internal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeKMS unwraps by returning wrapped as-is, failing for down regions
+type fakeKMS struct {
+	mu    sync.Mutex
+	down  map[string]bool
+	calls []string
+}
+
+func (k *fakeKMS) Unwrap(ctx context.Context, region, keyURI string, wrapped []byte) ([]byte, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.calls = append(k.calls, region+" "+keyURI)
+	if k.down[region] {
+		return nil, errors.New("region unavailable")
+	}
+	return wrapped, nil
+}
+
+func newProviderVault(t *testing.T, kms *fakeKMS, region string) *internal.GovaultDB {
+	t.Helper()
+	g, err := internal.New(internal.Config{
+		KeyProvider: kms,
+		ProviderKeys: map[string]internal.ProviderKey{
+			"kms": {
+				Wrapped: []byte("727d37a0-a5f2-4d67-af47-83039c8e"),
+				KeyURIs: map[string]string{
+					"us-east-1":      "arn:aws:kms:us-east-1:1:key/mrk-1",
+					"eu-west-1":      "arn:aws:kms:eu-west-1:1:key/mrk-1",
+					"ap-southeast-1": "arn:aws:kms:ap-southeast-1:1:key/mrk-1",
+				},
+			},
+		},
+		DefaultKeyID:    "kms",
+		Region:          region,
+		FailoverRegions: []string{"us-east-1"},
+	})
+	require.NoError(t, err)
+	return g
+}
+
+func TestProviderKeys(t *testing.T) {
+	t.Run("unwraps in the local region once", func(t *testing.T) {
+		kms := &fakeKMS{}
+		g := newProviderVault(t, kms, "eu-west-1")
+		assert.Empty(t, kms.calls)
+
+		ciphertext, err := g.Encrypt("secret")
+		require.NoError(t, err)
+		plaintext, err := g.Decrypt(ciphertext)
+		require.NoError(t, err)
+		assert.Equal(t, "secret", plaintext)
+
+		assert.Equal(t, []string{"eu-west-1 arn:aws:kms:eu-west-1:1:key/mrk-1"}, kms.calls)
+	})
+
+	t.Run("fails over in order", func(t *testing.T) {
+		kms := &fakeKMS{down: map[string]bool{"eu-west-1": true, "us-east-1": true}}
+		g := newProviderVault(t, kms, "eu-west-1")
+
+		_, err := g.Encrypt("secret")
+		require.NoError(t, err)
+		assert.Equal(t, []string{
+			"eu-west-1 arn:aws:kms:eu-west-1:1:key/mrk-1",
+			"us-east-1 arn:aws:kms:us-east-1:1:key/mrk-1",
+			"ap-southeast-1 arn:aws:kms:ap-southeast-1:1:key/mrk-1",
+		}, kms.calls)
+	})
+
+	t.Run("all regions down", func(t *testing.T) {
+		kms := &fakeKMS{down: map[string]bool{"eu-west-1": true, "us-east-1": true, "ap-southeast-1": true}}
+		g := newProviderVault(t, kms, "eu-west-1")
+
+		_, err := g.Encrypt("secret")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "region ap-southeast-1")
+
+		// A later call retries once a region is back
+		kms.down["us-east-1"] = false
+		_, err = g.Encrypt("secret")
+		assert.NoError(t, err)
+	})
+
+	t.Run("region specific wrapped key", func(t *testing.T) {
+		kms := &fakeKMS{}
+		g, err := internal.New(internal.Config{
+			KeyProvider: kms,
+			ProviderKeys: map[string]internal.ProviderKey{
+				"kms": {
+					Wrapped:       []byte("short"),
+					KeyURIs:       map[string]string{"eu-west-1": "eu-key", "us-east-1": "us-key"},
+					RegionWrapped: map[string][]byte{"eu-west-1": []byte("e778dc27-9b04-44c3-a862-feba061c")},
+				},
+			},
+			DefaultKeyID: "kms",
+			Region:       "eu-west-1",
+		})
+		require.NoError(t, err)
+
+		_, err = g.Encrypt("secret")
+		assert.NoError(t, err)
+	})
+
+	t.Run("config validation", func(t *testing.T) {
+		_, err := internal.New(internal.Config{
+			ProviderKeys: map[string]internal.ProviderKey{"kms": {KeyURIs: map[string]string{"a": "b"}}},
+			DefaultKeyID: "kms",
+		})
+		assert.Error(t, err)
+
+		_, err = internal.New(internal.Config{
+			KeyProvider:  &fakeKMS{},
+			ProviderKeys: map[string]internal.ProviderKey{"kms": {}},
+			DefaultKeyID: "kms",
+		})
+		assert.Error(t, err)
+	})
+}
+
+func TestProviderTimeoutAndBreaker(t *testing.T) {
+	var mu sync.Mutex
+	calls := map[string]int{}
+	slow := internal.KeyProviderFunc(func(ctx context.Context, region, keyURI string, wrapped []byte) ([]byte, error) {
+		mu.Lock()
+		calls[region]++
+		mu.Unlock()
+		if region == "eu-west-1" {
+			// Ignores ctx, like a stuck client
+			time.Sleep(time.Second)
+		}
+		return wrapped, nil
+	})
+
+	newVault := func(t *testing.T) *internal.GovaultDB {
+		g, err := internal.New(internal.Config{
+			KeyProvider: slow,
+			ProviderKeys: map[string]internal.ProviderKey{
+				"a": {Wrapped: []byte("727d37a0-a5f2-4d67-af47-83039c8e"), KeyURIs: map[string]string{"eu-west-1": "eu", "us-east-1": "us"}},
+				"b": {Wrapped: []byte("e778dc27-9b04-44c3-a862-feba061c"), KeyURIs: map[string]string{"eu-west-1": "eu", "us-east-1": "us"}},
+			},
+			DefaultKeyID:             "a",
+			Region:                   "eu-west-1",
+			ProviderTimeout:          20 * time.Millisecond,
+			ProviderBreakerThreshold: 1,
+			ProviderBreakerCooldown:  time.Minute,
+		})
+		require.NoError(t, err)
+		return g
+	}
+
+	g := newVault(t)
+	start := time.Now()
+	_, err := g.Encrypt("secret")
+	require.NoError(t, err)
+	assert.Less(t, time.Since(start), 500*time.Millisecond)
+
+	// The open circuit skips eu-west-1 for the second key
+	_, err = g.Encrypt("secret", "b")
+	require.NoError(t, err)
+	mu.Lock()
+	assert.Equal(t, map[string]int{"eu-west-1": 1, "us-east-1": 2}, calls)
+	mu.Unlock()
+}
+
+func TestProviderFallback(t *testing.T) {
+	keyBytes := []byte("727d37a0-a5f2-4d67-af47-83039c8e")
+	writer, err := internal.New(internal.Config{Keys: map[string][]byte{"kms": keyBytes}, DefaultKeyID: "kms"})
+	require.NoError(t, err)
+	ciphertext, err := writer.Encrypt("secret@example.com")
+	require.NoError(t, err)
+
+	down := internal.KeyProviderFunc(func(ctx context.Context, region, keyURI string, wrapped []byte) ([]byte, error) {
+		return nil, errors.New("kms unavailable")
+	})
+	newReader := func(t *testing.T, fallback internal.Fallback) *internal.GovaultDB {
+		g, err := internal.New(internal.Config{
+			KeyProvider:  down,
+			ProviderKeys: map[string]internal.ProviderKey{"kms": {Wrapped: keyBytes, KeyURIs: map[string]string{"eu-west-1": "eu"}}},
+			DefaultKeyID: "kms",
+			Fallback:     fallback,
+		})
+		require.NoError(t, err)
+		return g
+	}
+
+	type row struct {
+		Email string `encrypted:"true"`
+	}
+
+	t.Run("fail closed", func(t *testing.T) {
+		r := &row{Email: ciphertext}
+		err := newReader(t, internal.FallbackFailClosed).DecryptRecursive(r)
+		require.Error(t, err)
+		assert.ErrorIs(t, err, internal.ErrKeyUnavailable)
+	})
+
+	t.Run("ciphertext", func(t *testing.T) {
+		g := newReader(t, internal.FallbackCiphertext)
+		r := &row{Email: ciphertext}
+		require.NoError(t, g.DecryptRecursive(r))
+		assert.Equal(t, ciphertext, r.Email)
+
+		plaintext, err := g.Decrypt(ciphertext)
+		require.NoError(t, err)
+		assert.Equal(t, ciphertext, plaintext)
+		plaintext, err = g.DecryptContext(context.Background(), ciphertext)
+		require.NoError(t, err)
+		assert.Equal(t, ciphertext, plaintext)
+	})
+
+	t.Run("mask", func(t *testing.T) {
+		g := newReader(t, internal.FallbackMask)
+		r := &row{Email: ciphertext}
+		require.NoError(t, g.DecryptRecursive(r))
+		assert.Equal(t, internal.FallbackMaskValue, r.Email)
+
+		plaintext, err := g.Decrypt(ciphertext)
+		require.NoError(t, err)
+		assert.Equal(t, internal.FallbackMaskValue, plaintext)
+
+		// Writes never fall back
+		_, err = g.Encrypt("secret")
+		assert.ErrorIs(t, err, internal.ErrKeyUnavailable)
+		_, err = g.DecryptStrict(ciphertext)
+		assert.ErrorIs(t, err, internal.ErrKeyUnavailable)
+		_, _, err = g.DecryptFieldStrict(nil, "", ciphertext)
+		assert.ErrorIs(t, err, internal.ErrKeyUnavailable)
+	})
+
+	t.Run("unknown fallback", func(t *testing.T) {
+		_, err := internal.New(internal.Config{Keys: map[string][]byte{"kms": keyBytes}, DefaultKeyID: "kms", Fallback: "plaintext"})
+		assert.Error(t, err)
+	})
+}
+
+func TestProviderChain(t *testing.T) {
+	dataKey := []byte("727d37a0-a5f2-4d67-af47-83039c8e")
+	keys := map[string]internal.ProviderKey{
+		"kms": {
+			Wrapped: []byte("e778dc27-9b04-44c3-a862-feba061c"),
+			KeyURIs: map[string]string{"eu-west-1": "arn:aws:kms:eu-west-1:1:key/mrk-1"},
+		},
+	}
+	vault := func(provider internal.KeyProvider) *internal.GovaultDB {
+		g, err := internal.New(internal.Config{KeyProvider: provider, ProviderKeys: keys, DefaultKeyID: "kms", Region: "eu-west-1"})
+		require.NoError(t, err)
+		return g
+	}
+
+	t.Run("static keys first", func(t *testing.T) {
+		kms := &fakeKMS{}
+		static := internal.StaticKeyProvider(map[string][]byte{"arn:aws:kms:eu-west-1:1:key/mrk-1": dataKey})
+		g := vault(internal.ChainProviders(static, kms))
+
+		_, err := g.Encrypt("secret")
+		require.NoError(t, err)
+		assert.Empty(t, kms.calls)
+	})
+
+	t.Run("falls back in order", func(t *testing.T) {
+		kms := &fakeKMS{}
+		g := vault(internal.ChainProviders(internal.StaticKeyProvider(nil), kms))
+
+		_, err := g.Encrypt("secret")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"eu-west-1 arn:aws:kms:eu-west-1:1:key/mrk-1"}, kms.calls)
+	})
+
+	t.Run("fails with every error", func(t *testing.T) {
+		kms := &fakeKMS{down: map[string]bool{"eu-west-1": true}}
+		g := vault(internal.ChainProviders(internal.StaticKeyProvider(nil), kms))
+
+		_, err := g.Encrypt("secret")
+		assert.ErrorIs(t, err, internal.ErrKeyUnavailable)
+		assert.ErrorContains(t, err, "no static key")
+		assert.ErrorContains(t, err, "region unavailable")
+	})
+
+	t.Run("cache shared between instances", func(t *testing.T) {
+		kms := &fakeKMS{}
+		cached := internal.CacheProvider(kms, 0)
+
+		ciphertext, err := vault(cached).Encrypt("secret")
+		require.NoError(t, err)
+		plaintext, err := vault(cached).Decrypt(ciphertext)
+		require.NoError(t, err)
+		assert.Equal(t, "secret", plaintext)
+		assert.Len(t, kms.calls, 1)
+	})
+
+	t.Run("cache expires", func(t *testing.T) {
+		kms := &fakeKMS{}
+		cached := internal.CacheProvider(kms, time.Millisecond)
+
+		_, err := vault(cached).Encrypt("secret")
+		require.NoError(t, err)
+		time.Sleep(5 * time.Millisecond)
+		_, err = vault(cached).Encrypt("secret")
+		require.NoError(t, err)
+		assert.Len(t, kms.calls, 2)
+	})
+
+	t.Run("failures are not cached", func(t *testing.T) {
+		kms := &fakeKMS{down: map[string]bool{"eu-west-1": true}}
+		cached := internal.CacheProvider(kms, 0)
+
+		_, err := vault(cached).Encrypt("secret")
+		require.Error(t, err)
+		kms.down = nil
+		_, err = vault(cached).Encrypt("secret")
+		require.NoError(t, err)
+	})
+}
+
+func TestProviderContext(t *testing.T) {
+	keyBytes := []byte("727d37a0-a5f2-4d67-af47-83039c8e")
+	writer, err := internal.New(internal.Config{Keys: map[string][]byte{"kms": keyBytes}, DefaultKeyID: "kms"})
+	require.NoError(t, err)
+	ciphertext, err := writer.Encrypt("secret@example.com")
+	require.NoError(t, err)
+
+	var mu sync.Mutex
+	stuck := true
+	var calls []string
+	kms := internal.KeyProviderFunc(func(ctx context.Context, region, keyURI string, wrapped []byte) ([]byte, error) {
+		mu.Lock()
+		calls = append(calls, region)
+		wait := stuck
+		mu.Unlock()
+		if wait {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		}
+		return wrapped, nil
+	})
+	g, err := internal.New(internal.Config{
+		KeyProvider:              kms,
+		ProviderKeys:             map[string]internal.ProviderKey{"kms": {Wrapped: keyBytes, KeyURIs: map[string]string{"eu-west-1": "eu", "us-east-1": "us"}}},
+		DefaultKeyID:             "kms",
+		Region:                   "eu-west-1",
+		ProviderBreakerThreshold: 1,
+		ProviderBreakerCooldown:  time.Minute,
+		Fallback:                 internal.FallbackMask,
+	})
+	require.NoError(t, err)
+
+	type row struct {
+		Email string `encrypted:"true"`
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	err = g.DecryptRecursiveContext(ctx, &row{Email: ciphertext})
+	require.Error(t, err)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.NotErrorIs(t, err, internal.ErrKeyUnavailable, "deadlines of the caller are not masked")
+	mu.Lock()
+	assert.Equal(t, []string{"eu-west-1"}, calls, "regions are not tried after the deadline")
+	stuck = false
+	mu.Unlock()
+
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = g.DecryptContext(canceled, ciphertext)
+	assert.ErrorIs(t, err, context.Canceled)
+
+	// The deadline did not open the circuit of eu-west-1
+	plaintext, err := g.DecryptContext(context.Background(), ciphertext)
+	require.NoError(t, err)
+	assert.Equal(t, "secret@example.com", plaintext)
+	mu.Lock()
+	assert.Equal(t, []string{"eu-west-1", "eu-west-1"}, calls)
+	mu.Unlock()
+}
@@ -0,0 +1,78 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// StaticKeyProvider returns the data key stored for each key URI, ignoring
+// the wrapped key. It lets development use the ProviderKeys of production
+// without a KMS, alone or first in ChainProviders.
+func StaticKeyProvider(keys map[string][]byte) KeyProvider {
+	return KeyProviderFunc(func(ctx context.Context, region, keyURI string, wrapped []byte) ([]byte, error) {
+		key, exists := keys[keyURI]
+		if !exists {
+			return nil, fmt.Errorf("no static key for key URI '%s'", keyURI)
+		}
+		return bytes.Clone(key), nil
+	})
+}
+
+// ChainProviders returns a provider trying providers in order until one
+// unwraps the key, e.g. a static map, then a secrets manager, then a KMS.
+// It fails with the errors of all providers.
+func ChainProviders(providers ...KeyProvider) KeyProvider {
+	return KeyProviderFunc(func(ctx context.Context, region, keyURI string, wrapped []byte) ([]byte, error) {
+		var errs []error
+		for i, provider := range providers {
+			if err := ctx.Err(); err != nil {
+				return nil, errors.Join(append(errs, err)...)
+			}
+			key, err := provider.Unwrap(ctx, region, keyURI, wrapped)
+			if err == nil {
+				return key, nil
+			}
+			errs = append(errs, fmt.Errorf("provider %d: %w", i, err))
+		}
+		if len(errs) == 0 {
+			return nil, fmt.Errorf("no key provider")
+		}
+		return nil, errors.Join(errs...)
+	})
+}
+
+// CacheProvider caches the keys unwrapped by provider for ttl, zero meaning
+// forever; failures are not cached. Each GovaultDB unwraps a key once, so
+// the cache pays off when shared between instances, e.g. of several
+// databases or across config reloads.
+func CacheProvider(provider KeyProvider, ttl time.Duration) KeyProvider {
+	type entry struct {
+		key     []byte
+		expires time.Time
+	}
+	var mu sync.Mutex
+	cache := make(map[string]entry)
+
+	return KeyProviderFunc(func(ctx context.Context, region, keyURI string, wrapped []byte) ([]byte, error) {
+		id := region + "\x00" + keyURI + "\x00" + string(wrapped)
+		mu.Lock()
+		e, exists := cache[id]
+		mu.Unlock()
+		if exists && (ttl <= 0 || time.Now().Before(e.expires)) {
+			return bytes.Clone(e.key), nil
+		}
+
+		key, err := provider.Unwrap(ctx, region, keyURI, wrapped)
+		if err != nil {
+			return nil, err
+		}
+		mu.Lock()
+		cache[id] = entry{key: bytes.Clone(key), expires: time.Now().Add(ttl)}
+		mu.Unlock()
+		return key, nil
+	})
+}
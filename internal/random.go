@@ -0,0 +1,34 @@
+package internal
+
+import (
+	"crypto/rand"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// newNonce returns size random bytes read from Config.RandReader
+func (g *GovaultDB) newNonce(size int) ([]byte, error) {
+	random := g.random
+	if random == nil {
+		random = rand.Reader
+	}
+	nonce := make([]byte, size)
+	if _, err := io.ReadFull(random, nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	return nonce, nil
+}
+
+// lockedReader serializes the reads of a Config.RandReader, which need
+// not be safe for concurrent use
+type lockedReader struct {
+	mu sync.Mutex
+	r  io.Reader
+}
+
+func (l *lockedReader) Read(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.r.Read(p)
+}
@@ -0,0 +1,56 @@
+package internal_test
+
+import (
+	"bytes"
+	"math/rand/v2"
+	"testing"
+
+	"github.com/muhammadluth/govault/internal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRandReader(t *testing.T) {
+	newVault := func(seed byte) *internal.GovaultDB {
+		config := internal.Config{
+			Keys:         map[string][]byte{"1": []byte("727d37a0-a5f2-4d67-af47-83039c8e")},
+			DefaultKeyID: "1",
+		}
+		if seed != 0 {
+			config.RandReader = rand.NewChaCha8([32]byte{seed})
+		}
+		g, err := internal.New(config)
+		require.NoError(t, err)
+		return g
+	}
+	encryptAll := func(g *internal.GovaultDB) []string {
+		var out []string
+		for _, plaintext := range []string{"john@example.com", "john@example.com", "jane@example.com"} {
+			ciphertext, err := g.Encrypt(plaintext)
+			require.NoError(t, err)
+			out = append(out, ciphertext)
+		}
+		return out
+	}
+
+	first := encryptAll(newVault(1))
+	assert.Equal(t, first, encryptAll(newVault(1)), "the same seed replays the same ciphertexts")
+	assert.NotEqual(t, first[0], first[1], "nonces still differ within a run")
+	assert.NotEqual(t, first, encryptAll(newVault(2)))
+	assert.NotEqual(t, encryptAll(newVault(0)), encryptAll(newVault(0)), "crypto/rand by default")
+
+	plaintext, err := newVault(0).Decrypt(first[2])
+	require.NoError(t, err)
+	assert.Equal(t, "jane@example.com", plaintext)
+
+	t.Run("short reads fail", func(t *testing.T) {
+		g, err := internal.New(internal.Config{
+			Keys:         map[string][]byte{"1": []byte("727d37a0-a5f2-4d67-af47-83039c8e")},
+			DefaultKeyID: "1",
+			RandReader:   bytes.NewReader([]byte{1, 2, 3}),
+		})
+		require.NoError(t, err)
+		_, err = g.Encrypt("x")
+		assert.ErrorContains(t, err, "failed to generate nonce")
+	})
+}
@@ -0,0 +1,103 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+// ErrRateLimited is matched by the errors of operations exceeding the rate
+// limit of their key, see RateLimitError
+var ErrRateLimited = errors.New("rate limited")
+
+// RateLimitAllKeys is the Config.RateLimits entry applying to the keys
+// without an entry of their own
+const RateLimitAllKeys = "*"
+
+// RateLimit caps the operations per second of a key, see Config.RateLimits
+type RateLimit struct {
+	PerSecond float64
+	// Burst is the number of operations allowed at once, at least 1
+	Burst int
+}
+
+// RateLimitError is returned by operations exceeding the rate limit of
+// their key; errors.Is(err, ErrRateLimited) reports it
+type RateLimitError struct {
+	KeyID string
+	// RetryAfter is the time until the key allows an operation again
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("key '%s' is rate limited, retry after %s", e.KeyID, e.RetryAfter)
+}
+
+// Is reports whether target is ErrRateLimited
+func (e *RateLimitError) Is(target error) bool {
+	return target == ErrRateLimited
+}
+
+// rateLimiter is a token bucket
+type rateLimiter struct {
+	limit RateLimit
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+func newRateLimiter(limit RateLimit) *rateLimiter {
+	return &rateLimiter{limit: limit, tokens: float64(limit.Burst)}
+}
+
+// take takes a token, or returns the time until one is available
+func (l *rateLimiter) take(now time.Time) (time.Duration, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if !l.last.IsZero() {
+		elapsed := now.Sub(l.last).Seconds()
+		l.tokens = math.Min(float64(l.limit.Burst), l.tokens+elapsed*l.limit.PerSecond)
+	}
+	l.last = now
+	if l.tokens >= 1 {
+		l.tokens--
+		return 0, true
+	}
+	return time.Duration((1 - l.tokens) / l.limit.PerSecond * float64(time.Second)), false
+}
+
+// checkRateLimits validates Config.RateLimits
+func checkRateLimits(limits map[string]RateLimit) error {
+	for keyID, limit := range limits {
+		if limit.PerSecond <= 0 || math.IsInf(limit.PerSecond, 0) || math.IsNaN(limit.PerSecond) {
+			return fmt.Errorf("rate limit of key '%s' must be a positive rate, got %v", keyID, limit.PerSecond)
+		}
+		if limit.Burst < 1 {
+			return fmt.Errorf("rate limit of key '%s' must allow a burst of at least 1, got %d", keyID, limit.Burst)
+		}
+	}
+	return nil
+}
+
+// allowKey counts an encryption or decryption with keyID against its rate
+// limit
+func (g *GovaultDB) allowKey(keyID string) error {
+	limit, ok := g.rateLimits[keyID]
+	if !ok {
+		if limit, ok = g.rateLimits[RateLimitAllKeys]; !ok {
+			return nil
+		}
+	}
+	limiter, ok := g.limiters.Load(keyID)
+	if !ok {
+		limiter, _ = g.limiters.LoadOrStore(keyID, newRateLimiter(limit))
+	}
+	if wait, ok := limiter.(*rateLimiter).take(time.Now()); !ok {
+		return &RateLimitError{KeyID: keyID, RetryAfter: wait}
+	}
+	return nil
+}
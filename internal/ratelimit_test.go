@@ -0,0 +1,78 @@
+package internal_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/muhammadluth/govault/internal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRateLimits(t *testing.T) {
+	g, err := internal.New(internal.Config{
+		Keys:         map[string][]byte{"1": reloadKey1, "2": reloadKey2, "3": reloadKey3},
+		DefaultKeyID: "1",
+		RateLimits: map[string]internal.RateLimit{
+			"1":                       {PerSecond: 0.001, Burst: 3},
+			internal.RateLimitAllKeys: {PerSecond: 0.001, Burst: 1},
+		},
+	})
+	require.NoError(t, err)
+
+	t.Run("operations over the burst fail", func(t *testing.T) {
+		ciphertext, err := g.Encrypt("a")
+		require.NoError(t, err)
+		_, err = g.Decrypt(ciphertext)
+		require.NoError(t, err)
+		_, err = g.Encrypt("b")
+		require.NoError(t, err)
+
+		_, err = g.Decrypt(ciphertext)
+		require.Error(t, err)
+		assert.True(t, errors.Is(err, internal.ErrRateLimited))
+		var limited *internal.RateLimitError
+		require.ErrorAs(t, err, &limited)
+		assert.Equal(t, "1", limited.KeyID)
+		assert.Greater(t, limited.RetryAfter, time.Duration(0))
+	})
+
+	t.Run("keys are limited independently", func(t *testing.T) {
+		_, err := g.Encrypt("c", "2")
+		require.NoError(t, err)
+		_, err = g.Encrypt("c", "2")
+		assert.ErrorIs(t, err, internal.ErrRateLimited)
+
+		_, err = g.Encrypt("c", "3")
+		assert.NoError(t, err, "the default entry applies per key")
+	})
+
+	t.Run("tokens refill over time", func(t *testing.T) {
+		fast, err := internal.New(internal.Config{
+			Keys:         map[string][]byte{"1": reloadKey1},
+			DefaultKeyID: "1",
+			RateLimits:   map[string]internal.RateLimit{"1": {PerSecond: 100, Burst: 1}},
+		})
+		require.NoError(t, err)
+		_, err = fast.Encrypt("a")
+		require.NoError(t, err)
+		_, err = fast.Encrypt("a")
+		var limited *internal.RateLimitError
+		require.ErrorAs(t, err, &limited)
+		time.Sleep(limited.RetryAfter + time.Millisecond)
+		_, err = fast.Encrypt("a")
+		assert.NoError(t, err)
+	})
+
+	t.Run("invalid limits are rejected", func(t *testing.T) {
+		for _, limit := range []internal.RateLimit{{PerSecond: 0, Burst: 1}, {PerSecond: 1, Burst: 0}} {
+			_, err := internal.New(internal.Config{
+				Keys:         map[string][]byte{"1": reloadKey1},
+				DefaultKeyID: "1",
+				RateLimits:   map[string]internal.RateLimit{"1": limit},
+			})
+			assert.ErrorContains(t, err, "rate limit of key '1'")
+		}
+	})
+}
@@ -0,0 +1,67 @@
+package internal
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// SchemaMigration upgrades a Field value of one schema version to the
+// next. value is decoded without a Go type, e.g. a map[string]any for
+// JSON objects; the result is encoded again and decoded into the type.
+type SchemaMigration func(value any) (any, error)
+
+// maxSchemaVersion is the largest version the version byte records
+const maxSchemaVersion = 255
+
+var (
+	schemasMu sync.RWMutex
+	schemas   = make(map[reflect.Type][]SchemaMigration)
+)
+
+// RegisterSchema versions the plaintext of the Field values of type T.
+// migrations[0] upgrades version 1 to 2, migrations[1] version 2 to 3 and
+// so on; the current version, recorded in the values written from now on,
+// is one more than the number of migrations. Values written before T was
+// registered are version 1 and are upgraded on Scan, so stored rows do not
+// need to be rewritten when T changes.
+func RegisterSchema[T any](migrations ...SchemaMigration) {
+	if len(migrations)+1 > maxSchemaVersion {
+		panic(fmt.Sprintf("schema versions exceed %d", maxSchemaVersion))
+	}
+	schemasMu.Lock()
+	defer schemasMu.Unlock()
+	schemas[reflect.TypeFor[T]()] = migrations
+}
+
+// SchemaVersion returns the current schema version of typ, zero when it
+// has no registered schema
+func SchemaVersion(typ reflect.Type) int {
+	schemasMu.RLock()
+	defer schemasMu.RUnlock()
+	migrations, ok := schemas[typ]
+	if !ok {
+		return 0
+	}
+	return len(migrations) + 1
+}
+
+// migrateSchema upgrades data, encoded by s at version, to the current
+// schema version of typ
+func migrateSchema(s Serializer, typ reflect.Type, version int, data []byte) ([]byte, error) {
+	schemasMu.RLock()
+	migrations := schemas[typ]
+	schemasMu.RUnlock()
+
+	var value any
+	if err := s.Unmarshal(data, &value); err != nil {
+		return nil, err
+	}
+	for from := version; from <= len(migrations); from++ {
+		var err error
+		if value, err = migrations[from-1](value); err != nil {
+			return nil, fmt.Errorf("failed to migrate %s from schema version %d: %w", typ, from, err)
+		}
+	}
+	return s.Marshal(value)
+}
@@ -0,0 +1,156 @@
+package internal
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// Serializer encodes the values of struct-valued Field columns before
+// encryption. It is selected per field with the serializer tag or
+// Field.WithSerializer.
+type Serializer interface {
+	Marshal(v any) ([]byte, error)
+	Unmarshal(data []byte, v any) error
+}
+
+// Built-in serializers
+const (
+	// SerializerJSON is the default and is not recorded in the ciphertext,
+	// which keeps values readable by versions without serializers
+	SerializerJSON = "json"
+	// SerializerMsgpack encodes values with MessagePack
+	SerializerMsgpack = "msgpack"
+	// SerializerProto is reserved for protocol buffers. govault does not
+	// depend on protobuf, so applications register it themselves:
+	//
+	//	govault.RegisterSerializer(govault.SerializerProto, protoSerializer{})
+	SerializerProto = "proto"
+)
+
+// serializedMarker starts plaintexts encoded by a serializer other than
+// SerializerJSON: the marker, the serializer ID, the marker again and the
+// encoded value. JSON text never starts with a NUL byte.
+const serializedMarker = "\x00"
+
+// versionedMarker starts plaintexts of types with a registered schema: the
+// marker, the schema version byte, the serializer ID, serializedMarker and
+// the encoded value
+const versionedMarker = "\x01"
+
+var (
+	serializersMu sync.RWMutex
+	serializers   = map[string]Serializer{
+		SerializerJSON:    jsonSerializer{},
+		SerializerMsgpack: msgpackSerializer{},
+	}
+)
+
+// RegisterSerializer makes s available to serializer tags under id,
+// replacing any serializer registered before. The ID is recorded in every
+// value s encodes so that reads keep picking s after the tag changes; it
+// must not be renamed while such values are stored.
+func RegisterSerializer(id string, s Serializer) {
+	if id == "" || strings.Contains(id, serializedMarker) {
+		panic(fmt.Sprintf("invalid serializer ID %q", id))
+	}
+	serializersMu.Lock()
+	defer serializersMu.Unlock()
+	serializers[id] = s
+}
+
+// lookupSerializer returns the serializer registered under id, JSON when
+// empty
+func lookupSerializer(id string) (Serializer, error) {
+	if id == "" {
+		id = SerializerJSON
+	}
+	serializersMu.RLock()
+	defer serializersMu.RUnlock()
+	s, ok := serializers[id]
+	if !ok {
+		return nil, fmt.Errorf("unknown serializer '%s'", id)
+	}
+	return s, nil
+}
+
+// serialize encodes v with the serializer id, recording the ID in front
+// of the result unless it is JSON. A version above zero is recorded too.
+func serialize(id string, version int, v any) (string, error) {
+	if id == "" {
+		id = SerializerJSON
+	}
+	s, err := lookupSerializer(id)
+	if err != nil {
+		return "", err
+	}
+	data, err := s.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	switch {
+	case version > 0:
+		return versionedMarker + string([]byte{byte(version)}) + id + serializedMarker + string(data), nil
+	case id == SerializerJSON:
+		return string(data), nil
+	default:
+		return serializedMarker + id + serializedMarker + string(data), nil
+	}
+}
+
+// deserialize decodes data into v with the serializer recorded in data,
+// JSON when none is. Values of an older schema version are upgraded by the
+// migrations of the type of v first.
+func deserialize(data string, v any) error {
+	id, version := SerializerJSON, 1
+	switch {
+	case strings.HasPrefix(data, serializedMarker):
+		recorded, encoded, found := strings.Cut(data[len(serializedMarker):], serializedMarker)
+		if !found {
+			return fmt.Errorf("invalid serializer header")
+		}
+		id, data = recorded, encoded
+	case strings.HasPrefix(data, versionedMarker):
+		rest := data[len(versionedMarker):]
+		if rest == "" || rest[0] == 0 {
+			return fmt.Errorf("invalid serializer header")
+		}
+		recorded, encoded, found := strings.Cut(rest[1:], serializedMarker)
+		if !found {
+			return fmt.Errorf("invalid serializer header")
+		}
+		id, version, data = recorded, int(rest[0]), encoded
+	}
+	s, err := lookupSerializer(id)
+	if err != nil {
+		return err
+	}
+
+	typ := reflect.TypeOf(v).Elem()
+	current := SchemaVersion(typ)
+	if current > 0 && version > current {
+		return fmt.Errorf("schema version %d of %s is newer than %d", version, typ, current)
+	}
+	if current > 0 && version < current {
+		upgraded, err := migrateSchema(s, typ, version, []byte(data))
+		if err != nil {
+			return err
+		}
+		data = string(upgraded)
+	}
+	return s.Unmarshal([]byte(data), v)
+}
+
+type jsonSerializer struct{}
+
+func (jsonSerializer) Marshal(v any) ([]byte, error)      { return json.Marshal(v) }
+func (jsonSerializer) Unmarshal(data []byte, v any) error { return json.Unmarshal(data, v) }
+
+type msgpackSerializer struct{}
+
+func (msgpackSerializer) Marshal(v any) ([]byte, error)      { return msgpack.Marshal(v) }
+func (msgpackSerializer) Unmarshal(data []byte, v any) error { return msgpack.Unmarshal(data, v) }
@@ -0,0 +1,102 @@
+package internal
+
+import (
+	"crypto/rand"
+	"fmt"
+)
+
+// shamirShare is one point of the polynomials splitting a secret, one
+// polynomial per byte
+type shamirShare struct {
+	x byte
+	y []byte
+}
+
+// splitSecret splits secret into n shares of which any threshold recover
+// it, with Shamir's scheme over GF(2^8)
+func splitSecret(secret []byte, n, threshold int) ([]shamirShare, error) {
+	if threshold < 2 || threshold > n || n > 255 {
+		return nil, fmt.Errorf("invalid %d-of-%d split", threshold, n)
+	}
+
+	shares := make([]shamirShare, n)
+	for i := range shares {
+		shares[i] = shamirShare{x: byte(i + 1), y: make([]byte, len(secret))}
+	}
+	coefficients := make([]byte, threshold)
+	for b, value := range secret {
+		if _, err := rand.Read(coefficients[1:]); err != nil {
+			return nil, fmt.Errorf("failed to generate polynomial: %w", err)
+		}
+		coefficients[0] = value
+		for i := range shares {
+			shares[i].y[b] = gfEval(coefficients, shares[i].x)
+		}
+	}
+	clear(coefficients)
+	return shares, nil
+}
+
+// combineShares recovers the secret from threshold or more shares by
+// Lagrange interpolation at zero
+func combineShares(shares []shamirShare) ([]byte, error) {
+	if len(shares) < 2 {
+		return nil, fmt.Errorf("at least 2 shares are required, got %d", len(shares))
+	}
+	size := len(shares[0].y)
+	seen := make(map[byte]bool, len(shares))
+	for _, share := range shares {
+		if share.x == 0 || seen[share.x] {
+			return nil, fmt.Errorf("share %d is invalid or given twice", share.x)
+		}
+		if len(share.y) != size {
+			return nil, fmt.Errorf("shares have different lengths")
+		}
+		seen[share.x] = true
+	}
+
+	secret := make([]byte, size)
+	for i, share := range shares {
+		// basis is the Lagrange basis polynomial of share at zero
+		basis := byte(1)
+		for j, other := range shares {
+			if i != j {
+				basis = gfMul(basis, gfMul(other.x, gfInv(other.x^share.x)))
+			}
+		}
+		for b := range secret {
+			secret[b] ^= gfMul(share.y[b], basis)
+		}
+	}
+	return secret, nil
+}
+
+// gfEval evaluates the polynomial with coefficients at x, by Horner's rule
+func gfEval(coefficients []byte, x byte) byte {
+	var y byte
+	for i := len(coefficients) - 1; i >= 0; i-- {
+		y = gfMul(y, x) ^ coefficients[i]
+	}
+	return y
+}
+
+// gfMul multiplies in GF(2^8) with the AES polynomial, without branching
+// on secret values
+func gfMul(a, b byte) byte {
+	var p byte
+	for range 8 {
+		p ^= -(b & 1) & a
+		a = a<<1 ^ -(a>>7)&0x1b
+		b >>= 1
+	}
+	return p
+}
+
+// gfInv returns the multiplicative inverse of a non-zero a, a^254
+func gfInv(a byte) byte {
+	result := byte(1)
+	for range 254 {
+		result = gfMul(result, a)
+	}
+	return result
+}
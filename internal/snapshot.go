@@ -0,0 +1,100 @@
+package internal
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// DecryptSnapshot decrypts a copy of a row, e.g. one stored by a trigger as
+// jsonb in an audit or history table, for incident investigation. model
+// names the table of the row; the values of its encrypted fields are
+// decrypted with their codec, and binary fields may be hex encoded as by
+// Postgres' to_jsonb ("\x..."). Other values holding a ciphertext in the
+// native format are decrypted too, so that columns encrypted at the time
+// but no longer tagged are read, and model may be nil. Ciphertexts are
+// decrypted with the key named in them, so rows copied before a rotation
+// are read as long as the retired key is still loaded. Companion columns
+// such as blind indexes are returned as stored.
+func (g *GovaultDB) DecryptSnapshot(model any, snapshot map[string]any) (map[string]any, error) {
+	var spec *ModelSpec
+	if model != nil {
+		if spec = g.ModelSpec(model); spec == nil {
+			return nil, fmt.Errorf("model must be a struct, got %T", model)
+		}
+	}
+
+	row := make(map[string]any, len(snapshot))
+	for column, value := range snapshot {
+		row[column] = value
+		s, ok := value.(string)
+		if !ok || s == "" {
+			continue
+		}
+
+		field := snapshotField(spec, column)
+		if field == nil {
+			if !IsEncrypted(s) {
+				continue
+			}
+			plaintext, err := g.Decrypt(s)
+			if err != nil {
+				return nil, fmt.Errorf("failed to decrypt column %s: %w", column, err)
+			}
+			row[column] = plaintext
+			continue
+		}
+
+		if field.Binary {
+			decoded, err := decodeSnapshotBinary(s)
+			if err != nil {
+				return nil, fmt.Errorf("column %s: %w", column, err)
+			}
+			s = decoded
+		}
+		plaintext, ok, err := g.DecryptField(field, spec.Table, s)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt column %s: %w", column, err)
+		}
+		if ok {
+			row[column] = plaintext
+		}
+	}
+	return row, nil
+}
+
+// DecryptSnapshotJSON is DecryptSnapshot for a row encoded as a JSON object
+func (g *GovaultDB) DecryptSnapshotJSON(model any, data []byte) (map[string]any, error) {
+	var snapshot map[string]any
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return nil, fmt.Errorf("failed to parse snapshot: %w", err)
+	}
+	return g.DecryptSnapshot(model, snapshot)
+}
+
+// snapshotField returns the encrypted field of spec stored in column
+func snapshotField(spec *ModelSpec, column string) *FieldSpec {
+	if spec == nil {
+		return nil
+	}
+	for _, field := range spec.Fields {
+		if field.Column == column {
+			return field
+		}
+	}
+	return nil
+}
+
+// decodeSnapshotBinary returns the ciphertext of a binary column, given
+// either hex encoded with a \x prefix or as the raw bytes
+func decodeSnapshotBinary(s string) (string, error) {
+	data := []byte(s)
+	if hexData, ok := strings.CutPrefix(s, `\x`); ok {
+		var err error
+		if data, err = hex.DecodeString(hexData); err != nil {
+			return "", fmt.Errorf("invalid hex value: %w", err)
+		}
+	}
+	return DecodeBinary(data)
+}
@@ -0,0 +1,74 @@
+package internal_test
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"testing"
+
+	"github.com/muhammadluth/govault/internal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecryptSnapshot(t *testing.T) {
+	type customer struct {
+		ID        int64  `bun:"id,pk"`
+		Email     string `bun:"email" encrypted:"true" blindindex:"email_bidx"`
+		EmailBidx string `bun:"email_bidx"`
+		SSN       string `bun:"ssn" encrypted:"true" storage:"binary"`
+		Name      string `bun:"name"`
+	}
+
+	before, err := internal.New(internal.Config{
+		Keys:         map[string][]byte{"1": reloadKey1},
+		DefaultKeyID: "1",
+	})
+	require.NoError(t, err)
+	c := &customer{ID: 7, Email: "john@example.com", SSN: "123-45-6789", Name: "John"}
+	require.NoError(t, before.EncryptModel(c, ""))
+	ssn, err := internal.EncodeBinary(c.SSN)
+	require.NoError(t, err)
+	retired, err := before.Encrypt("dropped column")
+	require.NoError(t, err)
+
+	// The snapshot as written by a to_jsonb trigger before a key rotation
+	data, err := json.Marshal(map[string]any{
+		"id":         c.ID,
+		"email":      c.Email,
+		"email_bidx": c.EmailBidx,
+		"ssn":        `\x` + hex.EncodeToString(ssn),
+		"name":       c.Name,
+		"old_phone":  retired,
+	})
+	require.NoError(t, err)
+
+	after, err := internal.New(internal.Config{
+		Keys:         map[string][]byte{"1": reloadKey1, "2": reloadKey2},
+		DefaultKeyID: "2",
+	})
+	require.NoError(t, err)
+
+	row, err := after.DecryptSnapshotJSON((*customer)(nil), data)
+	require.NoError(t, err)
+	assert.Equal(t, "john@example.com", row["email"])
+	assert.Equal(t, c.EmailBidx, row["email_bidx"])
+	assert.Equal(t, "123-45-6789", row["ssn"])
+	assert.Equal(t, "John", row["name"])
+	assert.Equal(t, "dropped column", row["old_phone"], "untagged ciphertexts are decrypted")
+	assert.EqualValues(t, 7, row["id"])
+
+	row, err = after.DecryptSnapshotJSON(nil, data)
+	require.NoError(t, err)
+	assert.Equal(t, "john@example.com", row["email"])
+
+	withoutKey, err := internal.New(internal.Config{
+		Keys:         map[string][]byte{"2": reloadKey2},
+		DefaultKeyID: "2",
+	})
+	require.NoError(t, err)
+	_, err = withoutKey.DecryptSnapshotJSON((*customer)(nil), data)
+	assert.Error(t, err)
+
+	_, err = after.DecryptSnapshotJSON(nil, []byte("not json"))
+	assert.ErrorContains(t, err, "failed to parse snapshot")
+}
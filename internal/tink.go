@@ -0,0 +1,348 @@
+package internal
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+// CodecTink reads and writes Tink AES-GCM ciphertexts (TINK output prefix),
+// base64 encoded for text columns. The 5-byte prefix carrying the key ID is
+// all that tells them apart from other base64 text.
+const CodecTink = "tink"
+
+const (
+	tinkAESGCMTypeURL   = "type.googleapis.com/google.crypto.tink.AesGcmKey"
+	tinkStartByte       = 0x01
+	tinkPrefixSize      = 5
+	tinkStatusEnabled   = 1
+	tinkPrefixTink      = 1
+	tinkPrefixRaw       = 3
+	protoWireVarint     = 0
+	protoWireFixed64    = 1
+	protoWireBytes      = 2
+	protoWireFixed32    = 5
+	tinkKeysetFieldKey  = 2
+	tinkKeysetPrimaryID = 1
+)
+
+// TinkAEAD decrypts an encrypted Tink keyset. It matches the signature of
+// tink.AEAD so KMS-backed AEADs from the Tink integrations can be used as-is.
+type TinkAEAD interface {
+	Decrypt(ciphertext, associatedData []byte) ([]byte, error)
+}
+
+// TinkKeyset holds the AES-256-GCM keys loaded from a Tink keyset, keyed by
+// the decimal Tink key ID, ready to be used as Config.Keys
+type TinkKeyset struct {
+	Keys         map[string][]byte
+	PrimaryKeyID string
+}
+
+type tinkKey struct {
+	typeURL      string
+	value        []byte
+	status       int
+	keyID        uint32
+	outputPrefix int
+}
+
+// LoadTinkKeyset parses a cleartext Tink keyset in JSON format
+func LoadTinkKeyset(data []byte) (*TinkKeyset, error) {
+	var keyset struct {
+		PrimaryKeyID uint32 `json:"primaryKeyId"`
+		Key          []struct {
+			KeyData struct {
+				TypeURL string `json:"typeUrl"`
+				Value   string `json:"value"`
+			} `json:"keyData"`
+			Status           string `json:"status"`
+			KeyID            uint32 `json:"keyId"`
+			OutputPrefixType string `json:"outputPrefixType"`
+		} `json:"key"`
+	}
+	if err := json.Unmarshal(data, &keyset); err != nil {
+		return nil, fmt.Errorf("failed to parse tink keyset: %w", err)
+	}
+
+	keys := make([]tinkKey, 0, len(keyset.Key))
+	for _, k := range keyset.Key {
+		value, err := base64.StdEncoding.DecodeString(k.KeyData.Value)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode tink key %d: %w", k.KeyID, err)
+		}
+		key := tinkKey{typeURL: k.KeyData.TypeURL, value: value, keyID: k.KeyID}
+		if k.Status == "ENABLED" {
+			key.status = tinkStatusEnabled
+		}
+		switch k.OutputPrefixType {
+		case "TINK":
+			key.outputPrefix = tinkPrefixTink
+		case "RAW":
+			key.outputPrefix = tinkPrefixRaw
+		}
+		keys = append(keys, key)
+	}
+
+	return newTinkKeyset(keyset.PrimaryKeyID, keys)
+}
+
+// LoadEncryptedTinkKeyset parses a Tink keyset in JSON format whose key
+// material is encrypted with a key encryption key, typically held in a KMS
+func LoadEncryptedTinkKeyset(data []byte, kek TinkAEAD) (*TinkKeyset, error) {
+	var encrypted struct {
+		EncryptedKeyset string `json:"encryptedKeyset"`
+	}
+	if err := json.Unmarshal(data, &encrypted); err != nil {
+		return nil, fmt.Errorf("failed to parse encrypted tink keyset: %w", err)
+	}
+	if encrypted.EncryptedKeyset == "" {
+		return nil, fmt.Errorf("encrypted tink keyset is empty")
+	}
+
+	ciphertext, err := base64.StdEncoding.DecodeString(encrypted.EncryptedKeyset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode encrypted tink keyset: %w", err)
+	}
+
+	serialized, err := kek.Decrypt(ciphertext, []byte{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt tink keyset: %w", err)
+	}
+
+	return parseTinkKeysetProto(serialized)
+}
+
+// parseTinkKeysetProto parses a serialized google.crypto.tink.Keyset
+func parseTinkKeysetProto(data []byte) (*TinkKeyset, error) {
+	var primary uint32
+	var keys []tinkKey
+
+	err := walkProto(data, func(num int, wire int, v uint64, b []byte) error {
+		switch {
+		case num == tinkKeysetPrimaryID && wire == protoWireVarint:
+			primary = uint32(v)
+		case num == tinkKeysetFieldKey && wire == protoWireBytes:
+			key, err := parseTinkKeyProto(b)
+			if err != nil {
+				return err
+			}
+			keys = append(keys, key)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse tink keyset: %w", err)
+	}
+
+	return newTinkKeyset(primary, keys)
+}
+
+// parseTinkKeyProto parses a Keyset.Key message. KeyData.value is kept in
+// its serialized form and decoded by newTinkKeyset.
+func parseTinkKeyProto(data []byte) (tinkKey, error) {
+	var key tinkKey
+	err := walkProto(data, func(num int, wire int, v uint64, b []byte) error {
+		switch {
+		case num == 1 && wire == protoWireBytes:
+			return walkProto(b, func(num int, wire int, _ uint64, b []byte) error {
+				switch {
+				case num == 1 && wire == protoWireBytes:
+					key.typeURL = string(b)
+				case num == 2 && wire == protoWireBytes:
+					key.value = b
+				}
+				return nil
+			})
+		case num == 2 && wire == protoWireVarint:
+			key.status = int(v)
+		case num == 3 && wire == protoWireVarint:
+			key.keyID = uint32(v)
+		case num == 4 && wire == protoWireVarint:
+			key.outputPrefix = int(v)
+		}
+		return nil
+	})
+	return key, err
+}
+
+// newTinkKeyset extracts the enabled AES-GCM keys
+func newTinkKeyset(primary uint32, keys []tinkKey) (*TinkKeyset, error) {
+	keyset := &TinkKeyset{
+		Keys:         make(map[string][]byte),
+		PrimaryKeyID: strconv.FormatUint(uint64(primary), 10),
+	}
+
+	for _, key := range keys {
+		if key.status != tinkStatusEnabled {
+			continue
+		}
+		if key.typeURL != tinkAESGCMTypeURL {
+			return nil, fmt.Errorf("tink key %d: unsupported key type %s", key.keyID, key.typeURL)
+		}
+		switch key.outputPrefix {
+		case tinkPrefixTink:
+		case tinkPrefixRaw:
+			// The codec finds the key of a ciphertext by its prefix, and
+			// could not tell RAW ciphertexts from any other base64 text
+			return nil, fmt.Errorf("tink key %d: output prefix type RAW is not supported, only TINK", key.keyID)
+		default:
+			return nil, fmt.Errorf("tink key %d: unsupported output prefix type %d", key.keyID, key.outputPrefix)
+		}
+
+		// AesGcmKey: version = 1, key_value = 3
+		var keyValue []byte
+		err := walkProto(key.value, func(num int, wire int, _ uint64, b []byte) error {
+			if num == 3 && wire == protoWireBytes {
+				keyValue = b
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("tink key %d: failed to parse key value: %w", key.keyID, err)
+		}
+		if len(keyValue) != 32 {
+			return nil, fmt.Errorf("tink key %d: key must be 32 bytes for AES-256, got %d bytes", key.keyID, len(keyValue))
+		}
+
+		keyset.Keys[strconv.FormatUint(uint64(key.keyID), 10)] = keyValue
+	}
+
+	if _, exists := keyset.Keys[keyset.PrimaryKeyID]; !exists {
+		return nil, fmt.Errorf("primary tink key %s is not an enabled AES-GCM key", keyset.PrimaryKeyID)
+	}
+	return keyset, nil
+}
+
+// walkProto iterates over the fields of a serialized protobuf message
+func walkProto(data []byte, fn func(num int, wire int, v uint64, b []byte) error) error {
+	for len(data) > 0 {
+		tag, n := binary.Uvarint(data)
+		if n <= 0 {
+			return errors.New("malformed protobuf tag")
+		}
+		data = data[n:]
+		num, wire := int(tag>>3), int(tag&7)
+
+		var v uint64
+		var b []byte
+		switch wire {
+		case protoWireVarint:
+			v, n = binary.Uvarint(data)
+			if n <= 0 {
+				return errors.New("malformed protobuf varint")
+			}
+			data = data[n:]
+		case protoWireBytes:
+			size, n := binary.Uvarint(data)
+			if n <= 0 || uint64(len(data)-n) < size {
+				return errors.New("malformed protobuf length")
+			}
+			b = data[n : n+int(size)]
+			data = data[n+int(size):]
+		case protoWireFixed64:
+			if len(data) < 8 {
+				return errors.New("malformed protobuf fixed64")
+			}
+			data = data[8:]
+		case protoWireFixed32:
+			if len(data) < 4 {
+				return errors.New("malformed protobuf fixed32")
+			}
+			data = data[4:]
+		default:
+			return fmt.Errorf("unsupported protobuf wire type %d", wire)
+		}
+
+		if err := fn(num, wire, v, b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type tinkCodec struct{}
+
+// IsEncrypted reports whether value is canonical base64 of a TINK prefix
+// followed by at least a nonce and a tag
+func (tinkCodec) IsEncrypted(value string) bool {
+	data, err := base64.StdEncoding.Strict().DecodeString(value)
+	return err == nil && len(data) >= tinkPrefixSize+gcmNonceSize+gcmTagSize && data[0] == tinkStartByte
+}
+
+func (tinkCodec) Encrypt(g *GovaultDB, _ *FieldSpec, _, plaintext, keyID string) (string, error) {
+	key, err := g.encryptionKey(keyID)
+	if err != nil {
+		return "", err
+	}
+	if key.hpke != nil {
+		return "", fmt.Errorf("tink codec requires a symmetric key, '%s' is an HPKE key", key.ID)
+	}
+	id, err := strconv.ParseUint(key.ID, 10, 32)
+	if err != nil {
+		return "", fmt.Errorf("tink key ID must be numeric, got '%s'", key.ID)
+	}
+
+	aead := key.cipher
+	out := make([]byte, tinkPrefixSize, tinkPrefixSize+aead.NonceSize()+len(plaintext)+aead.Overhead())
+	out[0] = tinkStartByte
+	binary.BigEndian.PutUint32(out[1:], uint32(id))
+
+	nonce, err := g.newNonce(aead.NonceSize())
+	if err != nil {
+		return "", err
+	}
+	out = append(out, nonce...)
+	out = aead.Seal(out, nonce, []byte(plaintext), nil)
+	if err := g.RecordNonce(key.ID, nonce, out[tinkPrefixSize+len(nonce):]); err != nil {
+		return "", err
+	}
+
+	return base64.StdEncoding.EncodeToString(out), nil
+}
+
+func (c tinkCodec) Decrypt(g *GovaultDB, _ *FieldSpec, _, ciphertext string) (string, error) {
+	data, err := base64.StdEncoding.DecodeString(ciphertext)
+	if err != nil {
+		return "", &FormatError{Part: "ciphertext", Reason: "is not valid base64"}
+	}
+	if len(data) < tinkPrefixSize+gcmNonceSize+gcmTagSize || data[0] != tinkStartByte {
+		return "", &FormatError{Part: "ciphertext", Reason: "is not a tink ciphertext"}
+	}
+
+	key, err := g.lookupDecryptKey(tinkKeyID(data))
+	if err != nil {
+		return "", err
+	}
+	if key.hpke != nil {
+		return "", fmt.Errorf("tink codec requires a symmetric key, '%s' is an HPKE key", key.ID)
+	}
+
+	body := data[tinkPrefixSize:]
+	plaintext, err := key.cipher.Open(nil, body[:gcmNonceSize], body[gcmNonceSize:], nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt: %w", err)
+	}
+	return string(plaintext), nil
+}
+
+// KeyIDs returns the key named by the prefix of ciphertext
+func (tinkCodec) KeyIDs(_ *GovaultDB, ciphertext string) []string {
+	data, err := base64.StdEncoding.DecodeString(ciphertext)
+	if err != nil || len(data) < tinkPrefixSize || data[0] != tinkStartByte {
+		return nil
+	}
+	return []string{tinkKeyID(data)}
+}
+
+// tinkKeyID returns the key ID of the TINK prefix of data
+func tinkKeyID(data []byte) string {
+	return strconv.FormatUint(uint64(binary.BigEndian.Uint32(data[1:tinkPrefixSize])), 10)
+}
+
+func (tinkCodec) BlindIndex(g *GovaultDB, field *FieldSpec, table, plaintext string) (string, error) {
+	return g.nativeBlindIndex(field, table, plaintext)
+}
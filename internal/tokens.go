@@ -0,0 +1,87 @@
+package internal
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"reflect"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// tokenIndexDomain separates token index keys from blind index keys
+const tokenIndexDomain = "govault token index"
+
+// tokenIndexBits is the length of the token HMACs
+const tokenIndexBits = 64
+
+// Tokenize splits plaintext into the words of a token index: lower-cased
+// runs of letters and digits, deduplicated, in order of first occurrence
+func Tokenize(plaintext string) []string {
+	words := strings.FieldsFunc(strings.ToLower(plaintext), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	seen := make(map[string]bool, len(words))
+	tokens := words[:0]
+	for _, word := range words {
+		if !seen[word] {
+			seen[word] = true
+			tokens = append(tokens, word)
+		}
+	}
+	return tokens
+}
+
+// TokenIndex computes the token set of plaintext stored in the column named
+// by the tokenindex tag: the sorted HMACs of its words, space separated and
+// enclosed in spaces, so that TokenHash(word) is found with
+// LIKE '% <hash> %'.
+//
+// The set hides the words and their order and count but reveals the number
+// of distinct words of a value, which values share a word, and, over many
+// rows, the frequency of words, e.g. the most common one. Searches match
+// whole words only, without stemming or prefixes.
+func (g *GovaultDB) TokenIndex(field *FieldSpec, table, plaintext string) (string, error) {
+	words := Tokenize(plaintext)
+	if len(words) == 0 {
+		return "", nil
+	}
+	hashes := make([]string, len(words))
+	for i, word := range words {
+		hash, err := g.TokenHash(field, table, word)
+		if err != nil {
+			return "", err
+		}
+		hashes[i] = hash
+	}
+	sort.Strings(hashes)
+	return " " + strings.Join(hashes, " ") + " ", nil
+}
+
+// TokenHash computes the HMAC of a word of a token index, keyed by a
+// subkey of the blind index key bound to the table and column. word must
+// be a single word of Tokenize.
+func (g *GovaultDB) TokenHash(field *FieldSpec, table, word string) (string, error) {
+	subKey, err := g.blindIndexSubKey(tokenIndexDomain, table, field.Column)
+	if err != nil {
+		return "", err
+	}
+	mac := hmac.New(sha256.New, subKey)
+	mac.Write([]byte(word))
+	return hex.EncodeToString(truncateBits(mac.Sum(nil), tokenIndexBits)), nil
+}
+
+// setTokenIndex writes the token set of plaintext into the companion column
+func (g *GovaultDB) setTokenIndex(val reflect.Value, spec *ModelSpec, field *FieldSpec, plaintext string) error {
+	target, err := companionField(val, spec, field.TokenIndex, "token index")
+	if err != nil {
+		return err
+	}
+	tokens, err := g.TokenIndex(field, spec.Table, plaintext)
+	if err != nil {
+		return err
+	}
+	target.SetString(tokens)
+	return nil
+}
@@ -0,0 +1,60 @@
+package internal_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/muhammadluth/govault/internal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTokenize(t *testing.T) {
+	assert.Equal(t, []string{"call", "bob", "re", "invoice", "42"}, internal.Tokenize("Call Bob re: invoice #42, call BOB!"))
+	assert.Equal(t, []string{"café", "über"}, internal.Tokenize("café/Über"))
+	assert.Empty(t, internal.Tokenize(" -- "))
+}
+
+func TestTokenIndex(t *testing.T) {
+	type note struct {
+		ID         int64  `bun:"id"`
+		Body       string `bun:"body" encrypted:"true" tokenindex:"body_tokens"`
+		BodyTokens string `bun:"body_tokens"`
+		Title      string `bun:"title" encrypted:"true" tokenindex:"title_tokens"`
+	}
+
+	g, err := internal.New(internal.Config{
+		Keys:         map[string][]byte{"1": reloadKey1},
+		DefaultKeyID: "1",
+	})
+	require.NoError(t, err)
+	spec := internal.GetModelSpec((*note)(nil))
+	field := spec.Field("Body")
+	assert.Equal(t, "body_tokens", field.TokenIndex)
+
+	n := &note{Body: "Call Bob about the invoice, call today", Title: "Reminder"}
+	err = g.EncryptModel(n, "")
+	assert.ErrorContains(t, err, "token index column 'title_tokens' not found in model")
+	require.True(t, strings.HasPrefix(n.BodyTokens, " ") && strings.HasSuffix(n.BodyTokens, " "))
+
+	tokens := strings.Fields(n.BodyTokens)
+	assert.Len(t, tokens, 6, "one token per distinct word")
+	assert.IsIncreasing(t, tokens, "tokens are sorted, hiding the word order")
+	for _, word := range []string{"bob", "invoice", "call"} {
+		hash, err := g.TokenHash(field, spec.Table, word)
+		require.NoError(t, err)
+		assert.Contains(t, n.BodyTokens, " "+hash+" ")
+	}
+	hash, err := g.TokenHash(field, spec.Table, "alice")
+	require.NoError(t, err)
+	assert.NotContains(t, n.BodyTokens, " "+hash+" ")
+
+	// Tokens are bound to the column
+	other, err := g.TokenHash(spec.Field("Title"), spec.Table, "bob")
+	require.NoError(t, err)
+	assert.NotContains(t, n.BodyTokens, other)
+
+	empty, err := g.TokenIndex(field, spec.Table, "")
+	require.NoError(t, err)
+	assert.Empty(t, empty)
+}
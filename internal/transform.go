@@ -0,0 +1,167 @@
+package internal
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/hkdf"
+)
+
+// Transform anonymizes a decrypted value for a restricted role, e.g. an
+// analytics export. It is selected per field and role with the transform
+// tag: transform:"analytics=hash,support=last4".
+type Transform func(g *GovaultDB, field *FieldSpec, table, plaintext string) (string, error)
+
+// Built-in transforms
+const (
+	// TransformHash replaces the value with a keyed pseudonym. Equal values
+	// of the same column share a pseudonym, so exports can still be joined.
+	TransformHash = "hash"
+	// TransformMonth generalizes a date to its month, YYYY-MM
+	TransformMonth = "month"
+	// TransformYear generalizes a date to its year, YYYY
+	TransformYear = "year"
+	// TransformZip3 truncates a postal code to its first three characters
+	TransformZip3 = "zip3"
+	// TransformLast4 masks all but the last four characters
+	TransformLast4 = "last4"
+	// TransformRedact replaces the value with FallbackMaskValue
+	TransformRedact = "redact"
+)
+
+// TransformAnyRole is the role of the transform read by roles without one
+// of their own: transform:"*=last4,analytics=hash"
+const TransformAnyRole = "*"
+
+var (
+	transformsMu sync.RWMutex
+	transforms   = map[string]Transform{
+		TransformHash:    pseudonymize,
+		TransformMonth:   generalizeDate("2006-01"),
+		TransformYear:    generalizeDate("2006"),
+		TransformZip3:    truncateZip,
+		TransformLast4:   maskLast4,
+		TransformRedact:  redact,
+		TransformMaskPAN: maskPAN,
+	}
+)
+
+// RegisterTransform makes t available to transform tags under name,
+// replacing any transform registered before
+func RegisterTransform(name string, t Transform) {
+	transformsMu.Lock()
+	defer transformsMu.Unlock()
+	transforms[name] = t
+}
+
+// lookupTransform returns the transform registered under name
+func lookupTransform(name string) (Transform, error) {
+	transformsMu.RLock()
+	defer transformsMu.RUnlock()
+	t, ok := transforms[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown transform '%s'", name)
+	}
+	return t, nil
+}
+
+type transformRoleKey struct{}
+
+// WithTransformRole returns a context whose reads apply the transforms
+// configured for role instead of returning plaintext
+func WithTransformRole(ctx context.Context, role string) context.Context {
+	return context.WithValue(ctx, transformRoleKey{}, role)
+}
+
+// TransformRole returns the role set with WithTransformRole, or empty
+func TransformRole(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	role, _ := ctx.Value(transformRoleKey{}).(string)
+	return role
+}
+
+// TransformField applies the transform configured on field for role, or
+// for TransformAnyRole. Fields without either are returned unchanged.
+func (g *GovaultDB) TransformField(field *FieldSpec, table, role, plaintext string) (string, error) {
+	if role == "" || field == nil {
+		return plaintext, nil
+	}
+	name, ok := field.Transforms[role]
+	if !ok {
+		if name, ok = field.Transforms[TransformAnyRole]; !ok {
+			return plaintext, nil
+		}
+	}
+	t, err := lookupTransform(name)
+	if err != nil {
+		return "", err
+	}
+	return t(g, field, table, plaintext)
+}
+
+// pseudonymDomain separates pseudonym keys from blind index keys
+const pseudonymDomain = "govault pseudonym"
+
+// pseudonymize returns an HMAC-SHA256 of plaintext keyed by a subkey of the
+// blind index key bound to the table and column, truncated to 128 bits
+func pseudonymize(g *GovaultDB, field *FieldSpec, table, plaintext string) (string, error) {
+	key, err := g.lookupKey(g.blindIndexKey)
+	if err != nil {
+		return "", fmt.Errorf("failed to load pseudonym key: %w", err)
+	}
+
+	subKey := make([]byte, 32)
+	kdf := hkdf.New(sha256.New, key.Value, []byte(pseudonymDomain), []byte(table+"."+field.Column))
+	if _, err := io.ReadFull(kdf, subKey); err != nil {
+		return "", fmt.Errorf("failed to derive pseudonym key: %w", err)
+	}
+
+	mac := hmac.New(sha256.New, subKey)
+	mac.Write([]byte(plaintext))
+	return hex.EncodeToString(mac.Sum(nil)[:16]), nil
+}
+
+// dateLayouts are the layouts accepted by the date transforms
+var dateLayouts = []string{time.RFC3339Nano, time.DateTime, time.DateOnly}
+
+// generalizeDate returns a transform formatting dates with layout. Values
+// that are not dates fail rather than leak.
+func generalizeDate(layout string) Transform {
+	return func(g *GovaultDB, field *FieldSpec, table, plaintext string) (string, error) {
+		for _, in := range dateLayouts {
+			if t, err := time.Parse(in, plaintext); err == nil {
+				return t.Format(layout), nil
+			}
+		}
+		return "", fmt.Errorf("value of %s is not a date", field.Column)
+	}
+}
+
+func truncateZip(g *GovaultDB, field *FieldSpec, table, plaintext string) (string, error) {
+	zip := []rune(strings.TrimSpace(plaintext))
+	if len(zip) > 3 {
+		zip = zip[:3]
+	}
+	return string(zip), nil
+}
+
+func maskLast4(g *GovaultDB, field *FieldSpec, table, plaintext string) (string, error) {
+	runes := []rune(plaintext)
+	for i := 0; i < len(runes)-4; i++ {
+		runes[i] = '*'
+	}
+	return string(runes), nil
+}
+
+func redact(g *GovaultDB, field *FieldSpec, table, plaintext string) (string, error) {
+	return FallbackMaskValue, nil
+}
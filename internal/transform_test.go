@@ -0,0 +1,104 @@
+package internal_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/muhammadluth/govault/internal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type transformPatient struct {
+	Email     string `bun:"email" encrypted:"true" transform:"analytics=hash,support=last4"`
+	BirthDate string `bun:"birth_date" encrypted:"true" transform:"analytics=month"`
+	Zip       string `bun:"zip" encrypted:"true" transform:"analytics=zip3"`
+	Notes     string `bun:"notes" encrypted:"true" transform:"analytics=redact"`
+	Name      string `bun:"name" encrypted:"true"`
+}
+
+func newTransformDB(t testing.TB) *internal.GovaultDB {
+	t.Helper()
+	g, err := internal.New(internal.Config{
+		Keys:         map[string][]byte{"1": []byte("727d37a0-a5f2-4d67-af47-83039c8e")},
+		DefaultKeyID: "1",
+	})
+	require.NoError(t, err)
+	return g
+}
+
+func encryptedPatient(t *testing.T, g *internal.GovaultDB, email string) transformPatient {
+	t.Helper()
+	p := transformPatient{Email: email, BirthDate: "1987-06-21", Zip: "94110", Notes: "allergic to penicillin", Name: "Ada"}
+	require.NoError(t, g.EncryptModel(&p, ""))
+	return p
+}
+
+func TestTransformRoles(t *testing.T) {
+	g := newTransformDB(t)
+	stored := encryptedPatient(t, g, "ada@example.com")
+
+	plain := stored
+	require.NoError(t, g.DecryptRecursiveContext(context.Background(), &plain))
+	assert.Equal(t, "ada@example.com", plain.Email)
+	assert.Equal(t, "1987-06-21", plain.BirthDate)
+
+	analytics := internal.WithTransformRole(context.Background(), "analytics")
+	exported := []transformPatient{stored, encryptedPatient(t, g, "ada@example.com"), encryptedPatient(t, g, "bob@example.com")}
+	require.NoError(t, g.DecryptRecursiveContext(analytics, &exported))
+
+	assert.Len(t, exported[0].Email, 32)
+	assert.NotContains(t, exported[0].Email, "ada")
+	assert.Equal(t, exported[0].Email, exported[1].Email, "equal values share a pseudonym")
+	assert.NotEqual(t, exported[0].Email, exported[2].Email)
+	assert.Equal(t, "1987-06", exported[0].BirthDate)
+	assert.Equal(t, "941", exported[0].Zip)
+	assert.Equal(t, internal.FallbackMaskValue, exported[0].Notes)
+	assert.Equal(t, "Ada", exported[0].Name, "fields without a transform for the role are decrypted")
+
+	support := stored
+	require.NoError(t, g.DecryptRecursiveContext(internal.WithTransformRole(context.Background(), "support"), &support))
+	assert.Equal(t, "***********.com", support.Email)
+	assert.Equal(t, "1987-06-21", support.BirthDate)
+}
+
+func TestTransformPlaintextValues(t *testing.T) {
+	g := newTransformDB(t)
+
+	// Rows not yet encrypted must not leak either
+	p := transformPatient{BirthDate: "1987-06-21T10:00:00Z", Zip: "94110"}
+	require.NoError(t, g.DecryptRecursiveContext(internal.WithTransformRole(context.Background(), "analytics"), &p))
+	assert.Equal(t, "1987-06", p.BirthDate)
+	assert.Equal(t, "941", p.Zip)
+	assert.Empty(t, p.Email)
+
+	p = transformPatient{BirthDate: "sometime in june"}
+	err := g.DecryptRecursiveContext(internal.WithTransformRole(context.Background(), "analytics"), &p)
+	assert.ErrorContains(t, err, "not a date")
+}
+
+func TestRegisterTransform(t *testing.T) {
+	type record struct {
+		Name string `bun:"name" encrypted:"true" transform:"analytics=initials"`
+	}
+	internal.RegisterTransform("initials", func(g *internal.GovaultDB, field *internal.FieldSpec, table, plaintext string) (string, error) {
+		var out string
+		for _, word := range strings.Fields(plaintext) {
+			out += word[:1] + "."
+		}
+		return out, nil
+	})
+
+	g := newTransformDB(t)
+	r := record{Name: "Ada Lovelace"}
+	require.NoError(t, g.EncryptModel(&r, ""))
+	require.NoError(t, g.DecryptRecursiveContext(internal.WithTransformRole(context.Background(), "analytics"), &r))
+	assert.Equal(t, "A.L.", r.Name)
+
+	type unknown struct {
+		Name string `bun:"name" encrypted:"true" transform:"analytics=nope"`
+	}
+	u := unknown{Name: "Ada"}
+	assert.ErrorContains(t, g.DecryptRecursiveContext(internal.WithTransformRole(context.Background(), "analytics"), &u), "unknown transform 'nope'")
+}
@@ -0,0 +1,292 @@
+package govault
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// Option configures NewWithOptions. Options are applied in order, later
+// ones overriding earlier ones.
+type Option func(*options)
+
+// options collects the Config and the settings applied after construction
+type options struct {
+	config Config
+	hooks  []bun.QueryHook
+}
+
+// NewWithOptions creates a govault DB from functional options, the
+// extensible alternative to New:
+//
+//	govault.NewWithOptions(
+//		govault.WithBun(db),
+//		govault.WithKeys(keys),
+//		govault.WithDefaultKey("k1"),
+//		govault.WithHook(gb.NewPlaintextGuard(&User{})),
+//	)
+//
+// Unset options keep the zero value defaults of Config: read-write mode,
+// fail-closed fallback, blind indexes and ciphersweet under the default key,
+// empty strings stored as is and no nonce reuse detection.
+func NewWithOptions(opts ...Option) (*GovaultDB, error) {
+	o := options{config: Config{Mode: ModeReadWrite, Fallback: FallbackFailClosed}}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	if len(o.hooks) > 0 && o.config.AdapterName != AdapterNameBun {
+		return nil, fmt.Errorf("query hooks require the bun adapter")
+	}
+
+	govault, err := New(o.config)
+	if err != nil {
+		return nil, err
+	}
+	for _, hook := range o.hooks {
+		govault.BunDB().AddQueryHook(hook)
+	}
+	return govault, nil
+}
+
+// WithConfig starts from config, e.g. to migrate code using New one option
+// at a time. Later options override its fields; the maps and slices they
+// add to are copied, so config is left unchanged.
+func WithConfig(config Config) Option {
+	return func(o *options) {
+		o.config = config
+		o.config.Keys = maps.Clone(config.Keys)
+		o.config.HPKEPublicKeys = maps.Clone(config.HPKEPublicKeys)
+		o.config.HPKEPrivateKeys = maps.Clone(config.HPKEPrivateKeys)
+		o.config.ProviderKeys = maps.Clone(config.ProviderKeys)
+		o.config.BunDBs = maps.Clone(config.BunDBs)
+		o.config.EnvelopeCodecs = maps.Clone(config.EnvelopeCodecs)
+		o.config.DecryptOnlyKeyIDs = slices.Clip(config.DecryptOnlyKeyIDs)
+		o.config.ModelHooks = slices.Clip(config.ModelHooks)
+	}
+}
+
+// WithBun selects the bun adapter wrapping db
+func WithBun(db *bun.DB) Option {
+	return func(o *options) {
+		o.config.AdapterName = AdapterNameBun
+		o.config.BunDB = db
+	}
+}
+
+// WithAdapter selects the adapter registered under name wrapping db, see
+// RegisterAdapter
+func WithAdapter(name AdapterName, db any) Option {
+	return func(o *options) {
+		o.config.AdapterName = name
+		o.config.AdapterDB = db
+	}
+}
+
+// WithNamedBun adds db as the named database name, see Config.BunDBs. It
+// may be given more than once and requires WithBun.
+func WithNamedBun(name string, db *bun.DB) Option {
+	return func(o *options) {
+		if o.config.BunDBs == nil {
+			o.config.BunDBs = make(map[string]*bun.DB)
+		}
+		o.config.BunDBs[name] = db
+	}
+}
+
+// WithKeys adds encryption keys by key ID. It may be given more than once.
+func WithKeys(keys map[string][]byte) Option {
+	return func(o *options) {
+		if o.config.Keys == nil {
+			o.config.Keys = make(map[string][]byte, len(keys))
+		}
+		for id, key := range keys {
+			o.config.Keys[id] = key
+		}
+	}
+}
+
+// WithDefaultKey sets the key ID new values are encrypted with
+func WithDefaultKey(keyID string) Option {
+	return func(o *options) {
+		o.config.DefaultKeyID = keyID
+	}
+}
+
+// WithDecryptOnlyKeys marks keys that may decrypt but not encrypt
+func WithDecryptOnlyKeys(keyIDs ...string) Option {
+	return func(o *options) {
+		o.config.DecryptOnlyKeyIDs = append(o.config.DecryptOnlyKeyIDs, keyIDs...)
+	}
+}
+
+// WithBlindIndexKey sets the key used for blind indexes, by default the
+// default key
+func WithBlindIndexKey(keyID string) Option {
+	return func(o *options) {
+		o.config.BlindIndexKeyID = keyID
+	}
+}
+
+// WithCipherSweetKey sets the root key of the ciphersweet codec, by default
+// the default key
+func WithCipherSweetKey(keyID string) Option {
+	return func(o *options) {
+		o.config.CipherSweetKeyID = keyID
+	}
+}
+
+// WithHPKEKeys adds X25519 recipient keys, see Config.HPKEPublicKeys
+func WithHPKEKeys(publicKeys, privateKeys map[string][]byte) Option {
+	return func(o *options) {
+		if o.config.HPKEPublicKeys == nil {
+			o.config.HPKEPublicKeys = make(map[string][]byte, len(publicKeys))
+		}
+		for id, key := range publicKeys {
+			o.config.HPKEPublicKeys[id] = key
+		}
+		if o.config.HPKEPrivateKeys == nil {
+			o.config.HPKEPrivateKeys = make(map[string][]byte, len(privateKeys))
+		}
+		for id, key := range privateKeys {
+			o.config.HPKEPrivateKeys[id] = key
+		}
+	}
+}
+
+// WithKeyProvider unwraps keys with provider on first use
+func WithKeyProvider(provider KeyProvider, keys map[string]ProviderKey) Option {
+	return func(o *options) {
+		o.config.KeyProvider = provider
+		if o.config.ProviderKeys == nil {
+			o.config.ProviderKeys = make(map[string]ProviderKey, len(keys))
+		}
+		for id, key := range keys {
+			o.config.ProviderKeys[id] = key
+		}
+	}
+}
+
+// WithRegion sets the provider region tried first and the failover order
+func WithRegion(region string, failover ...string) Option {
+	return func(o *options) {
+		o.config.Region = region
+		o.config.FailoverRegions = failover
+	}
+}
+
+// WithProviderTimeout bounds every key provider call, by default unbounded
+func WithProviderTimeout(timeout time.Duration) Option {
+	return func(o *options) {
+		o.config.ProviderTimeout = timeout
+	}
+}
+
+// WithProviderBreaker skips a provider region for cooldown after threshold
+// consecutive failures, by default disabled
+func WithProviderBreaker(threshold int, cooldown time.Duration) Option {
+	return func(o *options) {
+		o.config.ProviderBreakerThreshold = threshold
+		o.config.ProviderBreakerCooldown = cooldown
+	}
+}
+
+// WithFallback sets what reads return when a provider key is unavailable,
+// by default FallbackFailClosed
+func WithFallback(fallback Fallback) Option {
+	return func(o *options) {
+		o.config.Fallback = fallback
+	}
+}
+
+// WithMode restricts the instance to encrypt-only or decrypt-only
+// operation, by default ModeReadWrite
+func WithMode(mode Mode) Option {
+	return func(o *options) {
+		o.config.Mode = mode
+	}
+}
+
+// WithEncryptEmptyStrings encrypts empty strings, see
+// Config.EncryptEmptyStrings
+func WithEncryptEmptyStrings() Option {
+	return func(o *options) {
+		o.config.EncryptEmptyStrings = true
+	}
+}
+
+// WithEmbedEncryptedAt records the encryption time in ciphertexts, see
+// Config.EmbedEncryptedAt
+func WithEmbedEncryptedAt() Option {
+	return func(o *options) {
+		o.config.EmbedEncryptedAt = true
+	}
+}
+
+// WithNonceCheck enables nonce reuse detection
+func WithNonceCheck(check NonceCheck) Option {
+	return func(o *options) {
+		o.config.NonceCheck = &check
+	}
+}
+
+// WithEnvelopeCodec reads values in a foreign ciphertext format with
+// codec, see Config.EnvelopeCodecs. It may be given more than once.
+func WithEnvelopeCodec(format string, codec EnvelopeCodec) Option {
+	return func(o *options) {
+		if o.config.EnvelopeCodecs == nil {
+			o.config.EnvelopeCodecs = make(map[string]EnvelopeCodec)
+		}
+		o.config.EnvelopeCodecs[format] = codec
+	}
+}
+
+// WithRandReader sets the source of nonces, see Config.RandReader. For
+// replay tests only.
+func WithRandReader(r io.Reader) Option {
+	return func(o *options) {
+		o.config.RandReader = r
+	}
+}
+
+// WithLogger sets the logger of safety warnings, by default slog.Default()
+func WithLogger(logger *slog.Logger) Option {
+	return func(o *options) {
+		o.config.Logger = logger
+	}
+}
+
+// WithDebug enables debug mode
+func WithDebug() Option {
+	return func(o *options) {
+		o.config.DebugMode = true
+	}
+}
+
+// WithModelHook adds a hook seeing the models in plaintext. It requires
+// WithExposePlaintextToHooks.
+func WithModelHook(hook ModelHook) Option {
+	return func(o *options) {
+		o.config.ModelHooks = append(o.config.ModelHooks, hook)
+	}
+}
+
+// WithExposePlaintextToHooks allows model hooks to run
+func WithExposePlaintextToHooks() Option {
+	return func(o *options) {
+		o.config.ExposePlaintextToHooks = true
+	}
+}
+
+// WithHook adds a query hook to the wrapped bun database, e.g. a
+// bun adapter PlaintextGuard. Requires WithBun.
+func WithHook(hook bun.QueryHook) Option {
+	return func(o *options) {
+		o.hooks = append(o.hooks, hook)
+	}
+}
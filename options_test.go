@@ -0,0 +1,34 @@
+package govault_test
+
+import (
+	"testing"
+
+	"github.com/muhammadluth/govault"
+	"github.com/muhammadluth/govault/govaulttest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithConfigLeavesConfigUnchanged(t *testing.T) {
+	keys := govaulttest.EphemeralKeys(1)
+	config := govault.Config{
+		AdapterName:       "memory",
+		AdapterDB:         &memoryStore{rows: make(map[int]adapterUser)},
+		Keys:              keys,
+		DefaultKeyID:      "1",
+		DecryptOnlyKeyIDs: make([]string, 0, 4),
+	}
+
+	_, err := govault.NewWithOptions(
+		govault.WithConfig(config),
+		govault.WithKeys(govaulttest.EphemeralKeys(2)),
+		govault.WithDefaultKey("2"),
+		govault.WithDecryptOnlyKeys("1"),
+	)
+	require.NoError(t, err)
+
+	assert.Len(t, config.Keys, 1)
+	assert.NotContains(t, keys, "2")
+	assert.Empty(t, config.DecryptOnlyKeyIDs)
+	assert.Empty(t, config.DecryptOnlyKeyIDs[:1][0], "appends do not write to the caller's array")
+}
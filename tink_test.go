@@ -0,0 +1,158 @@
+package govault_test
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/muhammadluth/govault"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	tinkKey1 = []byte("727d37a0-a5f2-4d67-af47-83039c8e")
+	tinkKey2 = []byte("e778dc27-9b04-44c3-a862-feba061c")
+)
+
+// aesGcmKeyProto serializes an AesGcmKey message
+func aesGcmKeyProto(key []byte) []byte {
+	return append([]byte{0x08, 0x00, 0x1a, byte(len(key))}, key...)
+}
+
+func protoBytes(num int, b []byte) []byte {
+	return append([]byte{byte(num<<3 | 2), byte(len(b))}, b...)
+}
+
+func protoVarint(num int, v byte) []byte {
+	return []byte{byte(num << 3), v}
+}
+
+func tinkKeysetJSON(primary int, keys map[int][]byte) []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, `{"primaryKeyId":%d,"key":[`, primary)
+	first := true
+	for id, key := range keys {
+		if !first {
+			buf.WriteString(",")
+		}
+		first = false
+		fmt.Fprintf(&buf, `{"keyData":{"typeUrl":"type.googleapis.com/google.crypto.tink.AesGcmKey","value":"%s","keyMaterialType":"SYMMETRIC"},"status":"ENABLED","keyId":%d,"outputPrefixType":"TINK"}`,
+			base64.StdEncoding.EncodeToString(aesGcmKeyProto(key)), id)
+	}
+	buf.WriteString("]}")
+	return buf.Bytes()
+}
+
+type xorAEAD struct{ fail bool }
+
+func (a xorAEAD) Decrypt(ciphertext, _ []byte) ([]byte, error) {
+	if a.fail {
+		return nil, errors.New("kms unavailable")
+	}
+	out := make([]byte, len(ciphertext))
+	for i, b := range ciphertext {
+		out[i] = b ^ 0x5a
+	}
+	return out, nil
+}
+
+func TestLoadTinkKeyset(t *testing.T) {
+	t.Run("cleartext keyset", func(t *testing.T) {
+		keyset, err := govault.LoadTinkKeyset(tinkKeysetJSON(42, map[int][]byte{42: tinkKey1, 7: tinkKey2}))
+		require.NoError(t, err)
+		assert.Equal(t, "42", keyset.PrimaryKeyID)
+		assert.Equal(t, tinkKey1, keyset.Keys["42"])
+		assert.Equal(t, tinkKey2, keyset.Keys["7"])
+	})
+
+	t.Run("missing primary key", func(t *testing.T) {
+		_, err := govault.LoadTinkKeyset(tinkKeysetJSON(1, map[int][]byte{42: tinkKey1}))
+		assert.Error(t, err)
+	})
+
+	t.Run("wrong key size", func(t *testing.T) {
+		_, err := govault.LoadTinkKeyset(tinkKeysetJSON(42, map[int][]byte{42: tinkKey1[:16]}))
+		assert.Error(t, err)
+	})
+
+	t.Run("encrypted keyset", func(t *testing.T) {
+		keyData := append(
+			protoBytes(1, []byte("type.googleapis.com/google.crypto.tink.AesGcmKey")),
+			protoBytes(2, aesGcmKeyProto(tinkKey1))...,
+		)
+		key := append(protoBytes(1, keyData), protoVarint(2, 1)...)
+		key = append(key, protoVarint(3, 42)...)
+		key = append(key, protoVarint(4, 1)...)
+		serialized := append(protoVarint(1, 42), protoBytes(2, key)...)
+
+		encrypted := make([]byte, len(serialized))
+		for i, b := range serialized {
+			encrypted[i] = b ^ 0x5a
+		}
+		data := fmt.Sprintf(`{"encryptedKeyset":"%s"}`, base64.StdEncoding.EncodeToString(encrypted))
+
+		keyset, err := govault.LoadEncryptedTinkKeyset([]byte(data), xorAEAD{})
+		require.NoError(t, err)
+		assert.Equal(t, "42", keyset.PrimaryKeyID)
+		assert.Equal(t, tinkKey1, keyset.Keys["42"])
+
+		_, err = govault.LoadEncryptedTinkKeyset([]byte(data), xorAEAD{fail: true})
+		assert.Error(t, err)
+	})
+}
+
+// tinkWrittenKeyset and tinkCiphertext were written by tink-go v2.8.0: the
+// keyset holds tinkKey1 as key 42 and the ciphertext encrypts
+// "john@example.com" without associated data
+const (
+	tinkWrittenKeyset = `{"primaryKeyId":42, "key":[{"keyData":{"typeUrl":"type.googleapis.com/google.crypto.tink.AesGcmKey", "value":"GiA3MjdkMzdhMC1hNWYyLTRkNjctYWY0Ny04MzAzOWM4ZQ==", "keyMaterialType":"SYMMETRIC"}, "status":"ENABLED", "keyId":42, "outputPrefixType":"TINK"}]}`
+	tinkCiphertext    = "AQAAACp9v6WlTwV6cRABXz6PalP2UwZze6zL8Y15J4qIxGV0h1iYVkpa2tS9/ap9YQ=="
+)
+
+func TestTinkCodecKnownAnswer(t *testing.T) {
+	keyset, err := govault.LoadTinkKeyset([]byte(tinkWrittenKeyset))
+	require.NoError(t, err)
+	govaultDB, err := govault.NewWithOptions(
+		govault.WithAdapter("memory", &memoryStore{}),
+		govault.WithKeys(keyset.Keys),
+		govault.WithDefaultKey(keyset.PrimaryKeyID),
+	)
+	require.NoError(t, err)
+	field := &govault.FieldSpec{Column: "email", Codec: govault.CodecTink}
+
+	plaintext, encrypted, err := govaultDB.DecryptField(field, "users", tinkCiphertext)
+	require.NoError(t, err)
+	assert.True(t, encrypted)
+	assert.Equal(t, "john@example.com", plaintext)
+
+	t.Run("round trip", func(t *testing.T) {
+		ciphertext, err := govaultDB.EncryptField(field, "users", "john@example.com", "")
+		require.NoError(t, err)
+		assert.Equal(t, tinkCiphertext[:6], ciphertext[:6], "the prefix carries key 42")
+		plaintext, _, err := govaultDB.DecryptField(field, "users", ciphertext)
+		require.NoError(t, err)
+		assert.Equal(t, "john@example.com", plaintext)
+	})
+
+	t.Run("not ciphertexts", func(t *testing.T) {
+		for _, value := range []string{
+			"john@example.com",
+			"AQAAACp9v6WlTwV6cRABXz6PalP2UwZz",             // too short for a nonce and tag
+			tinkCiphertext[:len(tinkCiphertext)-3] + "R==", // non-canonical base64
+		} {
+			_, encrypted, err := govaultDB.DecryptField(field, "users", value)
+			require.NoError(t, err)
+			assert.False(t, encrypted, value)
+		}
+	})
+
+	t.Run("RAW keys are rejected", func(t *testing.T) {
+		raw := strings.Replace(tinkWrittenKeyset, `"outputPrefixType":"TINK"`, `"outputPrefixType":"RAW"`, 1)
+		_, err := govault.LoadTinkKeyset([]byte(raw))
+		assert.ErrorContains(t, err, "tink key 42: output prefix type RAW is not supported")
+	})
+}